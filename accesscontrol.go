@@ -0,0 +1,148 @@
+package keychain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// AccessControlKey is key type for kSecAttrAccessControl.
+var AccessControlKey = "accc"
+
+// UseOperationPromptKey is key type for kSecUseOperationPrompt.
+var UseOperationPromptKey = "u_OpPrompt"
+
+// UseAuthenticationUIKey is key type for kSecUseAuthenticationUI.
+var UseAuthenticationUIKey = "u_AuthUI"
+
+// UseAuthenticationContextKey is key type for kSecUseAuthenticationContext.
+var UseAuthenticationContextKey = "u_AuthCtx"
+
+// AccessControlFlags are the conditions of an AccessControl, with the values
+// of SecAccessControlCreateFlags. Several conditions all have to be satisfied
+// unless AccessControlOr is set.
+type AccessControlFlags uint64
+
+const (
+	// AccessControlUserPresence requires biometry or the device passcode.
+	AccessControlUserPresence AccessControlFlags = 1 << 0
+	// AccessControlBiometryAny requires Touch ID or Face ID, with any finger
+	// or face enrolled, even later.
+	AccessControlBiometryAny AccessControlFlags = 1 << 1
+	// AccessControlBiometryCurrentSet requires Touch ID or Face ID, with the
+	// fingers or face enrolled when the item was added.
+	AccessControlBiometryCurrentSet AccessControlFlags = 1 << 3
+	// AccessControlDevicePasscode requires the device passcode (or the login
+	// password on macOS).
+	AccessControlDevicePasscode AccessControlFlags = 1 << 4
+	// AccessControlCompanion requires approval from a paired companion
+	// device, e.g. an Apple Watch, see FeatureCompanionApproval.
+	AccessControlCompanion AccessControlFlags = 1 << 5
+	// AccessControlWatch is the name of AccessControlCompanion before macOS
+	// 15.
+	AccessControlWatch = AccessControlCompanion
+	// AccessControlOr requires any of the other conditions.
+	AccessControlOr AccessControlFlags = 1 << 14
+	// AccessControlAnd requires all of the other conditions, which is the
+	// default.
+	AccessControlAnd AccessControlFlags = 1 << 15
+	// AccessControlPrivateKeyUsage allows using a Secure Enclave private key.
+	AccessControlPrivateKeyUsage AccessControlFlags = 1 << 30
+	// AccessControlApplicationPassword requires an application password.
+	AccessControlApplicationPassword AccessControlFlags = 1 << 31
+)
+
+// AccessControl protects an item behind authentication, e.g. Touch ID or the
+// device passcode (SecAccessControlCreateWithFlags). For example, to require
+// the current biometry or the passcode:
+//
+//	item.SetAccessControl(keychain.AccessControl{
+//		Accessible: keychain.AccessibleWhenPasscodeSetThisDeviceOnly,
+//		Flags: keychain.AccessControlBiometryCurrentSet | keychain.AccessControlDevicePasscode |
+//			keychain.AccessControlOr,
+//	})
+//
+// Reading the data of the item then shows an authentication prompt, see
+// Item.SetOperationPrompt, Item.SetAuthenticationUI and
+// Item.SetAuthenticationContext. Backends supporting access control report
+// Capability.Biometrics.
+type AccessControl struct {
+	// Accessible is when the item is accessible, replacing the accessible
+	// attribute. It defaults to AccessibleWhenUnlockedThisDeviceOnly.
+	Accessible Accessible
+	// Flags are the authentication conditions.
+	Flags AccessControlFlags
+}
+
+// SetAccessControl protects the item with ac. The accessibility of the item is
+// replaced by that of ac.
+func (k *Item) SetAccessControl(ac AccessControl) {
+	if ac.Accessible == AccessibleDefault {
+		ac.Accessible = AccessibleWhenUnlockedThisDeviceOnly
+	}
+
+	k.attr[AccessControlKey] = ac
+	delete(k.attr, AccessibleKey)
+}
+
+// encode checks the access control.
+func (ac AccessControl) encode() (AccessControl, error) {
+	if _, ok := accessibleValues[ac.Accessible]; !ok {
+		return ac, fmt.Errorf("unknown Accessible %d", ac.Accessible)
+	}
+
+	if ac.Flags&AccessControlOr != 0 && ac.Flags&AccessControlAnd != 0 {
+		return ac, errors.New("both AccessControlOr and AccessControlAnd set")
+	}
+
+	return ac, nil
+}
+
+// AuthenticationUI is whether a query may show an authentication prompt.
+type AuthenticationUI int
+
+const (
+	// AuthenticationUIDefault is the default, AuthenticationUIAllow.
+	AuthenticationUIDefault AuthenticationUI = iota
+	// AuthenticationUIAllow shows a prompt for items needing authentication.
+	AuthenticationUIAllow
+	// AuthenticationUIFail fails with ErrorInteractionNotAllowed instead of
+	// showing a prompt.
+	AuthenticationUIFail
+	// AuthenticationUISkip silently skips the items needing authentication.
+	AuthenticationUISkip
+)
+
+var authenticationUIValues = map[AuthenticationUI]string{
+	AuthenticationUIAllow: "u_AuthUIA",
+	AuthenticationUIFail:  "u_AuthUIF",
+	AuthenticationUISkip:  "u_AuthUIS",
+}
+
+// SetOperationPrompt sets the message of the authentication prompt shown for
+// items protected by an AccessControl, e.g. "Sign in to MyService".
+func (k *Item) SetOperationPrompt(s string) {
+	k.SetString(UseOperationPromptKey, s)
+}
+
+// SetAuthenticationUI sets whether the query may show an authentication
+// prompt.
+func (k *Item) SetAuthenticationUI(ui AuthenticationUI) {
+	if ui != AuthenticationUIDefault {
+		k.attr[UseAuthenticationUIKey] = ui
+	} else {
+		delete(k.attr, UseAuthenticationUIKey)
+	}
+}
+
+// SetAuthenticationContext sets the authentication context of the query, so
+// that a user authenticated once is not prompted again for other items while
+// the context is valid. It also applies to the query of UpdateItem, so that an
+// item read with a context can be updated without another prompt, see
+// ModifyItem. A nil context removes it.
+func (k *Item) SetAuthenticationContext(ctx *AuthenticationContext) {
+	if ctx != nil {
+		k.attr[UseAuthenticationContextKey] = ctx
+	} else {
+		delete(k.attr, UseAuthenticationContextKey)
+	}
+}
@@ -0,0 +1,170 @@
+//go:build darwin
+// +build darwin
+
+package keychain
+
+/*
+#cgo LDFLAGS: -framework CoreFoundation -framework Security -framework LocalAuthentication -lobjc
+
+#include <CoreFoundation/CoreFoundation.h>
+#include <Security/Security.h>
+#include <objc/message.h>
+#include <objc/runtime.h>
+
+static SecAccessControlRef createAccessControl(CFTypeRef protection, unsigned long long flags, CFErrorRef *error) {
+	return SecAccessControlCreateWithFlags(NULL, protection, (SecAccessControlCreateFlags)flags, error);
+}
+
+// newAuthenticationContext returns a new LAContext, or NULL if the
+// LocalAuthentication framework isn't available.
+static CFTypeRef newAuthenticationContext(void) {
+	Class cls = objc_getClass("LAContext");
+	if (cls == Nil) {
+		return NULL;
+	}
+
+	return (CFTypeRef)((id (*)(Class, SEL))objc_msgSend)(cls, sel_registerName("new"));
+}
+
+static int respondsTo(CFTypeRef obj, SEL sel) {
+	return class_respondsToSelector(object_getClass((id)obj), sel);
+}
+
+static void setReuseDuration(CFTypeRef ctx, double seconds) {
+	SEL sel = sel_registerName("setTouchIDAuthenticationAllowableReuseDuration:");
+	if (respondsTo(ctx, sel)) {
+		((void (*)(id, SEL, double))objc_msgSend)((id)ctx, sel, seconds);
+	}
+}
+
+static void invalidateAuthenticationContext(CFTypeRef ctx) {
+	SEL sel = sel_registerName("invalidate");
+	if (respondsTo(ctx, sel)) {
+		((void (*)(id, SEL))objc_msgSend)((id)ctx, sel);
+	}
+}
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+	"weak"
+)
+
+// Convert implements Convertable, creating a SecAccessControl.
+func (ac AccessControl) Convert() (C.CFTypeRef, error) {
+	protection, ok := securityConstants[accessibleValues[ac.Accessible]]
+	if !ok {
+		return 0, fmt.Errorf("unknown Accessible %d", ac.Accessible)
+	}
+
+	var cfErr C.CFErrorRef
+
+	ref := C.createAccessControl(protection, C.ulonglong(ac.Flags), &cfErr) // nolint: nlreturn
+	if ref == 0 {
+		return 0, fmt.Errorf("failed to create access control: %w", cfError(cfErr))
+	}
+
+	return C.CFTypeRef(ref), nil
+}
+
+// AuthenticationContext is an LAContext, the authentication of the user for
+// reading items protected by an AccessControl. Passing the same context to
+// several queries with Item.SetAuthenticationContext prompts only once.
+type AuthenticationContext struct {
+	mu  sync.Mutex
+	ref C.CFTypeRef // 0 once closed
+	id  uint64
+}
+
+// openContexts are the authentication contexts not closed yet, by id, for
+// Shutdown. They are weak so that the finalizer still closes those which
+// are dropped.
+var openContexts = struct {
+	sync.Mutex
+	next     uint64
+	contexts map[uint64]weak.Pointer[AuthenticationContext]
+}{contexts: make(map[uint64]weak.Pointer[AuthenticationContext])}
+
+// NewAuthenticationContext returns a new authentication context. Close it
+// when done.
+func NewAuthenticationContext() (*AuthenticationContext, error) {
+	ref := C.newAuthenticationContext() // nolint: nlreturn
+	if ref == 0 {
+		return nil, ErrorUnimplemented
+	}
+
+	ctx := &AuthenticationContext{ref: ref}
+	runtime.SetFinalizer(ctx, (*AuthenticationContext).Close)
+
+	openContexts.Lock()
+	openContexts.next++
+	ctx.id = openContexts.next
+	openContexts.contexts[ctx.id] = weak.Make(ctx)
+	openContexts.Unlock()
+
+	return ctx, nil
+}
+
+// SetReuseDuration sets how long after unlocking the device a Touch ID
+// authentication is not required again (up to 5 minutes).
+func (c *AuthenticationContext) SetReuseDuration(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ref != 0 {
+		C.setReuseDuration(c.ref, C.double(d.Seconds()))
+	}
+}
+
+// Convert implements Convertable.
+func (c *AuthenticationContext) Convert() (C.CFTypeRef, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ref == 0 {
+		return 0, errors.New("authentication context closed")
+	}
+
+	return C.CFRetain(c.ref), nil
+}
+
+// Close invalidates the context, so items protected by an AccessControl need
+// a new authentication. Closing a closed context has no effect.
+func (c *AuthenticationContext) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ref != 0 {
+		C.invalidateAuthenticationContext(c.ref)
+		Release(c.ref)
+		c.ref = 0
+		runtime.SetFinalizer(c, nil)
+
+		openContexts.Lock()
+		delete(openContexts.contexts, c.id)
+		openContexts.Unlock()
+	}
+
+	return nil
+}
+
+// closeAuthenticationContexts closes the authentication contexts which are
+// still open.
+func closeAuthenticationContexts() {
+	openContexts.Lock()
+	contexts := make([]*AuthenticationContext, 0, len(openContexts.contexts))
+	for _, p := range openContexts.contexts {
+		if c := p.Value(); c != nil {
+			contexts = append(contexts, c)
+		}
+	}
+	openContexts.Unlock()
+
+	for _, c := range contexts {
+		_ = c.Close()
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !darwin
+// +build !darwin
+
+package keychain
+
+import "time"
+
+// AuthenticationContext is an LAContext, the authentication of the user for
+// reading items protected by an AccessControl. It is only available on macOS
+// and iOS.
+type AuthenticationContext struct{}
+
+// NewAuthenticationContext returns ErrorUnimplemented.
+func NewAuthenticationContext() (*AuthenticationContext, error) {
+	return nil, ErrorUnimplemented
+}
+
+// SetReuseDuration has no effect.
+func (c *AuthenticationContext) SetReuseDuration(time.Duration) {}
+
+// Close has no effect.
+func (c *AuthenticationContext) Close() error {
+	return nil
+}
+
+// closeAuthenticationContexts has no effect.
+func closeAuthenticationContexts() {}
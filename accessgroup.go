@@ -0,0 +1,157 @@
+package keychain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// AppGroupPrefix is the prefix of iOS style application group identifiers,
+// e.g. "group.com.example.shared".
+const AppGroupPrefix = "group."
+
+// AccessGroupKind is the kind of a keychain access group identifier.
+type AccessGroupKind int
+
+const (
+	// AccessGroupKeychain is a team prefixed keychain access group, e.g.
+	// "ABCDE12345.com.example.shared", from the keychain-access-groups
+	// entitlement. On macOS, application groups also use this form.
+	AccessGroupKeychain AccessGroupKind = iota
+	// AccessGroupAppGroup is an application group, e.g.
+	// "group.com.example.shared", from the application-groups entitlement.
+	AccessGroupAppGroup
+)
+
+// AccessGroup is a parsed keychain access group.
+type AccessGroup struct {
+	Kind AccessGroupKind
+	// TeamID is the team (app identifier) prefix, for AccessGroupKeychain.
+	TeamID string
+	// Identifier is the remainder, e.g. "com.example.shared".
+	Identifier string
+}
+
+// String returns the access group identifier.
+func (g AccessGroup) String() string {
+	if g.Kind == AccessGroupAppGroup {
+		return AppGroupPrefix + g.Identifier
+	}
+
+	return g.TeamID + "." + g.Identifier
+}
+
+// Entitlements are the entitlements of a signed binary which determine the
+// access groups it can use.
+type Entitlements struct {
+	// TeamID is the team identifier (app identifier prefix) of the binary.
+	TeamID string
+	// KeychainAccessGroups is the keychain-access-groups entitlement.
+	KeychainAccessGroups []string
+	// ApplicationGroups is the com.apple.security.application-groups
+	// entitlement.
+	ApplicationGroups []string
+}
+
+func isTeamID(s string) bool {
+	if len(s) != 10 {
+		return false
+	}
+
+	for _, c := range s {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ParseAccessGroup parses and validates an access group identifier.
+func ParseAccessGroup(accessGroup string) (AccessGroup, error) {
+	if strings.HasPrefix(accessGroup, AppGroupPrefix) {
+		id := strings.TrimPrefix(accessGroup, AppGroupPrefix)
+		if id == "" {
+			return AccessGroup{}, fmt.Errorf("invalid access group %q: missing application group identifier", accessGroup)
+		}
+
+		return AccessGroup{Kind: AccessGroupAppGroup, Identifier: id}, nil
+	}
+
+	team, id, ok := strings.Cut(accessGroup, ".")
+	if !ok || id == "" {
+		return AccessGroup{}, fmt.Errorf("invalid access group %q: expected TEAMID.identifier or group.identifier", accessGroup)
+	}
+
+	if !isTeamID(team) {
+		return AccessGroup{}, fmt.Errorf("invalid access group %q: %q is not a 10 character team identifier", accessGroup, team)
+	}
+
+	return AccessGroup{Kind: AccessGroupKeychain, TeamID: team, Identifier: id}, nil
+}
+
+// AppGroupAccessGroup returns the access group for an application group, on
+// iOS (and macOS 15 and later) style "group.com.example.shared".
+func AppGroupAccessGroup(identifier string) string {
+	return AppGroupPrefix + strings.TrimPrefix(identifier, AppGroupPrefix)
+}
+
+// TeamAccessGroup returns the team prefixed access group for identifier, as
+// used in keychain-access-groups and macOS application groups.
+func TeamAccessGroup(teamID, identifier string) string {
+	return teamID + "." + identifier
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DiagnoseAccessGroup checks whether a binary with entitlements e can store
+// items in accessGroup, returning an error describing each problem found. An
+// empty access group uses the default group and is always allowed.
+//
+// On macOS, access groups (including application groups) are only honored for
+// items in the data protection keychain, see SetUseDataProtectionKeychain;
+// items in file based keychains ignore them.
+func DiagnoseAccessGroup(accessGroup string, e Entitlements) error {
+	if accessGroup == "" {
+		return nil
+	}
+
+	g, err := ParseAccessGroup(accessGroup)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	inKeychainGroups := contains(e.KeychainAccessGroups, accessGroup)
+	inAppGroups := contains(e.ApplicationGroups, accessGroup)
+
+	switch g.Kind {
+	case AccessGroupAppGroup:
+		if !inAppGroups {
+			errs = append(errs, fmt.Errorf("application group %q is not in the application-groups entitlement", accessGroup))
+		}
+	case AccessGroupKeychain:
+		if e.TeamID != "" && g.TeamID != e.TeamID {
+			errs = append(errs, fmt.Errorf("access group %q has team prefix %q, but the binary is signed by team %q", accessGroup, g.TeamID, e.TeamID))
+		}
+
+		if !inKeychainGroups && !inAppGroups {
+			errs = append(errs, fmt.Errorf("access group %q is in neither the keychain-access-groups nor the application-groups entitlement", accessGroup))
+		}
+	}
+
+	if e.TeamID == "" && len(e.KeychainAccessGroups) == 0 && len(e.ApplicationGroups) == 0 {
+		errs = append(errs, errors.New("binary has no team identifier or access group entitlements; unsigned binaries can't use access groups"))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,62 @@
+package keychain
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAccessGroup(t *testing.T) {
+	g, err := ParseAccessGroup("ABCDE12345.com.example.shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Kind != AccessGroupKeychain || g.TeamID != "ABCDE12345" || g.Identifier != "com.example.shared" {
+		t.Fatalf("unexpected access group: %+v", g)
+	}
+	if g.String() != "ABCDE12345.com.example.shared" {
+		t.Fatalf("unexpected string: %s", g)
+	}
+
+	g, err = ParseAccessGroup("group.com.example.shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Kind != AccessGroupAppGroup || g.Identifier != "com.example.shared" {
+		t.Fatalf("unexpected app group: %+v", g)
+	}
+
+	for _, invalid := range []string{"group.", "com.example.shared", "abcde12345.com.example", "ABCDE12345"} {
+		if _, err := ParseAccessGroup(invalid); err == nil {
+			t.Errorf("expected %q to be invalid", invalid)
+		}
+	}
+}
+
+func TestDiagnoseAccessGroup(t *testing.T) {
+	e := Entitlements{
+		TeamID:               "ABCDE12345",
+		KeychainAccessGroups: []string{"ABCDE12345.com.example.app"},
+		ApplicationGroups:    []string{"group.com.example.shared", "ABCDE12345.com.example.macshared"},
+	}
+
+	for _, ok := range []string{"", "ABCDE12345.com.example.app", "group.com.example.shared", "ABCDE12345.com.example.macshared"} {
+		if err := DiagnoseAccessGroup(ok, e); err != nil {
+			t.Errorf("expected %q to be allowed, got %v", ok, err)
+		}
+	}
+
+	err := DiagnoseAccessGroup("group.com.example.other", e)
+	if err == nil || !strings.Contains(err.Error(), "application-groups") {
+		t.Errorf("expected missing application group error, got %v", err)
+	}
+
+	err = DiagnoseAccessGroup("ZZZZZ12345.com.example.app", e)
+	if err == nil || !strings.Contains(err.Error(), "team prefix") {
+		t.Errorf("expected team mismatch error, got %v", err)
+	}
+
+	err = DiagnoseAccessGroup("ABCDE12345.com.example.app", Entitlements{})
+	if err == nil || !strings.Contains(err.Error(), "unsigned") {
+		t.Errorf("expected unsigned binary error, got %v", err)
+	}
+}
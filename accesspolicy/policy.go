@@ -0,0 +1,237 @@
+// Package accesspolicy builds consistent access policies for keychain items:
+// an AccessControl (SecAccessControl) and the matching accessibility.
+//
+//	policy, err := accesspolicy.New().RequireBiometry().OrPasscode().ThisDeviceOnly().Build()
+//	if err != nil {
+//		return err
+//	}
+//	policy.Apply(&item)
+//
+// Build fails on combinations the keychain rejects or silently ignores, e.g.
+// mixing "or" and "and", or requiring authentication for an item which may
+// be synchronized to other devices.
+package accesspolicy
+
+import (
+	"errors"
+	"fmt"
+
+	keychain "github.com/mailstone/go-keychain"
+)
+
+// ErrInvalidPolicy is wrapped by the errors of Build.
+var ErrInvalidPolicy = errors.New("invalid access policy")
+
+type availability int
+
+const (
+	whenUnlocked availability = iota
+	afterFirstUnlock
+	whenPasscodeSet
+)
+
+// Builder builds a Policy. Its methods return the builder, so calls can be
+// chained, and errors are reported by Build.
+type Builder struct {
+	flags          keychain.AccessControlFlags
+	conditions     int
+	availability   availability
+	thisDeviceOnly bool
+	err            error
+}
+
+// New returns a builder for a policy without authentication, accessible when
+// the device is unlocked.
+func New() *Builder {
+	return &Builder{}
+}
+
+func (b *Builder) fail(format string, args ...interface{}) *Builder {
+	if b.err == nil {
+		b.err = fmt.Errorf("%w: %s", ErrInvalidPolicy, fmt.Sprintf(format, args...))
+	}
+
+	return b
+}
+
+// require adds an authentication condition.
+func (b *Builder) require(flag keychain.AccessControlFlags, name string) *Builder {
+	switch {
+	case b.flags&flag != 0:
+		return b.fail("%s required twice", name)
+	case flag == keychain.AccessControlUserPresence && b.conditions > 0,
+		b.flags&keychain.AccessControlUserPresence != 0:
+		return b.fail("user presence can't be combined with %s", name)
+	case flag|b.flags&(keychain.AccessControlBiometryAny|keychain.AccessControlBiometryCurrentSet) ==
+		keychain.AccessControlBiometryAny|keychain.AccessControlBiometryCurrentSet:
+		return b.fail("both any and current biometry required")
+	}
+
+	b.flags |= flag
+	b.conditions++
+
+	return b
+}
+
+// combine sets how several conditions combine.
+func (b *Builder) combine(op keychain.AccessControlFlags, name string) *Builder {
+	if b.conditions == 0 {
+		return b.fail("%s without a previous condition", name)
+	}
+
+	if b.flags&(keychain.AccessControlOr|keychain.AccessControlAnd)&^op != 0 {
+		return b.fail("both or and and conditions")
+	}
+
+	b.flags |= op
+
+	return b
+}
+
+// RequireBiometry requires Touch ID or Face ID, with the fingers or face
+// enrolled when the item was added.
+func (b *Builder) RequireBiometry() *Builder {
+	return b.require(keychain.AccessControlBiometryCurrentSet, "biometry")
+}
+
+// RequireAnyBiometry requires Touch ID or Face ID, with any finger or face
+// enrolled, even later.
+func (b *Builder) RequireAnyBiometry() *Builder {
+	return b.require(keychain.AccessControlBiometryAny, "any biometry")
+}
+
+// RequirePasscode requires the device passcode (or the login password on
+// macOS).
+func (b *Builder) RequirePasscode() *Builder {
+	return b.require(keychain.AccessControlDevicePasscode, "passcode")
+}
+
+// RequireUserPresence requires biometry or the device passcode, whichever the
+// system chooses. It can't be combined with other conditions.
+func (b *Builder) RequireUserPresence() *Builder {
+	return b.require(keychain.AccessControlUserPresence, "user presence")
+}
+
+// RequireApplicationPassword requires a password provided by the application.
+func (b *Builder) RequireApplicationPassword() *Builder {
+	return b.require(keychain.AccessControlApplicationPassword, "application password")
+}
+
+// RequireWatch requires approval from a paired Apple Watch or other companion
+// device. Adding an item with the policy fails with a
+// *keychain.OSVersionError on systems without it, which
+// keychain.CheckFeature(keychain.FeatureCompanionApproval) tests in advance.
+func (b *Builder) RequireWatch() *Builder {
+	return b.require(keychain.AccessControlCompanion, "watch")
+}
+
+// OrWatch allows approval from a paired Apple Watch instead of the previous
+// conditions, e.g. biometry, like first-party apps do.
+func (b *Builder) OrWatch() *Builder {
+	return b.combine(keychain.AccessControlOr, "or").RequireWatch()
+}
+
+// OrPasscode allows the device passcode instead of the previous conditions.
+func (b *Builder) OrPasscode() *Builder {
+	return b.combine(keychain.AccessControlOr, "or").RequirePasscode()
+}
+
+// AndPasscode requires the device passcode as well as the previous
+// conditions.
+func (b *Builder) AndPasscode() *Builder {
+	return b.combine(keychain.AccessControlAnd, "and").RequirePasscode()
+}
+
+// PrivateKeyUsage allows using a Secure Enclave private key, once the other
+// conditions are satisfied.
+func (b *Builder) PrivateKeyUsage() *Builder {
+	b.flags |= keychain.AccessControlPrivateKeyUsage
+
+	return b
+}
+
+// WhenUnlocked makes the item accessible while the device is unlocked, which
+// is the default.
+func (b *Builder) WhenUnlocked() *Builder {
+	b.availability = whenUnlocked
+
+	return b
+}
+
+// AfterFirstUnlock makes the item accessible once the device has been
+// unlocked after a restart, e.g. for background tasks.
+func (b *Builder) AfterFirstUnlock() *Builder {
+	b.availability = afterFirstUnlock
+
+	return b
+}
+
+// WhenPasscodeSet makes the item accessible while the device is unlocked,
+// only as long as a passcode is set. It implies ThisDeviceOnly.
+func (b *Builder) WhenPasscodeSet() *Builder {
+	b.availability = whenPasscodeSet
+	b.thisDeviceOnly = true
+
+	return b
+}
+
+// ThisDeviceOnly keeps the item on this device, excluding it from backups
+// restored to other devices and from iCloud Keychain.
+func (b *Builder) ThisDeviceOnly() *Builder {
+	b.thisDeviceOnly = true
+
+	return b
+}
+
+// Build returns the policy, or an error wrapping ErrInvalidPolicy for an
+// inconsistent combination.
+func (b *Builder) Build() (Policy, error) {
+	if b.err != nil {
+		return Policy{}, b.err
+	}
+
+	p := Policy{Accessible: b.accessible()}
+
+	if b.flags != 0 {
+		if !b.thisDeviceOnly {
+			return Policy{}, fmt.Errorf("%w: items requiring authentication can't leave the device, set ThisDeviceOnly", ErrInvalidPolicy)
+		}
+
+		p.AccessControl = &keychain.AccessControl{Accessible: p.Accessible, Flags: b.flags}
+	}
+
+	return p, nil
+}
+
+func (b *Builder) accessible() keychain.Accessible {
+	switch {
+	case b.availability == whenPasscodeSet:
+		return keychain.AccessibleWhenPasscodeSetThisDeviceOnly
+	case b.availability == afterFirstUnlock && b.thisDeviceOnly:
+		return keychain.AccessibleAfterFirstUnlockThisDeviceOnly
+	case b.availability == afterFirstUnlock:
+		return keychain.AccessibleAfterFirstUnlock
+	case b.thisDeviceOnly:
+		return keychain.AccessibleWhenUnlockedThisDeviceOnly
+	default:
+		return keychain.AccessibleWhenUnlocked
+	}
+}
+
+// Policy is the access control and accessibility of items.
+type Policy struct {
+	// AccessControl is nil for a policy without authentication conditions.
+	AccessControl *keychain.AccessControl
+	// Accessible is the accessibility of the items, also that of
+	// AccessControl.
+	Accessible keychain.Accessible
+}
+
+// Apply sets the access control, or only the accessibility, of item.
+func (p Policy) Apply(item *keychain.Item) {
+	if p.AccessControl != nil {
+		item.SetAccessControl(*p.AccessControl)
+	} else {
+		item.SetAccessible(p.Accessible)
+	}
+}
@@ -0,0 +1,106 @@
+package accesspolicy_test
+
+import (
+	"errors"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/accesspolicy"
+)
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name       string
+		builder    *accesspolicy.Builder
+		flags      keychain.AccessControlFlags
+		accessible keychain.Accessible
+	}{
+		{
+			"no authentication", accesspolicy.New().AfterFirstUnlock(),
+			0, keychain.AccessibleAfterFirstUnlock,
+		},
+		{
+			"biometry or passcode", accesspolicy.New().RequireBiometry().OrPasscode().ThisDeviceOnly(),
+			keychain.AccessControlBiometryCurrentSet | keychain.AccessControlOr | keychain.AccessControlDevicePasscode,
+			keychain.AccessibleWhenUnlockedThisDeviceOnly,
+		},
+		{
+			"biometry or watch", accesspolicy.New().RequireAnyBiometry().OrWatch().OrPasscode().ThisDeviceOnly(),
+			keychain.AccessControlBiometryAny | keychain.AccessControlOr | keychain.AccessControlCompanion |
+				keychain.AccessControlDevicePasscode,
+			keychain.AccessibleWhenUnlockedThisDeviceOnly,
+		},
+		{
+			"user presence", accesspolicy.New().RequireUserPresence().WhenPasscodeSet(),
+			keychain.AccessControlUserPresence, keychain.AccessibleWhenPasscodeSetThisDeviceOnly,
+		},
+		{
+			"private key", accesspolicy.New().RequireAnyBiometry().PrivateKeyUsage().AfterFirstUnlock().ThisDeviceOnly(),
+			keychain.AccessControlBiometryAny | keychain.AccessControlPrivateKeyUsage,
+			keychain.AccessibleAfterFirstUnlockThisDeviceOnly,
+		},
+	}
+
+	for _, tt := range tests {
+		p, err := tt.builder.Build()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+
+			continue
+		}
+
+		if p.Accessible != tt.accessible {
+			t.Errorf("%s: got accessible %d, want %d", tt.name, p.Accessible, tt.accessible)
+		}
+
+		switch {
+		case tt.flags == 0 && p.AccessControl != nil:
+			t.Errorf("%s: unexpected access control %+v", tt.name, p.AccessControl)
+		case tt.flags != 0 && (p.AccessControl == nil || p.AccessControl.Flags != tt.flags || p.AccessControl.Accessible != tt.accessible):
+			t.Errorf("%s: got access control %+v, want flags %#x", tt.name, p.AccessControl, tt.flags)
+		}
+	}
+}
+
+func TestBuildInconsistent(t *testing.T) {
+	tests := map[string]*accesspolicy.Builder{
+		"synchronizable":          accesspolicy.New().RequireBiometry(),
+		"or and and":              accesspolicy.New().RequireBiometry().OrPasscode().RequireApplicationPassword().AndPasscode().ThisDeviceOnly(),
+		"or first":                accesspolicy.New().OrPasscode().ThisDeviceOnly(),
+		"passcode twice":          accesspolicy.New().RequirePasscode().OrPasscode().ThisDeviceOnly(),
+		"both biometry":           accesspolicy.New().RequireBiometry().RequireAnyBiometry().ThisDeviceOnly(),
+		"user presence and other": accesspolicy.New().RequireUserPresence().OrPasscode().ThisDeviceOnly(),
+		"watch twice":             accesspolicy.New().RequireWatch().OrWatch().ThisDeviceOnly(),
+		"and watch":               accesspolicy.New().RequireBiometry().AndPasscode().OrWatch().ThisDeviceOnly(),
+	}
+
+	for name, b := range tests {
+		if _, err := b.Build(); !errors.Is(err, accesspolicy.ErrInvalidPolicy) {
+			t.Errorf("%s: expected ErrInvalidPolicy, got %v", name, err)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	p, err := accesspolicy.New().RequirePasscode().ThisDeviceOnly().Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item := keychain.NewItem()
+	item.SetAccessible(keychain.AccessibleAfterFirstUnlock)
+	p.Apply(&item)
+
+	attrs, err := keychain.EncodeAttributes(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := attrs[keychain.AccessibleKey]; ok {
+		t.Fatalf("expected the accessibility to be replaced by the access control, got %v", attrs)
+	}
+
+	if _, ok := attrs[keychain.AccessControlKey]; !ok {
+		t.Fatalf("expected an access control, got %v", attrs)
+	}
+}
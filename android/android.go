@@ -0,0 +1,95 @@
+// Package android implements a keychain backend for Android apps, for use with
+// gomobile bind. Go has no access to the Android Keystore, so items are
+// persisted through a Store implemented in Java or Kotlin, typically over
+// EncryptedSharedPreferences with a master key held in the Android Keystore:
+//
+//	class PrefsStore(context: Context) : Android.Store {
+//	    private val prefs = EncryptedSharedPreferences.create(
+//	        context, "go-keychain",
+//	        MasterKey.Builder(context).setKeyScheme(MasterKey.KeyScheme.AES256_GCM).build(),
+//	        EncryptedSharedPreferences.PrefKeyEncryptionScheme.AES256_SIV,
+//	        EncryptedSharedPreferences.PrefValueEncryptionScheme.AES256_GCM)
+//
+//	    override fun get(key: String): ByteArray? =
+//	        prefs.getString(key, null)?.let { Base64.decode(it, Base64.NO_WRAP) }
+//
+//	    override fun set(key: String, value: ByteArray) {
+//	        prefs.edit().putString(key, Base64.encodeToString(value, Base64.NO_WRAP)).commit()
+//	    }
+//	}
+//
+//	Android.register(PrefsStore(context))
+//
+// After Register, the keychain package (and libraries built on it) work as on
+// macOS, with the same API.
+package android
+
+import (
+	"fmt"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/internal/secitem"
+)
+
+// Name is the name the backend is registered under, see keychain.UseBackend.
+const Name = "android"
+
+// storeKey is the Store key the items are saved under.
+const storeKey = "go-keychain"
+
+// Store is a persistent, encrypted key value store implemented on the Java
+// side.
+type Store interface {
+	// Get returns the value saved for key, or nil if there is none.
+	Get(key string) ([]byte, error)
+	// Set saves value for key, replacing any previous value. It must not
+	// return before the value is persisted.
+	Set(key string, value []byte) error
+}
+
+// Open returns a backend persisting items to store.
+func Open(store Store) (keychain.Backend, error) {
+	s, err := secitem.Open(storage{store})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Android keychain: %w", err)
+	}
+
+	return s, nil
+}
+
+// Register registers the backend for store and makes it the backend used by
+// the keychain package.
+func Register(store Store) error {
+	keychain.RegisterBackend(Name, func() (keychain.Backend, error) {
+		return Open(store)
+	})
+
+	return keychain.UseBackend(Name)
+}
+
+// storage adapts a Store to secitem.Storage.
+type storage struct {
+	store Store
+}
+
+func (s storage) Load() ([]byte, error) {
+	b, err := s.store.Get(storeKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", storeKey, err)
+	}
+
+	// gomobile passes empty byte arrays as nil.
+	if len(b) == 0 {
+		return nil, nil
+	}
+
+	return b, nil
+}
+
+func (s storage) Save(b []byte) error {
+	if err := s.store.Set(storeKey, b); err != nil {
+		return fmt.Errorf("failed to set %s: %w", storeKey, err)
+	}
+
+	return nil
+}
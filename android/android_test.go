@@ -0,0 +1,85 @@
+package android_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/android"
+	"github.com/mailstone/go-keychain/backendtest"
+)
+
+// memStore is a Store in memory, as the Java side would implement it.
+type memStore struct {
+	values map[string][]byte
+	fail   bool
+}
+
+func (m *memStore) Get(key string) ([]byte, error) {
+	return m.values[key], nil
+}
+
+func (m *memStore) Set(key string, value []byte) error {
+	if m.fail {
+		return errors.New("disk full")
+	}
+
+	m.values[key] = append([]byte(nil), value...)
+
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	store := &memStore{values: make(map[string][]byte)}
+
+	prev := keychain.SetBackend(nil)
+	defer keychain.SetBackend(prev)
+
+	if err := android.Register(store); err != nil {
+		t.Fatal(err)
+	}
+
+	item := keychain.NewGenericPassword("android-test", "alice", "", []byte("secret"), "")
+	if err := keychain.AddItem(item); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.values) != 1 {
+		t.Fatalf("expected the items to be saved to the store, got %d values", len(store.values))
+	}
+
+	// Reopening the store sees the saved item.
+	if err := keychain.UseBackend(android.Name); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := keychain.GetGenericPassword("android-test", "alice", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, []byte("secret")) {
+		t.Fatalf("expected secret, got %q", data)
+	}
+
+	store.fail = true
+	if err := keychain.DeleteGenericPasswordItem("android-test", "alice"); err == nil {
+		t.Fatal("expected the delete to fail when the store can't save")
+	}
+
+	store.fail = false
+	data, err = keychain.GetGenericPassword("android-test", "alice", "", "")
+	if err != nil || data == nil {
+		t.Fatalf("expected the item to survive a failed delete, got %q, %v", data, err)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	b, err := android.Open(&memStore{values: make(map[string][]byte)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backendtest.RunConformance(t, b)
+}
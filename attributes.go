@@ -0,0 +1,513 @@
+package keychain
+
+import "time"
+
+// The attribute keys and values below are the string values of the
+// corresponding Security framework constants, so items can be built and
+// encoded (see EncodeAttributes) on any platform.
+
+// SecClass is the items class code.
+type SecClass int
+
+// Keychain Item Classes.
+var (
+	/*
+		kSecClassGenericPassword item attributes:
+		 kSecAttrAccess (OS X only)
+		 kSecAttrAccessGroup (iOS; also OS X if kSecAttrSynchronizable specified)
+		 kSecAttrAccessible (iOS; also OS X if kSecAttrSynchronizable specified)
+		 kSecAttrAccount
+		 kSecAttrService
+	*/
+	SecClassGenericPassword  SecClass = 1
+	SecClassInternetPassword SecClass = 2
+	SecClassCertificate      SecClass = 3
+	SecClassPairKey          SecClass = 4
+	SecClassIdentity         SecClass = 5
+)
+
+// SecClassKey is the key type for SecClass.
+var SecClassKey = "class"
+
+var (
+	// ServiceKey is for kSecAttrService.
+	ServiceKey = "svce"
+
+	// ServerKey is for kSecAttrServer.
+	ServerKey = "srvr"
+	// ProtocolKey is for kSecAttrProtocol.
+	ProtocolKey = "ptcl"
+	// AuthenticationTypeKey is for kSecAttrAuthenticationType.
+	AuthenticationTypeKey = "atyp"
+	// PortKey is for kSecAttrPort.
+	PortKey = "port"
+	// PathKey is for kSecAttrPath.
+	PathKey = "path"
+
+	// LabelKey is for kSecAttrLabel.
+	LabelKey = "labl"
+	// AccountKey is for kSecAttrAccount.
+	AccountKey = "acct"
+	// AccessGroupKey is for kSecAttrAccessGroup.
+	AccessGroupKey = "agrp"
+	// DataKey is for kSecValueData.
+	DataKey = "v_Data"
+	// DescriptionKey is for kSecAttrDescription.
+	DescriptionKey = "desc"
+	// CommentKey is for kSecAttrComment.
+	CommentKey = "icmt"
+	// CreationDateKey is for kSecAttrCreationDate.
+	CreationDateKey = "cdat"
+	// ModificationDateKey is for kSecAttrModificationDate.
+	ModificationDateKey = "mdat"
+	// GenericKey is for kSecAttrGeneric.
+	GenericKey = "gena"
+	// TypeKey is for kSecAttrType.
+	TypeKey = "type"
+	// CreatorKey is for kSecAttrCreator.
+	CreatorKey = "crtr"
+
+	// InvisibleKey is for kSecAttrIsInvisible.
+	InvisibleKey = "invi"
+
+	// ApplicationTagKey is for kSecAttrApplicationTag, of key items.
+	ApplicationTagKey = "atag"
+	// KeySizeInBitsKey is for kSecAttrKeySizeInBits, of key items. The type of
+	// key items (kSecAttrKeyType) has the TypeKey.
+	KeySizeInBitsKey = "bsiz"
+)
+
+// Synchronizable is the items synchronizable status.
+type Synchronizable int
+
+const (
+	// SynchronizableDefault is the default setting.
+	SynchronizableDefault Synchronizable = 0
+	// SynchronizableAny is for kSecAttrSynchronizableAny.
+	SynchronizableAny = 1
+	// SynchronizableYes enables synchronization.
+	SynchronizableYes = 2
+	// SynchronizableNo disables synchronization.
+	SynchronizableNo = 3
+)
+
+// SynchronizableKey is the key type for Synchronizable.
+var SynchronizableKey = "sync"
+
+// Accessible is the items accessibility.
+type Accessible int
+
+const (
+	// AccessibleDefault is the default.
+	AccessibleDefault Accessible = 0
+	// AccessibleWhenUnlocked is when unlocked.
+	AccessibleWhenUnlocked = 1
+	// AccessibleAfterFirstUnlock is after first unlock.
+	AccessibleAfterFirstUnlock = 2
+	// AccessibleAlways is always.
+	AccessibleAlways = 3
+	// AccessibleWhenPasscodeSetThisDeviceOnly is when passcode is set.
+	AccessibleWhenPasscodeSetThisDeviceOnly = 4
+	// AccessibleWhenUnlockedThisDeviceOnly is when unlocked for this device only.
+	AccessibleWhenUnlockedThisDeviceOnly = 5
+	// AccessibleAfterFirstUnlockThisDeviceOnly is after first unlock for this device only.
+	AccessibleAfterFirstUnlockThisDeviceOnly = 6
+	// AccessibleAccessibleAlwaysThisDeviceOnly is always for this device only.
+	AccessibleAccessibleAlwaysThisDeviceOnly = 7
+)
+
+// AccessibleKey is key for kSecAttrAccessible.
+var AccessibleKey = "pdmn"
+
+// MatchLimit is whether to limit results on query.
+type MatchLimit int
+
+const (
+	// MatchLimitDefault is the default.
+	MatchLimitDefault MatchLimit = 0
+	// MatchLimitOne limits to one result.
+	MatchLimitOne = 1
+	// MatchLimitAll is no limit.
+	MatchLimitAll = 2
+)
+
+// MatchLimitKey is key type for MatchLimit.
+var MatchLimitKey = "m_Limit"
+
+// ReturnAttributesKey is key type for kSecReturnAttributes.
+var ReturnAttributesKey = "r_Attributes"
+
+// ReturnDataKey is key type for kSecReturnData.
+var ReturnDataKey = "r_Data"
+
+// ReturnRefKey is key type for kSecReturnRef.
+var ReturnRefKey = "r_Ref"
+
+// UseDataProtectionKeychainKey is key type for kSecUseDataProtectionKeychain.
+var UseDataProtectionKeychainKey = "nleg"
+
+// ReturnPersistentRefKey is key type for kSecReturnPersistentRef.
+var ReturnPersistentRefKey = "r_PersistentRef"
+
+// ValuePersistentRefKey is key type for kSecValuePersistentRef.
+var ValuePersistentRefKey = "v_PersistentRef"
+
+// MatchItemListKey is key type for kSecMatchItemList.
+var MatchItemListKey = "m_ItemList"
+
+// UseKeychainKey is key type for kSecUseKeychain.
+var UseKeychainKey = "u_Keychain"
+
+// MatchSearchListKey is key type for kSecMatchSearchList.
+var MatchSearchListKey = "m_SearchList"
+
+// Item for adding, querying or deleting.
+type Item struct {
+	// Values can be string, []byte, int32, bool, SecClass, Synchronizable,
+	// Accessible, MatchLimit, AccessControl, AuthenticationUI or a platform
+	// specific value (e.g. Convertable).
+	attr map[string]interface{}
+	// matchNormalized is set by SetMatchNormalized.
+	matchNormalized bool
+}
+
+// SetSecClass sets the security class.
+func (k *Item) SetSecClass(sc SecClass) {
+	k.attr[SecClassKey] = sc
+}
+
+// clone returns a copy of the item that can be modified independently.
+func (k *Item) clone() Item {
+	c := Item{attr: make(map[string]interface{}, len(k.attr)), matchNormalized: k.matchNormalized}
+	for key, v := range k.attr {
+		c.attr[key] = v
+	}
+
+	return c
+}
+
+// secClass returns the security class set on the item, if any.
+func (k *Item) secClass() SecClass {
+	sc, _ := k.attr[SecClassKey].(SecClass)
+
+	return sc
+}
+
+// SetInt32 sets an int32 attribute for a string key.
+func (k *Item) SetInt32(key string, v int32) {
+	if v != 0 {
+		k.attr[key] = v
+	} else {
+		delete(k.attr, key)
+	}
+}
+
+// SetBool sets a bool attribute for a string key.
+func (k *Item) SetBool(key string, b bool) {
+	k.attr[key] = b
+}
+
+// SetBytes sets a []byte attribute for a string key. A nil b removes it.
+func (k *Item) SetBytes(key string, b []byte) {
+	if b != nil {
+		k.attr[key] = b
+	} else {
+		delete(k.attr, key)
+	}
+}
+
+// SetString sets a string attibute for a string key.
+func (k *Item) SetString(key string, s string) {
+	if s != "" {
+		k.attr[key] = s
+	} else {
+		delete(k.attr, key)
+	}
+}
+
+// SetService sets the service attribute (for generic application items).
+func (k *Item) SetService(s string) {
+	k.SetString(ServiceKey, s)
+}
+
+// SetServer sets the server attribute (for internet password items).
+func (k *Item) SetServer(s string) {
+	k.SetString(ServerKey, s)
+}
+
+// SetProtocol sets the protocol attribute (for internet password items).
+// Example values are: "htps", "http", "smb ".
+func (k *Item) SetProtocol(s string) {
+	k.SetString(ProtocolKey, s)
+}
+
+// SetAuthenticationType sets the authentication type attribute (for internet password items).
+func (k *Item) SetAuthenticationType(s string) {
+	k.SetString(AuthenticationTypeKey, s)
+}
+
+// SetPort sets the port attribute (for internet password items). Items added
+// without a port get the default port of their protocol, e.g. 443 for "htps",
+// and queries for the default port also match items stored without one.
+func (k *Item) SetPort(v int32) {
+	k.SetInt32(PortKey, v)
+}
+
+// SetPath sets the path attribute (for internet password items).
+func (k *Item) SetPath(s string) {
+	k.SetString(PathKey, s)
+}
+
+// SetAccount sets the account attribute.
+func (k *Item) SetAccount(a string) {
+	k.SetString(AccountKey, a)
+}
+
+// SetLabel sets the label attribute.
+func (k *Item) SetLabel(l string) {
+	k.SetString(LabelKey, l)
+}
+
+// SetDescription sets the description attribute.
+func (k *Item) SetDescription(s string) {
+	k.SetString(DescriptionKey, s)
+}
+
+// SetComment sets the comment attribute.
+func (k *Item) SetComment(s string) {
+	k.SetString(CommentKey, s)
+}
+
+// SetInvisible sets whether the item is hidden from keychain UIs, such as
+// Keychain Access. Queries still return invisible items.
+func (k *Item) SetInvisible(b bool) {
+	k.attr[InvisibleKey] = b
+}
+
+// SetGeneric sets the generic attribute (for generic password items). It is
+// used by this package to store item metadata, see SetMetadata.
+func (k *Item) SetGeneric(b []byte) {
+	if b != nil {
+		k.attr[GenericKey] = b
+	} else {
+		delete(k.attr, GenericKey)
+	}
+}
+
+// SetType sets the type attribute to a four character code, e.g. "note".
+// An empty code clears it.
+func (k *Item) SetType(code string) error {
+	return k.setFourCharCode(TypeKey, code)
+}
+
+// SetCreator sets the creator attribute to a four character code identifying
+// the creating application. An empty code clears it.
+func (k *Item) SetCreator(code string) error {
+	return k.setFourCharCode(CreatorKey, code)
+}
+
+func (k *Item) setFourCharCode(key string, code string) error {
+	if code == "" {
+		delete(k.attr, key)
+
+		return nil
+	}
+
+	v, err := FourCharCode(code)
+	if err != nil {
+		return err
+	}
+
+	k.SetInt32(key, int32(v))
+
+	return nil
+}
+
+// SetData sets the data attribute.
+func (k *Item) SetData(b []byte) {
+	if b != nil {
+		k.attr[DataKey] = b
+	} else {
+		delete(k.attr, DataKey)
+	}
+}
+
+// SetAccessGroup sets the access group attribute.
+func (k *Item) SetAccessGroup(ag string) {
+	k.SetString(AccessGroupKey, ag)
+}
+
+// SetSynchronizable sets the synchronizable attribute.
+func (k *Item) SetSynchronizable(sync Synchronizable) {
+	if sync != SynchronizableDefault {
+		k.attr[SynchronizableKey] = sync
+	} else {
+		delete(k.attr, SynchronizableKey)
+	}
+}
+
+// SetAccessible sets the accessible attribute.
+func (k *Item) SetAccessible(accessible Accessible) {
+	if accessible != AccessibleDefault {
+		k.attr[AccessibleKey] = accessible
+	} else {
+		delete(k.attr, AccessibleKey)
+	}
+}
+
+// SetMatchLimit sets the match limit.
+func (k *Item) SetMatchLimit(matchLimit MatchLimit) {
+	if matchLimit != MatchLimitDefault {
+		k.attr[MatchLimitKey] = matchLimit
+	} else {
+		delete(k.attr, MatchLimitKey)
+	}
+}
+
+// SetReturnAttributes sets the return value type on query.
+func (k *Item) SetReturnAttributes(b bool) {
+	k.attr[ReturnAttributesKey] = b
+}
+
+// SetReturnData enables returning data on query.
+func (k *Item) SetReturnData(b bool) {
+	k.attr[ReturnDataKey] = b
+}
+
+// SetReturnRef enables returning references on query.
+func (k *Item) SetReturnRef(b bool) {
+	k.attr[ReturnRefKey] = b
+}
+
+// SetUseDataProtectionKeychain targets the data protection keychain on macOS
+// 10.15 and later, which uses iOS semantics including access groups. It has no
+// effect on iOS.
+func (k *Item) SetUseDataProtectionKeychain(b bool) {
+	if b {
+		k.attr[UseDataProtectionKeychainKey] = true
+	} else {
+		delete(k.attr, UseDataProtectionKeychainKey)
+	}
+}
+
+// SetReturnPersistentRef enables returning persistent references on query.
+func (k *Item) SetReturnPersistentRef(b bool) {
+	k.attr[ReturnPersistentRefKey] = b
+}
+
+// SetPersistentRef matches the item identified by a persistent reference
+// returned by a previous query.
+func (k *Item) SetPersistentRef(ref []byte) {
+	if ref != nil {
+		k.attr[ValuePersistentRefKey] = ref
+	} else {
+		delete(k.attr, ValuePersistentRefKey)
+	}
+}
+
+// SetMatchItemList limits the query to the items identified by persistent
+// references returned by previous queries, so several items can be targeted
+// exactly. Without references, the limit is removed.
+func (k *Item) SetMatchItemList(refs ...[]byte) {
+	if len(refs) == 0 {
+		delete(k.attr, MatchItemListKey)
+
+		return
+	}
+
+	list := make([][]byte, len(refs))
+	for i, ref := range refs {
+		list[i] = append([]byte{}, ref...)
+	}
+
+	k.attr[MatchItemListKey] = list
+}
+
+// NewItem is a new keychain item with the attributes set by SetDefaults.
+func NewItem() Item {
+	return cur().newItem()
+}
+
+func (s *state) newItem() Item {
+	item := newItem()
+	s.cfg.Defaults.apply(&item)
+
+	return item
+}
+
+// newItem is a new empty keychain item, for updates and queries targeting
+// existing items, which the defaults must not change or narrow.
+func newItem() Item {
+	return Item{attr: make(map[string]interface{})}
+}
+
+// NewGenericPassword creates a generic password item with the default keychain. This is a convenience method.
+// An empty access group leaves the default access group (see SetDefaults).
+func NewGenericPassword(service string, account string, label string, data []byte, accessGroup string) Item {
+	return cur().newGenericPassword(service, account, label, data, accessGroup)
+}
+
+func (s *state) newGenericPassword(service, account, label string, data []byte, accessGroup string) Item {
+	item := s.newItem()
+	item.SetSecClass(SecClassGenericPassword)
+	item.SetService(service)
+	item.SetAccount(account)
+	item.SetLabel(label)
+	item.SetData(data)
+
+	if accessGroup != "" {
+		item.SetAccessGroup(accessGroup)
+	}
+
+	return item
+}
+
+// QueryResult stores all possible results from queries.
+// Not all fields are applicable all the time. Results depend on query.
+type QueryResult struct {
+	// For generic application items.
+	Service string
+
+	// For internet password items.
+	Server             string
+	Protocol           string
+	AuthenticationType string
+	Port               int32
+	Path               string
+
+	// For key items.
+	ApplicationTag []byte
+	KeyType        KeyType
+	KeySizeInBits  int
+
+	Account          string
+	AccessGroup      string
+	Label            string
+	Description      string
+	Comment          string
+	Type             string
+	Creator          string
+	Generic          []byte
+	Data             []byte
+	CreationDate     time.Time
+	ModificationDate time.Time
+
+	// Accessible is when the item is accessible, if the backend returns it.
+	Accessible Accessible
+
+	// ID is the ItemID of the item, if the query had a class and returned
+	// attributes.
+	ID string
+
+	// PersistentRef identifies the item across launches. It is only set if
+	// the query enabled SetReturnPersistentRef.
+	PersistentRef []byte
+
+	// NormalizedService, NormalizedAccount and NormalizedLabel are Service,
+	// Account and Label in Unicode NFC, for comparing with values that may
+	// have been written decomposed by other apps. The fields above are as
+	// stored.
+	NormalizedService string
+	NormalizedAccount string
+	NormalizedLabel   string
+}
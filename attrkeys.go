@@ -0,0 +1,87 @@
+package keychain
+
+// Keys of the attributes of certificates, for queries with Item.SetBytes and
+// Item.SetInt32 or the Raw functions. The Security framework sets them when a
+// certificate is added; they can't be changed.
+const (
+	// SubjectKey is for kSecAttrSubject, the DER encoded subject name
+	// ([]byte).
+	SubjectKey = "subj"
+	// IssuerKey is for kSecAttrIssuer, the DER encoded issuer name ([]byte).
+	IssuerKey = "issr"
+	// SerialNumberKey is for kSecAttrSerialNumber, the DER encoded serial
+	// number ([]byte).
+	SerialNumberKey = "slnr"
+	// SubjectKeyIDKey is for kSecAttrSubjectKeyID, the subject key identifier
+	// extension ([]byte).
+	SubjectKeyIDKey = "skid"
+	// PublicKeyHashKey is for kSecAttrPublicKeyHash, the SHA-1 of the public
+	// key ([]byte). It equals the ApplicationLabelKey of the matching key.
+	PublicKeyHashKey = "pkhh"
+	// CertificateTypeKey is for kSecAttrCertificateType, a CSSM_CERT_TYPE
+	// (int32).
+	CertificateTypeKey = "ctyp"
+	// CertificateEncodingKey is for kSecAttrCertificateEncoding, a
+	// CSSM_CERT_ENCODING (int32).
+	CertificateEncodingKey = "cenc"
+)
+
+// Keys of the attributes of key items (SecClassPairKey), for queries with
+// Item.SetBytes, Item.SetBool and Item.SetInt32 or the Raw functions. The type
+// of a key (kSecAttrKeyType) has the TypeKey; see also ApplicationTagKey and
+// KeySizeInBitsKey.
+const (
+	// KeyClassKey is for kSecAttrKeyClass: "0" for public, "1" for private and
+	// "2" for symmetric keys.
+	KeyClassKey = "kcls"
+	// ApplicationLabelKey is for kSecAttrApplicationLabel, the SHA-1 of the
+	// public key for asymmetric keys ([]byte).
+	ApplicationLabelKey = "klbl"
+	// EffectiveKeySizeKey is for kSecAttrEffectiveKeySize, the number of bits
+	// of the key used by the algorithm (int32).
+	EffectiveKeySizeKey = "esiz"
+	// IsPermanentKey is for kSecAttrIsPermanent, whether the key is stored in
+	// the keychain (bool).
+	IsPermanentKey = "perm"
+	// IsSensitiveKey is for kSecAttrIsSensitive, whether the key can only be
+	// exported wrapped (bool, macOS only).
+	IsSensitiveKey = "sens"
+	// IsExtractableKey is for kSecAttrIsExtractable, whether the key can be
+	// exported (bool, macOS only).
+	IsExtractableKey = "extr"
+	// TokenIDKey is for kSecAttrTokenID, the token holding the key, e.g.
+	// "com.apple.setoken" for the Secure Enclave (string).
+	TokenIDKey = "tkid"
+)
+
+// Keys of the usage attributes of key items (bool), whether the key can be
+// used for an operation.
+const (
+	// CanEncryptKey is for kSecAttrCanEncrypt.
+	CanEncryptKey = "encr"
+	// CanDecryptKey is for kSecAttrCanDecrypt.
+	CanDecryptKey = "decr"
+	// CanDeriveKey is for kSecAttrCanDerive.
+	CanDeriveKey = "drve"
+	// CanSignKey is for kSecAttrCanSign.
+	CanSignKey = "sign"
+	// CanVerifyKey is for kSecAttrCanVerify.
+	CanVerifyKey = "vrfy"
+	// CanWrapKey is for kSecAttrCanWrap.
+	CanWrapKey = "wrap"
+	// CanUnwrapKey is for kSecAttrCanUnwrap.
+	CanUnwrapKey = "unwp"
+)
+
+// Keys of other attributes of password items.
+const (
+	// IsNegativeKey is for kSecAttrIsNegative, whether the item is a
+	// placeholder for a password the user chose not to store (bool).
+	IsNegativeKey = "nega"
+	// SecurityDomainKey is for kSecAttrSecurityDomain, the security domain of
+	// an internet password (string).
+	SecurityDomainKey = "sdmn"
+	// SyncViewHintKey is for kSecAttrSyncViewHint, the iCloud Keychain view
+	// of a synchronizable item (string).
+	SyncViewHintKey = "vwht"
+)
@@ -0,0 +1,307 @@
+// Package awssecrets implements a keychain backend storing generic passwords
+// in AWS Secrets Manager, so the same credential code runs on developer Macs
+// and cloud instances.
+//
+// Each item is a secret named after its service and account, holding the item
+// data as a binary secret, with the service, account, label, description and
+// comment as tags (keychain-service, keychain-account, ...). Items are listed
+// by the keychain-class tag. Deleted secrets are removed without a recovery
+// window, so their names can be reused right away.
+//
+// Importing the package registers the backend, configured from the
+// environment (see ConfigFromEnv):
+//
+//	import _ "github.com/mailstone/go-keychain/awssecrets"
+//
+//	err := keychain.UseBackend(awssecrets.Name)
+package awssecrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/internal/cloudsecret"
+)
+
+// Name is the name the backend is registered under, see keychain.UseBackend.
+const Name = "aws-secretsmanager"
+
+func init() {
+	keychain.RegisterBackend(Name, func() (keychain.Backend, error) {
+		return Open(ConfigFromEnv())
+	})
+}
+
+// Credentials are AWS access keys.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set for temporary credentials.
+	SessionToken string
+}
+
+// Config configures the connection to Secrets Manager.
+type Config struct {
+	// Region is the AWS region, e.g. us-east-1.
+	Region string
+	// Credentials returns the credentials to sign requests with. It is called
+	// for every request, so it can refresh temporary credentials.
+	Credentials func() (Credentials, error)
+	// Prefix is prepended to secret names, e.g. "myapp/".
+	Prefix string
+	// Endpoint overrides the Secrets Manager endpoint of the region.
+	Endpoint string
+	// HTTPClient is the client used for requests. It defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// ConfigFromEnv returns a Config from the AWS_REGION (or AWS_DEFAULT_REGION),
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and
+// KEYCHAIN_SECRET_PREFIX environment variables.
+func ConfigFromEnv() Config {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+
+	return Config{
+		Region: region,
+		Credentials: func() (Credentials, error) {
+			creds := Credentials{
+				AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+				SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+				SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+			}
+			if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+				return Credentials{}, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are not set")
+			}
+
+			return creds, nil
+		},
+		Prefix: os.Getenv("KEYCHAIN_SECRET_PREFIX"),
+	}
+}
+
+// Open returns a backend for Secrets Manager configured by cfg. No request is
+// made until the backend is used.
+func Open(cfg Config) (keychain.Backend, error) {
+	if cfg.Region == "" {
+		return nil, errors.New("AWS region is not set")
+	}
+
+	if cfg.Credentials == nil {
+		return nil, errors.New("AWS credentials are not set")
+	}
+
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://secretsmanager." + cfg.Region + ".amazonaws.com"
+	}
+
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+
+	return cloudsecret.New(&provider{cfg: cfg, now: time.Now}, cfg.Prefix), nil
+}
+
+// provider is a cloudsecret.Provider for the Secrets Manager API.
+type provider struct {
+	cfg Config
+	now func() time.Time
+}
+
+type tag struct {
+	Key   string
+	Value string
+}
+
+func tags(labels map[string]string) []tag {
+	t := make([]tag, 0, len(labels))
+	for key, value := range labels {
+		t = append(t, tag{Key: key, Value: value})
+	}
+
+	return t
+}
+
+func labels(tags []tag) map[string]string {
+	l := make(map[string]string, len(tags))
+	for _, t := range tags {
+		l[t.Key] = t.Value
+	}
+
+	return l
+}
+
+// call calls the action of the Secrets Manager API.
+func (p *provider) call(action string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", action, err)
+	}
+
+	creds, err := p.cfg.Credentials()
+	if err != nil {
+		return fmt.Errorf("failed to get AWS credentials: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.cfg.Endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", action, err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager."+action)
+	sign(req, body, creds, p.cfg.Region, "secretsmanager", p.now())
+
+	resp, err := p.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+
+		switch e.Type {
+		case "ResourceNotFoundException":
+			return cloudsecret.ErrNotFound
+		case "ResourceExistsException":
+			return cloudsecret.ErrExists
+		case "AccessDeniedException", "UnrecognizedClientException", "InvalidSignatureException", "ExpiredTokenException":
+			return fmt.Errorf("%s: %s: %w", action, e.Message, keychain.ErrorAuthFailed)
+		}
+
+		return fmt.Errorf("%s: %s %s: %s", action, resp.Status, e.Type, e.Message)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", action, err)
+	}
+
+	return nil
+}
+
+func (p *provider) Create(s cloudsecret.Secret) error {
+	return p.call("CreateSecret", map[string]interface{}{
+		"Name":         s.Name,
+		"SecretBinary": s.Data,
+		"Tags":         tags(s.Labels),
+	}, nil)
+}
+
+func (p *provider) Get(name string) (cloudsecret.Secret, error) {
+	var value struct {
+		SecretBinary []byte
+		SecretString *string
+	}
+
+	if err := p.call("GetSecretValue", map[string]string{"SecretId": name}, &value); err != nil {
+		return cloudsecret.Secret{}, err
+	}
+
+	var desc struct {
+		Tags []tag
+	}
+
+	if err := p.call("DescribeSecret", map[string]string{"SecretId": name}, &desc); err != nil {
+		return cloudsecret.Secret{}, err
+	}
+
+	data := value.SecretBinary
+	if value.SecretString != nil {
+		// Secrets created outside this package, e.g. in the console.
+		data = []byte(*value.SecretString)
+	}
+
+	if data == nil {
+		data = []byte{}
+	}
+
+	return cloudsecret.Secret{Name: name, Labels: labels(desc.Tags), Data: data}, nil
+}
+
+func (p *provider) Update(name string, data []byte, l map[string]string) error {
+	if data != nil {
+		if err := p.call("PutSecretValue", map[string]interface{}{"SecretId": name, "SecretBinary": data}, nil); err != nil {
+			return err
+		}
+	}
+
+	var desc struct {
+		Tags []tag
+	}
+
+	if err := p.call("DescribeSecret", map[string]string{"SecretId": name}, &desc); err != nil {
+		return err
+	}
+
+	var removed []string
+
+	for _, t := range desc.Tags {
+		if _, ok := l[t.Key]; !ok {
+			removed = append(removed, t.Key)
+		}
+	}
+
+	if len(removed) > 0 {
+		if err := p.call("UntagResource", map[string]interface{}{"SecretId": name, "TagKeys": removed}, nil); err != nil {
+			return err
+		}
+	}
+
+	return p.call("TagResource", map[string]interface{}{"SecretId": name, "Tags": tags(l)}, nil)
+}
+
+func (p *provider) Delete(name string) error {
+	return p.call("DeleteSecret", map[string]interface{}{"SecretId": name, "ForceDeleteWithoutRecovery": true}, nil)
+}
+
+func (p *provider) List() ([]cloudsecret.Secret, error) {
+	var secrets []cloudsecret.Secret
+
+	in := map[string]interface{}{
+		"Filters":    []map[string]interface{}{{"Key": "tag-key", "Values": []string{cloudsecret.ClassLabel}}},
+		"MaxResults": 100,
+	}
+
+	for {
+		var out struct {
+			SecretList []struct {
+				Name string
+				Tags []tag
+			}
+			NextToken string
+		}
+
+		if err := p.call("ListSecrets", in, &out); err != nil {
+			return nil, err
+		}
+
+		for _, s := range out.SecretList {
+			secrets = append(secrets, cloudsecret.Secret{Name: s.Name, Labels: labels(s.Tags)})
+		}
+
+		if out.NextToken == "" {
+			return secrets, nil
+		}
+
+		in["NextToken"] = out.NextToken
+	}
+}
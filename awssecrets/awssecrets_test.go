@@ -0,0 +1,330 @@
+package awssecrets_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/awssecrets"
+)
+
+type fakeTag struct {
+	Key   string
+	Value string
+}
+
+type fakeSecret struct {
+	binary []byte
+	str    *string
+	tags   map[string]string
+}
+
+// fakeSecretsManager is a minimal Secrets Manager, listing one secret per
+// page so that callers must follow NextToken.
+type fakeSecretsManager struct {
+	mu      sync.Mutex
+	secrets map[string]*fakeSecret
+	actions []string
+}
+
+func (f *fakeSecretsManager) fail(w http.ResponseWriter, errType string) {
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(map[string]string{"__type": errType, "message": errType + " message"})
+}
+
+func (f *fakeSecretsManager) tagList(s *fakeSecret) []fakeTag {
+	tags := make([]fakeTag, 0, len(s.tags))
+	for key, value := range s.tags {
+		tags = append(tags, fakeTag{Key: key, Value: value})
+	}
+
+	return tags
+}
+
+func (f *fakeSecretsManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	auth := r.Header.Get("Authorization")
+	if strings.Contains(auth, "Credential=EXPIRED/") {
+		f.fail(w, "ExpiredTokenException")
+
+		return
+	}
+
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/us-east-1/secretsmanager/aws4_request") {
+		f.fail(w, "IncompleteSignature")
+
+		return
+	}
+
+	action := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "secretsmanager.")
+	f.actions = append(f.actions, action)
+
+	var in struct {
+		Name         string
+		SecretID     string `json:"SecretId"`
+		SecretBinary []byte
+		Tags         []fakeTag
+		TagKeys      []string
+		NextToken    string
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		f.fail(w, "InvalidRequestException")
+
+		return
+	}
+
+	if action == "CreateSecret" {
+		if _, ok := f.secrets[in.Name]; ok {
+			f.fail(w, "ResourceExistsException")
+
+			return
+		}
+
+		s := &fakeSecret{binary: in.SecretBinary, tags: map[string]string{}}
+		for _, t := range in.Tags {
+			s.tags[t.Key] = t.Value
+		}
+
+		f.secrets[in.Name] = s
+		_ = json.NewEncoder(w).Encode(map[string]string{"Name": in.Name})
+
+		return
+	}
+
+	if action == "ListSecrets" {
+		var names []string
+
+		for name, s := range f.secrets {
+			if _, ok := s.tags["keychain-class"]; ok {
+				names = append(names, name)
+			}
+		}
+
+		sort.Strings(names)
+
+		i, _ := strconv.Atoi(in.NextToken)
+		out := map[string]interface{}{"SecretList": []interface{}{}}
+
+		if i < len(names) {
+			out["SecretList"] = []interface{}{map[string]interface{}{"Name": names[i], "Tags": f.tagList(f.secrets[names[i]])}}
+		}
+
+		if i+1 < len(names) {
+			out["NextToken"] = strconv.Itoa(i + 1)
+		}
+
+		_ = json.NewEncoder(w).Encode(out)
+
+		return
+	}
+
+	s, ok := f.secrets[in.SecretID]
+	if !ok {
+		f.fail(w, "ResourceNotFoundException")
+
+		return
+	}
+
+	switch action {
+	case "GetSecretValue":
+		if s.str != nil {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"SecretString": *s.str})
+		} else {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"SecretBinary": s.binary})
+		}
+	case "DescribeSecret":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"Tags": f.tagList(s)})
+	case "PutSecretValue":
+		s.binary, s.str = in.SecretBinary, nil
+		_ = json.NewEncoder(w).Encode(map[string]string{"Name": in.SecretID})
+	case "TagResource":
+		for _, t := range in.Tags {
+			s.tags[t.Key] = t.Value
+		}
+	case "UntagResource":
+		for _, key := range in.TagKeys {
+			delete(s.tags, key)
+		}
+	case "DeleteSecret":
+		delete(f.secrets, in.SecretID)
+		_ = json.NewEncoder(w).Encode(map[string]string{"Name": in.SecretID})
+	default:
+		f.fail(w, "InvalidAction")
+	}
+}
+
+// count returns how many times action was called.
+func (f *fakeSecretsManager) count(action string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+
+	for _, a := range f.actions {
+		if a == action {
+			n++
+		}
+	}
+
+	return n
+}
+
+// secret returns the secret holding the item of account.
+func (f *fakeSecretsManager) secret(t *testing.T, account string) *fakeSecret {
+	t.Helper()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, s := range f.secrets {
+		if s.tags["keychain-account"] == account {
+			return s
+		}
+	}
+
+	t.Fatalf("no secret for account %q", account)
+
+	return nil
+}
+
+func useSecretsManager(t *testing.T, accessKeyID string) *fakeSecretsManager {
+	t.Helper()
+
+	f := &fakeSecretsManager{secrets: map[string]*fakeSecret{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	b, err := awssecrets.Open(awssecrets.Config{
+		Region: "us-east-1",
+		Credentials: func() (awssecrets.Credentials, error) {
+			return awssecrets.Credentials{AccessKeyID: accessKeyID, SecretAccessKey: "secret"}, nil
+		},
+		Prefix:   "myapp/",
+		Endpoint: srv.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prev := keychain.SetBackend(b)
+	t.Cleanup(func() { keychain.SetBackend(prev) })
+
+	return f
+}
+
+func TestSecretsManager(t *testing.T) {
+	f := useSecretsManager(t, "AKID")
+
+	item := keychain.NewGenericPassword("My App", "alice", "Alice", []byte("secret"), "")
+	item.SetComment("first")
+
+	if err := keychain.AddItem(item); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := f.secret(t, "alice"); s.tags["keychain-class"] != "genp" || s.tags["keychain-service"] != "My App" ||
+		s.tags["keychain-label"] != "Alice" || !bytes.Equal(s.binary, []byte("secret")) {
+		t.Fatalf("unexpected secret %+v", s)
+	}
+
+	if err := keychain.AddItem(item); !errors.Is(err, keychain.ErrorDuplicateItem) {
+		t.Fatalf("expected ErrorDuplicateItem, got %v", err)
+	}
+
+	for _, account := range []string{"bob", "carol"} {
+		if err := keychain.AddItem(keychain.NewGenericPassword("My App", account, "", []byte(account), "")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Secrets of other programs, without the class tag, aren't listed.
+	f.mu.Lock()
+	f.secrets["other"] = &fakeSecret{binary: []byte("other"), tags: map[string]string{"keychain-service": "My App"}}
+	f.mu.Unlock()
+
+	accounts, err := keychain.GetGenericPasswordAccounts("My App")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(accounts)
+
+	if strings.Join(accounts, ",") != "alice,bob,carol" {
+		t.Fatalf("unexpected accounts %v", accounts)
+	}
+
+	if n := f.count("ListSecrets"); n != 3 {
+		t.Fatalf("expected the 3 pages to be listed, got %d calls", n)
+	}
+
+	data, err := keychain.GetGenericPassword("My App", "alice", "", "")
+	if err != nil || !bytes.Equal(data, []byte("secret")) {
+		t.Fatalf("expected secret, got %q (%v)", data, err)
+	}
+
+	// Secrets edited in the console hold a string.
+	console := "from the console"
+	f.secret(t, "bob").str = &console
+
+	if data, err := keychain.GetGenericPassword("My App", "bob", "", ""); err != nil || string(data) != console {
+		t.Fatalf("expected the string secret, got %q (%v)", data, err)
+	}
+
+	query := keychain.NewItem()
+	query.SetSecClass(keychain.SecClassGenericPassword)
+	query.SetService("My App")
+	query.SetAccount("alice")
+
+	update := keychain.NewItem()
+	update.SetData([]byte("rotated"))
+	update.SetLabel("Alice Liddell")
+
+	if err := keychain.UpdateItem(query, update); err != nil {
+		t.Fatal(err)
+	}
+
+	s := f.secret(t, "alice")
+	if !bytes.Equal(s.binary, []byte("rotated")) || s.tags["keychain-label"] != "Alice Liddell" || s.tags["keychain-comment"] != "first" {
+		t.Fatalf("unexpected updated secret %+v", s)
+	}
+
+	if err := keychain.DeleteGenericPasswordItem("My App", "carol"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.DeleteGenericPasswordItem("My App", "carol"); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound, got %v", err)
+	}
+
+	if data, err := keychain.GetGenericPassword("My App", "carol", "", ""); err != nil || data != nil {
+		t.Fatalf("expected no data for a deleted item, got %q (%v)", data, err)
+	}
+
+	missing := keychain.NewItem()
+	missing.SetSecClass(keychain.SecClassGenericPassword)
+	missing.SetService("My App")
+	missing.SetAccount("dave")
+
+	if err := keychain.UpdateItem(missing, update); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound, got %v", err)
+	}
+}
+
+func TestSecretsManagerAuthFailed(t *testing.T) {
+	useSecretsManager(t, "EXPIRED")
+
+	if _, err := keychain.GetGenericPassword("My App", "alice", "", ""); !errors.Is(err, keychain.ErrorAuthFailed) {
+		t.Fatalf("expected ErrorAuthFailed, got %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package keychain
+
+// Backend performs keychain operations. Queries and attributes are encoded as
+// by EncodeAttributes. Results are decoded into Go values: attribute
+// dictionaries as map[string]interface{} (keyed like Item attributes), data as
+// []byte, dates as time.Time, numbers as int32, int64 or float64, and arrays
+// of these as []interface{}. Dictionary values without a Go representation
+// (e.g. item references) are omitted. Failures are reported as Error values,
+// e.g. ErrorItemNotFound or ErrorDuplicateItem.
+//
+// The default backend calls the Security framework on darwin, the Secret
+// Service on Linux and the Credential Manager on Windows, once the
+// secretservice or wincred package is imported, and fails with
+// ErrorUnimplemented otherwise. Functions returning raw references
+// (AddItemReturningRef, QueryItemRef) always call the Security framework
+// directly and are only available on darwin.
+type Backend interface {
+	// Add adds an item, returning the requested result (see WantsResult) or
+	// nil.
+	Add(attrs map[string]interface{}) (interface{}, error)
+	// Update updates the items matching query with attrs.
+	Update(query, attrs map[string]interface{}) error
+	// CopyMatching returns the requested results for the items matching query,
+	// or ErrorItemNotFound. The result is nil if no result was requested.
+	CopyMatching(query map[string]interface{}) (interface{}, error)
+	// Delete deletes the items matching query.
+	Delete(query map[string]interface{}) error
+}
+
+// SetBackend replaces the backend used by this package and returns the
+// previous one. A nil backend restores the platform default. It is mostly
+// useful in tests, see the keychainfake package.
+//
+// Unless b reports Capability.UniqueKeys, this package rejects adding or
+// updating generic passwords to have the class, service, account and access
+// group of another item with ErrorDuplicateItem, like the Security framework.
+func SetBackend(b Backend) Backend {
+	return updateConfig(func(cfg *Config) { cfg.Backend = b }).Backend
+}
+
+// backend returns the Backend of the package configuration, with the
+// uniqueness layer if it needs it.
+func backend() Backend {
+	return cur().backend
+}
+
+// WantsResult returns whether attrs request a result to be returned.
+func WantsResult(attrs map[string]interface{}) bool {
+	for _, key := range []string{ReturnAttributesKey, ReturnDataKey, ReturnRefKey, ReturnPersistentRefKey} {
+		if b, ok := attrs[key].(bool); ok && b {
+			return true
+		}
+	}
+
+	return false
+}
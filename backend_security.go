@@ -0,0 +1,469 @@
+//go:build darwin
+// +build darwin
+
+package keychain
+
+/*
+#cgo LDFLAGS: -framework CoreFoundation -framework Security
+
+#include <CoreFoundation/CoreFoundation.h>
+#include <Security/Security.h>
+*/
+import "C"
+import "fmt"
+
+// securityBackend implements Backend with the Security framework.
+type securityBackend struct{}
+
+var defaultBackend Backend = securityBackend{}
+
+func checkError(errCode C.OSStatus) error {
+	if errCode == C.errSecSuccess {
+		return nil
+	}
+
+	return Error(errCode)
+}
+
+// securityErrors returns the framework result code for each Error, for
+// testing.
+func securityErrors() map[Error]C.OSStatus {
+	return map[Error]C.OSStatus{
+		ErrorUnimplemented:         C.errSecUnimplemented,
+		ErrorParam:                 C.errSecParam,
+		ErrorAllocate:              C.errSecAllocate,
+		ErrorNotAvailable:          C.errSecNotAvailable,
+		ErrorAuthFailed:            C.errSecAuthFailed,
+		ErrorDuplicateItem:         C.errSecDuplicateItem,
+		ErrorItemNotFound:          C.errSecItemNotFound,
+		ErrorInteractionNotAllowed: C.errSecInteractionNotAllowed,
+		ErrorDecode:                C.errSecDecode,
+		ErrorNoSuchKeychain:        C.errSecNoSuchKeychain,
+		ErrorNoAccessForItem:       C.errSecNoAccessForItem,
+		ErrorReadOnly:              C.errSecReadOnly,
+		ErrorInvalidKeychain:       C.errSecInvalidKeychain,
+		ErrorDuplicateKeyChain:     C.errSecDuplicateKeychain,
+		ErrorWrongVersion:          C.errSecWrongSecVersion,
+		ErrorReadonlyAttribute:     C.errSecReadOnlyAttr,
+		ErrorInvalidSearchRef:      C.errSecInvalidSearchRef,
+		ErrorInvalidItemRef:        C.errSecInvalidItemRef,
+		ErrorDataNotAvailable:      C.errSecDataNotAvailable,
+		ErrorDataNotModifiable:     C.errSecDataNotModifiable,
+		ErrorInvalidOwnerEdit:      C.errSecInvalidOwnerEdit,
+		ErrorUserCanceled:          C.errSecUserCanceled,
+		ErrorMissingEntitlement:    C.errSecMissingEntitlement,
+		ErrorNoSuchAttr:            C.errSecNoSuchAttr,
+	}
+}
+
+// securityConstants maps the values EncodeAttributes uses for classes,
+// accessibility, SynchronizableAny, match limits and authentication UI to the
+// framework constants, which are passed instead of equal strings.
+var securityConstants = map[string]C.CFTypeRef{
+	secClassValues[SecClassGenericPassword]:  C.CFTypeRef(C.kSecClassGenericPassword),
+	secClassValues[SecClassInternetPassword]: C.CFTypeRef(C.kSecClassInternetPassword),
+	secClassValues[SecClassCertificate]:      C.CFTypeRef(C.kSecClassCertificate),
+	secClassValues[SecClassPairKey]:          C.CFTypeRef(C.kSecClassKey),
+	secClassValues[SecClassIdentity]:         C.CFTypeRef(C.kSecClassIdentity),
+
+	accessibleValues[AccessibleWhenUnlocked]:                   C.CFTypeRef(C.kSecAttrAccessibleWhenUnlocked),
+	accessibleValues[AccessibleAfterFirstUnlock]:               C.CFTypeRef(C.kSecAttrAccessibleAfterFirstUnlock),
+	accessibleValues[AccessibleAlways]:                         C.CFTypeRef(C.kSecAttrAccessibleAlways),
+	accessibleValues[AccessibleWhenPasscodeSetThisDeviceOnly]:  C.CFTypeRef(C.kSecAttrAccessibleWhenPasscodeSetThisDeviceOnly),
+	accessibleValues[AccessibleWhenUnlockedThisDeviceOnly]:     C.CFTypeRef(C.kSecAttrAccessibleWhenUnlockedThisDeviceOnly),
+	accessibleValues[AccessibleAfterFirstUnlockThisDeviceOnly]: C.CFTypeRef(C.kSecAttrAccessibleAfterFirstUnlockThisDeviceOnly),
+	accessibleValues[AccessibleAccessibleAlwaysThisDeviceOnly]: C.CFTypeRef(C.kSecAttrAccessibleAlwaysThisDeviceOnly),
+
+	"syna": C.CFTypeRef(C.kSecAttrSynchronizableAny),
+
+	matchLimitValues[MatchLimitOne]: C.CFTypeRef(C.kSecMatchLimitOne),
+	matchLimitValues[MatchLimitAll]: C.CFTypeRef(C.kSecMatchLimitAll),
+
+	authenticationUIValues[AuthenticationUIAllow]: C.CFTypeRef(C.kSecUseAuthenticationUIAllow),
+	authenticationUIValues[AuthenticationUIFail]:  C.CFTypeRef(C.kSecUseAuthenticationUIFail),
+	authenticationUIValues[AuthenticationUISkip]:  C.CFTypeRef(C.kSecUseAuthenticationUISkip),
+}
+
+// securityKeys returns the framework constant for each attribute key, for
+// testing.
+func securityKeys() map[string]C.CFTypeRef {
+	return map[string]C.CFTypeRef{
+		SecClassKey:                  C.CFTypeRef(C.kSecClass),
+		ServiceKey:                   C.CFTypeRef(C.kSecAttrService),
+		ServerKey:                    C.CFTypeRef(C.kSecAttrServer),
+		ProtocolKey:                  C.CFTypeRef(C.kSecAttrProtocol),
+		AuthenticationTypeKey:        C.CFTypeRef(C.kSecAttrAuthenticationType),
+		PortKey:                      C.CFTypeRef(C.kSecAttrPort),
+		PathKey:                      C.CFTypeRef(C.kSecAttrPath),
+		LabelKey:                     C.CFTypeRef(C.kSecAttrLabel),
+		AccountKey:                   C.CFTypeRef(C.kSecAttrAccount),
+		AccessGroupKey:               C.CFTypeRef(C.kSecAttrAccessGroup),
+		DataKey:                      C.CFTypeRef(C.kSecValueData),
+		DescriptionKey:               C.CFTypeRef(C.kSecAttrDescription),
+		CommentKey:                   C.CFTypeRef(C.kSecAttrComment),
+		CreationDateKey:              C.CFTypeRef(C.kSecAttrCreationDate),
+		ModificationDateKey:          C.CFTypeRef(C.kSecAttrModificationDate),
+		GenericKey:                   C.CFTypeRef(C.kSecAttrGeneric),
+		TypeKey:                      C.CFTypeRef(C.kSecAttrType),
+		CreatorKey:                   C.CFTypeRef(C.kSecAttrCreator),
+		InvisibleKey:                 C.CFTypeRef(C.kSecAttrIsInvisible),
+		ApplicationTagKey:            C.CFTypeRef(C.kSecAttrApplicationTag),
+		KeySizeInBitsKey:             C.CFTypeRef(C.kSecAttrKeySizeInBits),
+		SubjectKey:                   C.CFTypeRef(C.kSecAttrSubject),
+		IssuerKey:                    C.CFTypeRef(C.kSecAttrIssuer),
+		SerialNumberKey:              C.CFTypeRef(C.kSecAttrSerialNumber),
+		SubjectKeyIDKey:              C.CFTypeRef(C.kSecAttrSubjectKeyID),
+		PublicKeyHashKey:             C.CFTypeRef(C.kSecAttrPublicKeyHash),
+		CertificateTypeKey:           C.CFTypeRef(C.kSecAttrCertificateType),
+		CertificateEncodingKey:       C.CFTypeRef(C.kSecAttrCertificateEncoding),
+		KeyClassKey:                  C.CFTypeRef(C.kSecAttrKeyClass),
+		ApplicationLabelKey:          C.CFTypeRef(C.kSecAttrApplicationLabel),
+		EffectiveKeySizeKey:          C.CFTypeRef(C.kSecAttrEffectiveKeySize),
+		IsPermanentKey:               C.CFTypeRef(C.kSecAttrIsPermanent),
+		TokenIDKey:                   C.CFTypeRef(C.kSecAttrTokenID),
+		CanEncryptKey:                C.CFTypeRef(C.kSecAttrCanEncrypt),
+		CanDecryptKey:                C.CFTypeRef(C.kSecAttrCanDecrypt),
+		CanDeriveKey:                 C.CFTypeRef(C.kSecAttrCanDerive),
+		CanSignKey:                   C.CFTypeRef(C.kSecAttrCanSign),
+		CanVerifyKey:                 C.CFTypeRef(C.kSecAttrCanVerify),
+		CanWrapKey:                   C.CFTypeRef(C.kSecAttrCanWrap),
+		CanUnwrapKey:                 C.CFTypeRef(C.kSecAttrCanUnwrap),
+		IsNegativeKey:                C.CFTypeRef(C.kSecAttrIsNegative),
+		SecurityDomainKey:            C.CFTypeRef(C.kSecAttrSecurityDomain),
+		SyncViewHintKey:              C.CFTypeRef(C.kSecAttrSyncViewHint),
+		SynchronizableKey:            C.CFTypeRef(C.kSecAttrSynchronizable),
+		AccessibleKey:                C.CFTypeRef(C.kSecAttrAccessible),
+		MatchLimitKey:                C.CFTypeRef(C.kSecMatchLimit),
+		ReturnAttributesKey:          C.CFTypeRef(C.kSecReturnAttributes),
+		ReturnDataKey:                C.CFTypeRef(C.kSecReturnData),
+		ReturnRefKey:                 C.CFTypeRef(C.kSecReturnRef),
+		UseDataProtectionKeychainKey: C.CFTypeRef(C.kSecUseDataProtectionKeychain),
+		ReturnPersistentRefKey:       C.CFTypeRef(C.kSecReturnPersistentRef),
+		ValuePersistentRefKey:        C.CFTypeRef(C.kSecValuePersistentRef),
+		AccessControlKey:             C.CFTypeRef(C.kSecAttrAccessControl),
+		UseOperationPromptKey:        C.CFTypeRef(C.kSecUseOperationPrompt),
+		UseAuthenticationUIKey:       C.CFTypeRef(C.kSecUseAuthenticationUI),
+		UseAuthenticationContextKey:  C.CFTypeRef(C.kSecUseAuthenticationContext),
+		UseKeychainKey:               C.CFTypeRef(C.kSecUseKeychain),
+		MatchSearchListKey:           C.CFTypeRef(C.kSecMatchSearchList),
+		MatchItemListKey:             C.CFTypeRef(C.kSecMatchItemList),
+	}
+}
+
+func attrKey(ref C.CFTypeRef) string {
+	return CFStringToString(C.CFStringRef(ref))
+}
+
+// attrsToCFDictionary converts encoded attributes into a CFDictionary, which
+// must be released with Release(ref). Attributes needing a newer OS fail with
+// an *OSVersionError.
+func attrsToCFDictionary(attrs map[string]interface{}) (C.CFDictionaryRef, error) {
+	if err := checkFeatures(attrs); err != nil {
+		return 0, err
+	}
+
+	m := make(map[string]interface{}, len(attrs))
+	for key, v := range attrs {
+		m[key] = v
+	}
+
+	for _, key := range []string{SecClassKey, AccessibleKey, SynchronizableKey, MatchLimitKey, UseAuthenticationUIKey} {
+		if s, ok := m[key].(string); ok {
+			if ref, ok := securityConstants[s]; ok {
+				m[key] = ref
+			}
+		}
+	}
+
+	if list, ok := m[MatchItemListKey].([][]byte); ok {
+		m[MatchItemListKey] = dataArray(list)
+	}
+
+	return ConvertMapToCFDictionary(m)
+}
+
+// itemToCFDictionary encodes the attributes of item into a CFDictionary, which
+// must be released with Release(ref).
+func itemToCFDictionary(item Item) (C.CFDictionaryRef, error) {
+	attrs, err := EncodeAttributes(item)
+	if err != nil {
+		return 0, err
+	}
+
+	return attrsToCFDictionary(attrs)
+}
+
+// AddItemReturningRef adds an item and returns a reference to the new item
+// (e.g. a SecKeychainItemRef). You must release it when you are done.
+func AddItemReturningRef(item Item) (C.CFTypeRef, error) {
+	if err := checkWritable(); err != nil {
+		return 0, err
+	}
+
+	add := item.clone()
+	add.SetReturnRef(true)
+
+	cfDict, err := itemToCFDictionary(add)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert item attributes to CFDictionary: %w", err)
+	}
+
+	defer Release(C.CFTypeRef(cfDict))
+
+	var result C.CFTypeRef
+
+	errCode := C.SecItemAdd(cfDict, &result) // nolint:nlreturn
+	if err := checkError(errCode); err != nil {
+		return 0, err
+	}
+
+	if result == 0 {
+		return 0, fmt.Errorf("no result returned for added item")
+	}
+
+	return result, nil
+}
+
+// QueryItemRef returns query result as CFTypeRef. You must release it when you are done.
+func QueryItemRef(item Item) (C.CFTypeRef, error) {
+	cfDict, err := itemToCFDictionary(item)
+	if err != nil {
+		return 0, err
+	}
+	defer Release(C.CFTypeRef(cfDict))
+
+	var resultsRef C.CFTypeRef
+
+	errCode := C.SecItemCopyMatching(cfDict, &resultsRef) //nolint
+	if Error(errCode) == ErrorItemNotFound {
+		return 0, nil
+	}
+
+	err = checkError(errCode)
+	if err != nil {
+		return 0, err
+	}
+
+	return resultsRef, nil
+}
+
+// Capabilities implements CapabilityReporter. Items can require biometrics
+// with an AccessControl.
+func (securityBackend) Capabilities() Capability {
+	return Capability{AccessGroups: true, Biometrics: true, Sync: true, PersistentRefs: true, UniqueKeys: true}
+}
+
+func (securityBackend) Add(attrs map[string]interface{}) (interface{}, error) {
+	cfDict, err := attrsToCFDictionary(attrs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert item attributes to CFDictionary: %w", err)
+	}
+
+	defer Release(C.CFTypeRef(cfDict))
+
+	if !WantsResult(attrs) {
+		return nil, explain(checkError(C.SecItemAdd(cfDict, nil)), attrs) // nolint:nlreturn
+	}
+
+	var result C.CFTypeRef
+
+	errCode := C.SecItemAdd(cfDict, &result) // nolint:nlreturn
+	if err := checkError(errCode); err != nil {
+		return nil, explain(err, attrs)
+	}
+
+	return decodeResult(result)
+}
+
+// convertableArray is a CFArray of Convertable values.
+type convertableArray []Convertable
+
+// Convert implements Convertable.
+func (a convertableArray) Convert() (C.CFTypeRef, error) {
+	refs := make([]C.CFTypeRef, 0, len(a))
+
+	defer func() {
+		for _, ref := range refs {
+			Release(ref)
+		}
+	}()
+
+	for _, v := range a {
+		ref, err := v.Convert()
+		if err != nil {
+			return 0, err
+		}
+
+		refs = append(refs, ref)
+	}
+
+	arr := ArrayToCFArray(refs)
+	if arr == 0 {
+		return 0, fmt.Errorf("failed to create CFArray")
+	}
+
+	return C.CFTypeRef(arr), nil
+}
+
+// dataArray is a CFArray of CFData values, e.g. persistent references.
+type dataArray [][]byte
+
+// Convert implements Convertable.
+func (a dataArray) Convert() (C.CFTypeRef, error) {
+	refs := make([]C.CFTypeRef, 0, len(a))
+
+	defer func() {
+		for _, ref := range refs {
+			Release(ref)
+		}
+	}()
+
+	for _, b := range a {
+		ref, err := BytesToCFData(b)
+		if err != nil {
+			return 0, err
+		}
+
+		refs = append(refs, C.CFTypeRef(ref))
+	}
+
+	arr := ArrayToCFArray(refs)
+	if arr == 0 {
+		return 0, fmt.Errorf("failed to create CFArray")
+	}
+
+	return C.CFTypeRef(arr), nil
+}
+
+// searchQuery returns query with the keychain set with Item.UseKeychain as
+// the search list, since kSecUseKeychain only applies to adding items.
+func searchQuery(query map[string]interface{}) map[string]interface{} {
+	kc, ok := query[UseKeychainKey].(Convertable)
+	if !ok {
+		return query
+	}
+
+	q := make(map[string]interface{}, len(query))
+	for key, v := range query {
+		q[key] = v
+	}
+
+	delete(q, UseKeychainKey)
+	q[MatchSearchListKey] = convertableArray{kc}
+
+	return q
+}
+
+func (securityBackend) Update(query, attrs map[string]interface{}) error {
+	cfDict, err := attrsToCFDictionary(searchQuery(query))
+	if err != nil {
+		return fmt.Errorf("failed to convert query item attributes to CFDictionary: %w", err)
+	}
+
+	defer Release(C.CFTypeRef(cfDict))
+
+	cfDictUpdate, err := attrsToCFDictionary(attrs)
+	if err != nil {
+		return fmt.Errorf("failed to convert update item attributes to CFDictionary: %w", err)
+	}
+
+	defer Release(C.CFTypeRef(cfDictUpdate))
+
+	errCode := C.SecItemUpdate(cfDict, cfDictUpdate) // nolint:nlreturn
+
+	return explain(checkError(errCode), query)
+}
+
+func (securityBackend) CopyMatching(query map[string]interface{}) (interface{}, error) {
+	cfDict, err := attrsToCFDictionary(searchQuery(query))
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert query attributes to CFDictionary: %w", err)
+	}
+
+	defer Release(C.CFTypeRef(cfDict))
+
+	if !WantsResult(query) {
+		// No result pointer, so nothing is decrypted.
+		return nil, explain(checkError(C.SecItemCopyMatching(cfDict, nil)), query) // nolint:nlreturn
+	}
+
+	var result C.CFTypeRef
+
+	errCode := C.SecItemCopyMatching(cfDict, &result) // nolint:nlreturn
+	if err := checkError(errCode); err != nil {
+		return nil, explain(err, query)
+	}
+
+	return decodeResult(result)
+}
+
+func (securityBackend) Delete(query map[string]interface{}) error {
+	cfDict, err := attrsToCFDictionary(searchQuery(query))
+	if err != nil {
+		return fmt.Errorf("failed to convert item to CFDictionary: %w", err)
+	}
+
+	defer Release(C.CFTypeRef(cfDict))
+
+	errCode := C.SecItemDelete(cfDict) // nolint:nlreturn
+
+	return explain(checkError(errCode), query)
+}
+
+// decodeResult decodes and releases a result returned by the Security
+// framework.
+func decodeResult(ref C.CFTypeRef) (interface{}, error) {
+	if ref == 0 {
+		return nil, nil
+	}
+
+	defer Release(ref)
+
+	v, err := decodeCF(ref)
+	if err != nil {
+		return nil, fmt.Errorf("%w (If you SetReturnRef(true) you should use QueryItemRef directly)", err)
+	}
+
+	return v, nil
+}
+
+// decodeCF converts ref to the Go values described by Backend.
+func decodeCF(ref C.CFTypeRef) (interface{}, error) {
+	switch C.CFGetTypeID(ref) { // nolint:nlreturn
+	case C.CFDictionaryGetTypeID():
+		m := CFDictionaryToMap(C.CFDictionaryRef(ref))
+		d := make(map[string]interface{}, len(m))
+
+		for k, v := range m {
+			if C.CFGetTypeID(k) != C.CFStringGetTypeID() { // nolint:nlreturn
+				continue
+			}
+
+			// Values without a Go representation, such as references or access
+			// control objects, are skipped.
+			if gv, err := decodeCF(v); err == nil {
+				d[attrKey(k)] = gv
+			}
+		}
+
+		return d, nil
+	case C.CFArrayGetTypeID():
+		arr := CFArrayToArray(C.CFArrayRef(ref))
+		a := make([]interface{}, 0, len(arr))
+
+		for _, e := range arr {
+			gv, err := decodeCF(e)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert CFArray element: %w", err)
+			}
+
+			a = append(a, gv)
+		}
+
+		return a, nil
+	case C.CFDateGetTypeID():
+		return CFDateToTime(C.CFDateRef(ref)), nil
+	default:
+		return Convert(ref)
+	}
+}
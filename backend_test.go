@@ -0,0 +1,213 @@
+package keychain
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAddItemDuplicate(t *testing.T) {
+	f := useFakeBackend(t, fakeResponse{err: ErrorDuplicateItem})
+
+	err := AddItem(NewGenericPassword("MyService", "gabriel", "", []byte("toomanysecrets"), ""))
+	if !errors.Is(err, ErrorDuplicateItem) {
+		t.Fatalf("expected ErrorDuplicateItem, got %v", err)
+	}
+
+	attrs := f.calls[0].attrs
+	if attrs[SecClassKey] != "genp" || attrs[ServiceKey] != "MyService" || attrs[AccountKey] != "gabriel" {
+		t.Fatalf("unexpected attributes: %v", attrs)
+	}
+}
+
+func TestUpsertGenericPassword(t *testing.T) {
+	f := useFakeBackend(t, fakeResponse{err: ErrorItemNotFound})
+
+	if err := upsertGenericPassword("MyService", "gabriel", "", []byte("toomanysecrets")); err != nil {
+		t.Fatal(err)
+	}
+
+	if ops := f.ops(); !reflect.DeepEqual(ops, []string{"copyMatching", "add"}) {
+		t.Fatalf("expected lookup then add, got %v", ops)
+	}
+
+	// The checksum of an existing item is kept current with its data.
+	f = useFakeBackend(t, fakeResponse{result: []interface{}{map[string]interface{}{
+		ServiceKey: "MyService",
+		AccountKey: "gabriel",
+		GenericKey: []byte(`{"sha256":"stale","other":"kept"}`),
+	}}})
+
+	if err := upsertGenericPassword("MyService", "gabriel", "", []byte("toomanysecrets")); err != nil {
+		t.Fatal(err)
+	}
+
+	if ops := f.ops(); !reflect.DeepEqual(ops, []string{"copyMatching", "update"}) {
+		t.Fatalf("expected lookup then update, got %v", ops)
+	}
+
+	m := parseMetadata(f.calls[1].attrs[GenericKey].([]byte))
+	if m[MetadataChecksumKey] != checksum([]byte("toomanysecrets")) || m["other"] != "kept" {
+		t.Fatalf("expected the checksum to be refreshed, got %v", m)
+	}
+}
+
+func TestQueryItemResults(t *testing.T) {
+	modified := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	attrs := map[string]interface{}{
+		ServiceKey:          "MyService",
+		AccountKey:          "gabriel",
+		PortKey:             int64(443),
+		TypeKey:             int32(0x6e6f7465),
+		ModificationDateKey: modified,
+		AccessibleKey:       "ak",
+		"v_Ref":             nil,
+	}
+
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetReturnAttributes(true)
+
+	useFakeBackend(t, fakeResponse{result: attrs})
+
+	results, err := QueryItem(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := QueryResult{
+		Service: "MyService", Account: "gabriel", Port: 443, Type: "note", ModificationDate: modified,
+		Accessible: AccessibleWhenUnlocked, ID: ItemID(SecClassGenericPassword, "MyService", "gabriel", ""),
+		NormalizedService: "MyService", NormalizedAccount: "gabriel",
+	}
+	if len(results) != 1 || !reflect.DeepEqual(results[0], want) {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	useFakeBackend(t, fakeResponse{result: []interface{}{attrs, map[string]interface{}{AccountKey: "alice"}}})
+
+	results, err = QueryItem(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 2 || results[1].Account != "alice" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	useFakeBackend(t, fakeResponse{result: []byte("toomanysecrets")})
+
+	results, err = QueryItem(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 || string(results[0].Data) != "toomanysecrets" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	useFakeBackend(t, fakeResponse{err: ErrorItemNotFound})
+
+	results, err = QueryItem(query)
+	if err != nil || results != nil {
+		t.Fatalf("expected no results, got %+v, %v", results, err)
+	}
+
+	useFakeBackend(t, fakeResponse{result: []interface{}{[]byte("ref")}})
+
+	if _, err := QueryItem(query); err == nil {
+		t.Fatal("expected error for non dictionary results")
+	}
+}
+
+func TestItemExistsQuery(t *testing.T) {
+	f := useFakeBackend(t, fakeResponse{}, fakeResponse{err: ErrorItemNotFound})
+
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService("MyService")
+	query.SetReturnData(true)
+	query.SetReturnAttributes(true)
+
+	exists, err := ItemExists(query)
+	if err != nil || !exists {
+		t.Fatalf("expected item to exist, got %v, %v", exists, err)
+	}
+
+	if WantsResult(f.calls[0].query) {
+		t.Fatalf("ItemExists requested a result: %v", f.calls[0].query)
+	}
+
+	if _, ok := query.attr[MatchLimitKey]; ok {
+		t.Fatal("ItemExists modified the query")
+	}
+
+	exists, err = ItemExists(query)
+	if err != nil || exists {
+		t.Fatalf("expected item not to exist, got %v, %v", exists, err)
+	}
+}
+
+func TestGetGenericPasswordTooManyResults(t *testing.T) {
+	useFakeBackend(t, fakeResponse{result: []interface{}{
+		map[string]interface{}{AccountKey: "gabriel", LabelKey: "one", DataKey: []byte("a")},
+		map[string]interface{}{AccountKey: "gabriel", LabelKey: "two", DataKey: []byte("b")},
+	}})
+
+	_, err := GetGenericPassword("MyService", "gabriel", "", "")
+
+	var tooMany *TooManyResultsError
+	if !errors.As(err, &tooMany) || len(tooMany.Candidates) != 2 {
+		t.Fatalf("expected TooManyResultsError with 2 candidates, got %v", err)
+	}
+}
+
+// plainBackend hides the capabilities of a Backend.
+type plainBackend struct {
+	Backend
+}
+
+func TestUniqueKeys(t *testing.T) {
+	existing := map[string]interface{}{SecClassKey: "genp", ServiceKey: "MyService", AccountKey: "gabriel"}
+	f := &fakeBackend{responses: []fakeResponse{
+		{result: []interface{}{existing}},
+		{result: []interface{}{existing}},
+	}}
+
+	prev := SetBackend(plainBackend{f})
+	defer SetBackend(prev)
+
+	err := AddItem(NewGenericPassword("MyService", "gabriel", "", []byte("a"), ""))
+	if !errors.Is(err, ErrorDuplicateItem) {
+		t.Fatalf("expected ErrorDuplicateItem, got %v", err)
+	}
+
+	// Another access group is another item.
+	if err := AddItem(NewGenericPassword("MyService", "gabriel", "", []byte("a"), "group")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(f.ops(), ","); got != "copyMatching,copyMatching,add" {
+		t.Fatalf("unexpected calls %s", got)
+	}
+
+	// Renaming an item to the account of another is a duplicate.
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService("MyService")
+	query.SetAccount("alice")
+
+	update := NewItem()
+	update.SetAccount("gabriel")
+
+	f.responses = []fakeResponse{
+		{result: []interface{}{map[string]interface{}{SecClassKey: "genp", ServiceKey: "MyService", AccountKey: "alice"}}},
+		{result: []interface{}{existing}},
+	}
+
+	if err := UpdateItem(query, update); !errors.Is(err, ErrorDuplicateItem) {
+		t.Fatalf("expected ErrorDuplicateItem, got %v", err)
+	}
+}
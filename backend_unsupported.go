@@ -0,0 +1,103 @@
+//go:build !darwin
+// +build !darwin
+
+package keychain
+
+import (
+	"runtime"
+	"sync"
+)
+
+// platformBackends are the registered backends used by default on platforms
+// without the Security framework, once their package is imported.
+var platformBackends = map[string]string{
+	"linux":   "secretservice",
+	"windows": "wincred",
+}
+
+// platformBackend is the default Backend on platforms without the Security
+// framework. It opens the registered backend of the platform on first use
+// (see platformBackends), or fails with ErrorUnimplemented if its package
+// isn't imported. Failures to open it are returned and retried on the next
+// call.
+type platformBackend struct{}
+
+var defaultBackend Backend = platformBackend{}
+
+var platform = struct {
+	sync.Mutex
+	b Backend
+}{}
+
+func (platformBackend) open() (Backend, error) {
+	platform.Lock()
+	defer platform.Unlock()
+
+	if platform.b != nil {
+		return platform.b, nil
+	}
+
+	name, ok := platformBackends[runtime.GOOS]
+	if !ok || !registered(name) {
+		return nil, ErrorUnimplemented
+	}
+
+	b, err := OpenBackend(name)
+	if err != nil {
+		return nil, err
+	}
+
+	platform.b = b
+
+	return b, nil
+}
+
+func (p platformBackend) Add(attrs map[string]interface{}) (interface{}, error) {
+	b, err := p.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return b.Add(attrs)
+}
+
+func (p platformBackend) Update(query, attrs map[string]interface{}) error {
+	b, err := p.open()
+	if err != nil {
+		return err
+	}
+
+	return b.Update(query, attrs)
+}
+
+func (p platformBackend) CopyMatching(query map[string]interface{}) (interface{}, error) {
+	b, err := p.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return b.CopyMatching(query)
+}
+
+func (p platformBackend) Delete(query map[string]interface{}) error {
+	b, err := p.open()
+	if err != nil {
+		return err
+	}
+
+	return b.Delete(query)
+}
+
+// Capabilities reports the capabilities of the platform backend, if it can be
+// opened. The platform backends reject duplicate items themselves.
+func (p platformBackend) Capabilities() Capability {
+	b, err := p.open()
+	if err != nil {
+		return Capability{UniqueKeys: true}
+	}
+
+	c := CapabilitiesOf(b)
+	c.UniqueKeys = true
+
+	return c
+}
@@ -0,0 +1,39 @@
+//go:build !darwin
+// +build !darwin
+
+package keychain
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+)
+
+func TestPlatformBackend(t *testing.T) {
+	prevName := platformBackends[runtime.GOOS]
+	platformBackends[runtime.GOOS] = "test-platform"
+
+	prev := SetBackend(nil)
+
+	t.Cleanup(func() {
+		platformBackends[runtime.GOOS] = prevName
+		platform.b = nil
+		SetBackend(prev)
+	})
+
+	if _, err := GetGenericPassword("MyService", "gabriel", "", ""); !errors.Is(err, ErrorUnimplemented) {
+		t.Fatalf("expected unimplemented without a platform backend, got %v", err)
+	}
+
+	f := &fakeBackend{responses: []fakeResponse{{result: []byte("toomanysecrets")}}}
+	RegisterBackend("test-platform", func() (Backend, error) { return f, nil })
+
+	data, err := GetGenericPassword("MyService", "gabriel", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "toomanysecrets" || len(f.calls) != 1 {
+		t.Fatalf("expected the platform backend to be used, got %q, %v", data, f.calls)
+	}
+}
@@ -0,0 +1,379 @@
+// Package backendtest provides a conformance suite for keychain backends, so
+// built-in and third-party backends behave the same for portable code:
+//
+//	func TestConformance(t *testing.T) {
+//		backendtest.RunConformance(t, mybackend.New())
+//	}
+//
+// The suite covers generic passwords only, through the keychain package API,
+// and checks service, account and data (other attributes may legitimately
+// be dropped by a backend).
+package backendtest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+)
+
+// randomOps is the number of operations of the randomized test.
+const randomOps = 200
+
+// maxLargeData is the size of large data for backends without a limit.
+const maxLargeData = 1 << 20
+
+// RunConformance runs the conformance suite against b, which is used as the
+// keychain backend for the duration of the test. Items are created under
+// services starting with "backendtest-" and deleted afterwards.
+func RunConformance(t *testing.T, b keychain.Backend) {
+	t.Helper()
+
+	prev := keychain.SetBackend(b)
+	t.Cleanup(func() { keychain.SetBackend(prev) })
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T, service string)
+	}{
+		{"AddGet", testAddGet},
+		{"Duplicate", testDuplicate},
+		{"NotFound", testNotFound},
+		{"UpdateData", testUpdateData},
+		{"UpdateAccount", testUpdateAccount},
+		{"Delete", testDelete},
+		{"Query", testQuery},
+		{"LargeData", testLargeData},
+		{"BinaryData", testBinaryData},
+		{"Unicode", testUnicode},
+		{"Random", testRandom},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := fmt.Sprintf("backendtest-%s-%d", strings.ToLower(tt.name), rand.Int63())
+			t.Cleanup(func() { deleteService(service) })
+
+			tt.run(t, service)
+		})
+	}
+}
+
+func deleteService(service string) {
+	query := keychain.NewItem()
+	query.SetSecClass(keychain.SecClassGenericPassword)
+	query.SetService(service)
+	query.SetMatchLimit(keychain.MatchLimitAll)
+
+	_ = keychain.DeleteItem(query)
+}
+
+func add(t *testing.T, service, account string, data []byte) {
+	t.Helper()
+
+	if err := keychain.AddItem(keychain.NewGenericPassword(service, account, "", data, "")); err != nil {
+		t.Fatalf("failed to add %s/%s: %v", service, account, err)
+	}
+}
+
+func query(service, account string) keychain.Item {
+	q := keychain.NewItem()
+	q.SetSecClass(keychain.SecClassGenericPassword)
+	q.SetService(service)
+	q.SetAccount(account)
+
+	return q
+}
+
+func expectData(t *testing.T, service, account string, want []byte) {
+	t.Helper()
+
+	data, err := keychain.GetGenericPassword(service, account, "", "")
+	if err != nil {
+		t.Fatalf("failed to get %s/%s: %v", service, account, err)
+	}
+
+	if !bytes.Equal(data, want) {
+		t.Fatalf("%s/%s: expected %d bytes %.32q, got %d bytes %.32q", service, account, len(want), want, len(data), data)
+	}
+}
+
+func expectAccounts(t *testing.T, service string, want ...string) {
+	t.Helper()
+
+	accounts, err := keychain.GetGenericPasswordAccounts(service)
+	if err != nil && !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("failed to get accounts of %s: %v", service, err)
+	}
+
+	sort.Strings(accounts)
+	sort.Strings(want)
+
+	if strings.Join(accounts, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("%s: expected accounts %q, got %q", service, want, accounts)
+	}
+}
+
+func testAddGet(t *testing.T, service string) {
+	add(t, service, "alice", []byte("secret"))
+	expectData(t, service, "alice", []byte("secret"))
+
+	q := query(service, "alice")
+	q.SetMatchLimit(keychain.MatchLimitOne)
+	q.SetReturnAttributes(true)
+
+	results, err := keychain.QueryItem(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 || results[0].Service != service || results[0].Account != "alice" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+}
+
+func testDuplicate(t *testing.T, service string) {
+	add(t, service, "alice", []byte("secret"))
+
+	err := keychain.AddItem(keychain.NewGenericPassword(service, "alice", "", []byte("other"), ""))
+	if !errors.Is(err, keychain.ErrorDuplicateItem) {
+		t.Fatalf("expected ErrorDuplicateItem, got %v", err)
+	}
+
+	expectData(t, service, "alice", []byte("secret"))
+	add(t, service, "bob", []byte("secret"))
+}
+
+func testNotFound(t *testing.T, service string) {
+	expectData(t, service, "nobody", nil)
+
+	if err := keychain.DeleteItem(query(service, "nobody")); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound deleting, got %v", err)
+	}
+
+	update := keychain.NewItem()
+	update.SetData([]byte("secret"))
+
+	if err := keychain.UpdateItem(query(service, "nobody"), update); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound updating, got %v", err)
+	}
+
+	q := query(service, "nobody")
+	q.SetMatchLimit(keychain.MatchLimitAll)
+	q.SetReturnAttributes(true)
+
+	results, err := keychain.QueryItem(q)
+	if err != nil || len(results) != 0 {
+		t.Fatalf("expected no results, got %v, %v", results, err)
+	}
+}
+
+func testUpdateData(t *testing.T, service string) {
+	add(t, service, "alice", []byte("secret"))
+	add(t, service, "bob", []byte("secret"))
+
+	update := keychain.NewItem()
+	update.SetData([]byte("rotated"))
+
+	if err := keychain.UpdateItem(query(service, "alice"), update); err != nil {
+		t.Fatal(err)
+	}
+
+	expectData(t, service, "alice", []byte("rotated"))
+	expectData(t, service, "bob", []byte("secret"))
+}
+
+func testUpdateAccount(t *testing.T, service string) {
+	add(t, service, "alice", []byte("a"))
+	add(t, service, "bob", []byte("b"))
+
+	update := keychain.NewItem()
+	update.SetAccount("bob")
+
+	if err := keychain.UpdateItem(query(service, "alice"), update); !errors.Is(err, keychain.ErrorDuplicateItem) {
+		t.Fatalf("expected ErrorDuplicateItem renaming onto an existing account, got %v", err)
+	}
+
+	update.SetAccount("carol")
+
+	if err := keychain.UpdateItem(query(service, "alice"), update); err != nil {
+		t.Fatal(err)
+	}
+
+	expectAccounts(t, service, "bob", "carol")
+	expectData(t, service, "carol", []byte("a"))
+}
+
+func testDelete(t *testing.T, service string) {
+	add(t, service, "alice", []byte("a"))
+	add(t, service, "bob", []byte("b"))
+
+	if err := keychain.DeleteGenericPasswordItem(service, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	expectAccounts(t, service, "bob")
+	expectData(t, service, "alice", nil)
+}
+
+func testQuery(t *testing.T, service string) {
+	want := []string{"a", "b", "c", "d", "e"}
+	for _, account := range want {
+		add(t, service, account, []byte("data-"+account))
+	}
+
+	expectAccounts(t, service, want...)
+
+	q := keychain.NewItem()
+	q.SetSecClass(keychain.SecClassGenericPassword)
+	q.SetService(service)
+	q.SetMatchLimit(keychain.MatchLimitAll)
+	q.SetReturnAttributes(true)
+	q.SetReturnData(true)
+
+	results, err := keychain.QueryItem(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %d", len(want), len(results))
+	}
+
+	for _, r := range results {
+		if string(r.Data) != "data-"+r.Account {
+			t.Fatalf("unexpected data %q for %s", r.Data, r.Account)
+		}
+	}
+
+	q.SetMatchLimit(keychain.MatchLimitOne)
+
+	results, err = keychain.QueryItem(q)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d, %v", len(results), err)
+	}
+}
+
+func testLargeData(t *testing.T, service string) {
+	size := keychain.Capabilities().MaxDataSize
+	if size == 0 || size > maxLargeData {
+		size = maxLargeData
+	}
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), size/16+1)[:size]
+
+	add(t, service, "large", data)
+	expectData(t, service, "large", data)
+}
+
+func testBinaryData(t *testing.T, service string) {
+	data := make([]byte, 256)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	err := keychain.AddItem(keychain.NewGenericPassword(service, "binary", "", data, ""))
+	if errors.Is(err, keychain.ErrorParam) {
+		t.Skipf("backend only stores text: %v", err)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectData(t, service, "binary", data)
+}
+
+func testUnicode(t *testing.T, service string) {
+	service += "-サービス-Ångström"
+	t.Cleanup(func() { deleteService(service) })
+
+	accounts := []string{"テスト", "émile", "😀 smile", "Zoë"}
+	for _, account := range accounts {
+		add(t, service, account, []byte("pässwörd "+account))
+	}
+
+	expectAccounts(t, service, accounts...)
+
+	for _, account := range accounts {
+		expectData(t, service, account, []byte("pässwörd "+account))
+	}
+}
+
+// testRandom checks random sequences of operations against a model.
+func testRandom(t *testing.T, service string) {
+	seed := rand.Int63()
+	rnd := rand.New(rand.NewSource(seed))
+	model := make(map[string][]byte)
+	accounts := []string{"a", "b", "c", "d"}
+
+	for i := 0; i < randomOps; i++ {
+		account := accounts[rnd.Intn(len(accounts))]
+		data := []byte(fmt.Sprintf("data-%d", i))
+		_, exists := model[account]
+
+		var (
+			op  string
+			err error
+			// want is the error expected from the operation.
+			want error
+		)
+
+		switch rnd.Intn(4) {
+		case 0:
+			op = "add"
+			err = keychain.AddItem(keychain.NewGenericPassword(service, account, "", data, ""))
+
+			if exists {
+				want = keychain.ErrorDuplicateItem
+			} else {
+				model[account] = data
+			}
+		case 1:
+			op = "update"
+			update := keychain.NewItem()
+			update.SetData(data)
+			err = keychain.UpdateItem(query(service, account), update)
+
+			if exists {
+				model[account] = data
+			} else {
+				want = keychain.ErrorItemNotFound
+			}
+		case 2:
+			op = "delete"
+			err = keychain.DeleteItem(query(service, account))
+
+			if exists {
+				delete(model, account)
+			} else {
+				want = keychain.ErrorItemNotFound
+			}
+		default:
+			op = "get"
+
+			var got []byte
+
+			got, err = keychain.GetGenericPassword(service, account, "", "")
+			if err == nil && !bytes.Equal(got, model[account]) {
+				t.Fatalf("seed %d, op %d: get %s: expected %q, got %q", seed, i, account, model[account], got)
+			}
+		}
+
+		if !errors.Is(err, want) {
+			t.Fatalf("seed %d, op %d: %s %s: expected %v, got %v", seed, i, op, account, want, err)
+		}
+	}
+
+	want := make([]string, 0, len(model))
+	for account := range model {
+		want = append(want, account)
+	}
+
+	expectAccounts(t, service, want...)
+}
@@ -1,5 +1,5 @@
-//go:build darwin || ios
-// +build darwin ios
+//go:build darwin || ios || android
+// +build darwin ios android
 
 package bind
 
@@ -0,0 +1,163 @@
+package keychain
+
+import (
+	"reflect"
+	"sync"
+	"time"
+)
+
+// DefaultBindDebounce is the default quiet period before a Binder applies
+// changes.
+const DefaultBindDebounce = 500 * time.Millisecond
+
+// Binder keeps the tagged fields of a struct (see Unmarshal) in sync with the
+// keychain. Changes to the bound items are collected until no further change
+// arrives for the debounce period, then applied together. Readers of the
+// struct must hold RLock while accessing it.
+type Binder struct {
+	sync.RWMutex
+
+	target   reflect.Value
+	fields   []boundField
+	debounce time.Duration
+	onChange func()
+	cancels  []func()
+	done     chan struct{}
+	wg       sync.WaitGroup
+	closed   sync.Once
+}
+
+type bindUpdate struct {
+	field boundField
+	data  []byte
+}
+
+// NewBinder loads the tagged fields of the struct pointed to by target from the
+// generic passwords of service and keeps them updated until Close is called.
+// The items are watched before they are loaded, so no change is missed, and
+// their data is read through the pipeline of the configuration, as with
+// Unmarshal. onChange, if not nil, is called after each batch of changes is
+// applied.
+func NewBinder(service string, target interface{}, debounce time.Duration, onChange func()) (*Binder, error) {
+	sv, err := structValue(target)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := parseFields(sv.Type(), service)
+	if err != nil {
+		return nil, err
+	}
+
+	if debounce <= 0 {
+		debounce = DefaultBindDebounce
+	}
+
+	b := &Binder{
+		target:   sv,
+		fields:   fields,
+		debounce: debounce,
+		onChange: onChange,
+		done:     make(chan struct{}),
+	}
+
+	s := cur()
+	updates := make(chan bindUpdate)
+
+	for _, f := range fields {
+		ch, cancel := WatchItem(f.service, f.account)
+		b.cancels = append(b.cancels, cancel)
+		b.wg.Add(1)
+
+		go func(f boundField) {
+			defer b.wg.Done()
+
+			for r := range ch {
+				// Items that fail to decode are ignored, keeping the
+				// previous value.
+				data, err := s.reverseResult(r)
+				if err != nil {
+					continue
+				}
+
+				select {
+				case updates <- bindUpdate{field: f, data: data}:
+				case <-b.done:
+					return
+				}
+			}
+		}(f)
+	}
+
+	b.wg.Add(1)
+
+	go b.run(updates)
+
+	// Changes made while loading are applied after it, under the lock.
+	b.Lock()
+	err = s.unmarshal(service, target)
+	b.Unlock()
+
+	if err != nil {
+		b.Close()
+
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (b *Binder) run(updates <-chan bindUpdate) {
+	defer b.wg.Done()
+
+	pending := make(map[int]bindUpdate)
+
+	var timer <-chan time.Time
+
+	for {
+		select {
+		case u := <-updates:
+			pending[u.field.index[0]] = u
+			timer = time.After(b.debounce)
+		case <-timer:
+			b.apply(pending)
+			pending = make(map[int]bindUpdate)
+			timer = nil
+		case <-b.done:
+			return
+		}
+	}
+}
+
+func (b *Binder) apply(pending map[int]bindUpdate) {
+	b.Lock()
+	for _, u := range pending {
+		fv := b.target.FieldByIndex(u.field.index)
+		if u.data == nil {
+			fv.Set(reflect.Zero(fv.Type()))
+
+			continue
+		}
+
+		// Values that fail to decode are ignored, keeping the previous value.
+		_ = decodeField(fv, u.data)
+	}
+	b.Unlock()
+
+	if b.onChange != nil {
+		b.onChange()
+	}
+}
+
+// Close stops watching the bound items.
+func (b *Binder) Close() {
+	b.closed.Do(func() {
+		close(b.done)
+
+		for _, cancel := range b.cancels {
+			cancel()
+		}
+
+		b.wg.Wait()
+	})
+}
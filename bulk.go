@@ -0,0 +1,373 @@
+package keychain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ChangeKind is the kind of modification a Change makes.
+type ChangeKind string
+
+const (
+	// ChangeAdd adds a missing item.
+	ChangeAdd ChangeKind = "add"
+	// ChangeUpdate updates attributes or data of an existing item.
+	ChangeUpdate ChangeKind = "update"
+	// ChangeDelete deletes an existing item.
+	ChangeDelete ChangeKind = "delete"
+	// ChangeCustom runs caller supplied code whose effects are not known in
+	// advance.
+	ChangeCustom ChangeKind = "custom"
+)
+
+// Change is a single planned modification made by a bulk operation or
+// migration.
+type Change struct {
+	Kind ChangeKind
+	// Step is the name of the operation or migration that planned the change.
+	Step string
+	// Class is the class of the affected item.
+	Class SecClass
+	// Item holds the attributes of the affected item (without data).
+	Item QueryResult
+	// Description is a human readable summary of the change.
+	Description string
+
+	apply func() error
+}
+
+// Report describes the changes planned (DryRun) or applied by a bulk
+// operation.
+type Report struct {
+	DryRun  bool
+	Changes []Change
+	// Version is the schema version reached (or that would be reached) by
+	// Migrations.Run. It is unused by other operations.
+	Version int
+}
+
+// BulkOptions are options for bulk operations and migrations.
+type BulkOptions struct {
+	// DryRun plans the changes and returns them in the report without
+	// modifying the keychain.
+	DryRun bool
+	// Workers is the number of items processed concurrently. Values less than
+	// 1 process items one at a time, in order.
+	Workers int
+	// Progress, if set, is called after each item is processed with the number
+	// of items done so far and the total. Calls are serialized.
+	Progress func(done, total int)
+}
+
+// Step plans the changes of a bulk operation or migration against the current
+// state of the keychain.
+type Step func() ([]Change, error)
+
+// FuncStep wraps arbitrary code as a step. It is reported as a single
+// ChangeCustom change since its effects can't be planned.
+func FuncStep(description string, apply func() error) Step {
+	return func() ([]Change, error) {
+		return []Change{{Kind: ChangeCustom, Description: description, apply: apply}}, nil
+	}
+}
+
+func applyChanges(ctx context.Context, changes []Change, opts BulkOptions) error {
+	return forEach(ctx, len(changes), opts, func(i int) error {
+		c := changes[i]
+		if err := c.apply(); err != nil {
+			return fmt.Errorf("failed to %s item (%s): %w", c.Kind, c.Description, err)
+		}
+
+		return nil
+	})
+}
+
+// forEach calls fn for 0 <= i < n using opts.Workers goroutines, reporting
+// progress. It stops handing out work on the first error or when ctx is done.
+func forEach(ctx context.Context, n int, opts BulkOptions, fn func(i int) error) error {
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	if workers > n {
+		workers = n
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		done     int
+		firstErr error
+		wg       sync.WaitGroup
+	)
+
+	jobs := make(chan int)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				err := fn(i)
+
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+
+					cancel()
+				}
+
+				done++
+				if opts.Progress != nil {
+					opts.Progress(done, n)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	if done < n {
+		return ctx.Err()
+	}
+
+	return nil
+}
+
+func runBulk(ctx context.Context, name string, step Step, opts BulkOptions) (Report, error) {
+	changes, err := step()
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to plan %s: %w", name, err)
+	}
+
+	for i := range changes {
+		changes[i].Step = name
+	}
+
+	report := Report{DryRun: opts.DryRun, Changes: changes}
+	if opts.DryRun {
+		return report, nil
+	}
+
+	return report, applyChanges(ctx, changes, opts)
+}
+
+// updateChange plans updating the item r of secClass with the attributes of
+// update. Items that have disappeared by the time the change is applied are
+// skipped.
+func updateChange(secClass SecClass, r QueryResult, update Item, description string) Change {
+	return updateQueryChange(queryForResult(secClass, r), r, update, description)
+}
+
+// updateQueryChange is updateChange for the item r matching query.
+func updateQueryChange(query Item, r QueryResult, update Item, description string) Change {
+	return Change{
+		Kind:        ChangeUpdate,
+		Class:       query.secClass(),
+		Item:        r,
+		Description: description,
+		apply: func() error {
+			err := UpdateItem(query, update)
+			if errors.Is(err, ErrorItemNotFound) {
+				return nil
+			}
+
+			return err
+		},
+	}
+}
+
+// deleteChange plans deleting the item r of secClass.
+func deleteChange(secClass SecClass, r QueryResult, description string) Change {
+	return deleteQueryChange(queryForResult(secClass, r), r, description)
+}
+
+// deleteQueryChange is deleteChange for the item r matching query.
+func deleteQueryChange(query Item, r QueryResult, description string) Change {
+	return Change{
+		Kind:        ChangeDelete,
+		Class:       query.secClass(),
+		Item:        r,
+		Description: description,
+		apply: func() error {
+			err := DeleteItem(query)
+			if errors.Is(err, ErrorItemNotFound) {
+				return nil
+			}
+
+			return err
+		},
+	}
+}
+
+// MoveAccessGroupStep plans moving every item matching query to accessGroup.
+func MoveAccessGroupStep(query Item, accessGroup string) Step {
+	return func() ([]Change, error) {
+		results, err := queryAttributes(query)
+		if err != nil {
+			return nil, err
+		}
+
+		secClass := query.secClass()
+		changes := make([]Change, 0, len(results))
+
+		for _, r := range results {
+			if r.AccessGroup == accessGroup {
+				continue
+			}
+
+			update := newItem()
+			update.SetAccessGroup(accessGroup)
+			changes = append(changes, updateChange(secClass, r, update,
+				fmt.Sprintf("move %s from access group %q to %q", describeResult(r), r.AccessGroup, accessGroup)))
+		}
+
+		return changes, nil
+	}
+}
+
+// MoveAccessGroup moves every item matching query (which must set a class) to
+// accessGroup.
+func MoveAccessGroup(ctx context.Context, query Item, accessGroup string, opts BulkOptions) (Report, error) {
+	return runBulk(ctx, "move access group", MoveAccessGroupStep(query, accessGroup), opts)
+}
+
+// PurgeStep plans deleting every item matching query.
+func PurgeStep(query Item) Step {
+	return func() ([]Change, error) {
+		results, err := queryAttributes(query)
+		if err != nil {
+			return nil, err
+		}
+
+		secClass := query.secClass()
+		changes := make([]Change, 0, len(results))
+
+		for _, r := range results {
+			changes = append(changes, deleteChange(secClass, r, "delete "+describeResult(r)))
+		}
+
+		return changes, nil
+	}
+}
+
+// Purge deletes every item matching query (which must set a class), one at a
+// time, reporting each deletion.
+func Purge(ctx context.Context, query Item, opts BulkOptions) (Report, error) {
+	return runBulk(ctx, "purge", PurgeStep(query), opts)
+}
+
+// MergeServicesStep plans moving the generic passwords of fromService into
+// toService. Accounts which already exist in toService are kept and the
+// corresponding fromService items are deleted.
+func MergeServicesStep(fromService, toService string) Step {
+	return func() ([]Change, error) {
+		existing, err := GetGenericPasswordAccounts(toService)
+		if err != nil {
+			return nil, err
+		}
+
+		accounts := make(map[string]bool, len(existing))
+		for _, account := range existing {
+			accounts[account] = true
+		}
+
+		query := NewItem()
+		query.SetSecClass(SecClassGenericPassword)
+		query.SetService(fromService)
+
+		results, err := queryAttributes(query)
+		if err != nil {
+			return nil, err
+		}
+
+		changes := make([]Change, 0, len(results))
+
+		for _, r := range results {
+			if accounts[r.Account] {
+				changes = append(changes, deleteChange(SecClassGenericPassword, r,
+					fmt.Sprintf("delete %s, already present in service %q", describeResult(r), toService)))
+
+				continue
+			}
+
+			update := newItem()
+			update.SetService(toService)
+			changes = append(changes, updateChange(SecClassGenericPassword, r, update,
+				fmt.Sprintf("move %s to service %q", describeResult(r), toService)))
+		}
+
+		return changes, nil
+	}
+}
+
+// MergeServices moves the generic passwords of fromService into toService.
+// Accounts already present in toService take precedence.
+func MergeServices(ctx context.Context, fromService, toService string, opts BulkOptions) (Report, error) {
+	return runBulk(ctx, "merge services", MergeServicesStep(fromService, toService), opts)
+}
+
+// Enumerate returns the attributes and data of every item matching query
+// (which must set a class). Data is fetched item by item, since macOS doesn't
+// support returning data for multiple items at once. Cancelling ctx dismisses
+// a pending authentication prompt, see QueryItemContext.
+func Enumerate(ctx context.Context, query Item, opts BulkOptions) ([]QueryResult, error) {
+	results, err := queryAttributes(query)
+	if err != nil {
+		return nil, err
+	}
+
+	secClass := query.secClass()
+
+	err = forEach(ctx, len(results), opts, func(i int) error {
+		dataQuery := queryForResult(secClass, results[i])
+		dataQuery.SetMatchLimit(MatchLimitOne)
+		dataQuery.SetReturnData(true)
+
+		dataResults, err := QueryItemContext(ctx, dataQuery)
+		if err != nil {
+			return fmt.Errorf("failed to read data of %s: %w", describeResult(results[i]), err)
+		}
+
+		if len(dataResults) == 1 {
+			results[i].Data = dataResults[0].Data
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func describeResult(r QueryResult) string {
+	if r.Server != "" {
+		return fmt.Sprintf("%q@%q", r.Account, r.Server)
+	}
+
+	return fmt.Sprintf("%q@%q", r.Account, r.Service)
+}
@@ -0,0 +1,79 @@
+//go:build darwin && !ios
+// +build darwin,!ios
+
+package keychain
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPurge(t *testing.T) {
+	service := "TestPurge"
+	defer func() { _ = DeleteGenericPasswordItem(service, "a") }()
+	defer func() { _ = DeleteGenericPasswordItem(service, "b") }()
+
+	for _, account := range []string{"a", "b"} {
+		if err := AddItem(NewGenericPassword(service, account, "", []byte("secret"), "")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService(service)
+
+	report, err := Purge(context.Background(), query, BulkOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.DryRun || len(report.Changes) != 2 {
+		t.Fatalf("unexpected dry run report: %+v", report)
+	}
+
+	accounts, err := GetGenericPasswordAccounts(service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 2 {
+		t.Fatalf("dry run should not delete items, have %v", accounts)
+	}
+
+	var progress []int
+	opts := BulkOptions{Workers: 2, Progress: func(done, total int) { progress = append(progress, done) }}
+	if _, err := Purge(context.Background(), query, opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(progress) != 2 || progress[1] != 2 {
+		t.Fatalf("unexpected progress: %v", progress)
+	}
+
+	accounts, err = GetGenericPasswordAccounts(service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 0 {
+		t.Fatalf("expected items to be purged, have %v", accounts)
+	}
+}
+
+func TestForEachCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	calls := 0
+	err := forEach(ctx, 10, BulkOptions{}, func(i int) error {
+		calls++
+		if i == 2 {
+			cancel()
+		}
+
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if calls >= 10 {
+		t.Fatalf("expected processing to stop early, got %d calls", calls)
+	}
+}
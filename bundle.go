@@ -0,0 +1,324 @@
+package keychain
+
+import (
+	"bytes"
+	"context"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// bundleMagic starts export bundles, and identifies their format.
+const bundleMagic = "GKB1"
+
+// bundleSaltSize and bundleIterations are the parameters of the derivation of
+// the key of a bundle from its passphrase, with PBKDF2-HMAC-SHA256.
+const (
+	bundleSaltSize   = 16
+	bundleIterations = 600000
+)
+
+// DefaultChunkSize is the number of bundle bytes per chunk of SplitBundle.
+// Encoded, a chunk is about 1.1 KB of text, which fits a QR code with medium
+// error correction.
+const DefaultChunkSize = 800
+
+// chunkPrefix starts the chunks of SplitBundle.
+const chunkPrefix = "GKB:"
+
+// ErrBundlePassphrase is returned by ImportBundle for a wrong passphrase or a
+// corrupted bundle.
+var ErrBundlePassphrase = errors.New("wrong passphrase or corrupted bundle")
+
+// bundledItem is an item in an export bundle.
+type bundledItem struct {
+	Class              string     `json:"class"`
+	Service            string     `json:"service,omitempty"`
+	Server             string     `json:"server,omitempty"`
+	Protocol           string     `json:"protocol,omitempty"`
+	AuthenticationType string     `json:"authenticationType,omitempty"`
+	Port               int32      `json:"port,omitempty"`
+	Path               string     `json:"path,omitempty"`
+	Account            string     `json:"account"`
+	AccessGroup        string     `json:"accessGroup,omitempty"`
+	Label              string     `json:"label,omitempty"`
+	Description        string     `json:"description,omitempty"`
+	Comment            string     `json:"comment,omitempty"`
+	Type               string     `json:"type,omitempty"`
+	Creator            string     `json:"creator,omitempty"`
+	Generic            []byte     `json:"generic,omitempty"`
+	Accessible         Accessible `json:"accessible,omitempty"`
+	Data               []byte     `json:"data"`
+}
+
+// bundleClasses are the classes of bundled items by name.
+var bundleClasses = map[string]SecClass{
+	"genp": SecClassGenericPassword,
+	"inet": SecClassInternetPassword,
+}
+
+// bundleCodec returns the codec encrypting bundles with the key derived from
+// passphrase and salt.
+func bundleCodec(passphrase string, salt []byte) (Codec, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, bundleIterations, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	return Encrypted(Compressed(JSONCodec), "bundle", key)
+}
+
+// ExportBundle returns the generic and internet passwords matching queries,
+// with their attributes and data, encrypted with a key derived from
+// passphrase, e.g. to copy selected credentials to another Mac without iCloud
+// Keychain; see SplitBundle to transfer it as QR codes. The attributes kept
+// are the service or the server, protocol, authentication type, port and
+// path, the account, access group, label, description, comment, type,
+// creator, generic attribute (with the metadata) and accessibility. Each query
+// must set one of the two classes. Cancelling ctx dismisses a pending
+// authentication prompt, see QueryItemContext.
+func ExportBundle(ctx context.Context, passphrase string, queries ...Item) ([]byte, error) {
+	var items []bundledItem
+
+	for i, query := range queries {
+		var class string
+
+		for name, c := range bundleClasses {
+			if c == query.secClass() {
+				class = name
+			}
+		}
+
+		if class == "" {
+			return nil, fmt.Errorf("query %d: only generic and internet passwords can be exported", i)
+		}
+
+		results, err := Enumerate(ctx, query, BulkOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to export query %d: %w", i, err)
+		}
+
+		for _, r := range results {
+			items = append(items, bundledItem{
+				Class:              class,
+				Service:            r.Service,
+				Server:             r.Server,
+				Protocol:           r.Protocol,
+				AuthenticationType: r.AuthenticationType,
+				Port:               r.Port,
+				Path:               r.Path,
+				Account:            r.Account,
+				AccessGroup:        r.AccessGroup,
+				Label:              r.Label,
+				Description:        r.Description,
+				Comment:            r.Comment,
+				Type:               r.Type,
+				Creator:            r.Creator,
+				Generic:            r.Generic,
+				Accessible:         r.Accessible,
+				Data:               r.Data,
+			})
+		}
+	}
+
+	salt, err := RandBytes(bundleSaltSize)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := bundleCodec(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := c.Marshal(items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the bundle: %w", err)
+	}
+
+	return append(append([]byte(bundleMagic), salt...), sealed...), nil
+}
+
+// ImportBundle adds the items of a bundle of ExportBundle, and returns how many
+// were added. Items which exist already are left unchanged. Attributes not in
+// the bundle, e.g. synchronization, are the defaults of NewItem.
+func ImportBundle(bundle []byte, passphrase string) (int, error) {
+	if !bytes.HasPrefix(bundle, []byte(bundleMagic)) || len(bundle) < len(bundleMagic)+bundleSaltSize {
+		return 0, errors.New("not an export bundle")
+	}
+
+	salt := bundle[len(bundleMagic) : len(bundleMagic)+bundleSaltSize]
+
+	c, err := bundleCodec(passphrase, salt)
+	if err != nil {
+		return 0, err
+	}
+
+	var items []bundledItem
+	if err := c.Unmarshal(bundle[len(bundleMagic)+bundleSaltSize:], &items); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrBundlePassphrase, err)
+	}
+
+	added := 0
+
+	for _, b := range items {
+		item, err := b.item()
+		if err != nil {
+			return added, err
+		}
+
+		err = AddItem(item)
+		if errors.Is(err, ErrorDuplicateItem) {
+			continue
+		}
+
+		if err != nil {
+			return added, fmt.Errorf("failed to import %q: %w", b.Account, err)
+		}
+
+		added++
+	}
+
+	return added, nil
+}
+
+// item returns the item to add for b.
+func (b bundledItem) item() (Item, error) {
+	secClass, ok := bundleClasses[b.Class]
+	if !ok {
+		return Item{}, fmt.Errorf("unknown class %q in bundle", b.Class)
+	}
+
+	item := NewItem()
+	item.SetSecClass(secClass)
+
+	if secClass == SecClassInternetPassword {
+		item.SetServer(b.Server)
+		item.SetProtocol(b.Protocol)
+		item.SetAuthenticationType(b.AuthenticationType)
+		item.SetPort(b.Port)
+		item.SetPath(b.Path)
+	} else {
+		item.SetService(b.Service)
+	}
+
+	item.attr[AccountKey] = b.Account
+	item.SetLabel(b.Label)
+	item.SetDescription(b.Description)
+	item.SetComment(b.Comment)
+	item.SetGeneric(b.Generic)
+	item.SetData(b.Data)
+
+	if b.AccessGroup != "" {
+		item.SetAccessGroup(b.AccessGroup)
+	}
+
+	if b.Accessible != AccessibleDefault {
+		item.SetAccessible(b.Accessible)
+	}
+
+	if err := item.SetType(b.Type); err != nil {
+		return Item{}, err
+	}
+
+	if err := item.SetCreator(b.Creator); err != nil {
+		return Item{}, err
+	}
+
+	return item, nil
+}
+
+// SplitBundle splits bundle into text chunks of up to size bytes of it
+// (DefaultChunkSize if size isn't positive), e.g. to show them as QR codes to
+// a device without a network connection, which reassembles them with
+// JoinBundle. Each chunk is "GKB:" followed by the ID of the bundle, its
+// number and the number of chunks, and the base64 encoded bytes.
+func SplitBundle(bundle []byte, size int) []string {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+
+	sum := sha256.Sum256(bundle)
+	id := hex.EncodeToString(sum[:4])
+	n := (len(bundle) + size - 1) / size
+
+	chunks := make([]string, 0, n)
+
+	for i := 0; i < n; i++ {
+		part := bundle[i*size : min((i+1)*size, len(bundle))]
+		chunks = append(chunks, fmt.Sprintf("%s%s:%d/%d:%s", chunkPrefix, id, i+1, n,
+			base64.RawURLEncoding.EncodeToString(part)))
+	}
+
+	return chunks
+}
+
+// JoinBundle reassembles a bundle from the chunks of SplitBundle, in any
+// order, e.g. as they were scanned. Chunks scanned twice are ignored. It fails
+// if a chunk is missing, or is of another bundle.
+func JoinBundle(chunks []string) ([]byte, error) {
+	var (
+		id    string
+		parts map[int][]byte
+		total int
+	)
+
+	for _, chunk := range chunks {
+		fields := strings.SplitN(strings.TrimPrefix(chunk, chunkPrefix), ":", 3)
+		if !strings.HasPrefix(chunk, chunkPrefix) || len(fields) != 3 {
+			return nil, fmt.Errorf("invalid bundle chunk %.20q", chunk)
+		}
+
+		number, count, ok := strings.Cut(fields[1], "/")
+		i, err1 := strconv.Atoi(number)
+		n, err2 := strconv.Atoi(count)
+
+		if !ok || err1 != nil || err2 != nil || i < 1 || i > n {
+			return nil, fmt.Errorf("invalid bundle chunk number %q", fields[1])
+		}
+
+		// The count is checked before it sizes anything, as it isn't trusted.
+		if n > len(chunks) {
+			return nil, fmt.Errorf("bundle of %d chunks, only %d given", n, len(chunks))
+		}
+
+		part, err := base64.RawURLEncoding.DecodeString(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid bundle chunk %d: %w", i, err)
+		}
+
+		if parts == nil {
+			id, total, parts = fields[0], n, make(map[int][]byte, n)
+		} else if fields[0] != id || n != total {
+			return nil, fmt.Errorf("chunk %d is of another bundle", i)
+		}
+
+		parts[i] = part
+	}
+
+	if parts == nil {
+		return nil, errors.New("no bundle chunks")
+	}
+
+	var bundle []byte
+
+	for i := 1; i <= total; i++ {
+		part, ok := parts[i]
+		if !ok {
+			return nil, fmt.Errorf("bundle chunk %d of %d is missing", i, total)
+		}
+
+		bundle = append(bundle, part...)
+	}
+
+	if sum := sha256.Sum256(bundle); hex.EncodeToString(sum[:4]) != id {
+		return nil, errors.New("bundle chunks are corrupted")
+	}
+
+	return bundle, nil
+}
@@ -0,0 +1,113 @@
+package keychain_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func TestExportBundle(t *testing.T) {
+	keychainfake.Install(t)
+
+	item := keychain.NewGenericPassword("com.example.app", "gabriel", "Example", []byte("toomanysecrets"), "")
+	item.SetComment("deploy key")
+
+	if err := item.SetMetadata(keychain.Metadata{"owner": "ops"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.AddItem(item); err != nil {
+		t.Fatal(err)
+	}
+
+	err := keychain.SaveCredential(keychain.Credential{Username: "gabriel", Password: "hunter2", URL: "https://example.com/login"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	genp := keychain.NewItem()
+	genp.SetSecClass(keychain.SecClassGenericPassword)
+	genp.SetService("com.example.app")
+
+	inet := keychain.NewItem()
+	inet.SetSecClass(keychain.SecClassInternetPassword)
+
+	bundle, err := keychain.ExportBundle(context.Background(), "correct horse", genp, inet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(bundle, []byte("toomanysecrets")) {
+		t.Fatal("expected the bundle to be encrypted")
+	}
+
+	// Import on another device.
+	keychainfake.Install(t)
+
+	if _, err := keychain.ImportBundle(bundle, "wrong"); !errors.Is(err, keychain.ErrBundlePassphrase) {
+		t.Fatalf("expected ErrBundlePassphrase, got %v", err)
+	}
+
+	if n, err := keychain.ImportBundle(bundle, "correct horse"); err != nil || n != 2 {
+		t.Fatalf("expected 2 items imported, got %d (%v)", n, err)
+	}
+
+	genp.SetAccount("gabriel")
+	genp.SetMatchLimit(keychain.MatchLimitOne)
+	genp.SetReturnAttributes(true)
+	genp.SetReturnData(true)
+
+	results, err := keychain.QueryItem(genp)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("expected the imported item, got %d (%v)", len(results), err)
+	}
+
+	r := results[0]
+	if string(r.Data) != "toomanysecrets" || r.Label != "Example" || r.Comment != "deploy key" || r.Metadata()["owner"] != "ops" {
+		t.Fatalf("attributes not preserved: %+v", r)
+	}
+
+	c, err := keychain.LoadCredential("https://example.com/login", "gabriel")
+	if err != nil || c.Password != "hunter2" {
+		t.Fatalf("expected the credential, got %+v (%v)", c, err)
+	}
+
+	if n, err := keychain.ImportBundle(bundle, "correct horse"); err != nil || n != 0 {
+		t.Fatalf("expected existing items to be skipped, got %d (%v)", n, err)
+	}
+}
+
+func TestSplitBundle(t *testing.T) {
+	bundle := bytes.Repeat([]byte("0123456789"), 25)
+
+	chunks := keychain.SplitBundle(bundle, 100)
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(chunks))
+	}
+
+	// Scanned out of order, one twice.
+	scanned := []string{chunks[2], chunks[0], chunks[2], chunks[1]}
+
+	joined, err := keychain.JoinBundle(scanned)
+	if err != nil || !bytes.Equal(joined, bundle) {
+		t.Fatalf("expected the bundle, got %d bytes (%v)", len(joined), err)
+	}
+
+	if _, err := keychain.JoinBundle(chunks[:2]); err == nil {
+		t.Fatal("expected an error for a missing chunk")
+	}
+
+	other := keychain.SplitBundle([]byte("another bundle"), 100)
+	if _, err := keychain.JoinBundle(slices.Concat(chunks, other)); err == nil {
+		t.Fatal("expected an error for chunks of another bundle")
+	}
+
+	if _, err := keychain.JoinBundle([]string{"GKB:abcd:1/999999999:AA"}); err == nil {
+		t.Fatal("expected an error for a count larger than the chunks")
+	}
+}
@@ -0,0 +1,95 @@
+package keychain
+
+import "context"
+
+// QueryItemContext returns the items matching query like QueryItem, and
+// dismisses a pending authentication prompt when ctx is done, e.g. when the
+// user navigates away from the screen which asked for a secret. It then
+// returns the error of ctx.
+//
+// Prompts are dismissed by invalidating the authentication context of the
+// query (see Item.SetAuthenticationContext), so a context set on query can't
+// be used anymore once ctx is done. Without one, a context is created for the
+// query. This only applies to items protected by an AccessControl, on macOS
+// and iOS. Other waits can't be interrupted, e.g. for the unlock dialog of a
+// file keychain or a slow backend: QueryItemContext still returns when ctx is
+// done, so that an operation doesn't exceed its deadline, while the call
+// finishes in the background and its results are discarded.
+func QueryItemContext(ctx context.Context, query Item) ([]QueryResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if ctx.Done() == nil {
+		return QueryItem(query)
+	}
+
+	query, stop := withCancelablePrompt(ctx, query)
+
+	type reply struct {
+		results []QueryResult
+		err     error
+	}
+
+	replies := make(chan reply, 1)
+
+	go func() {
+		results, err := QueryItem(query)
+		stop()
+		replies <- reply{results, err}
+	}()
+
+	select {
+	case r := <-replies:
+		if ctx.Err() != nil {
+			wipeResults(r.results)
+
+			return nil, ctx.Err()
+		}
+
+		return r.results, r.err
+	case <-ctx.Done():
+		go func() { wipeResults((<-replies).results) }()
+
+		return nil, ctx.Err()
+	}
+}
+
+// wipeResults clears the data of discarded results.
+func wipeResults(results []QueryResult) {
+	for _, r := range results {
+		clear(r.Data)
+	}
+}
+
+// withCancelablePrompt returns query with an authentication context which is
+// invalidated when ctx is done, and a function to call once the query
+// returned. Queries not reading data never prompt and are returned as is.
+func withCancelablePrompt(ctx context.Context, query Item) (Item, func()) {
+	if wantData, _ := query.attr[ReturnDataKey].(bool); !wantData || ctx.Done() == nil {
+		return query, func() {}
+	}
+
+	authCtx, ok := query.attr[UseAuthenticationContextKey].(*AuthenticationContext)
+	owned := !ok
+
+	if owned {
+		var err error
+		if authCtx, err = NewAuthenticationContext(); err != nil {
+			return query, func() {}
+		}
+
+		query = query.clone()
+		query.SetAuthenticationContext(authCtx)
+	}
+
+	stop := context.AfterFunc(ctx, func() { _ = authCtx.Close() })
+
+	return query, func() {
+		stop()
+
+		if owned {
+			_ = authCtx.Close()
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package keychain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestQueryItemContext(t *testing.T) {
+	f := useFakeBackend(t, fakeResponse{result: map[string]interface{}{AccountKey: "gabriel", DataKey: []byte("secret")}})
+
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService("TestQueryItemContext")
+	query.SetMatchLimit(MatchLimitOne)
+	query.SetReturnData(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	results, err := QueryItemContext(ctx, query)
+	if err != nil || len(results) != 1 || string(results[0].Data) != "secret" {
+		t.Fatalf("unexpected results %+v (%v)", results, err)
+	}
+
+	cancel()
+
+	if _, err := QueryItemContext(ctx, query); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	if len(f.calls) != 1 {
+		t.Fatalf("expected no query once cancelled, got %v", f.ops())
+	}
+}
+
+func TestQueryItemContextDeadline(t *testing.T) {
+	wait := make(chan struct{})
+	defer close(wait)
+
+	useFakeBackend(t, fakeResponse{result: map[string]interface{}{AccountKey: "gabriel", DataKey: []byte("secret")}, wait: wait})
+
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService("TestQueryItemContextDeadline")
+	query.SetMatchLimit(MatchLimitOne)
+	query.SetReturnAttributes(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if _, err := QueryItemContext(ctx, query); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded while the backend is blocked, got %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package keychain
+
+// Capability describes the features of a backend, so portable code can
+// feature-detect instead of checking GOOS.
+type Capability struct {
+	// AccessGroups reports whether items can be shared between apps with
+	// access groups, see Item.SetAccessGroup.
+	AccessGroups bool
+	// Biometrics reports whether items can require biometric authentication.
+	Biometrics bool
+	// Sync reports whether items can be synchronized between devices, see
+	// Item.SetSynchronizable.
+	Sync bool
+	// PersistentRefs reports whether persistent references can be returned
+	// and queried, see Item.SetReturnPersistentRef.
+	PersistentRefs bool
+	// ChangeNotifications reports whether the backend notifies of changes to
+	// items. Otherwise WatchItem polls.
+	ChangeNotifications bool
+	// UniqueKeys reports whether the backend rejects duplicate items itself,
+	// see SetBackend.
+	UniqueKeys bool
+	// ReadOnly reports whether the backend rejects changes with
+	// ErrReadOnlyMode, see ReadOnly.
+	ReadOnly bool
+	// MaxDataSize is the size of the largest item data in bytes, or 0 if
+	// there is no known limit.
+	MaxDataSize int
+	// MaxGenericSize is the size of the largest generic attribute in bytes,
+	// which holds the item metadata (see Item.SetMetadata), or 0 if there is
+	// no known limit.
+	MaxGenericSize int
+}
+
+// CapabilityReporter is implemented by backends reporting their
+// capabilities.
+type CapabilityReporter interface {
+	Capabilities() Capability
+}
+
+// CapabilitiesOf returns the capabilities of b. Backends not implementing
+// CapabilityReporter are assumed to only store items, without any of the
+// optional features.
+func CapabilitiesOf(b Backend) Capability {
+	if r, ok := b.(CapabilityReporter); ok {
+		return r.Capabilities()
+	}
+
+	return Capability{}
+}
+
+// Capabilities returns the capabilities of the backend used by this package.
+func Capabilities() Capability {
+	return CapabilitiesOf(backend())
+}
@@ -0,0 +1,245 @@
+package keychain
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha1" // nolint: gosec
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ImportCertificate adds a DER encoded certificate to the keychain, labeled
+// with its subject common name. It fails with ErrorDuplicateItem if the
+// certificate was already added.
+func ImportCertificate(der []byte) error {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return fmt.Errorf("invalid certificate: %w", err)
+	}
+
+	item := NewItem()
+	item.SetSecClass(SecClassCertificate)
+	item.SetData(der)
+
+	if cert.Subject.CommonName != "" {
+		item.SetLabel(cert.Subject.CommonName)
+	}
+
+	return AddItem(item)
+}
+
+// QueryCertificates returns the certificates matching query, e.g. by label,
+// parsed from their DER encoding (SecCertificateCopyData). The class of query
+// is set. Data is read certificate by certificate, by persistent reference,
+// since macOS doesn't return data for multiple items at once. No certificate
+// matching is not an error.
+func QueryCertificates(query Item) ([]*x509.Certificate, error) {
+	items, err := QueryCertificateItems(query)
+	if err != nil {
+		return nil, err
+	}
+
+	certs := make([]*x509.Certificate, 0, len(items))
+	for _, item := range items {
+		certs = append(certs, item.Certificate)
+	}
+
+	return certs, nil
+}
+
+// GetCertificate returns the certificate with label, or ErrorItemNotFound.
+func GetCertificate(label string) (*x509.Certificate, error) {
+	query := NewItem()
+	query.SetLabel(label)
+	query.SetMatchLimit(MatchLimitOne)
+
+	certs, err := QueryCertificates(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(certs) == 0 {
+		return nil, ErrorItemNotFound
+	}
+
+	return certs[0], nil
+}
+
+// FindCertificateByPublicKeyHash returns the certificate whose public key has
+// the SHA-1 hash (kSecAttrPublicKeyHash), or ErrorItemNotFound. The hash is
+// the ApplicationLabelKey of the key item, so this finds the certificate of
+// a known key.
+func FindCertificateByPublicKeyHash(hash []byte) (*x509.Certificate, error) {
+	query := NewItem()
+	query.SetBytes(PublicKeyHashKey, hash)
+	query.SetMatchLimit(MatchLimitOne)
+
+	certs, err := QueryCertificates(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(certs) == 0 {
+		return nil, ErrorItemNotFound
+	}
+
+	return certs[0], nil
+}
+
+// FindCertificateBySerial returns the certificate with the DER encoded issuer
+// name (x509.Certificate.RawIssuer) and serial number, which identify a
+// certificate uniquely, or ErrorItemNotFound. Certificates are queried by
+// serial number (kSecAttrSerialNumber) and compared by issuer, since the
+// keychain may store the issuer normalized.
+func FindCertificateBySerial(issuer []byte, serial *big.Int) (*x509.Certificate, error) {
+	query := NewItem()
+	query.SetBytes(SerialNumberKey, serialBytes(serial))
+
+	certs, err := QueryCertificates(query)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cert := range certs {
+		if bytes.Equal(cert.RawIssuer, issuer) && cert.SerialNumber.Cmp(serial) == 0 {
+			return cert, nil
+		}
+	}
+
+	return nil, ErrorItemNotFound
+}
+
+// serialBytes returns the content of the DER encoding of a non-negative serial
+// number, as stored in kSecAttrSerialNumber.
+func serialBytes(serial *big.Int) []byte {
+	b := serial.Bytes()
+	if len(b) == 0 || b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+
+	return b
+}
+
+// CertificatePublicKeyHash returns the SHA-1 hash of the public key of cert,
+// as stored in kSecAttrPublicKeyHash: the hash of the ANSI X9.63 point of EC
+// keys, or of the PKCS #1 encoding of RSA keys.
+func CertificatePublicKeyHash(cert *x509.Certificate) ([]byte, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+
+	if _, err := asn1.Unmarshal(cert.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil, fmt.Errorf("invalid subject public key info: %w", err)
+	}
+
+	sum := sha1.Sum(spki.PublicKey.Bytes) // nolint: gosec
+
+	return sum[:], nil
+}
+
+// PairCertificateWithKey makes the keychain pair cert with the permanent
+// private key, so that they form an identity (see QueryIdentities): the
+// keychain pairs a certificate with the key whose application label is the
+// public key hash of the certificate (see CertificatePublicKeyHash), which
+// keys imported or created elsewhere may lack. The certificate is added
+// unless it is in the keychain already, the application label of the key is
+// set, and the key is labeled with the subject common name of cert. It fails
+// if cert isn't for the key, and with ErrorUnimplemented on platforms other
+// than macOS and iOS.
+func PairCertificateWithKey(cert *x509.Certificate, key *Key) error {
+	public, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !public.Equal(key.Public()) {
+		return errors.New("the certificate is not for the key")
+	}
+
+	hash, err := CertificatePublicKeyHash(cert)
+	if err != nil {
+		return err
+	}
+
+	if err := ImportCertificate(cert.Raw); err != nil && !errors.Is(err, ErrorDuplicateItem) {
+		return fmt.Errorf("failed to add the certificate: %w", err)
+	}
+
+	err = key.withRef(func(ref uintptr) error {
+		return relabelKey(ref, hash, cert.Subject.CommonName)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to label the key: %w", err)
+	}
+
+	return nil
+}
+
+// Identity is a certificate with its private key, as stored in the keychain
+// (SecIdentity), e.g. a TLS client certificate. The private key stays in the
+// keychain and is used through crypto.Signer. Close releases the key.
+type Identity struct {
+	cert *x509.Certificate
+	key  *Key
+}
+
+// Certificate returns the certificate of the identity.
+func (id *Identity) Certificate() *x509.Certificate {
+	return id.cert
+}
+
+// PrivateKey returns the private key of the identity.
+func (id *Identity) PrivateKey() *Key {
+	return id.key
+}
+
+// TLSCertificate returns the identity as a tls.Certificate, followed by the
+// intermediates of its chain, e.g. for the client certificates of an
+// http.Transport:
+//
+//	transport := &http.Transport{TLSClientConfig: &tls.Config{
+//		Certificates: []tls.Certificate{id.TLSCertificate()},
+//	}}
+func (id *Identity) TLSCertificate(intermediates ...*x509.Certificate) tls.Certificate {
+	chain := [][]byte{id.cert.Raw}
+	for _, c := range intermediates {
+		chain = append(chain, c.Raw)
+	}
+
+	return tls.Certificate{Certificate: chain, PrivateKey: id.key, Leaf: id.cert}
+}
+
+// Close releases the private key of the identity.
+func (id *Identity) Close() error {
+	return id.key.Close()
+}
+
+// ImportIdentity adds the identities of a PKCS #12 archive (a .p12 or .pfx
+// file) to the keychain and returns them. Identities already in the keychain
+// are returned as well. It fails with ErrorUnimplemented on platforms other
+// than macOS and iOS, and with ErrorAuthFailed for a wrong password.
+func ImportIdentity(pkcs12 []byte, password string) ([]*Identity, error) {
+	if err := checkWritable(); err != nil {
+		return nil, err
+	}
+
+	return importIdentity(pkcs12, password)
+}
+
+// QueryIdentities returns the identities matching query, e.g. by label. The
+// class of query is set, and all matches are returned unless query sets a
+// match limit. It always calls the Security framework, like QueryItemRef, and
+// fails with ErrorUnimplemented on other platforms.
+func QueryIdentities(query Item) ([]*Identity, error) {
+	q := query.clone()
+	q.SetSecClass(SecClassIdentity)
+	q.SetReturnRef(true)
+
+	if _, ok := q.attr[MatchLimitKey]; !ok {
+		q.SetMatchLimit(MatchLimitAll)
+	}
+
+	return queryIdentities(q)
+}
@@ -0,0 +1,209 @@
+//go:build darwin
+// +build darwin
+
+package keychain
+
+/*
+#cgo LDFLAGS: -framework CoreFoundation -framework Security
+
+#include <CoreFoundation/CoreFoundation.h>
+#include <Security/Security.h>
+
+// importPKCS12 decodes the identities of a PKCS #12 archive into items, an
+// array of dictionaries which must be released.
+static OSStatus importPKCS12(CFDataRef data, CFStringRef password, CFArrayRef *items) {
+	const void *keys[] = {kSecImportExportPassphrase};
+	const void *values[] = {password};
+	CFDictionaryRef options = CFDictionaryCreate(NULL, keys, values, 1,
+		&kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+
+	OSStatus status = SecPKCS12Import(data, options, items);
+	CFRelease(options);
+
+	return status;
+}
+
+// importedIdentity returns the identity of the i-th item of importPKCS12,
+// which must not be released.
+static SecIdentityRef importedIdentity(CFArrayRef items, CFIndex i) {
+	CFDictionaryRef item = CFArrayGetValueAtIndex(items, i);
+
+	return (SecIdentityRef)CFDictionaryGetValue(item, kSecImportItemIdentity);
+}
+
+// addIdentity adds identity, its certificate and private key, to the keychain.
+// Identities already in the keychain are not an error.
+static OSStatus addIdentity(SecIdentityRef identity) {
+	const void *keys[] = {kSecValueRef};
+	const void *values[] = {identity};
+	CFDictionaryRef attrs = CFDictionaryCreate(NULL, keys, values, 1,
+		&kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+
+	OSStatus status = SecItemAdd(attrs, NULL);
+	CFRelease(attrs);
+
+	return status == errSecDuplicateItem ? errSecSuccess : status;
+}
+
+// identityParts returns the private key and the DER certificate of identity,
+// which must be released.
+static OSStatus identityParts(SecIdentityRef identity, SecKeyRef *key, CFDataRef *der) {
+	SecCertificateRef cert = NULL;
+
+	OSStatus status = SecIdentityCopyCertificate(identity, &cert);
+	if (status != errSecSuccess) {
+		return status;
+	}
+
+	*der = SecCertificateCopyData(cert);
+	CFRelease(cert);
+
+	status = SecIdentityCopyPrivateKey(identity, key);
+	if (status != errSecSuccess) {
+		CFRelease(*der);
+	}
+
+	return status;
+}
+
+static int isArray(CFTypeRef ref) {
+	return CFGetTypeID(ref) == CFArrayGetTypeID();
+}
+*/
+import "C"
+
+import (
+	"crypto/x509"
+	"fmt"
+)
+
+func importIdentity(pkcs12 []byte, password string) ([]*Identity, error) {
+	data, err := BytesToCFData(pkcs12)
+	if err != nil {
+		return nil, err
+	}
+
+	defer releaseCFData(data)
+
+	cfPassword, err := StringToCFString(password)
+	if err != nil {
+		return nil, err
+	}
+
+	defer releaseCFString(cfPassword)
+
+	var items C.CFArrayRef
+
+	if err := checkError(C.importPKCS12(data, cfPassword, &items)); err != nil { // nolint: nlreturn
+		return nil, fmt.Errorf("failed to import PKCS #12 archive: %w", err)
+	}
+
+	defer Release(C.CFTypeRef(items))
+
+	var identities []*Identity
+
+	for i := C.CFIndex(0); i < C.CFArrayGetCount(items); i++ { // nolint: nlreturn
+		ref := C.importedIdentity(items, i) // nolint: nlreturn
+		if ref == 0 {
+			continue
+		}
+
+		if err := checkError(C.addIdentity(ref)); err != nil { // nolint: nlreturn
+			closeIdentities(identities)
+
+			return nil, fmt.Errorf("failed to add identity: %w", err)
+		}
+
+		id, err := identityFromRef(ref)
+		if err != nil {
+			closeIdentities(identities)
+
+			return nil, err
+		}
+
+		identities = append(identities, id)
+	}
+
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("no identity in PKCS #12 archive: %w", ErrorItemNotFound)
+	}
+
+	return identities, nil
+}
+
+func queryIdentities(query Item) ([]*Identity, error) {
+	result, err := QueryItemRef(query)
+	if err != nil || result == 0 {
+		return nil, err
+	}
+
+	defer Release(result)
+
+	refs := []C.CFTypeRef{result}
+	if C.isArray(result) != 0 { // nolint: nlreturn
+		refs = CFArrayToArray(C.CFArrayRef(result))
+	}
+
+	identities := make([]*Identity, 0, len(refs))
+
+	for _, ref := range refs {
+		id, err := identityFromRef(C.SecIdentityRef(ref))
+		if err != nil {
+			closeIdentities(identities)
+
+			return nil, err
+		}
+
+		identities = append(identities, id)
+	}
+
+	return identities, nil
+}
+
+// identityFromRef returns the certificate and private key of identity.
+func identityFromRef(identity C.SecIdentityRef) (*Identity, error) {
+	var (
+		key C.SecKeyRef
+		der C.CFDataRef
+	)
+
+	if err := checkError(C.identityParts(identity, &key, &der)); err != nil { // nolint: nlreturn
+		return nil, fmt.Errorf("failed to read identity: %w", err)
+	}
+
+	defer releaseCFData(der)
+
+	b, err := CFDataToBytes(der)
+	if err != nil {
+		Release(C.CFTypeRef(key))
+
+		return nil, err
+	}
+
+	cert, err := x509.ParseCertificate(b)
+	if err != nil {
+		Release(C.CFTypeRef(key))
+
+		return nil, fmt.Errorf("invalid identity certificate: %w", err)
+	}
+
+	keyType, bits, err := keyRefInfo(key)
+	if err != nil {
+		Release(C.CFTypeRef(key))
+
+		return nil, err
+	}
+
+	k, err := newKey(uintptr(key), keyType, bits)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Identity{cert: cert, key: k}, nil
+}
+
+func closeIdentities(identities []*Identity) {
+	for _, id := range identities {
+		_ = id.Close()
+	}
+}
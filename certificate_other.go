@@ -0,0 +1,12 @@
+//go:build !darwin
+// +build !darwin
+
+package keychain
+
+func importIdentity([]byte, string) ([]*Identity, error) {
+	return nil, ErrorUnimplemented
+}
+
+func queryIdentities(Item) ([]*Identity, error) {
+	return nil, ErrorUnimplemented
+}
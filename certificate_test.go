@@ -0,0 +1,145 @@
+package keychain_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func selfSigned(t *testing.T, cn string) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return der
+}
+
+func TestCertificates(t *testing.T) {
+	keychainfake.Install(t)
+
+	der := selfSigned(t, "client.example.com")
+
+	if err := keychain.ImportCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.ImportCertificate([]byte("not a certificate")); err == nil {
+		t.Fatal("expected an invalid certificate to be rejected")
+	}
+
+	cert, err := keychain.GetCertificate("client.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cert.Subject.CommonName != "client.example.com" {
+		t.Fatalf("unexpected certificate %v", cert.Subject)
+	}
+
+	certs, err := keychain.QueryCertificates(keychain.NewItem())
+	if err != nil || len(certs) != 1 {
+		t.Fatalf("expected 1 certificate, got %d, %v", len(certs), err)
+	}
+
+	if _, err := keychain.GetCertificate("other"); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+// addCertificate adds der with the attributes the Security framework derives
+// from a certificate.
+func addCertificate(t *testing.T, der []byte, publicKeyHash []byte) *x509.Certificate {
+	t.Helper()
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item := keychain.NewItem()
+	item.SetSecClass(keychain.SecClassCertificate)
+	item.SetData(der)
+	item.SetLabel(cert.Subject.CommonName)
+	item.SetBytes(keychain.SerialNumberKey, cert.SerialNumber.Bytes())
+	item.SetBytes(keychain.IssuerKey, cert.RawIssuer)
+	item.SetBytes(keychain.PublicKeyHashKey, publicKeyHash)
+
+	if err := keychain.AddItem(item); err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+func TestFindCertificate(t *testing.T) {
+	keychainfake.Install(t)
+
+	first := addCertificate(t, selfSigned(t, "first.example.com"), []byte("hash1"))
+	second := addCertificate(t, selfSigned(t, "second.example.com"), []byte("hash2"))
+
+	cert, err := keychain.FindCertificateByPublicKeyHash([]byte("hash2"))
+	if err != nil || !cert.Equal(second) {
+		t.Fatalf("unexpected certificate %v (%v)", cert, err)
+	}
+
+	// Both certificates have serial number 1.
+	cert, err = keychain.FindCertificateBySerial(first.RawIssuer, big.NewInt(1))
+	if err != nil || !cert.Equal(first) {
+		t.Fatalf("unexpected certificate %v (%v)", cert, err)
+	}
+
+	if _, err := keychain.FindCertificateBySerial(first.RawIssuer, big.NewInt(2)); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound, got %v", err)
+	}
+
+	if _, err := keychain.FindCertificateByPublicKeyHash([]byte("hash3")); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound, got %v", err)
+	}
+}
+
+func TestCertificatePublicKeyHash(t *testing.T) {
+	cert, err := x509.ParseCertificate(selfSigned(t, "client.example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := keychain.CertificatePublicKeyHash(cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	point, err := cert.PublicKey.(*ecdsa.PublicKey).ECDH()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := sha1.Sum(point.Bytes()); !bytes.Equal(hash, want[:]) {
+		t.Fatalf("unexpected hash %x, want %x", hash, want)
+	}
+}
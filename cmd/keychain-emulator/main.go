@@ -0,0 +1,91 @@
+// Command keychain-emulator serves an emulated macOS keychain, so integration
+// tests of macOS apps can run on Linux. See the keychainemu package.
+//
+// Usage:
+//
+//	keychain-emulator [-listen addr] [-state file]
+//
+// The address is host:port or unix: followed by a socket path. Items are
+// kept in memory unless a state file is given.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/internal/secitem"
+	"github.com/mailstone/go-keychain/keychainemu"
+)
+
+// plainSealer stores the state file unencrypted; it only holds test data.
+type plainSealer struct{}
+
+func (plainSealer) Seal(b []byte) ([]byte, error) { return b, nil }
+func (plainSealer) Open(b []byte) ([]byte, error) { return b, nil }
+
+func listen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+
+		return net.Listen("unix", path)
+	}
+
+	return net.Listen("tcp", addr)
+}
+
+func main() {
+	addr := flag.String("listen", "127.0.0.1:9461", "address to listen on, host:port or unix:path")
+	state := flag.String("state", "", "file to keep items in across restarts")
+	flag.Parse()
+
+	open := keychainemu.NewKeychain
+	if *state != "" {
+		first := true
+		open = func() (keychain.Backend, error) {
+			// Resetting starts over with an empty file.
+			if !first {
+				if err := os.Remove(*state); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return nil, err
+				}
+			}
+
+			first = false
+
+			return secitem.OpenFile(*state, plainSealer{})
+		}
+	}
+
+	srv, err := keychainemu.NewServer(open)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	l, err := listen(*addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("keychain emulator listening on %s", *addr)
+
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		l.Close()
+	}()
+
+	if err := http.Serve(l, srv); err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Fatal(err)
+	}
+}
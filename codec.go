@@ -0,0 +1,292 @@
+package keychain
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MetadataCodecKey is the metadata key holding the ID of the Codec of values
+// written with SetValue.
+const MetadataCodecKey = "codec"
+
+// Codec serializes the values of SetValue and GetValue. Codecs are identified
+// by ID in the item metadata, so values can be read after the codec used for
+// writing changes; codecs other than the built-in JSONCodec and GobCodec must
+// be registered with RegisterCodec to be read.
+type Codec interface {
+	// ID identifies the codec and its configuration, e.g. "json" or
+	// "gzip+json".
+	ID() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// ItemCodec is a Codec binding its output to the generic password it is
+// stored in, e.g. so that a value copied to another item fails to decode.
+// SetValue and GetValue call MarshalItem and UnmarshalItem with the service
+// and account of the item instead of Marshal and Unmarshal.
+type ItemCodec interface {
+	Codec
+	MarshalItem(service, account string, v interface{}) ([]byte, error)
+	UnmarshalItem(service, account string, data []byte, v interface{}) error
+}
+
+// marshalItem encodes v with c for the item of service and account.
+func marshalItem(c Codec, service, account string, v interface{}) ([]byte, error) {
+	if ic, ok := c.(ItemCodec); ok {
+		return ic.MarshalItem(service, account, v)
+	}
+
+	return c.Marshal(v)
+}
+
+// unmarshalItem decodes data of the item of service and account with c.
+func unmarshalItem(c Codec, service, account string, data []byte, v interface{}) error {
+	if ic, ok := c.(ItemCodec); ok {
+		return ic.UnmarshalItem(service, account, data, v)
+	}
+
+	return c.Unmarshal(data, v)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ID() string                                 { return "json" }
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+type gobCodec struct{}
+
+func (gobCodec) ID() string { return "gob" }
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(v); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// Built-in codecs. JSONCodec is used for values without a codec in their
+// metadata, such as those written by SetJSON.
+var (
+	JSONCodec Codec = jsonCodec{}
+	GobCodec  Codec = gobCodec{}
+)
+
+var codecs = struct {
+	sync.RWMutex
+	byID map[string]Codec
+}{byID: map[string]Codec{JSONCodec.ID(): JSONCodec, GobCodec.ID(): GobCodec}}
+
+// RegisterCodec makes c available to GetValue, replacing a codec with the
+// same ID. Codecs wrapping others (Compressed, Encrypted) must be registered
+// as configured, e.g. with their key.
+func RegisterCodec(c Codec) {
+	codecs.Lock()
+	defer codecs.Unlock()
+
+	codecs.byID[c.ID()] = c
+}
+
+func lookupCodec(id string) (Codec, bool) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+
+	c, ok := codecs.byID[id]
+
+	return c, ok
+}
+
+type gzipCodec struct {
+	Codec
+}
+
+// Compressed returns a codec compressing the output of c with gzip, with the
+// ID "gzip+" followed by the ID of c.
+func Compressed(c Codec) Codec {
+	return gzipCodec{c}
+}
+
+func (c gzipCodec) ID() string {
+	return "gzip+" + c.Codec.ID()
+}
+
+func (c gzipCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.Codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return compress(data)
+}
+
+// MarshalItem implements ItemCodec, for wrapped codecs implementing it.
+func (c gzipCodec) MarshalItem(service, account string, v interface{}) ([]byte, error) {
+	data, err := marshalItem(c.Codec, service, account, v)
+	if err != nil {
+		return nil, err
+	}
+
+	return compress(data)
+}
+
+func compress(data []byte) ([]byte, error) {
+	var b bytes.Buffer
+
+	w := gzip.NewWriter(&b)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+func (c gzipCodec) Unmarshal(data []byte, v interface{}) error {
+	plain, err := decompress(data)
+	if err != nil {
+		return err
+	}
+
+	return c.Codec.Unmarshal(plain, v)
+}
+
+// UnmarshalItem implements ItemCodec, for wrapped codecs implementing it.
+func (c gzipCodec) UnmarshalItem(service, account string, data []byte, v interface{}) error {
+	plain, err := decompress(data)
+	if err != nil {
+		return err
+	}
+
+	return unmarshalItem(c.Codec, service, account, plain, v)
+}
+
+func decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(r)
+}
+
+type aesCodec struct {
+	Codec
+	name string
+	aead cipher.AEAD
+}
+
+// Encrypted returns a codec encrypting the output of c with AES-GCM and key
+// (16, 24 or 32 bytes), for values which must stay confidential even if the
+// keychain item is exported or synced to a less trusted store. Its ID is
+// "aes-" followed by name and "+" and the ID of c; use distinct names for
+// distinct keys.
+//
+// With SetValue and GetValue, the ciphertext is bound to the service and
+// account of the item, as associated data, so that a value copied onto
+// another item (e.g. by another process with access to the keychain) fails
+// to decrypt. Values encrypted with Marshal, which aren't bound, fail to
+// decrypt with GetValue as well.
+func Encrypted(c Codec, name string, key []byte) (Codec, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return aesCodec{Codec: c, name: name, aead: aead}, nil
+}
+
+func (c aesCodec) ID() string {
+	return "aes-" + c.name + "+" + c.Codec.ID()
+}
+
+func (c aesCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := c.Codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.seal(data, []byte(c.ID()))
+}
+
+func (c aesCodec) Unmarshal(data []byte, v interface{}) error {
+	plain, err := c.open(data, []byte(c.ID()))
+	if err != nil {
+		return err
+	}
+
+	return c.Codec.Unmarshal(plain, v)
+}
+
+// MarshalItem implements ItemCodec.
+func (c aesCodec) MarshalItem(service, account string, v interface{}) ([]byte, error) {
+	data, err := marshalItem(c.Codec, service, account, v)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.seal(data, c.itemData(service, account))
+}
+
+// UnmarshalItem implements ItemCodec.
+func (c aesCodec) UnmarshalItem(service, account string, data []byte, v interface{}) error {
+	plain, err := c.open(data, c.itemData(service, account))
+	if err != nil {
+		return err
+	}
+
+	return unmarshalItem(c.Codec, service, account, plain, v)
+}
+
+// itemData returns the associated data binding a ciphertext to the item of
+// service and account.
+func (c aesCodec) itemData(service, account string) []byte {
+	return []byte(c.ID() + "\x00" + norm.NFC.String(service) + "\x00" + norm.NFC.String(account))
+}
+
+func (c aesCodec) seal(data, additional []byte) ([]byte, error) {
+	nonce, err := RandBytes(c.aead.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+
+	return c.aead.Seal(nonce, nonce, data, additional), nil
+}
+
+func (c aesCodec) open(data, additional []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	if len(data) < n {
+		return nil, errors.New("encrypted value too short")
+	}
+
+	plain, err := c.aead.Open(nil, data[:n], data[n:], additional)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+
+	return plain, nil
+}
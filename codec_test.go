@@ -0,0 +1,116 @@
+package keychain_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+type settings struct {
+	Endpoint string
+	Retries  int
+}
+
+func TestCodecs(t *testing.T) {
+	keychainfake.Install(t)
+
+	want := settings{Endpoint: "https://example.com", Retries: 3}
+
+	encrypted, err := keychain.Encrypted(keychain.JSONCodec, "test", make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, codec := range []keychain.Codec{
+		keychain.JSONCodec,
+		keychain.GobCodec,
+		keychain.Compressed(keychain.GobCodec),
+		encrypted,
+	} {
+		keychain.RegisterCodec(codec)
+
+		if err := keychain.SetValue("MyService", "settings", want, codec); err != nil {
+			t.Fatalf("%s: %v", codec.ID(), err)
+		}
+
+		got, err := keychain.GetValue[settings]("MyService", "settings")
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: got %+v, %v", codec.ID(), got, err)
+		}
+	}
+
+	// Values written without a codec are JSON.
+	if err := keychain.SetJSON("MyService", "plain", want); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := keychain.GetValue[settings]("MyService", "plain"); err != nil || got != want {
+		t.Fatalf("got %+v, %v", got, err)
+	}
+
+	other, _ := keychain.Encrypted(keychain.JSONCodec, "unregistered", make([]byte, 16))
+	if err := keychain.SetValue("MyService", "settings", want, other); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := keychain.GetValue[settings]("MyService", "settings"); !errors.Is(err, keychain.ErrUnknownCodec) {
+		t.Fatalf("expected ErrUnknownCodec, got %v", err)
+	}
+}
+
+func TestEncryptedBindsItem(t *testing.T) {
+	keychainfake.Install(t)
+
+	codec, err := keychain.Encrypted(keychain.JSONCodec, "bound", make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keychain.RegisterCodec(codec)
+
+	want := settings{Endpoint: "https://example.com", Retries: 3}
+	if err := keychain.SetValue("MyService", "settings", want, codec); err != nil {
+		t.Fatal(err)
+	}
+
+	// A ciphertext copied onto another item doesn't decrypt.
+	data, err := keychain.GetBytes("MyService", "settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	copied := keychain.NewGenericPassword("MyService", "copied", "", data, "")
+	if err := copied.SetMetadata(keychain.Metadata{keychain.MetadataCodecKey: codec.ID()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.AddItem(copied); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := keychain.GetValue[settings]("MyService", "copied"); err == nil {
+		t.Fatal("expected a copied value to fail to decrypt")
+	}
+
+	// Values encrypted without binding don't decrypt either.
+	unbound, err := codec.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item := keychain.NewGenericPassword("MyService", "unbound", "", unbound, "")
+	if err := item.SetMetadata(keychain.Metadata{keychain.MetadataCodecKey: codec.ID()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.AddItem(item); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := keychain.GetValue[settings]("MyService", "unbound"); err == nil {
+		t.Fatal("expected an unbound value to fail to decrypt")
+	}
+}
@@ -0,0 +1,183 @@
+package keychain
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unicode/utf16"
+)
+
+// CompatConvention is a way generic passwords are stored by other tools,
+// tried in order by CompatLookup.
+type CompatConvention int
+
+const (
+	// CompatServiceAccount is an item with the service and account
+	// attributes, as stored by this package, `security add-generic-password
+	// -s service -a account`, Python keyring, keytar and Swift
+	// KeychainAccess.
+	CompatServiceAccount CompatConvention = iota
+	// CompatGenericAccount is an item of the service with the account in its
+	// generic attribute as UTF-8, as stored by SwiftKeychainWrapper and
+	// Apple's KeychainItemWrapper sample.
+	CompatGenericAccount
+	// CompatAccountOnly is an item with the account and no service, as
+	// stored by KeychainSwift.
+	CompatAccountOnly
+	// CompatLabel is an item with the service as its label and no service,
+	// as stored by `security add-generic-password -l label -a account`.
+	CompatLabel
+)
+
+var compatConventionNames = []string{"service+account", "generic account", "account only", "label"}
+
+func (c CompatConvention) String() string {
+	if c < 0 || int(c) >= len(compatConventionNames) {
+		return fmt.Sprintf("CompatConvention(%d)", int(c))
+	}
+
+	return compatConventionNames[c]
+}
+
+// compatQuery returns the query of the items of service and account stored
+// with convention c, and whether c applies to them.
+func compatQuery(c CompatConvention, service, account string) (Item, bool) {
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetReturnPersistentRef(true)
+
+	switch c {
+	case CompatServiceAccount:
+		query.SetService(service)
+		query.SetAccount(account)
+
+		return query, service != ""
+	case CompatGenericAccount:
+		query.SetService(service)
+		query.SetGeneric([]byte(account))
+
+		return query, service != "" && account != ""
+	case CompatAccountOnly:
+		query.SetAccount(account)
+
+		return query, service == "" && account != ""
+	case CompatLabel:
+		query.SetLabel(service)
+		query.SetAccount(account)
+
+		return query, service != ""
+	}
+
+	return query, false
+}
+
+// CompatLookup returns the generic password for service and account, with
+// its data, looking for it the way other tools on macOS store generic
+// passwords (see CompatConvention), so that items created by them are found.
+// An empty service only matches items without a service (CompatAccountOnly).
+// It also returns the convention of the item. Several items matching a
+// convention return a TooManyResultsError, and no item ErrorItemNotFound.
+func CompatLookup(service, account string) (QueryResult, CompatConvention, error) {
+	for c := CompatServiceAccount; c <= CompatLabel; c++ {
+		query, ok := compatQuery(c, service, account)
+		if !ok {
+			continue
+		}
+
+		results, err := queryAttributes(query)
+		if err != nil {
+			return QueryResult{}, c, err
+		}
+
+		if c == CompatAccountOnly || c == CompatLabel {
+			results = withoutService(results)
+		}
+
+		switch len(results) {
+		case 0:
+			continue
+		case 1:
+		default:
+			return QueryResult{}, c, newTooManyResultsError(results)
+		}
+
+		ref, err := resultRef(results[0])
+		if err != nil {
+			return QueryResult{}, c, err
+		}
+
+		dataQuery := newItem()
+		dataQuery.SetSecClass(SecClassGenericPassword)
+		dataQuery.SetPersistentRef(ref)
+		dataQuery.SetMatchLimit(MatchLimitOne)
+		dataQuery.SetReturnAttributes(true)
+		dataQuery.SetReturnData(true)
+
+		results, err = QueryItem(dataQuery)
+		if err != nil {
+			return QueryResult{}, c, err
+		}
+
+		if len(results) != 1 {
+			return QueryResult{}, c, ErrorItemNotFound
+		}
+
+		return results[0], c, nil
+	}
+
+	return QueryResult{}, CompatServiceAccount, ErrorItemNotFound
+}
+
+// withoutService returns the results without a service.
+func withoutService(results []QueryResult) []QueryResult {
+	var filtered []QueryResult
+
+	for _, r := range results {
+		if r.Service == "" {
+			filtered = append(filtered, r)
+		}
+	}
+
+	return filtered
+}
+
+// CompatGetString returns the data of the generic password found by
+// CompatLookup as a string. Data with a UTF-16 byte order mark is decoded,
+// and a trailing NUL, as written by C programs, is removed.
+func CompatGetString(service, account string) (string, error) {
+	r, _, err := CompatLookup(service, account)
+	if err != nil {
+		return "", err
+	}
+
+	return CompatString(r.Data), nil
+}
+
+// CompatString decodes password data written by another tool, see
+// CompatGetString.
+func CompatString(data []byte) string {
+	if len(data) >= 2 && len(data)%2 == 0 {
+		var order binary.ByteOrder
+
+		switch {
+		case data[0] == 0xfe && data[1] == 0xff:
+			order = binary.BigEndian
+		case data[0] == 0xff && data[1] == 0xfe:
+			order = binary.LittleEndian
+		}
+
+		if order != nil {
+			units := make([]uint16, 0, len(data)/2-1)
+			for i := 2; i < len(data); i += 2 {
+				units = append(units, order.Uint16(data[i:]))
+			}
+
+			data = []byte(string(utf16.Decode(units)))
+		}
+	}
+
+	if n := len(data); n > 0 && data[n-1] == 0 {
+		data = data[:n-1]
+	}
+
+	return string(data)
+}
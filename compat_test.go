@@ -0,0 +1,71 @@
+package keychain_test
+
+import (
+	"errors"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func TestCompatLookup(t *testing.T) {
+	keychainfake.Install(t)
+
+	// Items as stored by other tools.
+	security := keychain.NewGenericPassword("cli", "gabriel", "cli", []byte("fromsecurity\x00"), "")
+
+	swift := keychain.NewGenericPassword("com.example.app", "", "", []byte("fromswift"), "")
+	swift.SetGeneric([]byte("token"))
+
+	keychainSwift := keychain.NewGenericPassword("", "apiKey", "", []byte("fromkeychainswift"), "")
+
+	labelled := keychain.NewGenericPassword("", "gabriel", "Named", []byte{0xff, 0xfe, 'h', 0, 'i', 0}, "")
+
+	for _, item := range []keychain.Item{security, swift, keychainSwift, labelled} {
+		if err := keychain.AddItem(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		service, account string
+		convention       keychain.CompatConvention
+		want             string
+	}{
+		{"cli", "gabriel", keychain.CompatServiceAccount, "fromsecurity"},
+		{"com.example.app", "token", keychain.CompatGenericAccount, "fromswift"},
+		{"", "apiKey", keychain.CompatAccountOnly, "fromkeychainswift"},
+		{"Named", "gabriel", keychain.CompatLabel, "hi"},
+	}
+
+	for _, tt := range tests {
+		_, c, err := keychain.CompatLookup(tt.service, tt.account)
+		if err != nil || c != tt.convention {
+			t.Errorf("%q/%q: unexpected convention %v (%v)", tt.service, tt.account, c, err)
+		}
+
+		s, err := keychain.CompatGetString(tt.service, tt.account)
+		if err != nil || s != tt.want {
+			t.Errorf("%q/%q: got %q (%v), want %q", tt.service, tt.account, s, err, tt.want)
+		}
+	}
+
+	if _, _, err := keychain.CompatLookup("missing", "gabriel"); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound, got %v", err)
+	}
+}
+
+func TestCompatString(t *testing.T) {
+	tests := map[string]string{
+		"plain":                         "plain",
+		"c string\x00":                  "c string",
+		"\xfe\xff\x00h\x00\xe9":         "hé",
+		"\xff\xfeh\x00\xe9\x00\x00\x00": "hé",
+	}
+
+	for data, want := range tests {
+		if got := keychain.CompatString([]byte(data)); got != want {
+			t.Errorf("CompatString(%q) = %q, want %q", data, got, want)
+		}
+	}
+}
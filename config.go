@@ -0,0 +1,199 @@
+package keychain
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Config is the configuration of this package: the backend, the defaults of
+// new items, the handling of deprecated accessibilities, change sequences,
+// usage tracking, the transformation of data, the prompt cache, the caching
+// of QueryPage and the polling of WatchItem. SetBackend, SetDefaults,
+// SetAccessiblePolicy, SetChangeSequences, SetUsageTracking, SetPipeline,
+// SetPromptCache, SetQueryPageCacheTTL and SetWatchPollInterval each change
+// one setting of the package configuration. A Config is a value: changes
+// replace the package configuration as a whole, so operations in progress
+// keep the configuration they started with, and a library can use its own
+// with WithConfig.
+type Config struct {
+	// Backend is the backend, the platform default if nil.
+	Backend Backend
+	// Defaults are the attributes of new items, see SetDefaults.
+	Defaults Defaults
+	// AccessiblePolicy is the handling of deprecated accessibilities, see
+	// SetAccessiblePolicy.
+	AccessiblePolicy AccessiblePolicy
+	// ChangeSequences counts the changes of each service, see
+	// SetChangeSequences.
+	ChangeSequences bool
+	// UsageTracking records the reads of generic passwords, see
+	// SetUsageTracking.
+	UsageTracking bool
+	// Pipeline transforms the data written with SetBytes, see SetPipeline.
+	Pipeline Pipeline
+	// PromptCacheWindow is how long the data of reads with an access prompt
+	// is cached, see SetPromptCache. Only the package configuration uses it.
+	PromptCacheWindow time.Duration
+	// QueryPageCacheTTL is how long QueryPage reuses the item list of a query,
+	// 30 seconds if zero and not at all if negative, see
+	// SetQueryPageCacheTTL.
+	QueryPageCacheTTL time.Duration
+	// WatchPollInterval is how often watched items are checked for changes,
+	// 2 seconds if zero, see SetWatchPollInterval. Only the package
+	// configuration uses it.
+	WatchPollInterval time.Duration
+}
+
+// state is a configuration in use.
+type state struct {
+	cfg Config
+	// unique is cfg.Backend with the uniqueness layer, if it needs it.
+	unique Backend
+	// backend is unique with the drain.
+	backend Backend
+	// drain tracks the operations in progress, see Shutdown. It is shared by
+	// the package configurations, and nil for the state of an operation in
+	// progress, see begin.
+	drain *drain
+	// shared is set for the package configuration, whose reads use the
+	// prompt cache.
+	shared bool
+	// pages are the item lists cached by QueryPage.
+	pages *pageCache
+}
+
+func newState(cfg Config, shared bool) *state {
+	if cfg.Backend == nil {
+		cfg.Backend = defaultBackend
+	}
+
+	d := packageDrain
+	if !shared {
+		d = &drain{}
+	}
+
+	unique := withUniqueKeys(cfg.Backend)
+
+	return &state{
+		cfg:     cfg,
+		unique:  unique,
+		backend: drainBackend{unique, d},
+		drain:   d,
+		shared:  shared,
+		pages:   &pageCache{},
+	}
+}
+
+var (
+	// configMu serializes changes to the package configuration.
+	configMu      sync.Mutex
+	packageConfig atomic.Pointer[state]
+	initialConfig = newState(Config{}, true)
+)
+
+// cur returns the package configuration.
+func cur() *state {
+	if s := packageConfig.Load(); s != nil {
+		return s
+	}
+
+	return initialConfig
+}
+
+// updateConfig replaces the package configuration with a copy changed by
+// change, and returns the previous configuration.
+func updateConfig(change func(cfg *Config)) Config {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	prev := cur().cfg
+	next := prev
+	change(&next)
+	packageConfig.Store(newState(next, true))
+
+	if next.PromptCacheWindow != prev.PromptCacheWindow {
+		applyPromptCacheWindow(next.PromptCacheWindow)
+	}
+
+	return prev
+}
+
+// GetConfig returns the package configuration.
+func GetConfig() Config {
+	return cur().cfg
+}
+
+// SetConfig replaces the package configuration and returns the previous one.
+func SetConfig(cfg Config) Config {
+	return updateConfig(func(c *Config) { *c = cfg })
+}
+
+// Configured is a keychain handle using its own Config rather than the
+// package configuration.
+type Configured struct {
+	s *state
+}
+
+// WithConfig returns a keychain handle using cfg, e.g. for a library embedded
+// in a program which configures this package differently. Change sequences
+// and usage records of the changes and reads through the handle are written
+// with its backend. Reads through the handle don't use the prompt cache, and
+// functions other than its methods use the package configuration.
+func WithConfig(cfg Config) *Configured {
+	return &Configured{s: newState(cfg, false)}
+}
+
+// Config returns the configuration of the handle.
+func (c *Configured) Config() Config {
+	return c.s.cfg
+}
+
+// NewItem is a new keychain item with the defaults of the handle.
+func (c *Configured) NewItem() Item {
+	return c.s.newItem()
+}
+
+// NewGenericPassword creates a generic password item with the defaults of the
+// handle, see the package function.
+func (c *Configured) NewGenericPassword(service, account, label string, data []byte, accessGroup string) Item {
+	return c.s.newGenericPassword(service, account, label, data, accessGroup)
+}
+
+// AddItem adds item, see the package function.
+func (c *Configured) AddItem(item Item) error {
+	return c.s.addItem(item)
+}
+
+// QueryItem returns the items matching query, see the package function.
+func (c *Configured) QueryItem(query Item) ([]QueryResult, error) {
+	return c.s.queryItem(query)
+}
+
+// ItemExists returns whether an item matching query exists, see the package
+// function.
+func (c *Configured) ItemExists(query Item) (bool, error) {
+	return c.s.itemExists(query)
+}
+
+// UpdateItem updates the items matching query, see the package function.
+func (c *Configured) UpdateItem(query Item, update Item) error {
+	return c.s.updateItem(query, update)
+}
+
+// DeleteItem deletes the items matching item, see the package function.
+func (c *Configured) DeleteItem(item Item) error {
+	return c.s.deleteItem(item)
+}
+
+// GetBytes returns the data of the generic password for service and account,
+// see the package function.
+func (c *Configured) GetBytes(service, account string) ([]byte, error) {
+	return c.s.getBytes(service, account)
+}
+
+// SetBytes stores data in the generic password for service and account, see
+// the package function.
+func (c *Configured) SetBytes(service, account string, data []byte) error {
+	return c.s.setBytes(service, account, data)
+}
@@ -0,0 +1,129 @@
+package keychain_test
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func TestWithConfig(t *testing.T) {
+	shared := keychainfake.Install(t)
+	own := keychainfake.New()
+
+	c := keychain.WithConfig(keychain.Config{
+		Backend:         own,
+		Defaults:        keychain.Defaults{AccessGroup: "A123456789.group.com.mycorp"},
+		ChangeSequences: true,
+	})
+
+	if err := c.SetBytes("MyService", "gabriel", []byte("toomanysecrets")); err != nil {
+		t.Fatal(err)
+	}
+
+	if shared.Len() != 0 {
+		t.Fatalf("expected no item in the package backend, got %d", shared.Len())
+	}
+
+	if _, err := keychain.GetBytes("MyService", "gabriel"); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound with the package configuration, got %v", err)
+	}
+
+	query := c.NewItem()
+	query.SetSecClass(keychain.SecClassGenericPassword)
+	query.SetService("MyService")
+	query.SetMatchLimit(keychain.MatchLimitOne)
+	query.SetReturnAttributes(true)
+
+	results, err := c.QueryItem(query)
+	if err != nil || len(results) != 1 || results[0].AccessGroup != "A123456789.group.com.mycorp" {
+		t.Fatalf("expected the item with the default access group, got %+v (%v)", results, err)
+	}
+
+	if _, err := c.GetBytes(keychain.SequenceService, "MyService"); err != nil {
+		t.Fatalf("expected a change sequence in the handle backend: %v", err)
+	}
+}
+
+func TestSetConfigConcurrently(t *testing.T) {
+	keychainfake.Install(t)
+
+	prev := keychain.GetConfig()
+	t.Cleanup(func() { keychain.SetConfig(prev) })
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			keychain.SetDefaults(keychain.Defaults{AccessGroup: "group"})
+			keychain.SetChangeSequences(true)
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			_ = keychain.SetString("MyService", "gabriel", "toomanysecrets")
+		}()
+	}
+
+	wg.Wait()
+
+	cfg := keychain.GetConfig()
+	if cfg.Defaults.AccessGroup != "group" || !cfg.ChangeSequences {
+		t.Fatalf("unexpected configuration %+v", cfg)
+	}
+}
+
+func TestConfiguredQueryPage(t *testing.T) {
+	keychainfake.Install(t)
+
+	c := keychain.WithConfig(keychain.Config{Backend: keychainfake.New()})
+
+	for _, account := range []string{"a", "b", "c"} {
+		if err := keychain.SetBytes("MyService", account, []byte("secret-"+account)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.SetBytes("MyService", "x", []byte("secret-x")); err != nil {
+		t.Fatal(err)
+	}
+
+	query := keychain.NewItem()
+	query.SetSecClass(keychain.SecClassGenericPassword)
+	query.SetService("MyService")
+
+	defer keychain.ClearQueryPageCache()
+
+	// The limit is clamped without overflowing.
+	page, total, err := keychain.QueryPage(query, 1, math.MaxInt)
+	if err != nil || total != 3 || len(page) != 2 || string(page[0].Data) != "secret-b" {
+		t.Fatalf("unexpected page %+v (total %d, %v)", page, total, err)
+	}
+
+	// The handle doesn't reuse the item list of the package configuration.
+	page, total, err = c.QueryPage(query, 0, 10)
+	if err != nil || total != 1 || len(page) != 1 || page[0].Account != "x" {
+		t.Fatalf("unexpected page of the handle %+v (total %d, %v)", page, total, err)
+	}
+
+	// A negative TTL lists the items for each page.
+	uncached := keychain.WithConfig(keychain.Config{Backend: keychainfake.New(), QueryPageCacheTTL: -1})
+
+	for i, account := range []string{"a", "b"} {
+		if err := uncached.SetBytes("MyService", account, []byte("secret-"+account)); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, total, err := uncached.QueryPage(query, 0, 10); err != nil || total != i+1 {
+			t.Fatalf("expected %d items, got %d (%v)", i+1, total, err)
+		}
+	}
+}
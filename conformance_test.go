@@ -0,0 +1,17 @@
+//go:build darwin && !ios
+// +build darwin,!ios
+
+package keychain_test
+
+import (
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/backendtest"
+)
+
+func TestSecurityConformance(t *testing.T) {
+	// SetBackend(nil) installs and returns the Security framework backend.
+	keychain.SetBackend(nil)
+	backendtest.RunConformance(t, keychain.SetBackend(nil))
+}
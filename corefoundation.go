@@ -8,6 +8,7 @@ package keychain
 #cgo LDFLAGS: -framework CoreFoundation
 
 #include <CoreFoundation/CoreFoundation.h>
+#include <stdlib.h>
 
 // Can't cast a *uintptr to *unsafe.Pointer in Go, and casting
 // C.CFTypeRef to unsafe.Pointer is unsafe in Go, so have shim functions to
@@ -32,12 +33,19 @@ import "C"
 import (
 	"errors"
 	"fmt"
-	"math"
 	"reflect"
 	"unicode/utf8"
 	"unsafe"
 )
 
+// cMemoryThreshold is the size from which values are copied into C memory
+// (with C.CBytes or C.CString) that Core Foundation takes ownership of with
+// its NoCopy constructors, and frees with kCFAllocatorMalloc on release.
+// Smaller values are copied by Core Foundation from Go memory. Either way the
+// value is copied exactly once; "NoCopy" only means that Core Foundation
+// doesn't copy the C memory again.
+const cMemoryThreshold = 64 << 10
+
 // Release releases memory pointed to by a CFTypeRef.
 func Release(ref C.CFTypeRef) {
 	C.CFRelease(ref)
@@ -46,8 +54,18 @@ func Release(ref C.CFTypeRef) {
 // BytesToCFData will return a CFDataRef and if non-nil, must be released with
 // Release(ref).
 func BytesToCFData(b []byte) (C.CFDataRef, error) {
-	if uint64(len(b)) > math.MaxUint32 {
-		return 0, errors.New("data is too large")
+	if len(b) >= cMemoryThreshold {
+		// The one copy of b, owned by cfData from here on.
+		p := C.CBytes(b)
+
+		cfData := C.CFDataCreateWithBytesNoCopy(C.kCFAllocatorDefault, (*C.UInt8)(p), C.CFIndex(len(b)), C.kCFAllocatorMalloc) // nolint: nlreturn
+		if cfData == 0 {
+			C.free(p)
+
+			return 0, fmt.Errorf("CFDataCreateWithBytesNoCopy failed")
+		}
+
+		return cfData, nil
 	}
 
 	var p *C.UInt8
@@ -63,9 +81,18 @@ func BytesToCFData(b []byte) (C.CFDataRef, error) {
 	return cfData, nil
 }
 
-// CFDataToBytes converts CFData to bytes.
+// CFDataToBytes converts CFData to bytes. Empty data is returned as an empty,
+// non-nil slice.
 func CFDataToBytes(cfData C.CFDataRef) ([]byte, error) {
-	return C.GoBytes(unsafe.Pointer(C.CFDataGetBytePtr(cfData)), C.int(C.CFDataGetLength(cfData))), nil // nolint: nlreturn
+	length := C.CFDataGetLength(cfData) // nolint: nlreturn
+	if length == 0 {
+		return []byte{}, nil
+	}
+
+	b := make([]byte, length)
+	C.CFDataGetBytes(cfData, C.CFRange{0, length}, (*C.UInt8)(&b[0]))
+
+	return b, nil
 }
 
 // MapToCFDictionary will return a CFDictionaryRef and if non-nil, must be
@@ -125,28 +152,39 @@ func StringToCFString(s string) (C.CFStringRef, error) {
 		return 0, errors.New("invalid UTF-8 string")
 	}
 
-	if uint64(len(s)) > math.MaxUint32 {
-		return 0, errors.New("string is too large")
+	if len(s) >= cMemoryThreshold {
+		// The one copy of s, owned by cfStr from here on.
+		p := C.CString(s)
+
+		cfStr := C.CFStringCreateWithBytesNoCopy(C.kCFAllocatorDefault, (*C.UInt8)(unsafe.Pointer(p)), C.CFIndex(len(s)), C.kCFStringEncodingUTF8, C.false, C.kCFAllocatorMalloc) // nolint: nlreturn
+		if cfStr == 0 {
+			C.free(unsafe.Pointer(p))
+
+			return 0, fmt.Errorf("CFStringCreateWithBytesNoCopy failed")
+		}
+
+		return cfStr, nil
 	}
 
-	bytes := []byte(s)
 	var p *C.UInt8
+	if len(s) > 0 {
+		// Core Foundation copies the bytes and doesn't modify them.
+		p = (*C.UInt8)(unsafe.Pointer(unsafe.StringData(s)))
+	}
 
-	if len(bytes) > 0 {
-		p = (*C.UInt8)(&bytes[0])
+	cfStr := C.CFStringCreateWithBytes(C.kCFAllocatorDefault, p, C.CFIndex(len(s)), C.kCFStringEncodingUTF8, C.false) // nolint: nlreturn
+	if cfStr == 0 {
+		return 0, fmt.Errorf("CFStringCreateWithBytes failed")
 	}
 
-	return C.CFStringCreateWithBytes(C.kCFAllocatorDefault, p, C.CFIndex(len(s)), C.kCFStringEncodingUTF8, C.false), nil // nolint: nlreturn
+	return cfStr, nil
 }
 
-// CFStringToString converts a CFStringRef to a string.
+// CFStringToString converts a CFStringRef to a string. The bytes are always
+// copied out with CFStringGetBytes: the C string pointer CFStringGetCStringPtr
+// may return would truncate strings containing NUL characters.
 func CFStringToString(s C.CFStringRef) string {
-	p := C.CFStringGetCStringPtr(s, C.kCFStringEncodingUTF8) // nolint: nlreturn
-	if p != nil {
-		return C.GoString(p)
-	}
-
-	length := C.CFStringGetLength(s)
+	length := C.CFStringGetLength(s) // nolint: nlreturn
 	if length == 0 {
 		return ""
 	}
@@ -165,10 +203,18 @@ func CFStringToString(s C.CFStringRef) string {
 	return string(buf[:usedBufLen])
 }
 
+func releaseCFString(s C.CFStringRef) {
+	Release(C.CFTypeRef(s))
+}
+
+func releaseCFData(d C.CFDataRef) {
+	Release(C.CFTypeRef(d))
+}
+
 // ArrayToCFArray will return a CFArrayRef and if non-nil, must be released with
 // Release(ref).
 func ArrayToCFArray(a []C.CFTypeRef) C.CFArrayRef {
-	values := make([]C.uintptr_t, 0, len(a))
+	values := make([]C.uintptr_t, len(a))
 
 	for i := range a {
 		if a[i] == 0 {
@@ -0,0 +1,70 @@
+//go:build darwin && !ios
+// +build darwin,!ios
+
+package keychain
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestStringToCFStringRoundTrip(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"a",
+		"テスト ✓ 😀",
+		"nul\x00inside",
+		strings.Repeat("x", cMemoryThreshold-1),
+		strings.Repeat("é", cMemoryThreshold),
+		strings.Repeat("0123456789abcdef", 1<<20),
+	} {
+		cfStr, err := StringToCFString(s)
+		if err != nil {
+			t.Fatalf("%d bytes: %v", len(s), err)
+		}
+
+		got := CFStringToString(cfStr)
+		releaseCFString(cfStr)
+
+		if got != s {
+			t.Fatalf("%d bytes: round trip returned %d bytes", len(s), len(got))
+		}
+	}
+}
+
+func TestStringToCFStringInvalidUTF8(t *testing.T) {
+	for _, s := range []string{"\xff", "abc\xc3", strings.Repeat("a", cMemoryThreshold) + "\xed\xa0\x80"} {
+		if _, err := StringToCFString(s); err == nil {
+			t.Fatalf("expected an error for invalid UTF-8 %.16q", s)
+		}
+	}
+}
+
+func TestBytesToCFDataRoundTrip(t *testing.T) {
+	for _, b := range [][]byte{
+		nil,
+		{},
+		{0},
+		[]byte("\xff\xfe invalid UTF-8 is fine in data"),
+		bytes.Repeat([]byte{1, 2, 3}, cMemoryThreshold/3),
+		bytes.Repeat([]byte{0xab}, cMemoryThreshold),
+		bytes.Repeat([]byte("0123456789abcdef"), 1<<20),
+	} {
+		cfData, err := BytesToCFData(b)
+		if err != nil {
+			t.Fatalf("%d bytes: %v", len(b), err)
+		}
+
+		got, err := CFDataToBytes(cfData)
+		releaseCFData(cfData)
+
+		if err != nil {
+			t.Fatalf("%d bytes: %v", len(b), err)
+		}
+
+		if got == nil || !bytes.Equal(got, b) {
+			t.Fatalf("%d bytes: round trip returned %d bytes (nil: %v)", len(b), len(got), got == nil)
+		}
+	}
+}
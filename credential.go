@@ -0,0 +1,239 @@
+package keychain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Credential is a login for a URL: a username and password, with notes and
+// custom fields, e.g. a one-time password seed or security answers.
+// SaveCredential and LoadCredential store it in an internet password item with
+// a canonical layout, so that applications using this package read each
+// other's credentials:
+//
+//   - the host, scheme, port (the default of the scheme unless explicit) and
+//     path of URL are the server, protocol, port and path attributes;
+//   - Username is the account attribute and Password the data;
+//   - Notes and Fields are encoded as a JSON object, with the keys "notes" and
+//     "fields", in the comment attribute.
+type Credential struct {
+	Username string
+	Password string
+	URL      string
+	Notes    string
+	Fields   map[string]string
+}
+
+// credentialExtras is the encoding of the notes and fields of a Credential in
+// the comment attribute.
+type credentialExtras struct {
+	Notes  string            `json:"notes,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// schemeProtocols are the protocol attributes of URL schemes.
+var schemeProtocols = map[string]string{
+	"afp":    "afp ",
+	"ftp":    "ftp ",
+	"ftps":   "ftps",
+	"http":   "http",
+	"https":  "htps",
+	"imap":   "imap",
+	"imaps":  "imps",
+	"ldap":   "ldap",
+	"ldaps":  "ldps",
+	"pop3":   "pop3",
+	"pop3s":  "pops",
+	"smb":    "smb ",
+	"smtp":   "smtp",
+	"ssh":    "ssh ",
+	"telnet": "teln",
+}
+
+// protocolSchemes are the URL schemes of protocol attributes.
+var protocolSchemes = func() map[string]string {
+	m := make(map[string]string, len(schemeProtocols))
+	for scheme, protocol := range schemeProtocols {
+		m[protocol] = scheme
+	}
+
+	return m
+}()
+
+// internetLocation is the location of an internet password: its server,
+// protocol, port and path attributes.
+type internetLocation struct {
+	server   string
+	protocol string
+	port     int32
+	path     string
+}
+
+// parseLocation returns the location of an internet password for rawURL.
+func parseLocation(rawURL string) (internetLocation, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return internetLocation{}, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	return locationOf(u)
+}
+
+// locationOf returns the location of an internet password for u, with the
+// default port of its scheme unless it has an explicit port.
+func locationOf(u *url.URL) (internetLocation, error) {
+	protocol, ok := schemeProtocols[strings.ToLower(u.Scheme)]
+	if !ok {
+		return internetLocation{}, fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+
+	if u.Hostname() == "" {
+		return internetLocation{}, fmt.Errorf("URL %q has no host", u.Redacted())
+	}
+
+	loc := internetLocation{server: strings.ToLower(u.Hostname()), protocol: protocol, path: u.Path}
+	if loc.path == "/" {
+		loc.path = ""
+	}
+
+	if p := u.Port(); p != "" {
+		port, err := strconv.ParseUint(p, 10, 16)
+		if err != nil {
+			return internetLocation{}, fmt.Errorf("invalid port %q", p)
+		}
+
+		loc.port = int32(port)
+	}
+
+	if loc.port == 0 {
+		loc.port = defaultPorts[protocol]
+	}
+
+	return loc, nil
+}
+
+// query returns a query for the internet passwords at the location.
+func (loc internetLocation) query() Item {
+	query := NewItem()
+	query.SetSecClass(SecClassInternetPassword)
+	query.SetServer(loc.server)
+	query.SetProtocol(loc.protocol)
+	query.SetPath(loc.path)
+
+	if loc.port != 0 {
+		query.SetPort(loc.port)
+	}
+
+	return query
+}
+
+// resultURL returns the URL of an internet password result.
+func resultURL(r QueryResult) string {
+	u := url.URL{Scheme: protocolSchemes[r.Protocol], Host: r.Server, Path: r.Path}
+	if u.Scheme == "" {
+		u.Scheme = strings.TrimSpace(r.Protocol)
+	}
+
+	if r.Port != 0 && r.Port != defaultPorts[r.Protocol] {
+		u.Host += ":" + strconv.Itoa(int(r.Port))
+	}
+
+	return u.String()
+}
+
+// SaveCredential stores c in the internet password for its URL and username,
+// adding the item if it doesn't exist.
+func SaveCredential(c Credential) error {
+	loc, err := parseLocation(c.URL)
+	if err != nil {
+		return err
+	}
+
+	var comment string
+
+	if c.Notes != "" || len(c.Fields) > 0 {
+		b, err := json.Marshal(credentialExtras{Notes: c.Notes, Fields: c.Fields})
+		if err != nil {
+			return fmt.Errorf("failed to encode credential fields: %w", err)
+		}
+
+		comment = string(b)
+	}
+
+	// An empty username is an account, not a wildcard.
+	query := loc.query()
+	query.attr[AccountKey] = c.Username
+
+	update := newItem()
+	update.SetData([]byte(c.Password))
+	update.attr[CommentKey] = comment
+
+	err = UpdateItem(query, update)
+	if errors.Is(err, ErrorItemNotFound) {
+		item := loc.query()
+		item.attr[AccountKey] = c.Username
+		item.SetLabel(loc.server)
+		item.SetComment(comment)
+		item.SetData([]byte(c.Password))
+
+		err = AddItem(item)
+	}
+
+	return err
+}
+
+// LoadCredential returns the credential stored for rawURL, and username if it
+// isn't empty. If several credentials match, a TooManyResultsError lists them,
+// and if none does, ErrorItemNotFound is returned. A comment which wasn't
+// written by SaveCredential is returned as the notes, without the tags of the
+// item (see AddTags).
+func LoadCredential(rawURL, username string) (Credential, error) {
+	loc, err := parseLocation(rawURL)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	query := loc.query()
+	query.SetAccount(username)
+	query.SetMatchLimit(MatchLimitAll)
+	query.SetReturnAttributes(true)
+	query.SetReturnData(true)
+
+	results, err := QueryItem(query)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	switch {
+	case len(results) == 0:
+		return Credential{}, ErrorItemNotFound
+	case len(results) > 1:
+		return Credential{}, newTooManyResultsError(results)
+	}
+
+	return credentialFromResult(results[0]), nil
+}
+
+// credentialFromResult decodes a credential from an internet password result.
+func credentialFromResult(r QueryResult) Credential {
+	c := Credential{Username: r.Account, Password: string(r.Data), URL: resultURL(r)}
+
+	var extras credentialExtras
+
+	comment := r.CommentText()
+
+	dec := json.NewDecoder(strings.NewReader(comment))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&extras); err == nil && !dec.More() {
+		c.Notes, c.Fields = extras.Notes, extras.Fields
+	} else {
+		c.Notes = comment
+	}
+
+	return c
+}
@@ -0,0 +1,75 @@
+package keychain_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func TestCredential(t *testing.T) {
+	keychainfake.Install(t)
+
+	c := keychain.Credential{
+		Username: "alice",
+		Password: "toomanysecrets",
+		URL:      "https://Example.com:8443/login",
+		Notes:    "work account",
+		Fields:   map[string]string{"totp": "JBSWY3DPEHPK3PXP"},
+	}
+
+	if err := keychain.SaveCredential(c); err != nil {
+		t.Fatal(err)
+	}
+
+	query := keychain.NewItem()
+	query.SetServer("example.com")
+
+	items, err := keychain.QueryInternetPasswordItems(query)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("expected 1 internet password, got %+v (%v)", items, err)
+	}
+
+	if it := items[0]; it.Protocol != "htps" || it.Port != 8443 || it.Path != "/login" || it.Account != "alice" ||
+		it.Comment != `{"notes":"work account","fields":{"totp":"JBSWY3DPEHPK3PXP"}}` {
+		t.Fatalf("unexpected layout %+v", it)
+	}
+
+	got, err := keychain.LoadCredential("https://example.com:8443/login", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.URL = "https://example.com:8443/login"
+	if !reflect.DeepEqual(got, c) {
+		t.Fatalf("got %+v, want %+v", got, c)
+	}
+
+	c.Password, c.Notes, c.Fields = "changed", "", nil
+	if err := keychain.SaveCredential(c); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = keychain.LoadCredential(c.URL, "alice")
+	if err != nil || !reflect.DeepEqual(got, c) {
+		t.Fatalf("got %+v (%v), want %+v", got, err, c)
+	}
+
+	if err := keychain.SaveCredential(keychain.Credential{Username: "bob", URL: c.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := keychain.LoadCredential(c.URL, ""); !errors.Is(err, keychain.ErrTooManyResults) {
+		t.Fatalf("expected ErrTooManyResults, got %v", err)
+	}
+
+	if _, err := keychain.LoadCredential("https://example.org", ""); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound, got %v", err)
+	}
+
+	if err := keychain.SaveCredential(keychain.Credential{URL: "gopher://example.com"}); err == nil {
+		t.Fatal("expected an error for an unsupported scheme")
+	}
+}
@@ -0,0 +1,43 @@
+package keychain
+
+// Defaults are attributes applied to every Item created by NewItem and the
+// convenience functions, so an application can set its keychain policy in one
+// place instead of on each item.
+type Defaults struct {
+	// Accessible is the accessibility of new items, e.g.
+	// AccessibleWhenUnlockedThisDeviceOnly.
+	Accessible Accessible
+	// Synchronizable is the synchronizable attribute of new items.
+	Synchronizable Synchronizable
+	// AccessGroup is the access group of new items.
+	AccessGroup string
+	// UseDataProtection targets the data protection keychain on macOS, see
+	// Item.SetUseDataProtectionKeychain.
+	UseDataProtection bool
+}
+
+// SetDefaults sets the attributes applied by NewItem and returns the previous
+// defaults. The zero Defaults applies none, which is the initial state.
+//
+// The defaults apply to queries as well: a query created with NewItem only
+// matches items with the default accessibility, synchronizable attribute and
+// access group. Override them on the item to match others, e.g. with
+// SetAccessible(AccessibleDefault) or SetSynchronizable(SynchronizableAny).
+// The convenience functions taking an access group use the default access
+// group when passed "".
+func SetDefaults(d Defaults) Defaults {
+	return updateConfig(func(cfg *Config) { cfg.Defaults = d }).Defaults
+}
+
+// GetDefaults returns the attributes applied by NewItem.
+func GetDefaults() Defaults {
+	return cur().cfg.Defaults
+}
+
+// apply sets the defaults on item.
+func (d Defaults) apply(item *Item) {
+	item.SetAccessible(d.Accessible)
+	item.SetSynchronizable(d.Synchronizable)
+	item.SetAccessGroup(d.AccessGroup)
+	item.SetUseDataProtectionKeychain(d.UseDataProtection)
+}
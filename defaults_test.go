@@ -0,0 +1,56 @@
+package keychain
+
+import (
+	"testing"
+)
+
+func TestDefaults(t *testing.T) {
+	prev := SetDefaults(Defaults{
+		Accessible:        AccessibleWhenUnlockedThisDeviceOnly,
+		Synchronizable:    SynchronizableNo,
+		AccessGroup:       "A123456789.group.com.mycorp",
+		UseDataProtection: true,
+	})
+	defer SetDefaults(prev)
+
+	existing := map[string]interface{}{ServiceKey: "MyService", AccountKey: "gabriel"}
+	f := useFakeBackend(t, fakeResponse{result: existing}, fakeResponse{}, fakeResponse{err: ErrorItemNotFound}, fakeResponse{})
+
+	// The item is looked up, then updated, or added once it's missing.
+	for range 2 {
+		if err := SetString("MyService", "gabriel", "toomanysecrets"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	update, add := f.calls[1].attrs, f.calls[3].attrs
+
+	if len(update) != 1 || update[DataKey] == nil {
+		t.Fatalf("defaults applied to update: %v", update)
+	}
+
+	if add[AccessibleKey] != "aku" || add[SynchronizableKey] != false ||
+		add[AccessGroupKey] != "A123456789.group.com.mycorp" || add[UseDataProtectionKeychainKey] != true {
+		t.Fatalf("defaults not applied: %v", add)
+	}
+
+	// Overriding.
+	item := NewGenericPassword("MyService", "gabriel", "", nil, "other.group")
+	item.SetAccessible(AccessibleAfterFirstUnlock)
+	item.SetSynchronizable(SynchronizableDefault)
+
+	attrs, err := EncodeAttributes(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := attrs[SynchronizableKey]; ok || attrs[AccessibleKey] != "ck" || attrs[AccessGroupKey] != "other.group" {
+		t.Fatalf("defaults not overridden: %v", attrs)
+	}
+
+	SetDefaults(Defaults{})
+
+	if attrs, _ := EncodeAttributes(NewItem()); len(attrs) != 0 {
+		t.Fatalf("expected no defaults, got %v", attrs)
+	}
+}
@@ -0,0 +1,77 @@
+package keychain
+
+import "fmt"
+
+// DeprecatedAccessibleMode is what happens to items written with an
+// accessibility that is deprecated on the running OS: AccessibleAlways and
+// AccessibleAccessibleAlwaysThisDeviceOnly, deprecated since macOS 10.14 and
+// iOS 12.
+type DeprecatedAccessibleMode int
+
+const (
+	// DeprecatedAccessibleUpgrade writes AccessibleAfterFirstUnlock (or
+	// AccessibleAfterFirstUnlockThisDeviceOnly) instead, which keeps items
+	// readable in the background after the first unlock. It is the default.
+	DeprecatedAccessibleUpgrade DeprecatedAccessibleMode = iota
+	// DeprecatedAccessibleWarn writes the deprecated accessibility and reports
+	// it to AccessiblePolicy.Warn.
+	DeprecatedAccessibleWarn
+	// DeprecatedAccessibleAllow writes the deprecated accessibility, for
+	// compatibility with apps that still read such items on a locked device.
+	DeprecatedAccessibleAllow
+)
+
+// AccessiblePolicy configures the handling of deprecated accessibilities, see
+// SetAccessiblePolicy.
+type AccessiblePolicy struct {
+	Mode DeprecatedAccessibleMode
+	// Warn, if set, is called when an item is written with a deprecated
+	// accessibility, with a message describing what was done.
+	Warn func(msg string)
+}
+
+// upgradedAccessible maps the encoded deprecated accessibilities to their
+// replacements.
+var upgradedAccessible = map[string]string{
+	accessibleValues[AccessibleAlways]:                         accessibleValues[AccessibleAfterFirstUnlock],
+	accessibleValues[AccessibleAccessibleAlwaysThisDeviceOnly]: accessibleValues[AccessibleAfterFirstUnlockThisDeviceOnly],
+}
+
+// SetAccessiblePolicy sets the handling of deprecated accessibilities by
+// AddItem and UpdateItem and returns the previous policy. The policy only
+// applies where the accessibility is deprecated: on macOS 10.14, iOS 12 and
+// later, and with backends other than the Security framework. Queries are
+// never changed, so items written with a deprecated accessibility can still be
+// found (and migrated, see AccessibleMigration).
+func SetAccessiblePolicy(p AccessiblePolicy) AccessiblePolicy {
+	return updateConfig(func(cfg *Config) { cfg.AccessiblePolicy = p }).AccessiblePolicy
+}
+
+// applyAccessiblePolicy applies the accessible policy of s to the encoded
+// attributes of an item being written.
+func (s *state) applyAccessiblePolicy(attrs map[string]interface{}) {
+	accessible, _ := attrs[AccessibleKey].(string)
+
+	upgraded, ok := upgradedAccessible[accessible]
+	if !ok || !alwaysDeprecated(s.backend) {
+		return
+	}
+
+	p := s.cfg.AccessiblePolicy
+
+	var msg string
+
+	switch p.Mode {
+	case DeprecatedAccessibleUpgrade:
+		attrs[AccessibleKey] = upgraded
+		msg = fmt.Sprintf("deprecated accessibility %q replaced by %q", accessible, upgraded)
+	case DeprecatedAccessibleWarn:
+		msg = fmt.Sprintf("deprecated accessibility %q used", accessible)
+	default:
+		return
+	}
+
+	if p.Warn != nil {
+		p.Warn(msg)
+	}
+}
@@ -0,0 +1,54 @@
+package keychain
+
+import (
+	"testing"
+)
+
+func TestAccessiblePolicy(t *testing.T) {
+	if Strict() {
+		t.Skip("AccessibleAlways is forbidden in strict builds")
+	}
+
+	var warnings []string
+
+	warn := func(msg string) { warnings = append(warnings, msg) }
+	item := NewGenericPassword("MyService", "gabriel", "", []byte("toomanysecrets"), "")
+	item.SetAccessible(AccessibleAccessibleAlwaysThisDeviceOnly)
+
+	for _, tc := range []struct {
+		mode DeprecatedAccessibleMode
+		want string
+	}{
+		{DeprecatedAccessibleUpgrade, "cku"},
+		{DeprecatedAccessibleWarn, "dku"},
+		{DeprecatedAccessibleAllow, "dku"},
+	} {
+		prev := SetAccessiblePolicy(AccessiblePolicy{Mode: tc.mode, Warn: warn})
+		f := useFakeBackend(t)
+
+		if err := AddItem(item); err != nil {
+			t.Fatal(err)
+		}
+
+		SetAccessiblePolicy(prev)
+
+		if got := f.calls[0].attrs[AccessibleKey]; got != tc.want {
+			t.Fatalf("mode %d: expected accessibility %s, got %v", tc.mode, tc.want, got)
+		}
+	}
+
+	if len(warnings) != 2 {
+		t.Fatalf("expected warnings for upgrade and warn, got %q", warnings)
+	}
+
+	// Queries are not changed.
+	f := useFakeBackend(t, fakeResponse{err: ErrorItemNotFound})
+
+	if _, err := QueryItem(item); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := f.calls[0].query[AccessibleKey]; got != "dku" {
+		t.Fatalf("query changed to %v", got)
+	}
+}
@@ -0,0 +1,190 @@
+package keychain
+
+import (
+	"sort"
+	"time"
+)
+
+// accessibleNames are the names of accessibilities in descriptions.
+var accessibleNames = map[Accessible]string{
+	AccessibleWhenUnlocked:                   "WhenUnlocked",
+	AccessibleAfterFirstUnlock:               "AfterFirstUnlock",
+	AccessibleAlways:                         "Always",
+	AccessibleWhenPasscodeSetThisDeviceOnly:  "WhenPasscodeSetThisDeviceOnly",
+	AccessibleWhenUnlockedThisDeviceOnly:     "WhenUnlockedThisDeviceOnly",
+	AccessibleAfterFirstUnlockThisDeviceOnly: "AfterFirstUnlockThisDeviceOnly",
+	AccessibleAccessibleAlwaysThisDeviceOnly: "AlwaysThisDeviceOnly",
+}
+
+// ServiceDescription summarizes the generic passwords of a service, without
+// their data, e.g. to document the layout of an application or to diagnose a
+// support case. It encodes to JSON.
+type ServiceDescription struct {
+	Service  string               `json:"service"`
+	Accounts []AccountDescription `json:"accounts"`
+	// Attributes counts the items setting each optional attribute: "label",
+	// "description", "comment", "type", "creator" and "generic".
+	Attributes map[string]int `json:"attributes"`
+	// MetadataKeys counts the items with each metadata key, see Metadata.
+	MetadataKeys map[string]int `json:"metadataKeys,omitempty"`
+	// Accessibility counts the items of each protection class, e.g.
+	// "WhenUnlockedThisDeviceOnly".
+	Accessibility map[string]int `json:"accessibility"`
+	// AccessGroups counts the items of each access group.
+	AccessGroups map[string]int `json:"accessGroups,omitempty"`
+	// Synchronizable is the number of items synchronized with iCloud
+	// Keychain.
+	Synchronizable int `json:"synchronizable"`
+	// Protected is the number of items whose data needs user
+	// authentication, and whose size isn't known.
+	Protected int `json:"protected"`
+	// TotalSize is the size of the data of the other items.
+	TotalSize int `json:"totalSize"`
+}
+
+// AccountDescription describes a generic password in a ServiceDescription.
+type AccountDescription struct {
+	Account        string `json:"account"`
+	AccessGroup    string `json:"accessGroup,omitempty"`
+	Label          string `json:"label,omitempty"`
+	Accessibility  string `json:"accessibility,omitempty"`
+	Synchronizable bool   `json:"synchronizable"`
+	// Size is the size of the data, -1 if reading it needs user
+	// authentication.
+	Size     int       `json:"size"`
+	Created  time.Time `json:"created"`
+	Modified time.Time `json:"modified"`
+}
+
+// DescribeService returns a summary of the generic passwords of service in all
+// access groups, synchronized or not. The data of the items is only read to
+// measure it, without showing an authentication prompt (see
+// QueryItemNoPrompt), and the description holds no secret. Account names are
+// included; use ItemID to refer to items without them.
+func DescribeService(service string) (ServiceDescription, error) {
+	d := ServiceDescription{
+		Service:       service,
+		Accounts:      []AccountDescription{},
+		Attributes:    map[string]int{},
+		Accessibility: map[string]int{},
+	}
+
+	// Backends without persistent references read the data by attributes.
+	refs := Capabilities().PersistentRefs
+
+	for _, sync := range []Synchronizable{SynchronizableNo, SynchronizableYes} {
+		query := newItem()
+		query.SetSecClass(SecClassGenericPassword)
+		query.SetService(service)
+		query.SetSynchronizable(sync)
+
+		if refs {
+			query.SetReturnPersistentRef(true)
+		}
+
+		results, err := queryAttributes(query)
+		if err != nil {
+			return ServiceDescription{}, err
+		}
+
+		for _, r := range results {
+			if err := d.add(r, sync, refs); err != nil {
+				return ServiceDescription{}, err
+			}
+		}
+	}
+
+	sort.Slice(d.Accounts, func(i, j int) bool {
+		a, b := d.Accounts[i], d.Accounts[j]
+		if a.Account != b.Account {
+			return a.Account < b.Account
+		}
+
+		return a.AccessGroup < b.AccessGroup
+	})
+
+	return d, nil
+}
+
+// add adds the item of r, listed with sync, to the description. Its data is
+// read by persistent reference if refs is set.
+func (d *ServiceDescription) add(r QueryResult, sync Synchronizable, refs bool) error {
+	a := AccountDescription{
+		Account:        r.Account,
+		AccessGroup:    r.AccessGroup,
+		Label:          r.Label,
+		Accessibility:  accessibleNames[r.Accessible],
+		Synchronizable: sync == SynchronizableYes,
+		Created:        r.CreationDate,
+		Modified:       r.ModificationDate,
+	}
+
+	query := queryForResult(SecClassGenericPassword, r)
+
+	if refs {
+		ref, err := resultRef(r)
+		if err != nil {
+			return err
+		}
+
+		query = refQuery(ref)
+		query.SetSecClass(SecClassGenericPassword)
+	}
+
+	query.SetSynchronizable(sync)
+
+	data, ok, err := readDataNoPrompt(query)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		a.Size = len(data)
+		d.TotalSize += len(data)
+		clear(data)
+	} else {
+		a.Size = -1
+		d.Protected++
+	}
+
+	d.Accounts = append(d.Accounts, a)
+
+	for name, set := range map[string]bool{
+		"label":       r.Label != "",
+		"description": r.Description != "",
+		"comment":     r.Comment != "",
+		"type":        r.Type != "",
+		"creator":     r.Creator != "",
+		"generic":     len(r.Generic) > 0,
+	} {
+		if set {
+			d.Attributes[name]++
+		}
+	}
+
+	for key := range r.Metadata() {
+		if d.MetadataKeys == nil {
+			d.MetadataKeys = map[string]int{}
+		}
+
+		d.MetadataKeys[key]++
+	}
+
+	if a.Accessibility != "" {
+		d.Accessibility[a.Accessibility]++
+	}
+
+	if a.AccessGroup != "" {
+		if d.AccessGroups == nil {
+			d.AccessGroups = map[string]int{}
+		}
+
+		d.AccessGroups[a.AccessGroup]++
+	}
+
+	if a.Synchronizable {
+		d.Synchronizable++
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package keychain_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func TestDescribeService(t *testing.T) {
+	f := keychainfake.Install(t)
+
+	add := func(account string, data string, sync keychain.Synchronizable) {
+		t.Helper()
+
+		item := keychain.NewGenericPassword("com.example.app", account, "", []byte(data), "")
+		item.SetAccessible(keychain.AccessibleAfterFirstUnlock)
+		item.SetSynchronizable(sync)
+
+		if err := item.SetDataWithChecksum([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := keychain.AddItem(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	add("alice", "toomanysecrets", keychain.SynchronizableNo)
+	add("bob", "protected", keychain.SynchronizableNo)
+	add("carol", "synced", keychain.SynchronizableYes)
+
+	f.On(keychainfake.CopyMatching).Where(func(c keychainfake.Call) bool {
+		ref, _ := c.Query[keychain.ValuePersistentRefKey].([]byte)
+
+		return string(ref) == "secitem-2"
+	}).Fail(keychain.ErrorInteractionNotAllowed)
+
+	d, err := keychain.DescribeService("com.example.app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.Accounts) != 3 || d.Accounts[0].Size != 14 || d.Accounts[1].Size != -1 || !d.Accounts[2].Synchronizable {
+		t.Fatalf("unexpected accounts %+v", d.Accounts)
+	}
+
+	if d.Protected != 1 || d.TotalSize != 20 || d.Synchronizable != 1 ||
+		d.Accessibility["AfterFirstUnlock"] != 3 || d.Attributes["generic"] != 3 || d.MetadataKeys[keychain.MetadataChecksumKey] != 3 {
+		t.Fatalf("unexpected description %+v", d)
+	}
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(b, []byte("toomanysecrets")) {
+		t.Fatalf("description exposes item data: %s", b)
+	}
+}
+
+func TestDescribeServiceWithoutPersistentRefs(t *testing.T) {
+	f := keychainfake.Install(t)
+	f.SetCapabilities(keychain.Capability{})
+
+	if err := keychain.AddItem(keychain.NewGenericPassword("com.example.app", "alice", "", []byte("secret"), "")); err != nil {
+		t.Fatal(err)
+	}
+
+	f.On(keychainfake.CopyMatching).Where(func(c keychainfake.Call) bool {
+		_, ref := c.Query[keychain.ValuePersistentRefKey]
+		_, returnRef := c.Query[keychain.ReturnPersistentRefKey]
+
+		return ref || returnRef
+	}).Fail(keychain.ErrorUnimplemented)
+
+	d, err := keychain.DescribeService("com.example.app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.Accounts) != 1 || d.Accounts[0].Size != 6 || d.TotalSize != 6 {
+		t.Fatalf("unexpected description %+v", d)
+	}
+}
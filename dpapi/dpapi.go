@@ -0,0 +1,51 @@
+// Package dpapi implements a keychain backend for Windows that stores items in
+// a file encrypted for the current user with DPAPI (CryptProtectData). Unlike
+// the Credential Manager, whose secrets are limited to 2560 bytes, items may
+// be as large as on macOS.
+//
+// Importing the package registers the backend on Windows:
+//
+//	import _ "github.com/mailstone/go-keychain/dpapi"
+//
+//	err := keychain.UseBackend(dpapi.Name)
+package dpapi
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/internal/secitem"
+)
+
+// Name is the name the backend is registered under, see keychain.UseBackend.
+const Name = "dpapi"
+
+// DefaultPath returns the default store file,
+// %LOCALAPPDATA%\go-keychain\keychain.dpapi.
+func DefaultPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find local application data directory: %w", err)
+	}
+
+	return filepath.Join(dir, "go-keychain", "keychain.dpapi"), nil
+}
+
+// Open returns a backend storing items in the file at path, which is created
+// on the first change. Only the current Windows user can decrypt the file.
+func Open(path string) (keychain.Backend, error) {
+	return secitem.OpenFile(path, sealer{})
+}
+
+// sealer encrypts the store for the current user.
+type sealer struct{}
+
+func (sealer) Seal(plaintext []byte) ([]byte, error) {
+	return protect(plaintext)
+}
+
+func (sealer) Open(ciphertext []byte) ([]byte, error) {
+	return unprotect(ciphertext)
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package dpapi
+
+import "errors"
+
+var errUnsupported = errors.New("DPAPI is only available on Windows")
+
+func protect([]byte) ([]byte, error) {
+	return nil, errUnsupported
+}
+
+func unprotect([]byte) ([]byte, error) {
+	return nil, errUnsupported
+}
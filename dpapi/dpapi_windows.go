@@ -0,0 +1,85 @@
+//go:build windows
+// +build windows
+
+package dpapi
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	keychain "github.com/mailstone/go-keychain"
+)
+
+func init() {
+	keychain.RegisterBackend(Name, func() (keychain.Backend, error) {
+		path, err := DefaultPath()
+		if err != nil {
+			return nil, err
+		}
+
+		return Open(path)
+	})
+}
+
+// cryptProtectUIForbidden fails instead of prompting the user.
+const cryptProtectUIForbidden = 0x1
+
+var (
+	crypt32                = syscall.NewLazyDLL("crypt32.dll")
+	kernel32               = syscall.NewLazyDLL("kernel32.dll")
+	procCryptProtectData   = crypt32.NewProc("CryptProtectData")
+	procCryptUnprotectData = crypt32.NewProc("CryptUnprotectData")
+	procLocalFree          = kernel32.NewProc("LocalFree")
+)
+
+// dataBlob is DATA_BLOB.
+type dataBlob struct {
+	size uint32
+	data *byte
+}
+
+func newBlob(b []byte) *dataBlob {
+	if len(b) == 0 {
+		return &dataBlob{}
+	}
+
+	return &dataBlob{size: uint32(len(b)), data: &b[0]}
+}
+
+// bytes copies the blob, which was allocated by DPAPI, and frees it.
+func (b *dataBlob) bytes() []byte {
+	if b.data == nil {
+		return []byte{}
+	}
+
+	defer procLocalFree.Call(uintptr(unsafe.Pointer(b.data))) //nolint:errcheck
+
+	return append([]byte{}, unsafe.Slice(b.data, b.size)...)
+}
+
+func protect(plaintext []byte) ([]byte, error) {
+	var out dataBlob
+
+	r, _, err := procCryptProtectData.Call(
+		uintptr(unsafe.Pointer(newBlob(plaintext))), 0, 0, 0, 0,
+		cryptProtectUIForbidden, uintptr(unsafe.Pointer(&out)))
+	if r == 0 {
+		return nil, fmt.Errorf("CryptProtectData failed: %w", err)
+	}
+
+	return out.bytes(), nil
+}
+
+func unprotect(ciphertext []byte) ([]byte, error) {
+	var out dataBlob
+
+	r, _, err := procCryptUnprotectData.Call(
+		uintptr(unsafe.Pointer(newBlob(ciphertext))), 0, 0, 0, 0,
+		cryptProtectUIForbidden, uintptr(unsafe.Pointer(&out)))
+	if r == 0 {
+		return nil, fmt.Errorf("CryptUnprotectData failed: %w", err)
+	}
+
+	return out.bytes(), nil
+}
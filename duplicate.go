@@ -0,0 +1,115 @@
+package keychain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// primaryKeys are the attributes which, with the class, access group and
+// synchronizable attribute, identify an item of each class.
+var primaryKeys = map[SecClass][]string{
+	SecClassGenericPassword: {ServiceKey, AccountKey},
+	SecClassInternetPassword: {
+		ServerKey, ProtocolKey, AuthenticationTypeKey, PortKey, PathKey, AccountKey, SecurityDomainKey,
+	},
+	SecClassCertificate: {CertificateTypeKey, IssuerKey, SerialNumberKey},
+	SecClassPairKey: {
+		KeyClassKey, ApplicationLabelKey, ApplicationTagKey, TypeKey, KeySizeInBitsKey, EffectiveKeySizeKey,
+	},
+}
+
+// AddOptions are options of AddItemWithOptions.
+type AddOptions struct {
+	// CheckDuplicate looks for an item with the same primary key (by its
+	// attributes only) before adding, and returns a *DuplicateItemError
+	// describing it if there is one.
+	CheckDuplicate bool
+}
+
+// DuplicateItemError is returned by AddItemWithOptions with CheckDuplicate
+// when the item already exists. It matches ErrorDuplicateItem with errors.Is.
+type DuplicateItemError struct {
+	// Existing are the attributes of the existing item, without data.
+	Existing QueryResult
+}
+
+func (e *DuplicateItemError) Error() string {
+	return fmt.Sprintf("%s: existing item %s labelled %q, modified %s", ErrorDuplicateItem,
+		describeResult(e.Existing), e.Existing.Label, e.Existing.ModificationDate.Format("2006-01-02 15:04:05 MST"))
+}
+
+// Is reports whether target is ErrorDuplicateItem.
+func (e *DuplicateItemError) Is(target error) bool {
+	return target == ErrorDuplicateItem //nolint:errorlint
+}
+
+// AddItemWithOptions adds item like AddItem, with opts.
+func AddItemWithOptions(item Item, opts AddOptions) error {
+	if !opts.CheckDuplicate {
+		return AddItem(item)
+	}
+
+	if err := checkDuplicate(item); err != nil {
+		return err
+	}
+
+	err := AddItem(item)
+	if errors.Is(err, ErrorDuplicateItem) {
+		// The item was added concurrently, or is identified by attributes the
+		// check didn't query.
+		if dupErr := checkDuplicate(item); dupErr != nil {
+			return dupErr
+		}
+	}
+
+	return err
+}
+
+// checkDuplicate returns a *DuplicateItemError if an item with the primary key
+// of item exists.
+func checkDuplicate(item Item) error {
+	secClass := item.secClass()
+
+	keys, ok := primaryKeys[secClass]
+	if !ok {
+		return nil
+	}
+
+	query := newItem()
+	query.SetSecClass(secClass)
+
+	for _, list := range [][]string{keys, {AccessGroupKey, SynchronizableKey, UseKeychainKey, UseDataProtectionKeychainKey}} {
+		for _, key := range list {
+			if v, ok := item.attr[key]; ok {
+				query.attr[key] = v
+			}
+		}
+	}
+
+	results, err := queryAttributes(query)
+	if err != nil {
+		return fmt.Errorf("failed to check for a duplicate item: %w", err)
+	}
+
+	for _, r := range results {
+		if missingMatch(item, keys, r) {
+			return &DuplicateItemError{Existing: r}
+		}
+	}
+
+	return nil
+}
+
+// missingMatch returns whether the string attributes of keys missing from
+// item are missing from r as well, since a query can't ask for that.
+func missingMatch(item Item, keys []string, r QueryResult) bool {
+	values := map[string]string{ServiceKey: r.Service, AccountKey: r.Account, ServerKey: r.Server, PathKey: r.Path}
+
+	for _, key := range keys {
+		if _, ok := item.attr[key]; !ok && values[key] != "" {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,35 @@
+package keychain_test
+
+import (
+	"errors"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func TestAddItemCheckDuplicate(t *testing.T) {
+	keychainfake.Install(t)
+
+	opts := keychain.AddOptions{CheckDuplicate: true}
+
+	if err := keychain.AddItemWithOptions(keychain.NewGenericPassword("MyService", "", "", []byte("other"), ""), opts); err != nil {
+		t.Fatal(err)
+	}
+
+	item := keychain.NewGenericPassword("MyService", "gabriel", "A label", []byte("toomanysecrets"), "")
+	if err := keychain.AddItemWithOptions(item, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	err := keychain.AddItemWithOptions(item, opts)
+
+	var dupErr *keychain.DuplicateItemError
+	if !errors.As(err, &dupErr) || !errors.Is(err, keychain.ErrorDuplicateItem) {
+		t.Fatalf("expected a DuplicateItemError, got %v", err)
+	}
+
+	if dupErr.Existing.Label != "A label" || dupErr.Existing.Account != "gabriel" || dupErr.Existing.Data != nil {
+		t.Fatalf("unexpected existing item %+v", dupErr.Existing)
+	}
+}
@@ -0,0 +1,97 @@
+package keychain
+
+import (
+	"errors"
+	"fmt"
+)
+
+var secClassValues = map[SecClass]string{
+	SecClassGenericPassword:  "genp",
+	SecClassInternetPassword: "inet",
+	SecClassCertificate:      "cert",
+	SecClassPairKey:          "keys",
+	SecClassIdentity:         "idnt",
+}
+
+var syncValues = map[Synchronizable]interface{}{
+	SynchronizableAny: "syna",
+	SynchronizableYes: true,
+	SynchronizableNo:  false,
+}
+
+var accessibleValues = map[Accessible]string{
+	AccessibleWhenUnlocked:                   "ak",
+	AccessibleAfterFirstUnlock:               "ck",
+	AccessibleAlways:                         "dk",
+	AccessibleWhenPasscodeSetThisDeviceOnly:  "akpu",
+	AccessibleWhenUnlockedThisDeviceOnly:     "aku",
+	AccessibleAfterFirstUnlockThisDeviceOnly: "cku",
+	AccessibleAccessibleAlwaysThisDeviceOnly: "dku",
+}
+
+var matchLimitValues = map[MatchLimit]string{
+	MatchLimitOne: "m_LimitOne",
+	MatchLimitAll: "m_LimitAll",
+}
+
+// EncodeAttributes returns the attributes of item as they are passed to the
+// Security framework, keyed by the string values of the attribute key
+// constants. Class, accessibility and match limit values are encoded as the
+// string values of the corresponding constants, synchronizable as a bool (or
+// "syna" for SynchronizableAny). Strings, data, numbers, bools and platform
+// specific values are returned as set. In strict builds, items violating the
+// policy are rejected with ErrForbidden (see Strict).
+func EncodeAttributes(item Item) (map[string]interface{}, error) {
+	attrs := make(map[string]interface{}, len(item.attr))
+
+	for key, v := range item.attr {
+		ev, err := encodeValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %w", key, err)
+		}
+
+		attrs[key] = ev
+	}
+
+	if _, ok := attrs[AccessControlKey]; ok {
+		if _, ok := attrs[AccessibleKey]; ok {
+			return nil, errors.New("accessibility set with an access control")
+		}
+	}
+
+	if err := checkPolicy(item, attrs); err != nil {
+		return nil, err
+	}
+
+	return attrs, nil
+}
+
+func encodeValue(v interface{}) (interface{}, error) {
+	var (
+		ev interface{}
+		ok bool
+	)
+
+	switch v := v.(type) {
+	case SecClass:
+		ev, ok = secClassValues[v]
+	case Synchronizable:
+		ev, ok = syncValues[v]
+	case Accessible:
+		ev, ok = accessibleValues[v]
+	case MatchLimit:
+		ev, ok = matchLimitValues[v]
+	case AuthenticationUI:
+		ev, ok = authenticationUIValues[v]
+	case AccessControl:
+		return v.encode()
+	default:
+		return v, nil
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("unknown %T %d", v, v)
+	}
+
+	return ev, nil
+}
@@ -0,0 +1,173 @@
+package keychain
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files in testdata")
+
+// encodeVectors are items whose encoded attributes are checked against
+// testdata/encode/<name>.json on every platform.
+var encodeVectors = []struct {
+	name string
+	item func() Item
+}{
+	{"generic_password", func() Item {
+		return NewGenericPassword("MyService", "gabriel", "A label", []byte("toomanysecrets"), "A123456789.group.com.example")
+	}},
+	{"internet_password", func() Item {
+		item := NewItem()
+		item.SetSecClass(SecClassInternetPassword)
+		item.SetServer("example.com")
+		item.SetProtocol("htps")
+		item.SetAuthenticationType("dflt")
+		item.SetPort(8443)
+		item.SetPath("/login")
+		item.SetAccount("gabriel")
+		item.SetData([]byte("toomanysecrets"))
+
+		return item
+	}},
+	{"query_all", func() Item {
+		item := NewItem()
+		item.SetSecClass(SecClassGenericPassword)
+		item.SetService("MyService")
+		item.SetSynchronizable(SynchronizableAny)
+		item.SetMatchLimit(MatchLimitAll)
+		item.SetReturnAttributes(true)
+		item.SetReturnData(false)
+
+		return item
+	}},
+	{"accessible_synchronizable", func() Item {
+		item := NewGenericPassword("MyService", "gabriel", "", []byte("toomanysecrets"), "")
+		item.SetAccessible(AccessibleWhenUnlockedThisDeviceOnly)
+		item.SetSynchronizable(SynchronizableNo)
+		item.SetUseDataProtectionKeychain(true)
+
+		return item
+	}},
+	{"type_creator_generic", func() Item {
+		item := NewGenericPassword("MyService", "gabriel", "", []byte("toomanysecrets"), "")
+		_ = item.SetType("note")
+		_ = item.SetCreator("gokc")
+		item.SetGeneric([]byte(`{"owner":"gabriel"}`))
+		item.SetComment("A comment")
+		item.SetDescription("secure note")
+
+		return item
+	}},
+	{"persistent_ref", func() Item {
+		item := NewItem()
+		item.SetPersistentRef([]byte{0x67, 0x65, 0x6e, 0x70, 0x00, 0x01})
+		item.SetMatchLimit(MatchLimitOne)
+		item.SetReturnData(true)
+		item.SetReturnPersistentRef(true)
+
+		return item
+	}},
+	{"match_item_list", func() Item {
+		item := NewItem()
+		item.SetMatchItemList([]byte{0x67, 0x65, 0x6e, 0x70, 0x00, 0x01}, []byte{0x67, 0x65, 0x6e, 0x70, 0x00, 0x02})
+		item.SetMatchLimit(MatchLimitAll)
+		item.SetReturnAttributes(true)
+
+		return item
+	}},
+	{"access_control", func() Item {
+		item := NewGenericPassword("MyService", "gabriel", "", []byte("toomanysecrets"), "")
+		item.SetAccessControl(AccessControl{
+			Flags: AccessControlBiometryCurrentSet | AccessControlDevicePasscode | AccessControlOr,
+		})
+
+		return item
+	}},
+	{"authentication_query", func() Item {
+		item := NewItem()
+		item.SetSecClass(SecClassGenericPassword)
+		item.SetService("MyService")
+		item.SetReturnData(true)
+		item.SetOperationPrompt("Sign in to MyService")
+		item.SetAuthenticationUI(AuthenticationUIFail)
+
+		return item
+	}},
+	{"key_attributes", func() Item {
+		item := NewItem()
+		item.SetSecClass(SecClassPairKey)
+		item.SetString(KeyClassKey, "1")
+		item.SetBytes(ApplicationLabelKey, []byte{0x01, 0x02})
+		item.SetBool(CanSignKey, true)
+		item.SetInt32(EffectiveKeySizeKey, 256)
+		item.SetReturnAttributes(true)
+
+		return item
+	}},
+}
+
+func TestEncodeAttributesGolden(t *testing.T) {
+	if Strict() {
+		t.Skip("golden files are for builds without keychain_strict")
+	}
+
+	for _, v := range encodeVectors {
+		t.Run(v.name, func(t *testing.T) {
+			attrs, err := EncodeAttributes(v.item())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := json.MarshalIndent(attrs, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got = append(got, '\n')
+			path := filepath.Join("testdata", "encode", v.name+".json")
+
+			if *updateGolden {
+				if err := os.WriteFile(path, got, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+
+			if !bytes.Equal(got, want) {
+				t.Errorf("encoded attributes differ from %s:\n%s", path, got)
+			}
+		})
+	}
+}
+
+func TestEncodeAttributesInvalid(t *testing.T) {
+	item := NewItem()
+	item.SetSecClass(SecClass(99))
+
+	if _, err := EncodeAttributes(item); err == nil {
+		t.Fatal("expected error for unknown class")
+	}
+
+	item = NewItem()
+	item.SetMatchLimit(MatchLimit(7))
+
+	if _, err := EncodeAttributes(item); err == nil {
+		t.Fatal("expected error for unknown match limit")
+	}
+
+	item = NewItem()
+	item.SetAccessControl(AccessControl{Flags: AccessControlUserPresence})
+	item.SetAccessible(AccessibleAfterFirstUnlock)
+
+	if _, err := EncodeAttributes(item); err == nil {
+		t.Fatal("expected error for accessibility with an access control")
+	}
+}
@@ -0,0 +1,62 @@
+package keychain_test
+
+import (
+	"fmt"
+	"log"
+
+	keychain "github.com/mailstone/go-keychain"
+)
+
+func ExampleSetString() {
+	if err := keychain.SetString("com.example.app", "gabriel", "toomanysecrets"); err != nil {
+		log.Fatal(err)
+	}
+
+	token, err := keychain.GetString("com.example.app", "gabriel")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(token)
+}
+
+func ExampleQueryGenericPasswordItems() {
+	query := keychain.NewItem()
+	query.SetService("com.example.app")
+
+	items, err := keychain.QueryGenericPasswordItems(query)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, item := range items {
+		fmt.Println(item.Account, item.ModificationDate)
+	}
+}
+
+func ExampleWatchItem() {
+	changes, cancel := keychain.WatchItem("com.example.app", "gabriel")
+	defer cancel()
+
+	for r := range changes {
+		if r.Data == nil {
+			fmt.Println("deleted")
+
+			return
+		}
+
+		fmt.Printf("changed, %d bytes\n", len(r.Data))
+	}
+}
+
+func ExampleAccessControl() {
+	item := keychain.NewGenericPassword("com.example.app", "gabriel", "", []byte("toomanysecrets"), "")
+	item.SetAccessControl(keychain.AccessControl{
+		Accessible: keychain.AccessibleWhenPasscodeSetThisDeviceOnly,
+		Flags:      keychain.AccessControlBiometryCurrentSet | keychain.AccessControlDevicePasscode | keychain.AccessControlOr,
+	})
+
+	if err := keychain.AddItem(item); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,58 @@
+// Command biometric stores a secret that can only be read after Touch ID or
+// the login password, and reads it back, which shows the prompt.
+//
+// Usage:
+//
+//	biometric [-service name] account secret
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	keychain "github.com/mailstone/go-keychain"
+)
+
+func main() {
+	service := flag.String("service", "com.example.biometric", "service of the item")
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		log.Fatal("usage: biometric [-service name] account secret")
+	}
+
+	account, secret := flag.Arg(0), flag.Arg(1)
+
+	_ = keychain.DeleteGenericPasswordItem(*service, account)
+
+	item := keychain.NewGenericPassword(*service, account, "", []byte(secret), "")
+	item.SetAccessControl(keychain.AccessControl{
+		Accessible: keychain.AccessibleWhenPasscodeSetThisDeviceOnly,
+		Flags: keychain.AccessControlBiometryCurrentSet | keychain.AccessControlDevicePasscode |
+			keychain.AccessControlOr,
+	})
+
+	if err := keychain.AddItem(item); err != nil {
+		log.Fatalf("failed to add secret: %v", err)
+	}
+
+	query := keychain.NewItem()
+	query.SetSecClass(keychain.SecClassGenericPassword)
+	query.SetService(*service)
+	query.SetAccount(account)
+	query.SetMatchLimit(keychain.MatchLimitOne)
+	query.SetReturnData(true)
+	query.SetOperationPrompt("read the example secret")
+
+	results, err := keychain.QueryItem(query)
+	if err != nil {
+		log.Fatalf("failed to read secret: %v", err)
+	}
+
+	if len(results) != 1 {
+		log.Fatal("secret not found")
+	}
+
+	fmt.Printf("read %d bytes after authentication\n", len(results[0].Data))
+}
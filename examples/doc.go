@@ -0,0 +1,9 @@
+// Package examples holds small programs using the public API of go-keychain
+// end to end. They are a separate module so that they build against the
+// package like an application would, see the replace directive in go.mod.
+//
+//   - storetoken stores an API token and reads it back.
+//   - biometric stores a secret behind Touch ID or the login password.
+//   - mtls makes an HTTPS request with a client certificate from the keychain.
+//   - watch prints the changes of an item and the system lock events.
+package examples
@@ -0,0 +1,9 @@
+module github.com/mailstone/go-keychain/examples
+
+go 1.24.0
+
+require github.com/mailstone/go-keychain v0.0.0
+
+require golang.org/x/text v0.27.0 // indirect
+
+replace github.com/mailstone/go-keychain => ../
@@ -0,0 +1,60 @@
+// Command mtls makes an HTTPS request authenticated with a client certificate
+// and private key (an identity) from the keychain, e.g. one imported with
+// keychain.ImportIdentity.
+//
+// Usage:
+//
+//	mtls -label name url
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	keychain "github.com/mailstone/go-keychain"
+)
+
+func main() {
+	label := flag.String("label", "", "label of the identity")
+	flag.Parse()
+
+	if *label == "" || flag.NArg() != 1 {
+		log.Fatal("usage: mtls -label name url")
+	}
+
+	query := keychain.NewItem()
+	query.SetLabel(*label)
+	query.SetMatchLimit(keychain.MatchLimitOne)
+
+	ids, err := keychain.QueryIdentities(query)
+	if err != nil {
+		log.Fatalf("failed to query identities: %v", err)
+	}
+
+	if len(ids) == 0 {
+		log.Fatalf("no identity labeled %q", *label)
+	}
+
+	id := ids[0]
+	defer id.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{
+			Certificates: []tls.Certificate{id.TLSCertificate()},
+			MinVersion:   tls.VersionTLS12,
+		},
+	}}
+
+	resp, err := client.Get(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	n, _ := io.Copy(io.Discard, resp.Body)
+	fmt.Printf("%s: %d bytes as %s\n", resp.Status, n, id.Certificate().Subject.CommonName)
+}
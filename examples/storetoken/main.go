@@ -0,0 +1,36 @@
+// Command storetoken stores an API token in the keychain and reads it back.
+//
+// Usage:
+//
+//	storetoken [-service name] account token
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	keychain "github.com/mailstone/go-keychain"
+)
+
+func main() {
+	service := flag.String("service", "com.example.storetoken", "service of the item")
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		log.Fatal("usage: storetoken [-service name] account token")
+	}
+
+	account, token := flag.Arg(0), flag.Arg(1)
+
+	if err := keychain.SetString(*service, account, token); err != nil {
+		log.Fatalf("failed to store token: %v", err)
+	}
+
+	stored, err := keychain.GetString(*service, account)
+	if err != nil {
+		log.Fatalf("failed to read token: %v", err)
+	}
+
+	fmt.Printf("stored %d bytes for %s in %s\n", len(stored), account, *service)
+}
@@ -0,0 +1,49 @@
+// Command watch prints the changes of a generic password, and the lock,
+// unlock, sleep and wake events of the system, until interrupted.
+//
+// Usage:
+//
+//	watch service account
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	keychain "github.com/mailstone/go-keychain"
+)
+
+func main() {
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		log.Fatal("usage: watch service account")
+	}
+
+	changes, cancel := keychain.WatchItem(flag.Arg(0), flag.Arg(1))
+	defer cancel()
+
+	events, cancelEvents := keychain.WatchSystemEvents()
+	defer cancelEvents()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	for {
+		select {
+		case r := <-changes:
+			if r.Data == nil {
+				fmt.Println("item deleted")
+			} else {
+				fmt.Printf("item changed, %d bytes\n", len(r.Data))
+			}
+		case e := <-events:
+			fmt.Printf("system %s\n", e)
+		case <-interrupt:
+			return
+		}
+	}
+}
@@ -0,0 +1,5 @@
+package keychain
+
+// ResetShutdown accepts operations through the package configuration again
+// after Shutdown, for the tests following those of Shutdown.
+func ResetShutdown() { packageDrain.reopen() }
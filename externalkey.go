@@ -0,0 +1,249 @@
+package keychain
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// ErrUnsupportedKey is returned for keys without a Security framework
+// representation, e.g. Ed25519 and X25519 keys, or EC keys on curves other
+// than P-256, P-384 and P-521.
+var ErrUnsupportedKey = errors.New("key not supported by the Security framework")
+
+// ExternalKey is a key in the external representation of the Security
+// framework (SecKeyCopyExternalRepresentation and SecKeyCreateWithData): for
+// EC keys, the ANSI X9.63 uncompressed point 04 || X || Y, followed by the
+// private scalar for private keys, and for RSA keys the PKCS #1 RSAPublicKey
+// or RSAPrivateKey.
+type ExternalKey struct {
+	Type KeyType
+	// Bits is the key size.
+	Bits int
+	// Private is set for private keys (kSecAttrKeyClassPrivate).
+	Private bool
+	Data    []byte
+}
+
+// ecdhBits returns the size of the keys of curve, if it is supported.
+func ecdhBits(curve ecdh.Curve) (int, bool) {
+	for bits, c := range ecdhCurves {
+		if c == curve {
+			return bits, true
+		}
+	}
+
+	return 0, false
+}
+
+// MarshalExternalKey returns the external representation of key, an
+// *ecdsa.PublicKey, *ecdsa.PrivateKey, *ecdh.PublicKey, *ecdh.PrivateKey,
+// *rsa.PublicKey or *rsa.PrivateKey. Keys which the Security framework doesn't
+// support, such as Ed25519 keys, are reported as ErrUnsupportedKey.
+func MarshalExternalKey(key any) (ExternalKey, error) {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		pub, err := k.ECDH()
+		if err != nil {
+			return ExternalKey{}, fmt.Errorf("%w: EC keys on %s", ErrUnsupportedKey, k.Curve.Params().Name)
+		}
+
+		return MarshalExternalKey(pub)
+	case *ecdsa.PrivateKey:
+		priv, err := k.ECDH()
+		if err != nil {
+			return ExternalKey{}, fmt.Errorf("%w: EC keys on %s", ErrUnsupportedKey, k.Curve.Params().Name)
+		}
+
+		return MarshalExternalKey(priv)
+	case *ecdh.PublicKey:
+		bits, ok := ecdhBits(k.Curve())
+		if !ok {
+			return ExternalKey{}, fmt.Errorf("%w: %v keys", ErrUnsupportedKey, k.Curve())
+		}
+
+		return ExternalKey{Type: KeyTypeEC, Bits: bits, Data: k.Bytes()}, nil
+	case *ecdh.PrivateKey:
+		bits, ok := ecdhBits(k.Curve())
+		if !ok {
+			return ExternalKey{}, fmt.Errorf("%w: %v keys", ErrUnsupportedKey, k.Curve())
+		}
+
+		data := append(k.PublicKey().Bytes(), k.Bytes()...)
+
+		return ExternalKey{Type: KeyTypeEC, Bits: bits, Private: true, Data: data}, nil
+	case *rsa.PublicKey:
+		return ExternalKey{Type: KeyTypeRSA, Bits: k.N.BitLen(), Data: x509.MarshalPKCS1PublicKey(k)}, nil
+	case *rsa.PrivateKey:
+		return ExternalKey{Type: KeyTypeRSA, Bits: k.N.BitLen(), Private: true, Data: x509.MarshalPKCS1PrivateKey(k)}, nil
+	case ed25519.PublicKey, ed25519.PrivateKey, *ed25519.PublicKey, *ed25519.PrivateKey:
+		return ExternalKey{}, fmt.Errorf("%w: Ed25519 keys", ErrUnsupportedKey)
+	}
+
+	return ExternalKey{}, fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+}
+
+// ParseExternalKey parses the external representation of a public or private
+// key of keyType, e.g. exported by another program.
+func ParseExternalKey(keyType KeyType, private bool, data []byte) (ExternalKey, error) {
+	k := ExternalKey{Type: keyType, Private: private, Data: data}
+
+	switch keyType {
+	case KeyTypeRSA:
+		if private {
+			priv, err := x509.ParsePKCS1PrivateKey(data)
+			if err != nil {
+				return ExternalKey{}, fmt.Errorf("invalid RSA private key: %w", err)
+			}
+
+			k.Bits = priv.N.BitLen()
+		} else {
+			pub, err := x509.ParsePKCS1PublicKey(data)
+			if err != nil {
+				return ExternalKey{}, fmt.Errorf("invalid RSA public key: %w", err)
+			}
+
+			k.Bits = pub.N.BitLen()
+		}
+	case KeyTypeEC:
+		for bits := range ecdhCurves {
+			size := (bits + 7) / 8
+
+			n := 1 + 2*size
+			if private {
+				n += size
+			}
+
+			if len(data) == n {
+				k.Bits = bits
+			}
+		}
+
+		if k.Bits == 0 {
+			return ExternalKey{}, fmt.Errorf("%w: EC key of %d bytes", ErrUnsupportedKey, len(data))
+		}
+
+		if private {
+			if _, err := k.ECDHPrivateKey(); err != nil {
+				return ExternalKey{}, err
+			}
+		} else if _, err := k.ECDHPublicKey(); err != nil {
+			return ExternalKey{}, err
+		}
+	default:
+		return ExternalKey{}, fmt.Errorf("unknown key type %d", keyType)
+	}
+
+	return k, nil
+}
+
+// pointSize returns the size of the coordinates of the EC key.
+func (k ExternalKey) pointSize() int {
+	return (k.Bits + 7) / 8
+}
+
+// ECDHPublicKey returns the public key of an EC key.
+func (k ExternalKey) ECDHPublicKey() (*ecdh.PublicKey, error) {
+	curve, ok := ecdhCurves[k.Bits]
+	if k.Type != KeyTypeEC || !ok {
+		return nil, fmt.Errorf("%w: ECDH with a %d bit key of type %d", ErrUnsupportedKey, k.Bits, k.Type)
+	}
+
+	n := 1 + 2*k.pointSize()
+	if len(k.Data) < n {
+		return nil, fmt.Errorf("invalid EC key of %d bytes", len(k.Data))
+	}
+
+	pub, err := curve.NewPublicKey(k.Data[:n])
+	if err != nil {
+		return nil, fmt.Errorf("invalid EC public key: %w", err)
+	}
+
+	return pub, nil
+}
+
+// ECDHPrivateKey returns the private key of a private EC key.
+func (k ExternalKey) ECDHPrivateKey() (*ecdh.PrivateKey, error) {
+	if !k.Private {
+		return nil, errors.New("not a private key")
+	}
+
+	pub, err := k.ECDHPublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	priv, err := pub.Curve().NewPrivateKey(k.Data[1+2*k.pointSize():])
+	if err != nil {
+		return nil, fmt.Errorf("invalid EC private key: %w", err)
+	}
+
+	if !priv.PublicKey().Equal(pub) {
+		return nil, errors.New("invalid EC private key: the point isn't its public key")
+	}
+
+	return priv, nil
+}
+
+// PublicKey returns the public key, an *ecdsa.PublicKey or *rsa.PublicKey,
+// like Key.Public.
+func (k ExternalKey) PublicKey() (crypto.PublicKey, error) {
+	if k.Type == KeyTypeRSA {
+		if !k.Private {
+			return x509.ParsePKCS1PublicKey(k.Data)
+		}
+
+		priv, err := x509.ParsePKCS1PrivateKey(k.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		return &priv.PublicKey, nil
+	}
+
+	pub, err := k.ECDHPublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return k.ecdsaPublicKey(pub), nil
+}
+
+// ecdsaPublicKey converts the public key of the EC key.
+func (k ExternalKey) ecdsaPublicKey(pub *ecdh.PublicKey) *ecdsa.PublicKey {
+	b, size := pub.Bytes(), k.pointSize()
+
+	return &ecdsa.PublicKey{
+		Curve: curves[k.Bits],
+		X:     new(big.Int).SetBytes(b[1 : 1+size]),
+		Y:     new(big.Int).SetBytes(b[1+size:]),
+	}
+}
+
+// PrivateKey returns the private key of a private key, an *ecdsa.PrivateKey
+// or *rsa.PrivateKey.
+func (k ExternalKey) PrivateKey() (crypto.Signer, error) {
+	if k.Type == KeyTypeRSA {
+		if !k.Private {
+			return nil, errors.New("not a private key")
+		}
+
+		return x509.ParsePKCS1PrivateKey(k.Data)
+	}
+
+	priv, err := k.ECDHPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ecdsa.PrivateKey{
+		PublicKey: *k.ecdsaPublicKey(priv.PublicKey()),
+		D:         new(big.Int).SetBytes(priv.Bytes()),
+	}, nil
+}
@@ -0,0 +1,118 @@
+package keychain_test
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+)
+
+func TestExternalKeyEC(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521()} {
+		priv, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		k, err := keychain.MarshalExternalKey(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		size := (curve.Params().BitSize + 7) / 8
+		if k.Type != keychain.KeyTypeEC || k.Bits != curve.Params().BitSize || !k.Private || len(k.Data) != 1+3*size {
+			t.Fatalf("%s: unexpected external key %+v", curve.Params().Name, k)
+		}
+
+		parsed, err := keychain.ParseExternalKey(keychain.KeyTypeEC, true, k.Data)
+		if err != nil || parsed.Bits != k.Bits {
+			t.Fatalf("%s: failed to parse: %+v (%v)", curve.Params().Name, parsed, err)
+		}
+
+		signer, err := parsed.PrivateKey()
+		if err != nil || !priv.Equal(signer) {
+			t.Fatalf("%s: expected the private key back, got %v", curve.Params().Name, err)
+		}
+
+		ecdhPriv, err := parsed.ECDHPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The ECDH key converts to the same representation.
+		if k2, err := keychain.MarshalExternalKey(ecdhPriv); err != nil || string(k2.Data) != string(k.Data) {
+			t.Fatalf("%s: ECDH key converted differently: %v", curve.Params().Name, err)
+		}
+
+		pub, err := keychain.MarshalExternalKey(&priv.PublicKey)
+		if err != nil || pub.Private || len(pub.Data) != 1+2*size {
+			t.Fatalf("%s: unexpected external public key %+v (%v)", curve.Params().Name, pub, err)
+		}
+
+		if p, err := pub.PublicKey(); err != nil || !priv.PublicKey.Equal(p) {
+			t.Fatalf("%s: expected the public key back, got %v", curve.Params().Name, err)
+		}
+	}
+}
+
+func TestExternalKeyRSA(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := keychain.MarshalExternalKey(priv)
+	if err != nil || k.Type != keychain.KeyTypeRSA || k.Bits != 2048 {
+		t.Fatalf("unexpected external key %+v (%v)", k, err)
+	}
+
+	if p, err := k.PublicKey(); err != nil || !priv.PublicKey.Equal(p) {
+		t.Fatalf("expected the public key, got %v", err)
+	}
+
+	parsed, err := keychain.ParseExternalKey(keychain.KeyTypeRSA, true, k.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if signer, err := parsed.PrivateKey(); err != nil || !priv.Equal(signer) {
+		t.Fatalf("expected the private key back, got %v", err)
+	}
+}
+
+func TestExternalKeyUnsupported(t *testing.T) {
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x25519, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []any{edPub, x25519, p224, "key"} {
+		if _, err := keychain.MarshalExternalKey(key); !errors.Is(err, keychain.ErrUnsupportedKey) {
+			t.Fatalf("%T: expected ErrUnsupportedKey, got %v", key, err)
+		}
+	}
+
+	if _, err := keychain.ParseExternalKey(keychain.KeyTypeEC, false, make([]byte, 57)); !errors.Is(err, keychain.ErrUnsupportedKey) {
+		t.Fatalf("expected ErrUnsupportedKey for a P-224 point, got %v", err)
+	}
+
+	if _, err := keychain.ParseExternalKey(keychain.KeyTypeEC, false, make([]byte, 65)); err == nil {
+		t.Fatal("expected an error for a point not on the curve")
+	}
+}
@@ -0,0 +1,99 @@
+package keychain
+
+import (
+	"sync"
+	"testing"
+)
+
+// fakeCall is a Backend call recorded by fakeBackend.
+type fakeCall struct {
+	op    string
+	query map[string]interface{}
+	attrs map[string]interface{}
+}
+
+// fakeResponse is the scripted outcome of a fakeBackend call.
+type fakeResponse struct {
+	result interface{}
+	err    error
+	// wait, if not nil, blocks the call until it is closed.
+	wait chan struct{}
+}
+
+// fakeBackend is a Backend that records calls and answers them with scripted
+// responses, in order. Once the script is exhausted, calls succeed with no
+// result.
+type fakeBackend struct {
+	mu        sync.Mutex
+	calls     []fakeCall
+	responses []fakeResponse
+}
+
+// useFakeBackend replaces the Backend for the duration of the test.
+func useFakeBackend(t *testing.T, responses ...fakeResponse) *fakeBackend {
+	t.Helper()
+
+	f := &fakeBackend{responses: responses}
+	prev := SetBackend(f)
+
+	t.Cleanup(func() { SetBackend(prev) })
+
+	return f
+}
+
+// Capabilities implements CapabilityReporter, so calls aren't mixed with those
+// of the uniqueness layer.
+func (f *fakeBackend) Capabilities() Capability {
+	return Capability{UniqueKeys: true}
+}
+
+func (f *fakeBackend) record(c fakeCall) fakeResponse {
+	f.mu.Lock()
+	f.calls = append(f.calls, c)
+
+	var r fakeResponse
+	if len(f.responses) > 0 {
+		r = f.responses[0]
+		f.responses = f.responses[1:]
+	}
+	f.mu.Unlock()
+
+	if r.wait != nil {
+		<-r.wait
+	}
+
+	return r
+}
+
+// ops returns the operations called so far.
+func (f *fakeBackend) ops() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ops := make([]string, 0, len(f.calls))
+	for _, c := range f.calls {
+		ops = append(ops, c.op)
+	}
+
+	return ops
+}
+
+func (f *fakeBackend) Add(attrs map[string]interface{}) (interface{}, error) {
+	r := f.record(fakeCall{op: "add", attrs: attrs})
+
+	return r.result, r.err
+}
+
+func (f *fakeBackend) Update(query, attrs map[string]interface{}) error {
+	return f.record(fakeCall{op: "update", query: query, attrs: attrs}).err
+}
+
+func (f *fakeBackend) CopyMatching(query map[string]interface{}) (interface{}, error) {
+	r := f.record(fakeCall{op: "copyMatching", query: query})
+
+	return r.result, r.err
+}
+
+func (f *fakeBackend) Delete(query map[string]interface{}) error {
+	return f.record(fakeCall{op: "delete", query: query}).err
+}
@@ -0,0 +1,82 @@
+package keychain
+
+import "fmt"
+
+// FallbackResult is the item found by GetWithFallback.
+type FallbackResult struct {
+	QueryResult
+	// Index is the index of the query which found the item, 0 if it already
+	// has the current name.
+	Index int
+
+	secClass SecClass
+	current  Item
+}
+
+// GetWithFallback returns the attributes and data of the item found by the
+// first of queries matching one, or ErrorItemNotFound. This smooths renames
+// across releases: the first query looks the item up under its current name,
+// e.g. service, account and access group, and the following ones under the
+// names of previous releases. Call MigrateForward on the result to rename an
+// item found by a fallback query.
+func GetWithFallback(queries ...Item) (FallbackResult, error) {
+	for i, query := range queries {
+		q := query.clone()
+		q.SetMatchLimit(MatchLimitOne)
+		q.SetReturnAttributes(true)
+		q.SetReturnData(true)
+		q.SetReturnPersistentRef(true)
+
+		results, err := QueryItem(q)
+		if err != nil {
+			return FallbackResult{}, fmt.Errorf("fallback query %d failed: %w", i, err)
+		}
+
+		if len(results) == 1 {
+			return FallbackResult{QueryResult: results[0], Index: i, secClass: query.secClass(), current: queries[0]}, nil
+		}
+	}
+
+	return FallbackResult{}, ErrorItemNotFound
+}
+
+// MigrateForward renames an item found by a fallback query to the attributes
+// of the first query, so later lookups find it under its current name. It has
+// no effect on an item found by the first query.
+func (r FallbackResult) MigrateForward() error {
+	if r.Index == 0 {
+		return nil
+	}
+
+	ref, err := resultRef(r.QueryResult)
+	if err != nil {
+		return err
+	}
+
+	query := newItem()
+	if r.secClass != 0 {
+		query.SetSecClass(r.secClass)
+	}
+
+	query.SetPersistentRef(ref)
+
+	update := newItem()
+
+	for key, v := range r.current.attr {
+		switch {
+		case isControlKey(key), key == SecClassKey, key == DataKey, key == ValuePersistentRefKey:
+		default:
+			update.attr[key] = v
+		}
+	}
+
+	if len(update.attr) == 0 {
+		return nil
+	}
+
+	if err := UpdateItem(query, update); err != nil {
+		return fmt.Errorf("failed to migrate item: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package keychain_test
+
+import (
+	"errors"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func genericPasswordQuery(service, account string) keychain.Item {
+	query := keychain.NewItem()
+	query.SetSecClass(keychain.SecClassGenericPassword)
+	query.SetService(service)
+	query.SetAccount(account)
+
+	return query
+}
+
+func TestGetWithFallback(t *testing.T) {
+	keychainfake.Install(t)
+
+	if err := keychain.SetString("com.example.legacy", "token", "toomanysecrets"); err != nil {
+		t.Fatal(err)
+	}
+
+	current := genericPasswordQuery("com.example.app", "api-token")
+	legacy := genericPasswordQuery("com.example.legacy", "token")
+
+	r, err := keychain.GetWithFallback(current, legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Index != 1 || string(r.Data) != "toomanysecrets" {
+		t.Fatalf("unexpected result %+v", r)
+	}
+
+	if err := r.MigrateForward(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err = keychain.GetWithFallback(current, legacy)
+	if err != nil || r.Index != 0 || string(r.Data) != "toomanysecrets" {
+		t.Fatalf("expected the item under its current name, got %+v (%v)", r, err)
+	}
+
+	if _, err := keychain.GetString("com.example.legacy", "token"); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected the legacy item to be renamed, got %v", err)
+	}
+
+	if _, err := keychain.GetWithFallback(legacy); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound, got %v", err)
+	}
+
+	// An item without a persistent reference isn't renamed, as the update
+	// would match the whole class.
+	noRef := keychain.FallbackResult{QueryResult: keychain.QueryResult{Account: "token"}, Index: 1}
+	if err := noRef.MigrateForward(); !errors.Is(err, keychain.ErrNoPersistentRef) {
+		t.Fatalf("expected ErrNoPersistentRef, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package keychain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Feature is a Security framework feature only available on recent OS
+// versions.
+type Feature int
+
+const (
+	// FeatureDataProtectionKeychain is kSecUseDataProtectionKeychain, see
+	// Item.SetUseDataProtectionKeychain (macOS 10.15, iOS 13).
+	FeatureDataProtectionKeychain Feature = iota + 1
+	// FeatureSecureEnclaveKeys is SecKeyCreateRandomKey, used to create keys
+	// in the Secure Enclave (macOS 10.12, iOS 10).
+	FeatureSecureEnclaveKeys
+	// FeatureCompanionApproval is AccessControlCompanion, approving access
+	// from a paired Apple Watch (kSecAccessControlWatch on macOS 10.15,
+	// kSecAccessControlCompanion on iOS 18).
+	FeatureCompanionApproval
+)
+
+// featureVersions are the OS versions required by each Feature.
+var featureVersions = map[Feature]struct{ name, macOS, iOS string }{
+	FeatureDataProtectionKeychain: {"data protection keychain", "10.15", "13.0"},
+	FeatureSecureEnclaveKeys:      {"Secure Enclave keys", "10.12", "10.0"},
+	FeatureCompanionApproval:      {"companion device approval", "10.15", "18.0"},
+}
+
+// ErrUnsupportedOSVersion is matched (with errors.Is) by OSVersionError.
+var ErrUnsupportedOSVersion = errors.New("unsupported OS version")
+
+// OSVersionError is returned for operations using a Feature that the running
+// OS doesn't provide, instead of the errSecParam the Security framework
+// returns for unknown attributes.
+type OSVersionError struct {
+	Feature Feature
+	// MacOS and IOS are the versions providing the feature.
+	MacOS string
+	IOS   string
+}
+
+func (e *OSVersionError) Error() string {
+	return fmt.Sprintf("%s: %s requires macOS %s or iOS %s", ErrUnsupportedOSVersion,
+		featureVersions[e.Feature].name, e.MacOS, e.IOS)
+}
+
+// Is reports whether target is ErrUnsupportedOSVersion.
+func (e *OSVersionError) Is(target error) bool {
+	return target == ErrUnsupportedOSVersion //nolint:errorlint
+}
+
+// CheckFeature returns an *OSVersionError if f is not available on the running
+// OS. Availability is detected from the weakly linked symbols of the Security
+// framework, or from the OS version for flags. Elsewhere, the features are
+// unavailable.
+func CheckFeature(f Feature) error {
+	v, ok := featureVersions[f]
+	if !ok {
+		return fmt.Errorf("unknown feature %d", f)
+	}
+
+	if !featureAvailable(f) {
+		return &OSVersionError{Feature: f, MacOS: v.macOS, IOS: v.iOS}
+	}
+
+	return nil
+}
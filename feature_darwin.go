@@ -0,0 +1,58 @@
+//go:build darwin
+// +build darwin
+
+package keychain
+
+/*
+#cgo LDFLAGS: -framework Security
+
+#include <Security/Security.h>
+
+// Symbols introduced after the deployment target are weakly linked and NULL
+// on older systems.
+static int hasDataProtectionKeychain(void) {
+	return &kSecUseDataProtectionKeychain != NULL;
+}
+
+static int hasSecKeyCreateRandomKey(void) {
+	return &SecKeyCreateRandomKey != NULL;
+}
+*/
+import "C"
+
+import "runtime"
+
+func featureAvailable(f Feature) bool {
+	switch f {
+	case FeatureDataProtectionKeychain:
+		return C.hasDataProtectionKeychain() != 0 // nolint: nlreturn
+	case FeatureSecureEnclaveKeys:
+		return C.hasSecKeyCreateRandomKey() != 0 // nolint: nlreturn
+	case FeatureCompanionApproval:
+		// The flag is an enum value, not a symbol.
+		major, minor := osVersion()
+		if runtime.GOOS == "ios" {
+			return major >= 18
+		}
+
+		return major > 10 || major == 10 && minor >= 15
+	}
+
+	return false
+}
+
+// checkFeatures returns an *OSVersionError if attrs use a feature the running
+// OS doesn't provide.
+func checkFeatures(attrs map[string]interface{}) error {
+	if _, ok := attrs[UseDataProtectionKeychainKey]; ok {
+		if err := CheckFeature(FeatureDataProtectionKeychain); err != nil {
+			return err
+		}
+	}
+
+	if ac, ok := attrs[AccessControlKey].(AccessControl); ok && ac.Flags&AccessControlCompanion != 0 {
+		return CheckFeature(FeatureCompanionApproval)
+	}
+
+	return nil
+}
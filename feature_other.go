@@ -0,0 +1,8 @@
+//go:build !darwin
+// +build !darwin
+
+package keychain
+
+func featureAvailable(Feature) bool {
+	return false
+}
@@ -0,0 +1,33 @@
+package keychain
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCheckFeature(t *testing.T) {
+	err := CheckFeature(FeatureDataProtectionKeychain)
+
+	switch runtime.GOOS {
+	case "darwin", "ios":
+		// Test machines run recent versions.
+		if err != nil {
+			t.Fatal(err)
+		}
+	default:
+		var versionErr *OSVersionError
+		if !errors.As(err, &versionErr) || !errors.Is(err, ErrUnsupportedOSVersion) {
+			t.Fatalf("expected OSVersionError, got %v", err)
+		}
+
+		if versionErr.MacOS != "10.15" || !strings.Contains(err.Error(), "requires macOS 10.15 or iOS 13.0") {
+			t.Fatalf("unexpected error %q", err)
+		}
+	}
+
+	if err := CheckFeature(Feature(0)); err == nil || errors.Is(err, ErrUnsupportedOSVersion) {
+		t.Fatalf("expected unknown feature error, got %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package keychain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Flags is a store of typed configuration values kept in the keychain, for
+// sensitive runtime settings such as proxy credentials or telemetry keys that
+// shouldn't be written to plain files. Each flag is a generic password of the
+// service, with the flag name as account and the value as text. The getters
+// return the default of a flag which isn't set.
+type Flags struct {
+	service string
+}
+
+// NewFlags returns the flags stored in the generic passwords for service.
+func NewFlags(service string) *Flags {
+	return &Flags{service: service}
+}
+
+// get returns the value of the flag, and whether it is set.
+func (f *Flags) get(name string) (string, bool, error) {
+	s, err := GetString(f.service, name)
+	if errors.Is(err, ErrorItemNotFound) {
+		return "", false, nil
+	}
+
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read flag %q: %w", name, err)
+	}
+
+	return s, true, nil
+}
+
+func (f *Flags) set(name, s string) error {
+	if err := SetString(f.service, name, s); err != nil {
+		return fmt.Errorf("failed to write flag %q: %w", name, err)
+	}
+
+	notifyChange(f.service, name)
+
+	return nil
+}
+
+// GetString returns the value of the flag, or def.
+func (f *Flags) GetString(name, def string) (string, error) {
+	s, ok, err := f.get(name)
+	if err != nil || !ok {
+		return def, err
+	}
+
+	return s, nil
+}
+
+// SetString sets the flag.
+func (f *Flags) SetString(name, v string) error {
+	return f.set(name, v)
+}
+
+// GetBool returns the value of the flag, or def.
+func (f *Flags) GetBool(name string, def bool) (bool, error) {
+	s, ok, err := f.get(name)
+	if err != nil || !ok {
+		return def, err
+	}
+
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return def, fmt.Errorf("invalid flag %q: %w", name, err)
+	}
+
+	return v, nil
+}
+
+// SetBool sets the flag.
+func (f *Flags) SetBool(name string, v bool) error {
+	return f.set(name, strconv.FormatBool(v))
+}
+
+// GetInt returns the value of the flag, or def.
+func (f *Flags) GetInt(name string, def int64) (int64, error) {
+	s, ok, err := f.get(name)
+	if err != nil || !ok {
+		return def, err
+	}
+
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return def, fmt.Errorf("invalid flag %q: %w", name, err)
+	}
+
+	return v, nil
+}
+
+// SetInt sets the flag.
+func (f *Flags) SetInt(name string, v int64) error {
+	return f.set(name, strconv.FormatInt(v, 10))
+}
+
+// GetDuration returns the value of the flag, or def.
+func (f *Flags) GetDuration(name string, def time.Duration) (time.Duration, error) {
+	s, ok, err := f.get(name)
+	if err != nil || !ok {
+		return def, err
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return def, fmt.Errorf("invalid flag %q: %w", name, err)
+	}
+
+	return v, nil
+}
+
+// SetDuration sets the flag.
+func (f *Flags) SetDuration(name string, v time.Duration) error {
+	return f.set(name, v.String())
+}
+
+// Unset removes the flag, so its default applies again.
+func (f *Flags) Unset(name string) error {
+	err := DeleteGenericPasswordItem(f.service, name)
+	if err != nil && !errors.Is(err, ErrorItemNotFound) {
+		return fmt.Errorf("failed to remove flag %q: %w", name, err)
+	}
+
+	notifyChange(f.service, name)
+
+	return nil
+}
+
+// Watch reports changes of the flag, by this or other processes, see
+// WatchItem. The results carry the new value as Data, or nil Data once the
+// flag is unset. Call cancel to stop watching.
+func (f *Flags) Watch(name string) (<-chan QueryResult, func()) {
+	return WatchItem(f.service, name)
+}
@@ -0,0 +1,37 @@
+package keychain
+
+import (
+	"fmt"
+)
+
+// FourCharCode converts a four character code such as "note" to its numeric
+// value, as used by the type and creator attributes.
+func FourCharCode(s string) (uint32, error) {
+	if len(s) != 4 {
+		return 0, fmt.Errorf("invalid four character code %q: must be 4 bytes", s)
+	}
+
+	var v uint32
+	for i := 0; i < 4; i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			return 0, fmt.Errorf("invalid four character code %q: must be printable ASCII", s)
+		}
+
+		v = v<<8 | uint32(s[i])
+	}
+
+	return v, nil
+}
+
+// FourCharCodeString converts a numeric four character code to its string
+// form. Codes with non printable bytes are formatted as hex.
+func FourCharCodeString(v uint32) string {
+	b := []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+	for _, c := range b {
+		if c < 0x20 || c > 0x7e {
+			return fmt.Sprintf("0x%08x", v)
+		}
+	}
+
+	return string(b)
+}
@@ -0,0 +1,28 @@
+package keychain
+
+import (
+	"testing"
+)
+
+func TestFourCharCode(t *testing.T) {
+	v, err := FourCharCode("note")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != 0x6e6f7465 {
+		t.Fatalf("unexpected code 0x%08x", v)
+	}
+	if s := FourCharCodeString(v); s != "note" {
+		t.Fatalf("unexpected string %q", s)
+	}
+
+	for _, invalid := range []string{"", "abc", "abcde", "ab\x00c"} {
+		if _, err := FourCharCode(invalid); err == nil {
+			t.Errorf("expected %q to be invalid", invalid)
+		}
+	}
+
+	if s := FourCharCodeString(1); s != "0x00000001" {
+		t.Fatalf("unexpected string %q", s)
+	}
+}
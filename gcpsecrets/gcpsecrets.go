@@ -0,0 +1,333 @@
+// Package gcpsecrets implements a keychain backend storing generic passwords
+// in Google Cloud Secret Manager, so the same credential code runs on
+// developer Macs and cloud instances.
+//
+// Each item is a secret named after its service and account, whose latest
+// version holds the item data. Secret Manager labels only allow lowercase
+// values, so the keychain-class label marks the secrets of the backend and the
+// service, account, label, description and comment are annotations
+// (keychain-service, keychain-account, ...).
+//
+// Importing the package registers the backend, configured from the
+// environment (see ConfigFromEnv):
+//
+//	import _ "github.com/mailstone/go-keychain/gcpsecrets"
+//
+//	err := keychain.UseBackend(gcpsecrets.Name)
+package gcpsecrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/internal/cloudsecret"
+)
+
+// Name is the name the backend is registered under, see keychain.UseBackend.
+const Name = "gcp-secretmanager"
+
+func init() {
+	keychain.RegisterBackend(Name, func() (keychain.Backend, error) {
+		return Open(ConfigFromEnv())
+	})
+}
+
+// metadataTokenURL is the token endpoint of the metadata server of Google
+// Cloud instances.
+const metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// Config configures the connection to Secret Manager.
+type Config struct {
+	// Project is the Google Cloud project ID.
+	Project string
+	// Token returns an OAuth 2 access token with the cloud-platform scope.
+	// It is called for every request, so it can refresh the token. It
+	// defaults to MetadataToken.
+	Token func() (string, error)
+	// Prefix is prepended to secret IDs, e.g. "myapp-".
+	Prefix string
+	// Endpoint overrides the Secret Manager endpoint.
+	Endpoint string
+	// HTTPClient is the client used for requests. It defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// ConfigFromEnv returns a Config from the GOOGLE_CLOUD_PROJECT,
+// GOOGLE_OAUTH_ACCESS_TOKEN and KEYCHAIN_SECRET_PREFIX environment variables.
+// Without an access token, tokens are requested from the metadata server.
+func ConfigFromEnv() Config {
+	cfg := Config{Project: os.Getenv("GOOGLE_CLOUD_PROJECT"), Prefix: os.Getenv("KEYCHAIN_SECRET_PREFIX")}
+
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		cfg.Token = func() (string, error) { return token, nil }
+	}
+
+	return cfg
+}
+
+// MetadataToken returns an access token for the default service account of
+// the Google Cloud instance it runs on.
+func MetadataToken() (string, error) {
+	req, err := http.NewRequest(http.MethodGet, metadataTokenURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get token from the metadata server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get token from the metadata server: %s", resp.Status)
+	}
+
+	var out struct {
+		AccessToken string `json:"access_token"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("failed to decode token: %w", err)
+	}
+
+	return out.AccessToken, nil
+}
+
+// Open returns a backend for Secret Manager configured by cfg. No request is
+// made until the backend is used.
+func Open(cfg Config) (keychain.Backend, error) {
+	if cfg.Project == "" {
+		return nil, errors.New("google cloud project is not set")
+	}
+
+	if cfg.Token == nil {
+		cfg.Token = MetadataToken
+	}
+
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://secretmanager.googleapis.com"
+	}
+
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+
+	return cloudsecret.New(&provider{cfg: cfg}, cfg.Prefix), nil
+}
+
+// provider is a cloudsecret.Provider for the Secret Manager REST API.
+type provider struct {
+	cfg Config
+}
+
+type secret struct {
+	Name        string            `json:"name,omitempty"`
+	Replication interface{}       `json:"replication,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+func (p *provider) secretURL(name string) string {
+	return p.cfg.Endpoint + "/v1/projects/" + url.PathEscape(p.cfg.Project) + "/secrets/" + url.PathEscape(name)
+}
+
+// call makes a request to the Secret Manager API.
+func (p *provider) call(method, u string, in, out interface{}) error {
+	var body io.Reader
+
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode secret manager request: %w", err)
+		}
+
+		body = bytes.NewReader(b)
+	}
+
+	token, err := p.cfg.Token()
+	if err != nil {
+		return fmt.Errorf("failed to get google cloud access token: %w", err)
+	}
+
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return fmt.Errorf("failed to create secret manager request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("secret manager %s failed: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return cloudsecret.ErrNotFound
+	case http.StatusConflict:
+		return cloudsecret.ErrExists
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("secret manager %s: %s: %w", method, resp.Status, keychain.ErrorAuthFailed)
+	default:
+		var e struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+
+		return fmt.Errorf("secret manager %s: %s: %s", method, resp.Status, e.Error.Message)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode secret manager response: %w", err)
+	}
+
+	return nil
+}
+
+// split returns the labels and annotations for labels.
+func split(l map[string]string) (map[string]string, map[string]string) {
+	annotations := make(map[string]string, len(l))
+	for key, value := range l {
+		if key != cloudsecret.ClassLabel {
+			annotations[key] = value
+		}
+	}
+
+	return map[string]string{cloudsecret.ClassLabel: l[cloudsecret.ClassLabel]}, annotations
+}
+
+// join returns the labels of s.
+func join(s secret) map[string]string {
+	l := make(map[string]string, len(s.Annotations)+1)
+	for key, value := range s.Annotations {
+		l[key] = value
+	}
+
+	l[cloudsecret.ClassLabel] = s.Labels[cloudsecret.ClassLabel]
+
+	return l
+}
+
+func (p *provider) addVersion(name string, data []byte) error {
+	in := map[string]interface{}{"payload": map[string][]byte{"data": data}}
+
+	return p.call(http.MethodPost, p.secretURL(name)+":addVersion", in, nil)
+}
+
+func (p *provider) Create(s cloudsecret.Secret) error {
+	labels, annotations := split(s.Labels)
+	in := secret{
+		Replication: map[string]interface{}{"automatic": map[string]interface{}{}},
+		Labels:      labels,
+		Annotations: annotations,
+	}
+
+	u := p.cfg.Endpoint + "/v1/projects/" + url.PathEscape(p.cfg.Project) + "/secrets?secretId=" + url.QueryEscape(s.Name)
+	if err := p.call(http.MethodPost, u, in, nil); err != nil {
+		return err
+	}
+
+	if err := p.addVersion(s.Name, s.Data); err != nil {
+		// Don't leave a secret without a version behind.
+		_ = p.Delete(s.Name)
+
+		return err
+	}
+
+	return nil
+}
+
+func (p *provider) Get(name string) (cloudsecret.Secret, error) {
+	var s secret
+	if err := p.call(http.MethodGet, p.secretURL(name), nil, &s); err != nil {
+		return cloudsecret.Secret{}, err
+	}
+
+	var version struct {
+		Payload struct {
+			Data []byte `json:"data"`
+		} `json:"payload"`
+	}
+
+	if err := p.call(http.MethodGet, p.secretURL(name)+"/versions/latest:access", nil, &version); err != nil {
+		return cloudsecret.Secret{}, err
+	}
+
+	data := version.Payload.Data
+	if data == nil {
+		data = []byte{}
+	}
+
+	return cloudsecret.Secret{Name: name, Labels: join(s), Data: data}, nil
+}
+
+func (p *provider) Update(name string, data []byte, l map[string]string) error {
+	labels, annotations := split(l)
+	in := secret{Labels: labels, Annotations: annotations}
+
+	if err := p.call(http.MethodPatch, p.secretURL(name)+"?updateMask=labels,annotations", in, nil); err != nil {
+		return err
+	}
+
+	if data == nil {
+		return nil
+	}
+
+	return p.addVersion(name, data)
+}
+
+func (p *provider) Delete(name string) error {
+	return p.call(http.MethodDelete, p.secretURL(name), nil, nil)
+}
+
+func (p *provider) List() ([]cloudsecret.Secret, error) {
+	var secrets []cloudsecret.Secret
+
+	query := url.Values{"filter": {"labels." + cloudsecret.ClassLabel + ":*"}, "pageSize": {"100"}}
+
+	for {
+		var out struct {
+			Secrets       []secret `json:"secrets"`
+			NextPageToken string   `json:"nextPageToken"`
+		}
+
+		u := p.cfg.Endpoint + "/v1/projects/" + url.PathEscape(p.cfg.Project) + "/secrets?" + query.Encode()
+		if err := p.call(http.MethodGet, u, nil, &out); err != nil {
+			return nil, err
+		}
+
+		for _, s := range out.Secrets {
+			// Names are projects/<project>/secrets/<id>.
+			id := s.Name[strings.LastIndex(s.Name, "/")+1:]
+			secrets = append(secrets, cloudsecret.Secret{Name: id, Labels: join(s)})
+		}
+
+		if out.NextPageToken == "" {
+			return secrets, nil
+		}
+
+		query.Set("pageToken", out.NextPageToken)
+	}
+}
@@ -0,0 +1,137 @@
+package gcpsecrets_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/gcpsecrets"
+)
+
+// fakeSecretManager is a minimal Secret Manager for project p.
+type fakeSecretManager struct {
+	mu       sync.Mutex
+	secrets  map[string]map[string]interface{}
+	versions map[string][]byte
+}
+
+func (f *fakeSecretManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+
+		return
+	}
+
+	const prefix = "/v1/projects/p/secrets"
+
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, prefix), "/")
+
+	var in map[string]interface{}
+	_ = json.NewDecoder(r.Body).Decode(&in)
+
+	switch {
+	case r.Method == http.MethodPost && id == "":
+		id = r.URL.Query().Get("secretId")
+		if _, ok := f.secrets[id]; ok {
+			w.WriteHeader(http.StatusConflict)
+
+			return
+		}
+
+		in["name"] = "projects/p/secrets/" + id
+		f.secrets[id] = in
+	case r.Method == http.MethodGet && id == "":
+		secrets := make([]interface{}, 0, len(f.secrets))
+		for _, s := range f.secrets {
+			secrets = append(secrets, s)
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"secrets": secrets})
+	case r.Method == http.MethodPost && strings.HasSuffix(id, ":addVersion"):
+		data, _ := in["payload"].(map[string]interface{})["data"].(string)
+		f.versions[strings.TrimSuffix(id, ":addVersion")] = []byte(data)
+	case r.Method == http.MethodGet && strings.HasSuffix(id, "/versions/latest:access"):
+		data, ok := f.versions[strings.TrimSuffix(id, "/versions/latest:access")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"payload": map[string]string{"data": string(data)}})
+	case f.secrets[id] == nil:
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode(f.secrets[id])
+	case r.Method == http.MethodDelete:
+		delete(f.secrets, id)
+		delete(f.versions, id)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestSecretManager(t *testing.T) {
+	f := &fakeSecretManager{secrets: map[string]map[string]interface{}{}, versions: map[string][]byte{}}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	b, err := gcpsecrets.Open(gcpsecrets.Config{
+		Project:  "p",
+		Token:    func() (string, error) { return "token", nil },
+		Endpoint: srv.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prev := keychain.SetBackend(b)
+	defer keychain.SetBackend(prev)
+
+	if err := keychain.AddItem(keychain.NewGenericPassword("My App", "alice", "", []byte("secret"), "")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range f.secrets {
+		labels, _ := s["labels"].(map[string]interface{})
+		annotations, _ := s["annotations"].(map[string]interface{})
+
+		if len(labels) != 1 || labels["keychain-class"] != "genp" || annotations["keychain-service"] != "My App" {
+			t.Fatalf("unexpected labels %v and annotations %v", labels, annotations)
+		}
+	}
+
+	accounts, err := keychain.GetGenericPasswordAccounts("My App")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(accounts) != 1 || accounts[0] != "alice" {
+		t.Fatalf("unexpected accounts %v", accounts)
+	}
+
+	data, err := keychain.GetGenericPassword("My App", "alice", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, []byte("secret")) {
+		t.Fatalf("expected secret, got %q", data)
+	}
+
+	if err := keychain.DeleteGenericPasswordItem("My App", "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.secrets) != 0 {
+		t.Fatalf("expected the secret to be deleted, got %v", f.secrets)
+	}
+}
@@ -0,0 +1,548 @@
+// Package cloudsecret implements keychain backends over secret managers: the
+// cloud secret managers, the Secret Service on Linux and the Windows
+// Credential Manager. Each generic password is a secret named after its service and
+// account (see SecretName), with its attributes as labels so items can be
+// listed and searched without reading their data. The generic attribute,
+// which holds the item metadata, is stored base64-encoded across up to
+// GenericLabels labels.
+package cloudsecret
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	keychain "github.com/mailstone/go-keychain"
+)
+
+// ErrNotFound is returned by a Provider for secrets that don't exist.
+var ErrNotFound = errors.New("secret not found")
+
+// ErrExists is returned by a Provider creating a secret that already exists.
+var ErrExists = errors.New("secret already exists")
+
+// MaxDataSize is the size limit of secrets, which is 64 KiB for both AWS
+// Secrets Manager and Google Cloud Secret Manager.
+const MaxDataSize = 64 << 10
+
+// ClassLabel marks the secrets managed by the backend. Its value is the item
+// class, which is always "genp".
+const ClassLabel = "keychain-class"
+
+// GenericLabel is the first label key of the base64-encoded generic
+// attribute. The rest of the encoding continues in GenericLabel-1,
+// GenericLabel-2 and so on.
+const GenericLabel = "keychain-generic"
+
+// MaxLabelSize is the size limit of label values, which is 256 characters for
+// both AWS Secrets Manager tags and Windows Credential Manager attributes.
+const MaxLabelSize = 256
+
+// GenericLabels is the number of labels the generic attribute may span.
+const GenericLabels = 8
+
+// MaxGenericSize is the size limit of the generic attribute, so that its
+// base64 encoding fits GenericLabels labels.
+const MaxGenericSize = GenericLabels * MaxLabelSize / 4 * 3
+
+// attrLabels maps the stored item attributes to label keys.
+var attrLabels = map[string]string{
+	keychain.ServiceKey:     "keychain-service",
+	keychain.AccountKey:     "keychain-account",
+	keychain.LabelKey:       "keychain-label",
+	keychain.DescriptionKey: "keychain-description",
+	keychain.CommentKey:     "keychain-comment",
+}
+
+// Secret is a secret in a secret manager.
+type Secret struct {
+	Name   string
+	Labels map[string]string
+	// Data is the value of the latest version of the secret. It is not set
+	// for secrets returned by Provider.List.
+	Data []byte
+}
+
+// Provider is the API of a secret manager.
+type Provider interface {
+	// Create creates a secret, or returns ErrExists.
+	Create(s Secret) error
+	// Get returns a secret with its data, or ErrNotFound.
+	Get(name string) (Secret, error)
+	// Update replaces the labels of a secret and, unless data is nil, adds a
+	// version with data. It returns ErrNotFound if the secret doesn't
+	// exist.
+	Update(name string, data []byte, labels map[string]string) error
+	// Delete deletes a secret immediately, or returns ErrNotFound.
+	Delete(name string) error
+	// List returns the secrets with ClassLabel, without their data.
+	List() ([]Secret, error)
+}
+
+// Backend is a keychain.Backend storing generic passwords with a Provider.
+type Backend struct {
+	p           Provider
+	prefix      string
+	maxDataSize int
+}
+
+// New returns a backend storing items with p, in secrets whose names start
+// with prefix.
+func New(p Provider, prefix string) *Backend {
+	return &Backend{p: p, prefix: prefix, maxDataSize: MaxDataSize}
+}
+
+// SetMaxDataSize sets the size limit of secrets reported by Capabilities, for
+// providers with another limit than MaxDataSize.
+func (b *Backend) SetMaxDataSize(n int) {
+	b.maxDataSize = n
+}
+
+// sanitize replaces the characters not valid in secret names of all providers
+// and truncates s.
+func sanitize(s string) string {
+	const maxLen = 100
+
+	b := []byte(s)
+	if len(b) > maxLen {
+		b = b[:maxLen]
+	}
+
+	for i, c := range b {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '-') {
+			b[i] = '_'
+		}
+	}
+
+	return string(b)
+}
+
+// SecretName returns the name of the secret holding the item for service and
+// account: the sanitized service and account, for readability, and a hash of
+// both so different items never share a name.
+func SecretName(prefix, service, account string) string {
+	h := sha256.Sum256([]byte(service + "\x00" + account))
+
+	return prefix + sanitize(service) + "-" + sanitize(account) + "-" + hex.EncodeToString(h[:4])
+}
+
+// platformKeys are the attributes relying on the Security framework.
+var platformKeys = []string{
+	keychain.AccessControlKey,
+	keychain.UseAuthenticationContextKey,
+	keychain.UseKeychainKey,
+	keychain.MatchSearchListKey,
+}
+
+// check returns an error for attributes the backend can't handle.
+func check(attrs map[string]interface{}) error {
+	if attrs[keychain.SecClassKey] != "genp" {
+		return fmt.Errorf("secret managers only store generic passwords: %w", keychain.ErrorUnimplemented)
+	}
+
+	if ref, _ := attrs[keychain.ReturnPersistentRefKey].(bool); ref || attrs[keychain.ValuePersistentRefKey] != nil ||
+		attrs[keychain.MatchItemListKey] != nil {
+		return fmt.Errorf("secret managers have no persistent references: %w", keychain.ErrorUnimplemented)
+	}
+
+	for _, key := range platformKeys {
+		if _, ok := attrs[key]; ok {
+			return fmt.Errorf("attribute %s is only available on Apple platforms: %w", key, keychain.ErrorUnimplemented)
+		}
+	}
+
+	return nil
+}
+
+// checkGeneric returns an error if the generic attribute of attrs doesn't
+// fit its labels.
+func checkGeneric(attrs map[string]interface{}) error {
+	if g, _ := attrs[keychain.GenericKey].([]byte); len(g) > MaxGenericSize {
+		return fmt.Errorf("generic attribute of %d bytes exceeds the %d bytes stored in labels: %w",
+			len(g), MaxGenericSize, keychain.ErrorParam)
+	}
+
+	return nil
+}
+
+// genericLabel returns the key of the i-th label of the generic attribute.
+func genericLabel(i int) string {
+	if i == 0 {
+		return GenericLabel
+	}
+
+	return fmt.Sprintf("%s-%d", GenericLabel, i)
+}
+
+// encodedGeneric returns the base64-encoded generic attribute in l.
+func encodedGeneric(l map[string]string) string {
+	var b strings.Builder
+
+	for i := 0; i < GenericLabels; i++ {
+		part, ok := l[genericLabel(i)]
+		if !ok {
+			break
+		}
+
+		b.WriteString(part)
+	}
+
+	return b.String()
+}
+
+// ignoredKeys are the attributes of queries the backend ignores, since all its
+// items share them.
+var ignoredKeys = map[string]bool{
+	keychain.SecClassKey:                  true,
+	keychain.AccessGroupKey:               true,
+	keychain.SynchronizableKey:            true,
+	keychain.AccessibleKey:                true,
+	keychain.UseDataProtectionKeychainKey: true,
+}
+
+// checkQuery returns an error for queries matching attributes the backend
+// doesn't store, which would otherwise match every item.
+func checkQuery(query map[string]interface{}) error {
+	for key, v := range query {
+		if _, ok := attrLabels[key]; ok || key == keychain.GenericKey || ignoredKeys[key] ||
+			strings.HasPrefix(key, "m_") || strings.HasPrefix(key, "r_") || strings.HasPrefix(key, "u_") {
+			continue
+		}
+
+		if s, ok := v.(string); ok && s == "" {
+			continue
+		}
+
+		return fmt.Errorf("secret managers can't match attribute %s: %w", key, keychain.ErrorUnimplemented)
+	}
+
+	return nil
+}
+
+// labels returns the labels for attrs.
+func labels(attrs map[string]interface{}) map[string]string {
+	l := map[string]string{ClassLabel: "genp"}
+
+	for key, label := range attrLabels {
+		if s, _ := attrs[key].(string); s != "" {
+			l[label] = s
+		}
+	}
+
+	g, _ := attrs[keychain.GenericKey].([]byte)
+	enc := base64.StdEncoding.EncodeToString(g)
+
+	for i := 0; enc != ""; i++ {
+		n := min(len(enc), MaxLabelSize)
+		l[genericLabel(i)] = enc[:n]
+		enc = enc[n:]
+	}
+
+	return l
+}
+
+// attributes returns the item attributes stored in the labels of s.
+func attributes(s Secret) map[string]interface{} {
+	attrs := make(map[string]interface{})
+
+	for key, label := range attrLabels {
+		if v, ok := s.Labels[label]; ok {
+			attrs[key] = v
+		}
+	}
+
+	if g, err := base64.StdEncoding.DecodeString(encodedGeneric(s.Labels)); err == nil && len(g) > 0 {
+		attrs[keychain.GenericKey] = g
+	}
+
+	return attrs
+}
+
+// matches returns whether the labels of s match the attributes in query.
+func matches(s Secret, query map[string]interface{}) bool {
+	for key, label := range attrLabels {
+		if want, ok := query[key].(string); ok && s.Labels[label] != want {
+			return false
+		}
+	}
+
+	if want, ok := query[keychain.GenericKey].([]byte); ok && encodedGeneric(s.Labels) != base64.StdEncoding.EncodeToString(want) {
+		return false
+	}
+
+	return true
+}
+
+// find returns the secrets matching query, sorted by name, or
+// ErrorItemNotFound. Secrets are returned with their data only if the query
+// identifies a single item.
+func (b *Backend) find(query map[string]interface{}) ([]Secret, error) {
+	service, hasService := query[keychain.ServiceKey].(string)
+	account, hasAccount := query[keychain.AccountKey].(string)
+
+	if hasService && hasAccount {
+		s, err := b.p.Get(SecretName(b.prefix, service, account))
+		if errors.Is(err, ErrNotFound) || err == nil && !matches(s, query) {
+			return nil, keychain.ErrorItemNotFound
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return []Secret{s}, nil
+	}
+
+	secrets, err := b.p.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var found []Secret
+
+	for _, s := range secrets {
+		if strings.HasPrefix(s.Name, b.prefix) && matches(s, query) {
+			found = append(found, s)
+		}
+	}
+
+	if len(found) == 0 {
+		return nil, keychain.ErrorItemNotFound
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].Name < found[j].Name })
+
+	return found, nil
+}
+
+// data returns the data of s, reading it if s was listed.
+func (b *Backend) data(s Secret) ([]byte, error) {
+	if s.Data != nil {
+		return s.Data, nil
+	}
+
+	full, err := b.p.Get(s.Name)
+	if errors.Is(err, ErrNotFound) {
+		return nil, keychain.ErrorItemNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return full.Data, nil
+}
+
+// result shapes the result for s as requested by query.
+func (b *Backend) result(s Secret, query map[string]interface{}) (interface{}, error) {
+	wantAttrs, _ := query[keychain.ReturnAttributesKey].(bool)
+	wantData, _ := query[keychain.ReturnDataKey].(bool)
+
+	var data []byte
+
+	if wantData {
+		var err error
+		if data, err = b.data(s); err != nil {
+			return nil, err
+		}
+	}
+
+	if !wantAttrs && wantData {
+		return data, nil
+	}
+
+	d := make(map[string]interface{})
+
+	if wantAttrs {
+		d = attributes(s)
+		d[keychain.SecClassKey] = "genp"
+	}
+
+	if wantData {
+		d[keychain.DataKey] = data
+	}
+
+	return d, nil
+}
+
+// Capabilities implements keychain.CapabilityReporter. Items are unique by
+// service and account, regardless of access group.
+func (b *Backend) Capabilities() keychain.Capability {
+	return keychain.Capability{UniqueKeys: true, MaxDataSize: b.maxDataSize, MaxGenericSize: MaxGenericSize}
+}
+
+// Add implements keychain.Backend.
+func (b *Backend) Add(attrs map[string]interface{}) (interface{}, error) {
+	if err := check(attrs); err != nil {
+		return nil, err
+	}
+
+	if err := checkGeneric(attrs); err != nil {
+		return nil, err
+	}
+
+	service, _ := attrs[keychain.ServiceKey].(string)
+	account, _ := attrs[keychain.AccountKey].(string)
+	data, _ := attrs[keychain.DataKey].([]byte)
+
+	s := Secret{Name: SecretName(b.prefix, service, account), Labels: labels(attrs), Data: append([]byte{}, data...)}
+
+	err := b.p.Create(s)
+	if errors.Is(err, ErrExists) {
+		return nil, keychain.ErrorDuplicateItem
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !keychain.WantsResult(attrs) {
+		return nil, nil
+	}
+
+	return b.result(s, attrs)
+}
+
+// Update implements keychain.Backend. Changing the service or account of an
+// item moves it to a new secret, losing the history of the old one.
+func (b *Backend) Update(query, attrs map[string]interface{}) error {
+	if err := check(query); err != nil {
+		return err
+	}
+
+	if err := checkQuery(query); err != nil {
+		return err
+	}
+
+	if err := checkGeneric(attrs); err != nil {
+		return err
+	}
+
+	found, err := b.find(query)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range found {
+		merged := attributes(s)
+
+		for key := range attrLabels {
+			if v, ok := attrs[key]; ok {
+				merged[key] = v
+			}
+		}
+
+		if v, ok := attrs[keychain.GenericKey]; ok {
+			merged[keychain.GenericKey] = v
+		}
+
+		service, _ := merged[keychain.ServiceKey].(string)
+		account, _ := merged[keychain.AccountKey].(string)
+		name := SecretName(b.prefix, service, account)
+		data, _ := attrs[keychain.DataKey].([]byte)
+
+		if name == s.Name {
+			err = b.p.Update(name, data, labels(merged))
+		} else {
+			err = b.move(s, Secret{Name: name, Labels: labels(merged), Data: data})
+		}
+
+		if errors.Is(err, ErrNotFound) {
+			return keychain.ErrorItemNotFound
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// move replaces from with to, keeping the data of from unless to has data.
+func (b *Backend) move(from, to Secret) error {
+	if to.Data == nil {
+		data, err := b.data(from)
+		if err != nil {
+			return err
+		}
+
+		to.Data = data
+	}
+
+	err := b.p.Create(to)
+	if errors.Is(err, ErrExists) {
+		return keychain.ErrorDuplicateItem
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return b.p.Delete(from.Name)
+}
+
+// CopyMatching implements keychain.Backend.
+func (b *Backend) CopyMatching(query map[string]interface{}) (interface{}, error) {
+	if err := check(query); err != nil {
+		return nil, err
+	}
+
+	if err := checkQuery(query); err != nil {
+		return nil, err
+	}
+
+	found, err := b.find(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if !keychain.WantsResult(query) {
+		return nil, nil
+	}
+
+	if query[keychain.MatchLimitKey] != "m_LimitAll" {
+		return b.result(found[0], query)
+	}
+
+	results := make([]interface{}, 0, len(found))
+
+	for _, s := range found {
+		r, err := b.result(s, query)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, r)
+	}
+
+	return results, nil
+}
+
+// Delete implements keychain.Backend.
+func (b *Backend) Delete(query map[string]interface{}) error {
+	if err := check(query); err != nil {
+		return err
+	}
+
+	if err := checkQuery(query); err != nil {
+		return err
+	}
+
+	found, err := b.find(query)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range found {
+		if err := b.p.Delete(s.Name); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
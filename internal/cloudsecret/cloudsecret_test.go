@@ -0,0 +1,244 @@
+package cloudsecret_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/backendtest"
+	"github.com/mailstone/go-keychain/internal/cloudsecret"
+)
+
+// memProvider is a Provider in memory.
+type memProvider struct {
+	secrets map[string]cloudsecret.Secret
+}
+
+func (m *memProvider) Create(s cloudsecret.Secret) error {
+	if _, ok := m.secrets[s.Name]; ok {
+		return cloudsecret.ErrExists
+	}
+
+	m.secrets[s.Name] = s
+
+	return nil
+}
+
+func (m *memProvider) Get(name string) (cloudsecret.Secret, error) {
+	s, ok := m.secrets[name]
+	if !ok {
+		return cloudsecret.Secret{}, cloudsecret.ErrNotFound
+	}
+
+	return s, nil
+}
+
+func (m *memProvider) Update(name string, data []byte, labels map[string]string) error {
+	s, ok := m.secrets[name]
+	if !ok {
+		return cloudsecret.ErrNotFound
+	}
+
+	s.Labels = labels
+	if data != nil {
+		s.Data = data
+	}
+
+	m.secrets[name] = s
+
+	return nil
+}
+
+func (m *memProvider) Delete(name string) error {
+	if _, ok := m.secrets[name]; !ok {
+		return cloudsecret.ErrNotFound
+	}
+
+	delete(m.secrets, name)
+
+	return nil
+}
+
+func (m *memProvider) List() ([]cloudsecret.Secret, error) {
+	var secrets []cloudsecret.Secret
+
+	for _, s := range m.secrets {
+		secrets = append(secrets, cloudsecret.Secret{Name: s.Name, Labels: s.Labels})
+	}
+
+	return secrets, nil
+}
+
+func TestSecretName(t *testing.T) {
+	name := cloudsecret.SecretName("app-", "my service", "bob@example.com")
+	if !strings.HasPrefix(name, "app-my_service-bob_example_com-") {
+		t.Fatalf("unexpected name %s", name)
+	}
+
+	if name == cloudsecret.SecretName("app-", "my_service", "bob@example.com") {
+		t.Fatal("expected different items to have different names")
+	}
+}
+
+func TestBackend(t *testing.T) {
+	p := &memProvider{secrets: map[string]cloudsecret.Secret{}}
+
+	prev := keychain.SetBackend(cloudsecret.New(p, "app-"))
+	defer keychain.SetBackend(prev)
+
+	alice := keychain.NewGenericPassword("db", "alice", "Database", []byte("secret"), "")
+	if err := keychain.AddItem(alice); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.AddItem(alice); !errors.Is(err, keychain.ErrorDuplicateItem) {
+		t.Fatalf("expected duplicate item, got %v", err)
+	}
+
+	if err := keychain.AddItem(keychain.NewGenericPassword("db", "bob", "", []byte("hunter2"), "")); err != nil {
+		t.Fatal(err)
+	}
+
+	accounts, err := keychain.GetGenericPasswordAccounts("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(accounts, ",") != "alice,bob" {
+		t.Fatalf("unexpected accounts %v", accounts)
+	}
+
+	// Renaming the account moves the item to another secret.
+	query := keychain.NewItem()
+	query.SetSecClass(keychain.SecClassGenericPassword)
+	query.SetService("db")
+	query.SetAccount("alice")
+
+	update := keychain.NewItem()
+	update.SetAccount("carol")
+
+	if err := keychain.UpdateItem(query, update); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := keychain.GetGenericPassword("db", "carol", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, []byte("secret")) {
+		t.Fatalf("expected secret, got %q", data)
+	}
+
+	s, err := p.Get(cloudsecret.SecretName("app-", "db", "carol"))
+	if err != nil || s.Labels["keychain-label"] != "Database" {
+		t.Fatalf("expected the label to move with the item, got %v, %v", s.Labels, err)
+	}
+
+	if len(p.secrets) != 2 {
+		t.Fatalf("expected 2 secrets, got %d", len(p.secrets))
+	}
+
+	all := keychain.NewItem()
+	all.SetSecClass(keychain.SecClassGenericPassword)
+	all.SetService("db")
+
+	if err := keychain.DeleteItem(all); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.secrets) != 0 {
+		t.Fatalf("expected all secrets to be deleted, got %d", len(p.secrets))
+	}
+}
+
+func TestConformance(t *testing.T) {
+	backendtest.RunConformance(t, cloudsecret.New(&memProvider{secrets: map[string]cloudsecret.Secret{}}, ""))
+}
+
+func TestPlatformAttributes(t *testing.T) {
+	b := cloudsecret.New(&memProvider{secrets: map[string]cloudsecret.Secret{}}, "")
+	b.SetMaxDataSize(2560)
+
+	if c := b.Capabilities(); c.MaxDataSize != 2560 {
+		t.Fatalf("expected a max data size of 2560, got %d", c.MaxDataSize)
+	}
+
+	prev := keychain.SetBackend(b)
+	defer keychain.SetBackend(prev)
+
+	item := keychain.NewGenericPassword("db", "alice", "", []byte("secret"), "")
+	item.SetAccessControl(keychain.AccessControl{Flags: keychain.AccessControlUserPresence})
+
+	if err := keychain.AddItem(item); !errors.Is(err, keychain.ErrorUnimplemented) {
+		t.Fatalf("expected unimplemented, got %v", err)
+	}
+
+	// Types aren't stored, so they can't narrow a deletion.
+	query := keychain.NewItem()
+	query.SetSecClass(keychain.SecClassGenericPassword)
+	query.SetService("db")
+
+	if err := query.SetType("note"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.DeleteItem(query); !errors.Is(err, keychain.ErrorUnimplemented) {
+		t.Fatalf("expected unimplemented, got %v", err)
+	}
+}
+
+func TestGeneric(t *testing.T) {
+	p := &memProvider{secrets: map[string]cloudsecret.Secret{}}
+
+	prev := keychain.SetBackend(cloudsecret.New(p, ""))
+	defer keychain.SetBackend(prev)
+
+	if c := keychain.Capabilities(); c.MaxGenericSize != cloudsecret.MaxGenericSize {
+		t.Fatalf("expected a max generic size of %d, got %d", cloudsecret.MaxGenericSize, c.MaxGenericSize)
+	}
+
+	item := keychain.NewGenericPassword("db", "alice", "", []byte("secret"), "")
+	if err := item.SetMetadata(keychain.Metadata{"note": strings.Repeat("x", 300)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.AddItem(item); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := p.Get(cloudsecret.SecretName("", "db", "alice"))
+	if err != nil || s.Labels[cloudsecret.GenericLabel+"-1"] == "" {
+		t.Fatalf("expected the generic attribute to span labels, got %v, %v", s.Labels, err)
+	}
+
+	query := keychain.NewItem()
+	query.SetSecClass(keychain.SecClassGenericPassword)
+	query.SetService("db")
+	query.SetAccount("alice")
+	query.SetReturnAttributes(true)
+
+	results, err := keychain.QueryItem(query)
+	if err != nil || len(results) != 1 || results[0].Metadata()["note"] != strings.Repeat("x", 300) {
+		t.Fatalf("expected the metadata to be returned, got %+v, %v", results, err)
+	}
+
+	update := keychain.NewItem()
+	update.SetGeneric([]byte{})
+
+	if err := keychain.UpdateItem(query, update); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := p.secrets[s.Name]; s.Labels[cloudsecret.GenericLabel] != "" || s.Labels[cloudsecret.GenericLabel+"-1"] != "" {
+		t.Fatalf("expected the generic attribute to be cleared, got %v", s.Labels)
+	}
+
+	update.SetGeneric(make([]byte, cloudsecret.MaxGenericSize+1))
+
+	if err := keychain.UpdateItem(query, update); !errors.Is(err, keychain.ErrorParam) {
+		t.Fatalf("expected ErrorParam, got %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+package secitem
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func init() {
+	gob.Register(time.Time{})
+}
+
+// Storage persists the encoded items of a Store.
+type Storage interface {
+	// Load returns the saved items, or nil if nothing was saved yet.
+	Load() ([]byte, error)
+	// Save replaces the saved items.
+	Save(b []byte) error
+}
+
+// LockingStorage is a Storage shared between processes. The store takes its
+// lock around each operation and reloads the items, so that a change made by
+// another process is never overwritten.
+type LockingStorage interface {
+	Storage
+	// Lock takes an exclusive lock on the saved items and returns the
+	// function releasing it.
+	Lock() (unlock func() error, err error)
+}
+
+// Sealer protects persisted items, e.g. by encrypting them.
+type Sealer interface {
+	Seal(plaintext []byte) ([]byte, error)
+	Open(ciphertext []byte) ([]byte, error)
+}
+
+// storedRecord is the persisted form of a record.
+type storedRecord struct {
+	Attrs map[string]interface{}
+	Ref   []byte
+}
+
+type storedItems struct {
+	Records []storedRecord
+	NextRef int
+}
+
+// Open returns a Store persisted to storage. Existing items are loaded and
+// the items are saved after each change. If storage is a LockingStorage, each
+// operation holds its lock and starts by reloading the items.
+func Open(storage Storage) (*Store, error) {
+	s := NewStore()
+
+	if err := s.load(storage); err != nil {
+		return nil, err
+	}
+
+	s.persist = func() error {
+		return s.save(storage)
+	}
+
+	if ls, ok := storage.(LockingStorage); ok {
+		s.sync = func() (func(), error) {
+			unlock, err := ls.Lock()
+			if err != nil {
+				return nil, fmt.Errorf("failed to lock items: %w", err)
+			}
+
+			if err := s.load(storage); err != nil {
+				_ = unlock()
+
+				return nil, err
+			}
+
+			return func() { _ = unlock() }, nil
+		}
+	}
+
+	return s, nil
+}
+
+// load replaces the records with the items saved in storage.
+func (s *Store) load(storage Storage) error {
+	b, err := storage.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load items: %w", err)
+	}
+
+	s.records, s.nextRef = nil, 0
+
+	if b == nil {
+		return nil
+	}
+
+	var items storedItems
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&items); err != nil {
+		return fmt.Errorf("failed to decode items: %w", err)
+	}
+
+	for _, r := range items.Records {
+		s.records = append(s.records, &record{attrs: r.Attrs, ref: r.Ref})
+	}
+
+	s.nextRef = items.NextRef
+
+	return nil
+}
+
+func (s *Store) save(storage Storage) error {
+	items := storedItems{NextRef: s.nextRef}
+	for _, r := range s.records {
+		items.Records = append(items.Records, storedRecord{Attrs: r.attrs, Ref: r.ref})
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(items); err != nil {
+		return fmt.Errorf("failed to encode items: %w", err)
+	}
+
+	if err := storage.Save(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to save items: %w", err)
+	}
+
+	return nil
+}
+
+// fileStorage is a Storage sealing the items into a file.
+type fileStorage struct {
+	path   string
+	sealer Sealer
+}
+
+// OpenFile returns a Store persisted to the file at path and sealed with
+// sealer. The items are written to a temporary file which then replaces path.
+// Processes sharing the file lock path with the suffix ".lock" around each
+// operation.
+func OpenFile(path string, sealer Sealer) (*Store, error) {
+	return Open(fileStorage{path: path, sealer: sealer})
+}
+
+func (f fileStorage) Load() ([]byte, error) {
+	sealed, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.path, err)
+	}
+
+	b, err := f.sealer.Open(sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unseal %s: %w", f.path, err)
+	}
+
+	return b, nil
+}
+
+func (f fileStorage) Lock() (func() error, error) {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	unlock, err := lockFile(f.path + ".lock")
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock %s: %w", f.path, err)
+	}
+
+	return unlock, nil
+}
+
+func (f fileStorage) Save(b []byte) error {
+	sealed, err := f.sealer.Seal(b)
+	if err != nil {
+		return fmt.Errorf("failed to seal items: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, sealed, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+
+	if err := os.Rename(tmp, f.path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", f.path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package secitem
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+)
+
+// xorSealer is a reversible stand-in for encryption.
+type xorSealer struct{ fail bool }
+
+func (s xorSealer) Seal(b []byte) ([]byte, error) {
+	if s.fail {
+		return nil, errors.New("seal failed")
+	}
+
+	out := make([]byte, len(b))
+	for i, c := range b {
+		out[i] = c ^ 0x5a
+	}
+
+	return out, nil
+}
+
+func (s xorSealer) Open(b []byte) ([]byte, error) {
+	return s.Seal(b)
+}
+
+func TestOpenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "keychain.db")
+
+	s, err := OpenFile(path, xorSealer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Add(genp("a", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(raw, []byte("data-a")) {
+		t.Fatal("store file is not sealed")
+	}
+
+	s, err = OpenFile(path, xorSealer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := s.CopyMatching(map[string]interface{}{
+		keychain.SecClassKey:   "genp",
+		keychain.AccountKey:    "a",
+		keychain.ReturnDataKey: true,
+	})
+	if err != nil || string(data.([]byte)) != "data-a" {
+		t.Fatalf("unexpected data %q, %v", data, err)
+	}
+
+	// A failed save leaves the store unchanged.
+	s.persist = func() error { return s.save(fileStorage{path: path, sealer: xorSealer{fail: true}}) }
+
+	if _, err := s.Add(genp("b", nil)); err == nil {
+		t.Fatal("expected save error")
+	}
+
+	if s.Len() != 1 {
+		t.Fatalf("expected 1 item after failed save, got %d", s.Len())
+	}
+}
+
+func TestOpenFileShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keychain.db")
+
+	a, err := OpenFile(path, xorSealer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := OpenFile(path, xorSealer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.Add(genp("a", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each store reloads the items changed by the other before a change.
+	if _, err := b.Add(genp("b", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.Add(genp("b", nil)); !errors.Is(err, keychain.ErrorDuplicateItem) {
+		t.Fatalf("expected the item added by the other store to be a duplicate, got %v", err)
+	}
+
+	c, err := OpenFile(path, xorSealer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Len() != 2 {
+		t.Fatalf("expected the items of both stores, got %d", c.Len())
+	}
+}
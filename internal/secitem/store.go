@@ -0,0 +1,475 @@
+// Package secitem implements SecItem semantics (attribute matching, primary
+// key uniqueness, match limits and result shapes) over items held in memory,
+// optionally persisted (see Open and OpenFile), for backends and fakes that
+// have no keychain of their own.
+package secitem
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	keychain "github.com/mailstone/go-keychain"
+)
+
+// primaryKeys are the attributes which, with the class, access group and
+// synchronizable attributes, identify an item of each class.
+var primaryKeys = map[string][]string{
+	"genp": {keychain.AccountKey, keychain.ServiceKey},
+	"inet": {keychain.AccountKey, "sdmn", keychain.ServerKey, keychain.ProtocolKey,
+		keychain.AuthenticationTypeKey, keychain.PortKey, keychain.PathKey},
+	"cert": {"ctyp", "issr", "slnr"},
+	"keys": {"kcls", "klbl", "atag", "type", "bsiz", "esiz"},
+}
+
+// record is a stored item.
+type record struct {
+	attrs map[string]interface{}
+	ref   []byte
+}
+
+// Store is an in-memory keychain implementing keychain.Backend. The zero value
+// is not usable; use NewStore.
+type Store struct {
+	mu      sync.Mutex
+	records []*record
+	nextRef int
+	// persist, if set, saves the records after a change.
+	persist func() error
+	// sync, if set, locks the persisted records and reloads them before an
+	// operation, returning the function releasing the lock.
+	sync func() (func(), error)
+
+	// Now returns the creation and modification dates of items. It defaults to
+	// time.Now.
+	Now func() time.Time
+}
+
+// commit persists a change, restoring the records from before the change on
+// failure.
+func (s *Store) commit(prev []*record, prevRef int) error {
+	if s.persist == nil {
+		return nil
+	}
+
+	if err := s.persist(); err != nil {
+		s.records, s.nextRef = prev, prevRef
+
+		return err
+	}
+
+	return nil
+}
+
+// lock calls sync, if set, and returns the function releasing its lock.
+func (s *Store) lock() (func(), error) {
+	if s.sync == nil {
+		return func() {}, nil
+	}
+
+	return s.sync()
+}
+
+// snapshot returns a copy of the records, for commit.
+func (s *Store) snapshot() []*record {
+	prev := make([]*record, 0, len(s.records))
+	for _, r := range s.records {
+		c := *r
+		prev = append(prev, &c)
+	}
+
+	return prev
+}
+
+// NewStore returns an empty Store.
+func NewStore() *Store {
+	return &Store{Now: time.Now}
+}
+
+// isControlKey returns whether key is a search, return or use option rather
+// than an item attribute.
+func isControlKey(key string) bool {
+	switch key {
+	case keychain.UseDataProtectionKeychainKey, keychain.ValuePersistentRefKey, "v_Ref":
+		return true
+	}
+
+	return strings.HasPrefix(key, "m_") || strings.HasPrefix(key, "r_") || strings.HasPrefix(key, "u_")
+}
+
+// isZero returns whether v is missing or an empty value, which are equivalent
+// for matching and uniqueness.
+func isZero(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []byte:
+		return len(v) == 0
+	case bool:
+		return !v
+	case int32:
+		return v == 0
+	case int64:
+		return v == 0
+	case int:
+		return v == 0
+	}
+
+	return false
+}
+
+func equal(a, b interface{}) bool {
+	if isZero(a) || isZero(b) {
+		return isZero(a) && isZero(b)
+	}
+
+	switch a := a.(type) {
+	case []byte:
+		b, ok := b.([]byte)
+
+		return ok && bytes.Equal(a, b)
+	case time.Time:
+		b, ok := b.(time.Time)
+
+		return ok && a.Equal(b)
+	case int32, int64, int:
+		an, _ := toInt64(a)
+		bn, ok := toInt64(b)
+
+		return ok && an == bn
+	}
+
+	return a == b
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	}
+
+	return 0, false
+}
+
+func copyValue(v interface{}) interface{} {
+	if b, ok := v.([]byte); ok {
+		return append([]byte(nil), b...)
+	}
+
+	return v
+}
+
+func containsRef(list [][]byte, ref []byte) bool {
+	for _, r := range list {
+		if bytes.Equal(r, ref) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Store) matches(r *record, query map[string]interface{}) bool {
+	if ref, ok := query[keychain.ValuePersistentRefKey].([]byte); ok && !bytes.Equal(ref, r.ref) {
+		return false
+	}
+
+	if list, ok := query[keychain.MatchItemListKey].([][]byte); ok && !containsRef(list, r.ref) {
+		return false
+	}
+
+	// Items are only matched regardless of synchronizable when it is
+	// kSecAttrSynchronizableAny.
+	if query[keychain.SynchronizableKey] != "syna" && !equal(query[keychain.SynchronizableKey], r.attrs[keychain.SynchronizableKey]) {
+		return false
+	}
+
+	for key, v := range query {
+		if isControlKey(key) || key == keychain.SynchronizableKey || key == keychain.DataKey {
+			continue
+		}
+
+		if !equal(v, r.attrs[key]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// find returns the records matching query, or ErrorItemNotFound. Queries
+// need a class unless they match by persistent reference, which identifies
+// the class.
+func (s *Store) find(query map[string]interface{}) ([]*record, error) {
+	_, hasClass := query[keychain.SecClassKey].(string)
+	_, hasRef := query[keychain.ValuePersistentRefKey].([]byte)
+	_, hasList := query[keychain.MatchItemListKey].([][]byte)
+
+	if !hasClass && !hasRef && !hasList {
+		return nil, keychain.ErrorParam
+	}
+
+	var found []*record
+
+	for _, r := range s.records {
+		if s.matches(r, query) {
+			found = append(found, r)
+		}
+	}
+
+	if len(found) == 0 {
+		return nil, keychain.ErrorItemNotFound
+	}
+
+	return found, nil
+}
+
+// duplicate returns whether attrs has the same primary key as a record other
+// than except.
+func (s *Store) duplicate(attrs map[string]interface{}, except ...*record) bool {
+	class, _ := attrs[keychain.SecClassKey].(string)
+	keys := append([]string{keychain.SecClassKey, keychain.AccessGroupKey, keychain.SynchronizableKey}, primaryKeys[class]...)
+
+next:
+	for _, r := range s.records {
+		for _, e := range except {
+			if r == e {
+				continue next
+			}
+		}
+
+		for _, key := range keys {
+			if !equal(attrs[key], r.attrs[key]) {
+				continue next
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// result shapes the result for r as requested by query.
+func (s *Store) result(r *record, query map[string]interface{}) interface{} {
+	wantAttrs, _ := query[keychain.ReturnAttributesKey].(bool)
+	wantData, _ := query[keychain.ReturnDataKey].(bool)
+	wantRef, _ := query[keychain.ReturnPersistentRefKey].(bool)
+
+	switch {
+	case !wantAttrs && wantData && !wantRef:
+		return copyValue(r.attrs[keychain.DataKey])
+	case !wantAttrs && !wantData && wantRef:
+		return copyValue(r.ref)
+	}
+
+	d := make(map[string]interface{})
+
+	if wantAttrs {
+		for key, v := range r.attrs {
+			if key != keychain.DataKey {
+				d[key] = copyValue(v)
+			}
+		}
+	}
+
+	if wantData {
+		if data, ok := r.attrs[keychain.DataKey]; ok {
+			d[keychain.DataKey] = copyValue(data)
+		}
+	}
+
+	if wantRef {
+		d[keychain.ValuePersistentRefKey] = copyValue(r.ref)
+	}
+
+	return d
+}
+
+// Add implements keychain.Backend.
+func (s *Store) Add(attrs map[string]interface{}) (interface{}, error) {
+	if _, ok := attrs[keychain.SecClassKey].(string); !ok {
+		return nil, keychain.ErrorParam
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if s.duplicate(attrs) {
+		return nil, keychain.ErrorDuplicateItem
+	}
+
+	prev, prevRef := s.snapshot(), s.nextRef
+	now := s.Now()
+	r := &record{attrs: map[string]interface{}{
+		keychain.CreationDateKey:     now,
+		keychain.ModificationDateKey: now,
+	}}
+
+	for key, v := range attrs {
+		if !isControlKey(key) && !isZero(v) {
+			r.attrs[key] = copyValue(v)
+		}
+	}
+
+	s.nextRef++
+	r.ref = []byte(fmt.Sprintf("secitem-%d", s.nextRef))
+	s.records = append(s.records, r)
+
+	if err := s.commit(prev, prevRef); err != nil {
+		return nil, err
+	}
+
+	if !keychain.WantsResult(attrs) {
+		return nil, nil
+	}
+
+	return s.result(r, attrs), nil
+}
+
+// Update implements keychain.Backend.
+func (s *Store) Update(query, attrs map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	found, err := s.find(query)
+	if err != nil {
+		return err
+	}
+
+	updated := make([]map[string]interface{}, len(found))
+
+	for i, r := range found {
+		u := make(map[string]interface{}, len(r.attrs))
+		for key, v := range r.attrs {
+			u[key] = v
+		}
+
+		for key, v := range attrs {
+			switch {
+			case isControlKey(key) || key == keychain.SecClassKey:
+			case isZero(v):
+				delete(u, key)
+			default:
+				u[key] = copyValue(v)
+			}
+		}
+
+		if s.duplicate(u, found...) {
+			return keychain.ErrorDuplicateItem
+		}
+
+		updated[i] = u
+	}
+
+	prev, prevRef := s.snapshot(), s.nextRef
+	now := s.Now()
+
+	for i, r := range found {
+		updated[i][keychain.ModificationDateKey] = now
+		r.attrs = updated[i]
+	}
+
+	return s.commit(prev, prevRef)
+}
+
+// CopyMatching implements keychain.Backend.
+func (s *Store) CopyMatching(query map[string]interface{}) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	found, err := s.find(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if !keychain.WantsResult(query) {
+		return nil, nil
+	}
+
+	if query[keychain.MatchLimitKey] != "m_LimitAll" {
+		return s.result(found[0], query), nil
+	}
+
+	results := make([]interface{}, 0, len(found))
+	for _, r := range found {
+		results = append(results, s.result(r, query))
+	}
+
+	return results, nil
+}
+
+// Delete implements keychain.Backend.
+func (s *Store) Delete(query map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	found, err := s.find(query)
+	if err != nil {
+		return err
+	}
+
+	prev, prevRef := s.snapshot(), s.nextRef
+	kept := make([]*record, 0, len(s.records))
+
+next:
+	for _, r := range s.records {
+		for _, f := range found {
+			if r == f {
+				continue next
+			}
+		}
+
+		kept = append(kept, r)
+	}
+
+	s.records = kept
+
+	return s.commit(prev, prevRef)
+}
+
+// Capabilities implements keychain.CapabilityReporter. Access groups and
+// synchronizable are stored and matched, but not enforced or synchronized.
+func (s *Store) Capabilities() keychain.Capability {
+	return keychain.Capability{PersistentRefs: true, UniqueKeys: true}
+}
+
+// Len returns the number of stored items.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.records)
+}
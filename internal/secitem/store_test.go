@@ -0,0 +1,111 @@
+package secitem
+
+import (
+	"errors"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+)
+
+func genp(account string, extra map[string]interface{}) map[string]interface{} {
+	attrs := map[string]interface{}{
+		keychain.SecClassKey: "genp",
+		keychain.ServiceKey:  "MyService",
+		keychain.AccountKey:  account,
+		keychain.DataKey:     []byte("data-" + account),
+	}
+
+	for k, v := range extra {
+		attrs[k] = v
+	}
+
+	return attrs
+}
+
+func TestStoreSynchronizable(t *testing.T) {
+	s := NewStore()
+
+	if _, err := s.Add(genp("a", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same primary key with a different synchronizable status is a
+	// different item.
+	if _, err := s.Add(genp("a", map[string]interface{}{keychain.SynchronizableKey: true})); err != nil {
+		t.Fatal(err)
+	}
+
+	query := map[string]interface{}{
+		keychain.SecClassKey:         "genp",
+		keychain.MatchLimitKey:       "m_LimitAll",
+		keychain.ReturnAttributesKey: true,
+	}
+
+	for _, tc := range []struct {
+		sync interface{}
+		n    int
+	}{{nil, 1}, {true, 1}, {"syna", 2}} {
+		query[keychain.SynchronizableKey] = tc.sync
+
+		res, err := s.CopyMatching(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n := len(res.([]interface{})); n != tc.n {
+			t.Errorf("sync %v: expected %d results, got %d", tc.sync, tc.n, n)
+		}
+	}
+}
+
+func TestStorePersistentRef(t *testing.T) {
+	s := NewStore()
+
+	ref, err := s.Add(genp("a", map[string]interface{}{keychain.ReturnPersistentRefKey: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Add(genp("b", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := s.CopyMatching(map[string]interface{}{
+		keychain.SecClassKey:           "genp",
+		keychain.ValuePersistentRefKey: ref,
+		keychain.ReturnDataKey:         true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data.([]byte)) != "data-a" {
+		t.Fatalf("unexpected data %q", data)
+	}
+}
+
+func TestStoreUpdateDuplicate(t *testing.T) {
+	s := NewStore()
+
+	for _, account := range []string{"a", "b"} {
+		if _, err := s.Add(genp(account, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := s.Update(
+		map[string]interface{}{keychain.SecClassKey: "genp", keychain.AccountKey: "a"},
+		map[string]interface{}{keychain.AccountKey: "b"},
+	)
+	if !errors.Is(err, keychain.ErrorDuplicateItem) {
+		t.Fatalf("expected ErrorDuplicateItem, got %v", err)
+	}
+
+	err = s.Update(
+		map[string]interface{}{keychain.SecClassKey: "genp", keychain.AccountKey: "c"},
+		map[string]interface{}{keychain.LabelKey: "c"},
+	)
+	if !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound, got %v", err)
+	}
+}
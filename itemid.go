@@ -0,0 +1,68 @@
+package keychain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ItemID returns a stable, opaque identifier of the item with the primary key
+// class, service, account and access group (for internet passwords, service
+// is the server), so logs and external systems can reference the item without
+// recording its service and account. Service and account are compared in
+// Unicode NFC. The identifier is a truncated SHA-256 hash, which doesn't hide
+// names that can be guessed.
+func ItemID(class SecClass, service, account, accessGroup string) string {
+	return itemID(secClassValues[class], service, account, accessGroup)
+}
+
+func itemID(class, service, account, accessGroup string) string {
+	h := sha256.New()
+
+	for _, s := range []string{class, norm.NFC.String(service), norm.NFC.String(account), accessGroup} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// ItemIDOf returns the ItemID of the item targeted by encoded attributes (see
+// EncodeAttributes), e.g. in a Backend, or false if they have no class. An
+// access group missing from the attributes counts as empty, so the ID of a
+// query without one differs from that of an item in an access group.
+func ItemIDOf(attrs map[string]interface{}) (string, bool) {
+	class, ok := attrs[SecClassKey].(string)
+	if !ok {
+		return "", false
+	}
+
+	str := func(key string) string {
+		s, _ := attrs[key].(string)
+
+		return s
+	}
+
+	service := str(ServiceKey)
+	if class == secClassValues[SecClassInternetPassword] {
+		service = str(ServerKey)
+	}
+
+	return itemID(class, service, str(AccountKey), str(AccessGroupKey)), true
+}
+
+// resultID returns the ItemID of a result of a query for class.
+func resultID(class interface{}, r QueryResult) string {
+	c, ok := class.(string)
+	if !ok {
+		return ""
+	}
+
+	service := r.Service
+	if c == secClassValues[SecClassInternetPassword] {
+		service = r.Server
+	}
+
+	return itemID(c, service, r.Account, r.AccessGroup)
+}
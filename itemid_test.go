@@ -0,0 +1,37 @@
+package keychain
+
+import "testing"
+
+func TestItemID(t *testing.T) {
+	id := ItemID(SecClassGenericPassword, "MyService", "gabri\u00e9l", "")
+	if len(id) != 32 {
+		t.Fatalf("unexpected ID %q", id)
+	}
+
+	if other := ItemID(SecClassGenericPassword, "MyService", "gabri\u00e9l", ""); other != id {
+		t.Fatalf("expected a stable ID, got %q and %q", id, other)
+	}
+
+	if decomposed := ItemID(SecClassGenericPassword, "MyService", "gabrie\u0301l", ""); decomposed != id {
+		t.Fatal("expected the same ID for decomposed names")
+	}
+
+	for _, other := range []string{
+		ItemID(SecClassInternetPassword, "MyService", "gabri\u00e9l", ""),
+		ItemID(SecClassGenericPassword, "MyServic", "egabri\u00e9l", ""),
+		ItemID(SecClassGenericPassword, "MyService", "gabri\u00e9l", "group"),
+	} {
+		if other == id {
+			t.Fatalf("expected a different ID than %q", id)
+		}
+	}
+
+	attrs := map[string]interface{}{SecClassKey: "inet", ServerKey: "example.com", AccountKey: "gabriel"}
+	if got, ok := ItemIDOf(attrs); !ok || got != ItemID(SecClassInternetPassword, "example.com", "gabriel", "") {
+		t.Fatalf("unexpected ID %q", got)
+	}
+
+	if _, ok := ItemIDOf(map[string]interface{}{ServiceKey: "MyService"}); ok {
+		t.Fatal("expected no ID without a class")
+	}
+}
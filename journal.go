@@ -0,0 +1,199 @@
+package keychain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// JournalAccount is the account of the generic password item holding the
+// journal of a transaction in progress.
+const JournalAccount = "go-keychain.journal"
+
+// Recovery is how RecoverTransaction completes an interrupted transaction.
+type Recovery int
+
+const (
+	// RecoveryRollBack restores the items to their state before the
+	// transaction.
+	RecoveryRollBack Recovery = iota
+	// RecoveryRollForward applies the remaining writes of the transaction.
+	RecoveryRollForward
+)
+
+// txWrite is a single write of a transaction. A nil Data deletes the item.
+type txWrite struct {
+	Account string `json:"account"`
+	Data    []byte `json:"data"`
+}
+
+// journal is the write-ahead record of a transaction: the intended writes and
+// the state of the same items before the transaction.
+type journal struct {
+	Writes []txWrite `json:"writes"`
+	Before []txWrite `json:"before"`
+}
+
+// Transaction groups writes to the generic passwords of a service so that they
+// are applied together. Before applying, a journal of the writes and the prior
+// state is stored in the keychain, allowing RecoverTransaction to complete or
+// undo a transaction interrupted by a crash. The journal contains the secret
+// values and is stored with the same access group as the items.
+type Transaction struct {
+	service     string
+	accessGroup string
+	writes      []txWrite
+}
+
+// NewTransaction creates an empty transaction for the generic passwords of
+// service.
+func NewTransaction(service string) *Transaction {
+	return &Transaction{service: service}
+}
+
+// SetAccessGroup sets the access group of the items (and the journal).
+func (t *Transaction) SetAccessGroup(accessGroup string) {
+	t.accessGroup = accessGroup
+}
+
+// Put sets the data of account, adding the item if necessary.
+func (t *Transaction) Put(account string, data []byte) {
+	if data == nil {
+		data = []byte{}
+	}
+
+	t.writes = append(t.writes, txWrite{Account: account, Data: data})
+}
+
+// Delete deletes account, if it exists.
+func (t *Transaction) Delete(account string) {
+	t.writes = append(t.writes, txWrite{Account: account})
+}
+
+// Apply journals and applies the writes. If a write fails, the writes already
+// made are rolled back before returning the error.
+func (t *Transaction) Apply() error {
+	if len(t.writes) == 0 {
+		return nil
+	}
+
+	s, end, err := cur().begin()
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	j := journal{Writes: t.writes}
+
+	for _, w := range t.writes {
+		data, err := s.getGenericPassword(t.service, w.Account, "", t.accessGroup)
+		if err != nil {
+			return fmt.Errorf("failed to read %q before transaction: %w", w.Account, err)
+		}
+
+		j.Before = append(j.Before, txWrite{Account: w.Account, Data: data})
+	}
+
+	b, err := json.Marshal(j)
+	if err != nil {
+		return fmt.Errorf("failed to encode journal: %w", err)
+	}
+
+	if err := s.addItem(s.newGenericPassword(t.service, JournalAccount, "", b, t.accessGroup)); err != nil {
+		if errors.Is(err, ErrorDuplicateItem) {
+			return fmt.Errorf("a transaction is already in progress for %q, see RecoverTransaction: %w", t.service, err)
+		}
+
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+
+	if err := t.write(s, j.Writes); err != nil {
+		if rbErr := t.write(s, j.Before); rbErr != nil {
+			return fmt.Errorf("transaction failed (%w) and rollback failed, journal kept: %w", err, rbErr)
+		}
+
+		_ = t.deleteJournal(s)
+
+		return fmt.Errorf("transaction rolled back: %w", err)
+	}
+
+	return t.deleteJournal(s)
+}
+
+func (t *Transaction) write(s *state, writes []txWrite) error {
+	for _, w := range writes {
+		var err error
+		if w.Data == nil {
+			err = t.delete(s, w.Account)
+		} else {
+			err = s.upsertGenericPassword(t.service, w.Account, t.accessGroup, w.Data)
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to write %q: %w", w.Account, err)
+		}
+	}
+
+	return nil
+}
+
+func (t *Transaction) delete(s *state, account string) error {
+	item := s.newItem()
+	item.SetSecClass(SecClassGenericPassword)
+	item.SetService(t.service)
+	item.SetAccount(account)
+	item.SetAccessGroup(t.accessGroup)
+
+	if err := s.deleteItem(item); err != nil && !errors.Is(err, ErrorItemNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+func (t *Transaction) deleteJournal(s *state) error {
+	if err := t.delete(s, JournalAccount); err != nil {
+		return fmt.Errorf("failed to delete journal: %w", err)
+	}
+
+	return nil
+}
+
+// RecoverTransaction completes a transaction of service that was interrupted
+// before finishing, as found by its journal, and removes the journal. It
+// returns false if there was no interrupted transaction. Call it at startup
+// before reading the items.
+func RecoverTransaction(service, accessGroup string, recovery Recovery) (bool, error) {
+	s, end, err := cur().begin()
+	if err != nil {
+		return false, err
+	}
+	defer end()
+
+	b, err := s.getGenericPassword(service, JournalAccount, "", accessGroup)
+	if err != nil {
+		return false, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	if b == nil {
+		return false, nil
+	}
+
+	var j journal
+	if err := json.Unmarshal(b, &j); err != nil {
+		return true, fmt.Errorf("failed to decode journal: %w", err)
+	}
+
+	t := &Transaction{service: service, accessGroup: accessGroup}
+
+	writes := j.Before
+	if recovery == RecoveryRollForward {
+		writes = j.Writes
+	}
+
+	if err := t.write(s, writes); err != nil {
+		return true, err
+	}
+
+	return true, t.deleteJournal(s)
+}
@@ -0,0 +1,61 @@
+//go:build darwin && !ios
+// +build darwin,!ios
+
+package keychain
+
+import (
+	"testing"
+)
+
+func TestTransactionRecover(t *testing.T) {
+	service := "TestTransactionRecover"
+	defer func() { _ = DeleteGenericPasswordItem(service, "a") }()
+	defer func() { _ = DeleteGenericPasswordItem(service, "b") }()
+	defer func() { _ = DeleteGenericPasswordItem(service, JournalAccount) }()
+
+	if err := AddItem(NewGenericPassword(service, "a", "", []byte("old"), "")); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := NewTransaction(service)
+	tx.Put("a", []byte("new"))
+	tx.Put("b", []byte("added"))
+	if err := tx.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	recovered, err := RecoverTransaction(service, "", RecoveryRollBack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovered {
+		t.Fatal("journal should have been removed after a successful transaction")
+	}
+
+	// Simulate a crash after journaling a transaction deleting "b".
+	tx = NewTransaction(service)
+	tx.Delete("b")
+	j := `{"writes":[{"account":"b","data":null}],"before":[{"account":"b","data":"YWRkZWQ="}]}`
+	if err := AddItem(NewGenericPassword(service, JournalAccount, "", []byte(j), "")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.write(cur(), tx.writes); err != nil {
+		t.Fatal(err)
+	}
+
+	recovered, err = RecoverTransaction(service, "", RecoveryRollBack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !recovered {
+		t.Fatal("expected an interrupted transaction")
+	}
+
+	data, err := GetGenericPassword(service, "b", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "added" {
+		t.Fatalf("expected rollback to restore %q, got %q", "added", data)
+	}
+}
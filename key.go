@@ -0,0 +1,293 @@
+package keychain
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/elliptic"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// ErrKeyClosed is returned for operations on a closed Key.
+var ErrKeyClosed = errors.New("key closed")
+
+// KeyType is the type of a Key.
+type KeyType int
+
+const (
+	// KeyTypeEC is an elliptic curve key (kSecAttrKeyTypeECSECPrimeRandom)
+	// on P-256, P-384 or P-521, by size.
+	KeyTypeEC KeyType = iota + 1
+	// KeyTypeRSA is an RSA key (kSecAttrKeyTypeRSA).
+	KeyTypeRSA
+)
+
+var curves = map[int]elliptic.Curve{
+	256: elliptic.P256(),
+	384: elliptic.P384(),
+	521: elliptic.P521(),
+}
+
+var ecdhCurves = map[int]ecdh.Curve{
+	256: ecdh.P256(),
+	384: ecdh.P384(),
+	521: ecdh.P521(),
+}
+
+// KeyOptions are the options of GenerateKey.
+type KeyOptions struct {
+	Type KeyType
+	// Bits is the key size. It defaults to 256 for EC and 2048 for RSA keys.
+	Bits int
+	// SecureEnclave creates the key in the Secure Enclave
+	// (kSecAttrTokenIDSecureEnclave), from which it can't be exported. Only EC
+	// P-256 keys are supported, and only on devices with a Secure Enclave.
+	SecureEnclave bool
+	// Permanent stores the private key in the keychain (in the data protection
+	// keychain on macOS), to be loaded again with LoadKey. Other keys are
+	// ephemeral: they exist only until closed.
+	Permanent bool
+	// Label is the label of a permanent key.
+	Label string
+	// Tag is the application tag identifying a permanent key.
+	Tag []byte
+	// AccessGroup is the access group of a permanent key.
+	AccessGroup string
+}
+
+// Key is a private key held by the Security framework, possibly in the Secure
+// Enclave. It implements crypto.Signer, with ASN.1 signatures for EC keys and
+// PKCS #1 v1.5 or PSS (with the salt length equal to the hash) for RSA keys,
+// so it can be used for TLS client authentication or by SSH agents.
+//
+// Close releases the reference to the key (and an ephemeral key with it).
+// Keys that aren't closed are released when garbage collected.
+type Key struct {
+	keyType KeyType
+	bits    int
+	public  crypto.PublicKey
+
+	mu  sync.Mutex
+	ref uintptr // SecKeyRef, 0 once closed
+}
+
+func newKey(ref uintptr, keyType KeyType, bits int) (*Key, error) {
+	public, err := publicKey(ref, keyType, bits)
+	if err != nil {
+		releaseKey(ref)
+
+		return nil, err
+	}
+
+	k := &Key{keyType: keyType, bits: bits, public: public, ref: ref}
+	runtime.SetFinalizer(k, (*Key).Close)
+
+	return k, nil
+}
+
+// GenerateKey creates a private key. It fails with ErrorUnimplemented on
+// platforms other than macOS and iOS, and with an *OSVersionError before
+// macOS 10.12 and iOS 10.
+func GenerateKey(opts KeyOptions) (*Key, error) {
+	if opts.Bits == 0 {
+		opts.Bits = map[KeyType]int{KeyTypeEC: 256, KeyTypeRSA: 2048}[opts.Type]
+	}
+
+	if opts.SecureEnclave && (opts.Type != KeyTypeEC || opts.Bits != 256) {
+		return nil, errors.New("the Secure Enclave only supports EC P-256 keys")
+	}
+
+	if opts.Permanent {
+		if err := checkWritable(); err != nil {
+			return nil, err
+		}
+	}
+
+	ref, err := generateKey(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return newKey(ref, opts.Type, opts.Bits)
+}
+
+// ImportKey creates a Key from a private key of the standard library, an
+// *ecdsa.PrivateKey, *ecdh.PrivateKey or *rsa.PrivateKey, see
+// MarshalExternalKey, e.g. to use a key generated elsewhere with the
+// Security framework, or to store it in the keychain with opts.Permanent.
+// The type and size of the key come from key; opts.SecureEnclave isn't
+// supported, since keys can't be imported into the Secure Enclave.
+func ImportKey(key any, opts KeyOptions) (*Key, error) {
+	k, err := MarshalExternalKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if !k.Private {
+		return nil, fmt.Errorf("%T is not a private key", key)
+	}
+
+	if opts.SecureEnclave {
+		return nil, errors.New("keys can't be imported into the Secure Enclave")
+	}
+
+	if opts.Permanent {
+		if err := checkWritable(); err != nil {
+			return nil, err
+		}
+	}
+
+	ref, err := importKey(k, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return newKey(ref, k.Type, k.Bits)
+}
+
+// NewEphemeralKey creates a private key that is never written to a keychain,
+// e.g. for TLS session keys or one-shot signing.
+func NewEphemeralKey(keyType KeyType, bits int) (*Key, error) {
+	return GenerateKey(KeyOptions{Type: keyType, Bits: bits})
+}
+
+// WithEphemeralKey calls fn with a new ephemeral key, which is closed when fn
+// returns.
+func WithEphemeralKey(keyType KeyType, bits int, fn func(*Key) error) error {
+	k, err := NewEphemeralKey(keyType, bits)
+	if err != nil {
+		return err
+	}
+
+	defer k.Close()
+
+	return fn(k)
+}
+
+// LoadKey returns the permanent private key with the application tag, or
+// ErrorItemNotFound.
+func LoadKey(tag []byte) (*Key, error) {
+	ref, keyType, bits, err := loadKey(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return newKey(ref, keyType, bits)
+}
+
+// DeleteKey deletes the permanent private keys with the application tag.
+func DeleteKey(tag []byte) error {
+	if err := checkWritable(); err != nil {
+		return err
+	}
+
+	return deleteKey(tag)
+}
+
+// Type returns the type of the key.
+func (k *Key) Type() KeyType {
+	return k.keyType
+}
+
+// Public returns the public key, an *ecdsa.PublicKey or *rsa.PublicKey.
+func (k *Key) Public() crypto.PublicKey {
+	return k.public
+}
+
+// Export returns the external representation of the private key, e.g. to
+// convert it to a key of the standard library with ExternalKey.PrivateKey.
+// Keys in the Secure Enclave can't be exported.
+func (k *Key) Export() (ExternalKey, error) {
+	var data []byte
+
+	err := k.withRef(func(ref uintptr) (err error) {
+		data, err = exportKey(ref)
+
+		return err
+	})
+	if err != nil {
+		return ExternalKey{}, err
+	}
+
+	return ExternalKey{Type: k.keyType, Bits: k.bits, Private: true, Data: data}, nil
+}
+
+// withRef calls fn with the key reference, or returns ErrKeyClosed.
+func (k *Key) withRef(fn func(ref uintptr) error) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.ref == 0 {
+		return ErrKeyClosed
+	}
+
+	return fn(k.ref)
+}
+
+// Sign signs digest, which must be the hash of the message with
+// opts.HashFunc().
+func (k *Key) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var sig []byte
+
+	err := k.withRef(func(ref uintptr) (err error) {
+		sig, err = signWithKey(ref, k.keyType, digest, opts)
+
+		return err
+	})
+
+	return sig, err
+}
+
+// Verify checks a signature of digest made by Sign with the same opts.
+func (k *Key) Verify(digest, sig []byte, opts crypto.SignerOpts) error {
+	return k.withRef(func(ref uintptr) error {
+		return verifyWithKey(ref, k.keyType, digest, sig, opts)
+	})
+}
+
+// ECDH returns the shared secret of the key and peer, which must be on the
+// same curve (kSecKeyAlgorithmECDHKeyExchangeStandard).
+func (k *Key) ECDH(peer *ecdh.PublicKey) ([]byte, error) {
+	if k.keyType != KeyTypeEC {
+		return nil, errors.New("ECDH requires an EC key")
+	}
+
+	if want := ecdhCurves[k.bits]; peer.Curve() != want {
+		return nil, fmt.Errorf("peer key is on %v, not %v", peer.Curve(), want)
+	}
+
+	var secret []byte
+
+	err := k.withRef(func(ref uintptr) (err error) {
+		secret, err = keyExchange(ref, peer.Bytes())
+
+		return err
+	})
+
+	return secret, err
+}
+
+// delete deletes the permanent key from the keychain and closes it.
+func (k *Key) delete() error {
+	err := k.withRef(deleteKeyRef)
+	_ = k.Close()
+
+	return err
+}
+
+// Close releases the key. Closing a closed key has no effect.
+func (k *Key) Close() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.ref != 0 {
+		releaseKey(k.ref)
+		k.ref = 0
+		runtime.SetFinalizer(k, nil)
+	}
+
+	return nil
+}
@@ -0,0 +1,678 @@
+//go:build darwin
+// +build darwin
+
+package keychain
+
+/*
+#cgo LDFLAGS: -framework CoreFoundation -framework Security
+
+#include <CoreFoundation/CoreFoundation.h>
+#include <Security/Security.h>
+
+// generateKey creates a private key. label, tag and accessGroup may be NULL.
+static SecKeyRef generateKey(CFStringRef keyType, int bits, int permanent, int secureEnclave,
+	int dataProtection, CFStringRef label, CFDataRef tag, CFStringRef accessGroup, CFErrorRef *error) {
+	CFMutableDictionaryRef attrs = CFDictionaryCreateMutable(NULL, 0,
+		&kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+	CFMutableDictionaryRef private = CFDictionaryCreateMutable(NULL, 0,
+		&kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+	CFNumberRef size = CFNumberCreate(NULL, kCFNumberIntType, &bits);
+	SecAccessControlRef access = NULL;
+
+	CFDictionarySetValue(attrs, kSecAttrKeyType, keyType);
+	CFDictionarySetValue(attrs, kSecAttrKeySizeInBits, size);
+	CFDictionarySetValue(private, kSecAttrIsPermanent, permanent ? kCFBooleanTrue : kCFBooleanFalse);
+
+	if (secureEnclave) {
+		CFDictionarySetValue(attrs, kSecAttrTokenID, kSecAttrTokenIDSecureEnclave);
+
+		access = SecAccessControlCreateWithFlags(NULL, kSecAttrAccessibleWhenUnlockedThisDeviceOnly,
+			kSecAccessControlPrivateKeyUsage, error);
+		if (access == NULL) {
+			CFRelease(size);
+			CFRelease(private);
+			CFRelease(attrs);
+
+			return NULL;
+		}
+
+		CFDictionarySetValue(private, kSecAttrAccessControl, access);
+	}
+
+	if (dataProtection) {
+		CFDictionarySetValue(attrs, kSecUseDataProtectionKeychain, kCFBooleanTrue);
+	}
+
+	if (label != NULL) {
+		CFDictionarySetValue(private, kSecAttrLabel, label);
+	}
+
+	if (tag != NULL) {
+		CFDictionarySetValue(private, kSecAttrApplicationTag, tag);
+	}
+
+	if (accessGroup != NULL) {
+		CFDictionarySetValue(attrs, kSecAttrAccessGroup, accessGroup);
+	}
+
+	CFDictionarySetValue(attrs, kSecPrivateKeyAttrs, private);
+
+	SecKeyRef key = SecKeyCreateRandomKey(attrs, error);
+
+	if (access != NULL) {
+		CFRelease(access);
+	}
+
+	CFRelease(size);
+	CFRelease(private);
+	CFRelease(attrs);
+
+	return key;
+}
+
+// keyQuery returns a query for the private keys with tag, which must be
+// released.
+static CFMutableDictionaryRef keyQuery(CFDataRef tag, int dataProtection) {
+	CFMutableDictionaryRef query = CFDictionaryCreateMutable(NULL, 0,
+		&kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+
+	CFDictionarySetValue(query, kSecClass, kSecClassKey);
+	CFDictionarySetValue(query, kSecAttrKeyClass, kSecAttrKeyClassPrivate);
+	CFDictionarySetValue(query, kSecAttrApplicationTag, tag);
+
+	if (dataProtection) {
+		CFDictionarySetValue(query, kSecUseDataProtectionKeychain, kCFBooleanTrue);
+	}
+
+	return query;
+}
+
+static OSStatus loadKey(CFDataRef tag, int dataProtection, SecKeyRef *key) {
+	CFMutableDictionaryRef query = keyQuery(tag, dataProtection);
+
+	CFDictionarySetValue(query, kSecReturnRef, kCFBooleanTrue);
+	CFDictionarySetValue(query, kSecMatchLimit, kSecMatchLimitOne);
+
+	OSStatus status = SecItemCopyMatching(query, (CFTypeRef *)key);
+	CFRelease(query);
+
+	return status;
+}
+
+static OSStatus deleteKey(CFDataRef tag, int dataProtection) {
+	CFMutableDictionaryRef query = keyQuery(tag, dataProtection);
+	OSStatus status = SecItemDelete(query);
+	CFRelease(query);
+
+	return status;
+}
+
+static OSStatus deleteKeyRef(SecKeyRef key, int dataProtection) {
+	CFMutableDictionaryRef query = CFDictionaryCreateMutable(NULL, 0,
+		&kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+
+	CFDictionarySetValue(query, kSecClass, kSecClassKey);
+	CFDictionarySetValue(query, kSecValueRef, key);
+
+	if (dataProtection) {
+		CFDictionarySetValue(query, kSecUseDataProtectionKeychain, kCFBooleanTrue);
+	}
+
+	OSStatus status = SecItemDelete(query);
+	CFRelease(query);
+
+	return status;
+}
+
+// relabelKey sets the application label, and the label unless it is NULL, of
+// the keychain item of key.
+static OSStatus relabelKey(SecKeyRef key, CFDataRef applicationLabel, CFStringRef label, int dataProtection) {
+	CFMutableDictionaryRef query = CFDictionaryCreateMutable(NULL, 0,
+		&kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+	CFMutableDictionaryRef attrs = CFDictionaryCreateMutable(NULL, 0,
+		&kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+
+	CFDictionarySetValue(query, kSecClass, kSecClassKey);
+	CFDictionarySetValue(query, kSecValueRef, key);
+
+	if (dataProtection) {
+		CFDictionarySetValue(query, kSecUseDataProtectionKeychain, kCFBooleanTrue);
+	}
+
+	CFDictionarySetValue(attrs, kSecAttrApplicationLabel, applicationLabel);
+
+	if (label != NULL) {
+		CFDictionarySetValue(attrs, kSecAttrLabel, label);
+	}
+
+	OSStatus status = SecItemUpdate(query, attrs);
+	CFRelease(attrs);
+	CFRelease(query);
+
+	return status;
+}
+
+// keyInfo returns whether key is an EC key, and its size.
+static int keyInfo(SecKeyRef key, int *isEC, int *bits) {
+	CFDictionaryRef attrs = SecKeyCopyAttributes(key);
+	if (attrs == NULL) {
+		return 0;
+	}
+
+	CFTypeRef keyType = CFDictionaryGetValue(attrs, kSecAttrKeyType);
+	CFNumberRef size = CFDictionaryGetValue(attrs, kSecAttrKeySizeInBits);
+
+	*isEC = keyType != NULL && CFEqual(keyType, kSecAttrKeyTypeECSECPrimeRandom);
+
+	int ok = size != NULL && CFNumberGetValue(size, kCFNumberIntType, bits);
+	CFRelease(attrs);
+
+	return ok;
+}
+
+// copyPublicKeyData returns the external representation of the public key of
+// key: an ANSI X9.63 point for EC keys, PKCS #1 for RSA keys.
+static CFDataRef copyPublicKeyData(SecKeyRef key, CFErrorRef *error) {
+	SecKeyRef public = SecKeyCopyPublicKey(key);
+	if (public == NULL) {
+		return NULL;
+	}
+
+	CFDataRef data = SecKeyCopyExternalRepresentation(public, error);
+	CFRelease(public);
+
+	return data;
+}
+
+// importKey creates a private key from its external representation, and adds
+// it to the keychain if permanent. label, tag and accessGroup may be NULL. A
+// failure to add it is returned in status.
+static SecKeyRef importKey(CFDataRef data, CFStringRef keyType, int bits, int permanent, int dataProtection,
+	CFStringRef label, CFDataRef tag, CFStringRef accessGroup, CFErrorRef *error, OSStatus *status) {
+	CFMutableDictionaryRef attrs = CFDictionaryCreateMutable(NULL, 0,
+		&kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+	CFNumberRef size = CFNumberCreate(NULL, kCFNumberIntType, &bits);
+
+	CFDictionarySetValue(attrs, kSecAttrKeyType, keyType);
+	CFDictionarySetValue(attrs, kSecAttrKeyClass, kSecAttrKeyClassPrivate);
+	CFDictionarySetValue(attrs, kSecAttrKeySizeInBits, size);
+
+	SecKeyRef key = SecKeyCreateWithData(data, attrs, error);
+	CFRelease(size);
+	CFRelease(attrs);
+
+	if (key == NULL || !permanent) {
+		return key;
+	}
+
+	CFMutableDictionaryRef item = CFDictionaryCreateMutable(NULL, 0,
+		&kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+
+	CFDictionarySetValue(item, kSecClass, kSecClassKey);
+	CFDictionarySetValue(item, kSecValueRef, key);
+
+	if (dataProtection) {
+		CFDictionarySetValue(item, kSecUseDataProtectionKeychain, kCFBooleanTrue);
+	}
+
+	if (label != NULL) {
+		CFDictionarySetValue(item, kSecAttrLabel, label);
+	}
+
+	if (tag != NULL) {
+		CFDictionarySetValue(item, kSecAttrApplicationTag, tag);
+	}
+
+	if (accessGroup != NULL) {
+		CFDictionarySetValue(item, kSecAttrAccessGroup, accessGroup);
+	}
+
+	*status = SecItemAdd(item, NULL);
+	CFRelease(item);
+
+	if (*status != errSecSuccess) {
+		CFRelease(key);
+
+		return NULL;
+	}
+
+	return key;
+}
+
+static int verifySignature(SecKeyRef key, SecKeyAlgorithm algorithm, CFDataRef digest, CFDataRef sig,
+	CFErrorRef *error) {
+	SecKeyRef public = SecKeyCopyPublicKey(key);
+	if (public == NULL) {
+		return 0;
+	}
+
+	int ok = SecKeyVerifySignature(public, algorithm, digest, sig, error);
+	CFRelease(public);
+
+	return ok;
+}
+
+// keyExchange returns the ECDH shared secret of key and the X9.63 public key
+// peer.
+static CFDataRef keyExchange(SecKeyRef key, CFDataRef peer, CFErrorRef *error) {
+	const void *keys[] = {kSecAttrKeyType, kSecAttrKeyClass};
+	const void *values[] = {kSecAttrKeyTypeECSECPrimeRandom, kSecAttrKeyClassPublic};
+	CFDictionaryRef attrs = CFDictionaryCreate(NULL, keys, values, 2,
+		&kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+	SecKeyRef peerKey = SecKeyCreateWithData(peer, attrs, error);
+	CFRelease(attrs);
+
+	if (peerKey == NULL) {
+		return NULL;
+	}
+
+	CFDictionaryRef params = CFDictionaryCreate(NULL, NULL, NULL, 0,
+		&kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+	CFDataRef secret = SecKeyCopyKeyExchangeResult(key, kSecKeyAlgorithmECDHKeyExchangeStandard, peerKey,
+		params, error);
+
+	CFRelease(params);
+	CFRelease(peerKey);
+
+	return secret;
+}
+*/
+import "C"
+import (
+	"crypto"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+var keyTypes = map[KeyType]C.CFStringRef{
+	KeyTypeEC:  C.kSecAttrKeyTypeECSECPrimeRandom,
+	KeyTypeRSA: C.kSecAttrKeyTypeRSA,
+}
+
+// cfError converts and releases a CFError.
+func cfError(e C.CFErrorRef) error {
+	if e == 0 {
+		return errors.New("unknown error")
+	}
+
+	defer Release(C.CFTypeRef(e))
+
+	desc := C.CFErrorCopyDescription(e) // nolint: nlreturn
+	defer Release(C.CFTypeRef(desc))
+
+	return fmt.Errorf("%s: %w", CFStringToString(desc), Error(C.CFErrorGetCode(e))) // nolint: nlreturn
+}
+
+// usesDataProtection returns whether permanent keys are stored in the data
+// protection keychain, which macOS requires for Secure Enclave keys.
+func usesDataProtection() C.int {
+	if runtime.GOOS == "darwin" && CheckFeature(FeatureDataProtectionKeychain) == nil {
+		return 1
+	}
+
+	return 0
+}
+
+func boolInt(b bool) C.int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+func generateKey(opts KeyOptions) (uintptr, error) {
+	if err := CheckFeature(FeatureSecureEnclaveKeys); err != nil {
+		return 0, err
+	}
+
+	cfKeyType, ok := keyTypes[opts.Type]
+	if !ok {
+		return 0, fmt.Errorf("unknown key type %d", opts.Type)
+	}
+
+	if opts.Type == KeyTypeEC && curves[opts.Bits] == nil {
+		return 0, fmt.Errorf("unsupported EC key size %d", opts.Bits)
+	}
+
+	var label, accessGroup C.CFStringRef
+
+	var tag C.CFDataRef
+
+	var err error
+
+	if opts.Label != "" {
+		if label, err = StringToCFString(opts.Label); err != nil {
+			return 0, err
+		}
+
+		defer releaseCFString(label)
+	}
+
+	if opts.AccessGroup != "" {
+		if accessGroup, err = StringToCFString(opts.AccessGroup); err != nil {
+			return 0, err
+		}
+
+		defer releaseCFString(accessGroup)
+	}
+
+	if opts.Tag != nil {
+		if tag, err = BytesToCFData(opts.Tag); err != nil {
+			return 0, err
+		}
+
+		defer releaseCFData(tag)
+	}
+
+	dataProtection := C.int(0)
+	if opts.Permanent {
+		dataProtection = usesDataProtection()
+	}
+
+	var cfErr C.CFErrorRef
+
+	key := C.generateKey(cfKeyType, C.int(opts.Bits), boolInt(opts.Permanent), boolInt(opts.SecureEnclave),
+		dataProtection, label, tag, accessGroup, &cfErr) // nolint: nlreturn
+	if key == 0 {
+		return 0, fmt.Errorf("failed to create key: %w", cfError(cfErr))
+	}
+
+	return uintptr(key), nil
+}
+
+func importKey(k ExternalKey, opts KeyOptions) (uintptr, error) {
+	data, err := BytesToCFData(k.Data)
+	if err != nil {
+		return 0, err
+	}
+
+	defer releaseCFData(data)
+
+	var label, accessGroup C.CFStringRef
+
+	var tag C.CFDataRef
+
+	if opts.Label != "" {
+		if label, err = StringToCFString(opts.Label); err != nil {
+			return 0, err
+		}
+
+		defer releaseCFString(label)
+	}
+
+	if opts.AccessGroup != "" {
+		if accessGroup, err = StringToCFString(opts.AccessGroup); err != nil {
+			return 0, err
+		}
+
+		defer releaseCFString(accessGroup)
+	}
+
+	if opts.Tag != nil {
+		if tag, err = BytesToCFData(opts.Tag); err != nil {
+			return 0, err
+		}
+
+		defer releaseCFData(tag)
+	}
+
+	dataProtection := C.int(0)
+	if opts.Permanent {
+		dataProtection = usesDataProtection()
+	}
+
+	var (
+		cfErr  C.CFErrorRef
+		status C.OSStatus
+	)
+
+	key := C.importKey(data, keyTypes[k.Type], C.int(k.Bits), boolInt(opts.Permanent), dataProtection,
+		label, tag, accessGroup, &cfErr, &status) // nolint: nlreturn
+	if key == 0 {
+		if status != C.errSecSuccess {
+			return 0, fmt.Errorf("failed to add key: %w", checkError(status))
+		}
+
+		return 0, fmt.Errorf("failed to import key: %w", cfError(cfErr))
+	}
+
+	return uintptr(key), nil
+}
+
+func exportKey(ref uintptr) ([]byte, error) {
+	var cfErr C.CFErrorRef
+
+	data := C.SecKeyCopyExternalRepresentation(C.SecKeyRef(ref), &cfErr) // nolint: nlreturn
+	if data == 0 {
+		return nil, fmt.Errorf("failed to export key: %w", cfError(cfErr))
+	}
+
+	defer Release(C.CFTypeRef(data))
+
+	return CFDataToBytes(data)
+}
+
+func loadKey(tag []byte) (uintptr, KeyType, int, error) {
+	cfTag, err := BytesToCFData(tag)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	defer releaseCFData(cfTag)
+
+	var key C.SecKeyRef
+
+	if err := checkError(C.loadKey(cfTag, usesDataProtection(), &key)); err != nil { // nolint: nlreturn
+		return 0, 0, 0, err
+	}
+
+	keyType, bits, err := keyRefInfo(key)
+	if err != nil {
+		Release(C.CFTypeRef(key))
+
+		return 0, 0, 0, err
+	}
+
+	return uintptr(key), keyType, bits, nil
+}
+
+// keyRefInfo returns the type and size of key.
+func keyRefInfo(key C.SecKeyRef) (KeyType, int, error) {
+	var isEC, bits C.int
+
+	if C.keyInfo(key, &isEC, &bits) == 0 { // nolint: nlreturn
+		return 0, 0, errors.New("failed to read key attributes")
+	}
+
+	if isEC != 0 {
+		return KeyTypeEC, int(bits), nil
+	}
+
+	return KeyTypeRSA, int(bits), nil
+}
+
+func relabelKey(ref uintptr, applicationLabel []byte, label string) error {
+	cfApplicationLabel, err := BytesToCFData(applicationLabel)
+	if err != nil {
+		return err
+	}
+
+	defer releaseCFData(cfApplicationLabel)
+
+	var cfLabel C.CFStringRef
+
+	if label != "" {
+		if cfLabel, err = StringToCFString(label); err != nil {
+			return err
+		}
+
+		defer releaseCFString(cfLabel)
+	}
+
+	return checkError(C.relabelKey(C.SecKeyRef(ref), cfApplicationLabel, cfLabel, usesDataProtection())) // nolint: nlreturn
+}
+
+func deleteKey(tag []byte) error {
+	cfTag, err := BytesToCFData(tag)
+	if err != nil {
+		return err
+	}
+
+	defer releaseCFData(cfTag)
+
+	return checkError(C.deleteKey(cfTag, usesDataProtection())) // nolint: nlreturn
+}
+
+func deleteKeyRef(ref uintptr) error {
+	return checkError(C.deleteKeyRef(C.SecKeyRef(ref), usesDataProtection())) // nolint: nlreturn
+}
+
+func publicKey(ref uintptr, keyType KeyType, _ int) (crypto.PublicKey, error) {
+	var cfErr C.CFErrorRef
+
+	data := C.copyPublicKeyData(C.SecKeyRef(ref), &cfErr) // nolint: nlreturn
+	if data == 0 {
+		return nil, fmt.Errorf("failed to export public key: %w", cfError(cfErr))
+	}
+
+	defer Release(C.CFTypeRef(data))
+
+	b, err := CFDataToBytes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	k, err := ParseExternalKey(keyType, false, b)
+	if err != nil {
+		return nil, err
+	}
+
+	return k.PublicKey()
+}
+
+// signatureAlgorithm returns the SecKeyAlgorithm for signing digests with
+// keyType and opts.
+func signatureAlgorithm(keyType KeyType, opts crypto.SignerOpts) (C.SecKeyAlgorithm, error) {
+	pss, isPSS := opts.(*rsa.PSSOptions)
+
+	switch {
+	case keyType == KeyTypeEC:
+		switch opts.HashFunc() {
+		case crypto.SHA1:
+			return C.kSecKeyAlgorithmECDSASignatureDigestX962SHA1, nil
+		case crypto.SHA256:
+			return C.kSecKeyAlgorithmECDSASignatureDigestX962SHA256, nil
+		case crypto.SHA384:
+			return C.kSecKeyAlgorithmECDSASignatureDigestX962SHA384, nil
+		case crypto.SHA512:
+			return C.kSecKeyAlgorithmECDSASignatureDigestX962SHA512, nil
+		}
+	case isPSS:
+		if pss.SaltLength != rsa.PSSSaltLengthEqualsHash {
+			return 0, errors.New("only PSS salt lengths equal to the hash are supported")
+		}
+
+		switch opts.HashFunc() {
+		case crypto.SHA256:
+			return C.kSecKeyAlgorithmRSASignatureDigestPSSSHA256, nil
+		case crypto.SHA384:
+			return C.kSecKeyAlgorithmRSASignatureDigestPSSSHA384, nil
+		case crypto.SHA512:
+			return C.kSecKeyAlgorithmRSASignatureDigestPSSSHA512, nil
+		}
+	default:
+		switch opts.HashFunc() {
+		case crypto.SHA1:
+			return C.kSecKeyAlgorithmRSASignatureDigestPKCS1v15SHA1, nil
+		case crypto.SHA256:
+			return C.kSecKeyAlgorithmRSASignatureDigestPKCS1v15SHA256, nil
+		case crypto.SHA384:
+			return C.kSecKeyAlgorithmRSASignatureDigestPKCS1v15SHA384, nil
+		case crypto.SHA512:
+			return C.kSecKeyAlgorithmRSASignatureDigestPKCS1v15SHA512, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unsupported hash %v", opts.HashFunc())
+}
+
+func signWithKey(ref uintptr, keyType KeyType, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	algorithm, err := signatureAlgorithm(keyType, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	cfDigest, err := BytesToCFData(digest)
+	if err != nil {
+		return nil, err
+	}
+
+	defer releaseCFData(cfDigest)
+
+	var cfErr C.CFErrorRef
+
+	sig := C.SecKeyCreateSignature(C.SecKeyRef(ref), algorithm, cfDigest, &cfErr) // nolint: nlreturn
+	if sig == 0 {
+		return nil, fmt.Errorf("failed to sign: %w", cfError(cfErr))
+	}
+
+	defer releaseCFData(sig)
+
+	return CFDataToBytes(sig)
+}
+
+func verifyWithKey(ref uintptr, keyType KeyType, digest, sig []byte, opts crypto.SignerOpts) error {
+	algorithm, err := signatureAlgorithm(keyType, opts)
+	if err != nil {
+		return err
+	}
+
+	cfDigest, err := BytesToCFData(digest)
+	if err != nil {
+		return err
+	}
+
+	defer releaseCFData(cfDigest)
+
+	cfSig, err := BytesToCFData(sig)
+	if err != nil {
+		return err
+	}
+
+	defer releaseCFData(cfSig)
+
+	var cfErr C.CFErrorRef
+
+	if C.verifySignature(C.SecKeyRef(ref), algorithm, cfDigest, cfSig, &cfErr) == 0 { // nolint: nlreturn
+		return fmt.Errorf("invalid signature: %w", cfError(cfErr))
+	}
+
+	return nil
+}
+
+func keyExchange(ref uintptr, peer []byte) ([]byte, error) {
+	cfPeer, err := BytesToCFData(peer)
+	if err != nil {
+		return nil, err
+	}
+
+	defer releaseCFData(cfPeer)
+
+	var cfErr C.CFErrorRef
+
+	secret := C.keyExchange(C.SecKeyRef(ref), cfPeer, &cfErr) // nolint: nlreturn
+	if secret == 0 {
+		return nil, fmt.Errorf("failed to compute shared secret: %w", cfError(cfErr))
+	}
+
+	defer releaseCFData(secret)
+
+	return CFDataToBytes(secret)
+}
+
+func releaseKey(ref uintptr) {
+	Release(C.CFTypeRef(ref))
+}
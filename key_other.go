@@ -0,0 +1,52 @@
+//go:build !darwin
+// +build !darwin
+
+package keychain
+
+import "crypto"
+
+func generateKey(KeyOptions) (uintptr, error) {
+	return 0, ErrorUnimplemented
+}
+
+func importKey(ExternalKey, KeyOptions) (uintptr, error) {
+	return 0, ErrorUnimplemented
+}
+
+func exportKey(uintptr) ([]byte, error) {
+	return nil, ErrorUnimplemented
+}
+
+func loadKey([]byte) (uintptr, KeyType, int, error) {
+	return 0, 0, 0, ErrorUnimplemented
+}
+
+func relabelKey(uintptr, []byte, string) error {
+	return ErrorUnimplemented
+}
+
+func deleteKey([]byte) error {
+	return ErrorUnimplemented
+}
+
+func deleteKeyRef(uintptr) error {
+	return ErrorUnimplemented
+}
+
+func publicKey(uintptr, KeyType, int) (crypto.PublicKey, error) {
+	return nil, ErrorUnimplemented
+}
+
+func signWithKey(uintptr, KeyType, []byte, crypto.SignerOpts) ([]byte, error) {
+	return nil, ErrorUnimplemented
+}
+
+func verifyWithKey(uintptr, KeyType, []byte, []byte, crypto.SignerOpts) error {
+	return ErrorUnimplemented
+}
+
+func keyExchange(uintptr, []byte) ([]byte, error) {
+	return nil, ErrorUnimplemented
+}
+
+func releaseKey(uintptr) {}
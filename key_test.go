@@ -0,0 +1,331 @@
+//go:build darwin && !ios
+// +build darwin,!ios
+
+package keychain
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestEphemeralKeyEC(t *testing.T) {
+	digest := sha256.Sum256([]byte("toomanysecrets"))
+
+	err := WithEphemeralKey(KeyTypeEC, 256, func(k *Key) error {
+		sig, err := k.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			return err
+		}
+
+		if !ecdsa.VerifyASN1(k.Public().(*ecdsa.PublicKey), digest[:], sig) {
+			t.Fatal("invalid signature")
+		}
+
+		if err := k.Verify(digest[:], sig, crypto.SHA256); err != nil {
+			t.Fatal(err)
+		}
+
+		sig[len(sig)-1] ^= 1
+		if err := k.Verify(digest[:], sig, crypto.SHA256); err == nil {
+			t.Fatal("expected invalid signature")
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEphemeralKeyRSA(t *testing.T) {
+	k, err := NewEphemeralKey(KeyTypeRSA, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha256.Sum256([]byte("toomanysecrets"))
+	public := k.Public().(*rsa.PublicKey)
+
+	sig, err := k.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rsa.VerifyPKCS1v15(public, crypto.SHA256, digest[:], sig); err != nil {
+		t.Fatal(err)
+	}
+
+	pss := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+
+	sig, err = k.Sign(rand.Reader, digest[:], pss)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rsa.VerifyPSS(public, crypto.SHA256, digest[:], sig, pss); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := k.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := k.Sign(rand.Reader, digest[:], crypto.SHA256); !errors.Is(err, ErrKeyClosed) {
+		t.Fatalf("expected ErrKeyClosed, got %v", err)
+	}
+}
+
+func TestKeyECDH(t *testing.T) {
+	k, err := NewEphemeralKey(KeyTypeEC, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer k.Close()
+
+	peer, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret, err := k.ECDH(peer.PublicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	public, err := k.Public().(*ecdsa.PublicKey).ECDH()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := peer.ECDH(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(secret, want) {
+		t.Fatal("shared secrets differ")
+	}
+
+	other, _ := ecdh.P384().GenerateKey(rand.Reader)
+	if _, err := k.ECDH(other.PublicKey()); err == nil {
+		t.Fatal("expected error for a peer on another curve")
+	}
+}
+
+func TestImportKey(t *testing.T) {
+	priv, err := ecdh.P384().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := ImportKey(priv, KeyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer k.Close()
+
+	digest := sha256.Sum256([]byte("toomanysecrets"))
+
+	sig, err := k.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	external, err := k.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := external.PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ecdsa.VerifyASN1(&signer.(*ecdsa.PrivateKey).PublicKey, digest[:], sig) {
+		t.Fatal("the exported key doesn't match the imported one")
+	}
+
+	if _, err := ImportKey(priv.PublicKey(), KeyOptions{}); err == nil {
+		t.Fatal("expected an error importing a public key")
+	}
+}
+
+func TestPermanentKey(t *testing.T) {
+	tag := []byte("com.github.mailstone.go-keychain.test")
+
+	k, err := GenerateKey(KeyOptions{Type: KeyTypeEC, Permanent: true, Label: "go-keychain test", Tag: tag})
+	if errors.Is(err, ErrorMissingEntitlement) {
+		t.Skip("the data protection keychain requires a signed binary")
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	defer DeleteKey(tag) // nolint: errcheck
+	k.Close()
+
+	loaded, err := LoadKey(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+
+	if loaded.Type() != KeyTypeEC || !loaded.Public().(*ecdsa.PublicKey).Equal(k.Public()) {
+		t.Fatal("loaded another key")
+	}
+
+	if err := DeleteKey(tag); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadKey(tag); !errors.Is(err, ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound, got %v", err)
+	}
+}
+
+func TestPairCertificateWithKey(t *testing.T) {
+	tag := []byte("com.github.mailstone.go-keychain.test.pair")
+
+	k, err := GenerateKey(KeyOptions{Type: KeyTypeEC, Permanent: true, Tag: tag})
+	if errors.Is(err, ErrorMissingEntitlement) {
+		t.Skip("the data protection keychain requires a signed binary")
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	defer DeleteKey(tag) // nolint: errcheck
+	defer k.Close()
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "go-keychain pair test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, k.Public(), k)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := NewItem()
+	query.SetSecClass(SecClassCertificate)
+	query.SetLabel("go-keychain pair test")
+	defer DeleteItem(query) // nolint: errcheck
+
+	if err := PairCertificateWithKey(cert, k); err != nil {
+		t.Fatal(err)
+	}
+
+	query = NewItem()
+	query.SetLabel("go-keychain pair test")
+
+	ids, err := QueryIdentities(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ids) != 1 || !ids[0].Certificate().Equal(cert) {
+		t.Fatalf("expected the identity of the certificate, got %d", len(ids))
+	}
+
+	ids[0].Close()
+}
+
+func TestCertificateAgent(t *testing.T) {
+	tag := []byte("com.github.mailstone.go-keychain.test.agent")
+	defer DeleteKey(tag) // nolint: errcheck
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "go-keychain agent test CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	serial := int64(1)
+	enroller := EnrollerFunc(func(_ context.Context, csr *x509.CertificateRequest) ([]*x509.Certificate, error) {
+		serial++
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      csr.Subject,
+			NotBefore:    time.Now(),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, csr.PublicKey, caKey)
+		if err != nil {
+			return nil, err
+		}
+
+		cert, err := x509.ParseCertificate(der)
+
+		return []*x509.Certificate{cert}, err
+	})
+
+	template := &x509.CertificateRequest{Subject: pkix.Name{CommonName: "go-keychain agent test"}}
+	agent := NewCertificateAgent(template, enroller, RenewalPolicy{Key: KeyOptions{Tag: tag}})
+	defer agent.Close() // nolint: errcheck
+
+	query := NewItem()
+	query.SetSecClass(SecClassCertificate)
+	query.SetLabel("go-keychain agent test")
+	defer DeleteItem(query) // nolint: errcheck
+
+	err = agent.RenewNow(context.Background())
+	if errors.Is(err, ErrorMissingEntitlement) {
+		t.Skip("the data protection keychain requires a signed binary")
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := agent.GetClientCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := agent.RenewNow(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := agent.GetClientCertificate(nil)
+	if err != nil || second.Leaf.Equal(first.Leaf) || second.Leaf.SerialNumber.Int64() != 3 {
+		t.Fatalf("expected the renewed certificate, got %v (%v)", second.Leaf.SerialNumber, err)
+	}
+
+	restarted := NewCertificateAgent(template, enroller, RenewalPolicy{})
+	defer restarted.Close() // nolint: errcheck
+
+	if err := restarted.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !restarted.Certificate().Equal(second.Leaf) {
+		t.Fatal("expected the restarted agent to load the latest identity")
+	}
+}
@@ -1,22 +1,16 @@
-//go:build darwin
-// +build darwin
-
 package keychain
 
 // See https://developer.apple.com/library/ios/documentation/Security/Reference/keychainservices/index.html for the APIs used below.
 
 // Also see https://developer.apple.com/library/ios/documentation/Security/Conceptual/keychainServConcepts/01introduction/introduction.html .
 
-/*
-#cgo LDFLAGS: -framework CoreFoundation -framework Security
-
-#include <CoreFoundation/CoreFoundation.h>
-#include <Security/Security.h>
-*/
-import "C"
 import (
+	"errors"
 	"fmt"
+	"strconv"
 	"time"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 // Error defines keychain errors.
@@ -24,59 +18,55 @@ type Error int
 
 var (
 	// ErrorUnimplemented corresponds to errSecUnimplemented result code.
-	ErrorUnimplemented = Error(C.errSecUnimplemented)
+	ErrorUnimplemented = Error(-4)
 	// ErrorParam corresponds to errSecParam result code.
-	ErrorParam = Error(C.errSecParam)
+	ErrorParam = Error(-50)
 	// ErrorAllocate corresponds to errSecAllocate result code.
-	ErrorAllocate = Error(C.errSecAllocate)
+	ErrorAllocate = Error(-108)
 	// ErrorNotAvailable corresponds to errSecNotAvailable result code.
-	ErrorNotAvailable = Error(C.errSecNotAvailable)
+	ErrorNotAvailable = Error(-25291)
 	// ErrorAuthFailed corresponds to errSecAuthFailed result code.
-	ErrorAuthFailed = Error(C.errSecAuthFailed)
+	ErrorAuthFailed = Error(-25293)
 	// ErrorDuplicateItem corresponds to errSecDuplicateItem result code.
-	ErrorDuplicateItem = Error(C.errSecDuplicateItem)
+	ErrorDuplicateItem = Error(-25299)
 	// ErrorItemNotFound corresponds to errSecItemNotFound result code.
-	ErrorItemNotFound = Error(C.errSecItemNotFound)
+	ErrorItemNotFound = Error(-25300)
 	// ErrorInteractionNotAllowed corresponds to errSecInteractionNotAllowed result code.
-	ErrorInteractionNotAllowed = Error(C.errSecInteractionNotAllowed)
+	ErrorInteractionNotAllowed = Error(-25308)
 	// ErrorDecode corresponds to errSecDecode result code.
-	ErrorDecode = Error(C.errSecDecode)
+	ErrorDecode = Error(-26275)
 	// ErrorNoSuchKeychain corresponds to errSecNoSuchKeychain result code.
-	ErrorNoSuchKeychain = Error(C.errSecNoSuchKeychain)
+	ErrorNoSuchKeychain = Error(-25294)
 	// ErrorNoAccessForItem corresponds to errSecNoAccessForItem result code.
-	ErrorNoAccessForItem = Error(C.errSecNoAccessForItem)
+	ErrorNoAccessForItem = Error(-25243)
 	// ErrorReadOnly corresponds to errSecReadOnly result code.
-	ErrorReadOnly = Error(C.errSecReadOnly)
+	ErrorReadOnly = Error(-25292)
 	// ErrorInvalidKeychain corresponds to errSecInvalidKeychain result code.
-	ErrorInvalidKeychain = Error(C.errSecInvalidKeychain)
+	ErrorInvalidKeychain = Error(-25295)
 	// ErrorDuplicateKeyChain corresponds to errSecDuplicateKeychain result code.
-	ErrorDuplicateKeyChain = Error(C.errSecDuplicateKeychain)
+	ErrorDuplicateKeyChain = Error(-25296)
 	// ErrorWrongVersion corresponds to errSecWrongSecVersion result code.
-	ErrorWrongVersion = Error(C.errSecWrongSecVersion)
+	ErrorWrongVersion = Error(-25310)
 	// ErrorReadonlyAttribute corresponds to errSecReadOnlyAttr result code.
-	ErrorReadonlyAttribute = Error(C.errSecReadOnlyAttr)
+	ErrorReadonlyAttribute = Error(-25309)
 	// ErrorInvalidSearchRef corresponds to errSecInvalidSearchRef result code.
-	ErrorInvalidSearchRef = Error(C.errSecInvalidSearchRef)
+	ErrorInvalidSearchRef = Error(-25305)
 	// ErrorInvalidItemRef corresponds to errSecInvalidItemRef result code.
-	ErrorInvalidItemRef = Error(C.errSecInvalidItemRef)
+	ErrorInvalidItemRef = Error(-25304)
 	// ErrorDataNotAvailable corresponds to errSecDataNotAvailable result code.
-	ErrorDataNotAvailable = Error(C.errSecDataNotAvailable)
+	ErrorDataNotAvailable = Error(-25316)
 	// ErrorDataNotModifiable corresponds to errSecDataNotModifiable result code.
-	ErrorDataNotModifiable = Error(C.errSecDataNotModifiable)
+	ErrorDataNotModifiable = Error(-25317)
 	// ErrorInvalidOwnerEdit corresponds to errSecInvalidOwnerEdit result code.
-	ErrorInvalidOwnerEdit = Error(C.errSecInvalidOwnerEdit)
+	ErrorInvalidOwnerEdit = Error(-25244)
 	// ErrorUserCanceled corresponds to errSecUserCanceled result code.
-	ErrorUserCanceled = Error(C.errSecUserCanceled)
+	ErrorUserCanceled = Error(-128)
+	// ErrorMissingEntitlement corresponds to errSecMissingEntitlement result code.
+	ErrorMissingEntitlement = Error(-34018)
+	// ErrorNoSuchAttr corresponds to errSecNoSuchAttr result code.
+	ErrorNoSuchAttr = Error(-25303)
 )
 
-func checkError(errCode C.OSStatus) error {
-	if errCode == C.errSecSuccess {
-		return nil
-	}
-
-	return Error(errCode)
-}
-
 // nolint: gocyclo
 func (k Error) Error() (msg string) {
 	// SecCopyErrorMessageString is only available on OSX, so derive manually.
@@ -122,10 +112,14 @@ func (k Error) Error() (msg string) {
 		msg = "The data is not available."
 	case ErrorDataNotModifiable:
 		msg = "The data is not modifiable."
+	case ErrorMissingEntitlement:
+		msg = "A required entitlement isn't present."
 	case ErrorInvalidOwnerEdit:
 		msg = "An invalid attempt to change the owner of an item."
 	case ErrorUserCanceled:
 		msg = "User canceled the operation."
+	case ErrorNoSuchAttr:
+		msg = "The specified attribute does not exist."
 	default:
 		msg = "Keychain Error."
 	}
@@ -133,487 +127,332 @@ func (k Error) Error() (msg string) {
 	return fmt.Sprintf("%s (%d)", msg, k)
 }
 
-// SecClass is the items class code.
-type SecClass int
-
-// Keychain Item Classes.
-var (
-	/*
-		kSecClassGenericPassword item attributes:
-		 kSecAttrAccess (OS X only)
-		 kSecAttrAccessGroup (iOS; also OS X if kSecAttrSynchronizable specified)
-		 kSecAttrAccessible (iOS; also OS X if kSecAttrSynchronizable specified)
-		 kSecAttrAccount
-		 kSecAttrService
-	*/
-	SecClassGenericPassword  SecClass = 1
-	SecClassInternetPassword SecClass = 2
-	SecClassCertificate      SecClass = 3
-	SecClassPairKey          SecClass = 4
-)
-
-// SecClassKey is the key type for SecClass.
-var SecClassKey = attrKey(C.CFTypeRef(C.kSecClass))
-var secClassTypeRef = map[SecClass]C.CFTypeRef{
-	SecClassGenericPassword:  C.CFTypeRef(C.kSecClassGenericPassword),
-	SecClassInternetPassword: C.CFTypeRef(C.kSecClassInternetPassword),
-	SecClassCertificate:      C.CFTypeRef(C.kSecClassCertificate),
-	SecClassPairKey:          C.CFTypeRef(C.kSecClassKey),
-}
-
-var (
-	// ServiceKey is for kSecAttrService.
-	ServiceKey = attrKey(C.CFTypeRef(C.kSecAttrService))
-
-	// ServerKey is for kSecAttrServer.
-	ServerKey = attrKey(C.CFTypeRef(C.kSecAttrServer))
-	// ProtocolKey is for kSecAttrProtocol.
-	ProtocolKey = attrKey(C.CFTypeRef(C.kSecAttrProtocol))
-	// AuthenticationTypeKey is for kSecAttrAuthenticationType.
-	AuthenticationTypeKey = attrKey(C.CFTypeRef(C.kSecAttrAuthenticationType))
-	// PortKey is for kSecAttrPort.
-	PortKey = attrKey(C.CFTypeRef(C.kSecAttrPort))
-	// PathKey is for kSecAttrPath.
-	PathKey = attrKey(C.CFTypeRef(C.kSecAttrPath))
-
-	// LabelKey is for kSecAttrLabel.
-	LabelKey = attrKey(C.CFTypeRef(C.kSecAttrLabel))
-	// AccountKey is for kSecAttrAccount.
-	AccountKey = attrKey(C.CFTypeRef(C.kSecAttrAccount))
-	// AccessGroupKey is for kSecAttrAccessGroup.
-	AccessGroupKey = attrKey(C.CFTypeRef(C.kSecAttrAccessGroup))
-	// DataKey is for kSecValueData.
-	DataKey = attrKey(C.CFTypeRef(C.kSecValueData))
-	// DescriptionKey is for kSecAttrDescription.
-	DescriptionKey = attrKey(C.CFTypeRef(C.kSecAttrDescription))
-	// CommentKey is for kSecAttrComment.
-	CommentKey = attrKey(C.CFTypeRef(C.kSecAttrComment))
-	// CreationDateKey is for kSecAttrCreationDate.
-	CreationDateKey = attrKey(C.CFTypeRef(C.kSecAttrCreationDate))
-	// ModificationDateKey is for kSecAttrModificationDate.
-	ModificationDateKey = attrKey(C.CFTypeRef(C.kSecAttrModificationDate))
-)
-
-// Synchronizable is the items synchronizable status.
-type Synchronizable int
-
-const (
-	// SynchronizableDefault is the default setting.
-	SynchronizableDefault Synchronizable = 0
-	// SynchronizableAny is for kSecAttrSynchronizableAny.
-	SynchronizableAny = 1
-	// SynchronizableYes enables synchronization.
-	SynchronizableYes = 2
-	// SynchronizableNo disables synchronization.
-	SynchronizableNo = 3
-)
-
-// SynchronizableKey is the key type for Synchronizable.
-var SynchronizableKey = attrKey(C.CFTypeRef(C.kSecAttrSynchronizable))
-var syncTypeRef = map[Synchronizable]C.CFTypeRef{
-	SynchronizableAny: C.CFTypeRef(C.kSecAttrSynchronizableAny),
-	SynchronizableYes: C.CFTypeRef(C.kCFBooleanTrue),
-	SynchronizableNo:  C.CFTypeRef(C.kCFBooleanFalse),
+// AddItem adds a Item to a Keychain. The service, account and label are
+// stored in Unicode NFC, internet passwords without a port get the default
+// port of their protocol, and deprecated accessibilities are handled as set by
+// SetAccessiblePolicy.
+func AddItem(item Item) error {
+	return cur().addItem(item)
 }
 
-// Accessible is the items accessibility.
-type Accessible int
-
-const (
-	// AccessibleDefault is the default.
-	AccessibleDefault Accessible = 0
-	// AccessibleWhenUnlocked is when unlocked.
-	AccessibleWhenUnlocked = 1
-	// AccessibleAfterFirstUnlock is after first unlock.
-	AccessibleAfterFirstUnlock = 2
-	// AccessibleAlways is always.
-	AccessibleAlways = 3
-	// AccessibleWhenPasscodeSetThisDeviceOnly is when passcode is set.
-	AccessibleWhenPasscodeSetThisDeviceOnly = 4
-	// AccessibleWhenUnlockedThisDeviceOnly is when unlocked for this device only.
-	AccessibleWhenUnlockedThisDeviceOnly = 5
-	// AccessibleAfterFirstUnlockThisDeviceOnly is after first unlock for this device only.
-	AccessibleAfterFirstUnlockThisDeviceOnly = 6
-	// AccessibleAccessibleAlwaysThisDeviceOnly is always for this device only.
-	AccessibleAccessibleAlwaysThisDeviceOnly = 7
-)
+func (s *state) addItem(item Item) error {
+	attrs, err := EncodeAttributes(item)
+	if err != nil {
+		return fmt.Errorf("failed to encode item attributes: %w", err)
+	}
 
-// MatchLimit is whether to limit results on query.
-type MatchLimit int
+	normalizeAttrs(attrs)
+	inferPort(attrs)
+	s.applyAccessiblePolicy(attrs)
 
-const (
-	// MatchLimitDefault is the default.
-	MatchLimitDefault MatchLimit = 0
-	// MatchLimitOne limits to one result.
-	MatchLimitOne = 1
-	// MatchLimitAll is no limit.
-	MatchLimitAll = 2
-)
+	_, err = s.backend.Add(attrs)
 
-// MatchLimitKey is key type for MatchLimit.
-var MatchLimitKey = attrKey(C.CFTypeRef(C.kSecMatchLimit))
-var matchTypeRef = map[MatchLimit]C.CFTypeRef{
-	MatchLimitOne: C.CFTypeRef(C.kSecMatchLimitOne),
-	MatchLimitAll: C.CFTypeRef(C.kSecMatchLimitAll),
+	return s.countChange(err, attrs)
 }
 
-// ReturnAttributesKey is key type for kSecReturnAttributes.
-var ReturnAttributesKey = attrKey(C.CFTypeRef(C.kSecReturnAttributes))
-
-// ReturnDataKey is key type for kSecReturnData.
-var ReturnDataKey = attrKey(C.CFTypeRef(C.kSecReturnData))
+// AddItemReturningPersistentRef adds an item and returns its persistent
+// reference, which can be passed to SetPersistentRef to target the item in
+// later operations.
+func AddItemReturningPersistentRef(item Item) ([]byte, error) {
+	add := item.clone()
+	add.SetReturnPersistentRef(true)
 
-// ReturnRefKey is key type for kSecReturnRef.
-var ReturnRefKey = attrKey(C.CFTypeRef(C.kSecReturnRef))
-
-// Item for adding, querying or deleting.
-type Item struct {
-	// Values can be string, []byte, Convertable or CFTypeRef (constant).
-	attr map[string]interface{}
-}
+	attrs, err := EncodeAttributes(add)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode item attributes: %w", err)
+	}
 
-// SetSecClass sets the security class.
-func (k *Item) SetSecClass(sc SecClass) {
-	k.attr[SecClassKey] = secClassTypeRef[sc]
-}
+	s := cur()
+	normalizeAttrs(attrs)
+	inferPort(attrs)
+	s.applyAccessiblePolicy(attrs)
 
-// SetInt32 sets an int32 attribute for a string key.
-func (k *Item) SetInt32(key string, v int32) {
-	if v != 0 {
-		k.attr[key] = v
-	} else {
-		delete(k.attr, key)
+	result, err := s.backend.Add(attrs)
+	if err != nil {
+		return nil, err
 	}
-}
 
-// SetString sets a string attibute for a string key.
-func (k *Item) SetString(key string, s string) {
-	if s != "" {
-		k.attr[key] = s
-	} else {
-		delete(k.attr, key)
+	ref, ok := result.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("invalid persistent reference type: %T", result)
 	}
-}
-
-// SetService sets the service attribute (for generic application items).
-func (k *Item) SetService(s string) {
-	k.SetString(ServiceKey, s)
-}
-
-// SetServer sets the server attribute (for internet password items).
-func (k *Item) SetServer(s string) {
-	k.SetString(ServerKey, s)
-}
-
-// SetProtocol sets the protocol attribute (for internet password items).
-// Example values are: "htps", "http", "smb ".
-func (k *Item) SetProtocol(s string) {
-	k.SetString(ProtocolKey, s)
-}
-
-// SetAuthenticationType sets the authentication type attribute (for internet password items).
-func (k *Item) SetAuthenticationType(s string) {
-	k.SetString(AuthenticationTypeKey, s)
-}
-
-// SetPort sets the port attribute (for internet password items).
-func (k *Item) SetPort(v int32) {
-	k.SetInt32(PortKey, v)
-}
-
-// SetPath sets the path attribute (for internet password items).
-func (k *Item) SetPath(s string) {
-	k.SetString(PathKey, s)
-}
-
-// SetAccount sets the account attribute.
-func (k *Item) SetAccount(a string) {
-	k.SetString(AccountKey, a)
-}
 
-// SetLabel sets the label attribute.
-func (k *Item) SetLabel(l string) {
-	k.SetString(LabelKey, l)
+	return ref, s.countChange(nil, attrs)
 }
 
-// SetDescription sets the description attribute.
-func (k *Item) SetDescription(s string) {
-	k.SetString(DescriptionKey, s)
-}
-
-// SetComment sets the comment attribute.
-func (k *Item) SetComment(s string) {
-	k.SetString(CommentKey, s)
+// UpdateItem updates the queryItem with the parameters from updateItem. The
+// service, account and label are stored in Unicode NFC, and deprecated
+// accessibilities are handled as set by SetAccessiblePolicy.
+func UpdateItem(queryItem Item, updateItem Item) error {
+	return cur().updateItem(queryItem, updateItem)
 }
 
-// SetData sets the data attribute.
-func (k *Item) SetData(b []byte) {
-	if b != nil {
-		k.attr[DataKey] = b
-	} else {
-		delete(k.attr, DataKey)
+func (s *state) updateItem(queryItem Item, updateItem Item) error {
+	queryAttrs, err := EncodeAttributes(queryItem)
+	if err != nil {
+		return fmt.Errorf("failed to encode query item attributes: %w", err)
 	}
-}
-
-// SetAccessGroup sets the access group attribute.
-func (k *Item) SetAccessGroup(ag string) {
-	k.SetString(AccessGroupKey, ag)
-}
 
-// SetSynchronizable sets the synchronizable attribute.
-func (k *Item) SetSynchronizable(sync Synchronizable) {
-	if sync != SynchronizableDefault {
-		k.attr[SynchronizableKey] = syncTypeRef[sync]
-	} else {
-		delete(k.attr, SynchronizableKey)
+	attrs, err := EncodeAttributes(updateItem)
+	if err != nil {
+		return fmt.Errorf("failed to encode update item attributes: %w", err)
 	}
-}
 
-// SetAccessible sets the accessible attribute.
-func (k *Item) SetAccessible(accessible Accessible) {
-	if accessible != AccessibleDefault {
-		k.attr[AccessibleKey] = accessibleTypeRef[accessible]
-	} else {
-		delete(k.attr, AccessibleKey)
-	}
-}
+	normalizeAttrs(attrs)
+	s.applyAccessiblePolicy(attrs)
 
-// SetMatchLimit sets the match limit.
-func (k *Item) SetMatchLimit(matchLimit MatchLimit) {
-	if matchLimit != MatchLimitDefault {
-		k.attr[MatchLimitKey] = matchTypeRef[matchLimit]
-	} else {
-		delete(k.attr, MatchLimitKey)
-	}
-}
+	err = eachVariant(queryItem, func(query map[string]interface{}) error {
+		return s.backend.Update(query, attrs)
+	})
 
-// SetReturnAttributes sets the return value type on query.
-func (k *Item) SetReturnAttributes(b bool) {
-	k.attr[ReturnAttributesKey] = b
+	return s.countChange(err, queryAttrs, attrs)
 }
 
-// SetReturnData enables returning data on query.
-func (k *Item) SetReturnData(b bool) {
-	k.attr[ReturnDataKey] = b
+// ItemExists returns whether an item matching query exists. No attributes or
+// data are returned, so the item isn't decrypted and no access prompt is
+// shown for its data.
+func ItemExists(query Item) (bool, error) {
+	return cur().itemExists(query)
 }
 
-// SetReturnRef enables returning references on query.
-func (k *Item) SetReturnRef(b bool) {
-	k.attr[ReturnRefKey] = b
-}
+func (s *state) itemExists(query Item) (bool, error) {
+	q := query.clone()
+	q.SetMatchLimit(MatchLimitOne)
+	delete(q.attr, ReturnAttributesKey)
+	delete(q.attr, ReturnDataKey)
+	delete(q.attr, ReturnRefKey)
+	delete(q.attr, ReturnPersistentRefKey)
 
-// NewItem is a new empty keychain item.
-func NewItem() Item {
-	return Item{make(map[string]interface{})}
-}
+	if _, err := EncodeAttributes(q); err != nil {
+		return false, fmt.Errorf("failed to encode query attributes: %w", err)
+	}
 
-// NewGenericPassword creates a generic password item with the default keychain. This is a convenience method.
-func NewGenericPassword(service string, account string, label string, data []byte, accessGroup string) Item {
-	item := NewItem()
-	item.SetSecClass(SecClassGenericPassword)
-	item.SetService(service)
-	item.SetAccount(account)
-	item.SetLabel(label)
-	item.SetData(data)
-	item.SetAccessGroup(accessGroup)
+	err := eachVariant(q, func(query map[string]interface{}) error {
+		_, err := s.backend.CopyMatching(query)
 
-	return item
-}
+		return err
+	})
+	if errors.Is(err, ErrorItemNotFound) {
+		return false, nil
+	}
 
-// AddItem adds a Item to a Keychain.
-func AddItem(item Item) error {
-	cfDict, err := ConvertMapToCFDictionary(item.attr)
 	if err != nil {
-		return fmt.Errorf("failed to convert item attributes to CFDictionary: %w", err)
+		return false, err
 	}
 
-	defer Release(C.CFTypeRef(cfDict))
-
-	errCode := C.SecItemAdd(cfDict, nil) // nolint:nlreturn
-	err = checkError(errCode)
+	return true, nil
+}
 
-	return err
+// QueryItem returns a list of query results. Reads of generic password data
+// are recorded if usage tracking is enabled, see SetUsageTracking, and reads
+// with an access prompt may be answered from the prompt cache, see
+// SetPromptCache.
+func QueryItem(item Item) ([]QueryResult, error) {
+	return cur().queryItem(item)
 }
 
-// UpdateItem updates the queryItem with the parameters from updateItem.
-func UpdateItem(queryItem Item, updateItem Item) error {
-	cfDict, err := ConvertMapToCFDictionary(queryItem.attr)
+func (s *state) queryItem(item Item) ([]QueryResult, error) {
+	variants, err := queryVariants(item)
 	if err != nil {
-		return fmt.Errorf("failed to convert query item attributes to CFDictionary: %w", err)
+		return nil, fmt.Errorf("failed to encode query attributes: %w", err)
 	}
 
-	defer Release(C.CFTypeRef(cfDict))
-
-	cfDictUpdate, err := ConvertMapToCFDictionary(updateItem.attr)
-	if err != nil {
-		return fmt.Errorf("failed to convert update item attributes to CFDictionary: %w", err)
+	if s.shared {
+		if results, ok := cachedResult(variants[0]); ok {
+			return results, nil
+		}
 	}
 
-	defer Release(C.CFTypeRef(cfDictUpdate))
-
-	errCode := C.SecItemUpdate(cfDict, cfDictUpdate) // nolint:nlreturn
+	var results []QueryResult
 
-	err = checkError(errCode)
+	for _, query := range variants {
+		r, err := s.queryResults(query)
+		if err != nil {
+			return nil, err
+		}
 
-	return err
-}
+		results = append(results, r...)
 
-// QueryResult stores all possible results from queries.
-// Not all fields are applicable all the time. Results depend on query.
-type QueryResult struct {
-	// For generic application items.
-	Service string
-
-	// For internet password items.
-	Server             string
-	Protocol           string
-	AuthenticationType string
-	Port               int32
-	Path               string
-
-	Account          string
-	AccessGroup      string
-	Label            string
-	Description      string
-	Comment          string
-	Data             []byte
-	CreationDate     time.Time
-	ModificationDate time.Time
-}
-
-// QueryItemRef returns query result as CFTypeRef. You must release it when you are done.
-func QueryItemRef(item Item) (C.CFTypeRef, error) {
-	cfDict, err := ConvertMapToCFDictionary(item.attr)
-	if err != nil {
-		return 0, err
+		if len(results) > 0 && query[MatchLimitKey] != "m_LimitAll" {
+			break
+		}
 	}
-	defer Release(C.CFTypeRef(cfDict))
 
-	var resultsRef C.CFTypeRef
+	s.recordUses(variants[0], results)
 
-	errCode := C.SecItemCopyMatching(cfDict, &resultsRef) //nolint
-	if Error(errCode) == ErrorItemNotFound {
-		return 0, nil
-	}
-
-	err = checkError(errCode)
-	if err != nil {
-		return 0, err
+	if s.shared {
+		cacheResult(variants[0], results, s.cfg.PromptCacheWindow)
 	}
 
-	return resultsRef, nil
+	return results, nil
 }
 
-// QueryItem returns a list of query results.
-func QueryItem(item Item) ([]QueryResult, error) {
-	resultsRef, err := QueryItemRef(item)
+// queryResults returns the results for an encoded query.
+func (s *state) queryResults(query map[string]interface{}) ([]QueryResult, error) {
+	result, err := s.backend.CopyMatching(query)
+	if errors.Is(err, ErrorItemNotFound) {
+		return nil, nil
+	}
+
 	if err != nil {
 		return nil, err
 	}
-	if resultsRef == 0 {
-		return nil, nil
-	}
-	defer Release(resultsRef)
 
 	results := make([]QueryResult, 0, 1)
 
-	typeID := C.CFGetTypeID(resultsRef) //nolint:nlreturn
-
-	switch typeID {
-	case C.CFArrayGetTypeID():
-		arr := CFArrayToArray(C.CFArrayRef(resultsRef))
-		for _, ref := range arr {
-			elementTypeID := C.CFGetTypeID(ref) //nolint:nlreturn
-			if elementTypeID == C.CFDictionaryGetTypeID() {
-				item, err := convertResult(C.CFDictionaryRef(ref))
-				if err != nil {
-					return nil, fmt.Errorf("failed to convert CFDictionary to QueryResult: %w", err)
-				}
-
-				results = append(results, *item)
-			} else {
+	switch r := result.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		for _, e := range r {
+			d, ok := e.(map[string]interface{})
+			if !ok {
 				return nil, fmt.Errorf("invalid result type (If you SetReturnRef(true) you should use QueryItemRef directly)")
 			}
-		}
-	case C.CFDictionaryGetTypeID():
-		item, err := convertResult(C.CFDictionaryRef(resultsRef))
-		if err != nil {
-			return nil, fmt.Errorf("failed to convert CFDictionary to QueryResult: %w", err)
-		}
 
-		results = append(results, *item)
-	case C.CFDataGetTypeID():
-		b, err := CFDataToBytes(C.CFDataRef(resultsRef))
-		if err != nil {
-			return nil, fmt.Errorf("failed to convert CFData to bytes: %w", err)
+			results = append(results, convertResult(d))
+			results[len(results)-1].ID = resultID(query[SecClassKey], results[len(results)-1])
 		}
-
-		item := QueryResult{Data: b}
-		results = append(results, item)
+	case map[string]interface{}:
+		results = append(results, convertResult(r))
+		results[0].ID = resultID(query[SecClassKey], results[0])
+	case []byte:
+		results = append(results, QueryResult{Data: r})
 	default:
-		return nil, fmt.Errorf("invalid result type: %s", CFTypeDescription(resultsRef))
+		return nil, fmt.Errorf("invalid result type: %T", result)
 	}
 
-	return results, nil
-}
+	for _, r := range results {
+		if err := r.VerifyIntegrity(); err != nil {
+			return nil, err
+		}
+	}
 
-func attrKey(ref C.CFTypeRef) string {
-	return CFStringToString(C.CFStringRef(ref))
+	return results, nil
 }
 
-func convertResult(d C.CFDictionaryRef) (*QueryResult, error) {
-	m := CFDictionaryToMap(d)
-
+// convertResult converts a decoded attribute dictionary to a QueryResult.
+// Values of unexpected types are ignored.
+func convertResult(d map[string]interface{}) QueryResult {
 	result := QueryResult{}
 
-	for k, v := range m {
-		switch attrKey(k) {
+	for k, v := range d {
+		switch k {
 		case ServiceKey:
-			result.Service = CFStringToString(C.CFStringRef(v))
+			result.Service, _ = v.(string)
 		case ServerKey:
-			result.Server = CFStringToString(C.CFStringRef(v))
+			result.Server, _ = v.(string)
 		case ProtocolKey:
-			result.Protocol = CFStringToString(C.CFStringRef(v))
+			result.Protocol, _ = v.(string)
 		case AuthenticationTypeKey:
-			result.AuthenticationType = CFStringToString(C.CFStringRef(v))
+			result.AuthenticationType, _ = v.(string)
 		case PortKey:
-			val := CFNumberToInterface(C.CFNumberRef(v))
-
-			port, ok := val.(int32)
-			if !ok {
-				return nil, fmt.Errorf("expected int32 for PortKey, got %T", val)
+			if port, ok := int64Value(v); ok {
+				result.Port = int32(port)
 			}
-
-			result.Port = port
 		case PathKey:
-			result.Path = CFStringToString(C.CFStringRef(v))
+			result.Path, _ = v.(string)
+		case ApplicationTagKey:
+			result.ApplicationTag, _ = v.([]byte)
+		case KeySizeInBitsKey:
+			if bits, ok := int64Value(v); ok {
+				result.KeySizeInBits = int(bits)
+			}
 		case AccountKey:
-			result.Account = CFStringToString(C.CFStringRef(v))
+			result.Account, _ = v.(string)
 		case AccessGroupKey:
-			result.AccessGroup = CFStringToString(C.CFStringRef(v))
+			result.AccessGroup, _ = v.(string)
 		case LabelKey:
-			result.Label = CFStringToString(C.CFStringRef(v))
+			result.Label, _ = v.(string)
 		case DescriptionKey:
-			result.Description = CFStringToString(C.CFStringRef(v))
+			result.Description, _ = v.(string)
 		case CommentKey:
-			result.Comment = CFStringToString(C.CFStringRef(v))
+			result.Comment, _ = v.(string)
+		case TypeKey:
+			result.Type = fourCharCodeValue(v)
+			result.KeyType = keyTypeValue(v)
+		case CreatorKey:
+			result.Creator = fourCharCodeValue(v)
+		case GenericKey:
+			result.Generic, _ = v.([]byte)
 		case DataKey:
-			b, err := CFDataToBytes(C.CFDataRef(v))
-			if err != nil {
-				return nil, fmt.Errorf("failed to convert CFData to bytes: %w", err)
-			}
-
-			result.Data = b
+			result.Data, _ = v.([]byte)
 		case CreationDateKey:
-			result.CreationDate = CFDateToTime(C.CFDateRef(v))
+			result.CreationDate, _ = v.(time.Time)
 		case ModificationDateKey:
-			result.ModificationDate = CFDateToTime(C.CFDateRef(v))
-			// default:
-			// fmt.Printf("Unhandled key in conversion: %v = %v\n", cfTypeValue(k), cfTypeValue(v))
+			result.ModificationDate, _ = v.(time.Time)
+		case ValuePersistentRefKey:
+			result.PersistentRef, _ = v.([]byte)
+		case AccessibleKey:
+			result.Accessible = accessibleValue(v)
+		}
+	}
+
+	result.NormalizedService = norm.NFC.String(result.Service)
+	result.NormalizedAccount = norm.NFC.String(result.Account)
+	result.NormalizedLabel = norm.NFC.String(result.Label)
+
+	return result
+}
+
+func int64Value(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	default:
+		return 0, false
+	}
+}
+
+// accessibleValue converts an accessible attribute to an Accessible, or
+// AccessibleDefault if it is unknown.
+func accessibleValue(v interface{}) Accessible {
+	s, _ := v.(string)
+
+	for a, value := range accessibleValues {
+		if value == s {
+			return a
 		}
 	}
 
-	return &result, nil
+	return AccessibleDefault
+}
+
+// keyTypeValue converts the type attribute of a key item, a number or its
+// string form (kSecAttrKeyTypeRSA is "42", kSecAttrKeyTypeECSECPrimeRandom is
+// "73"), to a KeyType, or 0 for other items.
+func keyTypeValue(v interface{}) KeyType {
+	if s, ok := v.(string); ok {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return 0
+		}
+
+		v = n
+	}
+
+	switch n, _ := int64Value(v); n {
+	case 42:
+		return KeyTypeRSA
+	case 73:
+		return KeyTypeEC
+	default:
+		return 0
+	}
+}
+
+// fourCharCodeValue converts a type or creator attribute to its string form.
+func fourCharCodeValue(v interface{}) string {
+	n, ok := int64Value(v)
+	if !ok {
+		return ""
+	}
+
+	return FourCharCodeString(uint32(n))
 }
 
 // DeleteGenericPasswordItem removes a generic password item.
@@ -628,16 +467,16 @@ func DeleteGenericPasswordItem(service string, account string) error {
 
 // DeleteItem removes a Item.
 func DeleteItem(item Item) error {
-	cfDict, err := ConvertMapToCFDictionary(item.attr)
+	return cur().deleteItem(item)
+}
+
+func (s *state) deleteItem(item Item) error {
+	attrs, err := EncodeAttributes(item)
 	if err != nil {
-		return fmt.Errorf("failed to convert item to CFDictionary: %w", err)
+		return fmt.Errorf("failed to encode item attributes: %w", err)
 	}
 
-	defer Release(C.CFTypeRef(cfDict))
-
-	errCode := C.SecItemDelete(cfDict) // nolint:nlreturn
-
-	return checkError(errCode)
+	return s.countChange(eachVariant(item, s.backend.Delete), attrs)
 }
 
 // GetAccountsForService is deprecated.
@@ -666,25 +505,86 @@ func GetGenericPasswordAccounts(service string) ([]string, error) {
 	return accounts, nil
 }
 
+// upsertGenericPassword sets the data of the generic password for service and
+// account, adding the item if it doesn't exist.
+func upsertGenericPassword(service, account, accessGroup string, data []byte) error {
+	return cur().upsertGenericPassword(service, account, accessGroup, data)
+}
+
+func (s *state) upsertGenericPassword(service, account, accessGroup string, data []byte) error {
+	s, end, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	query := s.newItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService(service)
+	query.SetAccount(account)
+
+	if accessGroup != "" {
+		query.SetAccessGroup(accessGroup)
+	}
+
+	query.SetMatchLimit(MatchLimitAll)
+	query.SetReturnAttributes(true)
+
+	results, err := s.queryItem(query)
+	if err != nil {
+		return err
+	}
+
+	if len(results) == 0 {
+		return s.addItem(s.newGenericPassword(service, account, "", data, accessGroup))
+	}
+
+	for _, r := range results {
+		update := newItem()
+		update.SetData(data)
+
+		if m := r.Metadata(); refreshChecksum(m, data) {
+			if err := update.SetMetadata(m); err != nil {
+				return err
+			}
+		}
+
+		if err := s.updateItem(queryForResult(SecClassGenericPassword, r), update); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // GetGenericPassword returns password data for service and account. This is a convenience method.
 // If item is not found returns nil, nil.
 func GetGenericPassword(service string, account string, label string, accessGroup string) ([]byte, error) {
-	query := NewItem()
+	return cur().getGenericPassword(service, account, label, accessGroup)
+}
+
+func (s *state) getGenericPassword(service, account, label, accessGroup string) ([]byte, error) {
+	query := s.newItem()
 	query.SetSecClass(SecClassGenericPassword)
 	query.SetService(service)
 	query.SetAccount(account)
 	query.SetLabel(label)
-	query.SetAccessGroup(accessGroup)
+
+	if accessGroup != "" {
+		query.SetAccessGroup(accessGroup)
+	}
+
 	query.SetMatchLimit(MatchLimitOne)
+	query.SetReturnAttributes(true)
 	query.SetReturnData(true)
 
-	results, err := QueryItem(query)
+	results, err := s.queryItem(query)
 	if err != nil {
 		return nil, err
 	}
 
 	if len(results) > 1 {
-		return nil, fmt.Errorf("too many results")
+		return nil, newTooManyResultsError(results)
 	}
 
 	if len(results) == 1 {
@@ -693,3 +593,25 @@ func GetGenericPassword(service string, account string, label string, accessGrou
 
 	return nil, nil
 }
+
+// queryForResult returns a query matching the primary key attributes of a
+// result, suitable for targeting that item with UpdateItem or DeleteItem.
+func queryForResult(secClass SecClass, r QueryResult) Item {
+	query := newItem()
+	query.SetSecClass(secClass)
+	query.SetAccount(r.Account)
+	query.SetAccessGroup(r.AccessGroup)
+
+	switch secClass {
+	case SecClassInternetPassword:
+		query.SetServer(r.Server)
+		query.SetProtocol(r.Protocol)
+		query.SetAuthenticationType(r.AuthenticationType)
+		query.SetPort(r.Port)
+		query.SetPath(r.Path)
+	default:
+		query.SetService(r.Service)
+	}
+
+	return query
+}
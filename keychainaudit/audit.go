@@ -0,0 +1,291 @@
+// Package keychainaudit exports the keychain activity of a program as audit
+// events in newline delimited JSON, e.g. to ship keychain access telemetry to
+// a SIEM pipeline:
+//
+//	exp := keychainaudit.NewExporter(keychain.SetBackend(nil), w, keychainaudit.Options{})
+//	keychain.SetBackend(exp)
+//
+// Events name the items accessed and the outcome, never item data or the
+// generic attribute. Failures to write events are reported by Err but don't
+// fail keychain operations.
+package keychainaudit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	keychain "github.com/mailstone/go-keychain"
+)
+
+// Event is an audit event, describing a keychain operation and its outcome.
+type Event struct {
+	Time time.Time `json:"time"`
+	// Program and PID identify the process.
+	Program string `json:"program"`
+	PID     int    `json:"pid"`
+	// Op is "add", "update", "copyMatching" or "delete".
+	Op string `json:"op"`
+	// Class is the item class of the query or the added item, e.g. "genp".
+	Class       string `json:"class,omitempty"`
+	Service     string `json:"service,omitempty"`
+	Account     string `json:"account,omitempty"`
+	AccessGroup string `json:"accessGroup,omitempty"`
+	// ItemID is the keychain.ItemIDOf the query or the added item, if it has
+	// a class and a service or account.
+	ItemID string `json:"itemID,omitempty"`
+	// Data reports whether item data was read or written.
+	Data bool `json:"data,omitempty"`
+	// Items is the number of items returned by copyMatching.
+	Items int `json:"items,omitempty"`
+	// Status is the keychain.Error returned, if any.
+	Status int `json:"status,omitempty"`
+	// Error is the message of other errors returned.
+	Error string `json:"error,omitempty"`
+}
+
+// Options are options for an Exporter.
+type Options struct {
+	// Rotate returns the next output of the exporter, e.g. a new log file. It
+	// is called by Exporter.Rotate, once MaxBytes were written, and once when
+	// writing an event fails, so a lost socket connection is reopened. The
+	// previous output is closed if it is an io.Closer.
+	Rotate func() (io.Writer, error)
+	// MaxBytes rotates the output once that many bytes were written to it, if
+	// Rotate is set. Zero never rotates by size.
+	MaxBytes int64
+	// Now returns the time of events. It defaults to time.Now.
+	Now func() time.Time
+	// OmitNames leaves out the service, account and access group of events,
+	// so that items are only referenced by ItemID.
+	OmitNames bool
+}
+
+// Exporter is a keychain.Backend which writes an audit event for each
+// operation of another backend.
+type Exporter struct {
+	backend keychain.Backend
+	opts    Options
+	program string
+
+	mu      sync.Mutex
+	w       io.Writer
+	written int64
+	err     error
+}
+
+// NewExporter returns an Exporter writing the events of backend to w.
+func NewExporter(backend keychain.Backend, w io.Writer, opts Options) *Exporter {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	return &Exporter{backend: backend, opts: opts, program: filepath.Base(os.Args[0]), w: w}
+}
+
+// DialUnix returns an Exporter writing the events of backend to the unix
+// socket at path, e.g. that of a log shipper. Unless opts.Rotate is set, the
+// connection is reopened when writing fails.
+func DialUnix(backend keychain.Backend, path string, opts Options) (*Exporter, error) {
+	dial := func() (io.Writer, error) {
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %s: %w", path, err)
+		}
+
+		return conn, nil
+	}
+
+	w, err := dial()
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Rotate == nil {
+		opts.Rotate = dial
+	}
+
+	return NewExporter(backend, w, opts), nil
+}
+
+// Err returns the error of the last failed write, if events were lost since
+// the last successful rotation.
+func (e *Exporter) Err() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.err
+}
+
+// Rotate switches to the next output from Options.Rotate, e.g. on SIGHUP
+// after an external tool rotated the log file.
+func (e *Exporter) Rotate() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.rotate()
+}
+
+func (e *Exporter) rotate() error {
+	if e.opts.Rotate == nil {
+		return errors.New("no rotation configured")
+	}
+
+	w, err := e.opts.Rotate()
+	if err != nil {
+		e.err = fmt.Errorf("failed to rotate audit output: %w", err)
+
+		return e.err
+	}
+
+	if c, ok := e.w.(io.Closer); ok {
+		_ = c.Close()
+	}
+
+	e.w, e.written, e.err = w, 0, nil
+
+	return nil
+}
+
+// Close closes the output if it is an io.Closer.
+func (e *Exporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if c, ok := e.w.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// write writes line, rotating once on failure.
+func (e *Exporter) write(line []byte) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	n, err := e.w.Write(line)
+	e.written += int64(n)
+
+	if err != nil && e.opts.Rotate != nil && e.rotate() == nil {
+		n, err = e.w.Write(line)
+		e.written += int64(n)
+	}
+
+	if err != nil {
+		e.err = fmt.Errorf("failed to write audit event: %w", err)
+
+		return
+	}
+
+	if e.opts.MaxBytes > 0 && e.written >= e.opts.MaxBytes && e.opts.Rotate != nil {
+		_ = e.rotate()
+	}
+}
+
+func (e *Exporter) export(op string, query map[string]interface{}, data bool, items int, err error) {
+	str := func(key string) string {
+		s, _ := query[key].(string)
+
+		return s
+	}
+
+	ev := Event{
+		Time:        e.opts.Now(),
+		Program:     e.program,
+		PID:         os.Getpid(),
+		Op:          op,
+		Class:       str(keychain.SecClassKey),
+		Service:     str(keychain.ServiceKey),
+		Account:     str(keychain.AccountKey),
+		AccessGroup: str(keychain.AccessGroupKey),
+		Data:        data,
+		Items:       items,
+	}
+
+	if ev.Service != "" || ev.Account != "" || query[keychain.ServerKey] != nil {
+		ev.ItemID, _ = keychain.ItemIDOf(query)
+	}
+
+	if e.opts.OmitNames {
+		ev.Service, ev.Account, ev.AccessGroup = "", "", ""
+	}
+
+	var kerr keychain.Error
+
+	switch {
+	case errors.As(err, &kerr):
+		ev.Status = int(kerr)
+	case err != nil:
+		ev.Error = err.Error()
+	}
+
+	line, merr := json.Marshal(ev)
+	if merr != nil {
+		e.mu.Lock()
+		e.err = fmt.Errorf("failed to encode audit event: %w", merr)
+		e.mu.Unlock()
+
+		return
+	}
+
+	e.write(append(line, '\n'))
+}
+
+// Capabilities implements keychain.CapabilityReporter with the capabilities
+// of the audited backend.
+func (e *Exporter) Capabilities() keychain.Capability {
+	return keychain.CapabilitiesOf(e.backend)
+}
+
+// Add implements keychain.Backend.
+func (e *Exporter) Add(attrs map[string]interface{}) (interface{}, error) {
+	result, err := e.backend.Add(attrs)
+	_, data := attrs[keychain.DataKey]
+	e.export("add", attrs, data, 0, err)
+
+	return result, err
+}
+
+// Update implements keychain.Backend.
+func (e *Exporter) Update(query, attrs map[string]interface{}) error {
+	err := e.backend.Update(query, attrs)
+	_, data := attrs[keychain.DataKey]
+	e.export("update", query, data, 0, err)
+
+	return err
+}
+
+// CopyMatching implements keychain.Backend.
+func (e *Exporter) CopyMatching(query map[string]interface{}) (interface{}, error) {
+	result, err := e.backend.CopyMatching(query)
+	data, _ := query[keychain.ReturnDataKey].(bool)
+
+	items := 0
+
+	switch r := result.(type) {
+	case nil:
+	case []interface{}:
+		items = len(r)
+	default:
+		items = 1
+	}
+
+	e.export("copyMatching", query, data, items, err)
+
+	return result, err
+}
+
+// Delete implements keychain.Backend.
+func (e *Exporter) Delete(query map[string]interface{}) error {
+	err := e.backend.Delete(query)
+	e.export("delete", query, false, 0, err)
+
+	return err
+}
@@ -0,0 +1,172 @@
+package keychainaudit_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainaudit"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func decode(t *testing.T, r io.Reader) []keychainaudit.Event {
+	t.Helper()
+
+	var events []keychainaudit.Event
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var e keychainaudit.Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid event %q: %v", scanner.Text(), err)
+		}
+
+		events = append(events, e)
+	}
+
+	return events
+}
+
+func TestExporter(t *testing.T) {
+	var buf bytes.Buffer
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	exp := keychainaudit.NewExporter(keychainfake.New(), &buf, keychainaudit.Options{Now: func() time.Time { return now }})
+
+	prev := keychain.SetBackend(exp)
+	t.Cleanup(func() { keychain.SetBackend(prev) })
+
+	if err := keychain.SetBytes("MyService", "gabriel", []byte("toomanysecrets")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := keychain.GetGenericPassword("MyService", "gabriel", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.DeleteGenericPasswordItem("MyService", "nobody"); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	if exp.Err() != nil {
+		t.Fatal(exp.Err())
+	}
+
+	if strings.Contains(buf.String(), "toomanysecrets") || strings.Contains(buf.String(), "dG9vbWFueXNlY3JldHM") {
+		t.Fatal("events contain item data")
+	}
+
+	events := decode(t, &buf)
+
+	var ops []string
+	for _, e := range events {
+		ops = append(ops, e.Op)
+	}
+
+	if len(events) < 3 {
+		t.Fatalf("expected at least 3 events, got %v", ops)
+	}
+
+	read := events[len(events)-2]
+	if read.Op != "copyMatching" || read.Service != "MyService" || read.Account != "gabriel" || !read.Data ||
+		read.Items != 1 || !read.Time.Equal(now) || read.PID == 0 {
+		t.Fatalf("unexpected read event %+v", read)
+	}
+
+	if del := events[len(events)-1]; del.Op != "delete" || del.Status != int(keychain.ErrorItemNotFound) {
+		t.Fatalf("unexpected delete event %+v", del)
+	}
+}
+
+func TestOmitNames(t *testing.T) {
+	var buf bytes.Buffer
+
+	exp := keychainaudit.NewExporter(keychainfake.New(), &buf, keychainaudit.Options{OmitNames: true})
+
+	prev := keychain.SetBackend(exp)
+	t.Cleanup(func() { keychain.SetBackend(prev) })
+
+	if err := keychain.DeleteGenericPasswordItem("MyService", "gabriel"); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	if strings.Contains(buf.String(), "MyService") || strings.Contains(buf.String(), "gabriel") {
+		t.Fatalf("events contain names: %s", buf.String())
+	}
+
+	events := decode(t, &buf)
+
+	want := keychain.ItemID(keychain.SecClassGenericPassword, "MyService", "gabriel", "")
+	if len(events) != 1 || events[0].ItemID != want {
+		t.Fatalf("unexpected events %+v", events)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	outputs := []*bytes.Buffer{{}}
+
+	exp := keychainaudit.NewExporter(keychainfake.New(), outputs[0], keychainaudit.Options{
+		MaxBytes: 1,
+		Rotate: func() (io.Writer, error) {
+			outputs = append(outputs, &bytes.Buffer{})
+
+			return outputs[len(outputs)-1], nil
+		},
+	})
+
+	prev := keychain.SetBackend(exp)
+	t.Cleanup(func() { keychain.SetBackend(prev) })
+
+	for i := 0; i < 2; i++ {
+		_, _ = keychain.GetGenericPassword("MyService", "gabriel", "", "")
+	}
+
+	if len(outputs) != 3 || len(decode(t, outputs[0])) != 1 || len(decode(t, outputs[1])) != 1 {
+		t.Fatalf("expected an event per output, got %d outputs", len(outputs))
+	}
+}
+
+func TestDialUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.sock")
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer l.Close()
+
+	exp, err := keychainaudit.DialUnix(keychainfake.New(), path, keychainaudit.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exp.Close()
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	prev := keychain.SetBackend(exp)
+	t.Cleanup(func() { keychain.SetBackend(prev) })
+
+	_, _ = keychain.GetGenericPassword("MyService", "gabriel", "", "")
+
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var e keychainaudit.Event
+	if err := json.Unmarshal(line, &e); err != nil || e.Op != "copyMatching" || e.Status != int(keychain.ErrorItemNotFound) {
+		t.Fatalf("unexpected event %s: %v", line, err)
+	}
+}
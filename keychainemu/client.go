@@ -0,0 +1,156 @@
+package keychainemu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainrecord"
+)
+
+// Name is the name the client backend is registered under, see
+// keychain.UseBackend.
+const Name = "emulator"
+
+// AddrEnv is the environment variable holding the address of the emulator
+// for the registered backend.
+const AddrEnv = "KEYCHAIN_EMULATOR_ADDR"
+
+func init() {
+	keychain.RegisterBackend(Name, func() (keychain.Backend, error) {
+		addr := os.Getenv(AddrEnv)
+		if addr == "" {
+			return nil, errors.New(AddrEnv + " is not set")
+		}
+
+		return NewClient(addr), nil
+	})
+}
+
+// Client is a keychain.Backend calling an emulator.
+type Client struct {
+	url    string
+	client *http.Client
+}
+
+// NewClient returns a client for the emulator at addr, which is host:port,
+// an http:// URL, or unix: followed by the path of a Unix socket. No request
+// is made until the client is used.
+func NewClient(addr string) *Client {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", path)
+			},
+		}
+
+		return &Client{url: "http://emulator", client: &http.Client{Transport: transport}}
+	}
+
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+
+	return &Client{url: strings.TrimSuffix(addr, "/"), client: http.DefaultClient}
+}
+
+func (c *Client) post(path string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to encode emulator request: %w", err)
+	}
+
+	resp, err := c.client.Post(c.url+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to call keychain emulator: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("keychain emulator: %s", resp.Status)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode emulator response: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Client) call(op string, query, attrs map[string]interface{}) (interface{}, error) {
+	req := keychainrecord.Entry{Op: op}
+
+	var err error
+
+	if query != nil {
+		if req.Query, err = keychainrecord.NewValue(query); err != nil {
+			return nil, fmt.Errorf("failed to encode query: %w", err)
+		}
+	}
+
+	if attrs != nil {
+		if req.Attrs, err = keychainrecord.NewValue(attrs); err != nil {
+			return nil, fmt.Errorf("failed to encode attributes: %w", err)
+		}
+	}
+
+	var resp keychainrecord.Entry
+	if err := c.post("/v1/call", req, &resp); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.Status != 0:
+		return nil, keychain.Error(resp.Status)
+	case resp.Error != "":
+		return nil, errors.New(resp.Error)
+	}
+
+	return resp.Result.Interface(), nil
+}
+
+// Reset removes all items from the emulator.
+func (c *Client) Reset() error {
+	return c.post("/v1/reset", struct{}{}, nil)
+}
+
+// Capabilities implements keychain.CapabilityReporter with the capabilities
+// of the emulated keychain.
+func (c *Client) Capabilities() keychain.Capability {
+	return keychain.Capability{PersistentRefs: true, UniqueKeys: true}
+}
+
+// Add implements keychain.Backend.
+func (c *Client) Add(attrs map[string]interface{}) (interface{}, error) {
+	return c.call("add", nil, attrs)
+}
+
+// Update implements keychain.Backend.
+func (c *Client) Update(query, attrs map[string]interface{}) error {
+	_, err := c.call("update", query, attrs)
+
+	return err
+}
+
+// CopyMatching implements keychain.Backend.
+func (c *Client) CopyMatching(query map[string]interface{}) (interface{}, error) {
+	return c.call("copyMatching", query, nil)
+}
+
+// Delete implements keychain.Backend.
+func (c *Client) Delete(query map[string]interface{}) error {
+	_, err := c.call("delete", query, nil)
+
+	return err
+}
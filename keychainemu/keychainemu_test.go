@@ -0,0 +1,78 @@
+package keychainemu_test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/backendtest"
+	"github.com/mailstone/go-keychain/keychainemu"
+)
+
+func TestEmulator(t *testing.T) {
+	srv, err := keychainemu.NewServer(keychainemu.NewKeychain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	t.Setenv(keychainemu.AddrEnv, ts.URL)
+
+	prev := keychain.SetBackend(nil)
+	defer keychain.SetBackend(prev)
+
+	if err := keychain.UseBackend(keychainemu.Name); err != nil {
+		t.Fatal(err)
+	}
+
+	item := keychain.NewGenericPassword("MyService", "gabriel", "A label", []byte("toomanysecrets"), "")
+	item.SetAccessible(keychain.AccessibleWhenUnlocked)
+
+	if err := keychain.AddItem(item); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.AddItem(item); !errors.Is(err, keychain.ErrorDuplicateItem) {
+		t.Fatalf("expected ErrorDuplicateItem, got %v", err)
+	}
+
+	query := keychain.NewItem()
+	query.SetSecClass(keychain.SecClassGenericPassword)
+	query.SetService("MyService")
+	query.SetMatchLimit(keychain.MatchLimitAll)
+	query.SetReturnAttributes(true)
+	query.SetReturnData(true)
+
+	results, err := keychain.QueryItem(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 || results[0].Label != "A label" || string(results[0].Data) != "toomanysecrets" ||
+		results[0].CreationDate.IsZero() {
+		t.Fatalf("unexpected results %+v", results)
+	}
+
+	if err := keychainemu.NewClient(ts.URL).Reset(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.DeleteGenericPasswordItem("MyService", "gabriel"); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound after reset, got %v", err)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	srv, err := keychainemu.NewServer(keychainemu.NewKeychain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	backendtest.RunConformance(t, keychainemu.NewClient(ts.URL))
+}
@@ -0,0 +1,135 @@
+// Package keychainemu implements a keychain emulator: a server holding items
+// with the semantics of the macOS keychain (classes, primary key duplicate
+// rules, match limits and result shapes; accessibility is stored but has no
+// effect), and a client backend for it. With the keychain-emulator command, it
+// lets the integration tests of macOS apps run in Linux containers:
+//
+//	keychain-emulator -listen 127.0.0.1:9461 &
+//	KEYCHAIN_EMULATOR_ADDR=127.0.0.1:9461 go test ./...
+//
+// and in the tests:
+//
+//	import _ "github.com/mailstone/go-keychain/keychainemu"
+//
+//	err := keychain.UseBackend(keychainemu.Name)
+//
+// Calls are sent as JSON encoded keychainrecord entries, so the protocol
+// preserves the Go types of attribute values.
+package keychainemu
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/internal/secitem"
+	"github.com/mailstone/go-keychain/keychainrecord"
+)
+
+// NewKeychain returns an empty emulated keychain in memory, for NewServer.
+func NewKeychain() (keychain.Backend, error) {
+	return secitem.NewStore(), nil
+}
+
+// Server serves a keychain over HTTP.
+type Server struct {
+	open func() (keychain.Backend, error)
+
+	mu      sync.Mutex
+	backend keychain.Backend
+}
+
+// NewServer returns a Server for the keychain returned by open, which is
+// called again to replace the keychain when the server is reset.
+func NewServer(open func() (keychain.Backend, error)) (*Server, error) {
+	b, err := open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open keychain: %w", err)
+	}
+
+	return &Server{open: open, backend: b}, nil
+}
+
+// call calls the keychain for a request.
+func (s *Server) call(req keychainrecord.Entry) keychainrecord.Entry {
+	query, _ := req.Query.Interface().(map[string]interface{})
+	attrs, _ := req.Attrs.Interface().(map[string]interface{})
+
+	s.mu.Lock()
+	b := s.backend
+	s.mu.Unlock()
+
+	var (
+		result interface{}
+		err    error
+	)
+
+	switch req.Op {
+	case "add":
+		result, err = b.Add(attrs)
+	case "update":
+		err = b.Update(query, attrs)
+	case "copyMatching":
+		result, err = b.CopyMatching(query)
+	case "delete":
+		err = b.Delete(query)
+	default:
+		err = fmt.Errorf("unknown operation %q", req.Op)
+	}
+
+	resp := keychainrecord.Entry{Op: req.Op}
+
+	if err == nil {
+		resp.Result, err = keychainrecord.NewValue(result)
+	}
+
+	var kerr keychain.Error
+
+	switch {
+	case errors.As(err, &kerr):
+		resp.Status = int(kerr)
+	case err != nil:
+		resp.Error = err.Error()
+	}
+
+	return resp
+}
+
+// ServeHTTP serves POST /v1/call, which makes a call described by an entry
+// and responds with its outcome, and POST /v1/reset, which removes all items.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	switch r.URL.Path {
+	case "/v1/call":
+		var req keychainrecord.Entry
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.call(req))
+	case "/v1/reset":
+		b, err := s.open()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		s.mu.Lock()
+		s.backend = b
+		s.mu.Unlock()
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,323 @@
+// Package keychainfake provides an in-memory keychain backend whose responses
+// can be scripted, to exercise application retry, timeout and error handling
+// deterministically:
+//
+//	f := keychainfake.Install(t)
+//	f.On(keychainfake.CopyMatching).Nth(2).Fail(keychain.ErrorAuthFailed)
+//	f.On(keychainfake.Add).Delay(5 * time.Second)
+//
+// Calls that no rule answers are served by an in-memory keychain with the
+// duplicate, not found and match limit semantics of the Security framework.
+package keychainfake
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/internal/secitem"
+)
+
+// Op is a backend operation.
+type Op string
+
+const (
+	// Add is keychain.Backend.Add (SecItemAdd).
+	Add Op = "add"
+	// Update is keychain.Backend.Update (SecItemUpdate).
+	Update Op = "update"
+	// CopyMatching is keychain.Backend.CopyMatching (SecItemCopyMatching).
+	CopyMatching Op = "copyMatching"
+	// Delete is keychain.Backend.Delete (SecItemDelete).
+	Delete Op = "delete"
+)
+
+// Call is a recorded backend call.
+type Call struct {
+	Op Op
+	// N is the number of the call among calls of the same Op, starting at 1.
+	N int
+	// Query is the query of Update, CopyMatching and Delete calls.
+	Query map[string]interface{}
+	// Attrs are the attributes of Add and Update calls.
+	Attrs map[string]interface{}
+	// Err is the error returned.
+	Err error
+}
+
+// Fake is a scriptable keychain.Backend.
+type Fake struct {
+	mu     sync.Mutex
+	store  *secitem.Store
+	rules  []*Rule
+	calls  []Call
+	counts map[Op]int
+	caps   *keychain.Capability
+}
+
+// New returns a Fake with an empty keychain. Use keychain.SetBackend to make
+// the keychain package use it, or Install in tests.
+func New() *Fake {
+	return &Fake{store: secitem.NewStore(), counts: make(map[Op]int)}
+}
+
+// Install returns a new Fake used by the keychain package until the test
+// finishes.
+func Install(tb testing.TB) *Fake {
+	tb.Helper()
+
+	f := New()
+	prev := keychain.SetBackend(f)
+
+	tb.Cleanup(func() { keychain.SetBackend(prev) })
+
+	return f
+}
+
+// Rule scripts the response to matching calls. Rules are checked in the order
+// they were added and the first one matching a call applies.
+type Rule struct {
+	f      *Fake
+	op     Op
+	nth    int
+	times  int
+	where  func(Call) bool
+	delay  time.Duration
+	err    error
+	result interface{}
+	hasRes bool
+}
+
+// On adds a rule for calls of op, or of any operation if op is empty. Without
+// further configuration, the rule applies to every such call and has no
+// effect.
+func (f *Fake) On(op Op) *Rule {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	r := &Rule{f: f, op: op, times: -1}
+	f.rules = append(f.rules, r)
+
+	return r
+}
+
+func (r *Rule) set(fn func()) *Rule {
+	r.f.mu.Lock()
+	defer r.f.mu.Unlock()
+
+	fn()
+
+	return r
+}
+
+// Nth restricts the rule to the nth call of its operation, starting at 1.
+func (r *Rule) Nth(n int) *Rule {
+	return r.set(func() { r.nth = n })
+}
+
+// Times restricts the rule to its next n matching calls.
+func (r *Rule) Times(n int) *Rule {
+	return r.set(func() { r.times = n })
+}
+
+// Where restricts the rule to calls for which match returns true.
+func (r *Rule) Where(match func(Call) bool) *Rule {
+	return r.set(func() { r.where = match })
+}
+
+// Delay delays matching calls by d before they are answered.
+func (r *Rule) Delay(d time.Duration) *Rule {
+	return r.set(func() { r.delay = d })
+}
+
+// Fail answers matching calls with err, e.g. keychain.ErrorAuthFailed, without
+// touching the keychain.
+func (r *Rule) Fail(err error) *Rule {
+	return r.set(func() { r.err = err })
+}
+
+// Return answers matching calls with result without touching the keychain.
+// See keychain.Backend for the result types, and Results.
+func (r *Rule) Return(result interface{}) *Rule {
+	return r.set(func() { r.result, r.hasRes = result, true })
+}
+
+// Results returns n generic password results for service, with accounts
+// "account-1" to "account-n" and matching data, as returned for queries with
+// MatchLimitAll that return attributes and data.
+func Results(service string, n int) []interface{} {
+	results := make([]interface{}, 0, n)
+	now := time.Now()
+
+	for i := 1; i <= n; i++ {
+		results = append(results, map[string]interface{}{
+			keychain.SecClassKey:         "genp",
+			keychain.ServiceKey:          service,
+			keychain.AccountKey:          fmt.Sprintf("account-%d", i),
+			keychain.DataKey:             []byte(fmt.Sprintf("data-%d", i)),
+			keychain.CreationDateKey:     now,
+			keychain.ModificationDateKey: now,
+		})
+	}
+
+	return results
+}
+
+// SetCapabilities sets the capabilities reported by the fake, to exercise
+// feature detection. By default it reports those of its in-memory keychain.
+func (f *Fake) SetCapabilities(c keychain.Capability) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.caps = &c
+}
+
+// Capabilities implements keychain.CapabilityReporter.
+func (f *Fake) Capabilities() keychain.Capability {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.caps != nil {
+		return *f.caps
+	}
+
+	return f.store.Capabilities()
+}
+
+// Calls returns the calls made so far.
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Call(nil), f.calls...)
+}
+
+// Reset removes all rules, recorded calls, items and capabilities set.
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.store = secitem.NewStore()
+	f.rules = nil
+	f.calls = nil
+	f.counts = make(map[Op]int)
+	f.caps = nil
+}
+
+// Len returns the number of items in the keychain.
+func (f *Fake) Len() int {
+	f.mu.Lock()
+	store := f.store
+	f.mu.Unlock()
+
+	return store.Len()
+}
+
+// begin records the start of a call and returns a copy of the rule that
+// applies to it, if any, and the keychain serving it. The predicates of Where
+// are called without holding the lock of the fake, so they may call it.
+func (f *Fake) begin(c *Call) (*Rule, *secitem.Store) {
+	f.mu.Lock()
+	f.counts[c.Op]++
+	c.N = f.counts[c.Op]
+	rules := append([]*Rule(nil), f.rules...)
+	store := f.store
+	f.mu.Unlock()
+
+	for _, r := range rules {
+		f.mu.Lock()
+		skip := r.times == 0 || (r.op != "" && r.op != c.Op) || (r.nth != 0 && r.nth != c.N)
+		where := r.where
+		f.mu.Unlock()
+
+		if skip || where != nil && !where(*c) {
+			continue
+		}
+
+		// Another call may have used up the rule meanwhile.
+		f.mu.Lock()
+		applies := r.times != 0
+
+		if r.times > 0 {
+			r.times--
+		}
+
+		applied := *r
+		f.mu.Unlock()
+
+		if applies {
+			return &applied, store
+		}
+	}
+
+	return nil, store
+}
+
+func (f *Fake) end(c Call) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, c)
+}
+
+// do runs a call, answering it by the applicable rule or with serve.
+func (f *Fake) do(c Call, serve func(*secitem.Store) (interface{}, error)) (interface{}, error) {
+	r, store := f.begin(&c)
+
+	var (
+		result interface{}
+		err    error
+	)
+
+	if r != nil && r.delay > 0 {
+		time.Sleep(r.delay)
+	}
+
+	switch {
+	case r != nil && r.err != nil:
+		err = r.err
+	case r != nil && r.hasRes:
+		result = r.result
+	default:
+		result, err = serve(store)
+	}
+
+	c.Err = err
+	f.end(c)
+
+	return result, err
+}
+
+// Add implements keychain.Backend.
+func (f *Fake) Add(attrs map[string]interface{}) (interface{}, error) {
+	return f.do(Call{Op: Add, Attrs: attrs}, func(s *secitem.Store) (interface{}, error) {
+		return s.Add(attrs)
+	})
+}
+
+// Update implements keychain.Backend.
+func (f *Fake) Update(query, attrs map[string]interface{}) error {
+	_, err := f.do(Call{Op: Update, Query: query, Attrs: attrs}, func(s *secitem.Store) (interface{}, error) {
+		return nil, s.Update(query, attrs)
+	})
+
+	return err
+}
+
+// CopyMatching implements keychain.Backend.
+func (f *Fake) CopyMatching(query map[string]interface{}) (interface{}, error) {
+	return f.do(Call{Op: CopyMatching, Query: query}, func(s *secitem.Store) (interface{}, error) {
+		return s.CopyMatching(query)
+	})
+}
+
+// Delete implements keychain.Backend.
+func (f *Fake) Delete(query map[string]interface{}) error {
+	_, err := f.do(Call{Op: Delete, Query: query}, func(s *secitem.Store) (interface{}, error) {
+		return nil, s.Delete(query)
+	})
+
+	return err
+}
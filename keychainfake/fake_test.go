@@ -0,0 +1,116 @@
+package keychainfake_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/backendtest"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func TestFakeKeychain(t *testing.T) {
+	f := keychainfake.Install(t)
+
+	item := keychain.NewGenericPassword("MyService", "gabriel", "A label", []byte("toomanysecrets"), "")
+	if err := keychain.AddItem(item); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.AddItem(item); !errors.Is(err, keychain.ErrorDuplicateItem) {
+		t.Fatalf("expected ErrorDuplicateItem, got %v", err)
+	}
+
+	data, err := keychain.GetGenericPassword("MyService", "gabriel", "", "")
+	if err != nil || string(data) != "toomanysecrets" {
+		t.Fatalf("unexpected password %q, %v", data, err)
+	}
+
+	if err := keychain.DeleteGenericPasswordItem("MyService", "gabriel"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.DeleteGenericPasswordItem("MyService", "gabriel"); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound, got %v", err)
+	}
+
+	if f.Len() != 0 {
+		t.Fatalf("expected empty keychain, got %d items", f.Len())
+	}
+}
+
+func TestFakeNthCallFails(t *testing.T) {
+	f := keychainfake.Install(t)
+	f.On(keychainfake.CopyMatching).Nth(2).Fail(keychain.ErrorAuthFailed)
+
+	for i, want := range []error{nil, keychain.ErrorAuthFailed, nil} {
+		_, err := keychain.GetGenericPassword("MyService", "gabriel", "", "")
+		if !errors.Is(err, want) {
+			t.Fatalf("call %d: expected %v, got %v", i+1, want, err)
+		}
+	}
+
+	calls := f.Calls()
+	if len(calls) != 3 || calls[1].N != 2 || !errors.Is(calls[1].Err, keychain.ErrorAuthFailed) {
+		t.Fatalf("unexpected calls: %+v", calls)
+	}
+}
+
+func TestFakeWhereCallsFake(t *testing.T) {
+	f := keychainfake.Install(t)
+
+	// Predicates may inspect the fake, e.g. to fail once it holds an item.
+	f.On(keychainfake.Add).Where(func(keychainfake.Call) bool { return f.Len() > 0 }).Fail(keychain.ErrorAuthFailed)
+
+	if err := keychain.AddItem(keychain.NewGenericPassword("MyService", "a", "", nil, "")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.AddItem(keychain.NewGenericPassword("MyService", "b", "", nil, "")); !errors.Is(err, keychain.ErrorAuthFailed) {
+		t.Fatalf("expected ErrorAuthFailed, got %v", err)
+	}
+}
+
+func TestFakeDelayAndResults(t *testing.T) {
+	f := keychainfake.Install(t)
+	f.On(keychainfake.CopyMatching).Times(1).Delay(50 * time.Millisecond).Return(keychainfake.Results("MyService", 3))
+
+	start := time.Now()
+
+	accounts, err := keychain.GetGenericPasswordAccounts("MyService")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if time.Since(start) < 50*time.Millisecond {
+		t.Fatal("expected call to be delayed")
+	}
+
+	if len(accounts) != 3 || accounts[2] != "account-3" {
+		t.Fatalf("unexpected accounts: %v", accounts)
+	}
+
+	accounts, err = keychain.GetGenericPasswordAccounts("MyService")
+	if err != nil || len(accounts) != 0 {
+		t.Fatalf("expected no accounts once the rule is used up, got %v, %v", accounts, err)
+	}
+}
+
+func TestFakeCapabilities(t *testing.T) {
+	f := keychainfake.Install(t)
+
+	if !keychain.Capabilities().PersistentRefs {
+		t.Fatal("expected the fake to support persistent references")
+	}
+
+	f.SetCapabilities(keychain.Capability{Sync: true, MaxDataSize: 2560})
+
+	if c := keychain.Capabilities(); !c.Sync || c.PersistentRefs || c.MaxDataSize != 2560 {
+		t.Fatalf("unexpected capabilities %+v", c)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	backendtest.RunConformance(t, keychainfake.New())
+}
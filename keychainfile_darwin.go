@@ -0,0 +1,173 @@
+//go:build darwin && !ios
+// +build darwin,!ios
+
+package keychain
+
+/*
+#cgo LDFLAGS: -framework CoreFoundation -framework Security
+
+#include <stdlib.h>
+#include <CoreFoundation/CoreFoundation.h>
+#include <Security/Security.h>
+*/
+import "C"
+import (
+	"unsafe"
+)
+
+// Keychain is a keychain file on macOS, e.g. a temporary keychain for CI or
+// hermetic tests instead of the login keychain. Items are added to it with
+// Item.UseKeychain, which also limits queries, updates and deletes to it.
+type Keychain struct {
+	path string
+}
+
+// NewKeychain creates a keychain file at path, locked with password. It fails
+// with ErrorDuplicateKeyChain if the file exists.
+func NewKeychain(path string, password string) (Keychain, error) {
+	return newKeychain(path, password, false)
+}
+
+// NewKeychainWithPrompt creates a keychain file at path, asking the user for
+// its password.
+func NewKeychainWithPrompt(path string) (Keychain, error) {
+	return newKeychain(path, "", true)
+}
+
+func newKeychain(path, password string, prompt bool) (Keychain, error) {
+	pathName := C.CString(path)
+	defer C.free(unsafe.Pointer(pathName))
+
+	var kref C.SecKeychainRef
+
+	var errCode C.OSStatus
+
+	if prompt {
+		errCode = C.SecKeychainCreate(pathName, 0, nil, C.Boolean(1), 0, &kref) // nolint: nlreturn
+	} else {
+		passwordRef := C.CString(password)
+		defer C.free(unsafe.Pointer(passwordRef))
+
+		errCode = C.SecKeychainCreate(pathName, C.UInt32(len(password)), unsafe.Pointer(passwordRef), // nolint: nlreturn
+			C.Boolean(0), 0, &kref)
+	}
+
+	if err := checkError(errCode); err != nil {
+		return Keychain{}, err
+	}
+
+	Release(C.CFTypeRef(kref))
+
+	return Keychain{path: path}, nil
+}
+
+// OpenKeychain returns the existing keychain file at path, or
+// ErrorNoSuchKeychain.
+func OpenKeychain(path string) (Keychain, error) {
+	kc := Keychain{path: path}
+
+	if err := kc.Status(); err != nil {
+		return Keychain{}, err
+	}
+
+	return kc, nil
+}
+
+// openRef returns a reference to the keychain, which must be released with
+// Release(ref).
+func (kc Keychain) openRef() (C.SecKeychainRef, error) {
+	pathName := C.CString(kc.path)
+	defer C.free(unsafe.Pointer(pathName))
+
+	var kref C.SecKeychainRef
+
+	if err := checkError(C.SecKeychainOpen(pathName, &kref)); err != nil { // nolint: nlreturn
+		return 0, err
+	}
+
+	return kref, nil
+}
+
+// Path returns the path of the keychain file.
+func (kc Keychain) Path() string {
+	return kc.path
+}
+
+// Status returns ErrorNoSuchKeychain if the keychain file doesn't exist, and
+// nil otherwise.
+func (kc Keychain) Status() error {
+	kref, err := kc.openRef()
+	if err != nil {
+		return err
+	}
+
+	defer Release(C.CFTypeRef(kref))
+
+	var status C.SecKeychainStatus
+
+	return checkError(C.SecKeychainGetStatus(kref, &status)) // nolint: nlreturn
+}
+
+// Unlock unlocks the keychain with password.
+func (kc Keychain) Unlock(password string) error {
+	kref, err := kc.openRef()
+	if err != nil {
+		return err
+	}
+
+	defer Release(C.CFTypeRef(kref))
+
+	passwordRef := C.CString(password)
+	defer C.free(unsafe.Pointer(passwordRef))
+
+	return checkError(C.SecKeychainUnlock(kref, C.UInt32(len(password)), unsafe.Pointer(passwordRef), // nolint: nlreturn
+		C.Boolean(1)))
+}
+
+// Lock locks the keychain.
+func (kc Keychain) Lock() error {
+	kref, err := kc.openRef()
+	if err != nil {
+		return err
+	}
+
+	defer Release(C.CFTypeRef(kref))
+
+	return checkError(C.SecKeychainLock(kref)) // nolint: nlreturn
+}
+
+// Delete deletes the keychain file and removes it from the search list of the
+// user.
+func (kc Keychain) Delete() error {
+	kref, err := kc.openRef()
+	if err != nil {
+		return err
+	}
+
+	defer Release(C.CFTypeRef(kref))
+
+	return checkError(C.SecKeychainDelete(kref)) // nolint: nlreturn
+}
+
+// Convert implements Convertable, opening the keychain.
+func (kc Keychain) Convert() (C.CFTypeRef, error) {
+	kref, err := kc.openRef()
+
+	return C.CFTypeRef(kref), err
+}
+
+// UseKeychain scopes the item to kc: it is added to kc (kSecUseKeychain), and
+// queries, updates and deletes only match items in kc (kSecMatchSearchList).
+func (k *Item) UseKeychain(kc Keychain) {
+	k.attr[UseKeychainKey] = kc
+}
+
+// SetMatchSearchList limits a query to the items in the keychains.
+func (k *Item) SetMatchSearchList(keychains ...Keychain) {
+	list := make(convertableArray, 0, len(keychains))
+	for _, kc := range keychains {
+		list = append(list, kc)
+	}
+
+	k.attr[MatchSearchListKey] = list
+}
@@ -0,0 +1,135 @@
+//go:build darwin && !ios
+// +build darwin,!ios
+
+package keychain
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeychainFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.keychain")
+
+	kc, err := NewKeychain(path, "keychainpassword")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer kc.Delete() // nolint: errcheck
+
+	if _, err := NewKeychain(path, "keychainpassword"); !errors.Is(err, ErrorDuplicateKeyChain) {
+		t.Fatalf("expected ErrorDuplicateKeyChain, got %v", err)
+	}
+
+	item := NewGenericPassword("TestKeychainFile", "gabriel", "", []byte("toomanysecrets"), "")
+	item.UseKeychain(kc)
+
+	if err := AddItem(item); err != nil {
+		t.Fatal(err)
+	}
+
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService("TestKeychainFile")
+	query.SetMatchLimit(MatchLimitOne)
+	query.SetReturnData(true)
+	query.UseKeychain(kc)
+
+	results, err := QueryItem(query)
+	if err != nil || len(results) != 1 || string(results[0].Data) != "toomanysecrets" {
+		t.Fatalf("unexpected results %+v, %v", results, err)
+	}
+
+	other, err := NewKeychain(filepath.Join(t.TempDir(), "other.keychain"), "keychainpassword")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Delete() // nolint: errcheck
+
+	query = NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService("TestKeychainFile")
+	query.SetMatchLimit(MatchLimitOne)
+	query.SetReturnAttributes(true)
+	query.SetMatchSearchList(other)
+
+	if results, err := QueryItem(query); err != nil || len(results) != 0 {
+		t.Fatalf("found item in another keychain: %+v, %v", results, err)
+	}
+
+	if err := kc.Lock(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := kc.Unlock("keychainpassword"); err != nil {
+		t.Fatal(err)
+	}
+
+	del := NewItem()
+	del.SetSecClass(SecClassGenericPassword)
+	del.SetService("TestKeychainFile")
+	del.UseKeychain(kc)
+
+	if err := DeleteItem(del); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := kc.Delete(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenKeychain(path); !errors.Is(err, ErrorNoSuchKeychain) {
+		t.Fatalf("expected ErrorNoSuchKeychain, got %v", err)
+	}
+}
+
+func TestLegacyAttributes(t *testing.T) {
+	kc, err := NewKeychain(filepath.Join(t.TempDir(), "test.keychain"), "keychainpassword")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer kc.Delete() // nolint: errcheck
+
+	item := NewGenericPassword("TestLegacyAttributes", "gabriel", "", []byte("toomanysecrets"), "")
+	item.UseKeychain(kc)
+
+	if err := AddItem(item); err != nil {
+		t.Fatal(err)
+	}
+
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService("TestLegacyAttributes")
+	query.UseKeychain(kc)
+
+	account, _ := FourCharCode("acct")
+	comment, _ := FourCharCode("icmt")
+
+	attrs, err := LegacyAttributes(query, account, comment)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(attrs[account]) != "gabriel" {
+		t.Fatalf("unexpected attributes %q", attrs)
+	}
+
+	if err := ModifyLegacyAttributes(query, map[uint32][]byte{comment: []byte("A comment")}, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+
+	query.SetMatchLimit(MatchLimitOne)
+	query.SetReturnAttributes(true)
+	query.SetReturnData(true)
+
+	results, err := QueryItem(query)
+	if err != nil || len(results) != 1 || results[0].Comment != "A comment" || string(results[0].Data) != "new" {
+		t.Fatalf("unexpected results %+v, %v", results, err)
+	}
+
+	unknown, _ := FourCharCode("zzzz")
+	if _, err := LegacyAttributes(query, unknown); !errors.Is(err, ErrorNoSuchAttr) {
+		t.Fatalf("expected ErrorNoSuchAttr, got %v", err)
+	}
+}
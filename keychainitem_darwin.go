@@ -0,0 +1,188 @@
+//go:build darwin && !ios
+// +build darwin,!ios
+
+package keychain
+
+/*
+#cgo LDFLAGS: -framework CoreFoundation -framework Security
+
+#include <stdlib.h>
+#include <CoreFoundation/CoreFoundation.h>
+#include <Security/Security.h>
+
+// copyLegacyAttribute reads the attribute with tag of item as a blob. *value
+// is NULL if the attribute has no value, and must be released otherwise.
+static OSStatus copyLegacyAttribute(SecKeychainItemRef item, UInt32 tag, CFDataRef *value) {
+	UInt32 format = CSSM_DB_ATTRIBUTE_FORMAT_BLOB;
+	SecKeychainAttributeInfo info = {1, &tag, &format};
+	SecKeychainAttributeList *list = NULL;
+
+	*value = NULL;
+
+	OSStatus status = SecKeychainItemCopyAttributesAndData(item, &info, NULL, &list, NULL, NULL);
+	if (status != errSecSuccess) {
+		return status;
+	}
+
+	if (list->count == 1 && list->attr[0].data != NULL) {
+		*value = CFDataCreate(NULL, list->attr[0].data, list->attr[0].length);
+	}
+
+	SecKeychainItemFreeAttributesAndData(list, NULL);
+
+	return status;
+}
+
+// modifyLegacyAttributes sets count attributes of item, and its data unless
+// data is NULL, at once.
+static OSStatus modifyLegacyAttributes(SecKeychainItemRef item, UInt32 *tags, CFDataRef *values, int count,
+	CFDataRef data) {
+	SecKeychainAttribute *attrs = calloc(count > 0 ? count : 1, sizeof(SecKeychainAttribute));
+
+	for (int i = 0; i < count; i++) {
+		attrs[i].tag = tags[i];
+		attrs[i].length = (UInt32)CFDataGetLength(values[i]);
+		attrs[i].data = (void *)CFDataGetBytePtr(values[i]);
+	}
+
+	SecKeychainAttributeList list = {count, attrs};
+	OSStatus status = SecKeychainItemModifyAttributesAndData(item, &list,
+		data != NULL ? (UInt32)CFDataGetLength(data) : 0, data != NULL ? CFDataGetBytePtr(data) : NULL);
+	free(attrs);
+
+	return status;
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrNotFileKeychainItem is returned by the legacy attribute functions for
+// items which aren't in a file keychain.
+var ErrNotFileKeychainItem = errors.New("item not in a file keychain")
+
+// legacyItemRef returns a reference to the single item matching query, which
+// must be released with Release(ref).
+func legacyItemRef(query Item) (C.SecKeychainItemRef, error) {
+	q := query.clone()
+	q.SetReturnRef(true)
+	q.SetMatchLimit(MatchLimitOne)
+
+	ref, err := QueryItemRef(q)
+	if err != nil {
+		return 0, err
+	}
+
+	if ref == 0 {
+		return 0, ErrorItemNotFound
+	}
+
+	if C.CFGetTypeID(ref) != C.SecKeychainItemGetTypeID() { // nolint: nlreturn
+		Release(ref)
+
+		return 0, ErrNotFileKeychainItem
+	}
+
+	return C.SecKeychainItemRef(ref), nil
+}
+
+// LegacyAttributes returns the attributes with tags of the item matching
+// query in a file keychain, read with SecKeychainItemCopyAttributesAndData.
+// Tags are four character codes (see FourCharCode), including those of
+// custom attributes defined by older apps, which SecItemCopyMatching doesn't
+// return. Attributes without a value are omitted. It fails with
+// ErrorNoSuchAttr for tags unknown to the item class.
+func LegacyAttributes(query Item, tags ...uint32) (map[uint32][]byte, error) {
+	item, err := legacyItemRef(query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer Release(C.CFTypeRef(item))
+
+	attrs := make(map[uint32][]byte, len(tags))
+
+	for _, tag := range tags {
+		var value C.CFDataRef
+
+		if err := checkError(C.copyLegacyAttribute(item, C.UInt32(tag), &value)); err != nil { // nolint: nlreturn
+			return nil, fmt.Errorf("failed to read attribute %q: %w", FourCharCodeString(tag), err)
+		}
+
+		if value == 0 {
+			continue
+		}
+
+		b, err := CFDataToBytes(value)
+		releaseCFData(value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		attrs[tag] = b
+	}
+
+	return attrs, nil
+}
+
+// ModifyLegacyAttributes sets the attributes of the item matching query in a
+// file keychain, keyed by tag (see LegacyAttributes), and its data unless
+// data is nil, at once with SecKeychainItemModifyAttributesAndData. This
+// allows writing attributes that SecItemUpdate can't express.
+func ModifyLegacyAttributes(query Item, attrs map[uint32][]byte, data []byte) error {
+	if err := checkWritable(); err != nil {
+		return err
+	}
+
+	queryAttrs, err := EncodeAttributes(query)
+	if err != nil {
+		return fmt.Errorf("failed to encode query item attributes: %w", err)
+	}
+
+	item, err := legacyItemRef(query)
+	if err != nil {
+		return err
+	}
+
+	defer Release(C.CFTypeRef(item))
+
+	tags := make([]uint32, 0, len(attrs))
+	for tag := range attrs {
+		tags = append(tags, tag)
+	}
+
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+
+	ctags := make([]C.UInt32, len(tags)+1)
+	values := make([]C.CFDataRef, len(tags)+1)
+
+	for i, tag := range tags {
+		value, err := BytesToCFData(attrs[tag])
+		if err != nil {
+			return err
+		}
+
+		defer releaseCFData(value)
+
+		ctags[i], values[i] = C.UInt32(tag), value
+	}
+
+	var cfData C.CFDataRef
+
+	if data != nil {
+		if cfData, err = BytesToCFData(data); err != nil {
+			return err
+		}
+
+		defer releaseCFData(cfData)
+	}
+
+	err = checkError(C.modifyLegacyAttributes(item, &ctags[0], &values[0], C.int(len(tags)), cfData)) // nolint: nlreturn
+
+	return countChange(err, queryAttrs)
+}
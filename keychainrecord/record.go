@@ -0,0 +1,207 @@
+// Package keychainrecord records the operations of a keychain backend to a
+// file and replays them, so bug reports involving unusual keychain states can
+// be reproduced without access to the affected machine:
+//
+//	f, _ := os.Create("keychain.ndjson")
+//	keychain.SetBackend(keychainrecord.NewRecorder(keychain.SetBackend(nil), f, keychainrecord.Options{}))
+//
+// and later, in a test:
+//
+//	entries, _ := keychainrecord.Load(f)
+//	keychain.SetBackend(keychainrecord.NewReplayer(entries))
+//
+// Recordings are newline delimited JSON, one Entry per operation. Item data
+// and the generic attribute are redacted by default.
+package keychainrecord
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	keychain "github.com/mailstone/go-keychain"
+)
+
+// Entry is a recorded operation and its outcome.
+type Entry struct {
+	// Op is "add", "update", "copyMatching" or "delete".
+	Op     string `json:"op"`
+	Query  *Value `json:"query,omitempty"`
+	Attrs  *Value `json:"attrs,omitempty"`
+	Result *Value `json:"result,omitempty"`
+	// Status is the keychain.Error returned, if any.
+	Status int `json:"status,omitempty"`
+	// Error is the message of other errors returned.
+	Error string `json:"error,omitempty"`
+	// Unrecorded describes the values of the operation which couldn't be
+	// recorded, e.g. of a type unknown to recordings, and which are left out
+	// of the entry. Replaying the entry fails.
+	Unrecorded string `json:"unrecorded,omitempty"`
+}
+
+// Options are options for a Recorder.
+type Options struct {
+	// KeepData records item data and generic attributes as is. By default they
+	// are replaced by a short hash, which still allows replay to match queries
+	// but may reveal low entropy values to a determined reader.
+	KeepData bool
+	// Redact lists further attribute keys to redact, e.g. keychain.AccountKey.
+	Redact []string
+}
+
+// Recorder is a keychain.Backend which records the operations of another
+// backend.
+type Recorder struct {
+	backend keychain.Backend
+	redact  map[string]bool
+
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewRecorder returns a Recorder writing the operations of backend to w.
+func NewRecorder(backend keychain.Backend, w io.Writer, opts Options) *Recorder {
+	redact := make(map[string]bool)
+	if !opts.KeepData {
+		redact[keychain.DataKey] = true
+		redact[keychain.GenericKey] = true
+	}
+
+	for _, key := range opts.Redact {
+		redact[key] = true
+	}
+
+	return &Recorder{backend: backend, redact: redact, enc: json.NewEncoder(w)}
+}
+
+// Err returns the first error encountered recording an operation. Recording
+// goes on after an error: operations with values which can't be recorded are
+// written without them, see Entry.Unrecorded.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.err
+}
+
+func (r *Recorder) record(op string, query, attrs, result interface{}, err error) {
+	redact := func(key string) bool { return r.redact[key] }
+	e := Entry{Op: op}
+
+	var errs []error
+
+	encode := func(v interface{}) *Value {
+		ev, err := encode(v, redact)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		return ev
+	}
+
+	e.Query, e.Attrs = encode(query), encode(attrs)
+
+	// Results holding only data (no dictionary) are redacted like data
+	// attributes.
+	if b, ok := result.([]byte); ok && r.redact[keychain.DataKey] {
+		e.Result = &Value{Redacted: redactedHash(b)}
+	} else {
+		e.Result = encode(result)
+	}
+
+	var kerr keychain.Error
+
+	switch {
+	case errors.As(err, &kerr):
+		e.Status = int(kerr)
+	case err != nil:
+		e.Error = err.Error()
+	}
+
+	var recErr error
+
+	if len(errs) > 0 {
+		recErr = fmt.Errorf("failed to record %s: %w", op, errors.Join(errs...))
+		e.Unrecorded = errors.Join(errs...).Error()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.enc.Encode(e); err != nil && recErr == nil {
+		recErr = fmt.Errorf("failed to write recording: %w", err)
+	}
+
+	if r.err == nil {
+		r.err = recErr
+	}
+}
+
+// Capabilities implements keychain.CapabilityReporter with the capabilities
+// of the recorded backend.
+func (r *Recorder) Capabilities() keychain.Capability {
+	return keychain.CapabilitiesOf(r.backend)
+}
+
+// Add implements keychain.Backend.
+func (r *Recorder) Add(attrs map[string]interface{}) (interface{}, error) {
+	result, err := r.backend.Add(attrs)
+	r.record("add", nil, attrs, result, err)
+
+	return result, err
+}
+
+// Update implements keychain.Backend.
+func (r *Recorder) Update(query, attrs map[string]interface{}) error {
+	err := r.backend.Update(query, attrs)
+	r.record("update", query, attrs, nil, err)
+
+	return err
+}
+
+// CopyMatching implements keychain.Backend.
+func (r *Recorder) CopyMatching(query map[string]interface{}) (interface{}, error) {
+	result, err := r.backend.CopyMatching(query)
+	r.record("copyMatching", query, nil, result, err)
+
+	return result, err
+}
+
+// Delete implements keychain.Backend.
+func (r *Recorder) Delete(query map[string]interface{}) error {
+	err := r.backend.Delete(query)
+	r.record("delete", query, nil, nil, err)
+
+	return err
+}
+
+// Load reads a recording.
+func Load(rd io.Reader) ([]Entry, error) {
+	var entries []Entry
+
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(nil, 64<<20)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("invalid recording entry on line %d: %w", line, err)
+		}
+
+		entries = append(entries, e)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,153 @@
+package keychainrecord_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+	"github.com/mailstone/go-keychain/keychainrecord"
+)
+
+// session runs a few operations against the current backend.
+func session() (accounts []string, data []byte, addErr error, err error) {
+	item := keychain.NewGenericPassword("MyService", "gabriel", "", []byte("toomanysecrets"), "")
+	if err := keychain.AddItem(item); err != nil {
+		return nil, nil, nil, err
+	}
+
+	addErr = keychain.AddItem(item)
+
+	accounts, err = keychain.GetGenericPasswordAccounts("MyService")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	data, err = keychain.GetGenericPassword("MyService", "gabriel", "", "")
+
+	return accounts, data, addErr, err
+}
+
+func TestRecordReplay(t *testing.T) {
+	var buf bytes.Buffer
+
+	recorder := keychainrecord.NewRecorder(keychainfake.New(), &buf, keychainrecord.Options{})
+	prev := keychain.SetBackend(recorder)
+	t.Cleanup(func() { keychain.SetBackend(prev) })
+
+	accounts, data, addErr, err := session()
+	if err != nil || recorder.Err() != nil {
+		t.Fatal(err, recorder.Err())
+	}
+
+	if string(data) != "toomanysecrets" {
+		t.Fatalf("unexpected data %q", data)
+	}
+
+	if strings.Contains(buf.String(), "toomanysecrets") || strings.Contains(buf.String(), "dG9vbWFueXNlY3JldHM") {
+		t.Fatal("recording contains item data")
+	}
+
+	entries, err := keychainrecord.Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayer := keychainrecord.NewReplayer(entries)
+	keychain.SetBackend(replayer)
+
+	replayedAccounts, replayedData, replayedAddErr, err := session()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(replayedAccounts, ",") != strings.Join(accounts, ",") {
+		t.Fatalf("expected accounts %v, got %v", accounts, replayedAccounts)
+	}
+
+	if !errors.Is(replayedAddErr, keychain.ErrorDuplicateItem) || !errors.Is(addErr, keychain.ErrorDuplicateItem) {
+		t.Fatalf("expected duplicate errors, got %v and %v", addErr, replayedAddErr)
+	}
+
+	if !strings.HasPrefix(string(replayedData), "sha256:") {
+		t.Fatalf("expected redacted data, got %q", replayedData)
+	}
+
+	if replayer.Remaining() != 0 {
+		t.Fatalf("%d entries not replayed", replayer.Remaining())
+	}
+
+	if _, err := keychain.GetGenericPassword("MyService", "gabriel", "", ""); !errors.Is(err, keychainrecord.ErrReplayMismatch) {
+		t.Fatalf("expected ErrReplayMismatch, got %v", err)
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	var buf bytes.Buffer
+
+	prev := keychain.SetBackend(keychainrecord.NewRecorder(keychainfake.New(), &buf, keychainrecord.Options{}))
+	t.Cleanup(func() { keychain.SetBackend(prev) })
+
+	if _, err := keychain.GetGenericPassword("MyService", "gabriel", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := keychainrecord.Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keychain.SetBackend(keychainrecord.NewReplayer(entries))
+
+	_, err = keychain.GetGenericPassword("MyService", "alice", "", "")
+
+	var mismatch *keychainrecord.MismatchError
+	if !errors.As(err, &mismatch) || mismatch.Reason != "query differs" {
+		t.Fatalf("expected query mismatch, got %v", err)
+	}
+}
+
+// oddBackend returns results of a type recordings don't know.
+type oddBackend struct{ keychain.Backend }
+
+func (oddBackend) CopyMatching(map[string]interface{}) (interface{}, error) {
+	return complex(1, 2), nil
+}
+
+func TestRecordUnknownType(t *testing.T) {
+	var buf bytes.Buffer
+
+	recorder := keychainrecord.NewRecorder(oddBackend{keychainfake.New()}, &buf, keychainrecord.Options{})
+	query := map[string]interface{}{keychain.SecClassKey: "genp"}
+
+	if _, err := recorder.CopyMatching(query); err != nil {
+		t.Fatal(err)
+	}
+
+	if recorder.Err() == nil {
+		t.Fatal("expected the recording error to be reported")
+	}
+
+	// Recording goes on.
+	if err := recorder.Delete(query); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound, got %v", err)
+	}
+
+	entries, err := keychainrecord.Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 || entries[0].Unrecorded == "" || entries[1].Op != "delete" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+
+	replayer := keychainrecord.NewReplayer(entries)
+
+	var mismatch *keychainrecord.MismatchError
+	if _, err := replayer.CopyMatching(query); !errors.As(err, &mismatch) {
+		t.Fatalf("expected a mismatch for the incomplete entry, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package keychainrecord
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	keychain "github.com/mailstone/go-keychain"
+)
+
+// ErrReplayMismatch is returned (wrapped in a *MismatchError) by a Replayer
+// for calls that differ from the recording, or whose entry is incomplete.
+var ErrReplayMismatch = errors.New("call does not match recording")
+
+// MismatchError describes a call that differs from the recording.
+type MismatchError struct {
+	// Index is the index of the expected entry, or the number of entries if
+	// the recording is exhausted.
+	Index int
+	// Op is the operation called.
+	Op string
+	// Reason describes the difference.
+	Reason string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("replay entry %d: %s: %s", e.Index, e.Op, e.Reason)
+}
+
+// Is reports whether target is ErrReplayMismatch.
+func (e *MismatchError) Is(target error) bool {
+	return target == ErrReplayMismatch
+}
+
+// Replayer is a keychain.Backend serving recorded results. Calls must be made
+// in the recorded order with the recorded queries and attributes (redacted
+// values are compared by hash). Redacted results are returned as data holding
+// the hash.
+type Replayer struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int
+}
+
+// NewReplayer returns a Replayer for the recorded entries.
+func NewReplayer(entries []Entry) *Replayer {
+	return &Replayer{entries: entries}
+}
+
+// Remaining returns the number of entries not yet replayed.
+func (p *Replayer) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.entries) - p.next
+}
+
+func (p *Replayer) replay(op string, query, attrs map[string]interface{}) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.next >= len(p.entries) {
+		return nil, &MismatchError{Index: p.next, Op: op, Reason: "recording exhausted"}
+	}
+
+	e := p.entries[p.next]
+
+	switch {
+	case e.Op != op:
+		return nil, &MismatchError{Index: p.next, Op: op, Reason: "recorded " + e.Op}
+	case e.Unrecorded != "":
+		return nil, &MismatchError{Index: p.next, Op: op, Reason: "incomplete recording: " + e.Unrecorded}
+	case query != nil && !e.Query.matches(query):
+		return nil, &MismatchError{Index: p.next, Op: op, Reason: "query differs"}
+	case attrs != nil && !e.Attrs.matches(attrs):
+		return nil, &MismatchError{Index: p.next, Op: op, Reason: "attributes differ"}
+	}
+
+	p.next++
+
+	switch {
+	case e.Status != 0:
+		return nil, keychain.Error(e.Status)
+	case e.Error != "":
+		return nil, errors.New(e.Error)
+	}
+
+	return e.Result.decode(), nil
+}
+
+// Capabilities implements keychain.CapabilityReporter. Recordings don't
+// capture the capabilities of the recorded backend, so none are reported
+// except UniqueKeys: duplicates are reported as recorded.
+func (p *Replayer) Capabilities() keychain.Capability {
+	return keychain.Capability{UniqueKeys: true}
+}
+
+// Add implements keychain.Backend.
+func (p *Replayer) Add(attrs map[string]interface{}) (interface{}, error) {
+	return p.replay("add", nil, attrs)
+}
+
+// Update implements keychain.Backend.
+func (p *Replayer) Update(query, attrs map[string]interface{}) error {
+	_, err := p.replay("update", query, attrs)
+
+	return err
+}
+
+// CopyMatching implements keychain.Backend.
+func (p *Replayer) CopyMatching(query map[string]interface{}) (interface{}, error) {
+	return p.replay("copyMatching", query, nil)
+}
+
+// Delete implements keychain.Backend.
+func (p *Replayer) Delete(query map[string]interface{}) error {
+	_, err := p.replay("delete", query, nil)
+
+	return err
+}
@@ -0,0 +1,204 @@
+package keychainrecord
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Value is a recorded backend value. Exactly one field is set, preserving the
+// Go type of the value (see keychain.Backend), or none for nil.
+type Value struct {
+	String  *string           `json:"s,omitempty"`
+	Bytes   []byte            `json:"b,omitempty"`
+	Bool    *bool             `json:"bool,omitempty"`
+	Int32   *int32            `json:"i32,omitempty"`
+	Int64   *int64            `json:"i64,omitempty"`
+	Float64 *float64          `json:"f64,omitempty"`
+	Time    *time.Time        `json:"time,omitempty"`
+	Dict    map[string]*Value `json:"dict,omitempty"`
+	Array   []*Value          `json:"array,omitempty"`
+	// Redacted is the hash (see redactedHash) of a value that was not
+	// recorded.
+	Redacted string `json:"redacted,omitempty"`
+	// IsBytes marks empty data, which Bytes can't represent.
+	IsBytes bool `json:"isBytes,omitempty"`
+	// IsArray marks empty arrays, which Array can't represent.
+	IsArray bool `json:"isArray,omitempty"`
+	// IsDict marks empty dictionaries, which Dict can't represent.
+	IsDict bool `json:"isDict,omitempty"`
+}
+
+// redactedHash returns a short hash of v, so redacted values can still be
+// compared on replay.
+func redactedHash(v interface{}) string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%T:%v", v, v)))
+
+	return "sha256:" + hex.EncodeToString(h[:8])
+}
+
+// NewValue converts a backend value (see keychain.Backend) to a Value.
+func NewValue(v interface{}) (*Value, error) {
+	return encode(v, func(string) bool { return false })
+}
+
+// Interface converts v back to a Go value. Redacted values are returned as
+// data holding their hash.
+func (v *Value) Interface() interface{} {
+	return v.decode()
+}
+
+// encode converts v to a Value, redacting the values of dictionary keys for
+// which redact returns true.
+func encode(v interface{}, redact func(key string) bool) (*Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return &Value{String: &v}, nil
+	case []byte:
+		return &Value{Bytes: v, IsBytes: len(v) == 0}, nil
+	case bool:
+		return &Value{Bool: &v}, nil
+	case int32:
+		return &Value{Int32: &v}, nil
+	case int64:
+		return &Value{Int64: &v}, nil
+	case int:
+		n := int64(v)
+
+		return &Value{Int64: &n}, nil
+	case float64:
+		return &Value{Float64: &v}, nil
+	case time.Time:
+		return &Value{Time: &v}, nil
+	case map[string]interface{}:
+		d := make(map[string]*Value, len(v))
+
+		for key, e := range v {
+			if redact(key) {
+				d[key] = &Value{Redacted: redactedHash(e)}
+
+				continue
+			}
+
+			ev, err := encode(e, redact)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+
+			d[key] = ev
+		}
+
+		return &Value{Dict: d, IsDict: len(d) == 0}, nil
+	case []interface{}:
+		a := make([]*Value, 0, len(v))
+
+		for _, e := range v {
+			ev, err := encode(e, redact)
+			if err != nil {
+				return nil, err
+			}
+
+			a = append(a, ev)
+		}
+
+		return &Value{Array: a, IsArray: len(a) == 0}, nil
+	default:
+		return nil, fmt.Errorf("unsupported value type %T", v)
+	}
+}
+
+// decode converts a Value back to a Go value. Redacted values are returned as
+// data holding their hash.
+func (v *Value) decode() interface{} {
+	switch {
+	case v == nil:
+		return nil
+	case v.String != nil:
+		return *v.String
+	case v.Bytes != nil || v.IsBytes:
+		return append([]byte{}, v.Bytes...)
+	case v.Bool != nil:
+		return *v.Bool
+	case v.Int32 != nil:
+		return *v.Int32
+	case v.Int64 != nil:
+		return *v.Int64
+	case v.Float64 != nil:
+		return *v.Float64
+	case v.Time != nil:
+		return *v.Time
+	case v.Dict != nil || v.IsDict:
+		d := make(map[string]interface{}, len(v.Dict))
+		for key, e := range v.Dict {
+			d[key] = e.decode()
+		}
+
+		return d
+	case v.Array != nil || v.IsArray:
+		a := make([]interface{}, 0, len(v.Array))
+		for _, e := range v.Array {
+			a = append(a, e.decode())
+		}
+
+		return a
+	case v.Redacted != "":
+		return []byte(v.Redacted)
+	}
+
+	return nil
+}
+
+// matches returns whether the Go value x equals the recorded value v,
+// comparing hashes for redacted values.
+func (v *Value) matches(x interface{}) bool {
+	switch {
+	case v == nil:
+		return x == nil
+	case v.Redacted != "":
+		return redactedHash(x) == v.Redacted
+	case v.Dict != nil || v.IsDict:
+		d, ok := x.(map[string]interface{})
+		if !ok || len(d) != len(v.Dict) {
+			return false
+		}
+
+		for key, e := range v.Dict {
+			if xe, ok := d[key]; !ok || !e.matches(xe) {
+				return false
+			}
+		}
+
+		return true
+	case v.Array != nil || v.IsArray:
+		a, ok := x.([]interface{})
+		if !ok || len(a) != len(v.Array) {
+			return false
+		}
+
+		for i, e := range v.Array {
+			if !e.matches(a[i]) {
+				return false
+			}
+		}
+
+		return true
+	case v.Time != nil:
+		t, ok := x.(time.Time)
+
+		return ok && t.Equal(*v.Time)
+	case v.Bytes != nil || v.IsBytes:
+		b, ok := x.([]byte)
+
+		return ok && bytes.Equal(b, v.Bytes)
+	}
+
+	if n, ok := x.(int); ok {
+		x = int64(n)
+	}
+
+	return v.decode() == x
+}
@@ -0,0 +1,97 @@
+package keychain
+
+import (
+	"sync"
+)
+
+// ItemKind describes how an item is presented by Keychain Access and other
+// keychain UIs, which derive the kind (and icon) of an item from its class,
+// type and authentication type.
+type ItemKind struct {
+	// Name is the kind as displayed, e.g. "application password".
+	Name  string
+	Class SecClass
+	// Type is the four character code of the type attribute, if any.
+	Type string
+	// AuthenticationType is the authentication type attribute, if any (for
+	// internet password items).
+	AuthenticationType string
+}
+
+// Well-known item kinds.
+var (
+	// KindApplicationPassword is a generic password.
+	KindApplicationPassword = ItemKind{Name: "application password", Class: SecClassGenericPassword}
+	// KindSecureNote is a generic password displayed as a secure note.
+	KindSecureNote = ItemKind{Name: "secure note", Class: SecClassGenericPassword, Type: "note"}
+	// KindInternetPassword is an internet password.
+	KindInternetPassword = ItemKind{Name: "Internet password", Class: SecClassInternetPassword}
+	// KindWebFormPassword is an internet password saved from a web form.
+	KindWebFormPassword = ItemKind{Name: "Web form password", Class: SecClassInternetPassword, AuthenticationType: "form"}
+)
+
+var itemKinds = struct {
+	sync.Mutex
+	kinds []ItemKind
+}{kinds: []ItemKind{KindApplicationPassword, KindSecureNote, KindInternetPassword, KindWebFormPassword}}
+
+// RegisterItemKind adds an application specific kind to the registry used by
+// KindOf.
+func RegisterItemKind(kind ItemKind) {
+	itemKinds.Lock()
+	defer itemKinds.Unlock()
+
+	itemKinds.kinds = append(itemKinds.kinds, kind)
+}
+
+// ItemKinds returns the registered item kinds.
+func ItemKinds() []ItemKind {
+	itemKinds.Lock()
+	defer itemKinds.Unlock()
+
+	return append([]ItemKind(nil), itemKinds.kinds...)
+}
+
+// SetKind sets the class, type and authentication type attributes of kind.
+func (k *Item) SetKind(kind ItemKind) error {
+	k.SetSecClass(kind.Class)
+	k.SetAuthenticationType(kind.AuthenticationType)
+
+	return k.SetType(kind.Type)
+}
+
+// KindOf returns the most specific registered kind matching a result of
+// secClass. The query must have returned attributes.
+func KindOf(secClass SecClass, r QueryResult) (ItemKind, bool) {
+	var (
+		best  ItemKind
+		found bool
+	)
+
+	for _, kind := range ItemKinds() {
+		if kind.Class != secClass ||
+			(kind.Type != "" && kind.Type != r.Type) ||
+			(kind.AuthenticationType != "" && kind.AuthenticationType != r.AuthenticationType) {
+			continue
+		}
+
+		if !found || specificity(kind) > specificity(best) {
+			best, found = kind, true
+		}
+	}
+
+	return best, found
+}
+
+func specificity(kind ItemKind) int {
+	n := 0
+	if kind.Type != "" {
+		n++
+	}
+
+	if kind.AuthenticationType != "" {
+		n++
+	}
+
+	return n
+}
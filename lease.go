@@ -0,0 +1,242 @@
+package keychain
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrLeaseExpired is returned by SecretLease.Use once the lease expired or was
+// released.
+var ErrLeaseExpired = errors.New("secret lease expired")
+
+// leaseEntry is the data of an item shared by the leases of a query, in locked
+// memory.
+type leaseEntry struct {
+	key    string
+	result QueryResult
+	// refs is the number of leases of the entry, guarded by leases.
+	refs int
+
+	mu   sync.RWMutex
+	data []byte
+}
+
+// wipe wipes the data of e. Leases of e fail with ErrLeaseExpired from then
+// on.
+func (e *leaseEntry) wipe() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.data != nil {
+		freeLocked(e.data)
+		e.data = nil
+	}
+}
+
+var leases = struct {
+	sync.Mutex
+	// byKey are the entries new leases share, by query.
+	byKey map[string]*leaseEntry
+	// live are the entries with leases.
+	live map[*leaseEntry]struct{}
+}{byKey: make(map[string]*leaseEntry), live: make(map[*leaseEntry]struct{})}
+
+// SecretLease is the data of an item leased with Lease, until it expires.
+type SecretLease struct {
+	mu      sync.Mutex
+	entry   *leaseEntry
+	expires time.Time
+	timer   *time.Timer
+}
+
+// Lease reads the data of the item matching query and returns it as a lease
+// valid for ttl, limiting how long the secret stays in memory: the data is
+// kept in locked memory where the platform allows (see SetPromptCache), and is
+// wiped once the last lease of the item expires or is released. Leases of the
+// same query share the data while one of them is valid; after that, Lease
+// reads the item again, which may prompt the user to authenticate.
+//
+// Changing an item through this package stops sharing the data read before, so
+// leases taken after the change read it again. Shutdown wipes the data of all
+// leases.
+//
+//	lease, err := keychain.Lease(query, 30*time.Second)
+//	if err != nil {
+//		return err
+//	}
+//	defer lease.Release()
+//
+//	err = lease.Use(func(token []byte) error {
+//		return client.Authenticate(token)
+//	})
+func Lease(query Item, ttl time.Duration) (*SecretLease, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("invalid lease duration %s", ttl)
+	}
+
+	q := query.clone()
+	q.SetMatchLimit(MatchLimitOne)
+	q.SetReturnAttributes(true)
+	q.SetReturnData(true)
+
+	attrs, err := EncodeAttributes(q)
+	if err != nil {
+		return nil, err
+	}
+
+	key, _ := promptCacheKey(attrs)
+
+	leases.Lock()
+	e, ok := leases.byKey[key]
+	if ok {
+		e.refs++
+	}
+	leases.Unlock()
+
+	if !ok {
+		if e, err = readLeaseEntry(key, q); err != nil {
+			return nil, err
+		}
+	}
+
+	l := &SecretLease{entry: e, expires: time.Now().Add(ttl)}
+
+	l.mu.Lock()
+	l.timer = time.AfterFunc(ttl, l.Release)
+	l.mu.Unlock()
+
+	return l, nil
+}
+
+// readLeaseEntry reads the item matching q into a new entry under key, with a
+// lease, unless one was read concurrently.
+func readLeaseEntry(key string, q Item) (*leaseEntry, error) {
+	results, err := QueryItem(q)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) == 0 {
+		return nil, ErrorItemNotFound
+	}
+
+	e := &leaseEntry{key: key, result: results[0], refs: 1, data: allocLocked(len(results[0].Data))}
+	copy(e.data, results[0].Data)
+	clear(results[0].Data)
+	e.result.Data = nil
+
+	leases.Lock()
+	defer leases.Unlock()
+
+	if other, ok := leases.byKey[key]; ok {
+		other.refs++
+		e.wipe()
+
+		return other, nil
+	}
+
+	leases.byKey[key] = e
+	leases.live[e] = struct{}{}
+
+	return e, nil
+}
+
+// Use calls fn with the leased data, or returns ErrLeaseExpired. The data is
+// only valid during the call: fn must copy what it keeps, and mustn't modify
+// it. Release and the expiry of the lease wait for fn to return.
+func (l *SecretLease) Use(fn func(data []byte) error) error {
+	l.mu.Lock()
+	e, expired := l.entry, time.Now().After(l.expires)
+	l.mu.Unlock()
+
+	if e == nil || expired {
+		return ErrLeaseExpired
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.data == nil {
+		return ErrLeaseExpired
+	}
+
+	return fn(e.data)
+}
+
+// Attributes returns the attributes of the leased item, without its data.
+func (l *SecretLease) Attributes() QueryResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.entry == nil {
+		return QueryResult{}
+	}
+
+	return l.entry.result
+}
+
+// Expires returns when the lease expires.
+func (l *SecretLease) Expires() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.expires
+}
+
+// Release ends the lease before it expires, wiping the data if no other lease
+// shares it. Releasing a lease again has no effect.
+func (l *SecretLease) Release() {
+	l.mu.Lock()
+	e := l.entry
+	l.entry = nil
+
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+	l.mu.Unlock()
+
+	if e == nil {
+		return
+	}
+
+	leases.Lock()
+	e.refs--
+	last := e.refs == 0
+
+	if last {
+		delete(leases.live, e)
+
+		if leases.byKey[e.key] == e {
+			delete(leases.byKey, e.key)
+		}
+	}
+	leases.Unlock()
+
+	if last {
+		e.wipe()
+	}
+}
+
+// forgetLeases stops sharing the data of the current leases with new ones,
+// after an item changed.
+func forgetLeases() {
+	leases.Lock()
+	defer leases.Unlock()
+
+	clear(leases.byKey)
+}
+
+// revokeLeases wipes the data of all leases.
+func revokeLeases() {
+	leases.Lock()
+	defer leases.Unlock()
+
+	for e := range leases.live {
+		e.wipe()
+	}
+
+	clear(leases.byKey)
+	clear(leases.live)
+}
@@ -0,0 +1,90 @@
+package keychain_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func TestLease(t *testing.T) {
+	f := keychainfake.Install(t)
+
+	if err := keychain.SetString("com.example.app", "api-token", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	query := genericPasswordQuery("com.example.app", "api-token")
+
+	first, err := keychain.Lease(query, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := len(f.Calls())
+
+	second, err := keychain.Lease(query, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(f.Calls()) - calls; n != 0 {
+		t.Fatalf("expected the leases to share the data, got %d backend calls", n)
+	}
+
+	if a := second.Attributes(); a.Account != "api-token" || a.Data != nil {
+		t.Fatalf("unexpected attributes: %+v", a)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	if err := first.Use(func([]byte) error { return nil }); !errors.Is(err, keychain.ErrLeaseExpired) {
+		t.Fatalf("expected ErrLeaseExpired, got %v", err)
+	}
+
+	var s string
+
+	if err := second.Use(func(data []byte) error { s = string(data); return nil }); err != nil || s != "secret" {
+		t.Fatalf("expected the data of the valid lease, got %q (%v)", s, err)
+	}
+
+	second.Release()
+	second.Release()
+
+	if err := second.Use(func([]byte) error { return nil }); !errors.Is(err, keychain.ErrLeaseExpired) {
+		t.Fatalf("expected ErrLeaseExpired after Release, got %v", err)
+	}
+
+	calls = len(f.Calls())
+
+	third, err := keychain.Lease(query, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer third.Release()
+
+	if n := len(f.Calls()) - calls; n != 1 {
+		t.Fatalf("expected the item to be read again, got %d backend calls", n)
+	}
+
+	// Changes stop sharing the data read before.
+	if err := keychain.SetString("com.example.app", "api-token", "changed"); err != nil {
+		t.Fatal(err)
+	}
+
+	fourth, err := keychain.Lease(query, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fourth.Release()
+
+	if err := fourth.Use(func(data []byte) error { s = string(data); return nil }); err != nil || s != "changed" {
+		t.Fatalf("expected the changed data, got %q (%v)", s, err)
+	}
+
+	if _, err := keychain.Lease(genericPasswordQuery("com.example.app", "missing"), time.Hour); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !darwin && !linux
+// +build !darwin,!linux
+
+package keychain
+
+// allocLocked returns n bytes of memory. Memory isn't locked on this
+// platform.
+func allocLocked(n int) []byte {
+	return make([]byte, n)
+}
+
+// freeLocked wipes memory returned by allocLocked.
+func freeLocked(b []byte) {
+	clear(b)
+}
@@ -0,0 +1,36 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package keychain
+
+import "syscall"
+
+// allocLocked returns n bytes of memory locked into RAM, outside of the Go
+// heap so the garbage collector never copies it. It falls back to the heap if
+// the memory can't be locked, e.g. over RLIMIT_MEMLOCK.
+func allocLocked(n int) []byte {
+	if n == 0 {
+		return []byte{}
+	}
+
+	b, err := syscall.Mmap(-1, 0, n, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return make([]byte, n)
+	}
+
+	if err := syscall.Mlock(b); err != nil {
+		_ = syscall.Munmap(b)
+
+		return make([]byte, n)
+	}
+
+	return b
+}
+
+// freeLocked wipes and releases memory returned by allocLocked. Unmapping
+// unlocks the memory; syscall.Munmap ignores heap memory.
+func freeLocked(b []byte) {
+	clear(b)
+
+	_ = syscall.Munmap(b)
+}
@@ -4,7 +4,9 @@
 package keychain
 
 import (
+	"context"
 	"testing"
+	"time"
 )
 
 func TestUpdateItem(t *testing.T) {
@@ -127,3 +129,147 @@ func TestInternetPassword(t *testing.T) {
 		t.Errorf("expected comment 'this is the comment' but got %q", r.Comment)
 	}
 }
+
+func TestItemExists(t *testing.T) {
+	item := NewGenericPassword("TestItemExists", "test", "", []byte("toomanysecrets"), "")
+	defer func() { _ = DeleteItem(item) }()
+
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService("TestItemExists")
+	query.SetAccount("test")
+
+	exists, err := ItemExists(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatal("item should not exist yet")
+	}
+
+	if err := AddItem(item); err != nil {
+		t.Fatal(err)
+	}
+
+	exists, err = ItemExists(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Fatal("item should exist")
+	}
+}
+
+func TestAddItemReturningPersistentRef(t *testing.T) {
+	item := NewGenericPassword("TestAddItemReturningPersistentRef", "test", "", []byte("toomanysecrets"), "")
+	defer func() { _ = DeleteItem(item) }()
+
+	ref, err := AddItemReturningPersistentRef(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ref) == 0 {
+		t.Fatal("expected a persistent reference")
+	}
+
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetPersistentRef(ref)
+	query.SetMatchLimit(MatchLimitOne)
+	query.SetReturnData(true)
+
+	results, err := QueryItem(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || string(results[0].Data) != "toomanysecrets" {
+		t.Fatalf("unexpected results for persistent reference: %+v", results)
+	}
+}
+
+func TestItemKind(t *testing.T) {
+	item := NewGenericPassword("TestItemKind", "test", "A note", []byte("note contents"), "")
+	if err := item.SetKind(KindSecureNote); err != nil {
+		t.Fatal(err)
+	}
+	if err := item.SetCreator("GoKc"); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = DeleteItem(item) }()
+	if err := AddItem(item); err != nil {
+		t.Fatal(err)
+	}
+
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService("TestItemKind")
+	query.SetMatchLimit(MatchLimitOne)
+	query.SetReturnAttributes(true)
+	results, err := QueryItem(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Type != "note" || results[0].Creator != "GoKc" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	kind, ok := KindOf(SecClassGenericPassword, results[0])
+	if !ok || kind.Name != KindSecureNote.Name {
+		t.Fatalf("expected secure note kind, got %+v", kind)
+	}
+}
+
+func TestSecurityConstants(t *testing.T) {
+	for key, ref := range securityKeys() {
+		if got := attrKey(ref); got != key {
+			t.Errorf("key %q: framework constant is %q", key, got)
+		}
+	}
+
+	for value, ref := range securityConstants {
+		if got := attrKey(ref); got != value {
+			t.Errorf("value %q: framework constant is %q", value, got)
+		}
+	}
+
+	for e, code := range securityErrors() {
+		if int(e) != int(code) {
+			t.Errorf("%v: framework result code is %d", e, code)
+		}
+	}
+}
+
+func TestCancelablePrompt(t *testing.T) {
+	query := NewItem()
+	query.SetReturnData(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	q, stop := withCancelablePrompt(ctx, query)
+	defer stop()
+
+	authCtx, ok := q.attr[UseAuthenticationContextKey].(*AuthenticationContext)
+	if !ok {
+		t.Fatal("expected an authentication context")
+	}
+
+	if _, ok := query.attr[UseAuthenticationContextKey]; ok {
+		t.Fatal("expected the query to be left unchanged")
+	}
+
+	cancel()
+
+	// The context is invalidated asynchronously.
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		ref, err := authCtx.Convert()
+		if err != nil {
+			break
+		}
+
+		Release(ref)
+
+		if time.Now().After(deadline) {
+			t.Fatal("expected the authentication context to be invalidated")
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package keychain
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MarkerAccount is the account of the generic password item written by
+// WriteMarker.
+const MarkerAccount = "go-keychain.marker"
+
+// modulePath is the module path of this package.
+const modulePath = "github.com/mailstone/go-keychain"
+
+// Features recorded in a Marker. They are strings rather than Feature values,
+// which are features of the OS.
+const (
+	// MarkerChangeSequences is recorded with change sequences, see
+	// SetChangeSequences.
+	MarkerChangeSequences = "change-sequences"
+	// MarkerUsageTracking is recorded with usage tracking, see
+	// SetUsageTracking.
+	MarkerUsageTracking = "usage-tracking"
+	// MarkerPipeline is recorded with a pipeline, followed by "=" and its
+	// descriptor, see SetPipeline.
+	MarkerPipeline = "pipeline"
+	// MarkerStrict is recorded by programs built with the keychain_strict
+	// tag.
+	MarkerStrict = "keychain_strict"
+)
+
+// Marker describes the program which last wrote the items of a service, see
+// WriteMarker.
+type Marker struct {
+	// PackageVersion is the version of this package, see PackageVersion.
+	PackageVersion string `json:"packageVersion"`
+	// SchemaVersion is the schema version of the service, see Migrations.
+	SchemaVersion int `json:"schemaVersion"`
+	// Features are the features of this package in use, e.g.
+	// "change-sequences" or "pipeline=gzip|aes-2024".
+	Features []string `json:"features,omitempty"`
+	// Written is when the marker was written.
+	Written time.Time `json:"written"`
+}
+
+// Has returns whether the marker records feature, ignoring its value, e.g.
+// Has(MarkerPipeline) for "pipeline=gzip".
+func (m Marker) Has(feature string) bool {
+	return slices.ContainsFunc(m.Features, func(f string) bool {
+		return f == feature || strings.HasPrefix(f, feature+"=")
+	})
+}
+
+// PackageVersion returns the version of this package in the build of the
+// program, e.g. "v1.4.0", or "(devel)" if it isn't known.
+func PackageVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+
+	version := ""
+
+	if info.Main.Path == modulePath {
+		version = info.Main.Version
+	}
+
+	for _, dep := range info.Deps {
+		if dep.Path != modulePath {
+			continue
+		}
+
+		version = dep.Version
+		if dep.Replace != nil && dep.Replace.Version != "" {
+			version = dep.Replace.Version
+		}
+	}
+
+	if version == "" {
+		return "(devel)"
+	}
+
+	return version
+}
+
+// features returns the features of the configuration in use.
+func (s *state) features() []string {
+	var features []string
+
+	if s.cfg.ChangeSequences {
+		features = append(features, MarkerChangeSequences)
+	}
+
+	if s.cfg.UsageTracking {
+		features = append(features, MarkerUsageTracking)
+	}
+
+	if len(s.cfg.Pipeline) > 0 {
+		features = append(features, MarkerPipeline+"="+s.cfg.Pipeline.ID())
+	}
+
+	if strict {
+		features = append(features, MarkerStrict)
+	}
+
+	return features
+}
+
+// WriteMarker records the version of this package, the schema version of
+// service (see Migrations) and the features of the package configuration in
+// use in the generic password for (service, MarkerAccount), as JSON. Later
+// versions of a program, and support tools, read it with ReadMarker to learn
+// what wrote the items of the service, e.g. to keep reading them the same
+// way. The marker isn't transformed by the pipeline of the configuration.
+func WriteMarker(service string) error {
+	version, err := NewMigrations(service).Version()
+	if err != nil {
+		return err
+	}
+
+	m := Marker{
+		PackageVersion: PackageVersion(),
+		SchemaVersion:  version,
+		Features:       cur().features(),
+		Written:        time.Now().UTC().Truncate(time.Second),
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	if err := upsertGenericPassword(service, MarkerAccount, "", data); err != nil {
+		return fmt.Errorf("failed to write the marker of %q: %w", service, err)
+	}
+
+	return nil
+}
+
+// ReadMarker returns the marker of service written by WriteMarker, or
+// ErrorItemNotFound if there is none, e.g. because the items were written by
+// a version of this package without markers. Fields unknown to this version
+// are ignored.
+func ReadMarker(service string) (Marker, error) {
+	data, err := GetGenericPassword(service, MarkerAccount, "", "")
+	if err != nil {
+		return Marker{}, fmt.Errorf("failed to read the marker of %q: %w", service, err)
+	}
+
+	if data == nil {
+		return Marker{}, ErrorItemNotFound
+	}
+
+	var m Marker
+	if err := json.Unmarshal(data, &m); err != nil {
+		return Marker{}, fmt.Errorf("invalid marker of %q: %w", service, err)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,50 @@
+package keychain_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func TestMarker(t *testing.T) {
+	keychainfake.Install(t)
+
+	if _, err := keychain.ReadMarker("com.example.app"); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound, got %v", err)
+	}
+
+	m := keychain.NewMigrations("com.example.app")
+	if err := m.Register(3, "noop", keychain.FuncStep("noop", func() error { return nil })); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Run(context.Background(), keychain.BulkOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	prev := keychain.SetChangeSequences(true)
+	defer keychain.SetChangeSequences(prev)
+
+	keychain.SetPipeline(keychain.Pipeline{keychain.GzipTransform})
+	defer keychain.SetPipeline(nil)
+
+	if err := keychain.WriteMarker("com.example.app"); err != nil {
+		t.Fatal(err)
+	}
+
+	marker, err := keychain.ReadMarker("com.example.app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if marker.PackageVersion != keychain.PackageVersion() || marker.SchemaVersion != 3 || marker.Written.IsZero() {
+		t.Fatalf("unexpected marker %+v", marker)
+	}
+
+	if !marker.Has(keychain.MarkerChangeSequences) || !marker.Has(keychain.MarkerPipeline) || marker.Has(keychain.MarkerUsageTracking) {
+		t.Fatalf("unexpected features %q", marker.Features)
+	}
+}
@@ -0,0 +1,183 @@
+package keychain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// boundField is a struct field tagged for storage in the keychain.
+type boundField struct {
+	index     []int
+	service   string
+	account   string
+	omitEmpty bool
+}
+
+// parseFields returns the tagged fields of the struct type t. Fields are tagged
+// `keychain:"account"` and stored as generic passwords of service, unless the
+// tag overrides it: `keychain:"account,service=other"`. With ",omitempty",
+// Marshal deletes the item instead of storing an empty value.
+func parseFields(t reflect.Type, service string) ([]boundField, error) {
+	fields := make([]boundField, 0, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag, ok := f.Tag.Lookup("keychain")
+		if !ok || tag == "-" {
+			continue
+		}
+
+		if !f.IsExported() {
+			return nil, fmt.Errorf("field %s is tagged but not exported", f.Name)
+		}
+
+		parts := strings.Split(tag, ",")
+		bf := boundField{index: f.Index, service: service, account: parts[0]}
+
+		if bf.account == "" {
+			bf.account = f.Name
+		}
+
+		for _, opt := range parts[1:] {
+			switch {
+			case opt == "omitempty":
+				bf.omitEmpty = true
+			case strings.HasPrefix(opt, "service="):
+				bf.service = strings.TrimPrefix(opt, "service=")
+			default:
+				return nil, fmt.Errorf("field %s: unknown keychain tag option %q", f.Name, opt)
+			}
+		}
+
+		fields = append(fields, bf)
+	}
+
+	return fields, nil
+}
+
+func structValue(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("expected a non-nil pointer to a struct, got %T", v)
+	}
+
+	return rv.Elem(), nil
+}
+
+// encodeField encodes a field value: strings and byte slices are stored as is,
+// other types as JSON.
+func encodeField(v reflect.Value) ([]byte, error) {
+	switch {
+	case v.Kind() == reflect.String:
+		return []byte(v.String()), nil
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		return v.Bytes(), nil
+	default:
+		b, err := json.Marshal(v.Interface())
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", v.Type(), err)
+		}
+
+		return b, nil
+	}
+}
+
+func decodeField(v reflect.Value, data []byte) error {
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(string(data))
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		v.SetBytes(append([]byte(nil), data...))
+	default:
+		p := reflect.New(v.Type())
+		if err := json.Unmarshal(data, p.Interface()); err != nil {
+			return fmt.Errorf("failed to decode %s: %w", v.Type(), err)
+		}
+
+		v.Set(p.Elem())
+	}
+
+	return nil
+}
+
+// Unmarshal reads the tagged fields of the struct pointed to by v from the
+// keychain, reversing the pipeline their items were written with, if any. See
+// parseFields for the tag format. Fields whose items don't exist are left
+// unchanged.
+func Unmarshal(service string, v interface{}) error {
+	return cur().unmarshal(service, v)
+}
+
+func (s *state) unmarshal(service string, v interface{}) error {
+	sv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+
+	fields, err := parseFields(sv.Type(), service)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		data, err := s.getBytes(f.service, f.account)
+		if err != nil && !errors.Is(err, ErrorItemNotFound) {
+			return fmt.Errorf("failed to read %q: %w", f.account, err)
+		}
+
+		if len(data) == 0 {
+			continue
+		}
+
+		if err := decodeField(sv.FieldByIndex(f.index), data); err != nil {
+			return fmt.Errorf("field for %q: %w", f.account, err)
+		}
+	}
+
+	return nil
+}
+
+// Marshal writes the tagged fields of the struct pointed to by v to the
+// keychain, adding or updating generic passwords as needed. The data is
+// transformed by the pipeline of the configuration, as with SetBytes.
+func Marshal(service string, v interface{}) error {
+	s := cur()
+
+	sv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+
+	fields, err := parseFields(sv.Type(), service)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		fv := sv.FieldByIndex(f.index)
+
+		if f.omitEmpty && fv.IsZero() {
+			err := DeleteGenericPasswordItem(f.service, f.account)
+			if err != nil && !errors.Is(err, ErrorItemNotFound) {
+				return fmt.Errorf("failed to delete %q: %w", f.account, err)
+			}
+
+			continue
+		}
+
+		data, err := encodeField(fv)
+		if err != nil {
+			return err
+		}
+
+		if err := s.setBytes(f.service, f.account, data); err != nil {
+			return fmt.Errorf("failed to write %q: %w", f.account, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+//go:build darwin && !ios
+// +build darwin,!ios
+
+package keychain
+
+import (
+	"testing"
+	"time"
+)
+
+type testConfig struct {
+	Token   string   `keychain:"token"`
+	Key     []byte   `keychain:"key,omitempty"`
+	Scopes  []string `keychain:"scopes"`
+	Ignored string
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	service := "TestMarshalUnmarshal"
+	defer func() { _ = DeleteGenericPasswordItem(service, "token") }()
+	defer func() { _ = DeleteGenericPasswordItem(service, "scopes") }()
+
+	in := testConfig{Token: "t0ken", Scopes: []string{"read", "write"}, Ignored: "x"}
+	if err := Marshal(service, &in); err != nil {
+		t.Fatal(err)
+	}
+
+	var out testConfig
+	if err := Unmarshal(service, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Token != "t0ken" || len(out.Scopes) != 2 || out.Key != nil || out.Ignored != "" {
+		t.Fatalf("unexpected result: %+v", out)
+	}
+}
+
+func TestBinder(t *testing.T) {
+	service := "TestBinder"
+	defer func() { _ = DeleteGenericPasswordItem(service, "token") }()
+
+	prev := SetWatchPollInterval(50 * time.Millisecond)
+	defer SetWatchPollInterval(prev)
+
+	if err := AddItem(NewGenericPassword(service, "token", "", []byte("v1"), "")); err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg testConfig
+	changed := make(chan struct{}, 1)
+	b, err := NewBinder(service, &cfg, 10*time.Millisecond, func() { changed <- struct{}{} })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	b.RLock()
+	if cfg.Token != "v1" {
+		t.Fatalf("expected initial token v1, got %q", cfg.Token)
+	}
+	b.RUnlock()
+
+	if err := upsertGenericPassword(service, "token", "", []byte("v2")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for change")
+	}
+
+	b.RLock()
+	defer b.RUnlock()
+	if cfg.Token != "v2" {
+		t.Fatalf("expected updated token v2, got %q", cfg.Token)
+	}
+}
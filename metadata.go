@@ -0,0 +1,129 @@
+package keychain
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Metadata is a set of string values stored by this package alongside an item,
+// encoded as JSON in the generic attribute. Only generic password items have a
+// generic attribute.
+type Metadata map[string]string
+
+// MetadataChecksumKey is the metadata key holding the hex encoded SHA-256 of
+// the item data.
+const MetadataChecksumKey = "sha256"
+
+// ErrIntegrityMismatch is returned when the data of an item doesn't match the
+// checksum stored in its metadata.
+var ErrIntegrityMismatch = errors.New("item data does not match its checksum")
+
+// parseMetadata decodes metadata from a generic attribute. Values which were
+// not written by this package decode to nil.
+func parseMetadata(b []byte) Metadata {
+	if len(b) == 0 {
+		return nil
+	}
+
+	var m Metadata
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil
+	}
+
+	return m
+}
+
+// Metadata returns the metadata stored with the item, or nil if none is set.
+func (k *Item) Metadata() Metadata {
+	b, _ := k.attr[GenericKey].([]byte)
+
+	return parseMetadata(b)
+}
+
+// SetMetadata stores m in the generic attribute of the item, replacing any
+// existing value. A nil or empty m clears it.
+func (k *Item) SetMetadata(m Metadata) error {
+	if len(m) == 0 {
+		k.SetGeneric(nil)
+
+		return nil
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	k.SetGeneric(b)
+
+	return nil
+}
+
+// setMetadataValue sets a single metadata value, keeping the others.
+func (k *Item) setMetadataValue(key, value string) error {
+	m := k.Metadata()
+	if m == nil {
+		m = Metadata{}
+	}
+
+	m[key] = value
+
+	return k.SetMetadata(m)
+}
+
+// Metadata returns the metadata stored with the item, or nil if none is set or
+// the query didn't return attributes.
+func (r QueryResult) Metadata() Metadata {
+	return parseMetadata(r.Generic)
+}
+
+// SetDataWithChecksum sets the data attribute and records its SHA-256 in the
+// item metadata, so that reads can detect corrupted or partially written data.
+// SetBytes, SetValue, Marshal and Transaction keep the checksum current;
+// other updates of the data must also use SetDataWithChecksum.
+func (k *Item) SetDataWithChecksum(b []byte) error {
+	k.SetData(b)
+
+	return k.setMetadataValue(MetadataChecksumKey, checksum(b))
+}
+
+// checksum returns the hex encoded SHA-256 of data.
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// refreshChecksum replaces the checksum in m, if any, with the checksum of
+// data, and returns whether m has one. Writers replacing the data of items
+// keep their checksums current with it.
+func refreshChecksum(m Metadata, data []byte) bool {
+	if _, ok := m[MetadataChecksumKey]; !ok {
+		return false
+	}
+
+	m[MetadataChecksumKey] = checksum(data)
+
+	return true
+}
+
+// VerifyIntegrity checks the data of the result against the checksum stored
+// with SetDataWithChecksum. It returns nil if there is no checksum or the
+// result has no data, and an error wrapping ErrIntegrityMismatch otherwise.
+// QueryItem verifies every result returning both attributes and data.
+func (r QueryResult) VerifyIntegrity() error {
+	expected, ok := r.Metadata()[MetadataChecksumKey]
+	if !ok || r.Data == nil {
+		return nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(checksum(r.Data)), []byte(expected)) != 1 {
+		return fmt.Errorf("%w: %s", ErrIntegrityMismatch, describeResult(r))
+	}
+
+	return nil
+}
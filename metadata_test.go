@@ -0,0 +1,45 @@
+//go:build darwin && !ios
+// +build darwin,!ios
+
+package keychain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIntegrityChecksum(t *testing.T) {
+	service, account := "TestIntegrityChecksum", "test"
+
+	item := NewGenericPassword(service, account, "", nil, "")
+	if err := item.SetDataWithChecksum([]byte("toomanysecrets")); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = DeleteItem(item) }()
+	if err := AddItem(item); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := GetGenericPassword(service, account, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "toomanysecrets" {
+		t.Fatalf("unexpected data %q", data)
+	}
+
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService(service)
+	query.SetAccount(account)
+	update := NewItem()
+	update.SetData([]byte("corrupted"))
+	if err := UpdateItem(query, update); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = GetGenericPassword(service, account, "", "")
+	if !errors.Is(err, ErrIntegrityMismatch) {
+		t.Fatalf("expected ErrIntegrityMismatch, got %v", err)
+	}
+}
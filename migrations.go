@@ -0,0 +1,250 @@
+package keychain
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// SchemaVersionAccount is the account of the generic password item used to
+// record the applied schema version of a service.
+const SchemaVersionAccount = "go-keychain.schema-version"
+
+// Migration is a single versioned change to the items of a service.
+type Migration struct {
+	// Version is the schema version reached once the migration is applied.
+	Version int
+	// Description is a short human readable summary.
+	Description string
+	// Step plans the changes of the migration. It may be run again if a
+	// previous run failed before the version was recorded, so the changes it
+	// plans should be idempotent.
+	Step Step
+}
+
+// Migrations applies registered migrations in version order, recording
+// progress in a schema version item so that each one runs at most once.
+type Migrations struct {
+	service     string
+	accessGroup string
+	migrations  []Migration
+}
+
+// NewMigrations creates migrations for service, with the schema version
+// stored in a generic password item for (service, SchemaVersionAccount).
+func NewMigrations(service string) *Migrations {
+	return &Migrations{service: service}
+}
+
+// SetAccessGroup sets the access group of the schema version item.
+func (m *Migrations) SetAccessGroup(accessGroup string) {
+	m.accessGroup = accessGroup
+}
+
+// Register adds a migration. Versions must be positive and registered in
+// increasing order.
+func (m *Migrations) Register(version int, description string, step Step) error {
+	if version <= 0 {
+		return fmt.Errorf("invalid migration version %d", version)
+	}
+
+	if n := len(m.migrations); n > 0 && m.migrations[n-1].Version >= version {
+		return fmt.Errorf("migration version %d must be greater than %d", version, m.migrations[n-1].Version)
+	}
+
+	m.migrations = append(m.migrations, Migration{Version: version, Description: description, Step: step})
+
+	return nil
+}
+
+// Version returns the currently recorded schema version, or 0 if no migration
+// has been applied yet.
+func (m *Migrations) Version() (int, error) {
+	data, err := GetGenericPassword(m.service, SchemaVersionAccount, "", m.accessGroup)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	if data == nil {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(string(data))
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema version %q: %w", data, err)
+	}
+
+	return version, nil
+}
+
+// Run applies all migrations newer than the recorded schema version, recording
+// the version after each one. With opts.DryRun the changes are planned and
+// reported without modifying the keychain; note that each migration is then
+// planned against the current state, without the effects of earlier ones.
+// The changes of each migration are applied with opts.Workers; cancelling ctx
+// stops the run before the next change.
+func (m *Migrations) Run(ctx context.Context, opts BulkOptions) (Report, error) {
+	version, err := m.Version()
+	if err != nil {
+		return Report{}, err
+	}
+
+	report := Report{DryRun: opts.DryRun, Version: version}
+
+	for _, migration := range m.migrations {
+		if migration.Version <= report.Version {
+			continue
+		}
+
+		name := fmt.Sprintf("migration %d (%s)", migration.Version, migration.Description)
+
+		changes, err := migration.Step()
+		if err != nil {
+			return report, fmt.Errorf("failed to plan %s: %w", name, err)
+		}
+
+		for i := range changes {
+			changes[i].Step = name
+		}
+
+		report.Changes = append(report.Changes, changes...)
+
+		if !opts.DryRun {
+			if err := applyChanges(ctx, changes, opts); err != nil {
+				return report, fmt.Errorf("%s failed: %w", name, err)
+			}
+
+			if err := m.setVersion(migration.Version); err != nil {
+				return report, err
+			}
+		}
+
+		report.Version = migration.Version
+	}
+
+	return report, nil
+}
+
+func (m *Migrations) setVersion(version int) error {
+	err := upsertGenericPassword(m.service, SchemaVersionAccount, m.accessGroup, []byte(strconv.Itoa(version)))
+	if err != nil {
+		return fmt.Errorf("failed to record schema version %d: %w", version, err)
+	}
+
+	return nil
+}
+
+// RenameMigration returns a migration step that moves the generic password
+// for (fromService, fromAccount) to (toService, toAccount). It plans nothing
+// if the source item no longer exists.
+func RenameMigration(fromService, fromAccount, toService, toAccount string) Step {
+	return func() ([]Change, error) {
+		query := NewItem()
+		query.SetSecClass(SecClassGenericPassword)
+		query.SetService(fromService)
+		query.SetAccount(fromAccount)
+
+		results, err := queryAttributes(query)
+		if err != nil {
+			return nil, err
+		}
+
+		changes := make([]Change, 0, len(results))
+
+		for _, r := range results {
+			update := newItem()
+			update.SetService(toService)
+			update.SetAccount(toAccount)
+			changes = append(changes, updateChange(SecClassGenericPassword, r, update,
+				fmt.Sprintf("rename %s to %q@%q", describeResult(r), toAccount, toService)))
+		}
+
+		return changes, nil
+	}
+}
+
+// AccessibleMigration returns a migration step that changes the accessibility
+// of all items matching query (which must set a class).
+func AccessibleMigration(query Item, accessible Accessible) Step {
+	return func() ([]Change, error) {
+		results, err := queryAttributes(query)
+		if err != nil {
+			return nil, err
+		}
+
+		secClass := query.secClass()
+		changes := make([]Change, 0, len(results))
+
+		for _, r := range results {
+			update := newItem()
+			update.SetAccessible(accessible)
+			changes = append(changes, updateChange(secClass, r, update,
+				fmt.Sprintf("change accessibility of %s", describeResult(r))))
+		}
+
+		return changes, nil
+	}
+}
+
+// ReencryptMigration returns a migration step that rewrites the data of every
+// item matching query (which must set a class) with the output of transform,
+// for example to re-encrypt application-level ciphertext under a new key.
+// Data is only read and transformed when the changes are applied.
+func ReencryptMigration(query Item, transform func(data []byte) ([]byte, error)) Step {
+	return func() ([]Change, error) {
+		results, err := queryAttributes(query)
+		if err != nil {
+			return nil, err
+		}
+
+		secClass := query.secClass()
+		changes := make([]Change, 0, len(results))
+
+		for _, r := range results {
+			changes = append(changes, Change{
+				Kind:        ChangeUpdate,
+				Class:       secClass,
+				Item:        r,
+				Description: "re-encrypt data of " + describeResult(r),
+				apply:       func() error { return reencrypt(secClass, r, transform) },
+			})
+		}
+
+		return changes, nil
+	}
+}
+
+func reencrypt(secClass SecClass, r QueryResult, transform func(data []byte) ([]byte, error)) error {
+	dataQuery := queryForResult(secClass, r)
+	dataQuery.SetMatchLimit(MatchLimitOne)
+	dataQuery.SetReturnData(true)
+
+	results, err := QueryItem(dataQuery)
+	if err != nil {
+		return err
+	}
+
+	if len(results) != 1 {
+		return nil
+	}
+
+	data, err := transform(results[0].Data)
+	if err != nil {
+		return fmt.Errorf("failed to transform data: %w", err)
+	}
+
+	update := newItem()
+	update.SetData(data)
+
+	return UpdateItem(queryForResult(secClass, r), update)
+}
+
+// queryAttributes returns the attributes of all items matching query.
+func queryAttributes(query Item) ([]QueryResult, error) {
+	q := query.clone()
+	q.SetMatchLimit(MatchLimitAll)
+	q.SetReturnAttributes(true)
+	delete(q.attr, ReturnDataKey)
+
+	return QueryItem(q)
+}
@@ -0,0 +1,68 @@
+//go:build darwin && !ios
+// +build darwin,!ios
+
+package keychain
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	service := "TestMigrations"
+	defer func() { _ = DeleteGenericPasswordItem(service, SchemaVersionAccount) }()
+	defer func() { _ = DeleteGenericPasswordItem(service, "renamed") }()
+	defer func() { _ = DeleteGenericPasswordItem(service, "original") }()
+
+	if err := AddItem(NewGenericPassword(service, "original", "", []byte("secret"), "")); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	m := NewMigrations(service)
+	if err := m.Register(1, "rename", RenameMigration(service, "original", service, "renamed")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Register(2, "count", FuncStep("count", func() error { calls++; return nil })); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Register(2, "duplicate", FuncStep("noop", func() error { return nil })); err == nil {
+		t.Fatal("expected error registering a non-increasing version")
+	}
+
+	report, err := m.Run(context.Background(), BulkOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Version != 2 || len(report.Changes) != 2 {
+		t.Fatalf("unexpected dry run report: %+v", report)
+	}
+	if report.Changes[0].Kind != ChangeUpdate || report.Changes[1].Kind != ChangeCustom {
+		t.Fatalf("unexpected planned changes: %+v", report.Changes)
+	}
+	if calls != 0 {
+		t.Fatal("dry run should not apply changes")
+	}
+
+	for i := 0; i < 2; i++ {
+		report, err := m.Run(context.Background(), BulkOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Version != 2 {
+			t.Fatalf("expected version 2, got %d", report.Version)
+		}
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected migration to run once, ran %d times", calls)
+	}
+
+	data, err := GetGenericPassword(service, "renamed", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "secret" {
+		t.Fatalf("expected renamed item data, got %q", data)
+	}
+}
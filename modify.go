@@ -0,0 +1,68 @@
+package keychain
+
+import "fmt"
+
+// ModifyItem reads the attributes and data of the item matching query and
+// updates it with the changes returned by modify, e.g. to rewrite the data of
+// an item protected by biometry. The read and the update share the
+// authentication context of query (see Item.SetAuthenticationContext), or a
+// context created for the call, so the user is prompted once instead of
+// twice. Several matching items are reported as a TooManyResultsError, no
+// item as ErrorItemNotFound, and a backend returning no persistent reference
+// for the item as ErrNoPersistentRef.
+func ModifyItem(query Item, modify func(r QueryResult) (Item, error)) error {
+	q := query.clone()
+	q.SetReturnAttributes(true)
+	q.SetReturnData(true)
+	q.SetReturnPersistentRef(true)
+
+	authCtx, ok := q.attr[UseAuthenticationContextKey].(*AuthenticationContext)
+	if !ok {
+		if ctx, err := NewAuthenticationContext(); err == nil {
+			defer ctx.Close() // nolint: errcheck
+
+			authCtx = ctx
+			q.SetAuthenticationContext(ctx)
+		}
+	}
+
+	results, err := QueryItem(q)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(results) == 0:
+		return ErrorItemNotFound
+	case len(results) > 1:
+		return newTooManyResultsError(results)
+	}
+
+	ref, err := resultRef(results[0])
+	if err != nil {
+		return err
+	}
+
+	update, err := modify(results[0])
+	if err != nil {
+		return err
+	}
+
+	target := newItem()
+	if secClass := query.secClass(); secClass != 0 {
+		target.SetSecClass(secClass)
+	}
+
+	if v, ok := query.attr[UseDataProtectionKeychainKey]; ok {
+		target.attr[UseDataProtectionKeychainKey] = v
+	}
+
+	target.SetPersistentRef(ref)
+	target.SetAuthenticationContext(authCtx)
+
+	if err := UpdateItem(target, update); err != nil {
+		return fmt.Errorf("failed to update item: %w", err)
+	}
+
+	return nil
+}
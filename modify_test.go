@@ -0,0 +1,66 @@
+package keychain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestModifyItem(t *testing.T) {
+	f := useFakeBackend(t, fakeResponse{result: map[string]interface{}{
+		AccountKey:            "gabriel",
+		DataKey:               []byte("1"),
+		ValuePersistentRefKey: []byte("ref"),
+	}})
+
+	authCtx := &AuthenticationContext{}
+
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService("TestModifyItem")
+	query.SetAuthenticationContext(authCtx)
+
+	err := ModifyItem(query, func(r QueryResult) (Item, error) {
+		update := NewItem()
+		update.SetData(append(r.Data, '1'))
+
+		return update, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ops := f.ops(); len(ops) != 2 || ops[1] != "update" {
+		t.Fatalf("unexpected operations %v", ops)
+	}
+
+	for _, c := range f.calls {
+		if c.query[UseAuthenticationContextKey] != authCtx {
+			t.Fatalf("expected the authentication context in %v", c.query)
+		}
+	}
+
+	if string(f.calls[1].attrs[DataKey].([]byte)) != "11" {
+		t.Fatalf("unexpected update %v", f.calls[1].attrs)
+	}
+
+	if string(f.calls[1].query[ValuePersistentRefKey].([]byte)) != "ref" {
+		t.Fatalf("expected the update to target the item read, got %v", f.calls[1].query)
+	}
+
+	useFakeBackend(t, fakeResponse{err: ErrorItemNotFound})
+
+	if err := ModifyItem(query, nil); !errors.Is(err, ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound, got %v", err)
+	}
+
+	// Without a persistent reference, the update would match the whole class.
+	f = useFakeBackend(t, fakeResponse{result: map[string]interface{}{AccountKey: "gabriel", DataKey: []byte("1")}})
+
+	if err := ModifyItem(query, nil); !errors.Is(err, ErrNoPersistentRef) {
+		t.Fatalf("expected ErrNoPersistentRef, got %v", err)
+	}
+
+	if ops := f.ops(); len(ops) != 1 {
+		t.Fatalf("expected no update, got %v", ops)
+	}
+}
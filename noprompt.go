@@ -0,0 +1,82 @@
+package keychain
+
+import "errors"
+
+// QueryItemNoPrompt returns the items matching query like QueryItem, for
+// inventory and audit scans that must never show an authentication dialog.
+// Matching items are enumerated by their attributes, which never need
+// authentication, and if query returns data, the data of each item is read
+// with AuthenticationUISkip: items needing user authentication are silently
+// left out of the results, and their number is returned as skipped.
+func QueryItemNoPrompt(query Item) (results []QueryResult, skipped int, err error) {
+	list := query.clone()
+	list.SetReturnAttributes(true)
+	list.SetReturnPersistentRef(true)
+	delete(list.attr, ReturnDataKey)
+	delete(list.attr, UseOperationPromptKey)
+
+	if _, ok := list.attr[MatchLimitKey]; !ok {
+		list.SetMatchLimit(MatchLimitAll)
+	}
+
+	all, err := QueryItem(list)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if wantData, _ := query.attr[ReturnDataKey].(bool); !wantData {
+		return all, 0, nil
+	}
+
+	secClass := query.secClass()
+	results = make([]QueryResult, 0, len(all))
+
+	for _, r := range all {
+		ref, err := resultRef(r)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		query := refQuery(ref)
+		if secClass != 0 {
+			query.SetSecClass(secClass)
+		}
+
+		query.SetSynchronizable(SynchronizableAny)
+
+		data, ok, err := readDataNoPrompt(query)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if !ok {
+			skipped++
+
+			continue
+		}
+
+		r.Data = data
+		results = append(results, r)
+	}
+
+	return results, skipped, nil
+}
+
+// readDataNoPrompt reads the data of the item matching query, or returns
+// false if it needs user authentication.
+func readDataNoPrompt(query Item) ([]byte, bool, error) {
+	query.SetMatchLimit(MatchLimitOne)
+	query.SetReturnData(true)
+	query.SetAuthenticationUI(AuthenticationUISkip)
+
+	results, err := QueryItem(query)
+	if errors.Is(err, ErrorInteractionNotAllowed) || (err == nil && len(results) != 1) {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return results[0].Data, true, nil
+}
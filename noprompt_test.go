@@ -0,0 +1,50 @@
+package keychain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestQueryItemNoPrompt(t *testing.T) {
+	f := useFakeBackend(t,
+		fakeResponse{result: []interface{}{
+			map[string]interface{}{AccountKey: "open", ValuePersistentRefKey: []byte("ref1")},
+			map[string]interface{}{AccountKey: "protected", ValuePersistentRefKey: []byte("ref2")},
+			map[string]interface{}{AccountKey: "locked", ValuePersistentRefKey: []byte("ref3")},
+		}},
+		fakeResponse{result: []byte("secret")},
+		fakeResponse{err: ErrorItemNotFound},
+		fakeResponse{err: ErrorInteractionNotAllowed},
+	)
+
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService("TestQueryItemNoPrompt")
+	query.SetReturnData(true)
+
+	results, skipped, err := QueryItemNoPrompt(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if skipped != 2 || len(results) != 1 || results[0].Account != "open" || string(results[0].Data) != "secret" {
+		t.Fatalf("unexpected results %+v, %d skipped", results, skipped)
+	}
+
+	if _, ok := f.calls[0].query[ReturnDataKey]; ok {
+		t.Fatal("expected the items to be listed without data")
+	}
+
+	for _, c := range f.calls[1:] {
+		if c.query[UseAuthenticationUIKey] != authenticationUIValues[AuthenticationUISkip] {
+			t.Fatalf("expected data to be read with AuthenticationUISkip, got %v", c.query)
+		}
+	}
+
+	// Reading by an empty persistent reference would match any item.
+	useFakeBackend(t, fakeResponse{result: map[string]interface{}{AccountKey: "open"}})
+
+	if _, _, err := QueryItemNoPrompt(query); !errors.Is(err, ErrNoPersistentRef) {
+		t.Fatalf("expected ErrNoPersistentRef, got %v", err)
+	}
+}
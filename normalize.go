@@ -0,0 +1,129 @@
+package keychain
+
+import (
+	"errors"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// normalizedKeys are the string attributes written in Unicode NFC. Other apps
+// (e.g. Finder) may store them decomposed (NFD), which then doesn't match
+// queries byte for byte, see Item.SetMatchNormalized.
+var normalizedKeys = []string{ServiceKey, AccountKey, LabelKey}
+
+// SetMatchNormalized makes the query match items whose service, account or
+// label are stored in another Unicode normalization form (composed NFC or
+// decomposed NFD) than the query, as written by other apps. Items written by
+// this package are always stored in NFC, and their NFC form is queried even
+// without it.
+func (k *Item) SetMatchNormalized(b bool) {
+	k.matchNormalized = b
+}
+
+// normalizeAttrs converts the normalized attributes of attrs to NFC.
+func normalizeAttrs(attrs map[string]interface{}) {
+	for _, key := range normalizedKeys {
+		if s, ok := attrs[key].(string); ok {
+			attrs[key] = norm.NFC.String(s)
+		}
+	}
+}
+
+// queryVariants returns the encoded query for item, its NFC form where it
+// differs (matching what this package writes) and, if it matches normalized
+// forms, its NFD form where it differs, each also with the other form of a
+// default port (see portVariant).
+func queryVariants(item Item) ([]map[string]interface{}, error) {
+	query, err := EncodeAttributes(item)
+	if err != nil {
+		return nil, err
+	}
+
+	forms := []norm.Form{norm.NFC}
+	if item.matchNormalized {
+		forms = append(forms, norm.NFD)
+	}
+
+	variants := normalizedVariants(query, forms...)
+
+	for _, v := range variants {
+		if pv, ok := portVariant(v); ok {
+			variants = append(variants, pv)
+		}
+	}
+
+	return variants, nil
+}
+
+// normalizedVariants returns query and its forms where they differ.
+func normalizedVariants(query map[string]interface{}, forms ...norm.Form) []map[string]interface{} {
+	variants := []map[string]interface{}{query}
+
+	for _, form := range forms {
+		v := make(map[string]interface{}, len(query))
+		for key, value := range query {
+			v[key] = value
+		}
+
+		changed := false
+
+		for _, key := range normalizedKeys {
+			if s, ok := query[key].(string); ok && !form.IsNormalString(s) {
+				v[key] = form.String(s)
+				changed = true
+			}
+		}
+
+		if changed && !sameVariant(variants, v) {
+			variants = append(variants, v)
+		}
+	}
+
+	return variants
+}
+
+// sameVariant returns whether variants contain v.
+func sameVariant(variants []map[string]interface{}, v map[string]interface{}) bool {
+next:
+	for _, other := range variants {
+		for _, key := range normalizedKeys {
+			if other[key] != v[key] {
+				continue next
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// eachVariant calls fn for the query variants of item, and returns
+// ErrorItemNotFound only if no variant matched.
+func eachVariant(item Item, fn func(query map[string]interface{}) error) error {
+	variants, err := queryVariants(item)
+	if err != nil {
+		return err
+	}
+
+	found := false
+
+	for _, query := range variants {
+		err := fn(query)
+		if errors.Is(err, ErrorItemNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		found = true
+	}
+
+	if !found {
+		return ErrorItemNotFound
+	}
+
+	return nil
+}
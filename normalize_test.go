@@ -0,0 +1,73 @@
+package keychain
+
+import "testing"
+
+func TestUnicodeNormalization(t *testing.T) {
+	const (
+		nfc = "Zo\u00eb"
+		nfd = "Zoe\u0308"
+	)
+
+	f := useFakeBackend(t,
+		fakeResponse{},
+		fakeResponse{err: ErrorItemNotFound},
+		fakeResponse{result: map[string]interface{}{AccountKey: nfd}},
+	)
+
+	if err := AddItem(NewGenericPassword("MyService", nfd, "", []byte("a"), "")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := f.calls[0].attrs[AccountKey]; got != nfc {
+		t.Fatalf("expected account to be written in NFC, got %+q", got)
+	}
+
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetAccount(nfc)
+	query.SetReturnAttributes(true)
+	query.SetMatchNormalized(true)
+
+	results, err := QueryItem(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.calls) != 3 || f.calls[2].query[AccountKey] != nfd {
+		t.Fatalf("expected the NFD form to be queried after the NFC form, got %+v", f.calls)
+	}
+
+	if len(results) != 1 || results[0].Account != nfd || results[0].NormalizedAccount != nfc {
+		t.Fatalf("unexpected results %+v", results)
+	}
+}
+
+func TestUnicodeNormalizationWithoutMatching(t *testing.T) {
+	const (
+		nfc = "Zo\u00eb"
+		nfd = "Zoe\u0308"
+	)
+
+	f := useFakeBackend(t,
+		fakeResponse{err: ErrorItemNotFound},
+		fakeResponse{result: map[string]interface{}{AccountKey: nfc}},
+	)
+
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetAccount(nfd)
+	query.SetReturnAttributes(true)
+
+	results, err := QueryItem(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.calls) != 2 || f.calls[0].query[AccountKey] != nfd || f.calls[1].query[AccountKey] != nfc {
+		t.Fatalf("expected the NFC form written by AddItem to be queried, got %+v", f.calls)
+	}
+
+	if len(results) != 1 || results[0].Account != nfc {
+		t.Fatalf("unexpected results %+v", results)
+	}
+}
@@ -0,0 +1,58 @@
+//go:build darwin
+// +build darwin
+
+package keychain
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+var (
+	osVersionOnce    sync.Once
+	osMajor, osMinor int
+)
+
+// osVersion returns the major and minor version of macOS or iOS, or 0, 0 if
+// it can't be determined.
+func osVersion() (int, int) {
+	osVersionOnce.Do(func() {
+		v, err := syscall.Sysctl("kern.osproductversion")
+		if err != nil {
+			return
+		}
+
+		parts := strings.SplitN(v, ".", 3)
+		osMajor, _ = strconv.Atoi(parts[0])
+
+		if len(parts) > 1 {
+			osMinor, _ = strconv.Atoi(parts[1])
+		}
+	})
+
+	return osMajor, osMinor
+}
+
+// alwaysDeprecated returns whether AccessibleAlways is deprecated on this OS
+// or by backend b. Unknown versions are assumed to be recent.
+func alwaysDeprecated(b Backend) bool {
+	if _, ok := b.(securityBackend); !ok {
+		return true
+	}
+
+	major, minor := osVersion()
+
+	switch {
+	case major == 0:
+		return true
+	case runtime.GOOS == "ios":
+		return major >= 12
+	case major == 10:
+		return minor >= 14
+	}
+
+	return major > 10
+}
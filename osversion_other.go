@@ -0,0 +1,10 @@
+//go:build !darwin
+// +build !darwin
+
+package keychain
+
+// alwaysDeprecated returns whether AccessibleAlways is deprecated. Backends
+// other than the Security framework follow its current behavior.
+func alwaysDeprecated(Backend) bool {
+	return true
+}
@@ -0,0 +1,85 @@
+package keychain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNoPersistentRef is returned when an item found by a query must be read or
+// changed by persistent reference, and the backend returned none, e.g.
+// because it doesn't support them (see Capability.PersistentRefs). Matching an
+// empty reference would match every item of the class instead.
+var ErrNoPersistentRef = errors.New("no persistent reference returned for the item")
+
+// resultRef returns the persistent reference of r, or ErrNoPersistentRef.
+func resultRef(r QueryResult) ([]byte, error) {
+	if len(r.PersistentRef) == 0 {
+		return nil, fmt.Errorf("%w %s", ErrNoPersistentRef, describeResult(r))
+	}
+
+	return r.PersistentRef, nil
+}
+
+// refQuery returns a query for the item identified by a persistent reference.
+func refQuery(ref []byte) Item {
+	query := newItem()
+	query.SetPersistentRef(ref)
+
+	return query
+}
+
+// UpdateItemRef updates the item identified by a persistent reference (see
+// QueryResult.PersistentRef), without matching attributes, so that exactly
+// that item is updated when several items share a service and account (e.g.
+// in different access groups or keychains). Such changes aren't counted by
+// change sequences.
+func UpdateItemRef(ref []byte, update Item) error {
+	if len(ref) == 0 {
+		return errors.New("empty persistent reference")
+	}
+
+	return UpdateItem(refQuery(ref), update)
+}
+
+// DeleteItemRef deletes the item identified by a persistent reference, see
+// UpdateItemRef.
+func DeleteItemRef(ref []byte) error {
+	if len(ref) == 0 {
+		return errors.New("empty persistent reference")
+	}
+
+	return DeleteItem(refQuery(ref))
+}
+
+// BatchUpdate updates the items identified by persistent references with
+// update. All items are attempted; the error joins the failures, each naming
+// the index of its reference.
+func BatchUpdate(refs [][]byte, update Item) error {
+	return batch(refs, func(ref []byte) error { return UpdateItemRef(ref, update) })
+}
+
+// BatchDelete deletes the items identified by persistent references, e.g. the
+// results of a previous query. Items already deleted are skipped. All items
+// are attempted; the error joins the failures, each naming the index of its
+// reference.
+func BatchDelete(refs [][]byte) error {
+	return batch(refs, func(ref []byte) error {
+		if err := DeleteItemRef(ref); !errors.Is(err, ErrorItemNotFound) {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func batch(refs [][]byte, fn func(ref []byte) error) error {
+	var errs []error
+
+	for i, ref := range refs {
+		if err := fn(ref); err != nil {
+			errs = append(errs, fmt.Errorf("item %d: %w", i, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
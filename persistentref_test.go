@@ -0,0 +1,114 @@
+package keychain_test
+
+import (
+	"errors"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+// addTwins adds the same service and account in two access groups and
+// returns their persistent references.
+func addTwins(t *testing.T) [][]byte {
+	t.Helper()
+
+	var refs [][]byte
+
+	for _, group := range []string{"A123456789.one", "A123456789.two"} {
+		item := keychain.NewGenericPassword("MyService", "gabriel", "", []byte(group), group)
+
+		ref, err := keychain.AddItemReturningPersistentRef(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		refs = append(refs, ref)
+	}
+
+	return refs
+}
+
+func queryTwins(t *testing.T, refs ...[]byte) []keychain.QueryResult {
+	t.Helper()
+
+	query := keychain.NewItem()
+	query.SetSecClass(keychain.SecClassGenericPassword)
+	query.SetService("MyService")
+	query.SetMatchItemList(refs...)
+	query.SetMatchLimit(keychain.MatchLimitAll)
+	query.SetReturnAttributes(true)
+
+	results, err := keychain.QueryItem(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return results
+}
+
+func TestItemRefs(t *testing.T) {
+	keychainfake.Install(t)
+
+	refs := addTwins(t)
+
+	if results := queryTwins(t, refs[1]); len(results) != 1 || results[0].AccessGroup != "A123456789.two" {
+		t.Fatalf("expected the item list to select the second item, got %v", results)
+	}
+
+	update := keychain.NewItem()
+	update.SetLabel("Second")
+
+	if err := keychain.UpdateItemRef(refs[1], update); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range queryTwins(t) {
+		if (r.Label == "Second") != (r.AccessGroup == "A123456789.two") {
+			t.Fatalf("expected only the second item to be updated, got %+v", r)
+		}
+	}
+
+	if err := keychain.DeleteItemRef(refs[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if results := queryTwins(t); len(results) != 1 || results[0].AccessGroup != "A123456789.two" {
+		t.Fatalf("expected only the first item to be deleted, got %v", results)
+	}
+
+	if err := keychain.DeleteItemRef(refs[0]); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestBatchRefs(t *testing.T) {
+	keychainfake.Install(t)
+
+	refs := addTwins(t)
+
+	update := keychain.NewItem()
+	update.SetComment("batch")
+
+	if err := keychain.BatchUpdate(refs, update); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range queryTwins(t) {
+		if r.Comment != "batch" {
+			t.Fatalf("expected both items to be updated, got %+v", r)
+		}
+	}
+
+	if err := keychain.BatchDelete(append(refs, refs[0])); err != nil {
+		t.Fatal(err)
+	}
+
+	if results := queryTwins(t); len(results) != 0 {
+		t.Fatalf("expected all items to be deleted, got %v", results)
+	}
+
+	if err := keychain.BatchUpdate([][]byte{refs[0]}, update); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
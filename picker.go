@@ -0,0 +1,93 @@
+package keychain
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// AccountInfo describes an account offered by PickAccount.
+type AccountInfo struct {
+	Account  string
+	Label    string
+	Modified time.Time
+}
+
+// PickAccount lets the user choose one of the accounts of the generic
+// passwords for service, e.g. in a command line login flow, and returns the
+// data of the chosen item. prompt is called with the accounts, most recently
+// modified first, and returns the index of the chosen one; its error is
+// returned as is. An account stored several times, e.g. in different access
+// groups, is offered once, for its most recently modified item. If there is
+// no account, ErrorItemNotFound is returned without calling prompt.
+func PickAccount(service string, prompt func(accounts []AccountInfo) (int, error)) ([]byte, error) {
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService(service)
+	query.SetReturnPersistentRef(true)
+
+	results, err := queryAttributes(query)
+	if err != nil {
+		return nil, err
+	}
+
+	newest := make(map[string]QueryResult, len(results))
+	for _, r := range results {
+		if prev, ok := newest[r.Account]; !ok || r.ModificationDate.After(prev.ModificationDate) {
+			newest[r.Account] = r
+		}
+	}
+
+	if len(newest) == 0 {
+		return nil, ErrorItemNotFound
+	}
+
+	candidates := make([]QueryResult, 0, len(newest))
+	for _, r := range newest {
+		candidates = append(candidates, r)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if !candidates[i].ModificationDate.Equal(candidates[j].ModificationDate) {
+			return candidates[i].ModificationDate.After(candidates[j].ModificationDate)
+		}
+
+		return candidates[i].Account < candidates[j].Account
+	})
+
+	accounts := make([]AccountInfo, len(candidates))
+	for i, r := range candidates {
+		accounts[i] = AccountInfo{Account: r.Account, Label: r.Label, Modified: r.ModificationDate}
+	}
+
+	i, err := prompt(accounts)
+	if err != nil {
+		return nil, err
+	}
+
+	if i < 0 || i >= len(candidates) {
+		return nil, fmt.Errorf("invalid account index %d of %d", i, len(candidates))
+	}
+
+	ref, err := resultRef(candidates[i])
+	if err != nil {
+		return nil, err
+	}
+
+	dataQuery := newItem()
+	dataQuery.SetSecClass(SecClassGenericPassword)
+	dataQuery.SetPersistentRef(ref)
+	dataQuery.SetMatchLimit(MatchLimitOne)
+	dataQuery.SetReturnData(true)
+
+	data, err := QueryItem(dataQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read account %q: %w", candidates[i].Account, err)
+	}
+
+	if len(data) != 1 {
+		return nil, ErrorItemNotFound
+	}
+
+	return data[0].Data, nil
+}
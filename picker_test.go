@@ -0,0 +1,69 @@
+package keychain_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func TestPickAccount(t *testing.T) {
+	keychainfake.Install(t)
+
+	add := func(account, accessGroup, data string) {
+		t.Helper()
+
+		item := keychain.NewGenericPassword("com.example.cli", account, account+" login", []byte(data), accessGroup)
+		if err := keychain.AddItem(item); err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	add("alice", "group-a", "old")
+	add("bob", "", "bob-secret")
+	add("alice", "group-b", "new")
+
+	var offered []keychain.AccountInfo
+
+	data, err := keychain.PickAccount("com.example.cli", func(accounts []keychain.AccountInfo) (int, error) {
+		offered = accounts
+
+		return 0, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(offered) != 2 || offered[0].Account != "alice" || offered[1].Account != "bob" || offered[1].Label != "bob login" {
+		t.Fatalf("unexpected accounts %+v", offered)
+	}
+
+	if string(data) != "new" {
+		t.Fatalf("expected the newest alice item, got %q", data)
+	}
+
+	errCancelled := errors.New("cancelled")
+	if _, err := keychain.PickAccount("com.example.cli", func([]keychain.AccountInfo) (int, error) {
+		return 0, errCancelled
+	}); !errors.Is(err, errCancelled) {
+		t.Fatalf("expected the prompt error, got %v", err)
+	}
+
+	if _, err := keychain.PickAccount("com.example.cli", func([]keychain.AccountInfo) (int, error) {
+		return 2, nil
+	}); err == nil {
+		t.Fatal("expected an error for an invalid index")
+	}
+
+	if _, err := keychain.PickAccount("com.example.none", func([]keychain.AccountInfo) (int, error) {
+		t.Fatal("unexpected prompt")
+
+		return 0, nil
+	}); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,315 @@
+package keychain
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+)
+
+// MetadataPipelineKey is the metadata key holding the descriptor of the
+// Pipeline the data of an item was written with, see Config.Pipeline.
+const MetadataPipelineKey = "pipeline"
+
+// pipelineSeparator separates the IDs of the transforms of a pipeline in its
+// descriptor.
+const pipelineSeparator = "|"
+
+// ErrUnknownTransform is returned when reading data written with a transform
+// that isn't registered, see RegisterTransform.
+var ErrUnknownTransform = errors.New("unknown transform")
+
+// Transform is a reversible step of a Pipeline, e.g. compressing, encrypting
+// or encoding the data of items.
+type Transform interface {
+	// ID identifies the transform and its configuration in the descriptors of
+	// pipelines, e.g. "gzip" or "aes-2024". It mustn't contain "|".
+	ID() string
+	// Apply transforms data when it is written.
+	Apply(data []byte) ([]byte, error)
+	// Reverse undoes Apply when the data is read.
+	Reverse(data []byte) ([]byte, error)
+}
+
+type gzipTransform struct{}
+
+func (gzipTransform) ID() string                          { return "gzip" }
+func (gzipTransform) Apply(data []byte) ([]byte, error)   { return compress(data) }
+func (gzipTransform) Reverse(data []byte) ([]byte, error) { return decompress(data) }
+
+type base64Transform struct{}
+
+func (base64Transform) ID() string { return "base64" }
+
+func (base64Transform) Apply(data []byte) ([]byte, error) {
+	return []byte(base64.StdEncoding.EncodeToString(data)), nil
+}
+
+func (base64Transform) Reverse(data []byte) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(string(data))
+}
+
+// Built-in transforms, which are always registered.
+var (
+	// GzipTransform compresses data with gzip.
+	GzipTransform Transform = gzipTransform{}
+	// Base64Transform encodes data in standard base64, e.g. for backends
+	// only storing text.
+	Base64Transform Transform = base64Transform{}
+)
+
+type aesTransform struct {
+	c aesCodec
+}
+
+// EncryptTransform returns a transform encrypting data with AES-GCM and key
+// (16, 24 or 32 bytes), with the ID "aes-" followed by name. Use distinct
+// names for distinct keys, so items written with a previous key are still
+// read once that transform is registered.
+func EncryptTransform(name string, key []byte) (Transform, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return aesTransform{aesCodec{name: name, aead: aead}}, nil
+}
+
+func (t aesTransform) ID() string {
+	return "aes-" + t.c.name
+}
+
+func (t aesTransform) Apply(data []byte) ([]byte, error) {
+	return t.c.seal(data, []byte(t.ID()))
+}
+
+func (t aesTransform) Reverse(data []byte) ([]byte, error) {
+	return t.c.open(data, []byte(t.ID()))
+}
+
+var transforms = struct {
+	sync.RWMutex
+	byID map[string]Transform
+}{byID: map[string]Transform{GzipTransform.ID(): GzipTransform, Base64Transform.ID(): Base64Transform}}
+
+// RegisterTransform makes t available to read data written with a pipeline
+// including it, replacing a transform with the same ID. Transforms with a
+// configuration, like those of EncryptTransform, must be registered as
+// configured, e.g. with their key.
+func RegisterTransform(t Transform) {
+	transforms.Lock()
+	defer transforms.Unlock()
+
+	transforms.byID[t.ID()] = t
+}
+
+func lookupTransform(id string) (Transform, bool) {
+	transforms.RLock()
+	defer transforms.RUnlock()
+
+	t, ok := transforms.byID[id]
+
+	return t, ok
+}
+
+// Pipeline is a list of transforms applied in order to the data of items when
+// it is written, and reversed when it is read, e.g. compressing, encrypting
+// and encoding it:
+//
+//	encrypt, err := keychain.EncryptTransform("2024", key)
+//	p := keychain.Pipeline{keychain.GzipTransform, encrypt, keychain.Base64Transform}
+//
+// The descriptor of the pipeline, its ID, is stored in the metadata of the
+// items, so items written with a previous pipeline are still read after the
+// pipeline changes, as long as its transforms are registered.
+type Pipeline []Transform
+
+// ID returns the descriptor of the pipeline, the IDs of its transforms
+// separated by "|", e.g. "gzip|aes-2024|base64".
+func (p Pipeline) ID() string {
+	ids := make([]string, len(p))
+	for i, t := range p {
+		ids[i] = t.ID()
+	}
+
+	return strings.Join(ids, pipelineSeparator)
+}
+
+// Apply applies the transforms of the pipeline to data, in order.
+func (p Pipeline) Apply(data []byte) ([]byte, error) {
+	for _, t := range p {
+		var err error
+		if data, err = t.Apply(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", t.ID(), err)
+		}
+	}
+
+	return data, nil
+}
+
+// Reverse reverses the transforms of the pipeline on data, in reverse order.
+func (p Pipeline) Reverse(data []byte) ([]byte, error) {
+	for i := len(p) - 1; i >= 0; i-- {
+		var err error
+		if data, err = p[i].Reverse(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", p[i].ID(), err)
+		}
+	}
+
+	return data, nil
+}
+
+// SetPipeline sets the pipeline transforming the data written with SetBytes
+// and the typed setters built on it (SetString, SetJSON), and returns the
+// previous one. The descriptor of the pipeline is recorded in the metadata of
+// the items, and GetBytes and the typed getters reverse the pipeline an item
+// was written with, made of the transforms of the configured pipeline or of
+// registered ones, see RegisterTransform. An empty pipeline, the default,
+// stores data as is, and removes the descriptor of the items it writes.
+func SetPipeline(p Pipeline) Pipeline {
+	return updateConfig(func(cfg *Config) { cfg.Pipeline = p }).Pipeline
+}
+
+// pipeline returns the pipeline described by id, with the transforms of the
+// configured pipeline or registered.
+func (s *state) pipeline(id string) (Pipeline, error) {
+	if id == "" {
+		return nil, nil
+	}
+
+	var p Pipeline
+
+	for _, tid := range strings.Split(id, pipelineSeparator) {
+		t, ok := lookupTransform(tid)
+
+		for _, configured := range s.cfg.Pipeline {
+			if configured.ID() == tid {
+				t, ok = configured, true
+			}
+		}
+
+		if !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownTransform, tid)
+		}
+
+		p = append(p, t)
+	}
+
+	return p, nil
+}
+
+// reverseResult returns the data of r, reversing the pipeline it was written
+// with.
+func (s *state) reverseResult(r QueryResult) ([]byte, error) {
+	id, ok := r.Metadata()[MetadataPipelineKey]
+	if !ok {
+		return r.Data, nil
+	}
+
+	p, err := s.pipeline(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %q: %w", r.Account, err)
+	}
+
+	data, err := p.Reverse(r.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %q: %w", r.Account, err)
+	}
+
+	return data, nil
+}
+
+// setBytes stores data in the generic password for service and account,
+// transformed by the configured pipeline, if any. The descriptor recorded in
+// the metadata of the item is replaced, or removed without a pipeline, so the
+// data is read as it was written.
+func (s *state) setBytes(service, account string, data []byte) error {
+	return s.setData(service, account, data, nil)
+}
+
+// setData is setBytes also recording the values of set in the metadata of
+// the item. Other metadata of the item is kept.
+func (s *state) setData(service, account string, data []byte, set Metadata) error {
+	s, end, err := s.begin()
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	if data == nil {
+		data = []byte{}
+	}
+
+	if len(s.cfg.Pipeline) > 0 {
+		var err error
+		if data, err = s.cfg.Pipeline.Apply(data); err != nil {
+			return fmt.Errorf("failed to encode %q: %w", account, err)
+		}
+	}
+
+	query := s.newItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService(service)
+	query.SetAccount(account)
+	query.SetMatchLimit(MatchLimitOne)
+	query.SetReturnAttributes(true)
+
+	results, err := s.queryItem(query)
+	if err != nil {
+		return err
+	}
+
+	var recorded Metadata
+	if len(results) > 0 {
+		recorded = results[0].Metadata()
+	}
+
+	m := maps.Clone(recorded)
+	if m == nil {
+		m = Metadata{}
+	}
+
+	maps.Copy(m, set)
+	refreshChecksum(m, data)
+
+	if id := s.cfg.Pipeline.ID(); id != "" {
+		m[MetadataPipelineKey] = id
+	} else {
+		delete(m, MetadataPipelineKey)
+	}
+
+	if len(results) == 0 {
+		item := s.newGenericPassword(service, account, "", data, "")
+		if err := item.SetMetadata(m); err != nil {
+			return err
+		}
+
+		return s.addItem(item)
+	}
+
+	update := newItem()
+	update.SetData(data)
+
+	switch {
+	case maps.Equal(m, recorded):
+		// The metadata is current.
+	case len(m) == 0:
+		// An empty generic attribute clears the metadata in an update.
+		update.SetGeneric([]byte{})
+	default:
+		if err := update.SetMetadata(m); err != nil {
+			return err
+		}
+	}
+
+	return s.updateItem(queryForResult(SecClassGenericPassword, results[0]), update)
+}
@@ -0,0 +1,252 @@
+package keychain_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func TestPipeline(t *testing.T) {
+	keychainfake.Install(t)
+
+	if err := keychain.SetString("com.example.app", "plain", "legacy"); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := keychain.EncryptTransform("first", bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := keychain.Pipeline{keychain.GzipTransform, first, keychain.Base64Transform}
+	if p.ID() != "gzip|aes-first|base64" {
+		t.Fatalf("unexpected descriptor %q", p.ID())
+	}
+
+	query := genericPasswordQuery("com.example.app", "config")
+	query.SetReturnAttributes(true)
+	query.SetReturnData(true)
+
+	prev := keychain.SetPipeline(p)
+	defer keychain.SetPipeline(prev)
+
+	if err := keychain.SetJSON("com.example.app", "config", map[string]int{"retries": 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := keychain.QueryItem(query)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("expected the item, got %d (%v)", len(results), err)
+	}
+
+	if bytes.Contains(results[0].Data, []byte("retries")) || results[0].Metadata()[keychain.MetadataPipelineKey] != p.ID() {
+		t.Fatalf("expected transformed data with its descriptor, got %q %v", results[0].Data, results[0].Metadata())
+	}
+
+	// Change the pipeline: items written before are still read.
+	second, err := keychain.EncryptTransform("second", bytes.Repeat([]byte{2}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keychain.SetPipeline(keychain.Pipeline{second})
+
+	if _, err := keychain.GetJSON[map[string]int]("com.example.app", "config"); !errors.Is(err, keychain.ErrUnknownTransform) {
+		t.Fatalf("expected ErrUnknownTransform, got %v", err)
+	}
+
+	keychain.RegisterTransform(first)
+
+	v, err := keychain.GetJSON[map[string]int]("com.example.app", "config")
+	if err != nil || v["retries"] != 3 {
+		t.Fatalf("expected the value written with the previous pipeline, got %v (%v)", v, err)
+	}
+
+	if err := keychain.SetJSON("com.example.app", "config", v); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err = keychain.QueryItem(query)
+	if err != nil || results[0].Metadata()[keychain.MetadataPipelineKey] != "aes-second" {
+		t.Fatalf("expected the new descriptor, got %v (%v)", results[0].Metadata(), err)
+	}
+
+	// Items written without a pipeline are read as is.
+	if s, err := keychain.GetString("com.example.app", "plain"); err != nil || s != "legacy" {
+		t.Fatalf("expected the plain item, got %q (%v)", s, err)
+	}
+}
+
+func TestPipelineOff(t *testing.T) {
+	keychainfake.Install(t)
+
+	prev := keychain.SetPipeline(keychain.Pipeline{keychain.GzipTransform, keychain.Base64Transform})
+	defer keychain.SetPipeline(prev)
+
+	if err := keychain.SetBytes("com.example.app", "acct", []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a pipeline, the item is replaced as is, without a descriptor.
+	keychain.SetPipeline(nil)
+
+	if err := keychain.SetBytes("com.example.app", "acct", []byte("second")); err != nil {
+		t.Fatal(err)
+	}
+
+	if b, err := keychain.GetBytes("com.example.app", "acct"); err != nil || string(b) != "second" {
+		t.Fatalf("expected the value written without a pipeline, got %q (%v)", b, err)
+	}
+
+	query := genericPasswordQuery("com.example.app", "acct")
+	query.SetReturnAttributes(true)
+	query.SetReturnData(true)
+
+	results, err := keychain.QueryItem(query)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("expected the item, got %d (%v)", len(results), err)
+	}
+
+	if _, ok := results[0].Metadata()[keychain.MetadataPipelineKey]; ok || string(results[0].Data) != "second" {
+		t.Fatalf("expected plain data without a descriptor, got %q %v", results[0].Data, results[0].Metadata())
+	}
+}
+
+func TestPipelineValue(t *testing.T) {
+	keychainfake.Install(t)
+
+	prev := keychain.SetPipeline(keychain.Pipeline{keychain.GzipTransform, keychain.Base64Transform})
+	defer keychain.SetPipeline(prev)
+
+	if err := keychain.SetValue("com.example.app", "acct", []string{"a", "b"}, keychain.JSONCodec); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := keychain.GetValue[[]string]("com.example.app", "acct"); err != nil || len(v) != 2 || v[1] != "b" {
+		t.Fatalf("expected the value through the pipeline, got %q (%v)", v, err)
+	}
+
+	// Replacing the value without a pipeline drops the stale descriptor.
+	keychain.SetPipeline(nil)
+
+	if err := keychain.SetValue("com.example.app", "acct", []string{"c"}, keychain.JSONCodec); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := keychain.GetValue[[]string]("com.example.app", "acct"); err != nil || len(v) != 1 || v[0] != "c" {
+		t.Fatalf("expected the value written without a pipeline, got %q (%v)", v, err)
+	}
+
+	query := genericPasswordQuery("com.example.app", "acct")
+	query.SetReturnAttributes(true)
+
+	results, err := keychain.QueryItem(query)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("expected the item, got %d (%v)", len(results), err)
+	}
+
+	if m := results[0].Metadata(); m[keychain.MetadataPipelineKey] != "" || m[keychain.MetadataCodecKey] != keychain.JSONCodec.ID() {
+		t.Fatalf("expected the codec without a descriptor, got %v", m)
+	}
+}
+
+func TestChecksumKeptCurrent(t *testing.T) {
+	keychainfake.Install(t)
+
+	item := keychain.NewGenericPassword("com.example.app", "acct", "", nil, "")
+	if err := item.SetDataWithChecksum([]byte("first")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.AddItem(item); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.SetString("com.example.app", "acct", "second"); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err := keychain.GetString("com.example.app", "acct"); err != nil || s != "second" {
+		t.Fatalf("expected the replaced data to verify, got %q (%v)", s, err)
+	}
+
+	if err := keychain.SetValue("com.example.app", "acct", 3, keychain.JSONCodec); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := keychain.GetValue[int]("com.example.app", "acct"); err != nil || v != 3 {
+		t.Fatalf("expected the replaced value to verify, got %d (%v)", v, err)
+	}
+
+	tx := keychain.NewTransaction("com.example.app")
+	tx.Put("acct", []byte("fourth"))
+
+	if err := tx.Apply(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err := keychain.GetString("com.example.app", "acct"); err != nil || s != "fourth" {
+		t.Fatalf("expected the transaction data to verify, got %q (%v)", s, err)
+	}
+}
+
+func TestPipelineBinder(t *testing.T) {
+	keychainfake.Install(t)
+
+	prev := keychain.SetPipeline(keychain.Pipeline{keychain.Base64Transform})
+	defer keychain.SetPipeline(prev)
+
+	interval := keychain.SetWatchPollInterval(10 * time.Millisecond)
+	defer keychain.SetWatchPollInterval(interval)
+
+	type config struct {
+		Token string `keychain:"token"`
+	}
+
+	if err := keychain.Marshal("com.example.app", &config{Token: "v1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if raw, err := keychain.GetGenericPassword("com.example.app", "token", "", ""); err != nil || string(raw) == "v1" {
+		t.Fatalf("expected the transformed data, got %q (%v)", raw, err)
+	}
+
+	var cfg config
+
+	changed := make(chan struct{}, 1)
+
+	b, err := keychain.NewBinder("com.example.app", &cfg, time.Millisecond, func() { changed <- struct{}{} })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	b.RLock()
+	token := cfg.Token
+	b.RUnlock()
+
+	if token != "v1" {
+		t.Fatalf("expected the initial token v1, got %q", token)
+	}
+
+	if err := keychain.SetString("com.example.app", "token", "v2"); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the change")
+	}
+
+	b.RLock()
+	defer b.RUnlock()
+
+	if cfg.Token != "v2" {
+		t.Fatalf("expected the updated token v2, got %q", cfg.Token)
+	}
+}
@@ -0,0 +1,53 @@
+package keychain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrForbidden is returned for items using options that are forbidden in
+// builds with the keychain_strict tag:
+//
+//	go build -tags keychain_strict
+//
+// Strict builds reject the AccessibleAlways and
+// AccessibleAccessibleAlwaysThisDeviceOnly accessibilities, which are
+// deprecated and leave items readable while the device is locked, and always
+// target the data protection keychain on macOS instead of the legacy file
+// keychain (see Item.SetUseDataProtectionKeychain), so a security review can
+// rely on the build rather than on every call site.
+var ErrForbidden = errors.New("forbidden by keychain_strict build")
+
+// Strict returns whether the package was built with the keychain_strict tag.
+func Strict() bool {
+	return strict
+}
+
+// forbiddenAccessible are the accessibilities rejected by strict builds.
+var forbiddenAccessible = map[Accessible]bool{
+	AccessibleAlways:                         true,
+	AccessibleAccessibleAlwaysThisDeviceOnly: true,
+}
+
+// checkPolicy enforces the strict build policy on encoded attributes.
+func checkPolicy(item Item, attrs map[string]interface{}) error {
+	if !strict {
+		return nil
+	}
+
+	if a, ok := item.attr[AccessibleKey].(Accessible); ok && forbiddenAccessible[a] {
+		return fmt.Errorf("accessibility %q: %w", accessibleValues[a], ErrForbidden)
+	}
+
+	if ac, ok := item.attr[AccessControlKey].(AccessControl); ok && forbiddenAccessible[ac.Accessible] {
+		return fmt.Errorf("access control accessibility %q: %w", accessibleValues[ac.Accessible], ErrForbidden)
+	}
+
+	// Items and queries (which have a class, unlike the attributes of an
+	// update) never target the legacy keychain.
+	if _, ok := attrs[SecClassKey]; ok {
+		attrs[UseDataProtectionKeychainKey] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package keychain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStrictPolicy(t *testing.T) {
+	item := NewGenericPassword("MyService", "gabriel", "", []byte("toomanysecrets"), "")
+	item.SetAccessible(AccessibleAlways)
+
+	_, err := EncodeAttributes(item)
+	if Strict() != errors.Is(err, ErrForbidden) {
+		t.Fatalf("unexpected error in strict=%v build: %v", Strict(), err)
+	}
+
+	item.SetAccessible(AccessibleWhenUnlocked)
+
+	attrs, err := EncodeAttributes(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := attrs[UseDataProtectionKeychainKey]; ok != Strict() {
+		t.Fatalf("unexpected keychain in strict=%v build: %v", Strict(), attrs)
+	}
+
+	update := newItem()
+	update.SetAccessible(AccessibleAfterFirstUnlock)
+
+	if attrs, _ := EncodeAttributes(update); len(attrs) != 1 {
+		t.Fatalf("unexpected update attributes: %v", attrs)
+	}
+}
@@ -0,0 +1,74 @@
+package keychain
+
+// defaultPorts are the default ports of protocol attributes.
+var defaultPorts = map[string]int32{
+	"afp ": 548,
+	"ftp ": 21,
+	"ftps": 990,
+	"http": 80,
+	"htps": 443,
+	"imap": 143,
+	"imps": 993,
+	"ldap": 389,
+	"ldps": 636,
+	"pop3": 110,
+	"pops": 995,
+	"smb ": 445,
+	"smtp": 25,
+	"ssh ": 22,
+	"teln": 23,
+}
+
+// inferPort sets the port of an internet password without one to the default
+// port of its protocol, so that it matches queries with an explicit port.
+func inferPort(attrs map[string]interface{}) {
+	if attrs[SecClassKey] != secClassValues[SecClassInternetPassword] {
+		return
+	}
+
+	if n, _ := int64Value(attrs[PortKey]); n != 0 {
+		return
+	}
+
+	protocol, _ := attrs[ProtocolKey].(string)
+	if port, ok := defaultPorts[protocol]; ok {
+		attrs[PortKey] = port
+	}
+}
+
+// portVariant returns query for internet passwords with the default port of
+// its protocol swapped with no port (0), which items added without a port,
+// e.g. by other apps, have.
+func portVariant(query map[string]interface{}) (map[string]interface{}, bool) {
+	if query[SecClassKey] != secClassValues[SecClassInternetPassword] {
+		return nil, false
+	}
+
+	n, ok := int64Value(query[PortKey])
+	if !ok {
+		return nil, false
+	}
+
+	protocol, _ := query[ProtocolKey].(string)
+
+	port, ok := defaultPorts[protocol]
+	if !ok {
+		return nil, false
+	}
+
+	v := make(map[string]interface{}, len(query))
+	for key, value := range query {
+		v[key] = value
+	}
+
+	switch int32(n) {
+	case port:
+		v[PortKey] = int32(0)
+	case 0:
+		v[PortKey] = port
+	default:
+		return nil, false
+	}
+
+	return v, true
+}
@@ -0,0 +1,62 @@
+package keychain_test
+
+import (
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func internetPassword(account string, port int32) keychain.Item {
+	item := keychain.NewItem()
+	item.SetSecClass(keychain.SecClassInternetPassword)
+	item.SetServer("example.com")
+	item.SetProtocol("htps")
+	item.SetAccount(account)
+
+	if port != 0 {
+		item.SetPort(port)
+	}
+
+	return item
+}
+
+func TestDefaultPort(t *testing.T) {
+	f := keychainfake.Install(t)
+
+	if err := keychain.AddItem(internetPassword("inferred", 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Items of other apps may have no port.
+	if _, err := f.Add(map[string]interface{}{
+		keychain.SecClassKey: "inet", keychain.ServerKey: "example.com", keychain.ProtocolKey: "htps",
+		keychain.AccountKey: "legacy",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := keychain.QueryInternetPasswordItems(internetPassword("inferred", 0))
+	if err != nil || len(items) != 1 || items[0].Port != 443 {
+		t.Fatalf("expected the inferred port, got %+v (%v)", items, err)
+	}
+
+	for _, port := range []int32{0, 443} {
+		query := internetPassword("", port)
+
+		items, err := keychain.QueryInternetPasswordItems(query)
+		if err != nil || len(items) != 2 {
+			t.Fatalf("port %d: expected both items, got %+v (%v)", port, items, err)
+		}
+	}
+
+	query := internetPassword("legacy", 443)
+	if ok, err := keychain.ItemExists(query); err != nil || !ok {
+		t.Fatalf("expected the item without a port to match port 443, got %v (%v)", ok, err)
+	}
+
+	items, err = keychain.QueryInternetPasswordItems(internetPassword("", 8443))
+	if err != nil || len(items) != 0 {
+		t.Fatalf("expected no item on port 8443, got %+v (%v)", items, err)
+	}
+}
@@ -0,0 +1,80 @@
+package keychain
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// defaultPreloadTTL is how long preloaded data is cached by default.
+const defaultPreloadTTL = time.Minute
+
+// PreloadOptions are options of PreloadWithOptions.
+type PreloadOptions struct {
+	// Workers is the number of queries run concurrently. Values less than 1
+	// run them one at a time, in order.
+	Workers int
+	// ShareAuthentication reads the items with one AuthenticationContext, so
+	// the user authenticates once for the items protected by the same access
+	// control, unless a query sets its own.
+	ShareAuthentication bool
+	// TTL is how long the data is cached, one minute if zero.
+	TTL time.Duration
+}
+
+// Preload reads the attributes and data of the item matching each of
+// queries, e.g. the secrets an application needs at launch, so that the first
+// reads after it don't wait on the keychain. Each query is completed to return
+// the attributes and data of one item, like GetBytes does, and the results are
+// kept in the prompt cache (see SetPromptCache) for a minute: reads with the
+// same query are answered from the cache, including GetBytes and the other
+// typed getters for a query of the same service and account. Queries matching
+// no item are ignored.
+func Preload(queries ...Item) error {
+	return PreloadWithOptions(context.Background(), PreloadOptions{}, queries...)
+}
+
+// PreloadWithOptions preloads queries like Preload, with opts. Cancelling ctx
+// stops it and dismisses a pending authentication prompt.
+func PreloadWithOptions(ctx context.Context, opts PreloadOptions, queries ...Item) error {
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = defaultPreloadTTL
+	}
+
+	var authCtx *AuthenticationContext
+
+	if opts.ShareAuthentication {
+		if c, err := NewAuthenticationContext(); err == nil {
+			defer c.Close() // nolint: errcheck
+
+			authCtx = c
+		}
+	}
+
+	return forEach(ctx, len(queries), BulkOptions{Workers: opts.Workers}, func(i int) error {
+		q := queries[i].clone()
+		q.SetMatchLimit(MatchLimitOne)
+		q.SetReturnAttributes(true)
+		q.SetReturnData(true)
+
+		// The cache key ignores the authentication context.
+		key, err := EncodeAttributes(q)
+		if err != nil {
+			return fmt.Errorf("failed to encode preload query %d: %w", i, err)
+		}
+
+		if _, ok := q.attr[UseAuthenticationContextKey]; !ok && authCtx != nil {
+			q.SetAuthenticationContext(authCtx)
+		}
+
+		results, err := QueryItemContext(ctx, q)
+		if err != nil {
+			return fmt.Errorf("failed to preload query %d: %w", i, err)
+		}
+
+		cacheResultFor(key, results, ttl)
+
+		return nil
+	})
+}
@@ -0,0 +1,55 @@
+package keychain_test
+
+import (
+	"context"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func TestPreload(t *testing.T) {
+	f := keychainfake.Install(t)
+	t.Cleanup(keychain.ClearPromptCache)
+
+	for _, account := range []string{"api-token", "refresh-token"} {
+		if err := keychain.SetString("com.example.app", account, account+"-secret"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := keychain.PreloadWithOptions(context.Background(), keychain.PreloadOptions{Workers: 2, ShareAuthentication: true},
+		genericPasswordQuery("com.example.app", "api-token"),
+		genericPasswordQuery("com.example.app", "refresh-token"),
+		genericPasswordQuery("com.example.app", "missing"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := len(f.Calls())
+
+	for _, account := range []string{"api-token", "refresh-token"} {
+		s, err := keychain.GetString("com.example.app", account)
+		if err != nil || s != account+"-secret" {
+			t.Fatalf("%s: got %q (%v)", account, s, err)
+		}
+	}
+
+	if n := len(f.Calls()) - calls; n != 0 {
+		t.Fatalf("expected the reads to be answered from the cache, got %d backend calls", n)
+	}
+
+	// Changes wipe the cache.
+	if err := keychain.SetString("com.example.app", "api-token", "changed"); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, err := keychain.GetString("com.example.app", "api-token"); err != nil || s != "changed" {
+		t.Fatalf("expected the changed value, got %q (%v)", s, err)
+	}
+
+	if err := keychain.Preload(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,176 @@
+package keychain
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// promptEntry is a cached query result, with its data in locked memory.
+type promptEntry struct {
+	result QueryResult
+	data   []byte
+	timer  *time.Timer
+}
+
+var promptCache = struct {
+	sync.Mutex
+	entries map[string]*promptEntry
+}{entries: make(map[string]*promptEntry)}
+
+// SetPromptCache sets how long the data of an item read with an access prompt
+// is cached, and returns the previous window. Within the window, the same
+// query returns the cached data without prompting again, which helps
+// workflows needing a secret several times within seconds. Zero, the default,
+// disables the cache.
+//
+// Only queries with an operation prompt (see Item.SetOperationPrompt) for a
+// single item are cached, besides those of Preload, and only with the package
+// configuration: WithConfig handles ignore Config.PromptCacheWindow. Cached
+// data is kept in locked memory where the platform allows, so it isn't
+// swapped to disk, and is wiped when the window ends, when an item is changed
+// with the package configuration and on ClearPromptCache. The data of items
+// only accessible while the device is unlocked (or of unknown accessibility)
+// is also wiped when the screen locks or the system sleeps, see
+// NotifySystemEvent.
+func SetPromptCache(window time.Duration) time.Duration {
+	return updateConfig(func(cfg *Config) { cfg.PromptCacheWindow = window }).PromptCacheWindow
+}
+
+// applyPromptCacheWindow starts watching system events for a window, or wipes
+// the cache without one.
+func applyPromptCacheWindow(window time.Duration) {
+	if window > 0 {
+		startSystemEvents()
+	} else {
+		ClearPromptCache()
+	}
+}
+
+// ClearPromptCache wipes the data cached by SetPromptCache, e.g. when the
+// application detects that the user stepped away.
+func ClearPromptCache() {
+	promptCache.Lock()
+	defer promptCache.Unlock()
+
+	for key, e := range promptCache.entries {
+		e.timer.Stop()
+		freeLocked(e.data)
+		delete(promptCache.entries, key)
+	}
+}
+
+// clearPromptCacheWhenUnlocked wipes the cached data of the items which are
+// only accessible while the device is unlocked.
+func clearPromptCacheWhenUnlocked() {
+	promptCache.Lock()
+	defer promptCache.Unlock()
+
+	for key, e := range promptCache.entries {
+		switch e.result.Accessible {
+		case AccessibleAfterFirstUnlock, AccessibleAfterFirstUnlockThisDeviceOnly,
+			AccessibleAlways, AccessibleAccessibleAlwaysThisDeviceOnly:
+			continue
+		}
+
+		e.timer.Stop()
+		freeLocked(e.data)
+		delete(promptCache.entries, key)
+	}
+}
+
+// promptCacheKey returns the key of query in the prompt cache, if it reads
+// the data of a single item.
+func promptCacheKey(query map[string]interface{}) (string, bool) {
+	if wantData, _ := query[ReturnDataKey].(bool); !wantData || query[MatchLimitKey] == "m_LimitAll" {
+		return "", false
+	}
+
+	key := make(map[string]interface{}, len(query))
+
+	for k, v := range query {
+		switch k {
+		case UseOperationPromptKey, UseAuthenticationUIKey, UseAuthenticationContextKey:
+		default:
+			key[k] = v
+		}
+	}
+
+	// fmt prints maps sorted by key.
+	return fmt.Sprint(key), true
+}
+
+// cachedResult returns the cached result of query.
+func cachedResult(query map[string]interface{}) ([]QueryResult, bool) {
+	key, ok := promptCacheKey(query)
+	if !ok {
+		return nil, false
+	}
+
+	promptCache.Lock()
+	defer promptCache.Unlock()
+
+	e, ok := promptCache.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	r := e.result
+	r.Data = append([]byte{}, e.data...)
+
+	return []QueryResult{r}, true
+}
+
+// cacheResult caches the result of query for window, if it is positive and
+// query has an operation prompt.
+func cacheResult(query map[string]interface{}, results []QueryResult, window time.Duration) {
+	key, ok := promptCacheKey(query)
+	if _, prompt := query[UseOperationPromptKey]; !ok || !prompt || len(results) != 1 {
+		return
+	}
+
+	promptCache.Lock()
+	defer promptCache.Unlock()
+
+	if window > 0 {
+		storeResult(key, results[0], window)
+	}
+}
+
+// cacheResultFor caches the result of query for window, e.g. for Preload.
+func cacheResultFor(query map[string]interface{}, results []QueryResult, window time.Duration) {
+	key, ok := promptCacheKey(query)
+	if !ok || len(results) != 1 {
+		return
+	}
+
+	promptCache.Lock()
+	defer promptCache.Unlock()
+
+	storeResult(key, results[0], window)
+}
+
+// storeResult caches r under key for window. The cache must be locked.
+func storeResult(key string, r QueryResult, window time.Duration) {
+	if old, ok := promptCache.entries[key]; ok {
+		old.timer.Stop()
+		freeLocked(old.data)
+	}
+
+	e := &promptEntry{result: r, data: allocLocked(len(r.Data))}
+	copy(e.data, r.Data)
+	e.result.Data = nil
+	e.timer = time.AfterFunc(window, func() { expirePrompt(key, e) })
+	promptCache.entries[key] = e
+}
+
+// expirePrompt wipes e at the end of its window, unless it was replaced.
+func expirePrompt(key string, e *promptEntry) {
+	promptCache.Lock()
+	defer promptCache.Unlock()
+
+	if promptCache.entries[key] == e {
+		freeLocked(e.data)
+		delete(promptCache.entries, key)
+	}
+}
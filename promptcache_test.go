@@ -0,0 +1,121 @@
+package keychain
+
+import (
+	"testing"
+	"time"
+)
+
+func promptQuery() Item {
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService("MyService")
+	query.SetAccount("gabriel")
+	query.SetMatchLimit(MatchLimitOne)
+	query.SetReturnData(true)
+	query.SetOperationPrompt("Sign in to MyService")
+
+	return query
+}
+
+func TestPromptCache(t *testing.T) {
+	prev := SetPromptCache(time.Minute)
+	defer SetPromptCache(prev)
+
+	secret := fakeResponse{result: []byte("toomanysecrets")}
+	f := useFakeBackend(t, secret, fakeResponse{}, fakeResponse{}, secret)
+
+	for i := 0; i < 2; i++ {
+		results, err := QueryItem(promptQuery())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(results) != 1 || string(results[0].Data) != "toomanysecrets" {
+			t.Fatalf("unexpected results %v", results)
+		}
+
+		results[0].Data[0] = 'x'
+	}
+
+	if len(f.calls) != 1 {
+		t.Fatalf("expected the second read to be cached, got %d calls", len(f.calls))
+	}
+
+	// Reads without a prompt aren't cached.
+	if _, err := GetGenericPassword("MyService", "gabriel", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.calls) != 2 {
+		t.Fatalf("expected a read without prompt to reach the backend, got %d calls", len(f.calls))
+	}
+
+	// Changes clear the cache.
+	if err := DeleteGenericPasswordItem("Other", "gabriel"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := QueryItem(promptQuery()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.calls) != 4 {
+		t.Fatalf("expected the cache to be cleared by a change, got %d calls", len(f.calls))
+	}
+
+	// Changes through a handle with its own configuration don't.
+	c := WithConfig(Config{Backend: &fakeBackend{}})
+	if err := c.DeleteItem(promptQuery()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := QueryItem(promptQuery()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.calls) != 4 {
+		t.Fatalf("expected the cache to be kept after a change through a handle, got %d calls", len(f.calls))
+	}
+
+	if GetConfig().PromptCacheWindow != time.Minute {
+		t.Fatalf("expected the window in the configuration, got %v", GetConfig().PromptCacheWindow)
+	}
+}
+
+func TestPromptCacheWindow(t *testing.T) {
+	prev := SetPromptCache(10 * time.Millisecond)
+	defer SetPromptCache(prev)
+
+	f := useFakeBackend(t, fakeResponse{result: []byte("toomanysecrets")}, fakeResponse{result: []byte("toomanysecrets")})
+
+	if _, err := QueryItem(promptQuery()); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	if _, err := QueryItem(promptQuery()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.calls) != 2 {
+		t.Fatalf("expected the cached data to expire, got %d calls", len(f.calls))
+	}
+
+	SetPromptCache(0)
+
+	if _, ok := cachedResult(mustEncode(t, promptQuery())); ok {
+		t.Fatal("expected disabling the cache to clear it")
+	}
+}
+
+func mustEncode(t *testing.T, item Item) map[string]interface{} {
+	t.Helper()
+
+	attrs, err := EncodeAttributes(item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return attrs
+}
@@ -0,0 +1,217 @@
+package keychain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultQueryPageCacheTTL is the QueryPageCacheTTL of a Config without one.
+const defaultQueryPageCacheTTL = 30 * time.Second
+
+type pageCacheEntry struct {
+	results []QueryResult
+	expires time.Time
+}
+
+// pageCache holds the item lists of QueryPage for a configuration, so those
+// of another backend aren't reused.
+type pageCache struct {
+	sync.Mutex
+	entries map[string]pageCacheEntry
+}
+
+// SetQueryPageCacheTTL sets how long QueryPage reuses the item list of a query
+// before enumerating the keychain again, and returns the previous setting.
+// Zero, the default, is 30 seconds; a negative ttl disables the cache, so
+// each page enumerates the keychain.
+func SetQueryPageCacheTTL(ttl time.Duration) time.Duration {
+	return updateConfig(func(cfg *Config) { cfg.QueryPageCacheTTL = ttl }).QueryPageCacheTTL
+}
+
+// QueryPage returns up to limit results (with data) of item, starting at
+// offset, along with the total number of matching items. Matching items are
+// enumerated once (attributes only) and their order is cached for
+// Config.QueryPageCacheTTL, so only the data of the requested page is read.
+// Results are ordered by label, account, service and server. The lists are
+// cached per configuration, so changing the package configuration discards
+// them.
+func QueryPage(item Item, offset, limit int) ([]QueryResult, int, error) {
+	return cur().queryPage(item, offset, limit)
+}
+
+// QueryPage returns a page of the results of item, see the package function.
+func (c *Configured) QueryPage(item Item, offset, limit int) ([]QueryResult, int, error) {
+	return c.s.queryPage(item, offset, limit)
+}
+
+func (s *state) queryPage(item Item, offset, limit int) ([]QueryResult, int, error) {
+	if offset < 0 || limit < 0 {
+		return nil, 0, fmt.Errorf("invalid page offset %d or limit %d", offset, limit)
+	}
+
+	all, err := s.pageList(item)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if offset >= len(all) {
+		return nil, len(all), nil
+	}
+
+	// offset+limit may overflow.
+	if limit > len(all)-offset {
+		limit = len(all) - offset
+	}
+
+	page := make([]QueryResult, 0, limit)
+	secClass := item.secClass()
+
+	for _, r := range all[offset : offset+limit] {
+		ref, err := resultRef(r)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		query := newItem()
+		if secClass != 0 {
+			query.SetSecClass(secClass)
+		}
+
+		query.SetPersistentRef(ref)
+		query.SetMatchLimit(MatchLimitOne)
+		query.SetReturnData(true)
+
+		dataResults, err := s.queryItem(query)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if len(dataResults) == 1 {
+			r.Data = dataResults[0].Data
+		}
+
+		page = append(page, r)
+	}
+
+	return page, len(all), nil
+}
+
+// ClearQueryPageCache discards the cached item lists used by QueryPage.
+func ClearQueryPageCache() {
+	cur().pages.clear()
+}
+
+// ClearQueryPageCache discards the cached item lists used by the QueryPage
+// method of the handle.
+func (c *Configured) ClearQueryPageCache() {
+	c.s.pages.clear()
+}
+
+func (c *pageCache) clear() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.entries = nil
+}
+
+// get returns the cached list for key, if it hasn't expired.
+func (c *pageCache) get(key string) ([]QueryResult, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || !time.Now().Before(entry.expires) {
+		return nil, false
+	}
+
+	return entry.results, true
+}
+
+// put caches the list for key for ttl, evicting the expired lists.
+func (c *pageCache) put(key string, results []QueryResult, ttl time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+
+	now := time.Now()
+
+	if c.entries == nil {
+		c.entries = make(map[string]pageCacheEntry)
+	}
+
+	for k, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	c.entries[key] = pageCacheEntry{results: results, expires: now.Add(ttl)}
+}
+
+func (s *state) pageList(item Item) ([]QueryResult, error) {
+	key := item.cacheKey()
+
+	ttl := s.cfg.QueryPageCacheTTL
+	if ttl == 0 {
+		ttl = defaultQueryPageCacheTTL
+	}
+
+	if ttl > 0 {
+		if results, ok := s.pages.get(key); ok {
+			return results, nil
+		}
+	}
+
+	query := item.clone()
+	query.SetReturnPersistentRef(true)
+	query.SetMatchLimit(MatchLimitAll)
+	query.SetReturnAttributes(true)
+	delete(query.attr, ReturnDataKey)
+
+	results, err := s.queryItem(query)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Label != b.Label {
+			return a.Label < b.Label
+		}
+
+		if a.Account != b.Account {
+			return a.Account < b.Account
+		}
+
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+
+		return a.Server < b.Server
+	})
+
+	if ttl > 0 {
+		s.pages.put(key, results, ttl)
+	}
+
+	return results, nil
+}
+
+// cacheKey returns a string identifying the attributes of the item.
+func (k *Item) cacheKey() string {
+	keys := make([]string, 0, len(k.attr))
+	for key := range k.attr {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&sb, "%s=%#v;", key, k.attr[key])
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,64 @@
+//go:build darwin && !ios
+// +build darwin,!ios
+
+package keychain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueryPage(t *testing.T) {
+	service := "TestQueryPage"
+	for _, account := range []string{"a", "b", "c"} {
+		defer func(account string) { _ = DeleteGenericPasswordItem(service, account) }(account)
+		if err := AddItem(NewGenericPassword(service, account, "", []byte("secret-"+account), "")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService(service)
+	defer ClearQueryPageCache()
+
+	page, total, err := QueryPage(query, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 {
+		t.Fatalf("expected 3 items, got %d", total)
+	}
+	if len(page) != 1 || page[0].Account != "b" || string(page[0].Data) != "secret-b" {
+		t.Fatalf("unexpected page: %+v", page)
+	}
+
+	page, total, err = QueryPage(query, 2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3 || len(page) != 1 || page[0].Account != "c" {
+		t.Fatalf("unexpected last page: %+v (total %d)", page, total)
+	}
+}
+
+func TestPageCacheEviction(t *testing.T) {
+	var c pageCache
+
+	c.put("expired", nil, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	if _, ok := c.get("expired"); ok {
+		t.Fatal("expected the expired list to be missed")
+	}
+
+	c.put("fresh", []QueryResult{{Account: "a"}}, time.Minute)
+
+	if _, ok := c.entries["expired"]; ok || len(c.entries) != 1 {
+		t.Fatalf("expected the expired list to be evicted, got %v", c.entries)
+	}
+
+	if results, ok := c.get("fresh"); !ok || len(results) != 1 {
+		t.Fatalf("expected the fresh list, got %v", results)
+	}
+}
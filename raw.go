@@ -0,0 +1,94 @@
+package keychain
+
+import (
+	"fmt"
+	"math"
+)
+
+// The Raw functions call the SecItem functions (or the backend) with attribute
+// maps as is, for attributes this package doesn't wrap yet. Keys are the
+// values of the Security framework constants, e.g. "svce" for
+// kSecAttrService, and values are bool, int, int32, string or []byte, or
+// [][]byte for MatchItemListKey. Only the value types are checked. Unlike
+// the Item functions, they bypass the defaults, Unicode normalization,
+// accessibility policy and prompt cache of this package; changes still count
+// for change sequences.
+
+// rawAttrs checks that the values of attrs can be converted by the backends,
+// and returns a copy with int values as int32.
+func rawAttrs(attrs map[string]interface{}) (map[string]interface{}, error) {
+	m := make(map[string]interface{}, len(attrs))
+
+	for key, v := range attrs {
+		switch val := v.(type) {
+		case bool, int32, string, []byte:
+		case int:
+			if val < math.MinInt32 || val > math.MaxInt32 {
+				return nil, fmt.Errorf("value of %q out of range: %d", key, val)
+			}
+
+			v = int32(val)
+		case [][]byte:
+			if key != MatchItemListKey {
+				return nil, fmt.Errorf("unsupported value type for %q: %T", key, v)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported value type for %q: %T", key, v)
+		}
+
+		m[key] = v
+	}
+
+	return m, nil
+}
+
+// RawQuery calls SecItemCopyMatching with query. The result is converted as
+// described by Backend, deeply, so it is typically a map[string]interface{}
+// or a []interface{} of them. ErrorItemNotFound is returned if no item
+// matches.
+func RawQuery(query map[string]interface{}) (interface{}, error) {
+	q, err := rawAttrs(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return backend().CopyMatching(q)
+}
+
+// RawAdd calls SecItemAdd with attrs, and returns the requested result (see
+// WantsResult) or nil.
+func RawAdd(attrs map[string]interface{}) (interface{}, error) {
+	a, err := rawAttrs(attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := backend().Add(a)
+
+	return result, countChange(err, a)
+}
+
+// RawUpdate calls SecItemUpdate with query and attrs.
+func RawUpdate(query, attrs map[string]interface{}) error {
+	q, err := rawAttrs(query)
+	if err != nil {
+		return err
+	}
+
+	a, err := rawAttrs(attrs)
+	if err != nil {
+		return err
+	}
+
+	return countChange(backend().Update(q, a), q, a)
+}
+
+// RawDelete calls SecItemDelete with query.
+func RawDelete(query map[string]interface{}) error {
+	q, err := rawAttrs(query)
+	if err != nil {
+		return err
+	}
+
+	return countChange(backend().Delete(q), q)
+}
@@ -0,0 +1,63 @@
+package keychain
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRawQuery(t *testing.T) {
+	f := useFakeBackend(t, fakeResponse{result: map[string]interface{}{"svce": "MyService", "pdmn": "ak"}}, fakeResponse{err: ErrorItemNotFound})
+
+	attrs := map[string]interface{}{"class": "genp", "svce": "MyService", "port": 443, "r_Attributes": true}
+
+	result, err := RawQuery(attrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := map[string]interface{}{"svce": "MyService", "pdmn": "ak"}; !reflect.DeepEqual(result, want) {
+		t.Fatalf("unexpected result: %v", result)
+	}
+
+	if query := f.calls[0].query; query["port"] != int32(443) || query["class"] != "genp" {
+		t.Fatalf("unexpected query: %v", query)
+	}
+
+	if _, err := RawQuery(attrs); !errors.Is(err, ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound, got %v", err)
+	}
+}
+
+func TestRawChanges(t *testing.T) {
+	f := useFakeBackend(t, fakeResponse{result: []byte("ref")})
+
+	attrs := map[string]interface{}{"class": "genp", "svce": "MyService", "acct": "gabriel", "v_Data": []byte("toomanysecrets")}
+
+	result, err := RawAdd(attrs)
+	if err != nil || !reflect.DeepEqual(result, []byte("ref")) {
+		t.Fatalf("unexpected result %v (%v)", result, err)
+	}
+
+	query := map[string]interface{}{"class": "genp", "svce": "MyService"}
+
+	if err := RawUpdate(query, map[string]interface{}{"labl": "label"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RawDelete(query); err != nil {
+		t.Fatal(err)
+	}
+
+	if ops := f.ops(); !reflect.DeepEqual(ops, []string{"add", "update", "delete"}) {
+		t.Fatalf("unexpected operations %v", ops)
+	}
+
+	if err := RawDelete(map[string]interface{}{"cdat": 1.5}); err == nil {
+		t.Fatal("expected an unsupported value type error")
+	}
+
+	if len(f.calls) != 3 {
+		t.Fatalf("expected invalid attributes to not reach the backend, got %d calls", len(f.calls))
+	}
+}
@@ -0,0 +1,78 @@
+package keychain
+
+import "errors"
+
+// ErrReadOnlyMode is returned for changes to a keychain opened read-only, see
+// ReadOnly.
+var ErrReadOnlyMode = errors.New("keychain is read-only")
+
+// readOnlyBackend rejects changes before they reach the wrapped Backend.
+type readOnlyBackend struct {
+	Backend
+}
+
+// ReadOnly returns a Backend serving queries from b and failing all changes
+// with ErrReadOnlyMode, without calling b, e.g. for audit tools or to run
+// untrusted plugins against a credential store. A nil b is the platform
+// default. To make this package read-only:
+//
+//	keychain.SetBackend(keychain.ReadOnly(nil))
+//
+// While the backend is read-only, functions calling the Security framework
+// directly (AddItemReturningRef, GenerateKey for permanent keys, DeleteKey)
+// fail with ErrReadOnlyMode too, and no usage records are written.
+func ReadOnly(b Backend) Backend {
+	if b == nil {
+		b = defaultBackend
+	}
+
+	if CapabilitiesOf(b).ReadOnly {
+		return b
+	}
+
+	return readOnlyBackend{b}
+}
+
+// OpenReadOnly opens the backend registered under name read-only, see
+// ReadOnly.
+func OpenReadOnly(name string) (Backend, error) {
+	b, err := OpenBackend(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return ReadOnly(b), nil
+}
+
+// Capabilities implements CapabilityReporter.
+func (r readOnlyBackend) Capabilities() Capability {
+	c := CapabilitiesOf(r.Backend)
+	c.ReadOnly = true
+
+	return c
+}
+
+// Add implements Backend.
+func (r readOnlyBackend) Add(map[string]interface{}) (interface{}, error) {
+	return nil, ErrReadOnlyMode
+}
+
+// Update implements Backend.
+func (r readOnlyBackend) Update(_, _ map[string]interface{}) error {
+	return ErrReadOnlyMode
+}
+
+// Delete implements Backend.
+func (r readOnlyBackend) Delete(map[string]interface{}) error {
+	return ErrReadOnlyMode
+}
+
+// checkWritable returns ErrReadOnlyMode if the backend used by this package is
+// read-only.
+func checkWritable() error {
+	if Capabilities().ReadOnly {
+		return ErrReadOnlyMode
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package keychain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReadOnly(t *testing.T) {
+	f := &fakeBackend{responses: []fakeResponse{{result: []byte("toomanysecrets")}}}
+
+	prev := SetBackend(ReadOnly(f))
+	defer SetBackend(prev)
+
+	if !Capabilities().ReadOnly || !Capabilities().UniqueKeys {
+		t.Fatalf("unexpected capabilities %+v", Capabilities())
+	}
+
+	if s, err := GetString("MyService", "gabriel"); err != nil || s != "toomanysecrets" {
+		t.Fatalf("unexpected read %q, %v", s, err)
+	}
+
+	for name, err := range map[string]error{
+		"add":    AddItem(NewGenericPassword("MyService", "gabriel", "", []byte("a"), "")),
+		"set":    SetString("MyService", "gabriel", "a"),
+		"delete": DeleteGenericPasswordItem("MyService", "gabriel"),
+		"key":    DeleteKey([]byte("tag")),
+	} {
+		if !errors.Is(err, ErrReadOnlyMode) {
+			t.Errorf("%s: expected ErrReadOnlyMode, got %v", name, err)
+		}
+	}
+
+	for _, op := range f.ops() {
+		if op != "copyMatching" {
+			t.Fatalf("changes reached the backend: %v", f.ops())
+		}
+	}
+
+	if ReadOnly(ReadOnly(f)) != ReadOnly(f) {
+		t.Fatal("read-only backend wrapped twice")
+	}
+}
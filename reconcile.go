@@ -0,0 +1,252 @@
+package keychain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// DesiredItem is a generic password declared in a manifest for Reconcile.
+type DesiredItem struct {
+	Service string
+	Account string
+	// AccessGroup, if set, is the access group of the item. Otherwise items
+	// of any access group match, and missing items are added with the
+	// defaults of NewItem.
+	AccessGroup string
+	// Label, if set, is the label of the item.
+	Label string
+	// Accessible, if set, is the protection class of the item.
+	Accessible Accessible
+	// Secret returns the data of the item. It is called when the item is
+	// added, and to compare the data with ReconcileOptions.CheckSecrets.
+	Secret func() ([]byte, error)
+}
+
+// ReconcileOptions are options of Reconcile.
+type ReconcileOptions struct {
+	// BulkOptions apply to the changes: with DryRun, Reconcile only reports
+	// the drift.
+	BulkOptions
+	// Prune deletes the generic passwords of the services of the manifest
+	// which it doesn't declare.
+	Prune bool
+	// CheckSecrets reads the data of the declared items, which may need user
+	// authentication, and replaces it when it differs from their Secret.
+	CheckSecrets bool
+}
+
+// ReconcileStep plans the changes making the generic passwords of the services
+// of manifest match it, see Reconcile.
+func ReconcileStep(manifest []DesiredItem, opts ReconcileOptions) Step {
+	return func() ([]Change, error) {
+		var (
+			services []string
+			declared = map[string][]DesiredItem{}
+			seen     = map[[3]string]bool{}
+		)
+
+		for _, d := range manifest {
+			key := [3]string{d.Service, d.Account, d.AccessGroup}
+			if seen[key] {
+				return nil, fmt.Errorf("%q@%q is declared twice", d.Account, d.Service)
+			}
+
+			seen[key] = true
+
+			if _, ok := declared[d.Service]; !ok {
+				services = append(services, d.Service)
+			}
+
+			declared[d.Service] = append(declared[d.Service], d)
+		}
+
+		var changes []Change
+
+		for _, service := range services {
+			// The manifest declares items regardless of the defaults of
+			// NewItem, synchronized or not.
+			query := newItem()
+			query.SetSecClass(SecClassGenericPassword)
+			query.SetService(service)
+			query.SetSynchronizable(SynchronizableAny)
+
+			results, err := queryAttributes(query)
+			if err != nil {
+				return nil, err
+			}
+
+			matched := make([]bool, len(results))
+
+			for _, d := range declared[service] {
+				i := matchDesired(d, results)
+				if i < 0 {
+					if d.Secret == nil {
+						return nil, fmt.Errorf("%q@%q is missing and has no secret", d.Account, d.Service)
+					}
+
+					changes = append(changes, addDesiredChange(d))
+
+					continue
+				}
+
+				matched[i] = true
+
+				c, ok, err := updateDesiredChange(d, results[i], opts.CheckSecrets)
+				if err != nil {
+					return nil, err
+				}
+
+				if ok {
+					changes = append(changes, c)
+				}
+			}
+
+			if !opts.Prune {
+				continue
+			}
+
+			for i, r := range results {
+				if !matched[i] {
+					changes = append(changes, deleteQueryChange(reconcileQuery(r), r, "delete undeclared "+describeResult(r)))
+				}
+			}
+		}
+
+		return changes, nil
+	}
+}
+
+// reconcileQuery returns the query for the generic password r, synchronized
+// or not.
+func reconcileQuery(r QueryResult) Item {
+	query := queryForResult(SecClassGenericPassword, r)
+	query.SetSynchronizable(SynchronizableAny)
+
+	return query
+}
+
+// matchDesired returns the index of the result of d, or -1.
+func matchDesired(d DesiredItem, results []QueryResult) int {
+	for i, r := range results {
+		if r.Account == d.Account && (d.AccessGroup == "" || r.AccessGroup == d.AccessGroup) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// addDesiredChange plans adding the missing item d.
+func addDesiredChange(d DesiredItem) Change {
+	return Change{
+		Kind:        ChangeAdd,
+		Class:       SecClassGenericPassword,
+		Item:        QueryResult{Service: d.Service, Account: d.Account, AccessGroup: d.AccessGroup, Label: d.Label, Accessible: d.Accessible},
+		Description: fmt.Sprintf("add missing %q@%q", d.Account, d.Service),
+		apply: func() error {
+			data, err := d.Secret()
+			if err != nil {
+				return fmt.Errorf("failed to get the secret: %w", err)
+			}
+
+			item := NewItem()
+			item.SetSecClass(SecClassGenericPassword)
+			item.SetService(d.Service)
+			item.attr[AccountKey] = d.Account
+			item.SetLabel(d.Label)
+			item.SetData(data)
+
+			if d.AccessGroup != "" {
+				item.SetAccessGroup(d.AccessGroup)
+			}
+
+			if d.Accessible != AccessibleDefault {
+				item.SetAccessible(d.Accessible)
+			}
+
+			return AddItem(item)
+		},
+	}
+}
+
+// updateDesiredChange plans updating r to match d, and returns false if it
+// does.
+func updateDesiredChange(d DesiredItem, r QueryResult, checkSecret bool) (Change, bool, error) {
+	update := newItem()
+
+	var drift []string
+
+	if d.Label != "" && r.Label != d.Label {
+		update.SetLabel(d.Label)
+		drift = append(drift, fmt.Sprintf("label %q instead of %q", r.Label, d.Label))
+	}
+
+	// Backends which don't return the accessibility can't drift.
+	if d.Accessible != AccessibleDefault && r.Accessible != AccessibleDefault && r.Accessible != d.Accessible {
+		update.SetAccessible(d.Accessible)
+		drift = append(drift, fmt.Sprintf("accessible %s instead of %s", accessibleNames[r.Accessible], accessibleNames[d.Accessible]))
+	}
+
+	if checkSecret && d.Secret != nil {
+		differs, data, err := secretDiffers(d, r)
+		if err != nil {
+			return Change{}, false, err
+		}
+
+		if differs {
+			update.SetData(data)
+			drift = append(drift, "another secret")
+		}
+	}
+
+	if len(drift) == 0 {
+		return Change{}, false, nil
+	}
+
+	return updateQueryChange(reconcileQuery(r), r, update,
+		fmt.Sprintf("update %s with %s", describeResult(r), strings.Join(drift, ", "))), true, nil
+}
+
+// secretDiffers returns whether the data of r differs from the secret of d,
+// and the secret.
+func secretDiffers(d DesiredItem, r QueryResult) (bool, []byte, error) {
+	want, err := d.Secret()
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to get the secret of %s: %w", describeResult(r), err)
+	}
+
+	query := reconcileQuery(r)
+	query.SetMatchLimit(MatchLimitOne)
+	query.SetReturnData(true)
+
+	results, err := QueryItem(query)
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to read %s: %w", describeResult(r), err)
+	}
+
+	if len(results) != 1 {
+		return false, nil, fmt.Errorf("failed to read %s: %w", describeResult(r), ErrorItemNotFound)
+	}
+
+	defer clear(results[0].Data)
+
+	if bytes.Equal(results[0].Data, want) {
+		clear(want)
+
+		return false, nil, nil
+	}
+
+	return true, want, nil
+}
+
+// Reconcile compares the generic passwords of the services of manifest with
+// it, e.g. to manage the credentials of machines as code, and converges them:
+// missing items are added with their secret, and items are updated where
+// their label, protection class or (with CheckSecrets) data differ from the
+// declaration. With DryRun, the report lists the drift without changing the
+// keychain.
+func Reconcile(ctx context.Context, manifest []DesiredItem, opts ReconcileOptions) (Report, error) {
+	return runBulk(ctx, "reconcile", ReconcileStep(manifest, opts), opts.BulkOptions)
+}
@@ -0,0 +1,127 @@
+package keychain_test
+
+import (
+	"context"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func TestReconcile(t *testing.T) {
+	keychainfake.Install(t)
+
+	service := "com.example.fleet"
+	secret := func(s string) func() ([]byte, error) {
+		return func() ([]byte, error) { return []byte(s), nil }
+	}
+
+	if err := keychain.AddItem(keychain.NewGenericPassword(service, "db", "Old label", []byte("old"), "")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.AddItem(keychain.NewGenericPassword(service, "legacy", "", []byte("legacy"), "")); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := []keychain.DesiredItem{
+		{Service: service, Account: "db", Label: "Database", Secret: secret("new")},
+		{Service: service, Account: "api", Label: "API", Secret: secret("token")},
+	}
+
+	opts := keychain.ReconcileOptions{BulkOptions: keychain.BulkOptions{DryRun: true}, Prune: true}
+
+	report, err := keychain.Reconcile(context.Background(), manifest, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := map[keychain.ChangeKind]int{}
+	for _, c := range report.Changes {
+		kinds[c.Kind]++
+	}
+
+	if len(report.Changes) != 3 || kinds[keychain.ChangeAdd] != 1 || kinds[keychain.ChangeUpdate] != 1 || kinds[keychain.ChangeDelete] != 1 {
+		t.Fatalf("unexpected drift: %+v", report.Changes)
+	}
+
+	if data, _ := keychain.GetGenericPassword(service, "api", "", ""); data != nil {
+		t.Fatal("dry run should not add items")
+	}
+
+	opts.DryRun = false
+	if _, err := keychain.Reconcile(context.Background(), manifest, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := keychain.GetGenericPassword(service, "api", "", ""); err != nil || string(data) != "token" {
+		t.Fatalf("expected the missing item to be added, got %q (%v)", data, err)
+	}
+
+	if data, _ := keychain.GetGenericPassword(service, "legacy", "", ""); data != nil {
+		t.Fatal("expected the undeclared item to be deleted")
+	}
+
+	// Without CheckSecrets, the data of existing items isn't compared.
+	if report, err := keychain.Reconcile(context.Background(), manifest, opts); err != nil || len(report.Changes) != 0 {
+		t.Fatalf("expected no drift, got %+v (%v)", report.Changes, err)
+	}
+
+	opts.CheckSecrets = true
+	if report, err := keychain.Reconcile(context.Background(), manifest, opts); err != nil || len(report.Changes) != 1 {
+		t.Fatalf("expected the secret to be replaced, got %+v (%v)", report.Changes, err)
+	}
+
+	if data, err := keychain.GetGenericPassword(service, "db", "", ""); err != nil || string(data) != "new" {
+		t.Fatalf("expected the new secret, got %q (%v)", data, err)
+	}
+
+	if report, err := keychain.Reconcile(context.Background(), manifest, opts); err != nil || len(report.Changes) != 0 {
+		t.Fatalf("expected the keychain to be converged, got %+v (%v)", report.Changes, err)
+	}
+
+	duplicate := append(manifest, manifest[0])
+	if _, err := keychain.Reconcile(context.Background(), duplicate, opts); err == nil {
+		t.Fatal("expected an error for an item declared twice")
+	}
+}
+
+func TestReconcileIgnoresDefaults(t *testing.T) {
+	keychainfake.Install(t)
+
+	service := "com.example.fleet"
+
+	db := keychain.NewGenericPassword(service, "db", "Database", []byte("secret"), "")
+	db.SetSynchronizable(keychain.SynchronizableNo)
+
+	if err := keychain.AddItem(db); err != nil {
+		t.Fatal(err)
+	}
+
+	legacy := keychain.NewGenericPassword(service, "legacy", "", []byte("legacy"), "")
+	legacy.SetSynchronizable(keychain.SynchronizableYes)
+
+	if err := keychain.AddItem(legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	prev := keychain.SetDefaults(keychain.Defaults{Synchronizable: keychain.SynchronizableYes})
+	defer keychain.SetDefaults(prev)
+
+	manifest := []keychain.DesiredItem{{Service: service, Account: "db", Label: "Database"}}
+
+	report, err := keychain.Reconcile(context.Background(), manifest, keychain.ReconcileOptions{Prune: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Changes) != 1 || report.Changes[0].Kind != keychain.ChangeDelete || report.Changes[0].Item.Account != "legacy" {
+		t.Fatalf("expected only the undeclared item to be deleted, got %+v", report.Changes)
+	}
+
+	keychain.SetDefaults(prev)
+
+	if data, err := keychain.GetGenericPassword(service, "db", "", ""); err != nil || string(data) != "secret" {
+		t.Fatalf("expected the declared item to be kept, got %q (%v)", data, err)
+	}
+}
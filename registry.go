@@ -0,0 +1,81 @@
+package keychain
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// BackendFactory opens a registered backend.
+type BackendFactory func() (Backend, error)
+
+var backends = struct {
+	sync.Mutex
+	factories map[string]BackendFactory
+}{factories: make(map[string]BackendFactory)}
+
+// RegisterBackend registers a backend under name, usually from the init
+// function of the package implementing it, so it can be selected with
+// UseBackend. Registering a name again replaces the earlier factory.
+func RegisterBackend(name string, factory BackendFactory) {
+	backends.Lock()
+	defer backends.Unlock()
+
+	backends.factories[name] = factory
+}
+
+// Backends returns the names of the registered backends, sorted.
+func Backends() []string {
+	backends.Lock()
+	defer backends.Unlock()
+
+	names := make([]string, 0, len(backends.factories))
+	for name := range backends.factories {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// registered returns whether a backend is registered under name.
+func registered(name string) bool {
+	backends.Lock()
+	defer backends.Unlock()
+
+	_, ok := backends.factories[name]
+
+	return ok
+}
+
+// OpenBackend opens the backend registered under name.
+func OpenBackend(name string) (Backend, error) {
+	backends.Lock()
+	factory, ok := backends.factories[name]
+	backends.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown keychain backend %q (registered: %v)", name, Backends())
+	}
+
+	b, err := factory()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open keychain backend %q: %w", name, err)
+	}
+
+	return b, nil
+}
+
+// UseBackend opens the backend registered under name and makes it the backend
+// used by this package, see SetBackend.
+func UseBackend(name string) error {
+	b, err := OpenBackend(name)
+	if err != nil {
+		return err
+	}
+
+	SetBackend(b)
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package keychain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUseBackend(t *testing.T) {
+	f := &fakeBackend{}
+	RegisterBackend("test-fake", func() (Backend, error) { return f, nil })
+	RegisterBackend("test-broken", func() (Backend, error) { return nil, ErrorNotAvailable })
+
+	prev := GetConfig().Backend
+	t.Cleanup(func() { SetBackend(prev) })
+
+	if err := UseBackend("test-fake"); err != nil {
+		t.Fatal(err)
+	}
+
+	if GetConfig().Backend != f {
+		t.Fatal("expected registered backend to be used")
+	}
+
+	if err := UseBackend("test-broken"); !errors.Is(err, ErrorNotAvailable) {
+		t.Fatalf("expected ErrorNotAvailable, got %v", err)
+	}
+
+	if err := UseBackend("test-missing"); err == nil {
+		t.Fatal("expected error for unknown backend")
+	}
+
+	if GetConfig().Backend != f {
+		t.Fatal("failed UseBackend changed the backend")
+	}
+}
@@ -0,0 +1,420 @@
+package keychain
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultRenewalRetry is how long a CertificateAgent waits after a failed
+// renewal before trying again.
+const DefaultRenewalRetry = time.Minute
+
+// maxChainLength is the number of issuers looked up for a loaded identity.
+const maxChainLength = 4
+
+// ErrNoIdentity is returned by the TLS callbacks of a CertificateAgent until
+// it has an identity.
+var ErrNoIdentity = errors.New("no identity to serve")
+
+// ErrRenewalDue is reported by CertificateAgent.Run when a new certificate
+// is already due for renewal.
+var ErrRenewalDue = errors.New("new certificate is already due for renewal")
+
+// Enroller obtains certificates from a certificate authority, e.g. a SCEP,
+// EST or ACME client.
+type Enroller interface {
+	// Enroll returns the certificate issued for csr, followed by the
+	// intermediates of its chain.
+	Enroll(ctx context.Context, csr *x509.CertificateRequest) ([]*x509.Certificate, error)
+}
+
+// EnrollerFunc is a function implementing Enroller.
+type EnrollerFunc func(ctx context.Context, csr *x509.CertificateRequest) ([]*x509.Certificate, error)
+
+// Enroll implements Enroller.
+func (f EnrollerFunc) Enroll(ctx context.Context, csr *x509.CertificateRequest) ([]*x509.Certificate, error) {
+	return f(ctx, csr)
+}
+
+// RenewalPolicy is when and how a CertificateAgent renews its identity.
+type RenewalPolicy struct {
+	// RenewBefore is how long before the certificate expires it is renewed.
+	// It defaults to a third of the validity of the certificate.
+	RenewBefore time.Duration
+	// Retry is the delay before retrying a failed renewal. It defaults to
+	// DefaultRenewalRetry.
+	Retry time.Duration
+	// Key are the options of the private keys of new identities, an EC P-256
+	// key by default. The keys are always permanent.
+	Key KeyOptions
+	// OnRenew, if set, is called with each new certificate once it is served.
+	OnRenew func(*x509.Certificate)
+	// OnError, if set, is called with the errors of background renewals.
+	OnError func(error)
+}
+
+// CertificateAgent keeps a TLS identity in the keychain valid, e.g. the client
+// certificate of a daemon authenticating with mTLS: before the certificate
+// expires, it creates a new private key in the keychain, sends a certificate
+// request for it to an Enroller, pairs the issued certificate with the key
+// (see PairCertificateWithKey) and serves the new identity from then on. The
+// identity is labeled with the common name of the request, and its
+// intermediates are added to the keychain too, so a restarted agent finds
+// them with Load. Previous identities are left in the keychain.
+//
+// GetCertificate and GetClientCertificate serve the identity to a tls.Config:
+//
+//	agent := keychain.NewCertificateAgent(template, enroller, keychain.RenewalPolicy{})
+//	go agent.Run(ctx)
+//	config := &tls.Config{GetClientCertificate: agent.GetClientCertificate}
+type CertificateAgent struct {
+	template x509.CertificateRequest
+	enroller Enroller
+	policy   RenewalPolicy
+	trigger  chan struct{}
+
+	mu      sync.RWMutex
+	id      *Identity
+	current *tls.Certificate
+	// retired is the previous identity, still used by handshakes in
+	// progress, closed on the next renewal.
+	retired *Identity
+}
+
+// NewCertificateAgent returns an agent for the identity requested with
+// template, which must have a subject common name. Call Run to load and renew
+// it.
+func NewCertificateAgent(template *x509.CertificateRequest, enroller Enroller, policy RenewalPolicy) *CertificateAgent {
+	if policy.Retry <= 0 {
+		policy.Retry = DefaultRenewalRetry
+	}
+
+	if policy.Key.Type == 0 {
+		policy.Key.Type = KeyTypeEC
+	}
+
+	policy.Key.Permanent = true
+
+	return &CertificateAgent{
+		template: *template,
+		enroller: enroller,
+		policy:   policy,
+		trigger:  make(chan struct{}, 1),
+	}
+}
+
+// label returns the label of the identity.
+func (a *CertificateAgent) label() string {
+	return a.template.Subject.CommonName
+}
+
+// Certificate returns the certificate served, or nil.
+func (a *CertificateAgent) Certificate() *x509.Certificate {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.id == nil {
+		return nil
+	}
+
+	return a.id.Certificate()
+}
+
+// GetCertificate serves the identity as the certificate of a TLS server, see
+// tls.Config.
+func (a *CertificateAgent) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return a.tlsCertificate()
+}
+
+// GetClientCertificate serves the identity as the certificate of a TLS
+// client, see tls.Config.
+func (a *CertificateAgent) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return a.tlsCertificate()
+}
+
+func (a *CertificateAgent) tlsCertificate() (*tls.Certificate, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.current == nil {
+		return nil, ErrNoIdentity
+	}
+
+	return a.current, nil
+}
+
+// Load serves the identity in the keychain with the label of the agent that
+// expires last, with the intermediates of its chain found in the keychain, or
+// returns ErrorItemNotFound.
+func (a *CertificateAgent) Load() error {
+	query := NewItem()
+	query.SetLabel(a.label())
+
+	ids, err := QueryIdentities(query)
+	if err != nil {
+		return fmt.Errorf("failed to load the identity %q: %w", a.label(), err)
+	}
+
+	var latest *Identity
+
+	for _, id := range ids {
+		if latest == nil || id.Certificate().NotAfter.After(latest.Certificate().NotAfter) {
+			latest = id
+		}
+	}
+
+	for _, id := range ids {
+		if id != latest {
+			_ = id.Close()
+		}
+	}
+
+	if latest == nil {
+		return ErrorItemNotFound
+	}
+
+	a.serve(latest, issuersOf(latest.Certificate()))
+
+	return nil
+}
+
+// issuersOf returns the intermediates of the chain of cert found in the
+// keychain by the common names of their subjects. Roots aren't included.
+func issuersOf(cert *x509.Certificate) []*x509.Certificate {
+	var chain []*x509.Certificate
+
+	for len(chain) < maxChainLength && !bytes.Equal(cert.RawIssuer, cert.RawSubject) {
+		query := NewItem()
+		query.SetLabel(cert.Issuer.CommonName)
+
+		candidates, err := QueryCertificates(query)
+		if err != nil {
+			break
+		}
+
+		var issuer *x509.Certificate
+
+		for _, c := range candidates {
+			if bytes.Equal(c.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(c) == nil {
+				issuer = c
+
+				break
+			}
+		}
+
+		if issuer == nil || bytes.Equal(issuer.RawIssuer, issuer.RawSubject) {
+			break
+		}
+
+		chain = append(chain, issuer)
+		cert = issuer
+	}
+
+	return chain
+}
+
+// RenewNow enrolls a new identity and serves it.
+func (a *CertificateAgent) RenewNow(ctx context.Context) error {
+	key, err := GenerateKey(a.policy.Key)
+	if err != nil {
+		return fmt.Errorf("failed to create the key of %q: %w", a.label(), err)
+	}
+
+	id, chain, err := a.enroll(ctx, key)
+	if err != nil {
+		// The key is permanent: delete it so failed renewals don't pile up
+		// keys in the keychain.
+		_ = key.delete()
+
+		return err
+	}
+
+	a.serve(id, chain)
+
+	if a.policy.OnRenew != nil {
+		a.policy.OnRenew(id.Certificate())
+	}
+
+	return nil
+}
+
+// enroll requests a certificate for key and installs it with its
+// intermediates.
+func (a *CertificateAgent) enroll(ctx context.Context, key *Key) (*Identity, []*x509.Certificate, error) {
+	der, err := x509.CreateCertificateRequest(rand.Reader, &a.template, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create the certificate request of %q: %w", a.label(), err)
+	}
+
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid certificate request of %q: %w", a.label(), err)
+	}
+
+	certs, err := a.enroller.Enroll(ctx, csr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to enroll %q: %w", a.label(), err)
+	}
+
+	if len(certs) == 0 {
+		return nil, nil, fmt.Errorf("failed to enroll %q: no certificate issued", a.label())
+	}
+
+	if err := PairCertificateWithKey(certs[0], key); err != nil {
+		return nil, nil, fmt.Errorf("failed to install the certificate of %q: %w", a.label(), err)
+	}
+
+	for _, c := range certs[1:] {
+		if err := ImportCertificate(c.Raw); err != nil && !errors.Is(err, ErrorDuplicateItem) {
+			return nil, nil, fmt.Errorf("failed to add the intermediate %q: %w", c.Subject.CommonName, err)
+		}
+	}
+
+	return &Identity{cert: certs[0], key: key}, certs[1:], nil
+}
+
+// serve swaps the identity served, closing the one retired before.
+func (a *CertificateAgent) serve(id *Identity, chain []*x509.Certificate) {
+	c := id.TLSCertificate(chain...)
+
+	a.mu.Lock()
+	retired := a.retired
+	a.retired, a.id, a.current = a.id, id, &c
+	a.mu.Unlock()
+
+	if retired != nil {
+		_ = retired.Close()
+	}
+}
+
+// Trigger requests a renewal from Run without waiting for it.
+func (a *CertificateAgent) Trigger() {
+	select {
+	case a.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// renewalTime returns when cert is to be renewed.
+func renewalTime(cert *x509.Certificate, before time.Duration) time.Time {
+	if before <= 0 {
+		before = cert.NotAfter.Sub(cert.NotBefore) / 3
+	}
+
+	return cert.NotAfter.Add(-before)
+}
+
+// next returns how long to wait before the next renewal, loading the identity
+// first if none is served.
+func (a *CertificateAgent) next() (time.Duration, error) {
+	cert := a.Certificate()
+	if cert == nil {
+		err := a.Load()
+		if errors.Is(err, ErrorItemNotFound) {
+			return 0, nil
+		}
+
+		if err != nil {
+			return 0, err
+		}
+
+		cert = a.Certificate()
+	}
+
+	wait := time.Until(renewalTime(cert, a.policy.RenewBefore))
+	if wait < 0 {
+		return 0, nil
+	}
+
+	return wait, nil
+}
+
+// Run loads the identity from the keychain, or enrolls one if there is none,
+// and renews it as required by the policy and when triggered, until ctx is
+// done. Failed renewals are reported to the OnError of the policy and
+// retried, while the current identity is still served. A new certificate
+// already due for renewal, e.g. with a RenewBefore of at least its validity,
+// is reported with ErrRenewalDue and renewed again only after Retry.
+func (a *CertificateAgent) Run(ctx context.Context) error {
+	for {
+		wait, err := a.next()
+		if err == nil && wait == 0 {
+			if err = a.RenewNow(ctx); err == nil {
+				err = a.checkRenewed()
+			}
+
+			if err == nil {
+				continue
+			}
+		}
+
+		if err != nil {
+			a.reportError(err)
+
+			wait = a.policy.Retry
+		}
+
+		if err := a.wait(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// checkRenewed returns an error if the certificate served is already due for
+// renewal.
+func (a *CertificateAgent) checkRenewed() error {
+	cert := a.Certificate()
+	if cert == nil || time.Now().Before(renewalTime(cert, a.policy.RenewBefore)) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: the certificate of %q expires at %v", ErrRenewalDue, a.label(), cert.NotAfter)
+}
+
+// wait waits for d or a trigger, renewing on triggers.
+func (a *CertificateAgent) wait(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-a.trigger:
+		if err := a.RenewNow(ctx); err != nil {
+			a.reportError(err)
+		}
+	case <-t.C:
+	}
+
+	return nil
+}
+
+func (a *CertificateAgent) reportError(err error) {
+	if a.policy.OnError != nil {
+		a.policy.OnError(err)
+	}
+}
+
+// Close releases the private keys of the identities of the agent, once Run
+// returned. Its TLS callbacks fail with ErrNoIdentity from then on.
+func (a *CertificateAgent) Close() error {
+	a.mu.Lock()
+	ids := []*Identity{a.id, a.retired}
+	a.id, a.retired, a.current = nil, nil, nil
+	a.mu.Unlock()
+
+	for _, id := range ids {
+		if id != nil {
+			_ = id.Close()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package keychain
+
+import (
+	"crypto/x509"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRenewalTime(t *testing.T) {
+	notBefore := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cert := &x509.Certificate{NotBefore: notBefore, NotAfter: notBefore.Add(90 * 24 * time.Hour)}
+
+	if got, want := renewalTime(cert, 0), notBefore.Add(60*24*time.Hour); !got.Equal(want) {
+		t.Fatalf("expected renewal after two thirds of the validity at %v, got %v", want, got)
+	}
+
+	if got, want := renewalTime(cert, 24*time.Hour), notBefore.Add(89*24*time.Hour); !got.Equal(want) {
+		t.Fatalf("expected renewal a day before expiry at %v, got %v", want, got)
+	}
+}
+
+func TestCertificateAgentWithoutIdentity(t *testing.T) {
+	template := &x509.CertificateRequest{}
+	template.Subject.CommonName = "TestCertificateAgentWithoutIdentity"
+
+	agent := NewCertificateAgent(template, nil, RenewalPolicy{})
+
+	if _, err := agent.GetCertificate(nil); !errors.Is(err, ErrNoIdentity) {
+		t.Fatalf("expected ErrNoIdentity, got %v", err)
+	}
+
+	if agent.Certificate() != nil {
+		t.Fatal("expected no certificate")
+	}
+}
+
+func TestCertificateAgentRenewalDue(t *testing.T) {
+	template := &x509.CertificateRequest{}
+	template.Subject.CommonName = "TestCertificateAgentRenewalDue"
+
+	notBefore := time.Now().Add(-time.Hour)
+	cert := &x509.Certificate{NotBefore: notBefore, NotAfter: notBefore.Add(24 * time.Hour)}
+
+	agent := NewCertificateAgent(template, nil, RenewalPolicy{RenewBefore: 48 * time.Hour})
+	agent.id = &Identity{cert: cert}
+
+	if err := agent.checkRenewed(); !errors.Is(err, ErrRenewalDue) {
+		t.Fatalf("expected ErrRenewalDue, got %v", err)
+	}
+
+	agent.policy.RenewBefore = time.Hour
+
+	if err := agent.checkRenewed(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,138 @@
+package keychain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTooManyResults is matched (with errors.Is) by TooManyResultsError.
+var ErrTooManyResults = errors.New("too many results")
+
+// TooManyResultsError is returned when a query expected to match a single item
+// matches several.
+type TooManyResultsError struct {
+	// Candidates are the attributes (without data) of the matching items.
+	Candidates []QueryResult
+}
+
+func newTooManyResultsError(results []QueryResult) *TooManyResultsError {
+	candidates := make([]QueryResult, len(results))
+	for i, r := range results {
+		r.Data = nil
+		candidates[i] = r
+	}
+
+	return &TooManyResultsError{Candidates: candidates}
+}
+
+func (e *TooManyResultsError) Error() string {
+	return fmt.Sprintf("%s: %d items match", ErrTooManyResults, len(e.Candidates))
+}
+
+// Is reports whether target is ErrTooManyResults.
+func (e *TooManyResultsError) Is(target error) bool {
+	return target == ErrTooManyResults //nolint:errorlint
+}
+
+// Resolver picks one of several items matching a query, or returns an error
+// if it can't.
+type Resolver func(candidates []QueryResult) (QueryResult, error)
+
+// PickNewest resolves to the most recently modified candidate.
+func PickNewest(candidates []QueryResult) (QueryResult, error) {
+	if len(candidates) == 0 {
+		return QueryResult{}, ErrorItemNotFound
+	}
+
+	newest := candidates[0]
+	for _, c := range candidates[1:] {
+		if c.ModificationDate.After(newest.ModificationDate) {
+			newest = c
+		}
+	}
+
+	return newest, nil
+}
+
+// PickByLabel resolves to the only candidate with the given label.
+func PickByLabel(label string) Resolver {
+	return func(candidates []QueryResult) (QueryResult, error) {
+		var matches []QueryResult
+
+		for _, c := range candidates {
+			if c.Label == label {
+				matches = append(matches, c)
+			}
+		}
+
+		switch len(matches) {
+		case 0:
+			return QueryResult{}, fmt.Errorf("no candidate with label %q: %w", label, ErrorItemNotFound)
+		case 1:
+			return matches[0], nil
+		default:
+			return QueryResult{}, newTooManyResultsError(matches)
+		}
+	}
+}
+
+// GetGenericPasswordResolved returns password data for service and account
+// like GetGenericPassword, using resolve to pick an item when several match
+// (e.g. in different access groups or keychains). With a nil resolve, a
+// TooManyResultsError is returned instead. If no item is found returns nil,
+// nil.
+func GetGenericPasswordResolved(service string, account string, accessGroup string, resolve Resolver) ([]byte, error) {
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService(service)
+	query.SetAccount(account)
+
+	if accessGroup != "" {
+		query.SetAccessGroup(accessGroup)
+	}
+
+	query.SetReturnPersistentRef(true)
+
+	candidates, err := queryAttributes(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var chosen QueryResult
+
+	switch {
+	case len(candidates) == 0:
+		return nil, nil
+	case len(candidates) == 1:
+		chosen = candidates[0]
+	case resolve == nil:
+		return nil, newTooManyResultsError(candidates)
+	default:
+		if chosen, err = resolve(candidates); err != nil {
+			return nil, err
+		}
+	}
+
+	ref, err := resultRef(chosen)
+	if err != nil {
+		return nil, err
+	}
+
+	dataQuery := newItem()
+	dataQuery.SetSecClass(SecClassGenericPassword)
+	dataQuery.SetPersistentRef(ref)
+	dataQuery.SetMatchLimit(MatchLimitOne)
+	dataQuery.SetReturnAttributes(true)
+	dataQuery.SetReturnData(true)
+
+	results, err := QueryItem(dataQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) != 1 {
+		return nil, nil
+	}
+
+	return results[0].Data, nil
+}
@@ -0,0 +1,40 @@
+//go:build darwin && !ios
+// +build darwin,!ios
+
+package keychain
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResolvers(t *testing.T) {
+	now := time.Now()
+	candidates := []QueryResult{
+		{Account: "a", Label: "old", ModificationDate: now.Add(-time.Hour)},
+		{Account: "b", Label: "new", ModificationDate: now},
+		{Account: "c", Label: "new", ModificationDate: now.Add(-time.Minute)},
+	}
+
+	r, err := PickNewest(candidates)
+	if err != nil || r.Account != "b" {
+		t.Fatalf("expected newest candidate b, got %+v (%v)", r, err)
+	}
+
+	r, err = PickByLabel("old")(candidates)
+	if err != nil || r.Account != "a" {
+		t.Fatalf("expected candidate a, got %+v (%v)", r, err)
+	}
+
+	_, err = PickByLabel("new")(candidates)
+	var tooMany *TooManyResultsError
+	if !errors.As(err, &tooMany) || len(tooMany.Candidates) != 2 || !errors.Is(err, ErrTooManyResults) {
+		t.Fatalf("expected TooManyResultsError with 2 candidates, got %v", err)
+	}
+
+	_, err = PickByLabel("missing")(candidates)
+	if !errors.Is(err, ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package keychain
+
+import (
+	"crypto/x509"
+	"fmt"
+	"time"
+)
+
+// GenericPasswordResult is a generic password returned by
+// QueryGenericPasswordItems, with only the fields of generic passwords.
+type GenericPasswordResult struct {
+	Service          string
+	Account          string
+	AccessGroup      string
+	Label            string
+	Description      string
+	Comment          string
+	Type             string
+	Creator          string
+	Generic          []byte
+	Data             []byte
+	CreationDate     time.Time
+	ModificationDate time.Time
+	Accessible       Accessible
+	ID               string
+	PersistentRef    []byte
+}
+
+// InternetPasswordResult is an internet password returned by
+// QueryInternetPasswordItems, with only the fields of internet passwords.
+type InternetPasswordResult struct {
+	Server             string
+	Protocol           string
+	AuthenticationType string
+	Port               int32
+	Path               string
+	Account            string
+	AccessGroup        string
+	Label              string
+	Description        string
+	Comment            string
+	Type               string
+	Creator            string
+	Data               []byte
+	CreationDate       time.Time
+	ModificationDate   time.Time
+	Accessible         Accessible
+	ID                 string
+	PersistentRef      []byte
+}
+
+// CertificateResult is a certificate returned by QueryCertificateItems.
+type CertificateResult struct {
+	Label         string
+	AccessGroup   string
+	Certificate   *x509.Certificate
+	PersistentRef []byte
+}
+
+// KeyResult is a key item returned by QueryKeyItems. Use LoadKey with the
+// application tag to sign or decrypt with the key.
+type KeyResult struct {
+	Label          string
+	ApplicationTag []byte
+	KeyType        KeyType
+	Bits           int
+	AccessGroup    string
+	PersistentRef  []byte
+}
+
+// queryClass queries the items of class matching query, with their attributes
+// and persistent references, and all matching items unless query sets a match
+// limit.
+func queryClass(class SecClass, query Item) ([]QueryResult, error) {
+	q := query.clone()
+	q.SetSecClass(class)
+	q.SetReturnAttributes(true)
+	q.SetReturnPersistentRef(true)
+
+	if _, ok := q.attr[MatchLimitKey]; !ok {
+		q.SetMatchLimit(MatchLimitAll)
+	}
+
+	return QueryItem(q)
+}
+
+// QueryGenericPasswordItems returns the generic passwords matching query. The
+// class of query is set, and all matching items are returned unless query
+// sets a match limit. Data is only returned if query enables SetReturnData,
+// which macOS only supports with MatchLimitOne.
+func QueryGenericPasswordItems(query Item) ([]GenericPasswordResult, error) {
+	results, err := queryClass(SecClassGenericPassword, query)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]GenericPasswordResult, 0, len(results))
+
+	for _, r := range results {
+		items = append(items, GenericPasswordResult{
+			Service:          r.Service,
+			Account:          r.Account,
+			AccessGroup:      r.AccessGroup,
+			Label:            r.Label,
+			Description:      r.Description,
+			Comment:          r.Comment,
+			Type:             r.Type,
+			Creator:          r.Creator,
+			Generic:          r.Generic,
+			Data:             r.Data,
+			CreationDate:     r.CreationDate,
+			ModificationDate: r.ModificationDate,
+			Accessible:       r.Accessible,
+			ID:               r.ID,
+			PersistentRef:    r.PersistentRef,
+		})
+	}
+
+	return items, nil
+}
+
+// QueryInternetPasswordItems returns the internet passwords matching query,
+// like QueryGenericPasswordItems.
+func QueryInternetPasswordItems(query Item) ([]InternetPasswordResult, error) {
+	results, err := queryClass(SecClassInternetPassword, query)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]InternetPasswordResult, 0, len(results))
+	for _, r := range results {
+		items = append(items, internetPasswordResult(r))
+	}
+
+	return items, nil
+}
+
+// internetPasswordResult returns the internet password fields of r.
+func internetPasswordResult(r QueryResult) InternetPasswordResult {
+	return InternetPasswordResult{
+		Server:             r.Server,
+		Protocol:           r.Protocol,
+		AuthenticationType: r.AuthenticationType,
+		Port:               r.Port,
+		Path:               r.Path,
+		Account:            r.Account,
+		AccessGroup:        r.AccessGroup,
+		Label:              r.Label,
+		Description:        r.Description,
+		Comment:            r.Comment,
+		Type:               r.Type,
+		Creator:            r.Creator,
+		Data:               r.Data,
+		CreationDate:       r.CreationDate,
+		ModificationDate:   r.ModificationDate,
+		Accessible:         r.Accessible,
+		ID:                 r.ID,
+		PersistentRef:      r.PersistentRef,
+	}
+}
+
+// QueryCertificateItems returns the certificates matching query, with their
+// attributes, see QueryCertificates.
+func QueryCertificateItems(query Item) ([]CertificateResult, error) {
+	q := query.clone()
+	delete(q.attr, ReturnDataKey)
+
+	results, err := queryClass(SecClassCertificate, q)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]CertificateResult, 0, len(results))
+
+	for _, r := range results {
+		ref, err := resultRef(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read certificate %q: %w", r.Label, err)
+		}
+
+		dataQuery := newItem()
+		dataQuery.SetSecClass(SecClassCertificate)
+		dataQuery.SetPersistentRef(ref)
+		dataQuery.SetMatchLimit(MatchLimitOne)
+		dataQuery.SetReturnData(true)
+
+		dataResults, err := QueryItem(dataQuery)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read certificate %q: %w", r.Label, err)
+		}
+
+		if len(dataResults) != 1 {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(dataResults[0].Data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificate %q in keychain: %w", r.Label, err)
+		}
+
+		items = append(items, CertificateResult{
+			Label:         r.Label,
+			AccessGroup:   r.AccessGroup,
+			Certificate:   cert,
+			PersistentRef: r.PersistentRef,
+		})
+	}
+
+	return items, nil
+}
+
+// QueryKeyItems returns the key items matching query, e.g. the permanent keys
+// created by GenerateKey. The class of query is set, and all matching items
+// are returned unless query sets a match limit.
+func QueryKeyItems(query Item) ([]KeyResult, error) {
+	q := query.clone()
+	delete(q.attr, ReturnDataKey)
+
+	results, err := queryClass(SecClassPairKey, q)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]KeyResult, 0, len(results))
+
+	for _, r := range results {
+		items = append(items, KeyResult{
+			Label:          r.Label,
+			ApplicationTag: r.ApplicationTag,
+			KeyType:        r.KeyType,
+			Bits:           r.KeySizeInBits,
+			AccessGroup:    r.AccessGroup,
+			PersistentRef:  r.PersistentRef,
+		})
+	}
+
+	return items, nil
+}
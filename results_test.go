@@ -0,0 +1,86 @@
+package keychain
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestQueryGenericPasswordItems(t *testing.T) {
+	f := useFakeBackend(t, fakeResponse{result: []interface{}{
+		map[string]interface{}{ServiceKey: "MyService", AccountKey: "gabriel", ServerKey: "ignored", ValuePersistentRefKey: []byte("ref")},
+		map[string]interface{}{ServiceKey: "MyService", AccountKey: "alice"},
+	}})
+
+	query := NewItem()
+	query.SetService("MyService")
+
+	items, err := QueryGenericPasswordItems(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []GenericPasswordResult{
+		{Service: "MyService", Account: "gabriel", ID: ItemID(SecClassGenericPassword, "MyService", "gabriel", ""), PersistentRef: []byte("ref")},
+		{Service: "MyService", Account: "alice", ID: ItemID(SecClassGenericPassword, "MyService", "alice", "")},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Fatalf("unexpected items: %+v", items)
+	}
+
+	attrs := f.calls[0].query
+	if attrs[SecClassKey] != "genp" || attrs[MatchLimitKey] != "m_LimitAll" || attrs[ReturnAttributesKey] != true {
+		t.Fatalf("unexpected query: %v", attrs)
+	}
+}
+
+func TestQueryInternetPasswordItems(t *testing.T) {
+	useFakeBackend(t, fakeResponse{result: map[string]interface{}{ServerKey: "example.com", PortKey: int32(443), AccountKey: "gabriel"}})
+
+	items, err := QueryInternetPasswordItems(NewItem())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 1 || items[0].Server != "example.com" || items[0].Port != 443 || items[0].Account != "gabriel" {
+		t.Fatalf("unexpected items: %+v", items)
+	}
+}
+
+func TestQueryCertificateItemsWithoutRef(t *testing.T) {
+	f := useFakeBackend(t, fakeResponse{result: map[string]interface{}{LabelKey: "example.com"}})
+
+	// Reading the data without a persistent reference would match any
+	// certificate.
+	if _, err := QueryCertificateItems(NewItem()); !errors.Is(err, ErrNoPersistentRef) {
+		t.Fatalf("expected ErrNoPersistentRef, got %v", err)
+	}
+
+	if ops := f.ops(); len(ops) != 1 {
+		t.Fatalf("expected no data query, got %v", ops)
+	}
+}
+
+func TestQueryKeyItems(t *testing.T) {
+	f := useFakeBackend(t, fakeResponse{result: []interface{}{
+		map[string]interface{}{LabelKey: "signing", ApplicationTagKey: []byte("com.example.signing"), TypeKey: "73", KeySizeInBitsKey: int64(256)},
+		map[string]interface{}{ApplicationTagKey: []byte("com.example.rsa"), TypeKey: int32(42), KeySizeInBitsKey: int32(2048)},
+	}})
+
+	items, err := QueryKeyItems(NewItem())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []KeyResult{
+		{Label: "signing", ApplicationTag: []byte("com.example.signing"), KeyType: KeyTypeEC, Bits: 256},
+		{ApplicationTag: []byte("com.example.rsa"), KeyType: KeyTypeRSA, Bits: 2048},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Fatalf("unexpected items: %+v", items)
+	}
+
+	if attrs := f.calls[0].query; attrs[SecClassKey] != "keys" {
+		t.Fatalf("unexpected query: %v", attrs)
+	}
+}
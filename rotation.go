@@ -0,0 +1,272 @@
+package keychain
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// Metadata keys written by a Rotator.
+const (
+	// MetadataRotatedKey is the time of the last rotation, in RFC 3339 format.
+	MetadataRotatedKey = "rotated"
+	// MetadataGenerationKey is the number of rotations.
+	MetadataGenerationKey = "generation"
+)
+
+// DefaultRotationRetry is how long a Rotator waits after a failed rotation
+// before trying again.
+const DefaultRotationRetry = time.Minute
+
+// ErrRotationConflict is returned when an item changed while it was being
+// rotated. The rotated value is discarded.
+var ErrRotationConflict = errors.New("item changed during rotation")
+
+// RotationPolicy is when a Rotator rotates a secret.
+type RotationPolicy struct {
+	// MaxAge is the age after which the secret is rotated, measured from the
+	// last rotation (or the modification of the item, if it was never
+	// rotated). Zero rotates only on demand, see Rotator.Trigger.
+	MaxAge time.Duration
+	// Jitter is the maximum random delay added to MaxAge, so that processes
+	// sharing a secret don't all rotate it at the same moment.
+	Jitter time.Duration
+	// Retry is the delay before retrying a failed rotation. It defaults to
+	// DefaultRotationRetry.
+	Retry time.Duration
+	// OnError, if set, is called with the errors of background rotations.
+	OnError func(error)
+}
+
+// Rotator rotates the secret in a generic password: it passes the current
+// data to a rotate function (which e.g. issues a new API token and revokes the
+// old one) and stores the result. Writes only succeed if the item is
+// unchanged since it was read, so concurrent rotations by several processes
+// store a single new value. Watchers of the item (see WatchItem) are notified
+// after each rotation.
+type Rotator struct {
+	service     string
+	account     string
+	accessGroup string
+	policy      RotationPolicy
+	rotate      func(old []byte) ([]byte, error)
+	trigger     chan struct{}
+}
+
+// NewRotator returns a Rotator for the generic password for service and
+// account.
+func NewRotator(service, account string, policy RotationPolicy, rotate func(old []byte) ([]byte, error)) *Rotator {
+	if policy.Retry <= 0 {
+		policy.Retry = DefaultRotationRetry
+	}
+
+	return &Rotator{
+		service: service,
+		account: account,
+		policy:  policy,
+		rotate:  rotate,
+		trigger: make(chan struct{}, 1),
+	}
+}
+
+// SetAccessGroup sets the access group of the item.
+func (r *Rotator) SetAccessGroup(accessGroup string) {
+	r.accessGroup = accessGroup
+}
+
+func (r *Rotator) query() Item {
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService(r.service)
+	query.SetAccount(r.account)
+
+	if r.accessGroup != "" {
+		query.SetAccessGroup(r.accessGroup)
+	}
+
+	return query
+}
+
+// read returns the current item.
+func (r *Rotator) read() (QueryResult, error) {
+	query := r.query()
+	query.SetMatchLimit(MatchLimitOne)
+	query.SetReturnAttributes(true)
+	query.SetReturnData(true)
+
+	results, err := QueryItem(query)
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	if len(results) == 0 {
+		return QueryResult{}, ErrorItemNotFound
+	}
+
+	return results[0], nil
+}
+
+// rotatedAt returns when the item was last rotated.
+func rotatedAt(item QueryResult) time.Time {
+	if t, err := time.Parse(time.RFC3339Nano, item.Metadata()[MetadataRotatedKey]); err == nil {
+		return t
+	}
+
+	return item.ModificationDate
+}
+
+// RotateNow rotates the secret, returning ErrRotationConflict if the item
+// changed meanwhile.
+func (r *Rotator) RotateNow() error {
+	old, err := r.read()
+	if err != nil {
+		return fmt.Errorf("failed to read %q: %w", r.account, err)
+	}
+
+	data, err := r.rotate(old.Data)
+	if err != nil {
+		return fmt.Errorf("failed to rotate %q: %w", r.account, err)
+	}
+
+	m := Metadata{}
+	for k, v := range old.Metadata() {
+		m[k] = v
+	}
+
+	generation, _ := strconv.Atoi(m[MetadataGenerationKey])
+	m[MetadataGenerationKey] = strconv.Itoa(generation + 1)
+	m[MetadataRotatedKey] = time.Now().UTC().Format(time.RFC3339Nano)
+
+	if _, ok := m[MetadataChecksumKey]; ok {
+		sum := sha256.Sum256(data)
+		m[MetadataChecksumKey] = hex.EncodeToString(sum[:])
+	}
+
+	update := newItem()
+	update.SetData(data)
+
+	if err := update.SetMetadata(m); err != nil {
+		return err
+	}
+
+	// The generic attribute changes with every rotation, so matching it makes
+	// the update fail if another process rotated the item meanwhile. Other
+	// writes are detected by comparing the data first.
+	query := r.query()
+	query.SetGeneric(old.Generic)
+
+	current, err := r.read()
+	if err != nil {
+		return fmt.Errorf("failed to read %q: %w", r.account, err)
+	}
+
+	if !bytes.Equal(current.Data, old.Data) || !bytes.Equal(current.Generic, old.Generic) {
+		return ErrRotationConflict
+	}
+
+	err = UpdateItem(query, update)
+	if errors.Is(err, ErrorItemNotFound) {
+		return ErrRotationConflict
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to write %q: %w", r.account, err)
+	}
+
+	notifyChange(r.service, r.account)
+
+	return nil
+}
+
+// Trigger requests a rotation from Run without waiting for it.
+func (r *Rotator) Trigger() {
+	select {
+	case r.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// next returns how long to wait before the next rotation.
+func (r *Rotator) next() (time.Duration, error) {
+	if r.policy.MaxAge <= 0 {
+		return -1, nil
+	}
+
+	item, err := r.read()
+	if err != nil {
+		return 0, err
+	}
+
+	wait := time.Until(rotatedAt(item).Add(r.policy.MaxAge))
+	if wait <= 0 {
+		return 0, nil
+	}
+
+	if r.policy.Jitter > 0 {
+		wait += time.Duration(rand.Int63n(int64(r.policy.Jitter))) // nolint:gosec
+	}
+
+	return wait, nil
+}
+
+// Run rotates the secret as required by the policy and when triggered, until
+// ctx is done. Failed rotations are reported to the OnError of the policy and
+// retried.
+func (r *Rotator) Run(ctx context.Context) error {
+	for {
+		wait, err := r.next()
+		if err == nil && wait == 0 {
+			err = r.RotateNow()
+			if err == nil || errors.Is(err, ErrRotationConflict) {
+				// Another writer may have rotated it; recompute.
+				continue
+			}
+		}
+
+		if err != nil {
+			r.reportError(err)
+
+			wait = r.policy.Retry
+		}
+
+		if err := r.wait(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// wait waits for d (forever if negative) or a trigger, rotating on triggers.
+func (r *Rotator) wait(ctx context.Context, d time.Duration) error {
+	var timer <-chan time.Time
+
+	if d >= 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+
+		timer = t.C
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-r.trigger:
+		if err := r.RotateNow(); err != nil {
+			r.reportError(err)
+		}
+	case <-timer:
+	}
+
+	return nil
+}
+
+func (r *Rotator) reportError(err error) {
+	if r.policy.OnError != nil {
+		r.policy.OnError(err)
+	}
+}
@@ -0,0 +1,77 @@
+package keychain_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func TestRotator(t *testing.T) {
+	keychainfake.Install(t)
+
+	if err := keychain.SetString("MyService", "token", "v0"); err != nil {
+		t.Fatal(err)
+	}
+
+	rotations := 0
+	r := keychain.NewRotator("MyService", "token", keychain.RotationPolicy{MaxAge: 50 * time.Millisecond},
+		func(old []byte) ([]byte, error) {
+			rotations++
+
+			return append(old, '+'), nil
+		})
+
+	updates, cancel := keychain.WatchItem("MyService", "token")
+	defer cancel()
+
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() { done <- r.Run(ctx) }()
+
+	for _, want := range []string{"v0+", "v0++"} {
+		select {
+		case u := <-updates:
+			if string(u.Data) != want || u.Metadata()[keychain.MetadataRotatedKey] == "" {
+				t.Fatalf("expected %s, got %s (%v)", want, u.Data, u.Metadata())
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no rotation to %s", want)
+		}
+	}
+
+	stop()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+
+	if rotations < 2 {
+		t.Fatalf("expected 2 rotations, got %d", rotations)
+	}
+}
+
+func TestRotatorConflict(t *testing.T) {
+	keychainfake.Install(t)
+
+	if err := keychain.SetString("MyService", "token", "v0"); err != nil {
+		t.Fatal(err)
+	}
+
+	r := keychain.NewRotator("MyService", "token", keychain.RotationPolicy{}, func(old []byte) ([]byte, error) {
+		// Another process writes while the new value is issued.
+		return []byte("mine"), keychain.SetString("MyService", "token", "theirs")
+	})
+
+	if err := r.RotateNow(); !errors.Is(err, keychain.ErrRotationConflict) {
+		t.Fatalf("expected ErrRotationConflict, got %v", err)
+	}
+
+	if s, _ := keychain.GetString("MyService", "token"); s != "theirs" {
+		t.Fatalf("concurrent write overwritten with %q", s)
+	}
+}
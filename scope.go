@@ -0,0 +1,258 @@
+package keychain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrScopeDenied is returned (wrapped in a *ScopeError) for operations outside
+// of the rules of a Scoped keychain.
+var ErrScopeDenied = errors.New("operation outside of keychain scope")
+
+// ScopeOp is a set of operations allowed by a Rule.
+type ScopeOp int
+
+const (
+	// ScopeRead allows queries.
+	ScopeRead ScopeOp = 1 << iota
+	// ScopeAdd allows adding items.
+	ScopeAdd
+	// ScopeUpdate allows updating items.
+	ScopeUpdate
+	// ScopeDelete allows deleting items.
+	ScopeDelete
+
+	// ScopeWrite allows adding, updating and deleting items.
+	ScopeWrite = ScopeAdd | ScopeUpdate | ScopeDelete
+	// ScopeAll allows all operations.
+	ScopeAll = ScopeRead | ScopeWrite
+)
+
+var scopeOpNames = []string{"read", "add", "update", "delete"}
+
+func (op ScopeOp) String() string {
+	var names []string
+
+	for i, name := range scopeOpNames {
+		if op&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 || op > ScopeAll {
+		return fmt.Sprintf("ScopeOp(%d)", int(op))
+	}
+
+	return strings.Join(names, "+")
+}
+
+// Rule allows operations on the items of a class, service and account. Empty
+// fields match any value, and a Service or Account ending with "*" matches
+// the values with that prefix. A query or change must target the service and
+// account of the rule: a query without a service isn't allowed by a rule
+// with a Service, and queries by persistent reference are only allowed by
+// rules matching any item.
+type Rule struct {
+	Class   SecClass
+	Service string
+	Account string
+	Ops     ScopeOp
+}
+
+func (r Rule) allows(op ScopeOp, attrs map[string]interface{}) bool {
+	if r.Ops&op != op {
+		return false
+	}
+
+	if hasRefs(attrs) && (r.Class != 0 || r.Service != "" || r.Account != "") {
+		return false
+	}
+
+	if r.Class != 0 && attrs[SecClassKey] != secClassValues[r.Class] {
+		return false
+	}
+
+	return matchPattern(r.Service, attrs[ServiceKey]) && matchPattern(r.Account, attrs[AccountKey])
+}
+
+// hasRefs returns whether attrs target items by persistent reference.
+func hasRefs(attrs map[string]interface{}) bool {
+	_, ref := attrs[ValuePersistentRefKey]
+	_, list := attrs[MatchItemListKey]
+
+	return ref || list
+}
+
+func matchPattern(pattern string, v interface{}) bool {
+	if pattern == "" {
+		return true
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+
+	s = norm.NFC.String(s)
+
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(s, prefix)
+	}
+
+	return s == pattern
+}
+
+// ScopeError describes an operation denied by a Scoped keychain.
+type ScopeError struct {
+	Op      ScopeOp
+	Service string
+	Account string
+}
+
+func (e *ScopeError) Error() string {
+	return fmt.Sprintf("%s of service %q, account %q: %v", e.Op, e.Service, e.Account, ErrScopeDenied)
+}
+
+// Is reports whether target is ErrScopeDenied.
+func (e *ScopeError) Is(target error) bool {
+	return target == ErrScopeDenied
+}
+
+// Scoped is a restricted keychain handle, allowing only the operations of its
+// rules, e.g. for a host application to hand to plugins instead of the full
+// package API. Operations outside of the rules fail with a *ScopeError before
+// reaching the backend.
+type Scoped struct {
+	rules []Rule
+}
+
+// Scope returns a keychain handle allowing only the operations of the rules.
+func Scope(allow ...Rule) *Scoped {
+	return &Scoped{rules: append([]Rule(nil), allow...)}
+}
+
+// check returns a *ScopeError unless a rule allows op on the items with attrs.
+func (s *Scoped) check(op ScopeOp, attrs map[string]interface{}) error {
+	for _, r := range s.rules {
+		if r.allows(op, attrs) {
+			return nil
+		}
+	}
+
+	return &ScopeError{Op: op, Service: stringAttr(attrs, ServiceKey), Account: stringAttr(attrs, AccountKey)}
+}
+
+func (s *Scoped) checkItem(op ScopeOp, item Item) error {
+	attrs, err := EncodeAttributes(item)
+	if err != nil {
+		return fmt.Errorf("failed to encode item attributes: %w", err)
+	}
+
+	return s.check(op, attrs)
+}
+
+func genericPasswordAttrs(service, account string) map[string]interface{} {
+	return map[string]interface{}{
+		SecClassKey: secClassValues[SecClassGenericPassword],
+		ServiceKey:  service,
+		AccountKey:  account,
+	}
+}
+
+// AddItem adds item, see the package function.
+func (s *Scoped) AddItem(item Item) error {
+	if err := s.checkItem(ScopeAdd, item); err != nil {
+		return err
+	}
+
+	return AddItem(item)
+}
+
+// QueryItem returns the items matching query, see the package function.
+func (s *Scoped) QueryItem(query Item) ([]QueryResult, error) {
+	if err := s.checkItem(ScopeRead, query); err != nil {
+		return nil, err
+	}
+
+	return QueryItem(query)
+}
+
+// ItemExists returns whether an item matching query exists, see the package
+// function.
+func (s *Scoped) ItemExists(query Item) (bool, error) {
+	if err := s.checkItem(ScopeRead, query); err != nil {
+		return false, err
+	}
+
+	return ItemExists(query)
+}
+
+// UpdateItem updates the items matching query, see the package function. The
+// updated items must be in scope as well, so items can't be moved out of it.
+func (s *Scoped) UpdateItem(query Item, update Item) error {
+	queryAttrs, err := EncodeAttributes(query)
+	if err != nil {
+		return fmt.Errorf("failed to encode query item attributes: %w", err)
+	}
+
+	if err := s.check(ScopeUpdate, queryAttrs); err != nil {
+		return err
+	}
+
+	attrs, err := EncodeAttributes(update)
+	if err != nil {
+		return fmt.Errorf("failed to encode update item attributes: %w", err)
+	}
+
+	for key, v := range attrs {
+		queryAttrs[key] = v
+	}
+
+	if err := s.check(ScopeUpdate, queryAttrs); err != nil {
+		return err
+	}
+
+	return UpdateItem(query, update)
+}
+
+// DeleteItem deletes the items matching item, see the package function.
+func (s *Scoped) DeleteItem(item Item) error {
+	if err := s.checkItem(ScopeDelete, item); err != nil {
+		return err
+	}
+
+	return DeleteItem(item)
+}
+
+// GetBytes returns the data of the generic password for service and account,
+// see the package function.
+func (s *Scoped) GetBytes(service, account string) ([]byte, error) {
+	if err := s.check(ScopeRead, genericPasswordAttrs(service, account)); err != nil {
+		return nil, err
+	}
+
+	return GetBytes(service, account)
+}
+
+// SetBytes stores data in the generic password for service and account, see
+// the package function. It needs both ScopeAdd and ScopeUpdate.
+func (s *Scoped) SetBytes(service, account string, data []byte) error {
+	if err := s.check(ScopeAdd|ScopeUpdate, genericPasswordAttrs(service, account)); err != nil {
+		return err
+	}
+
+	return SetBytes(service, account, data)
+}
+
+// DeleteGenericPasswordItem deletes the generic password for service and
+// account, see the package function.
+func (s *Scoped) DeleteGenericPasswordItem(service, account string) error {
+	if err := s.check(ScopeDelete, genericPasswordAttrs(service, account)); err != nil {
+		return err
+	}
+
+	return DeleteGenericPasswordItem(service, account)
+}
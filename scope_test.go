@@ -0,0 +1,71 @@
+package keychain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestScope(t *testing.T) {
+	existing := map[string]interface{}{ServiceKey: "plugin.weather", AccountKey: "api-key"}
+	f := useFakeBackend(t, fakeResponse{result: existing}, fakeResponse{}, fakeResponse{result: map[string]interface{}{DataKey: []byte("{}")}})
+
+	s := Scope(
+		Rule{Class: SecClassGenericPassword, Service: "plugin.weather", Ops: ScopeAll},
+		Rule{Service: "shared.*", Account: "config", Ops: ScopeRead},
+	)
+
+	if err := s.SetBytes("plugin.weather", "api-key", []byte("toomanysecrets")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.GetBytes("shared.settings", "config"); err != nil {
+		t.Fatal(err)
+	}
+
+	denied := func(name string, err error) {
+		t.Helper()
+
+		var scopeErr *ScopeError
+		if !errors.Is(err, ErrScopeDenied) || !errors.As(err, &scopeErr) {
+			t.Errorf("%s: expected ErrScopeDenied, got %v", name, err)
+		}
+	}
+
+	denied("other service", s.SetBytes("MyService", "gabriel", []byte("a")))
+	denied("read only", s.DeleteGenericPasswordItem("shared.settings", "config"))
+	denied("other account", func() error { _, err := s.GetBytes("shared.settings", "token"); return err }())
+
+	// Queries must name a service in scope.
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetReturnAttributes(true)
+	query.SetMatchLimit(MatchLimitAll)
+
+	_, err := s.QueryItem(query)
+	denied("query without service", err)
+
+	query.SetService("plugin.weather")
+
+	if _, err := s.QueryItem(query); err != nil {
+		t.Fatal(err)
+	}
+
+	// Items can't be moved out of scope.
+	update := newItem()
+	update.SetService("MyService")
+	denied("move", s.UpdateItem(query, update))
+
+	byRef := NewItem()
+	byRef.SetPersistentRef([]byte("ref"))
+
+	_, err = s.QueryItem(byRef)
+	denied("persistent ref", err)
+
+	if n := len(f.calls); n != 4 {
+		t.Fatalf("expected 4 backend calls, got %d: %v", n, f.ops())
+	}
+
+	if got := (ScopeAdd | ScopeUpdate).String(); got != "add+update" {
+		t.Fatalf("unexpected op name %q", got)
+	}
+}
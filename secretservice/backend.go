@@ -0,0 +1,29 @@
+// Package secretservice is a client of the freedesktop.org Secret Service
+// D-Bus API (gnome-keyring, KWallet, KeePassXC) and implements a keychain
+// backend over it on Linux.
+//
+// Importing the package registers the backend, which the keychain package
+// then uses by default on Linux:
+//
+//	import _ "github.com/mailstone/go-keychain/secretservice"
+//
+//	data, err := keychain.GetGenericPassword("MyService", "gabriel", "", "")
+//
+// Each generic password is an item of the default collection, with its
+// service, account, label, description and comment as attributes
+// (keychain-service, keychain-account, ...), so other Secret Service clients
+// such as secret-tool can find it.
+package secretservice
+
+// Name is the name the backend is registered under, see keychain.UseBackend.
+const Name = "secretservice"
+
+// NameAttribute is the attribute identifying the items of the backend.
+const NameAttribute = "keychain-name"
+
+// SchemaAttribute is the libsecret schema attribute of the items of the
+// backend.
+const SchemaAttribute = "xdg:schema"
+
+// Schema is the libsecret schema of the items of the backend.
+const Schema = "com.github.mailstone.go-keychain.GenericPassword"
@@ -0,0 +1,235 @@
+//go:build linux
+// +build linux
+
+package secretservice
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	dbus "github.com/godbus/dbus/v5"
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/internal/cloudsecret"
+)
+
+func init() {
+	keychain.RegisterBackend(Name, Open)
+}
+
+// Open returns a backend storing items in the default collection, over an
+// encrypted session. It fails if no Secret Service is available, see
+// BackendInfo.
+func Open() (keychain.Backend, error) {
+	info, err := BackendInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.Available {
+		return nil, fmt.Errorf("no Secret Service found on the session bus: %w", keychain.ErrorNotAvailable)
+	}
+
+	srv, err := NewService()
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := srv.OpenSession(AuthenticationDHAES)
+	if err != nil {
+		return nil, err
+	}
+
+	b := cloudsecret.New(&provider{srv: srv, session: session}, "")
+	b.SetMaxDataSize(info.MaxItemSize)
+
+	return b, nil
+}
+
+// provider stores secrets as items of the default collection. Calls are
+// serialized since prompts aren't thread-safe.
+type provider struct {
+	mu      sync.Mutex
+	srv     *SecretService
+	session *Session
+}
+
+// find returns the items of the secret name.
+func (p *provider) find(name string) ([]dbus.ObjectPath, error) {
+	return p.srv.SearchCollection(DefaultCollection, Attributes{NameAttribute: name, SchemaAttribute: Schema})
+}
+
+// attributes returns the item attributes for name and labels.
+func attributes(name string, labels map[string]string) Attributes {
+	attrs := Attributes{NameAttribute: name, SchemaAttribute: Schema}
+
+	for k, v := range labels {
+		attrs[k] = v
+	}
+
+	return attrs
+}
+
+// label returns the item label shown by keyring managers.
+func label(name string, labels map[string]string) string {
+	if l := labels["keychain-label"]; l != "" {
+		return l
+	}
+
+	return name
+}
+
+func (p *provider) Create(s cloudsecret.Secret) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	items, err := p.find(s.Name)
+	if err != nil {
+		return err
+	}
+
+	if len(items) > 0 {
+		return cloudsecret.ErrExists
+	}
+
+	if err := p.srv.Unlock([]dbus.ObjectPath{DefaultCollection}); err != nil {
+		return err
+	}
+
+	secret, err := p.session.NewSecret(s.Data)
+	if err != nil {
+		return err
+	}
+
+	props := NewSecretProperties(label(s.Name, s.Labels), attributes(s.Name, s.Labels))
+
+	_, err = p.srv.CreateItem(DefaultCollection, props, secret, ReplaceBehaviorDoNotReplace)
+
+	return err
+}
+
+func (p *provider) Get(name string) (cloudsecret.Secret, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	items, err := p.find(name)
+	if err != nil {
+		return cloudsecret.Secret{}, err
+	}
+
+	if len(items) == 0 {
+		return cloudsecret.Secret{}, cloudsecret.ErrNotFound
+	}
+
+	if err := p.srv.Unlock(items[:1]); err != nil {
+		return cloudsecret.Secret{}, err
+	}
+
+	attrs, err := p.srv.GetAttributes(items[0])
+	if err != nil {
+		return cloudsecret.Secret{}, err
+	}
+
+	data, err := p.srv.GetSecret(items[0], *p.session)
+	if err != nil {
+		return cloudsecret.Secret{}, err
+	}
+
+	if data == nil {
+		return cloudsecret.Secret{}, errors.New("failed to decrypt secret")
+	}
+
+	return cloudsecret.Secret{Name: name, Labels: attrs, Data: data}, nil
+}
+
+func (p *provider) Update(name string, data []byte, labels map[string]string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	items, err := p.find(name)
+	if err != nil {
+		return err
+	}
+
+	if len(items) == 0 {
+		return cloudsecret.ErrNotFound
+	}
+
+	if err := p.srv.Unlock(items); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if err := p.srv.SetAttributes(item, attributes(name, labels)); err != nil {
+			return err
+		}
+
+		if err := p.srv.SetLabel(item, label(name, labels)); err != nil {
+			return err
+		}
+
+		if data == nil {
+			continue
+		}
+
+		secret, err := p.session.NewSecret(data)
+		if err != nil {
+			return err
+		}
+
+		if err := p.srv.SetSecret(item, secret); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *provider) Delete(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	items, err := p.find(name)
+	if err != nil {
+		return err
+	}
+
+	if len(items) == 0 {
+		return cloudsecret.ErrNotFound
+	}
+
+	for _, item := range items {
+		if err := p.srv.DeleteItem(item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *provider) List() ([]cloudsecret.Secret, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	items, err := p.srv.SearchCollection(DefaultCollection, Attributes{SchemaAttribute: Schema})
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make([]cloudsecret.Secret, 0, len(items))
+
+	for _, item := range items {
+		attrs, err := p.srv.GetAttributes(item)
+		if err != nil {
+			return nil, err
+		}
+
+		if attrs[NameAttribute] == "" || attrs[cloudsecret.ClassLabel] == "" {
+			continue
+		}
+
+		secrets = append(secrets, cloudsecret.Secret{Name: attrs[NameAttribute], Labels: attrs})
+	}
+
+	return secrets, nil
+}
@@ -0,0 +1,171 @@
+//go:build linux
+// +build linux
+
+package secretservice
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+// Provider identifies the program providing secret storage on the session bus.
+type Provider string
+
+const (
+	// ProviderNone means no secret storage was found.
+	ProviderNone Provider = ""
+	// ProviderGnomeKeyring is gnome-keyring-daemon.
+	ProviderGnomeKeyring Provider = "gnome-keyring"
+	// ProviderKWallet is kwalletd, which implements the Secret Service since
+	// KDE Frameworks 5.97 and only its own interface before.
+	ProviderKWallet Provider = "kwallet"
+	// ProviderKeePassXC is KeePassXC with Secret Service integration enabled.
+	ProviderKeePassXC Provider = "keepassxc"
+	// ProviderUnknown is another Secret Service implementation.
+	ProviderUnknown Provider = "unknown"
+)
+
+// maxMessageSize is the maximum length of a D-Bus message, which bounds the
+// size of secrets sent over the Secret Service API.
+const maxMessageSize = 1 << 27
+
+// kwalletNames are the bus names of kwalletd's own interface.
+var kwalletNames = []string{"org.kde.kwalletd6", "org.kde.kwalletd5", "org.kde.kwalletd"}
+
+// Info describes the secret storage available on the session bus, so apps can
+// degrade gracefully (e.g. fall back to a file or prompt) when there is none.
+type Info struct {
+	// Available reports whether the Secret Service API is running or can be
+	// activated. Items can only be stored if it is.
+	Available bool
+	// Provider is the program providing the Secret Service, or the secret
+	// storage found if it isn't available (e.g. an old KWallet).
+	Provider Provider
+	// MaxItemSize is the largest secret, in bytes, that can be stored, or 0
+	// if none can.
+	MaxItemSize int
+	// AttributeSearch reports whether items can be searched by attributes,
+	// see SearchCollection.
+	AttributeSearch bool
+}
+
+// bus is the part of the session bus used to detect the secret storage.
+type bus interface {
+	// names returns the names currently owned on the bus.
+	names() ([]string, error)
+	// activatable returns the names that can be started on demand.
+	activatable() ([]string, error)
+	// command returns the name of the program owning name, if known.
+	command(name string) string
+}
+
+// BackendInfo connects to the session bus and reports the secret storage
+// available on it. It returns an error if there is no session bus, in which
+// case no secret storage is available either.
+func BackendInfo() (Info, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to open dbus connection: %w", err)
+	}
+	defer conn.Close()
+
+	return detect(dbusBus{conn})
+}
+
+// BackendInfo reports the secret storage available on the session bus of s.
+func (s *SecretService) BackendInfo() (Info, error) {
+	return detect(dbusBus{s.conn})
+}
+
+func detect(b bus) (Info, error) {
+	names, err := b.names()
+	if err != nil {
+		return Info{}, err
+	}
+
+	running := make(map[string]bool, len(names))
+	for _, name := range names {
+		running[name] = true
+	}
+
+	if running[SecretServiceInterface] {
+		return secretServiceInfo(providerFor(b.command(SecretServiceInterface))), nil
+	}
+
+	activatable, err := b.activatable()
+	if err != nil {
+		return Info{}, err
+	}
+
+	for _, name := range activatable {
+		if name == SecretServiceInterface {
+			// The provider is only known once it is started.
+			return secretServiceInfo(ProviderUnknown), nil
+		}
+	}
+
+	for _, name := range kwalletNames {
+		if running[name] {
+			return Info{Provider: ProviderKWallet}, nil
+		}
+	}
+
+	return Info{}, nil
+}
+
+func secretServiceInfo(p Provider) Info {
+	return Info{Available: true, Provider: p, MaxItemSize: maxMessageSize, AttributeSearch: true}
+}
+
+// providerFor returns the provider for a program name.
+func providerFor(command string) Provider {
+	switch {
+	case strings.HasPrefix(command, "gnome-keyring"):
+		return ProviderGnomeKeyring
+	case strings.HasPrefix(command, "kwalletd"), strings.HasPrefix(command, "ksecretd"):
+		return ProviderKWallet
+	case strings.HasPrefix(strings.ToLower(command), "keepassxc"):
+		return ProviderKeePassXC
+	}
+
+	return ProviderUnknown
+}
+
+type dbusBus struct {
+	conn *dbus.Conn
+}
+
+func (b dbusBus) names() ([]string, error) {
+	var names []string
+	if err := b.conn.BusObject().Call("org.freedesktop.DBus.ListNames", NilFlags).Store(&names); err != nil {
+		return nil, fmt.Errorf("failed to list dbus names: %w", err)
+	}
+
+	return names, nil
+}
+
+func (b dbusBus) activatable() ([]string, error) {
+	var names []string
+	if err := b.conn.BusObject().Call("org.freedesktop.DBus.ListActivatableNames", NilFlags).Store(&names); err != nil {
+		return nil, fmt.Errorf("failed to list activatable dbus names: %w", err)
+	}
+
+	return names, nil
+}
+
+func (b dbusBus) command(name string) string {
+	var pid uint32
+	if err := b.conn.BusObject().Call("org.freedesktop.DBus.GetConnectionUnixProcessID", NilFlags, name).Store(&pid); err != nil {
+		return ""
+	}
+
+	comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(comm))
+}
@@ -0,0 +1,54 @@
+//go:build linux
+// +build linux
+
+package secretservice
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBus struct {
+	running          []string
+	activatableNames []string
+	commands         map[string]string
+}
+
+func (b fakeBus) names() ([]string, error)       { return b.running, nil }
+func (b fakeBus) activatable() ([]string, error) { return b.activatableNames, nil }
+func (b fakeBus) command(name string) string     { return b.commands[name] }
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		bus  fakeBus
+		want Info
+	}{
+		{"none", fakeBus{running: []string{"org.freedesktop.DBus"}}, Info{}},
+		{
+			"gnome-keyring",
+			fakeBus{running: []string{SecretServiceInterface}, commands: map[string]string{SecretServiceInterface: "gnome-keyring-d"}},
+			Info{Available: true, Provider: ProviderGnomeKeyring, MaxItemSize: maxMessageSize, AttributeSearch: true},
+		},
+		{
+			"kwallet with secret service",
+			fakeBus{running: []string{"org.kde.kwalletd6", SecretServiceInterface}, commands: map[string]string{SecretServiceInterface: "kwalletd6"}},
+			Info{Available: true, Provider: ProviderKWallet, MaxItemSize: maxMessageSize, AttributeSearch: true},
+		},
+		{"kwallet only", fakeBus{running: []string{"org.kde.kwalletd5"}}, Info{Provider: ProviderKWallet}},
+		{
+			"activatable",
+			fakeBus{activatableNames: []string{SecretServiceInterface}},
+			Info{Available: true, Provider: ProviderUnknown, MaxItemSize: maxMessageSize, AttributeSearch: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := detect(tt.bus)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, info)
+		})
+	}
+}
@@ -4,6 +4,7 @@
 package secretservice
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/big"
@@ -259,6 +260,33 @@ func (s *SecretService) GetAttributes(item dbus.ObjectPath) (attributes Attribut
 	return Attributes(attributesMap), nil
 }
 
+func (s *SecretService) SetAttributes(item dbus.ObjectPath, attributes Attributes) error {
+	err := s.Obj(item).SetProperty("org.freedesktop.Secret.Item.Attributes", dbus.MakeVariant(map[string]string(attributes)))
+	if err != nil {
+		return fmt.Errorf("failed to set attributes: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SecretService) SetLabel(item dbus.ObjectPath, label string) error {
+	err := s.Obj(item).SetProperty("org.freedesktop.Secret.Item.Label", dbus.MakeVariant(label))
+	if err != nil {
+		return fmt.Errorf("failed to set label: %w", err)
+	}
+
+	return nil
+}
+
+func (s *SecretService) SetSecret(item dbus.ObjectPath, secret Secret) error {
+	err := s.Obj(item).Call("org.freedesktop.Secret.Item.SetSecret", NilFlags, secret).Err
+	if err != nil {
+		return fmt.Errorf("failed to set secret: %w", err)
+	}
+
+	return nil
+}
+
 func (s *SecretService) GetSecret(item dbus.ObjectPath, session Session) (secretPlaintext []byte, err error) {
 	var secretI []interface{}
 
@@ -296,19 +324,25 @@ func (s *SecretService) GetSecret(item dbus.ObjectPath, session Session) (secret
 const NullPrompt = "/"
 
 func (s *SecretService) Unlock(items []dbus.ObjectPath) (err error) {
+	return s.UnlockContext(context.Background(), items)
+}
+
+// UnlockContext unlocks items like Unlock, giving up and dismissing the
+// unlock prompt once ctx is done.
+func (s *SecretService) UnlockContext(ctx context.Context, items []dbus.ObjectPath) (err error) {
 	var (
 		dummy  []dbus.ObjectPath
 		prompt dbus.ObjectPath
 	)
 
 	err = s.ServiceObj().
-		Call("org.freedesktop.Secret.Service.Unlock", NilFlags, items).
+		CallWithContext(ctx, "org.freedesktop.Secret.Service.Unlock", NilFlags, items).
 		Store(&dummy, &prompt)
 	if err != nil {
 		return fmt.Errorf("failed to unlock items: %w", err)
 	}
 
-	_, err = s.PromptAndWait(prompt)
+	_, err = s.PromptAndWaitContext(ctx, prompt)
 	if err != nil {
 		return fmt.Errorf("failed to prompt: %w", err)
 	}
@@ -317,19 +351,25 @@ func (s *SecretService) Unlock(items []dbus.ObjectPath) (err error) {
 }
 
 func (s *SecretService) LockItems(items []dbus.ObjectPath) (err error) {
+	return s.LockItemsContext(context.Background(), items)
+}
+
+// LockItemsContext locks items like LockItems, giving up and dismissing the
+// prompt once ctx is done.
+func (s *SecretService) LockItemsContext(ctx context.Context, items []dbus.ObjectPath) (err error) {
 	var (
 		dummy  []dbus.ObjectPath
 		prompt dbus.ObjectPath
 	)
 
 	err = s.ServiceObj().
-		Call("org.freedesktop.Secret.Service.Lock", NilFlags, items).
+		CallWithContext(ctx, "org.freedesktop.Secret.Service.Lock", NilFlags, items).
 		Store(&dummy, &prompt)
 	if err != nil {
 		return fmt.Errorf("failed to lock items: %w", err)
 	}
 
-	_, err = s.PromptAndWait(prompt)
+	_, err = s.PromptAndWaitContext(ctx, prompt)
 	if err != nil {
 		return fmt.Errorf("failed to prompt: %w", err)
 	}
@@ -345,17 +385,32 @@ func (p PromptDismissedError) Error() string {
 	return p.err.Error()
 }
 
+// DefaultPromptTimeout is how long PromptAndWait waits for the user to answer
+// a prompt.
+const DefaultPromptTimeout = 30 * time.Second
+
 // PromptAndWait is NOT thread-safe.
 func (s *SecretService) PromptAndWait(prompt dbus.ObjectPath) (paths *dbus.Variant, err error) {
+	return s.PromptAndWaitContext(context.Background(), prompt)
+}
+
+// PromptAndWaitContext shows prompt and waits for the user to answer it like
+// PromptAndWait, for up to DefaultPromptTimeout or until ctx is done, e.g. at
+// the deadline of the operation needing it. The prompt is then dismissed and
+// the error of ctx returned. It is NOT thread-safe.
+func (s *SecretService) PromptAndWaitContext(ctx context.Context, prompt dbus.ObjectPath) (paths *dbus.Variant, err error) {
 	if prompt == NullPrompt {
 		return nil, nil // nolint:nilerr
 	}
 
-	call := s.Obj(prompt).Call("org.freedesktop.Secret.Prompt.Prompt", NilFlags, "Keyring Prompt")
+	call := s.Obj(prompt).CallWithContext(ctx, "org.freedesktop.Secret.Prompt.Prompt", NilFlags, "Keyring Prompt")
 	if call.Err != nil {
 		return nil, fmt.Errorf("failed to prompt: %w", call.Err)
 	}
 
+	timeout := time.NewTimer(DefaultPromptTimeout)
+	defer timeout.Stop()
+
 	for {
 		var result PromptCompletedResult
 
@@ -383,8 +438,14 @@ func (s *SecretService) PromptAndWait(prompt dbus.ObjectPath) (paths *dbus.Varia
 			}
 
 			return &result.Paths, nil
-		case <-time.After(30 * time.Second):
+		case <-timeout.C:
+			_ = s.Obj(prompt).Call("org.freedesktop.Secret.Prompt.Dismiss", NilFlags).Err
+
 			return nil, errors.New("prompt timed out")
+		case <-ctx.Done():
+			_ = s.Obj(prompt).Call("org.freedesktop.Secret.Prompt.Dismiss", NilFlags).Err
+
+			return nil, fmt.Errorf("prompt abandoned: %w", ctx.Err())
 		}
 	}
 }
@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	dbus "github.com/godbus/dbus/v5"
+	"github.com/mailstone/go-keychain/backendtest"
 	"github.com/stretchr/testify/require"
 )
 
@@ -85,3 +86,10 @@ func TestGetAll(t *testing.T) {
 	err = srv.DeleteItem(item)
 	require.NoError(t, err)
 }
+
+func TestBackendConformance(t *testing.T) {
+	b, err := Open()
+	require.NoError(t, err)
+
+	backendtest.RunConformance(t, b)
+}
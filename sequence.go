@@ -0,0 +1,170 @@
+package keychain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// SequenceService is the service of the generic password items holding the
+// change sequences of other services, see SetChangeSequences. The sequence of
+// "MyService" is stored in the account "MyService", in the generic attribute,
+// so it can be read without decrypting any data.
+const SequenceService = "go-keychain.sequence"
+
+// maxSequenceAttempts is how often incrementing a sequence is attempted when
+// other processes increment it concurrently.
+const maxSequenceAttempts = 10
+
+// ErrSequenceNotUpdated is returned (wrapped) by the functions modifying items
+// when the change sequence of the service couldn't be incremented after a
+// successful change, see SetChangeSequences. The change itself isn't undone.
+var ErrSequenceNotUpdated = errors.New("change sequence not updated")
+
+// SetChangeSequences enables or disables change sequences and returns the
+// previous setting. While enabled, AddItem, UpdateItem and DeleteItem (and the
+// convenience methods using them) increment a counter per service after each
+// successful change, so that other processes can cheaply detect that a
+// service changed since they last looked, with ChangeSequence or
+// ChangedSince, instead of reading all its items. Changes of items matched
+// without a service, and changes made by other programs, aren't counted.
+func SetChangeSequences(enabled bool) bool {
+	return updateConfig(func(cfg *Config) { cfg.ChangeSequences = enabled }).ChangeSequences
+}
+
+func (s *state) sequenceQuery(service string) Item {
+	query := s.newItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService(SequenceService)
+	query.SetAccount(service)
+
+	return query
+}
+
+// ChangeSequence returns the change sequence of service, which is 0 until the
+// first counted change.
+func ChangeSequence(service string) (uint64, error) {
+	return cur().changeSequence(service)
+}
+
+func (s *state) changeSequence(service string) (uint64, error) {
+	query := s.sequenceQuery(service)
+	query.SetMatchLimit(MatchLimitOne)
+	query.SetReturnAttributes(true)
+
+	results, err := s.queryItem(query)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(results) == 0 {
+		return 0, nil
+	}
+
+	seq, err := strconv.ParseUint(string(results[0].Generic), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid change sequence for %q: %w", service, err)
+	}
+
+	return seq, nil
+}
+
+// ChangedSince returns whether service changed since its change sequence was
+// seq, and the current sequence to pass on the next call.
+func ChangedSince(service string, seq uint64) (bool, uint64, error) {
+	current, err := ChangeSequence(service)
+	if err != nil {
+		return false, seq, err
+	}
+
+	return current != seq, current, nil
+}
+
+// incrementSequence increments the change sequence of service. The update
+// matches the sequence that was read, so concurrent increments by other
+// processes are retried rather than lost.
+func (s *state) incrementSequence(service string) error {
+	for attempt := 0; attempt < maxSequenceAttempts; attempt++ {
+		seq, err := s.changeSequence(service)
+		if err != nil {
+			return err
+		}
+
+		next := []byte(strconv.FormatUint(seq+1, 10))
+
+		if seq == 0 {
+			item := s.sequenceQuery(service)
+			item.SetGeneric(next)
+
+			err = s.addItem(item)
+			if errors.Is(err, ErrorDuplicateItem) {
+				continue
+			}
+
+			return err
+		}
+
+		query := s.sequenceQuery(service)
+		query.SetGeneric([]byte(strconv.FormatUint(seq, 10)))
+
+		update := newItem()
+		update.SetGeneric(next)
+
+		err = s.updateItem(query, update)
+		if errors.Is(err, ErrorItemNotFound) {
+			continue
+		}
+
+		return err
+	}
+
+	return fmt.Errorf("too many concurrent changes")
+}
+
+// countChange increments the change sequences of the services in attrs, if
+// change sequences are enabled, after a successful change. Any change also
+// stops sharing the data of leases, see Lease, and changes with the package
+// configuration clear the prompt cache, see SetPromptCache.
+func countChange(err error, attrs ...map[string]interface{}) error {
+	return cur().countChange(err, attrs...)
+}
+
+func (s *state) countChange(err error, attrs ...map[string]interface{}) error {
+	if err == nil {
+		if s.shared {
+			ClearPromptCache()
+		}
+
+		forgetLeases()
+	}
+
+	if err != nil || !s.cfg.ChangeSequences {
+		return err
+	}
+
+	var services []string
+
+	for _, a := range attrs {
+		service, _ := a[ServiceKey].(string)
+		service = norm.NFC.String(service)
+
+		if service == "" || service == SequenceService || strings.HasPrefix(service, UsageServicePrefix) {
+			continue
+		}
+
+		if len(services) == 0 || services[0] != service {
+			services = append(services, service)
+		}
+	}
+
+	for _, service := range services {
+		if err := s.incrementSequence(service); err != nil {
+			return fmt.Errorf("%w for %q: %w", ErrSequenceNotUpdated, service, err)
+		}
+	}
+
+	return nil
+}
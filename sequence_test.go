@@ -0,0 +1,66 @@
+package keychain_test
+
+import (
+	"errors"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func TestChangeSequence(t *testing.T) {
+	f := keychainfake.Install(t)
+
+	defer keychain.SetChangeSequences(keychain.SetChangeSequences(true))
+
+	seq, err := keychain.ChangeSequence("MyService")
+	if err != nil || seq != 0 {
+		t.Fatalf("expected 0, got %d, %v", seq, err)
+	}
+
+	if err := keychain.SetString("MyService", "gabriel", "toomanysecrets"); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, seq, err := keychain.ChangedSince("MyService", seq)
+	if err != nil || !changed || seq != 1 {
+		t.Fatalf("expected change to 1, got %v, %d, %v", changed, seq, err)
+	}
+
+	// Reads aren't changes.
+	if _, err := keychain.GetString("MyService", "gabriel"); err != nil {
+		t.Fatal(err)
+	}
+
+	if changed, _, err := keychain.ChangedSince("MyService", seq); err != nil || changed {
+		t.Fatalf("unexpected change %v, %v", changed, err)
+	}
+
+	if err := keychain.SetString("MyService", "gabriel", "other"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.DeleteGenericPasswordItem("MyService", "gabriel"); err != nil {
+		t.Fatal(err)
+	}
+
+	if seq, err := keychain.ChangeSequence("MyService"); err != nil || seq != 3 {
+		t.Fatalf("expected 3, got %d, %v", seq, err)
+	}
+
+	if seq, err := keychain.ChangeSequence("OtherService"); err != nil || seq != 0 {
+		t.Fatalf("expected 0 for another service, got %d, %v", seq, err)
+	}
+
+	// Sequences are not passwords of the service.
+	if accounts, err := keychain.GetGenericPasswordAccounts("MyService"); err != nil || len(accounts) != 0 {
+		t.Fatalf("unexpected accounts %v, %v", accounts, err)
+	}
+
+	f.On(keychainfake.Update).Fail(keychain.ErrorInteractionNotAllowed)
+
+	err = keychain.AddItem(keychain.NewGenericPassword("MyService", "alice", "", []byte("a"), ""))
+	if !errors.Is(err, keychain.ErrSequenceNotUpdated) {
+		t.Fatalf("expected ErrSequenceNotUpdated, got %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+package keychain
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrShutdown is returned for operations started after Shutdown, or after
+// Configured.Close for those of the handle.
+var ErrShutdown = errors.New("keychain is shut down")
+
+// drain tracks the operations and backend calls of a configuration in
+// progress, so that closing it can wait for them.
+type drain struct {
+	mu     sync.Mutex
+	closed bool
+	// calls is replaced when the drain is reopened, so the calls entered
+	// afterwards aren't mixed with those a previous close may still wait
+	// for.
+	calls *sync.WaitGroup
+}
+
+// packageDrain is the drain of the package configurations, which new ones
+// inherit, so Shutdown is final.
+var packageDrain = &drain{}
+
+// enter registers a call and returns the function ending it, or returns
+// ErrShutdown once the drain is closed.
+func (d *drain) enter() (func(), error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil, ErrShutdown
+	}
+
+	if d.calls == nil {
+		d.calls = &sync.WaitGroup{}
+	}
+
+	d.calls.Add(1)
+
+	return d.calls.Done, nil
+}
+
+// close rejects new calls and waits for those in progress until ctx is done.
+func (d *drain) close(ctx context.Context) error {
+	d.mu.Lock()
+	d.closed = true
+	calls := d.calls
+	d.mu.Unlock()
+
+	if calls == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		calls.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reopen accepts calls again after close.
+func (d *drain) reopen() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.closed, d.calls = false, nil
+}
+
+// begin registers an operation made of several backend calls, e.g. a read
+// followed by a write, with the drain: closing it waits for the whole
+// operation rather than failing its remaining calls. It returns the state to
+// run the operation with, whose calls bypass the drain, and the function
+// ending the operation.
+func (s *state) begin() (*state, func(), error) {
+	if s.drain == nil {
+		return s, func() {}, nil
+	}
+
+	end, err := s.drain.enter()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	op := *s
+	op.backend, op.drain = s.unique, nil
+
+	return &op, end, nil
+}
+
+// drainBackend registers the calls to the wrapped Backend with a drain.
+type drainBackend struct {
+	Backend
+	d *drain
+}
+
+// Capabilities implements CapabilityReporter.
+func (b drainBackend) Capabilities() Capability {
+	return CapabilitiesOf(b.Backend)
+}
+
+// Add implements Backend.
+func (b drainBackend) Add(attrs map[string]interface{}) (interface{}, error) {
+	end, err := b.d.enter()
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	return b.Backend.Add(attrs)
+}
+
+// Update implements Backend.
+func (b drainBackend) Update(query, attrs map[string]interface{}) error {
+	end, err := b.d.enter()
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	return b.Backend.Update(query, attrs)
+}
+
+// CopyMatching implements Backend.
+func (b drainBackend) CopyMatching(query map[string]interface{}) (interface{}, error) {
+	end, err := b.d.enter()
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	return b.Backend.CopyMatching(query)
+}
+
+// Delete implements Backend.
+func (b drainBackend) Delete(query map[string]interface{}) error {
+	end, err := b.d.enter()
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	return b.Backend.Delete(query)
+}
+
+// Shutdown stops the package for a clean exit, e.g. of a daemon about to be
+// restarted: operations through the package configuration fail with
+// ErrShutdown from then on, the channels of WatchItem and WatchSystemEvents
+// are closed, and Shutdown waits for the operations and backend calls in
+// progress and the polling of watched items to finish, or for ctx to be done.
+// Operations made of several calls, such as SetBytes or Transaction.Apply,
+// are completed as a whole. It then wipes the prompt cache and the data of
+// leases (see Lease), and closes the authentication contexts which are still
+// open, releasing their Core Foundation references rather than leaving them
+// to the garbage collector, which also dismisses their pending prompts. It
+// returns the error of ctx if the calls didn't finish in time.
+//
+// Shutdown is final: configurations set afterwards, e.g. with SetConfig or
+// SetBackend, still fail with ErrShutdown. Calls made with previous package
+// configurations are waited for too, but not those of handles of WithConfig,
+// see Configured.Close.
+func Shutdown(ctx context.Context) error {
+	err := packageDrain.close(ctx)
+
+	if werr := stopWatches(ctx); err == nil {
+		err = werr
+	}
+
+	stopSystemEventWatchers()
+	ClearPromptCache()
+	revokeLeases()
+	closeAuthenticationContexts()
+
+	return err
+}
+
+// Close stops the handle: its operations fail with ErrShutdown from then on,
+// and Close waits for the operations and backend calls in progress to finish,
+// or returns the error of ctx once it is done.
+func (c *Configured) Close(ctx context.Context) error {
+	return c.s.drain.close(ctx)
+}
@@ -0,0 +1,156 @@
+package keychain_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+// onStart delays the next read of f by d and returns a channel closed once it
+// started.
+func onStart(f *keychainfake.Fake, d time.Duration) <-chan struct{} {
+	started := make(chan struct{})
+
+	var once sync.Once
+
+	f.On(keychainfake.CopyMatching).Times(1).Delay(d).Where(func(keychainfake.Call) bool {
+		once.Do(func() { close(started) })
+
+		return true
+	})
+
+	return started
+}
+
+func TestShutdown(t *testing.T) {
+	f := keychainfake.Install(t)
+	t.Cleanup(keychain.ResetShutdown)
+
+	if err := keychain.SetString("com.example.daemon", "token", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	ch, cancel := keychain.WatchItem("com.example.daemon", "token")
+	defer cancel()
+
+	started := onStart(f, 100*time.Millisecond)
+
+	read := make(chan error, 1)
+
+	go func() {
+		s, err := keychain.GetString("com.example.daemon", "token")
+		if err == nil && s != "secret" {
+			err = errors.New("unexpected data " + s)
+		}
+		read <- err
+	}()
+
+	<-started
+
+	if err := keychain.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-read:
+		if err != nil {
+			t.Fatalf("expected the read in progress to finish, got %v", err)
+		}
+	default:
+		t.Fatal("Shutdown returned before the read in progress")
+	}
+
+	if _, err := keychain.GetString("com.example.daemon", "token"); !errors.Is(err, keychain.ErrShutdown) {
+		t.Fatalf("expected ErrShutdown, got %v", err)
+	}
+
+	if _, ok := <-ch; ok {
+		t.Fatal("expected the watch channel to be closed")
+	}
+
+	// New configurations inherit the shutdown.
+	keychainfake.Install(t)
+
+	if err := keychain.SetString("com.example.daemon", "token", "secret"); !errors.Is(err, keychain.ErrShutdown) {
+		t.Fatalf("expected ErrShutdown from a new configuration, got %v", err)
+	}
+}
+
+func TestShutdownCompletesOperations(t *testing.T) {
+	f := keychainfake.Install(t)
+	t.Cleanup(keychain.ResetShutdown)
+
+	if err := keychain.SetString("com.example.daemon", "token", "v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The lookup of SetString is delayed, so Shutdown starts before its
+	// update.
+	started := onStart(f, 100*time.Millisecond)
+
+	set := make(chan error, 1)
+
+	go func() { set <- keychain.SetString("com.example.daemon", "token", "v2") }()
+
+	<-started
+
+	if err := keychain.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-set; err != nil {
+		t.Fatalf("expected the operation in progress to complete, got %v", err)
+	}
+
+	keychain.ResetShutdown()
+
+	if s, err := keychain.GetString("com.example.daemon", "token"); err != nil || s != "v2" {
+		t.Fatalf("expected v2, got %q (%v)", s, err)
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	f := keychainfake.Install(t)
+	t.Cleanup(keychain.ResetShutdown)
+
+	started := onStart(f, time.Second)
+
+	go func() { _, _ = keychain.GetString("com.example.daemon", "token") }()
+
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := keychain.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestConfiguredClose(t *testing.T) {
+	keychainfake.Install(t)
+
+	c := keychain.WithConfig(keychain.Config{Backend: keychainfake.New()})
+
+	if err := c.SetBytes("com.example.library", "token", []byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.GetBytes("com.example.library", "token"); !errors.Is(err, keychain.ErrShutdown) {
+		t.Fatalf("expected ErrShutdown, got %v", err)
+	}
+
+	// The package configuration is unaffected.
+	if err := keychain.SetString("com.example.library", "token", "secret"); err != nil {
+		t.Fatal(err)
+	}
+}
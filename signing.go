@@ -0,0 +1,108 @@
+package keychain
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// CodeSigning describes how the running process is signed, which determines
+// the keychain items and access groups it can use.
+type CodeSigning struct {
+	// Identifier is the signing identifier, empty for unsigned binaries.
+	// Binaries built with go build are ad-hoc signed on Apple silicon, with an
+	// identifier but no team.
+	Identifier string
+	// Sandboxed is whether the process runs in the App Sandbox, as App Store
+	// apps (and all iOS apps) do.
+	Sandboxed bool
+	// Entitlements are the access group entitlements of the process.
+	Entitlements Entitlements
+}
+
+// Signed returns whether the process is signed with a team identifier, as
+// required to use access groups and the data protection keychain on macOS.
+func (s CodeSigning) Signed() bool {
+	return s.Identifier != "" && s.Entitlements.TeamID != ""
+}
+
+var signing struct {
+	once sync.Once
+	s    CodeSigning
+	err  error
+}
+
+// Signing returns the code signing of the running process. It fails with
+// ErrorUnimplemented on platforms other than macOS and iOS.
+func Signing() (CodeSigning, error) {
+	signing.once.Do(func() {
+		signing.s, signing.err = currentSigning()
+	})
+
+	return signing.s, signing.err
+}
+
+// AccessError is a keychain error explained by the code signing of the process,
+// e.g. an access group used by an unsigned binary. It unwraps to the Error
+// returned by the Security framework.
+type AccessError struct {
+	Err     error
+	Signing CodeSigning
+	// Hint describes the likely cause.
+	Hint string
+}
+
+func (e *AccessError) Error() string {
+	return fmt.Sprintf("%s (%s)", e.Err, e.Hint)
+}
+
+func (e *AccessError) Unwrap() error {
+	return e.Err
+}
+
+// explainError returns err as an *AccessError if the code signing s explains
+// it for an operation with the encoded attributes attrs, and otherwise err.
+func explainError(err error, attrs map[string]interface{}, s CodeSigning) error {
+	accessGroup, _ := attrs[AccessGroupKey].(string)
+	_, dataProtection := attrs[UseDataProtectionKeychainKey]
+
+	var hint string
+
+	switch {
+	case errors.Is(err, ErrorMissingEntitlement) && !s.Signed():
+		hint = "unsigned and ad-hoc signed binaries can't use access groups or the data protection keychain; sign with a team identifier and a keychain-access-groups entitlement"
+	case errors.Is(err, ErrorMissingEntitlement) && accessGroup != "":
+		if derr := DiagnoseAccessGroup(accessGroup, s.Entitlements); derr != nil {
+			hint = derr.Error()
+		} else {
+			hint = fmt.Sprintf("the provisioning profile may not allow access group %q", accessGroup)
+		}
+	case errors.Is(err, ErrorMissingEntitlement):
+		hint = "the binary has no keychain-access-groups or application-groups entitlement, which the data protection keychain requires"
+	case (errors.Is(err, ErrorParam) || errors.Is(err, ErrorNoAccessForItem)) && accessGroup != "" && !s.Signed():
+		hint = "unsigned binaries can't use access groups"
+	case errors.Is(err, ErrorParam) && dataProtection && !s.Signed():
+		hint = "unsigned binaries can't use the data protection keychain"
+	case (errors.Is(err, ErrorAuthFailed) || errors.Is(err, ErrorNoAccessForItem)) && s.Sandboxed:
+		hint = "sandboxed apps can only access items they created or that are in their access groups"
+	default:
+		return err
+	}
+
+	return &AccessError{Err: err, Signing: s, Hint: hint}
+}
+
+// explain adds the code signing context to access group and entitlement
+// errors of the Security framework, see AccessError.
+func explain(err error, attrs map[string]interface{}) error {
+	if err == nil {
+		return nil
+	}
+
+	s, serr := Signing()
+	if serr != nil {
+		return err
+	}
+
+	return explainError(err, attrs, s)
+}
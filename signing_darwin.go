@@ -0,0 +1,79 @@
+//go:build darwin && !ios
+// +build darwin,!ios
+
+package keychain
+
+/*
+#cgo LDFLAGS: -framework CoreFoundation -framework Security
+
+#include <CoreFoundation/CoreFoundation.h>
+#include <Security/Security.h>
+
+// copySigningInformation returns the signing information of the running
+// process, including its entitlements, or NULL.
+static CFDictionaryRef copySigningInformation(void) {
+	SecCodeRef code = NULL;
+	SecStaticCodeRef staticCode = NULL;
+	CFDictionaryRef info = NULL;
+
+	if (SecCodeCopySelf(kSecCSDefaultFlags, &code) != errSecSuccess) {
+		return NULL;
+	}
+
+	if (SecCodeCopyStaticCode(code, kSecCSDefaultFlags, &staticCode) == errSecSuccess) {
+		SecCodeCopySigningInformation(staticCode, kSecCSSigningInformation | kSecCSRequirementInformation, &info);
+		CFRelease(staticCode);
+	}
+
+	CFRelease(code);
+
+	return info;
+}
+*/
+import "C"
+import "fmt"
+
+func currentSigning() (CodeSigning, error) {
+	ref := C.CFTypeRef(C.copySigningInformation()) // nolint: nlreturn
+	if ref == 0 {
+		return CodeSigning{}, fmt.Errorf("failed to read code signing information")
+	}
+
+	defer Release(ref)
+
+	v, err := decodeCF(ref)
+	if err != nil {
+		return CodeSigning{}, fmt.Errorf("failed to decode code signing information: %w", err)
+	}
+
+	info, _ := v.(map[string]interface{})
+	entitlements, _ := info[attrKey(C.CFTypeRef(C.kSecCodeInfoEntitlementsDict))].(map[string]interface{})
+
+	identifier, _ := info[attrKey(C.CFTypeRef(C.kSecCodeInfoIdentifier))].(string)
+	teamID, _ := info[attrKey(C.CFTypeRef(C.kSecCodeInfoTeamIdentifier))].(string)
+
+	s := CodeSigning{
+		Identifier: identifier,
+		Entitlements: Entitlements{
+			TeamID:               teamID,
+			KeychainAccessGroups: stringValues(entitlements["keychain-access-groups"]),
+			ApplicationGroups:    stringValues(entitlements["com.apple.security.application-groups"]),
+		},
+	}
+	s.Sandboxed, _ = entitlements["com.apple.security.app-sandbox"].(bool)
+
+	return s, nil
+}
+
+func stringValues(v interface{}) []string {
+	a, _ := v.([]interface{})
+	s := make([]string, 0, len(a))
+
+	for _, e := range a {
+		if e, ok := e.(string); ok {
+			s = append(s, e)
+		}
+	}
+
+	return s
+}
@@ -0,0 +1,8 @@
+//go:build !darwin || ios
+// +build !darwin ios
+
+package keychain
+
+func currentSigning() (CodeSigning, error) {
+	return CodeSigning{}, ErrorUnimplemented
+}
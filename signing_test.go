@@ -0,0 +1,46 @@
+package keychain
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExplainError(t *testing.T) {
+	unsigned := CodeSigning{Identifier: "a.out"}
+	signed := CodeSigning{Identifier: "com.example.app", Sandboxed: true, Entitlements: Entitlements{
+		TeamID:               "A123456789",
+		KeychainAccessGroups: []string{"A123456789.com.example.shared"},
+	}}
+	withGroup := func(ag string) map[string]interface{} {
+		return map[string]interface{}{SecClassKey: "genp", AccessGroupKey: ag}
+	}
+
+	for _, tc := range []struct {
+		err   error
+		attrs map[string]interface{}
+		s     CodeSigning
+		hint  string
+	}{
+		{ErrorMissingEntitlement, withGroup(""), unsigned, "unsigned and ad-hoc signed binaries"},
+		{ErrorMissingEntitlement, withGroup("A123456789.com.example.other"), signed, "is in neither"},
+		{ErrorParam, withGroup("A123456789.com.example.shared"), unsigned, "unsigned binaries can't use access groups"},
+		{ErrorNoAccessForItem, withGroup(""), signed, "sandboxed apps"},
+		{ErrorItemNotFound, withGroup("A123456789.com.example.shared"), unsigned, ""},
+		{ErrorParam, withGroup(""), signed, ""},
+	} {
+		err := explainError(tc.err, tc.attrs, tc.s)
+		if !errors.Is(err, tc.err) {
+			t.Fatalf("%v: explained error doesn't unwrap: %v", tc.err, err)
+		}
+
+		var accessErr *AccessError
+
+		switch {
+		case tc.hint == "" && errors.As(err, &accessErr):
+			t.Fatalf("%v: unexpected hint %q", tc.err, accessErr.Hint)
+		case tc.hint != "" && !strings.Contains(err.Error(), tc.hint):
+			t.Fatalf("%v: expected hint %q, got %q", tc.err, tc.hint, err)
+		}
+	}
+}
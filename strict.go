@@ -0,0 +1,7 @@
+//go:build keychain_strict
+// +build keychain_strict
+
+package keychain
+
+// strict is set by the keychain_strict build tag, see ErrForbidden.
+const strict = true
@@ -0,0 +1,6 @@
+//go:build !keychain_strict
+// +build !keychain_strict
+
+package keychain
+
+const strict = false
@@ -0,0 +1,239 @@
+package keychain
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// SyncBaseSuffix is appended to the service of the local copies kept by
+// SetSynced and GetSynced of the version last seen on this device.
+const SyncBaseSuffix = "#syncbase"
+
+// MetadataHistoryKey is the metadata key holding the checksums of the
+// previous versions of an item written by SetSynced, most recent first,
+// separated by commas.
+const MetadataHistoryKey = "history"
+
+// syncHistoryLength is the number of previous versions recorded.
+const syncHistoryLength = 8
+
+// ErrSyncConflict is matched (with errors.Is) by SyncConflictError.
+var ErrSyncConflict = errors.New("conflicting updates of a synchronized item")
+
+// SyncVersion is a version of a synchronized item.
+type SyncVersion struct {
+	Data []byte
+	// Checksum is the hex encoded SHA-256 of Data.
+	Checksum string
+	Modified time.Time
+}
+
+// SyncConflictError is returned by SetSynced and GetSynced when the
+// synchronized item was updated on another device from a version other than
+// the one this device last saw, so that one of the updates would be lost.
+type SyncConflictError struct {
+	Service string
+	Account string
+	// Local is the version last written or read on this device, Remote the
+	// version in the keychain.
+	Local  SyncVersion
+	Remote SyncVersion
+}
+
+func (e *SyncConflictError) Error() string {
+	return fmt.Sprintf("%s: %q@%q changed to %s on another device, last seen as %s", ErrSyncConflict,
+		e.Account, e.Service, shortChecksum(e.Remote.Checksum), shortChecksum(e.Local.Checksum))
+}
+
+// Is reports whether target is ErrSyncConflict.
+func (e *SyncConflictError) Is(target error) bool {
+	return target == ErrSyncConflict //nolint:errorlint
+}
+
+func shortChecksum(s string) string {
+	if len(s) > 12 {
+		return s[:12]
+	}
+
+	return s
+}
+
+// GetSynced returns the data of the synchronizable generic password for
+// service and account, or ErrorItemNotFound. If it was updated on another
+// device from a version other than the one last seen on this device, e.g.
+// when both devices updated it while offline and iCloud Keychain kept the
+// other update, a *SyncConflictError holds both versions, to be merged with
+// ResolveSyncConflict. Each version records the checksums of those it
+// replaced, and this device keeps a local copy of the version it last saw.
+func GetSynced(service, account string) ([]byte, error) {
+	remote, history, err := readSyncVersion(syncedQuery(service, account))
+	if err != nil {
+		return nil, err
+	}
+
+	seen, err := checkSyncBase(service, account, remote, history)
+	if err != nil {
+		return nil, err
+	}
+
+	if !seen {
+		if err := writeSyncVersion(syncBaseQuery(service, account), remote.Data, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return remote.Data, nil
+}
+
+// SetSynced stores data in the synchronizable generic password for service
+// and account, or returns a *SyncConflictError without changing it if it was
+// updated on another device since this device last saw it, so that the update
+// doesn't silently replace a version it wasn't based on. Call GetSynced to see
+// the new version, or ResolveSyncConflict to replace it.
+func SetSynced(service, account string, data []byte) error {
+	remote, history, err := readSyncVersion(syncedQuery(service, account))
+
+	switch {
+	case errors.Is(err, ErrorItemNotFound):
+	case err != nil:
+		return err
+	default:
+		// The update must replace the version last seen on this device.
+		if _, err := checkSyncBase(service, account, remote, nil); err != nil {
+			return err
+		}
+
+		history = append([]string{remote.Checksum}, history...)
+		if len(history) > syncHistoryLength {
+			history = history[:syncHistoryLength]
+		}
+	}
+
+	if err := writeSyncVersion(syncedQuery(service, account), data, history); err != nil {
+		return err
+	}
+
+	return writeSyncVersion(syncBaseQuery(service, account), data, nil)
+}
+
+// checkSyncBase returns a *SyncConflictError if remote is neither the version
+// last seen on this device nor one replacing it, whose checksum is in history,
+// and whether it is that version. Items not written by SetSynced have no
+// checksum and never conflict.
+func checkSyncBase(service, account string, remote SyncVersion, history []string) (bool, error) {
+	local, _, err := readSyncVersion(syncBaseQuery(service, account))
+
+	switch {
+	case errors.Is(err, ErrorItemNotFound):
+		return false, nil
+	case err != nil:
+		return false, err
+	case local.Checksum == remote.Checksum:
+		return true, nil
+	case remote.Checksum != "" && !slices.Contains(history, local.Checksum):
+		return false, &SyncConflictError{Service: service, Account: account, Local: local, Remote: remote}
+	}
+
+	return false, nil
+}
+
+// ResolveSyncConflict stores data, e.g. the merge of the versions of a
+// SyncConflictError, in place of the version in the keychain.
+func ResolveSyncConflict(service, account string, data []byte) error {
+	remote, _, err := readSyncVersion(syncedQuery(service, account))
+	if err != nil {
+		return err
+	}
+
+	if err := writeSyncVersion(syncBaseQuery(service, account), remote.Data, nil); err != nil {
+		return err
+	}
+
+	return SetSynced(service, account, data)
+}
+
+// syncedQuery returns a query for the synchronized item.
+func syncedQuery(service, account string) Item {
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService(service)
+	query.SetAccount(account)
+	query.SetSynchronizable(SynchronizableYes)
+
+	return query
+}
+
+// syncBaseQuery returns a query for the local copy of the synchronized item.
+func syncBaseQuery(service, account string) Item {
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService(service + SyncBaseSuffix)
+	query.SetAccount(account)
+	query.SetSynchronizable(SynchronizableNo)
+
+	return query
+}
+
+// readSyncVersion returns the version of the item matching query and the
+// checksums of the versions it replaced, or ErrorItemNotFound.
+func readSyncVersion(query Item) (SyncVersion, []string, error) {
+	q := query.clone()
+	q.SetMatchLimit(MatchLimitOne)
+	q.SetReturnAttributes(true)
+	q.SetReturnData(true)
+
+	results, err := QueryItem(q)
+	if err != nil {
+		return SyncVersion{}, nil, err
+	}
+
+	if len(results) == 0 {
+		return SyncVersion{}, nil, ErrorItemNotFound
+	}
+
+	r := results[0]
+	m := r.Metadata()
+
+	var history []string
+	if h := m[MetadataHistoryKey]; h != "" {
+		history = strings.Split(h, ",")
+	}
+
+	return SyncVersion{Data: r.Data, Checksum: m[MetadataChecksumKey], Modified: r.ModificationDate}, history, nil
+}
+
+// writeSyncVersion stores data with its checksum and history in the item
+// matching query, adding it if it doesn't exist.
+func writeSyncVersion(query Item, data []byte, history []string) error {
+	setVersion := func(k *Item) error {
+		if err := k.SetDataWithChecksum(data); err != nil {
+			return err
+		}
+
+		if len(history) == 0 {
+			return nil
+		}
+
+		return k.setMetadataValue(MetadataHistoryKey, strings.Join(history, ","))
+	}
+
+	update := newItem()
+	if err := setVersion(&update); err != nil {
+		return err
+	}
+
+	err := UpdateItem(query, update)
+	if errors.Is(err, ErrorItemNotFound) {
+		item := query.clone()
+		if err := setVersion(&item); err != nil {
+			return err
+		}
+
+		err = AddItem(item)
+	}
+
+	return err
+}
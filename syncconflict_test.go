@@ -0,0 +1,110 @@
+package keychain_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+// switchDevice simulates another device sharing the synchronized items, by
+// swapping the local copies of the versions last seen by each device.
+func switchDevice(t *testing.T, from, to string) {
+	t.Helper()
+
+	rename := func(fromService, toService string) {
+		query := genericPasswordQuery(fromService, "alice")
+		update := keychain.NewItem()
+		update.SetService(toService)
+
+		if err := keychain.UpdateItem(query, update); err != nil && !errors.Is(err, keychain.ErrorItemNotFound) {
+			t.Fatal(err)
+		}
+	}
+
+	base := "com.example.app" + keychain.SyncBaseSuffix
+	rename(base, base+"-"+from)
+	rename(base+"-"+to, base)
+}
+
+func TestSyncConflict(t *testing.T) {
+	keychainfake.Install(t)
+
+	if err := keychain.SetSynced("com.example.app", "alice", []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+
+	switchDevice(t, "a", "b")
+
+	for _, v := range []string{"v2", "v3"} {
+		if _, err := keychain.GetSynced("com.example.app", "alice"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := keychain.SetSynced("com.example.app", "alice", []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	switchDevice(t, "b", "a")
+
+	// Device a last saw v1, which v3 replaced.
+	if data, err := keychain.GetSynced("com.example.app", "alice"); err != nil || string(data) != "v3" {
+		t.Fatalf("expected v3, got %q (%v)", data, err)
+	}
+
+	if err := keychain.SetSynced("com.example.app", "alice", []byte("a4")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Device b updated v3 too while offline, and iCloud Keychain kept its
+	// version.
+	sum := func(s string) string {
+		h := sha256.Sum256([]byte(s))
+
+		return hex.EncodeToString(h[:])
+	}
+
+	synced := genericPasswordQuery("com.example.app", "alice")
+	synced.SetSynchronizable(keychain.SynchronizableYes)
+
+	update := keychain.NewItem()
+	update.SetData([]byte("b4"))
+
+	if err := update.SetMetadata(keychain.Metadata{
+		keychain.MetadataChecksumKey: sum("b4"),
+		keychain.MetadataHistoryKey:  sum("v3"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.UpdateItem(synced, update); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := keychain.GetSynced("com.example.app", "alice")
+
+	var conflict *keychain.SyncConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, keychain.ErrSyncConflict) {
+		t.Fatalf("expected a SyncConflictError, got %v", err)
+	}
+
+	if string(conflict.Local.Data) != "a4" || string(conflict.Remote.Data) != "b4" {
+		t.Fatalf("unexpected versions %+v", conflict)
+	}
+
+	if err := keychain.SetSynced("com.example.app", "alice", []byte("a5")); !errors.Is(err, keychain.ErrSyncConflict) {
+		t.Fatalf("expected the update to be refused, got %v", err)
+	}
+
+	if err := keychain.ResolveSyncConflict("com.example.app", "alice", []byte("a4+b4")); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := keychain.GetSynced("com.example.app", "alice"); err != nil || string(data) != "a4+b4" {
+		t.Fatalf("expected the merged version, got %q (%v)", data, err)
+	}
+}
@@ -0,0 +1,111 @@
+package keychain
+
+import (
+	"fmt"
+	"sync"
+)
+
+// SystemEvent is a change of the security state of the system.
+type SystemEvent int
+
+const (
+	// SystemLock is sent when the screen locks.
+	SystemLock SystemEvent = iota + 1
+	// SystemUnlock is sent when the screen unlocks.
+	SystemUnlock
+	// SystemSleep is sent before the system sleeps.
+	SystemSleep
+	// SystemWake is sent after the system woke up.
+	SystemWake
+)
+
+var systemEventNames = map[SystemEvent]string{
+	SystemLock:   "lock",
+	SystemUnlock: "unlock",
+	SystemSleep:  "sleep",
+	SystemWake:   "wake",
+}
+
+func (e SystemEvent) String() string {
+	if name, ok := systemEventNames[e]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("SystemEvent(%d)", int(e))
+}
+
+var systemEvents = struct {
+	sync.Mutex
+	subs  map[chan SystemEvent]struct{}
+	start sync.Once
+}{subs: make(map[chan SystemEvent]struct{})}
+
+// startSystemEvents starts observing the system events of the platform, once.
+func startSystemEvents() {
+	systemEvents.start.Do(watchSystemEvents)
+}
+
+// WatchSystemEvents sends the lock, unlock, sleep and wake events of the
+// system on the returned channel. They are observed on macOS; on other
+// platforms the application reports them with NotifySystemEvent. Events are
+// dropped if the receiver falls behind. Call cancel to stop watching; it
+// closes the channel.
+func WatchSystemEvents() (<-chan SystemEvent, func()) {
+	startSystemEvents()
+
+	ch := make(chan SystemEvent, 8)
+
+	systemEvents.Lock()
+	systemEvents.subs[ch] = struct{}{}
+	systemEvents.Unlock()
+
+	var once sync.Once
+
+	cancel := func() {
+		once.Do(func() {
+			systemEvents.Lock()
+			defer systemEvents.Unlock()
+
+			// Shutdown closes the channels itself.
+			if _, ok := systemEvents.subs[ch]; !ok {
+				return
+			}
+
+			delete(systemEvents.subs, ch)
+			close(ch)
+		})
+	}
+
+	return ch, cancel
+}
+
+// stopSystemEventWatchers closes the channels of WatchSystemEvents.
+func stopSystemEventWatchers() {
+	systemEvents.Lock()
+	defer systemEvents.Unlock()
+
+	for ch := range systemEvents.subs {
+		delete(systemEvents.subs, ch)
+		close(ch)
+	}
+}
+
+// NotifySystemEvent reports a system event observed by the application, e.g.
+// from logind on Linux. Locking and sleeping wipe the cached data of items
+// only accessible while the device is unlocked (see SetPromptCache), then the
+// event is sent to the watchers of WatchSystemEvents.
+func NotifySystemEvent(e SystemEvent) {
+	if e == SystemLock || e == SystemSleep {
+		clearPromptCacheWhenUnlocked()
+	}
+
+	systemEvents.Lock()
+	defer systemEvents.Unlock()
+
+	for ch := range systemEvents.subs {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
@@ -0,0 +1,70 @@
+//go:build darwin && !ios
+// +build darwin,!ios
+
+package keychain
+
+/*
+#cgo LDFLAGS: -framework CoreFoundation -framework IOKit
+
+#include <CoreFoundation/CoreFoundation.h>
+#include <IOKit/IOMessage.h>
+#include <IOKit/pwr_mgt/IOPMLib.h>
+
+// The values of SystemEvent.
+enum { systemLock = 1, systemUnlock, systemSleep, systemWake };
+
+extern void keychainSystemEvent(int);
+
+static io_connect_t rootPort;
+
+static void screenCallback(CFNotificationCenterRef center, void *observer, CFNotificationName name, const void *object, CFDictionaryRef userInfo) {
+	keychainSystemEvent(CFEqual(name, CFSTR("com.apple.screenIsLocked")) ? systemLock : systemUnlock);
+}
+
+static void powerCallback(void *refcon, io_service_t service, natural_t messageType, void *messageArgument) {
+	switch (messageType) {
+	case kIOMessageCanSystemSleep:
+		IOAllowPowerChange(rootPort, (long)messageArgument);
+		break;
+	case kIOMessageSystemWillSleep:
+		keychainSystemEvent(systemSleep);
+		IOAllowPowerChange(rootPort, (long)messageArgument);
+		break;
+	case kIOMessageSystemHasPoweredOn:
+		keychainSystemEvent(systemWake);
+		break;
+	}
+}
+
+// watchSystemEvents observes screen lock and power notifications and runs the
+// run loop of the current thread to deliver them. It never returns.
+void watchSystemEvents(void) {
+	CFNotificationCenterRef center = CFNotificationCenterGetDistributedCenter();
+	CFNotificationCenterAddObserver(center, (const void *)screenCallback, screenCallback,
+		CFSTR("com.apple.screenIsLocked"), NULL, CFNotificationSuspensionBehaviorDeliverImmediately);
+	CFNotificationCenterAddObserver(center, (const void *)screenCallback, screenCallback,
+		CFSTR("com.apple.screenIsUnlocked"), NULL, CFNotificationSuspensionBehaviorDeliverImmediately);
+
+	IONotificationPortRef port;
+	io_object_t notifier;
+
+	rootPort = IORegisterForSystemPower(NULL, &port, powerCallback, &notifier);
+	if (rootPort != MACH_PORT_NULL) {
+		CFRunLoopAddSource(CFRunLoopGetCurrent(), IONotificationPortGetRunLoopSource(port), kCFRunLoopCommonModes);
+	}
+
+	CFRunLoopRun();
+}
+*/
+import "C"
+
+import "runtime"
+
+// watchSystemEvents reports the screen lock and power notifications with
+// NotifySystemEvent, on a thread dedicated to the notification run loop.
+func watchSystemEvents() {
+	go func() {
+		runtime.LockOSThread()
+		C.watchSystemEvents()
+	}()
+}
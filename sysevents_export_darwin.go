@@ -0,0 +1,11 @@
+//go:build darwin && !ios
+// +build darwin,!ios
+
+package keychain
+
+import "C"
+
+//export keychainSystemEvent
+func keychainSystemEvent(e C.int) {
+	NotifySystemEvent(SystemEvent(e))
+}
@@ -0,0 +1,8 @@
+//go:build !darwin || ios
+// +build !darwin ios
+
+package keychain
+
+// watchSystemEvents does nothing; system events are only observed on macOS,
+// see NotifySystemEvent.
+func watchSystemEvents() {}
@@ -0,0 +1,62 @@
+package keychain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSystemEventsClearPromptCache(t *testing.T) {
+	prev := SetPromptCache(time.Minute)
+	defer SetPromptCache(prev)
+
+	unlocked := fakeResponse{result: map[string]interface{}{DataKey: []byte("unlocked"), AccessibleKey: "ak"}}
+	afterFirst := fakeResponse{result: map[string]interface{}{DataKey: []byte("afterfirst"), AccessibleKey: "ck"}}
+	f := useFakeBackend(t, unlocked, afterFirst, unlocked)
+
+	unlockedQuery := promptQuery()
+	afterFirstQuery := promptQuery()
+	afterFirstQuery.SetAccount("alice")
+
+	for _, query := range []Item{unlockedQuery, afterFirstQuery} {
+		if _, err := QueryItem(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, cancel := WatchSystemEvents()
+	defer cancel()
+
+	NotifySystemEvent(SystemLock)
+
+	if e := <-events; e != SystemLock {
+		t.Fatalf("expected %v, got %v", SystemLock, e)
+	}
+
+	for _, query := range []Item{unlockedQuery, afterFirstQuery} {
+		if _, err := QueryItem(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(f.calls) != 3 {
+		t.Fatalf("expected only the item accessible when unlocked to be read again, got %d calls", len(f.calls))
+	}
+
+	cancel()
+
+	if _, ok := <-events; ok {
+		t.Fatal("expected the events to be closed")
+	}
+
+	NotifySystemEvent(SystemUnlock)
+}
+
+func TestSystemEventString(t *testing.T) {
+	if s := SystemSleep.String(); s != "sleep" {
+		t.Fatalf("unexpected %q", s)
+	}
+
+	if s := SystemEvent(42).String(); s != "SystemEvent(42)" {
+		t.Fatalf("unexpected %q", s)
+	}
+}
@@ -0,0 +1,170 @@
+package keychain
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// tagsPrefix starts the last line of the comment of an item listing its tags.
+const tagsPrefix = "Tags: "
+
+// splitTags returns the comment without its line of tags, and the tags.
+func splitTags(comment string) (string, []string) {
+	text, line := "", comment
+	if i := strings.LastIndexByte(comment, '\n'); i >= 0 {
+		text, line = comment[:i], comment[i+1:]
+	}
+
+	if !strings.HasPrefix(line, tagsPrefix) {
+		return comment, nil
+	}
+
+	var tags []string
+
+	for _, tag := range strings.Split(strings.TrimPrefix(line, tagsPrefix), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" && !hasTag(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	return text, tags
+}
+
+// joinTags returns text followed by the line of tags.
+func joinTags(text string, tags []string) string {
+	switch {
+	case len(tags) == 0:
+		return text
+	case text == "":
+		return tagsPrefix + strings.Join(tags, ", ")
+	}
+
+	return text + "\n" + tagsPrefix + strings.Join(tags, ", ")
+}
+
+// hasTag returns whether tags has tag, ignoring case.
+func hasTag(tags []string, tag string) bool {
+	return slices.ContainsFunc(tags, func(t string) bool { return strings.EqualFold(t, tag) })
+}
+
+// checkTags returns an error for a tag which can't be stored in a comment.
+func checkTags(tags []string) error {
+	for _, tag := range tags {
+		if strings.TrimSpace(tag) != tag || tag == "" || strings.ContainsAny(tag, ",\n") {
+			return fmt.Errorf("invalid tag %q", tag)
+		}
+	}
+
+	return nil
+}
+
+// Tags returns the tags of the item, see AddTags.
+func (r QueryResult) Tags() []string {
+	_, tags := splitTags(r.Comment)
+
+	return tags
+}
+
+// Labels returns the label of the item followed by its tags, e.g. to show
+// them as a list of labels.
+func (r QueryResult) Labels() []string {
+	labels := r.Tags()
+	if r.Label != "" {
+		labels = append([]string{r.Label}, labels...)
+	}
+
+	return labels
+}
+
+// CommentText returns the comment of the item without its line of tags.
+func (r QueryResult) CommentText() string {
+	text, _ := splitTags(r.Comment)
+
+	return text
+}
+
+// SetTags sets the tags of a new item, after the text of its comment set
+// with SetComment, see AddTags.
+func (k *Item) SetTags(tags ...string) error {
+	if err := checkTags(tags); err != nil {
+		return err
+	}
+
+	comment, _ := k.attr[CommentKey].(string)
+	text, _ := splitTags(comment)
+	k.SetComment(joinTags(text, tags))
+
+	return nil
+}
+
+// AddTags tags the items matching query (which must set a class), to
+// categorize them in addition to their label. The tags are stored in the last
+// line of the comment of the items as "Tags: " followed by the tags separated
+// by commas, so they are shown, and can be edited, in Keychain Access. Tags
+// are compared ignoring case, and can't contain commas or line breaks.
+// Saving a credential with SaveCredential replaces its comment and tags.
+func AddTags(query Item, tags ...string) error {
+	if err := checkTags(tags); err != nil {
+		return err
+	}
+
+	return retag(query, func(current []string) []string {
+		for _, tag := range tags {
+			if !hasTag(current, tag) {
+				current = append(current, tag)
+			}
+		}
+
+		return current
+	})
+}
+
+// RemoveTags removes tags from the items matching query (which must set a
+// class), see AddTags.
+func RemoveTags(query Item, tags ...string) error {
+	return retag(query, func(current []string) []string {
+		return slices.DeleteFunc(current, func(t string) bool { return hasTag(tags, t) })
+	})
+}
+
+// retag replaces the tags of the items matching query with those returned by
+// change.
+func retag(query Item, change func(current []string) []string) error {
+	results, err := queryAttributes(query)
+	if err != nil {
+		return err
+	}
+
+	secClass := query.secClass()
+
+	for _, r := range results {
+		text, current := splitTags(r.Comment)
+
+		comment := joinTags(text, change(slices.Clone(current)))
+		if comment == r.Comment {
+			continue
+		}
+
+		update := newItem()
+		update.attr[CommentKey] = comment
+
+		if err := UpdateItem(queryForResult(secClass, r), update); err != nil {
+			return fmt.Errorf("failed to tag %s: %w", describeResult(r), err)
+		}
+	}
+
+	return nil
+}
+
+// FindByTag returns the attributes of the items matching query (which must
+// set a class) tagged with tag, ignoring case. The keychain can't search
+// comments, so the items matching query are filtered.
+func FindByTag(query Item, tag string) ([]QueryResult, error) {
+	results, err := queryAttributes(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(results, func(r QueryResult) bool { return !hasTag(r.Tags(), tag) }), nil
+}
@@ -0,0 +1,72 @@
+package keychain_test
+
+import (
+	"slices"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func TestTags(t *testing.T) {
+	keychainfake.Install(t)
+
+	item := keychain.NewGenericPassword("com.example.app", "db", "Database", []byte("secret"), "")
+	item.SetComment("rotated monthly")
+
+	if err := item.SetTags("prod"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.AddItem(item); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.AddItem(keychain.NewGenericPassword("com.example.app", "cache", "", []byte("secret"), "")); err != nil {
+		t.Fatal(err)
+	}
+
+	query := keychain.NewItem()
+	query.SetSecClass(keychain.SecClassGenericPassword)
+	query.SetService("com.example.app")
+
+	if err := keychain.AddTags(query, "Team-A", "prod"); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := keychain.FindByTag(query, "PROD")
+	if err != nil || len(results) != 2 {
+		t.Fatalf("expected 2 items tagged prod, got %d (%v)", len(results), err)
+	}
+
+	for _, r := range results {
+		if r.Account != "db" {
+			continue
+		}
+
+		if r.Comment != "rotated monthly\nTags: prod, Team-A" || r.CommentText() != "rotated monthly" {
+			t.Fatalf("unexpected comment %q", r.Comment)
+		}
+
+		if labels := r.Labels(); !slices.Equal(labels, []string{"Database", "prod", "Team-A"}) {
+			t.Fatalf("unexpected labels %q", labels)
+		}
+	}
+
+	db := keychain.NewItem()
+	db.SetSecClass(keychain.SecClassGenericPassword)
+	db.SetService("com.example.app")
+	db.SetAccount("db")
+
+	if err := keychain.RemoveTags(db, "team-a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if results, err := keychain.FindByTag(query, "team-a"); err != nil || len(results) != 1 || results[0].Account != "cache" {
+		t.Fatalf("expected only the cache tagged team-a, got %+v (%v)", results, err)
+	}
+
+	if err := keychain.AddTags(query, "a,b"); err == nil {
+		t.Fatal("expected an error for a tag with a comma")
+	}
+}
@@ -0,0 +1,181 @@
+package keychain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TombstoneSuffix is appended to the service of the tombstones written by
+// DeleteItemWithOptions, so that they don't match queries for the service.
+const TombstoneSuffix = "#tombstone"
+
+// MetadataExpiresKey is the metadata key holding when a tombstone expires, in
+// RFC 3339 format.
+const MetadataExpiresKey = "expires"
+
+// DeleteOptions are options of DeleteItemWithOptions.
+type DeleteOptions struct {
+	// Tombstone, if not zero, records the deletion of synchronizable generic
+	// passwords in a synchronizable tombstone, which CheckTombstone finds for
+	// this long on the other devices of the user, e.g. to sign out there too
+	// rather than wait for an item that will never sync.
+	Tombstone time.Duration
+}
+
+// DeleteItemWithOptions deletes the items matching item like DeleteItem, with
+// opts. Only the items matched with SynchronizableYes or SynchronizableAny
+// get tombstones.
+func DeleteItemWithOptions(item Item, opts DeleteOptions) error {
+	if opts.Tombstone == 0 {
+		return DeleteItem(item)
+	}
+
+	var synced []QueryResult
+
+	sync, _ := item.attr[SynchronizableKey].(Synchronizable)
+	if item.secClass() == SecClassGenericPassword && (sync == SynchronizableYes || sync == SynchronizableAny) {
+		q := item.clone()
+		q.SetSynchronizable(SynchronizableYes)
+
+		var err error
+		if synced, err = queryClass(SecClassGenericPassword, q); err != nil {
+			return err
+		}
+	}
+
+	if err := DeleteItem(item); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	m := Metadata{
+		MetadataDeletedKey: now.Format(time.RFC3339Nano),
+		MetadataExpiresKey: now.Add(opts.Tombstone).Format(time.RFC3339Nano),
+	}
+
+	for _, r := range synced {
+		if err := writeTombstone(r, m); err != nil {
+			return fmt.Errorf("failed to write the tombstone of %q: %w", r.Account, err)
+		}
+	}
+
+	return nil
+}
+
+// writeTombstone adds or replaces the tombstone of the item of r.
+func writeTombstone(r QueryResult, m Metadata) error {
+	query := tombstoneQuery(r.Service, r.Account)
+	query.SetAccessGroup(r.AccessGroup)
+
+	update := newItem()
+	if err := update.SetMetadata(m); err != nil {
+		return err
+	}
+
+	err := UpdateItem(query, update)
+	if errors.Is(err, ErrorItemNotFound) {
+		tombstone := query.clone()
+		tombstone.SetInvisible(true)
+		tombstone.SetData([]byte{})
+
+		if err := tombstone.SetMetadata(m); err != nil {
+			return err
+		}
+
+		err = AddItem(tombstone)
+	}
+
+	return err
+}
+
+// tombstoneQuery returns a query for the tombstones of service and account.
+// Tombstones are created without the defaults of NewItem, which may not
+// allow synchronization.
+func tombstoneQuery(service, account string) Item {
+	query := newItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService(service + TombstoneSuffix)
+	query.SetAccount(account)
+	query.SetSynchronizable(SynchronizableYes)
+
+	return query
+}
+
+// CheckTombstone returns when the generic password for service and account
+// was deleted with a tombstone, on this or another device, and true if the
+// tombstone hasn't expired. An expired tombstone is deleted. Tombstones are
+// kept when an item is added again; use the modification date of the item to
+// tell whether it is more recent.
+func CheckTombstone(service, account string) (time.Time, bool, error) {
+	results, err := queryClass(SecClassGenericPassword, tombstoneQuery(service, account))
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	var (
+		deleted time.Time
+		found   bool
+	)
+
+	for _, r := range results {
+		m := r.Metadata()
+
+		expires, err := time.Parse(time.RFC3339Nano, m[MetadataExpiresKey])
+		if err != nil || time.Now().After(expires) {
+			_ = DeleteItem(queryForTombstone(r))
+
+			continue
+		}
+
+		if t, err := time.Parse(time.RFC3339Nano, m[MetadataDeletedKey]); err == nil && (!found || t.After(deleted)) {
+			deleted, found = t, true
+		}
+	}
+
+	return deleted, found, nil
+}
+
+// PurgeTombstones deletes the expired tombstones, and returns how many were
+// deleted.
+func PurgeTombstones() (int, error) {
+	query := newItem()
+	query.SetSynchronizable(SynchronizableYes)
+	query.SetInvisible(true)
+
+	results, err := queryClass(SecClassGenericPassword, query)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+
+	for _, r := range results {
+		if !strings.HasSuffix(r.Service, TombstoneSuffix) {
+			continue
+		}
+
+		expires, err := time.Parse(time.RFC3339Nano, r.Metadata()[MetadataExpiresKey])
+		if err == nil && time.Now().Before(expires) {
+			continue
+		}
+
+		err = DeleteItem(queryForTombstone(r))
+		if err != nil && !errors.Is(err, ErrorItemNotFound) {
+			return n, fmt.Errorf("failed to delete the tombstone of %q: %w", r.Account, err)
+		}
+
+		n++
+	}
+
+	return n, nil
+}
+
+// queryForTombstone returns a query for the tombstone of r.
+func queryForTombstone(r QueryResult) Item {
+	query := queryForResult(SecClassGenericPassword, r)
+	query.SetSynchronizable(SynchronizableYes)
+
+	return query
+}
@@ -0,0 +1,62 @@
+package keychain_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func TestTombstone(t *testing.T) {
+	f := keychainfake.Install(t)
+
+	for _, account := range []string{"alice", "bob"} {
+		item := keychain.NewGenericPassword("com.example.app", account, "", []byte("token"), "")
+		item.SetSynchronizable(keychain.SynchronizableYes)
+
+		if err := keychain.AddItem(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	query := genericPasswordQuery("com.example.app", "alice")
+	query.SetSynchronizable(keychain.SynchronizableAny)
+
+	if err := keychain.DeleteItemWithOptions(query, keychain.DeleteOptions{Tombstone: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := keychain.GetString("com.example.app", "alice"); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected the item to be deleted, got %v", err)
+	}
+
+	deleted, ok, err := keychain.CheckTombstone("com.example.app", "alice")
+	if err != nil || !ok || time.Since(deleted) > time.Minute {
+		t.Fatalf("expected a recent tombstone, got %v, %v (%v)", deleted, ok, err)
+	}
+
+	if _, ok, err := keychain.CheckTombstone("com.example.app", "bob"); err != nil || ok {
+		t.Fatalf("expected no tombstone for bob, got %v (%v)", ok, err)
+	}
+
+	query = genericPasswordQuery("com.example.app", "bob")
+	query.SetSynchronizable(keychain.SynchronizableYes)
+
+	if err := keychain.DeleteItemWithOptions(query, keychain.DeleteOptions{Tombstone: time.Nanosecond}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := keychain.PurgeTombstones(); err != nil || n != 1 {
+		t.Fatalf("expected 1 expired tombstone, got %d (%v)", n, err)
+	}
+
+	if f.Len() != 1 {
+		t.Fatalf("expected only the tombstone of alice to remain, got %d items", f.Len())
+	}
+
+	if err := keychain.DeleteItemWithOptions(query, keychain.DeleteOptions{Tombstone: time.Hour}); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound, got %v", err)
+	}
+}
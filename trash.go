@@ -0,0 +1,166 @@
+package keychain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TrashSuffix is appended to the service of generic passwords moved to the
+// trash by SoftDelete, so that they no longer match queries for the service.
+const TrashSuffix = "#trash"
+
+// MetadataDeletedKey is the metadata key holding when an item was moved to the
+// trash, in RFC 3339 format.
+const MetadataDeletedKey = "deleted"
+
+// SoftDelete moves the generic passwords matching query to the trash instead
+// of deleting them, so that Restore can undo the deletion until EmptyTrash
+// removes them. Trashed items are invisible, their service has the
+// TrashSuffix, and their metadata records when they were deleted; their data
+// is kept. An item already in the trash for the same service and account is
+// replaced. It returns ErrorItemNotFound if no item matches.
+func SoftDelete(query Item) error {
+	results, err := queryClass(SecClassGenericPassword, query)
+	if err != nil {
+		return err
+	}
+
+	if len(results) == 0 {
+		return ErrorItemNotFound
+	}
+
+	deleted := time.Now().UTC().Format(time.RFC3339Nano)
+
+	for _, r := range results {
+		if strings.HasSuffix(r.Service, TrashSuffix) {
+			continue
+		}
+
+		m := Metadata{}
+		for k, v := range r.Metadata() {
+			m[k] = v
+		}
+
+		m[MetadataDeletedKey] = deleted
+
+		if err := moveItem(r, r.Service+TrashSuffix, true, m); err != nil {
+			return fmt.Errorf("failed to move %q to the trash: %w", r.Account, err)
+		}
+	}
+
+	return nil
+}
+
+// Restore moves the generic passwords matching query, for their original
+// service, back from the trash. It returns ErrorItemNotFound if no item in
+// the trash matches, and ErrorDuplicateItem if an item was added in place of
+// a deleted one meanwhile.
+func Restore(query Item) error {
+	q := query.clone()
+	if service, ok := q.attr[ServiceKey].(string); ok {
+		q.SetService(service + TrashSuffix)
+	}
+
+	results, err := queryTrash(q)
+	if err != nil {
+		return err
+	}
+
+	if len(results) == 0 {
+		return ErrorItemNotFound
+	}
+
+	for _, r := range results {
+		m := r.Metadata()
+		delete(m, MetadataDeletedKey)
+
+		if err := moveItem(r, strings.TrimSuffix(r.Service, TrashSuffix), false, m); err != nil {
+			return fmt.Errorf("failed to restore %q: %w", r.Account, err)
+		}
+	}
+
+	return nil
+}
+
+// EmptyTrash deletes the items moved to the trash more than age ago, and
+// returns how many were deleted. An age of zero empties the trash.
+func EmptyTrash(age time.Duration) (int, error) {
+	results, err := queryTrash(NewItem())
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+
+	for _, r := range results {
+		deleted, err := time.Parse(time.RFC3339Nano, r.Metadata()[MetadataDeletedKey])
+		if err == nil && time.Since(deleted) < age {
+			continue
+		}
+
+		err = DeleteItem(queryForResult(SecClassGenericPassword, r))
+		if err != nil && !errors.Is(err, ErrorItemNotFound) {
+			return n, fmt.Errorf("failed to delete %q: %w", r.Account, err)
+		}
+
+		n++
+	}
+
+	return n, nil
+}
+
+// queryTrash returns the items in the trash matching query.
+func queryTrash(query Item) ([]QueryResult, error) {
+	q := query.clone()
+	q.SetInvisible(true)
+
+	results, err := queryClass(SecClassGenericPassword, q)
+	if err != nil {
+		return nil, err
+	}
+
+	trash := results[:0]
+
+	for _, r := range results {
+		if strings.HasSuffix(r.Service, TrashSuffix) {
+			trash = append(trash, r)
+		}
+	}
+
+	return trash, nil
+}
+
+// moveItem changes the service, visibility and metadata of the item of r. An
+// item moved to the trash replaces the one already there.
+func moveItem(r QueryResult, service string, invisible bool, m Metadata) error {
+	update := newItem()
+	update.SetService(service)
+	update.SetInvisible(invisible)
+
+	if err := update.SetMetadata(m); err != nil {
+		return err
+	}
+
+	if len(m) == 0 {
+		// Clear the metadata of the item.
+		update.SetGeneric([]byte{})
+	}
+
+	query := queryForResult(SecClassGenericPassword, r)
+
+	err := UpdateItem(query, update)
+	if invisible && errors.Is(err, ErrorDuplicateItem) {
+		old := query.clone()
+		old.SetService(service)
+
+		if err := DeleteItem(old); err != nil {
+			return err
+		}
+
+		err = UpdateItem(query, update)
+	}
+
+	return err
+}
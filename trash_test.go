@@ -0,0 +1,86 @@
+package keychain_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func TestSoftDelete(t *testing.T) {
+	keychainfake.Install(t)
+
+	if err := keychain.SetString("MyService", "gabriel", "toomanysecrets"); err != nil {
+		t.Fatal(err)
+	}
+
+	query := keychain.NewItem()
+	query.SetService("MyService")
+	query.SetAccount("gabriel")
+
+	if err := keychain.SoftDelete(query); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := keychain.GetString("MyService", "gabriel"); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected the item to be gone, got %v", err)
+	}
+
+	// Deleting a new item with the same name replaces the trashed one.
+	if err := keychain.SetString("MyService", "gabriel", "new"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.SoftDelete(query); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.Restore(query); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := keychain.GetString("MyService", "gabriel")
+	if err != nil || s != "new" {
+		t.Fatalf("unexpected restored data %q (%v)", s, err)
+	}
+
+	items, err := keychain.QueryGenericPasswordItems(query)
+	if err != nil || len(items) != 1 || len(items[0].Generic) != 0 {
+		t.Fatalf("expected the deletion metadata to be removed, got %+v (%v)", items, err)
+	}
+
+	if err := keychain.Restore(query); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound, got %v", err)
+	}
+}
+
+func TestEmptyTrash(t *testing.T) {
+	keychainfake.Install(t)
+
+	for _, account := range []string{"gabriel", "alice"} {
+		if err := keychain.SetString("MyService", account, "toomanysecrets"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	query := keychain.NewItem()
+	query.SetService("MyService")
+
+	if err := keychain.SoftDelete(query); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := keychain.EmptyTrash(time.Hour); err != nil || n != 0 {
+		t.Fatalf("expected recent items to be kept, deleted %d (%v)", n, err)
+	}
+
+	if n, err := keychain.EmptyTrash(0); err != nil || n != 2 {
+		t.Fatalf("expected 2 items to be deleted, deleted %d (%v)", n, err)
+	}
+
+	if err := keychain.Restore(query); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected an empty trash, got %v", err)
+	}
+}
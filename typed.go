@@ -0,0 +1,160 @@
+package keychain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// GetBytes returns the data of the generic password for service and account.
+// Unlike GetGenericPassword, a missing item is reported as ErrorItemNotFound,
+// and an item with empty data returns an empty, non-nil slice.
+func GetBytes(service, account string) ([]byte, error) {
+	return cur().getBytes(service, account)
+}
+
+func (s *state) getBytes(service, account string) ([]byte, error) {
+	r, err := s.getResult(service, account)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := s.reverseResult(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return []byte{}, nil
+	}
+
+	return data, nil
+}
+
+// SetBytes stores data in the generic password for service and account,
+// adding the item or replacing its data. The data is transformed by the
+// pipeline of the configuration, if any, see Config.Pipeline.
+func SetBytes(service, account string, data []byte) error {
+	return cur().setBytes(service, account, data)
+}
+
+// GetString returns the data of the generic password for service and account
+// as a string, or ErrorItemNotFound if the item doesn't exist.
+func GetString(service, account string) (string, error) {
+	data, err := GetBytes(service, account)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// SetString stores s in the generic password for service and account.
+func SetString(service, account string, s string) error {
+	return SetBytes(service, account, []byte(s))
+}
+
+// GetJSON decodes the JSON data of the generic password for service and
+// account into a T, or returns ErrorItemNotFound if the item doesn't exist.
+func GetJSON[T any](service, account string) (T, error) {
+	var v T
+
+	data, err := GetBytes(service, account)
+	if err != nil {
+		return v, err
+	}
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, fmt.Errorf("failed to decode %q: %w", account, err)
+	}
+
+	return v, nil
+}
+
+// SetJSON stores v encoded as JSON in the generic password for service and
+// account.
+func SetJSON[T any](service, account string, v T) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %q: %w", account, err)
+	}
+
+	return SetBytes(service, account, data)
+}
+
+// ErrUnknownCodec is returned by GetValue for values written with a codec
+// that isn't registered, see RegisterCodec.
+var ErrUnknownCodec = errors.New("unknown codec")
+
+// SetValue stores v encoded with codec in the generic password for service
+// and account, recording the codec in the item metadata for GetValue. Other
+// metadata of the item is kept. Like SetBytes, the encoded value is
+// transformed by the pipeline of the configuration, if any.
+func SetValue[T any](service, account string, v T, codec Codec) error {
+	data, err := marshalItem(codec, service, account, v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %q: %w", account, err)
+	}
+
+	return cur().setData(service, account, data, Metadata{MetadataCodecKey: codec.ID()})
+}
+
+// GetValue decodes the generic password for service and account into a T with
+// the codec recorded by SetValue, or JSONCodec for values written otherwise
+// (e.g. with SetJSON). It returns ErrorItemNotFound if the item doesn't exist.
+// Values written with SetValue must only be replaced with SetValue, which
+// keeps the recorded codec current.
+func GetValue[T any](service, account string) (T, error) {
+	var v T
+
+	s := cur()
+
+	r, err := s.getResult(service, account)
+	if err != nil {
+		return v, err
+	}
+
+	data, err := s.reverseResult(r)
+	if err != nil {
+		return v, err
+	}
+
+	id, ok := r.Metadata()[MetadataCodecKey]
+	if !ok {
+		id = JSONCodec.ID()
+	}
+
+	codec, ok := lookupCodec(id)
+	if !ok {
+		return v, fmt.Errorf("failed to decode %q: %w %q", account, ErrUnknownCodec, id)
+	}
+
+	if err := unmarshalItem(codec, service, account, data, &v); err != nil {
+		return v, fmt.Errorf("failed to decode %q: %w", account, err)
+	}
+
+	return v, nil
+}
+
+// getResult returns the attributes and data of the generic password for
+// service and account, or ErrorItemNotFound.
+func (s *state) getResult(service, account string) (QueryResult, error) {
+	query := s.newItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService(service)
+	query.SetAccount(account)
+	query.SetMatchLimit(MatchLimitOne)
+	query.SetReturnAttributes(true)
+	query.SetReturnData(true)
+
+	results, err := s.queryItem(query)
+	if err != nil {
+		return QueryResult{}, err
+	}
+
+	if len(results) == 0 {
+		return QueryResult{}, ErrorItemNotFound
+	}
+
+	return results[0], nil
+}
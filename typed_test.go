@@ -0,0 +1,42 @@
+//go:build darwin && !ios
+// +build darwin,!ios
+
+package keychain
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTypedGetters(t *testing.T) {
+	service := "TestTypedGetters"
+	defer func() { _ = DeleteGenericPasswordItem(service, "empty") }()
+	defer func() { _ = DeleteGenericPasswordItem(service, "json") }()
+
+	if _, err := GetString(service, "missing"); !errors.Is(err, ErrorItemNotFound) {
+		t.Fatalf("expected ErrorItemNotFound, got %v", err)
+	}
+
+	if err := SetString(service, "empty", ""); err != nil {
+		t.Fatal(err)
+	}
+	s, err := GetString(service, "empty")
+	if err != nil || s != "" {
+		t.Fatalf("expected empty string, got %q (%v)", s, err)
+	}
+
+	type token struct {
+		Value  string
+		Scopes []string
+	}
+	if err := SetJSON(service, "json", token{Value: "t", Scopes: []string{"read"}}); err != nil {
+		t.Fatal(err)
+	}
+	tok, err := GetJSON[token](service, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.Value != "t" || len(tok.Scopes) != 1 {
+		t.Fatalf("unexpected token: %+v", tok)
+	}
+}
@@ -0,0 +1,191 @@
+package keychain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// uniqueKeys are the attributes which, with the class, identify a generic
+// password for the uniqueness layer.
+var uniqueKeys = []string{ServiceKey, AccountKey, AccessGroupKey}
+
+// uniqueBackend is the uniqueness layer, rejecting duplicate generic passwords
+// for backends without native duplicate detection.
+type uniqueBackend struct {
+	Backend
+}
+
+func withUniqueKeys(b Backend) Backend {
+	if CapabilitiesOf(b).UniqueKeys {
+		return b
+	}
+
+	return uniqueBackend{b}
+}
+
+func stringAttr(attrs map[string]interface{}, key string) string {
+	s, _ := attrs[key].(string)
+
+	return s
+}
+
+// sameKey returns whether a and b have the same unique key attributes.
+func sameKey(a, b map[string]interface{}) bool {
+	for _, key := range uniqueKeys {
+		if stringAttr(a, key) != stringAttr(b, key) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matching returns the attributes of the generic passwords matching query.
+func (u uniqueBackend) matching(query map[string]interface{}) ([]map[string]interface{}, error) {
+	q := map[string]interface{}{
+		SecClassKey:         query[SecClassKey],
+		ReturnAttributesKey: true,
+		MatchLimitKey:       "m_LimitAll",
+	}
+
+	for key, v := range query {
+		if !isControlKey(key) && key != DataKey {
+			q[key] = v
+		}
+	}
+
+	result, err := u.Backend.CopyMatching(q)
+	if errors.Is(err, ErrorItemNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var items []map[string]interface{}
+
+	switch r := result.(type) {
+	case map[string]interface{}:
+		items = append(items, r)
+	case []interface{}:
+		for _, e := range r {
+			if d, ok := e.(map[string]interface{}); ok {
+				items = append(items, d)
+			}
+		}
+	}
+
+	return items, nil
+}
+
+// taken returns whether a generic password other than those in except has the
+// unique key of attrs.
+func (u uniqueBackend) taken(attrs map[string]interface{}, except []map[string]interface{}) (bool, error) {
+	// Missing attributes must match missing attributes, which can't be
+	// queried, so only the service narrows the query.
+	query := map[string]interface{}{SecClassKey: attrs[SecClassKey]}
+	if service, ok := attrs[ServiceKey]; ok {
+		query[ServiceKey] = service
+	}
+
+	items, err := u.matching(query)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for duplicate items: %w", err)
+	}
+
+next:
+	for _, item := range items {
+		for _, e := range except {
+			if sameKey(item, e) {
+				continue next
+			}
+		}
+
+		if sameKey(item, attrs) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// isControlKey returns whether key is a search, return or use option rather
+// than an item attribute.
+func isControlKey(key string) bool {
+	return len(key) > 2 && key[1] == '_' && (key[0] == 'm' || key[0] == 'r' || key[0] == 'u')
+}
+
+func (u uniqueBackend) Add(attrs map[string]interface{}) (interface{}, error) {
+	if attrs[SecClassKey] == "genp" {
+		taken, err := u.taken(attrs, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if taken {
+			return nil, ErrorDuplicateItem
+		}
+	}
+
+	return u.Backend.Add(attrs)
+}
+
+func (u uniqueBackend) Update(query, attrs map[string]interface{}) error {
+	changesKey := false
+
+	for _, key := range uniqueKeys {
+		if _, ok := attrs[key]; ok {
+			changesKey = true
+		}
+	}
+
+	if query[SecClassKey] != "genp" || !changesKey {
+		return u.Backend.Update(query, attrs)
+	}
+
+	items, err := u.matching(query)
+	if err != nil {
+		return fmt.Errorf("failed to check for duplicate items: %w", err)
+	}
+
+	var targets []map[string]interface{}
+
+	for _, item := range items {
+		target := map[string]interface{}{SecClassKey: "genp"}
+
+		for _, key := range uniqueKeys {
+			target[key] = item[key]
+			if v, ok := attrs[key]; ok {
+				target[key] = v
+			}
+		}
+
+		for _, t := range targets {
+			if sameKey(t, target) {
+				return ErrorDuplicateItem
+			}
+		}
+
+		targets = append(targets, target)
+
+		taken, err := u.taken(target, items)
+		if err != nil {
+			return err
+		}
+
+		if taken {
+			return ErrorDuplicateItem
+		}
+	}
+
+	return u.Backend.Update(query, attrs)
+}
+
+// Capabilities implements CapabilityReporter.
+func (u uniqueBackend) Capabilities() Capability {
+	c := CapabilitiesOf(u.Backend)
+	c.UniqueKeys = true
+
+	return c
+}
@@ -0,0 +1,119 @@
+package keychain
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// URLMatchOptions are options of FindInternetPasswordsForURLWithOptions.
+type URLMatchOptions struct {
+	// ParentDomains also matches the passwords of parent domains of the host,
+	// e.g. those of example.com for login.example.com. Top level domains
+	// never match.
+	ParentDomains bool
+}
+
+// FindInternetPasswordsForURL returns the internet passwords for u, like a
+// browser filling a login form: the protocol must be that of the scheme and
+// the server the host, case insensitively. An item without a port matches the
+// default port of the scheme, and an item without a path, or with "/",
+// matches any path, while an item with a path only matches u below it. The
+// most specific matches come first: exact ports and longer paths, then the
+// most recently modified. The results have attributes and persistent
+// references but no data, which can then be read by persistent reference.
+func FindInternetPasswordsForURL(u *url.URL) ([]InternetPasswordResult, error) {
+	return FindInternetPasswordsForURLWithOptions(u, URLMatchOptions{})
+}
+
+// FindInternetPasswordsForURLWithOptions returns the internet passwords for u
+// like FindInternetPasswordsForURL, with opts. Matches of parent domains come
+// after those of the host, closest domains first.
+func FindInternetPasswordsForURLWithOptions(u *url.URL, opts URLMatchOptions) ([]InternetPasswordResult, error) {
+	loc, err := locationOf(u)
+	if err != nil {
+		return nil, err
+	}
+
+	// Servers are compared in Go, the keychain matches them case sensitively.
+	query := NewItem()
+	query.SetProtocol(loc.protocol)
+
+	results, err := queryClass(SecClassInternetPassword, query)
+	if err != nil {
+		return nil, err
+	}
+
+	type match struct {
+		r     QueryResult
+		depth int
+	}
+
+	var matches []match
+
+	for _, r := range results {
+		depth, ok := domainDepth(loc.server, strings.ToLower(r.Server), opts.ParentDomains)
+		if !ok || !portMatches(r.Port, loc.port, loc.protocol) || !pathMatches(r.Path, loc.path) {
+			continue
+		}
+
+		matches = append(matches, match{r, depth})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+
+		switch {
+		case a.depth != b.depth:
+			return a.depth < b.depth
+		case (a.r.Port != 0) != (b.r.Port != 0):
+			return a.r.Port != 0
+		case len(strings.TrimSuffix(a.r.Path, "/")) != len(strings.TrimSuffix(b.r.Path, "/")):
+			return len(strings.TrimSuffix(a.r.Path, "/")) > len(strings.TrimSuffix(b.r.Path, "/"))
+		default:
+			return a.r.ModificationDate.After(b.r.ModificationDate)
+		}
+	})
+
+	items := make([]InternetPasswordResult, 0, len(matches))
+	for _, m := range matches {
+		items = append(items, internetPasswordResult(m.r))
+	}
+
+	return items, nil
+}
+
+// domainDepth returns the number of labels removed from host to get server, 0
+// if they are the same, or false if server isn't host or, with parents, one of
+// its parent domains other than a top level domain.
+func domainDepth(host, server string, parents bool) (int, bool) {
+	if server == host {
+		return 0, true
+	}
+
+	if !parents || !strings.Contains(server, ".") || !strings.HasSuffix(host, "."+server) {
+		return 0, false
+	}
+
+	return strings.Count(strings.TrimSuffix(host, server), "."), true
+}
+
+// portMatches returns whether an item with port matches the port of a URL.
+func portMatches(port, urlPort int32, protocol string) bool {
+	if port == 0 {
+		return urlPort == defaultPorts[protocol]
+	}
+
+	return port == urlPort
+}
+
+// pathMatches returns whether an item with path matches urlPath, which is
+// below it.
+func pathMatches(path, urlPath string) bool {
+	path = strings.TrimSuffix(path, "/")
+	if path == "" || path == urlPath {
+		return true
+	}
+
+	return strings.HasPrefix(urlPath, path+"/")
+}
@@ -0,0 +1,72 @@
+package keychain_test
+
+import (
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func TestFindInternetPasswordsForURL(t *testing.T) {
+	keychainfake.Install(t)
+
+	for _, c := range []struct{ account, url string }{
+		{"site", "https://example.com"},
+		{"login", "https://example.com/login"},
+		{"other-path", "https://example.com/admin"},
+		{"explicit-port", "https://example.com:443"},
+		{"other-port", "https://example.com:8443"},
+		{"insecure", "http://example.com"},
+		{"subdomain", "https://login.example.com"},
+		{"parent", "https://EXAMPLE.org"},
+	} {
+		if err := keychain.SaveCredential(keychain.Credential{Username: c.account, Password: "secret", URL: c.url}); err != nil {
+			t.Fatal(err)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	accounts := func(rawURL string, opts keychain.URLMatchOptions) []string {
+		t.Helper()
+
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		results, err := keychain.FindInternetPasswordsForURLWithOptions(u, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var names []string
+		for _, r := range results {
+			names = append(names, r.Account)
+		}
+
+		return names
+	}
+
+	tests := []struct {
+		url  string
+		opts keychain.URLMatchOptions
+		want []string
+	}{
+		{"https://Example.com/login/form", keychain.URLMatchOptions{}, []string{"login", "explicit-port", "site"}},
+		{"https://example.com:8443/", keychain.URLMatchOptions{}, []string{"other-port"}},
+		{"http://example.com/", keychain.URLMatchOptions{}, []string{"insecure"}},
+		{"https://www.login.example.org/", keychain.URLMatchOptions{}, nil},
+		{"https://www.login.example.org/", keychain.URLMatchOptions{ParentDomains: true}, []string{"parent"}},
+		{"https://login.example.com/", keychain.URLMatchOptions{ParentDomains: true}, []string{"subdomain", "explicit-port", "site"}},
+	}
+
+	for _, tt := range tests {
+		if got := accounts(tt.url, tt.opts); !slices.Equal(got, tt.want) {
+			t.Errorf("%s %+v: got %v, want %v", tt.url, tt.opts, got, tt.want)
+		}
+	}
+}
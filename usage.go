@@ -0,0 +1,234 @@
+package keychain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UsageServicePrefix prefixes the service of the generic password items
+// holding usage records, see SetUsageTracking. The usage of the account
+// "gabriel" of "MyService" is stored in the account "gabriel" of
+// "go-keychain.usage:MyService".
+const UsageServicePrefix = "go-keychain.usage:"
+
+// Usage records how often and when a generic password was read.
+type Usage struct {
+	Service  string    `json:"-"`
+	Account  string    `json:"-"`
+	Count    int64     `json:"count"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+type usageKey struct {
+	s                *state
+	service, account string
+}
+
+var usageState = struct {
+	sync.Mutex
+	pending map[usageKey]Usage
+	running bool
+	idle    sync.Cond
+	err     error
+}{}
+
+func init() {
+	usageState.idle.L = &usageState.Mutex
+}
+
+// SetUsageTracking enables or disables usage tracking and returns the previous
+// setting. While enabled, each generic password whose data is read with
+// QueryItem (or a convenience method like GetGenericPassword) has its read
+// count and last read time recorded in a separate item, see Usage. Records
+// are written in the background; FlushUsage waits for them.
+func SetUsageTracking(enabled bool) bool {
+	return updateConfig(func(cfg *Config) { cfg.UsageTracking = enabled }).UsageTracking
+}
+
+// usageService returns the service of the usage records of service.
+func usageService(service string) string {
+	return UsageServicePrefix + service
+}
+
+// recordUses records the use of the generic passwords whose data was returned
+// for query, if usage tracking is enabled. Results without service or account
+// attributes are attributed to those of the query.
+func (s *state) recordUses(query map[string]interface{}, results []QueryResult) {
+	if query[SecClassKey] != "genp" || !s.cfg.UsageTracking || CapabilitiesOf(s.backend).ReadOnly {
+		return
+	}
+
+	usageState.Lock()
+	defer usageState.Unlock()
+
+	now := time.Now()
+	queryService, _ := query[ServiceKey].(string)
+	queryAccount, _ := query[AccountKey].(string)
+
+	for _, r := range results {
+		key := usageKey{s: s, service: r.Service, account: r.Account}
+		if key.service == "" {
+			key.service = queryService
+		}
+
+		if key.account == "" {
+			key.account = queryAccount
+		}
+
+		if r.Data == nil || key.service == "" || key.account == "" ||
+			strings.HasPrefix(key.service, UsageServicePrefix) || key.account == JournalAccount {
+			continue
+		}
+
+		if usageState.pending == nil {
+			usageState.pending = make(map[usageKey]Usage)
+		}
+
+		u := usageState.pending[key]
+		u.Count++
+		u.LastUsed = now
+		usageState.pending[key] = u
+	}
+
+	if len(usageState.pending) > 0 && !usageState.running {
+		usageState.running = true
+
+		go writeUsages()
+	}
+}
+
+// writeUsages writes the pending usage records until there are none.
+func writeUsages() {
+	for {
+		usageState.Lock()
+		pending := usageState.pending
+		usageState.pending = nil
+
+		if len(pending) == 0 {
+			usageState.running = false
+			usageState.idle.Broadcast()
+			usageState.Unlock()
+
+			return
+		}
+
+		usageState.Unlock()
+
+		for key, u := range pending {
+			if err := addUsage(key, u); err != nil {
+				usageState.Lock()
+				usageState.err = errors.Join(usageState.err, err)
+				usageState.Unlock()
+			}
+		}
+	}
+}
+
+// addUsage adds the counts of u to the usage record of key.
+func addUsage(key usageKey, u Usage) error {
+	stored, err := key.s.getUsage(key.service, key.account)
+	if err != nil {
+		return err
+	}
+
+	stored.Count += u.Count
+	if u.LastUsed.After(stored.LastUsed) {
+		stored.LastUsed = u.LastUsed
+	}
+
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return fmt.Errorf("failed to encode usage of %q: %w", key.account, err)
+	}
+
+	if err := key.s.upsertGenericPassword(usageService(key.service), key.account, "", data); err != nil {
+		return fmt.Errorf("failed to write usage of %q: %w", key.account, err)
+	}
+
+	return nil
+}
+
+// FlushUsage waits until the usage records of the reads so far are written,
+// and returns the errors writing them since the last call.
+func FlushUsage() error {
+	usageState.Lock()
+	defer usageState.Unlock()
+
+	for usageState.running {
+		usageState.idle.Wait()
+	}
+
+	err := usageState.err
+	usageState.err = nil
+
+	return err
+}
+
+// GetUsage returns the usage of the generic password for service and account.
+// A password without usage record has a zero Count and LastUsed.
+func GetUsage(service, account string) (Usage, error) {
+	return cur().getUsage(service, account)
+}
+
+func (s *state) getUsage(service, account string) (Usage, error) {
+	u := Usage{Service: service, Account: account}
+
+	data, err := s.getBytes(usageService(service), account)
+	if errors.Is(err, ErrorItemNotFound) {
+		return u, nil
+	}
+
+	if err != nil {
+		return u, err
+	}
+
+	if err := json.Unmarshal(data, &u); err != nil {
+		return u, fmt.Errorf("invalid usage record for %q: %w", account, err)
+	}
+
+	return u, nil
+}
+
+// UnusedSince returns the accounts of the generic passwords of service that
+// haven't been read since t, including those never read while usage tracking
+// was enabled, e.g. to clean up stale credentials.
+func UnusedSince(service string, t time.Time) ([]string, error) {
+	accounts, err := GetGenericPasswordAccounts(service)
+	if err != nil {
+		return nil, err
+	}
+
+	var unused []string
+
+	for _, account := range accounts {
+		if account == JournalAccount {
+			continue
+		}
+
+		u, err := GetUsage(service, account)
+		if err != nil {
+			return nil, err
+		}
+
+		if u.LastUsed.Before(t) {
+			unused = append(unused, account)
+		}
+	}
+
+	return unused, nil
+}
+
+// DeleteUsage deletes the usage record of the generic password for service
+// and account, e.g. after deleting the password.
+func DeleteUsage(service, account string) error {
+	err := DeleteGenericPasswordItem(usageService(service), account)
+	if errors.Is(err, ErrorItemNotFound) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,62 @@
+package keychain_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/keychainfake"
+)
+
+func TestUsageTracking(t *testing.T) {
+	keychainfake.Install(t)
+
+	defer keychain.SetUsageTracking(keychain.SetUsageTracking(true))
+
+	start := time.Now()
+
+	for _, account := range []string{"gabriel", "alice"} {
+		if err := keychain.SetString("MyService", account, "toomanysecrets"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := keychain.GetString("MyService", "gabriel"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := keychain.FlushUsage(); err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := keychain.GetUsage("MyService", "gabriel")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.Count != 3 || u.LastUsed.Before(start) {
+		t.Fatalf("unexpected usage %+v", u)
+	}
+
+	// Usage records are not passwords of the service.
+	accounts, err := keychain.GetGenericPasswordAccounts("MyService")
+	if err != nil || len(accounts) != 2 {
+		t.Fatalf("unexpected accounts %v, %v", accounts, err)
+	}
+
+	unused, err := keychain.UnusedSince("MyService", start)
+	if err != nil || !reflect.DeepEqual(unused, []string{"alice"}) {
+		t.Fatalf("expected alice to be unused, got %v, %v", unused, err)
+	}
+
+	if err := keychain.DeleteUsage("MyService", "gabriel"); err != nil {
+		t.Fatal(err)
+	}
+
+	if u, _ := keychain.GetUsage("MyService", "gabriel"); u.Count != 0 {
+		t.Fatalf("usage not deleted: %+v", u)
+	}
+}
@@ -0,0 +1,246 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	keychain "github.com/mailstone/go-keychain"
+)
+
+// errNotFound is returned for requests answered with 404 Not Found.
+var errNotFound = errors.New("not found")
+
+// errCASMismatch is returned for writes of a secret changed concurrently.
+var errCASMismatch = errors.New("check-and-set parameter did not match the current version")
+
+// escapePath escapes the segments of a secret path.
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func (b *Backend) secretPath(kind, service string) string {
+	return b.cfg.Mount + "/" + kind + "/" + escapePath(b.cfg.Prefix+service)
+}
+
+// login logs in with AppRole and returns the client token.
+func (b *Backend) login() (string, error) {
+	in := map[string]string{"role_id": b.cfg.RoleID, "secret_id": b.cfg.SecretID}
+
+	var out struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+
+	if err := b.send(http.MethodPost, "auth/"+b.cfg.AppRoleMount+"/login", "", in, &out); err != nil {
+		return "", fmt.Errorf("vault AppRole login failed: %w", err)
+	}
+
+	return out.Auth.ClientToken, nil
+}
+
+// request makes an authenticated request, logging in again with AppRole if
+// the token was rejected.
+func (b *Backend) request(method, path string, in, out interface{}) error {
+	b.auth.mu.Lock()
+	token := b.auth.token
+	b.auth.mu.Unlock()
+
+	canLogin := b.cfg.Token == "" && b.cfg.RoleID != ""
+
+	for attempt := 0; ; attempt++ {
+		if token == "" {
+			var err error
+			if token, err = b.login(); err != nil {
+				return err
+			}
+
+			b.auth.mu.Lock()
+			b.auth.token = token
+			b.auth.mu.Unlock()
+		}
+
+		err := b.send(method, path, token, in, out)
+		if !errors.Is(err, keychain.ErrorAuthFailed) || !canLogin || attempt > 0 || b.ctx.Err() != nil {
+			return err
+		}
+
+		token = ""
+	}
+}
+
+// send makes a request to the Vault API.
+func (b *Backend) send(method, path, token string, in, out interface{}) error {
+	var body io.Reader
+
+	if in != nil {
+		buf, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode vault request: %w", err)
+		}
+
+		body = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequestWithContext(b.ctx, method, strings.TrimSuffix(b.cfg.Address, "/")+"/v1/"+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create vault request: %w", err)
+	}
+
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+
+	if b.cfg.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", b.cfg.Namespace)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault %s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return errNotFound
+	case resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("vault %s %s: %w", method, path, keychain.ErrorAuthFailed)
+	case resp.StatusCode >= 300:
+		var e struct {
+			Errors []string `json:"errors"`
+		}
+
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+
+		msg := strings.Join(e.Errors, "; ")
+		if strings.Contains(msg, "check-and-set") {
+			return errCASMismatch
+		}
+
+		return fmt.Errorf("vault %s %s: %s: %s", method, path, resp.Status, msg)
+	case out == nil || resp.StatusCode == http.StatusNoContent:
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode vault response: %w", err)
+	}
+
+	return nil
+}
+
+// read returns the accounts of service and the version of its secret, which
+// is 0 if the secret doesn't exist.
+func (b *Backend) read(service string) (map[string]string, int, error) {
+	var out struct {
+		Data struct {
+			Data     map[string]string `json:"data"`
+			Metadata struct {
+				Version int `json:"version"`
+			} `json:"metadata"`
+		} `json:"data"`
+	}
+
+	err := b.request(http.MethodGet, b.secretPath("data", service), nil, &out)
+	if errors.Is(err, errNotFound) {
+		// The latest version may be deleted, but writes still need to know
+		// it.
+		var meta struct {
+			Data struct {
+				CurrentVersion int `json:"current_version"`
+			} `json:"data"`
+		}
+
+		err = b.request(http.MethodGet, b.secretPath("metadata", service), nil, &meta)
+		if errors.Is(err, errNotFound) {
+			return map[string]string{}, 0, nil
+		}
+
+		return map[string]string{}, meta.Data.CurrentVersion, err
+	}
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if out.Data.Data == nil {
+		out.Data.Data = map[string]string{}
+	}
+
+	return out.Data.Data, out.Data.Metadata.Version, nil
+}
+
+// modify applies change to the accounts of service and saves them if change
+// returns true, retrying if the secret is changed concurrently.
+func (b *Backend) modify(service string, change func(data map[string]string) (bool, error)) error {
+	for attempt := 0; ; attempt++ {
+		data, version, err := b.read(service)
+		if err != nil {
+			return err
+		}
+
+		changed, err := change(data)
+		if err != nil || !changed {
+			return err
+		}
+
+		if len(data) == 0 {
+			err = b.request(http.MethodDelete, b.secretPath("metadata", service), nil, nil)
+		} else {
+			in := map[string]interface{}{
+				"options": map[string]int{"cas": version},
+				"data":    data,
+			}
+			err = b.request(http.MethodPost, b.secretPath("data", service), in, nil)
+		}
+
+		if !errors.Is(err, errCASMismatch) || attempt+1 == casRetries {
+			return err
+		}
+
+		if err := b.ctx.Err(); err != nil {
+			return fmt.Errorf("vault change of %q not retried: %w", service, err)
+		}
+	}
+}
+
+// list returns the services stored under the prefix.
+func (b *Backend) list() ([]string, error) {
+	var out struct {
+		Data struct {
+			Keys []string `json:"keys"`
+		} `json:"data"`
+	}
+
+	err := b.request("LIST", b.cfg.Mount+"/metadata/"+escapePath(b.cfg.Prefix), nil, &out)
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	services := make([]string, 0, len(out.Data.Keys))
+
+	for _, key := range out.Data.Keys {
+		// Keys ending with a slash are folders of further secrets.
+		if !strings.HasSuffix(key, "/") {
+			services = append(services, key)
+		}
+	}
+
+	return services, nil
+}
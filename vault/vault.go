@@ -0,0 +1,502 @@
+// Package vault implements a keychain backend storing generic passwords in a
+// HashiCorp Vault KV version 2 secrets engine, so server deployments of code
+// written against the keychain package can use centralized secrets while
+// laptops use the keychain.
+//
+// Each service is a secret at Config.Prefix followed by the service name, and
+// each account a key of that secret holding the password as a string:
+//
+//	vault kv get -field=alice secret/myapp/db
+//
+// Only the service, account and data of generic passwords are stored; other
+// attributes are ignored when adding items, queries matching them fail with
+// keychain.ErrorUnimplemented and persistent references are unsupported.
+//
+// Importing the package registers the backend, configured from the
+// environment (see ConfigFromEnv):
+//
+//	import _ "github.com/mailstone/go-keychain/vault"
+//
+//	err := keychain.UseBackend(vault.Name)
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	keychain "github.com/mailstone/go-keychain"
+)
+
+// Name is the name the backend is registered under, see keychain.UseBackend.
+const Name = "vault"
+
+func init() {
+	keychain.RegisterBackend(Name, func() (keychain.Backend, error) {
+		return Open(ConfigFromEnv())
+	})
+}
+
+// casRetries is the number of times a change is retried when the secret was
+// changed concurrently.
+const casRetries = 3
+
+// Config configures the connection to Vault.
+type Config struct {
+	// Address is the address of the Vault server, e.g.
+	// https://vault.example.com:8200.
+	Address string
+	// Namespace is the Vault Enterprise namespace, if any.
+	Namespace string
+	// Token authenticates requests. If it is empty, the backend logs in with
+	// AppRole using RoleID and SecretID, and logs in again when the token
+	// expires.
+	Token string
+	// RoleID and SecretID are the AppRole credentials.
+	RoleID   string
+	SecretID string
+	// AppRoleMount is the path the AppRole auth method is mounted at. It
+	// defaults to "approle".
+	AppRoleMount string
+	// Mount is the path the KV secrets engine is mounted at. It defaults to
+	// "secret".
+	Mount string
+	// Prefix is prepended to service names to form secret paths, e.g.
+	// "myapp/".
+	Prefix string
+	// HTTPClient is the client used for requests. It defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// ConfigFromEnv returns a Config from the VAULT_ADDR, VAULT_NAMESPACE,
+// VAULT_TOKEN, VAULT_ROLE_ID, VAULT_SECRET_ID, VAULT_KV_MOUNT and
+// VAULT_KV_PREFIX environment variables.
+func ConfigFromEnv() Config {
+	return Config{
+		Address:   os.Getenv("VAULT_ADDR"),
+		Namespace: os.Getenv("VAULT_NAMESPACE"),
+		Token:     os.Getenv("VAULT_TOKEN"),
+		RoleID:    os.Getenv("VAULT_ROLE_ID"),
+		SecretID:  os.Getenv("VAULT_SECRET_ID"),
+		Mount:     os.Getenv("VAULT_KV_MOUNT"),
+		Prefix:    os.Getenv("VAULT_KV_PREFIX"),
+	}
+}
+
+// Backend is a keychain.Backend storing items in Vault.
+type Backend struct {
+	cfg    Config
+	client *http.Client
+	// ctx bounds the requests and their retries, see WithContext.
+	ctx  context.Context
+	auth *auth
+}
+
+// auth is the client token, shared by the copies of WithContext.
+type auth struct {
+	mu    sync.Mutex
+	token string
+}
+
+// Open returns a backend for the Vault server configured by cfg. No request
+// is made until the backend is used.
+func Open(cfg Config) (*Backend, error) {
+	if cfg.Address == "" {
+		return nil, errors.New("vault address is not set")
+	}
+
+	if cfg.Token == "" && (cfg.RoleID == "" || cfg.SecretID == "") {
+		return nil, errors.New("vault token or AppRole credentials are not set")
+	}
+
+	if cfg.AppRoleMount == "" {
+		cfg.AppRoleMount = "approle"
+	}
+
+	if cfg.Mount == "" {
+		cfg.Mount = "secret"
+	}
+
+	client := cfg.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &Backend{cfg: cfg, client: client, ctx: context.Background(), auth: &auth{token: cfg.Token}}, nil
+}
+
+// WithContext returns a copy of the backend whose requests, including logging
+// in again and retrying changes made concurrently, are cancelled when ctx is
+// done, e.g. at the deadline of a request of a server. The copy shares the
+// client token:
+//
+//	kc := keychain.WithConfig(keychain.Config{Backend: b.WithContext(ctx)})
+//	password, err := kc.GetBytes("db", "alice")
+func (b *Backend) WithContext(ctx context.Context) *Backend {
+	c := *b
+	c.ctx = ctx
+
+	return &c
+}
+
+// item is a stored generic password.
+type item struct {
+	service, account string
+	data             []byte
+}
+
+// check returns an error for attributes the backend can't handle.
+func check(attrs map[string]interface{}) error {
+	if attrs[keychain.SecClassKey] != "genp" {
+		return fmt.Errorf("vault only stores generic passwords: %w", keychain.ErrorUnimplemented)
+	}
+
+	if ref, _ := attrs[keychain.ReturnPersistentRefKey].(bool); ref || attrs[keychain.ValuePersistentRefKey] != nil ||
+		attrs[keychain.MatchItemListKey] != nil {
+		return fmt.Errorf("vault has no persistent references: %w", keychain.ErrorUnimplemented)
+	}
+
+	return nil
+}
+
+// queryKeys are the attributes the backend matches in queries, or ignores
+// since all its items share them.
+var queryKeys = map[string]bool{
+	keychain.SecClassKey:                  true,
+	keychain.ServiceKey:                   true,
+	keychain.AccountKey:                   true,
+	keychain.AccessGroupKey:               true,
+	keychain.SynchronizableKey:            true,
+	keychain.AccessibleKey:                true,
+	keychain.UseDataProtectionKeychainKey: true,
+}
+
+// checkQuery returns an error for queries matching attributes the backend
+// doesn't store, which would otherwise match every item.
+func checkQuery(query map[string]interface{}) error {
+	for key, v := range query {
+		if queryKeys[key] || strings.HasPrefix(key, "m_") || strings.HasPrefix(key, "r_") || strings.HasPrefix(key, "u_") {
+			continue
+		}
+
+		if s, ok := v.(string); ok && s == "" {
+			continue
+		}
+
+		return fmt.Errorf("vault can't match attribute %s: %w", key, keychain.ErrorUnimplemented)
+	}
+
+	return nil
+}
+
+func stringAttr(attrs map[string]interface{}, key string) (string, bool) {
+	s, ok := attrs[key].(string)
+
+	return s, ok
+}
+
+// dataValue returns the secret value for item data.
+func dataValue(v interface{}) (string, error) {
+	b, _ := v.([]byte)
+	if !utf8.Valid(b) {
+		return "", fmt.Errorf("vault only stores UTF-8 data: %w", keychain.ErrorParam)
+	}
+
+	return string(b), nil
+}
+
+// services returns the services query may match.
+func (b *Backend) services(query map[string]interface{}) ([]string, error) {
+	if service, ok := stringAttr(query, keychain.ServiceKey); ok {
+		return []string{service}, nil
+	}
+
+	return b.list()
+}
+
+// matchAccount returns whether account is matched by query.
+func matchAccount(query map[string]interface{}, account string) bool {
+	want, ok := stringAttr(query, keychain.AccountKey)
+
+	return !ok || want == account
+}
+
+// find returns the items matching query, sorted, or ErrorItemNotFound.
+func (b *Backend) find(query map[string]interface{}) ([]item, error) {
+	services, err := b.services(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []item
+
+	for _, service := range services {
+		data, _, err := b.read(service)
+		if err != nil {
+			return nil, err
+		}
+
+		accounts := make([]string, 0, len(data))
+		for account := range data {
+			if matchAccount(query, account) {
+				accounts = append(accounts, account)
+			}
+		}
+
+		sort.Strings(accounts)
+
+		for _, account := range accounts {
+			found = append(found, item{service: service, account: account, data: []byte(data[account])})
+		}
+	}
+
+	if len(found) == 0 {
+		return nil, keychain.ErrorItemNotFound
+	}
+
+	return found, nil
+}
+
+// result shapes the result for it as requested by query.
+func result(it item, query map[string]interface{}) interface{} {
+	wantAttrs, _ := query[keychain.ReturnAttributesKey].(bool)
+	wantData, _ := query[keychain.ReturnDataKey].(bool)
+
+	if !wantAttrs && wantData {
+		return it.data
+	}
+
+	d := make(map[string]interface{})
+
+	if wantAttrs {
+		d[keychain.SecClassKey] = "genp"
+		d[keychain.ServiceKey] = it.service
+		d[keychain.AccountKey] = it.account
+	}
+
+	if wantData {
+		d[keychain.DataKey] = it.data
+	}
+
+	return d
+}
+
+// Capabilities implements keychain.CapabilityReporter. Items are unique by
+// service and account, regardless of access group.
+func (b *Backend) Capabilities() keychain.Capability {
+	return keychain.Capability{UniqueKeys: true}
+}
+
+// Add implements keychain.Backend.
+func (b *Backend) Add(attrs map[string]interface{}) (interface{}, error) {
+	if err := check(attrs); err != nil {
+		return nil, err
+	}
+
+	service, ok := stringAttr(attrs, keychain.ServiceKey)
+	if !ok {
+		return nil, fmt.Errorf("vault items need a service: %w", keychain.ErrorParam)
+	}
+
+	account, _ := stringAttr(attrs, keychain.AccountKey)
+
+	value, err := dataValue(attrs[keychain.DataKey])
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.modify(service, func(data map[string]string) (bool, error) {
+		if _, ok := data[account]; ok {
+			return false, keychain.ErrorDuplicateItem
+		}
+
+		data[account] = value
+
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !keychain.WantsResult(attrs) {
+		return nil, nil
+	}
+
+	return result(item{service: service, account: account, data: []byte(value)}, attrs), nil
+}
+
+// Update implements keychain.Backend. The service of items can't be changed.
+func (b *Backend) Update(query, attrs map[string]interface{}) error {
+	if err := check(query); err != nil {
+		return err
+	}
+
+	if err := checkQuery(query); err != nil {
+		return err
+	}
+
+	if _, ok := attrs[keychain.ServiceKey]; ok {
+		return fmt.Errorf("vault can't move items between services: %w", keychain.ErrorUnimplemented)
+	}
+
+	var value *string
+
+	if v, ok := attrs[keychain.DataKey]; ok {
+		s, err := dataValue(v)
+		if err != nil {
+			return err
+		}
+
+		value = &s
+	}
+
+	services, err := b.services(query)
+	if err != nil {
+		return err
+	}
+
+	found := false
+
+	for _, service := range services {
+		err := b.modify(service, func(data map[string]string) (bool, error) {
+			updated := make(map[string]string, len(data))
+
+			var matched []string
+
+			for account, v := range data {
+				if matchAccount(query, account) {
+					matched = append(matched, account)
+				} else {
+					updated[account] = v
+				}
+			}
+
+			for _, account := range matched {
+				v := data[account]
+
+				if newAccount, ok := stringAttr(attrs, keychain.AccountKey); ok {
+					account = newAccount
+				}
+
+				if value != nil {
+					v = *value
+				}
+
+				if _, ok := updated[account]; ok {
+					return false, keychain.ErrorDuplicateItem
+				}
+
+				updated[account] = v
+			}
+
+			if len(matched) == 0 {
+				return false, nil
+			}
+
+			for account := range data {
+				delete(data, account)
+			}
+
+			for account, v := range updated {
+				data[account] = v
+			}
+
+			found = true
+
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if !found {
+		return keychain.ErrorItemNotFound
+	}
+
+	return nil
+}
+
+// CopyMatching implements keychain.Backend.
+func (b *Backend) CopyMatching(query map[string]interface{}) (interface{}, error) {
+	if err := check(query); err != nil {
+		return nil, err
+	}
+
+	if err := checkQuery(query); err != nil {
+		return nil, err
+	}
+
+	found, err := b.find(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if !keychain.WantsResult(query) {
+		return nil, nil
+	}
+
+	if query[keychain.MatchLimitKey] != "m_LimitAll" {
+		return result(found[0], query), nil
+	}
+
+	results := make([]interface{}, 0, len(found))
+	for _, it := range found {
+		results = append(results, result(it, query))
+	}
+
+	return results, nil
+}
+
+// Delete implements keychain.Backend. Secrets left without accounts are
+// deleted with all their versions.
+func (b *Backend) Delete(query map[string]interface{}) error {
+	if err := check(query); err != nil {
+		return err
+	}
+
+	if err := checkQuery(query); err != nil {
+		return err
+	}
+
+	services, err := b.services(query)
+	if err != nil {
+		return err
+	}
+
+	found := false
+
+	for _, service := range services {
+		err := b.modify(service, func(data map[string]string) (bool, error) {
+			changed := false
+
+			for account := range data {
+				if matchAccount(query, account) {
+					delete(data, account)
+
+					changed = true
+				}
+			}
+
+			found = found || changed
+
+			return changed, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if !found {
+		return keychain.ErrorItemNotFound
+	}
+
+	return nil
+}
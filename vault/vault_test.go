@@ -0,0 +1,270 @@
+package vault_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/backendtest"
+	"github.com/mailstone/go-keychain/vault"
+)
+
+// fakeVault is a minimal Vault server with a KV version 2 engine at secret/
+// and AppRole auth.
+type fakeVault struct {
+	mu       sync.Mutex
+	secrets  map[string]map[string]string
+	versions map[string]int
+	tokens   map[string]bool
+	logins   int
+}
+
+func newFakeVault(t *testing.T) (*fakeVault, *httptest.Server) {
+	t.Helper()
+
+	v := &fakeVault{secrets: map[string]map[string]string{}, versions: map[string]int{}, tokens: map[string]bool{"root": true}}
+	srv := httptest.NewServer(v)
+	t.Cleanup(srv.Close)
+
+	return v, srv
+}
+
+func reply(w http.ResponseWriter, status int, v interface{}) {
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if r.URL.Path == "/v1/auth/approle/login" {
+		v.logins++
+		reply(w, http.StatusOK, map[string]interface{}{"auth": map[string]string{"client_token": "approle-token"}})
+		v.tokens["approle-token"] = true
+
+		return
+	}
+
+	if !v.tokens[r.Header.Get("X-Vault-Token")] {
+		reply(w, http.StatusForbidden, map[string][]string{"errors": {"permission denied"}})
+
+		return
+	}
+
+	switch path := r.URL.Path; {
+	case r.Method == "LIST":
+		prefix := strings.TrimPrefix(path, "/v1/secret/metadata/")
+
+		var keys []string
+
+		for p := range v.secrets {
+			if strings.HasPrefix(p, prefix) {
+				keys = append(keys, strings.TrimPrefix(p, prefix))
+			}
+		}
+
+		sort.Strings(keys)
+		reply(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"keys": keys}})
+	case strings.HasPrefix(path, "/v1/secret/data/") && r.Method == http.MethodGet:
+		p := strings.TrimPrefix(path, "/v1/secret/data/")
+		if v.secrets[p] == nil {
+			reply(w, http.StatusNotFound, map[string][]string{"errors": {}})
+
+			return
+		}
+
+		reply(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+			"data":     v.secrets[p],
+			"metadata": map[string]int{"version": v.versions[p]},
+		}})
+	case strings.HasPrefix(path, "/v1/secret/data/") && r.Method == http.MethodPost:
+		p := strings.TrimPrefix(path, "/v1/secret/data/")
+
+		var in struct {
+			Options struct{ CAS int } `json:"options"`
+			Data    map[string]string `json:"data"`
+		}
+
+		_ = json.NewDecoder(r.Body).Decode(&in)
+
+		if in.Options.CAS != v.versions[p] {
+			reply(w, http.StatusBadRequest, map[string][]string{"errors": {"check-and-set parameter did not match the current version"}})
+
+			return
+		}
+
+		v.secrets[p] = in.Data
+		v.versions[p]++
+		reply(w, http.StatusOK, map[string]interface{}{})
+	case strings.HasPrefix(path, "/v1/secret/metadata/") && r.Method == http.MethodGet:
+		reply(w, http.StatusNotFound, map[string][]string{"errors": {}})
+	case strings.HasPrefix(path, "/v1/secret/metadata/") && r.Method == http.MethodDelete:
+		p := strings.TrimPrefix(path, "/v1/secret/metadata/")
+		delete(v.secrets, p)
+		delete(v.versions, p)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		reply(w, http.StatusNotFound, map[string][]string{"errors": {}})
+	}
+}
+
+func useVault(t *testing.T, cfg vault.Config) {
+	t.Helper()
+
+	b, err := vault.Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prev := keychain.SetBackend(b)
+	t.Cleanup(func() { keychain.SetBackend(prev) })
+}
+
+func TestGenericPasswords(t *testing.T) {
+	v, srv := newFakeVault(t)
+	useVault(t, vault.Config{Address: srv.URL, Token: "root", Prefix: "myapp/"})
+
+	item := keychain.NewGenericPassword("db", "alice", "", []byte("secret"), "")
+	if err := keychain.AddItem(item); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.AddItem(item); !errors.Is(err, keychain.ErrorDuplicateItem) {
+		t.Fatalf("expected duplicate item, got %v", err)
+	}
+
+	if err := keychain.AddItem(keychain.NewGenericPassword("db", "bob", "", []byte("hunter2"), "")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := v.secrets["myapp/db"]; len(got) != 2 || got["alice"] != "secret" {
+		t.Fatalf("unexpected secret %v", got)
+	}
+
+	accounts, err := keychain.GetGenericPasswordAccounts("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(accounts, ",") != "alice,bob" {
+		t.Fatalf("unexpected accounts %v", accounts)
+	}
+
+	query := keychain.NewItem()
+	query.SetSecClass(keychain.SecClassGenericPassword)
+	query.SetService("db")
+	query.SetAccount("alice")
+
+	update := keychain.NewItem()
+	update.SetData([]byte("rotated"))
+
+	if err := keychain.UpdateItem(query, update); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := keychain.GetGenericPassword("db", "alice", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, []byte("rotated")) {
+		t.Fatalf("expected rotated, got %q", data)
+	}
+
+	// Labels aren't stored, so they can't narrow a deletion.
+	labeled := keychain.NewItem()
+	labeled.SetSecClass(keychain.SecClassGenericPassword)
+	labeled.SetService("db")
+	labeled.SetLabel("Alice")
+
+	if err := keychain.DeleteItem(labeled); !errors.Is(err, keychain.ErrorUnimplemented) {
+		t.Fatalf("expected unimplemented, got %v", err)
+	}
+
+	if err := keychain.DeleteGenericPasswordItem("db", "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keychain.DeleteGenericPasswordItem("db", "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := v.secrets["myapp/db"]; ok {
+		t.Fatal("expected the secret without accounts to be deleted")
+	}
+
+	if err := keychain.DeleteGenericPasswordItem("db", "bob"); !errors.Is(err, keychain.ErrorItemNotFound) {
+		t.Fatalf("expected item not found, got %v", err)
+	}
+}
+
+func TestAppRoleLogin(t *testing.T) {
+	v, srv := newFakeVault(t)
+	useVault(t, vault.Config{Address: srv.URL, RoleID: "role", SecretID: "secret"})
+
+	if err := keychain.AddItem(keychain.NewGenericPassword("db", "alice", "", []byte("secret"), "")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Expire the token; the backend logs in again.
+	v.mu.Lock()
+	delete(v.tokens, "approle-token")
+	v.mu.Unlock()
+
+	data, err := keychain.GetGenericPassword("db", "alice", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, []byte("secret")) || v.logins != 2 {
+		t.Fatalf("expected secret after 2 logins, got %q after %d", data, v.logins)
+	}
+}
+
+func TestWithContext(t *testing.T) {
+	v, srv := newFakeVault(t)
+
+	b, err := vault.Open(vault.Config{Address: srv.URL, RoleID: "role", SecretID: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	kc := keychain.WithConfig(keychain.Config{Backend: b.WithContext(ctx)})
+
+	if err := kc.SetBytes("db", "alice", []byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+
+	if _, err := kc.GetBytes("db", "alice"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// The backend shares the token of the cancelled copy.
+	data, err := keychain.WithConfig(keychain.Config{Backend: b}).GetBytes("db", "alice")
+	if err != nil || string(data) != "secret" || v.logins != 1 {
+		t.Fatalf("expected secret after 1 login, got %q (%v) after %d", data, err, v.logins)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	_, srv := newFakeVault(t)
+
+	b, err := vault.Open(vault.Config{Address: srv.URL, Token: "root"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backendtest.RunConformance(t, b)
+}
@@ -0,0 +1,288 @@
+package keychain
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultWatchPollInterval is the WatchPollInterval of a Config without one.
+const defaultWatchPollInterval = 2 * time.Second
+
+// SetWatchPollInterval sets how often watched items are checked for changes,
+// and returns the previous setting. Zero, the default, is 2 seconds. The
+// keychain has no public change notifications for arbitrary items, so
+// watching is implemented by polling attributes (not data) of each watched
+// item once per interval, regardless of the number of subscribers.
+func SetWatchPollInterval(interval time.Duration) time.Duration {
+	return updateConfig(func(cfg *Config) { cfg.WatchPollInterval = interval }).WatchPollInterval
+}
+
+// watchPollInterval returns the poll interval of the package configuration.
+func watchPollInterval() time.Duration {
+	if interval := cur().cfg.WatchPollInterval; interval > 0 {
+		return interval
+	}
+
+	return defaultWatchPollInterval
+}
+
+type watchKey struct {
+	service string
+	account string
+}
+
+type watchedItem struct {
+	exists   bool
+	modified time.Time
+	data     []byte
+	subs     map[chan QueryResult]struct{}
+}
+
+var watches = struct {
+	sync.Mutex
+	items   map[watchKey]*watchedItem
+	polling bool
+	// stop stops the polling goroutine, which closes done when it returns.
+	stop chan struct{}
+	done chan struct{}
+}{items: make(map[watchKey]*watchedItem)}
+
+// WatchItem watches the generic password for service and account and sends
+// its attributes and data on the returned channel whenever it is added or
+// changed. When the item is deleted, a result with only Service and Account
+// set (and nil Data) is sent. If the receiver falls behind, only the latest
+// value is kept. Call cancel to stop watching; it closes the channel.
+func WatchItem(service, account string) (<-chan QueryResult, func()) {
+	key := watchKey{service: service, account: account}
+	ch := make(chan QueryResult, 1)
+
+	watches.Lock()
+	w, ok := watches.items[key]
+	watches.Unlock()
+
+	if !ok {
+		// Capture the current state outside the lock so that only later changes
+		// are reported.
+		w = &watchedItem{subs: make(map[chan QueryResult]struct{})}
+		if r, found, err := readWatched(key); err == nil && found {
+			w.exists, w.modified, w.data = true, r.ModificationDate, r.Data
+		}
+	}
+
+	watches.Lock()
+	if existing, ok := watches.items[key]; ok {
+		w = existing
+	} else {
+		watches.items[key] = w
+	}
+
+	w.subs[ch] = struct{}{}
+
+	if !watches.polling {
+		watches.polling = true
+		watches.stop, watches.done = make(chan struct{}), make(chan struct{})
+
+		go pollWatches(watches.stop, watches.done)
+	}
+	watches.Unlock()
+
+	var once sync.Once
+
+	cancel := func() {
+		once.Do(func() {
+			watches.Lock()
+			defer watches.Unlock()
+
+			// Shutdown closes the channels itself.
+			if _, ok := w.subs[ch]; !ok {
+				return
+			}
+
+			delete(w.subs, ch)
+
+			if len(w.subs) == 0 && watches.items[key] == w {
+				delete(watches.items, key)
+			}
+
+			close(ch)
+		})
+	}
+
+	return ch, cancel
+}
+
+func pollWatches(stop, done chan struct{}) {
+	defer close(done)
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(watchPollInterval()):
+		}
+
+		watches.Lock()
+		if watches.stop != stop {
+			watches.Unlock()
+
+			return
+		}
+
+		if len(watches.items) == 0 {
+			watches.polling = false
+			watches.Unlock()
+
+			return
+		}
+
+		keys := make([]watchKey, 0, len(watches.items))
+		for key := range watches.items {
+			keys = append(keys, key)
+		}
+		watches.Unlock()
+
+		for _, key := range keys {
+			pollWatch(key)
+		}
+	}
+}
+
+func pollWatch(key watchKey) {
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService(key.service)
+	query.SetAccount(key.account)
+	query.SetMatchLimit(MatchLimitOne)
+	query.SetReturnAttributes(true)
+
+	results, err := QueryItem(query)
+	if err != nil {
+		// Transient failures (e.g. locked keychain) are retried next interval.
+		return
+	}
+
+	watches.Lock()
+	w, ok := watches.items[key]
+	var changed bool
+	if ok {
+		changed = len(results) == 1 && (!w.exists || !w.modified.Equal(results[0].ModificationDate))
+		changed = changed || (len(results) == 0 && w.exists)
+	}
+	watches.Unlock()
+
+	if !changed {
+		return
+	}
+
+	r, found, err := readWatched(key)
+	if err != nil {
+		return
+	}
+
+	if !found {
+		r = QueryResult{Service: key.service, Account: key.account}
+	}
+
+	watches.Lock()
+	defer watches.Unlock()
+
+	if w, ok = watches.items[key]; !ok {
+		return
+	}
+
+	if !found && !w.exists {
+		return
+	}
+
+	if found && w.exists && w.modified.Equal(r.ModificationDate) && bytes.Equal(w.data, r.Data) {
+		return
+	}
+
+	w.exists, w.modified, w.data = found, r.ModificationDate, r.Data
+
+	for ch := range w.subs {
+		sendLatest(ch, r)
+	}
+}
+
+// stopWatches closes the channels of all watched items and stops polling
+// them, waiting for a poll in progress until ctx is done.
+func stopWatches(ctx context.Context) error {
+	watches.Lock()
+
+	for key, w := range watches.items {
+		for ch := range w.subs {
+			delete(w.subs, ch)
+			close(ch)
+		}
+
+		delete(watches.items, key)
+	}
+
+	done := watches.done
+	if watches.polling {
+		close(watches.stop)
+		watches.polling, watches.stop = false, nil
+	}
+	watches.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// notifyChange reports a change of a watched item made by this process without
+// waiting for the next poll.
+func notifyChange(service, account string) {
+	key := watchKey{service: service, account: account}
+
+	watches.Lock()
+	_, ok := watches.items[key]
+	watches.Unlock()
+
+	if ok {
+		pollWatch(key)
+	}
+}
+
+// sendLatest sends r without blocking, replacing an unreceived older value.
+func sendLatest(ch chan QueryResult, r QueryResult) {
+	for {
+		select {
+		case ch <- r:
+			return
+		default:
+		}
+
+		select {
+		case <-ch:
+		default:
+		}
+	}
+}
+
+func readWatched(key watchKey) (QueryResult, bool, error) {
+	query := NewItem()
+	query.SetSecClass(SecClassGenericPassword)
+	query.SetService(key.service)
+	query.SetAccount(key.account)
+	query.SetMatchLimit(MatchLimitOne)
+	query.SetReturnAttributes(true)
+	query.SetReturnData(true)
+
+	results, err := QueryItem(query)
+	if err != nil || len(results) != 1 {
+		return QueryResult{}, false, err
+	}
+
+	return results[0], true, nil
+}
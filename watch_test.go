@@ -0,0 +1,46 @@
+//go:build darwin && !ios
+// +build darwin,!ios
+
+package keychain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatchItem(t *testing.T) {
+	service, account := "TestWatchItem", "test"
+	defer func() { _ = DeleteGenericPasswordItem(service, account) }()
+
+	prev := SetWatchPollInterval(50 * time.Millisecond)
+	defer SetWatchPollInterval(prev)
+
+	ch, cancel := WatchItem(service, account)
+	defer cancel()
+
+	if err := AddItem(NewGenericPassword(service, account, "", []byte("v1"), "")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case r := <-ch:
+		if string(r.Data) != "v1" {
+			t.Fatalf("expected v1, got %q", r.Data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for add")
+	}
+
+	if err := DeleteGenericPasswordItem(service, account); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case r := <-ch:
+		if r.Data != nil || r.Account != account {
+			t.Fatalf("expected deletion marker, got %+v", r)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for delete")
+	}
+}
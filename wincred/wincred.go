@@ -0,0 +1,26 @@
+// Package wincred implements a keychain backend storing generic passwords in
+// the Windows Credential Manager (CredWrite), as generic credentials of the
+// current user. Credentials hold at most 2560 bytes; see the dpapi package for
+// larger items.
+//
+// Importing the package registers the backend, which the keychain package
+// then uses by default on Windows:
+//
+//	import _ "github.com/mailstone/go-keychain/wincred"
+//
+//	data, err := keychain.GetGenericPassword("MyService", "gabriel", "", "")
+//
+// Each item is a credential whose target name starts with Prefix and is
+// derived from its service and account, with the account as user name and the
+// service, account, label, description and comment as credential attributes
+// (keychain-service, keychain-account, ...).
+package wincred
+
+// Name is the name the backend is registered under, see keychain.UseBackend.
+const Name = "wincred"
+
+// Prefix is the start of the target names of the credentials of the backend.
+const Prefix = "go-keychain:"
+
+// MaxDataSize is the size limit of credentials (CRED_MAX_CREDENTIAL_BLOB_SIZE).
+const MaxDataSize = 5 * 512
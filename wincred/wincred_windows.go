@@ -0,0 +1,261 @@
+//go:build windows
+// +build windows
+
+package wincred
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	keychain "github.com/mailstone/go-keychain"
+	"github.com/mailstone/go-keychain/internal/cloudsecret"
+)
+
+func init() {
+	keychain.RegisterBackend(Name, Open)
+}
+
+// Open returns a backend storing items in the Credential Manager.
+func Open() (keychain.Backend, error) {
+	b := cloudsecret.New(provider{}, Prefix)
+	b.SetMaxDataSize(MaxDataSize)
+
+	return b, nil
+}
+
+const (
+	credTypeGeneric          = 1
+	credPersistLocalMachine  = 2
+	errorNotFound            = syscall.Errno(1168)
+	credMaxAttributeValueLen = 256
+)
+
+var (
+	advapi32           = syscall.NewLazyDLL("advapi32.dll")
+	procCredWriteW     = advapi32.NewProc("CredWriteW")
+	procCredReadW      = advapi32.NewProc("CredReadW")
+	procCredEnumerateW = advapi32.NewProc("CredEnumerateW")
+	procCredDeleteW    = advapi32.NewProc("CredDeleteW")
+	procCredFree       = advapi32.NewProc("CredFree")
+)
+
+// credential is CREDENTIALW.
+type credential struct {
+	flags          uint32
+	typ            uint32
+	targetName     *uint16
+	comment        *uint16
+	lastWritten    syscall.Filetime
+	blobSize       uint32
+	blob           *byte
+	persist        uint32
+	attributeCount uint32
+	attributes     *credentialAttribute
+	targetAlias    *uint16
+	userName       *uint16
+}
+
+// credentialAttribute is CREDENTIAL_ATTRIBUTEW.
+type credentialAttribute struct {
+	keyword   *uint16
+	flags     uint32
+	valueSize uint32
+	value     *byte
+}
+
+// secret copies the credential, which was allocated by the Credential Manager.
+func (c *credential) secret() cloudsecret.Secret {
+	s := cloudsecret.Secret{
+		Name:   syscall.UTF16ToString(unsafe.Slice(c.targetName, wcslen(c.targetName))),
+		Labels: make(map[string]string),
+		Data:   []byte{},
+	}
+
+	if c.blobSize > 0 {
+		s.Data = append(s.Data, unsafe.Slice(c.blob, c.blobSize)...)
+	}
+
+	for _, a := range unsafe.Slice(c.attributes, c.attributeCount) {
+		s.Labels[syscall.UTF16ToString(unsafe.Slice(a.keyword, wcslen(a.keyword)))] = string(unsafe.Slice(a.value, a.valueSize))
+	}
+
+	return s
+}
+
+func wcslen(p *uint16) int {
+	if p == nil {
+		return 0
+	}
+
+	n := 0
+	for ; *(*uint16)(unsafe.Add(unsafe.Pointer(p), n*2)) != 0; n++ {
+	}
+
+	return n
+}
+
+// provider stores secrets as generic credentials.
+type provider struct{}
+
+func (provider) read(name string) (cloudsecret.Secret, error) {
+	target, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return cloudsecret.Secret{}, err
+	}
+
+	var c *credential
+
+	r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&c)))
+	if r == 0 {
+		if errors.Is(err, errorNotFound) {
+			return cloudsecret.Secret{}, cloudsecret.ErrNotFound
+		}
+
+		return cloudsecret.Secret{}, fmt.Errorf("CredRead failed: %w", err)
+	}
+
+	defer procCredFree.Call(uintptr(unsafe.Pointer(c))) //nolint:errcheck
+
+	return c.secret(), nil
+}
+
+func (provider) write(s cloudsecret.Secret) error {
+	target, err := syscall.UTF16PtrFromString(s.Name)
+	if err != nil {
+		return err
+	}
+
+	c := credential{typ: credTypeGeneric, targetName: target, persist: credPersistLocalMachine}
+
+	if account := s.Labels["keychain-account"]; account != "" {
+		if c.userName, err = syscall.UTF16PtrFromString(account); err != nil {
+			return err
+		}
+	}
+
+	if len(s.Data) > 0 {
+		c.blobSize, c.blob = uint32(len(s.Data)), &s.Data[0]
+	}
+
+	attrs := make([]credentialAttribute, 0, len(s.Labels))
+
+	for k, v := range s.Labels {
+		if len(v) > credMaxAttributeValueLen {
+			return fmt.Errorf("%s is longer than %d bytes: %w", k, credMaxAttributeValueLen, keychain.ErrorParam)
+		}
+
+		keyword, err := syscall.UTF16PtrFromString(k)
+		if err != nil {
+			return err
+		}
+
+		a := credentialAttribute{keyword: keyword, valueSize: uint32(len(v))}
+		if len(v) > 0 {
+			a.value = unsafe.StringData(v)
+		}
+
+		attrs = append(attrs, a)
+	}
+
+	if len(attrs) > 0 {
+		c.attributeCount, c.attributes = uint32(len(attrs)), &attrs[0]
+	}
+
+	r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&c)), 0)
+	if r == 0 {
+		return fmt.Errorf("CredWrite failed: %w", err)
+	}
+
+	return nil
+}
+
+func (p provider) Create(s cloudsecret.Secret) error {
+	if _, err := p.read(s.Name); !errors.Is(err, cloudsecret.ErrNotFound) {
+		if err == nil {
+			return cloudsecret.ErrExists
+		}
+
+		return err
+	}
+
+	return p.write(s)
+}
+
+func (p provider) Get(name string) (cloudsecret.Secret, error) {
+	return p.read(name)
+}
+
+func (p provider) Update(name string, data []byte, labels map[string]string) error {
+	s, err := p.read(name)
+	if err != nil {
+		return err
+	}
+
+	s.Labels = labels
+	if data != nil {
+		s.Data = data
+	}
+
+	return p.write(s)
+}
+
+func (provider) Delete(name string) error {
+	target, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return err
+	}
+
+	r, _, err := procCredDeleteW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0)
+	if r == 0 {
+		if errors.Is(err, errorNotFound) {
+			return cloudsecret.ErrNotFound
+		}
+
+		return fmt.Errorf("CredDelete failed: %w", err)
+	}
+
+	return nil
+}
+
+func (provider) List() ([]cloudsecret.Secret, error) {
+	filter, err := syscall.UTF16PtrFromString(Prefix + "*")
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		count uint32
+		creds **credential
+	)
+
+	r, _, err := procCredEnumerateW.Call(uintptr(unsafe.Pointer(filter)), 0, uintptr(unsafe.Pointer(&count)), uintptr(unsafe.Pointer(&creds)))
+	if r == 0 {
+		if errors.Is(err, errorNotFound) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("CredEnumerate failed: %w", err)
+	}
+
+	defer procCredFree.Call(uintptr(unsafe.Pointer(creds))) //nolint:errcheck
+
+	secrets := make([]cloudsecret.Secret, 0, count)
+
+	for _, c := range unsafe.Slice(creds, count) {
+		if c.typ != credTypeGeneric {
+			continue
+		}
+
+		s := c.secret()
+		if s.Labels[cloudsecret.ClassLabel] == "" {
+			continue
+		}
+
+		s.Data = nil
+		secrets = append(secrets, s)
+	}
+
+	return secrets, nil
+}
@@ -0,0 +1,20 @@
+//go:build windows
+// +build windows
+
+package wincred_test
+
+import (
+	"testing"
+
+	"github.com/mailstone/go-keychain/backendtest"
+	"github.com/mailstone/go-keychain/wincred"
+)
+
+func TestConformance(t *testing.T) {
+	b, err := wincred.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backendtest.RunConformance(t, b)
+}